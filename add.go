@@ -42,6 +42,10 @@ import (
 type AddAndCopyOptions struct {
 	// Chmod sets the access permissions of the destination content.
 	Chmod string
+	// ChmodDirs and ChmodFiles set the access permissions of directories
+	// and of everything else in the destination content, respectively,
+	// overriding Chmod.
+	ChmodDirs, ChmodFiles string
 	// Chown is a spec for the user who should be given ownership over the
 	// newly-added content, potentially overriding permissions which would
 	// otherwise be set to 0:0.
@@ -104,6 +108,18 @@ type AddAndCopyOptions struct {
 	Parents bool
 	// Timestamp is a timestamp to override on all content as it is being read.
 	Timestamp *time.Time
+	// ClampTimestamp causes Timestamp to only be applied to content which
+	// is newer than it, so that it serves as an upper bound.
+	ClampTimestamp bool
+	// DropPatterns is a list of patterns for the names of items which
+	// should be left out of the destination, including the contents of
+	// archives that we extract.  Patterns which don't include a "/" are
+	// compared to every component of an item's name.
+	DropPatterns []string
+	// UnixLineEndings is a list of patterns, in the same format as
+	// DropPatterns, for the names of regular files in which CRLF line
+	// endings should be converted to LF.
+	UnixLineEndings []string
 	// Link, when set to true, creates an independent layer containing the copied content
 	// that sits on top of existing layers. This layer can be cached and reused
 	// separately, and is not affected by filesystem changes from previous instructions.
@@ -125,10 +141,39 @@ type AddAndCopyOptions struct {
 	// FollowSymlink controls whether symlinks should be followed when copying content.
 	// When set to false, symlinks are not dereferenced.
 	FollowSymlink types.OptionalBool
+	// NoClone disables reflinking of regular files from the context
+	// directory into the container, which is otherwise attempted when the
+	// two are on the same filesystem and it supports reflinks, with a
+	// fallback to copying contents.  Cloning is also skipped if Hasher
+	// is set.
+	NoClone bool
+	// HardlinkContext permits hard linking regular files from the context
+	// directory into the container, instead of copying or reflinking them,
+	// if their ownership, permissions, and timestamps would not need to
+	// be changed.  Since the container and the context directory would
+	// then share those files, it should only be set if the files in the
+	// context directory will never be modified, for example if it's a
+	// read-only snapshot, and if nothing will modify the copies in the
+	// container, for example because it will be committed and removed
+	// without running anything in it.
+	HardlinkContext bool
+	// Parallel is the number of workers to use for reading and writing
+	// the contents of directory trees in the context directory, and for
+	// digesting them while they're being written.  The archive that's
+	// digested is the same either way.  Values less than 2 mean that items
+	// are read and written one at a time.
+	Parallel int
+	// SaveRemoteContent, if set, is called for each URL which content is
+	// downloaded from and each Git repository which is cloned, and returns
+	// a location, which must not already exist, where a copy of the
+	// content should be kept, or "" if no copy is needed.  A downloaded
+	// file is saved in a new directory at that location, and a clone is
+	// moved there after its contents have been copied.
+	SaveRemoteContent func(src string) (string, error)
 }
 
 // getURL writes a tar archive containing the named content
-func getURL(src string, chown *idtools.IDPair, mountpoint, renameTarget string, writer io.Writer, chmod *os.FileMode, srcDigest digest.Digest, certPath string, insecureSkipTLSVerify types.OptionalBool, timestamp *time.Time) error {
+func getURL(src string, chown *idtools.IDPair, mountpoint, renameTarget string, writer io.Writer, chmod *os.FileMode, srcDigest digest.Digest, certPath string, insecureSkipTLSVerify types.OptionalBool, timestamp *time.Time, clampTimestamp bool, saveDir string) error {
 	url, err := url.Parse(src)
 	if err != nil {
 		return err
@@ -169,7 +214,7 @@ func getURL(src string, chown *idtools.IDPair, mountpoint, renameTarget string,
 	// If there's a date on the content, use it.  If not, use the Unix epoch
 	// or a specified value for compatibility.
 	date := time.Unix(0, 0).UTC()
-	if timestamp != nil {
+	if timestamp != nil && !clampTimestamp {
 		date = timestamp.UTC()
 	} else {
 		lastModified := response.Header.Get("Last-Modified")
@@ -181,6 +226,10 @@ func getURL(src string, chown *idtools.IDPair, mountpoint, renameTarget string,
 			date = d.UTC()
 		}
 	}
+	// If we're clamping, don't let the date be later than the timestamp.
+	if timestamp != nil && date.After(timestamp.UTC()) {
+		date = timestamp.UTC()
+	}
 	// Figure out the size of the content.
 	size := response.ContentLength
 	var responseBody io.Reader = response.Body
@@ -208,6 +257,23 @@ func getURL(src string, chown *idtools.IDPair, mountpoint, renameTarget string,
 		digester = srcDigest.Algorithm().Digester()
 		responseBody = io.TeeReader(responseBody, digester.Hash())
 	}
+	// Save a copy of the content if we were asked to.  If we're retrying,
+	// this replaces whatever we saved last time.
+	if saveDir != "" {
+		saveName := path.Base(name)
+		if saveName == "." || saveName == "/" {
+			saveName = "download"
+		}
+		if err := os.MkdirAll(saveDir, 0o700); err != nil {
+			return fmt.Errorf("creating directory to hold a copy of %q: %w", src, err)
+		}
+		f, err := os.Create(filepath.Join(saveDir, saveName))
+		if err != nil {
+			return fmt.Errorf("creating file to hold a copy of %q: %w", src, err)
+		}
+		defer f.Close()
+		responseBody = io.TeeReader(responseBody, f)
+	}
 	// Write the output archive.  Set permissions for compatibility.
 	tw := tar.NewWriter(writer)
 	defer tw.Close()
@@ -411,14 +477,31 @@ func (b *Builder) Add(destination string, extract bool, options AddAndCopyOption
 			return fmt.Errorf("looking up UID/GID for %q: %w", options.Chown, err)
 		}
 	}
-	var chmodDirsFiles *os.FileMode
-	if options.Chmod != "" {
-		p, err := strconv.ParseUint(options.Chmod, 8, 32)
+	parseChmod := func(chmod string) (*os.FileMode, error) {
+		if chmod == "" {
+			return nil, nil
+		}
+		p, err := strconv.ParseUint(chmod, 8, 32)
 		if err != nil {
-			return fmt.Errorf("parsing chmod %q: %w", options.Chmod, err)
+			return nil, fmt.Errorf("parsing chmod %q: %w", chmod, err)
 		}
 		perm := os.FileMode(p)
-		chmodDirsFiles = &perm
+		return &perm, nil
+	}
+	chmodDirs, err := parseChmod(options.Chmod)
+	if err != nil {
+		return err
+	}
+	chmodFiles := chmodDirs
+	if options.ChmodDirs != "" {
+		if chmodDirs, err = parseChmod(options.ChmodDirs); err != nil {
+			return err
+		}
+	}
+	if options.ChmodFiles != "" {
+		if chmodFiles, err = parseChmod(options.ChmodFiles); err != nil {
+			return err
+		}
 	}
 
 	chownDirs = &idtools.IDPair{UID: int(userUID), GID: int(userGID)}
@@ -597,6 +680,13 @@ func (b *Builder) Add(destination string, extract bool, options AddAndCopyOption
 				}
 			}
 
+			var saveDir string
+			if options.SaveRemoteContent != nil {
+				if saveDir, err = options.SaveRemoteContent(src); err != nil {
+					return err
+				}
+			}
+
 			wg.Add(1)
 			if urlsource.IsGit(src) {
 				go func() {
@@ -614,24 +704,30 @@ func (b *Builder) Add(destination string, extract bool, options AddAndCopyOption
 						Excludes:           options.Excludes,
 						ExpandArchives:     extract,
 						ChownDirs:          chownDirs,
-						ChmodDirs:          chmodDirsFiles,
+						ChmodDirs:          chmodDirs,
 						ChownFiles:         chownFiles,
-						ChmodFiles:         chmodDirsFiles,
+						ChmodFiles:         chmodFiles,
 						KeepDirectoryNames: options.DirCopyContents == types.OptionalBoolFalse,
 						NoDerefSymlinks:    options.FollowSymlink == types.OptionalBoolFalse,
 						StripSetuidBit:     options.StripSetuidBit,
 						StripSetgidBit:     options.StripSetgidBit,
 						StripStickyBit:     options.StripStickyBit,
 						Timestamp:          options.Timestamp,
+						ClampTimestamp:     options.ClampTimestamp,
+						DropPatterns:       options.DropPatterns,
+						UnixLineEndings:    options.UnixLineEndings,
 					}
 					writer := io.WriteCloser(pipeWriter)
 					repositoryDir := filepath.Join(cloneDir, subdir)
 					getErr = copier.Get(repositoryDir, repositoryDir, getOptions, []string{"."}, writer)
+					if getErr == nil && saveDir != "" {
+						getErr = os.Rename(repositoryDir, saveDir)
+					}
 				}()
 			} else {
 				go func() {
 					getErr = retry.IfNecessary(context.TODO(), func() error {
-						return getURL(src, chownFiles, mountPoint, renameTarget, pipeWriter, chmodDirsFiles, srcDigest, options.CertPath, options.InsecureSkipTLSVerify, options.Timestamp)
+						return getURL(src, chownFiles, mountPoint, renameTarget, pipeWriter, chmodFiles, srcDigest, options.CertPath, options.InsecureSkipTLSVerify, options.Timestamp, options.ClampTimestamp, saveDir)
 					}, &retry.Options{
 						MaxRetry: options.MaxRetries,
 						Delay:    options.RetryDelay,
@@ -742,11 +838,54 @@ func (b *Builder) Add(destination string, extract bool, options AddAndCopyOption
 			if options.Link && st.ModTime.After(latestTimestamp) {
 				latestTimestamp = st.ModTime
 			}
+			// the archive will note where regular files came from, so
+			// that the receiver can clone them or read them itself;
+			// Hasher callers expect the data
+			clone := !options.NoClone && options.Hasher == nil && !options.DryRun
+			if st.IsDir {
+				b.ContentDigester.Start("dir")
+			} else {
+				b.ContentDigester.Start("file")
+			}
+			hashCloser := b.ContentDigester.Hash()
+			hashers := []io.Writer{hashCloser}
+			if options.Hasher != nil {
+				hashers = append(hashers, options.Hasher)
+			}
+			// digest the archive while it's being written out; a
+			// digest over one stream can't be divided up without
+			// changing its value, but each digest can have a
+			// worker of its own
+			var asyncHashers []*asyncWriter
+			if options.Parallel > 1 && !options.DryRun {
+				for i := range hashers {
+					asyncHasher := newAsyncWriter(hashers[i], options.Parallel*16)
+					asyncHashers = append(asyncHashers, asyncHasher)
+					hashers[i] = asyncHasher
+				}
+			}
+			hasher := io.MultiWriter(hashers...)
 			pipeReader, pipeWriter := io.Pipe()
 			wg.Add(1)
 			go func() {
 				renamedItems := 0
 				writer := io.WriteCloser(pipeWriter)
+				if clone {
+					// digest the contents of files that the
+					// receiver will read for itself, but don't
+					// send them
+					writer = newTarFilterer(writer, func(hdr *tar.Header) (bool, bool, io.Reader) {
+						if _, ok := hdr.PAXRecords[copier.CloneSourcePAXRecord]; ok && hdr.Typeflag == tar.TypeReg {
+							hdr.PAXRecords[copier.CloneSizePAXRecord] = strconv.FormatInt(hdr.Size, 10)
+							hdr.Size = 0
+						}
+						return false, false, nil
+					})
+				}
+				writer = struct {
+					io.Writer
+					io.Closer
+				}{io.MultiWriter(hasher, writer), writer}
 				if renameTarget != "" {
 					writer = newTarFilterer(writer, func(hdr *tar.Header) (bool, bool, io.Reader) {
 						hdr.Name = renameTarget
@@ -783,18 +922,23 @@ func (b *Builder) Add(destination string, extract bool, options AddAndCopyOption
 					Excludes:           options.Excludes,
 					ExpandArchives:     extract,
 					ChownDirs:          chownDirs,
-					ChmodDirs:          chmodDirsFiles,
+					ChmodDirs:          chmodDirs,
 					ChownFiles:         chownFiles,
-					ChmodFiles:         chmodDirsFiles,
+					ChmodFiles:         chmodFiles,
 					KeepDirectoryNames: options.DirCopyContents == types.OptionalBoolFalse,
 					StripSetuidBit:     options.StripSetuidBit,
 					StripSetgidBit:     options.StripSetgidBit,
 					StripStickyBit:     options.StripStickyBit,
 					Parents:            options.Parents,
 					Timestamp:          options.Timestamp,
+					ClampTimestamp:     options.ClampTimestamp,
+					DropPatterns:       options.DropPatterns,
+					UnixLineEndings:    options.UnixLineEndings,
 					DisallowWildcard:   options.AllowWildcard == types.OptionalBoolFalse,
 					AllowEmptyWildcard: options.AllowEmptyWildcard == types.OptionalBoolTrue,
 					NoDerefSymlinks:    options.FollowSymlink == types.OptionalBoolFalse,
+					NoteSources:        clone,
+					Parallel:           options.Parallel,
 				}
 				getErr = copier.Get(contextDir, contextDir, getOptions, []string{globbedToGlobbable(globbed)}, writer)
 				closeErr = writer.Close()
@@ -805,18 +949,8 @@ func (b *Builder) Add(destination string, extract bool, options AddAndCopyOption
 			}()
 			wg.Add(1)
 			go func() {
-				if st.IsDir {
-					b.ContentDigester.Start("dir")
-				} else {
-					b.ContentDigester.Start("file")
-				}
-				hashCloser := b.ContentDigester.Hash()
-				hasher := io.Writer(hashCloser)
-				if options.Hasher != nil {
-					hasher = io.MultiWriter(hasher, options.Hasher)
-				}
 				if options.DryRun {
-					_, putErr = io.Copy(hasher, pipeReader)
+					_, putErr = io.Copy(io.Discard, pipeReader)
 				} else {
 					putOptions := copier.PutOptions{
 						UIDMap:          destUIDMap,
@@ -828,15 +962,25 @@ func (b *Builder) Add(destination string, extract bool, options AddAndCopyOption
 						ChownFiles:      nil,
 						ChmodFiles:      nil,
 						IgnoreDevices:   userns.RunningInUserNS(),
+						Parallel:        options.Parallel,
 					}
-					putErr = copier.Put(putRoot, putDir, putOptions, io.TeeReader(pipeReader, hasher))
+					if clone {
+						putOptions.CloneFrom = contextDir
+						putOptions.HardlinkClones = options.HardlinkContext
+					}
+					putErr = copier.Put(putRoot, putDir, putOptions, pipeReader)
 				}
-				hashCloser.Close()
 				pipeReader.Close()
 				wg.Done()
 			}()
 
 			wg.Wait()
+			for _, asyncHasher := range asyncHashers {
+				if err := asyncHasher.Close(); err != nil && putErr == nil {
+					putErr = err
+				}
+			}
+			hashCloser.Close()
 			if getErr != nil {
 				getErr = fmt.Errorf("reading %q: %w", src, getErr)
 			}
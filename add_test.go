@@ -1,10 +1,20 @@
 package buildah
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
+	digest "github.com/opencontainers/go-digest"
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.podman.io/image/v5/types"
+	"go.podman.io/storage"
+	storageTypes "go.podman.io/storage/types"
 )
 
 func TestDirCopyContentsToKeepDirectoryNames(t *testing.T) {
@@ -36,3 +46,54 @@ func TestDirCopyContentsToKeepDirectoryNames(t *testing.T) {
 		})
 	}
 }
+
+func TestAddParallelDigests(t *testing.T) {
+	// This test can't be parallelized; see TestCommitLinkedLayers.
+	ctx := context.TODO()
+	graphDriverName := os.Getenv("STORAGE_DRIVER")
+	if graphDriverName == "" {
+		graphDriverName = "vfs"
+	}
+	store, err := storage.GetStore(storageTypes.StoreOptions{
+		RunRoot:         t.TempDir(),
+		GraphRoot:       t.TempDir(),
+		GraphDriverName: graphDriverName,
+	})
+	require.NoError(t, err, "initializing storage")
+	t.Cleanup(func() { _, err := store.Shutdown(true); assert.NoError(t, err) })
+
+	contextDir := t.TempDir()
+	for i := range 64 {
+		subdir := filepath.Join(contextDir, fmt.Sprintf("dir%d", i%4))
+		require.NoError(t, os.MkdirAll(subdir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(subdir, fmt.Sprintf("file%d", i)), bytes.Repeat([]byte{byte(i)}, i*1000), 0o644))
+	}
+
+	b, err := NewBuilder(ctx, store, BuilderOptions{
+		FromImage: "scratch",
+		NamespaceOptions: []NamespaceOption{{
+			Name: string(rspec.NetworkNamespace),
+			Host: true,
+		}},
+		SystemContext: &testSystemContext,
+	})
+	require.NoError(t, err, "creating builder")
+	t.Cleanup(func() { assert.NoError(t, b.Delete()) })
+
+	// the digests shouldn't depend on how many workers there are, even
+	// when each of them is computed in its own goroutine
+	var expectedContent, expectedHasher string
+	for _, parallel := range []int{1, 4} {
+		hasher := digest.Canonical.Digester()
+		b.ContentDigester.Restart()
+		err := b.Add(fmt.Sprintf("/parallel%d", parallel), false, AddAndCopyOptions{Parallel: parallel, Hasher: hasher.Hash()}, contextDir)
+		require.NoErrorf(t, err, "adding with %d workers", parallel)
+		_, contentDigest := b.ContentDigester.Digest()
+		if expectedContent == "" {
+			expectedContent, expectedHasher = contentDigest.String(), hasher.Digest().String()
+			continue
+		}
+		assert.Equalf(t, expectedContent, contentDigest.String(), "content digest with %d workers", parallel)
+		assert.Equalf(t, expectedHasher, hasher.Digest().String(), "hasher digest with %d workers", parallel)
+	}
+}
@@ -324,6 +324,15 @@ func runUsingChrootMain() {
 		defer stdoutRead.Close()
 		defer stderrRead.Close()
 	}
+	// Our stdin is probably shared with the process that started us, so
+	// note whether it's in blocking mode before we change that, so that we
+	// can put it back the way we found it.
+	stdinBlocked := false
+	if _, ok := relays[unix.Stdin]; ok {
+		if mask, err := unix.FcntlInt(uintptr(unix.Stdin), unix.F_GETFL, 0); err == nil {
+			stdinBlocked = mask&unix.O_NONBLOCK == 0
+		}
+	}
 	for readFd, writeFd := range relays {
 		if err := unix.SetNonblock(readFd, true); err != nil {
 			logrus.Errorf("error setting descriptor %d (%s) non-blocking: %v", readFd, fdDesc[readFd], err)
@@ -443,6 +452,11 @@ func runUsingChrootMain() {
 
 	// Set up mounts and namespaces, and run the parent subprocess.
 	status, err := runUsingChroot(options.Spec, options.BundlePath, ctty, stdin, stdout, stderr, noPivot, closeOnceRunning)
+	if stdinBlocked {
+		if err := unix.SetNonblock(unix.Stdin, false); err != nil {
+			logrus.Debugf("error setting stdin back to blocking: %v", err)
+		}
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error running subprocess: %v\n", err)
 		os.Exit(1)
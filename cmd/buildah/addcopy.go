@@ -13,6 +13,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"go.podman.io/buildah"
+	"go.podman.io/buildah/internal"
 	"go.podman.io/buildah/pkg/cli"
 	"go.podman.io/buildah/pkg/parse"
 	"go.podman.io/common/pkg/auth"
@@ -46,6 +47,13 @@ type addCopyResults struct {
 	allowWildcard      bool
 	allowEmptyWildcard bool
 	noFollowSymlinks   bool
+	noClone            bool
+	jobs               int
+	chmodDirs          string
+	chmodFiles         string
+	clampTimestamp     bool
+	drop               []string
+	unixLineEndings    []string
 }
 
 func createCommand(addCopy string, desc string, short string, opts *addCopyResults) *cobra.Command {
@@ -79,8 +87,13 @@ func applyFlagVars(flags *pflag.FlagSet, opts *addCopyResults) {
 	flags.StringVar(&opts.checksum, "checksum", "", "checksum the HTTP source content")
 	flags.StringVar(&opts.chown, "chown", "", "set the user and group ownership of the destination content")
 	flags.StringVar(&opts.chmod, "chmod", "", "set the access permissions of the destination content")
+	flags.StringVar(&opts.chmodDirs, "chmod-dirs", "", "set the access permissions of directories in the destination content")
+	flags.StringVar(&opts.chmodFiles, "chmod-files", "", "set the access permissions of non-directories in the destination content")
+	flags.BoolVar(&opts.clampTimestamp, "clamp-timestamp", false, "only change timestamps which are later than the --timestamp value or $"+internal.SourceDateEpochName)
 	flags.StringVar(&opts.creds, "creds", "", "use `[username[:password]]` for accessing registries when pulling images")
+	flags.IntVar(&opts.jobs, "jobs", 1, "number of workers to use for reading and writing the contents of directories")
 	flags.BoolVar(&opts.link, "link", false, "enable layer caching for this operation (creates an independent layer)")
+	flags.BoolVar(&opts.noClone, "no-clone", false, "do not reflink files from the context directory, always copy their contents")
 	flags.BoolVar(&opts.noFollowSymlinks, "no-follow-symlinks", false, "do not follow symlinks when copying content (copy the symlink itself)")
 	if err := flags.MarkHidden("creds"); err != nil {
 		panic(fmt.Sprintf("error marking creds as hidden: %v", err))
@@ -91,11 +104,13 @@ func applyFlagVars(flags *pflag.FlagSet, opts *addCopyResults) {
 		panic(fmt.Sprintf("error marking decryption-key as hidden: %v", err))
 	}
 	flags.StringSliceVar(&opts.excludes, "exclude", nil, "exclude pattern when copying files")
+	flags.StringSliceVar(&opts.drop, "drop", nil, "leave out items whose names match `pattern`, including the contents of extracted archives")
 	flags.StringVar(&opts.ignoreFile, "ignorefile", "", "path to .containerignore file")
 	flags.StringVar(&opts.contextdir, "contextdir", "", "context directory path")
 	flags.IntVar(&opts.retry, "retry", cli.MaxPullPushRetries, "number of times to retry in case of failure when performing pull")
 	flags.StringVar(&opts.retryDelay, "retry-delay", cli.PullPushRetryDelay.String(), "delay between retries in case of pull failures")
 	flags.BoolVarP(&opts.quiet, "quiet", "q", false, "don't output a digest of the newly-added/copied content")
+	flags.StringSliceVar(&opts.unixLineEndings, "unix-line-endings", nil, "convert CRLF line endings to LF in files whose names match `pattern`")
 	flags.BoolVar(&opts.tlsVerify, "tls-verify", true, "require HTTPS and verify certificates when accessing registries when pulling images, and when retrieving sources from HTTPS URLs. TLS verification cannot be used when talking to an insecure registry.")
 	flags.BoolVarP(&opts.removeSignatures, "remove-signatures", "", false, "don't copy signatures when pulling image")
 	if err := flags.MarkHidden("remove-signatures"); err != nil {
@@ -146,6 +161,9 @@ func addAndCopyCmd(c *cobra.Command, args []string, verb string, iopts addCopyRe
 		return errors.New("src must be specified")
 	}
 
+	if iopts.jobs < 1 {
+		return errors.New("invalid value for --jobs.  It must be a positive integer")
+	}
 	if err := cli.VerifyFlagsArgsOrder(args); err != nil {
 		return err
 	}
@@ -249,10 +267,17 @@ func addAndCopyCmd(c *cobra.Command, args []string, verb string, iopts addCopyRe
 	builder.ContentDigester.Restart()
 
 	var timestamp *time.Time
-	if iopts.timestamp != "" {
-		u, err := strconv.ParseInt(iopts.timestamp, 10, 64)
+	timestampValue := iopts.timestamp
+	if iopts.clampTimestamp && timestampValue == "" {
+		timestampValue = os.Getenv(internal.SourceDateEpochName)
+		if timestampValue == "" {
+			return fmt.Errorf("--clamp-timestamp requires either --timestamp or $%s", internal.SourceDateEpochName)
+		}
+	}
+	if timestampValue != "" {
+		u, err := strconv.ParseInt(timestampValue, 10, 64)
 		if err != nil {
-			return fmt.Errorf("parsing timestamp value %q: %w", iopts.timestamp, err)
+			return fmt.Errorf("parsing timestamp value %q: %w", timestampValue, err)
 		}
 		t := time.Unix(u, 0).UTC()
 		timestamp = &t
@@ -280,6 +305,13 @@ func addAndCopyCmd(c *cobra.Command, args []string, verb string, iopts addCopyRe
 		Timestamp:             timestamp,
 		Link:                  iopts.link,
 		FollowSymlink:         followSymlink,
+		Parallel:              iopts.jobs,
+		ChmodDirs:             iopts.chmodDirs,
+		ChmodFiles:            iopts.chmodFiles,
+		ClampTimestamp:        iopts.clampTimestamp,
+		DropPatterns:          iopts.drop,
+		UnixLineEndings:       iopts.unixLineEndings,
+		NoClone:               iopts.noClone,
 	}
 	if iopts.contextdir != "" {
 		var excludes []string
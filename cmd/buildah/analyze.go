@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	units "github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"go.podman.io/buildah/internal/analyze"
+	"go.podman.io/buildah/pkg/parse"
+	"go.podman.io/common/libimage"
+	"go.podman.io/storage"
+	"go.podman.io/storage/pkg/archive"
+)
+
+type analyzeOptions struct {
+	json      bool
+	top       int
+	maxWasted string
+}
+
+func analyzeInit() {
+	var opts analyzeOptions
+	analyzeDescription := `
+  Reads the layers of an image, and reports how much space each of them adds
+  and removes, which files are overwritten or deleted by later layers, the
+  largest files and directories, and which files are duplicated across layers.`
+	analyzeCommand := &cobra.Command{
+		Use:   "analyze",
+		Short: "Analyze how an image's layers use space",
+		Long:  analyzeDescription,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return analyzeCmd(cmd, args, opts)
+		},
+		Example: `buildah analyze imageName
+  buildah analyze --json imageName
+  buildah analyze --max-wasted 10MB imageName`,
+		Args:    cobra.ExactArgs(1),
+		GroupID: groupImages,
+	}
+	analyzeCommand.SetUsageTemplate(UsageTemplate())
+
+	flags := analyzeCommand.Flags()
+	flags.SetInterspersed(false)
+	flags.BoolVar(&opts.json, "json", false, "output in JSON format")
+	flags.StringVar(&opts.maxWasted, "max-wasted", "", "fail if more than `size` bytes are wasted on content which later layers overwrite or delete")
+	flags.IntVar(&opts.top, "top", 10, "list at most `number` files and directories in each part of the report (-1 for all)")
+
+	rootCmd.AddCommand(analyzeCommand)
+}
+
+func analyzeCmd(c *cobra.Command, args []string, iopts analyzeOptions) error {
+	var maxWasted int64 = -1
+	if iopts.maxWasted != "" {
+		size, err := units.FromHumanSize(iopts.maxWasted)
+		if err != nil {
+			return fmt.Errorf("parsing --max-wasted value %q: %w", iopts.maxWasted, err)
+		}
+		maxWasted = size
+	}
+
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+	systemContext, err := parse.SystemContextFromOptions(c)
+	if err != nil {
+		return fmt.Errorf("building system context: %w", err)
+	}
+	runtime, err := libimage.RuntimeFromStore(store, &libimage.RuntimeOptions{SystemContext: systemContext})
+	if err != nil {
+		return err
+	}
+	img, _, err := runtime.LookupImage(args[0], nil)
+	if err != nil {
+		return err
+	}
+	ctx := getContext()
+	data, err := img.Inspect(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("inspecting image %q: %w", args[0], err)
+	}
+
+	// walk down from the top layer, prepending each layer so that the
+	// list starts with the base layer
+	var layers []analyze.Layer
+	uncompressed := archive.Uncompressed
+	for layerID := img.TopLayer(); layerID != ""; {
+		layer, err := store.Layer(layerID)
+		if err != nil {
+			return fmt.Errorf("locating layer %q: %w", layerID, err)
+		}
+		id := layer.ID
+		layers = append([]analyze.Layer{{
+			ID: id,
+			Open: func() (io.ReadCloser, error) {
+				return store.Diff("", id, &storage.DiffOptions{Compression: &uncompressed})
+			},
+		}}, layers...)
+		layerID = layer.Parent
+	}
+	// match the history entries which added layers to the layers, if
+	// there are as many of them as there are layers
+	var createdBy []string
+	for _, history := range data.History {
+		if !history.EmptyLayer {
+			createdBy = append(createdBy, history.CreatedBy)
+		}
+	}
+	if len(createdBy) == len(layers) {
+		for i := range layers {
+			layers[i].CreatedBy = createdBy[i]
+		}
+	}
+
+	report, err := analyze.Analyze(layers, iopts.top)
+	if err != nil {
+		return fmt.Errorf("analyzing image %q: %w", args[0], err)
+	}
+
+	if iopts.json {
+		encoded, err := json.MarshalIndent(report, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", encoded)
+	} else if err := writeAnalyzeReport(os.Stdout, args[0], report); err != nil {
+		return err
+	}
+
+	if maxWasted >= 0 && report.WastedBytes > maxWasted {
+		return fmt.Errorf("%s wasted in image %q exceeds the limit of %s", formattedSize(report.WastedBytes), args[0], formattedSize(maxWasted))
+	}
+	return nil
+}
+
+// writeAnalyzeReport writes a report as text.
+func writeAnalyzeReport(w io.Writer, name string, report *analyze.Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(tw, "Image:\t%s\n", name)
+	fmt.Fprintf(tw, "Total size:\t%s in %d layers\n", formattedSize(report.TotalBytes), len(report.Layers))
+	fmt.Fprintf(tw, "Final size:\t%s\n", formattedSize(report.FinalBytes))
+	fmt.Fprintf(tw, "Wasted space:\t%s\n", formattedSize(report.WastedBytes))
+	fmt.Fprintf(tw, "Duplicate content:\t%s\n", formattedSize(report.DuplicateBytes))
+	fmt.Fprintf(tw, "Efficiency:\t%.2f%%\n", report.Efficiency*100)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nLayers:\n")
+	tw = tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(tw, "LAYER\tID\tADDED\tREMOVED\tFILES\tDELETIONS\tCREATED BY\n")
+	for _, layer := range report.Layers {
+		createdBy := strings.TrimPrefix(layer.CreatedBy, "/bin/sh -c ")
+		if len(createdBy) > 60 {
+			createdBy = createdBy[:57] + "..."
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%d\t%s\n", layer.Index, layer.ID[:min(12, len(layer.ID))], formattedSize(layer.AddedBytes), formattedSize(layer.RemovedBytes), layer.Files, layer.Deletions, createdBy)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.Wasted) > 0 {
+		fmt.Fprintf(w, "\nWasted space (files overwritten or deleted by later layers):\n")
+		tw = tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		fmt.Fprintf(tw, "SIZE\tLAYER\tREMOVED BY\tPATH\n")
+		for _, wasted := range report.Wasted {
+			how := "deleted"
+			if wasted.Overwritten {
+				how = "overwritten"
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d (%s)\t%s\n", formattedSize(wasted.Size), wasted.Layer, wasted.RemovedBy, how, wasted.Path)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(report.LargestFiles) > 0 {
+		fmt.Fprintf(w, "\nLargest files:\n")
+		tw = tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		fmt.Fprintf(tw, "SIZE\tLAYER\tPATH\n")
+		for _, file := range report.LargestFiles {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", formattedSize(file.Size), file.Layer, file.Path)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(report.LargestDirectories) > 0 {
+		fmt.Fprintf(w, "\nLargest directories:\n")
+		tw = tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		fmt.Fprintf(tw, "SIZE\tFILES\tPATH\n")
+		for _, dir := range report.LargestDirectories {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", formattedSize(dir.Size), dir.Files, dir.Path)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(report.Duplicates) > 0 {
+		fmt.Fprintf(w, "\nDuplicate content (files with the same contents added by more than one layer):\n")
+		tw = tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		fmt.Fprintf(tw, "SIZE\tCOPIES\tDIGEST\tLAYER\tPATH\n")
+		for _, duplicate := range report.Duplicates {
+			for i, file := range duplicate.Files {
+				if i == 0 {
+					fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\n", formattedSize(duplicate.Size), len(duplicate.Files), duplicate.Digest.Encoded()[:12], file.Layer, file.Path)
+				} else {
+					fmt.Fprintf(tw, "\t\t\t%d\t%s\n", file.Layer, file.Path)
+				}
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.podman.io/buildah/define"
+	"go.podman.io/buildah/imagebuildah"
+	"go.podman.io/buildah/pkg/bake"
+	buildahcli "go.podman.io/buildah/pkg/cli"
+	"go.podman.io/common/pkg/completion"
+	"golang.org/x/sync/semaphore"
+)
+
+type bakeResults struct {
+	files []string
+	jobs  int
+	print bool
+}
+
+// bakeOnlyFlags are the flags which the bake command accepts which aren't
+// passed along to the builds of the targets.
+var bakeOnlyFlags = []string{"file", "jobs", "print"}
+
+// bakeSigningFlags are the build flags which control signing, which the bake
+// command handles itself so that signers are only set up once, instead of
+// once for each target.
+var bakeSigningFlags = []string{"sign-by-sigstore", "sign-by-sigstore-private-key", "sign-passphrase-file"}
+
+// bakeExcludedBuildFlags are flags which the build command accepts, but
+// which would only make sense for a single build.
+var bakeExcludedBuildFlags = []string{"debug-socket", "file", "iidfile", "jobs", "logfile", "logsplit", "manifest", "metadata-file", "tag"}
+
+func bakeInit() {
+	var opts bakeResults
+	bakeDescription := `
+  Builds the images described by the targets in one or more definition files,
+  running the builds at the same time and sharing the results of steps which
+  they have in common.
+
+  If no targets are named, the targets in the "default" group are built.  Any
+  options which "buildah build" accepts, other than those which only make
+  sense for a single image, can be specified, and are applied to every target.`
+
+	bakeCommand := &cobra.Command{
+		Use:   "bake [TARGET...]",
+		Short: "Build the images described by targets in a definition file",
+		Long:  bakeDescription,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return bakeCmd(cmd, args, opts)
+		},
+		Example: `buildah bake
+  buildah bake -f docker-bake.hcl app db
+  buildah bake --print release`,
+		GroupID: groupImages,
+	}
+	bakeCommand.SetUsageTemplate(UsageTemplate())
+
+	flags := bakeCommand.Flags()
+	flags.SetInterspersed(false)
+	flags.StringArrayVarP(&opts.files, "file", "f", nil, "read target definitions from `file` (default: "+strings.Join(bake.DefaultFiles, ", ")+")")
+	_ = bakeCommand.RegisterFlagCompletionFunc("file", completion.AutocompleteDefault)
+	flags.IntVar(&opts.jobs, "jobs", 0, "how many targets to build at the same time (0 for all of them)")
+	flags.BoolVar(&opts.print, "print", false, "print the resolved target definitions instead of building them")
+
+	var buildFlags pflag.FlagSet
+	if err := addBuildFlags(&buildFlags, newBakeBuildOptions()); err != nil {
+		logrus.Errorf("failed to setup From and Build flags: %v", err)
+		os.Exit(1)
+	}
+	buildFlags.VisitAll(func(flag *pflag.Flag) {
+		if slices.Contains(bakeExcludedBuildFlags, flag.Name) {
+			return
+		}
+		flag.Hidden = true
+		flags.AddFlag(flag)
+	})
+	flags.SetNormalizeFunc(buildahcli.AliasFlags)
+
+	rootCmd.AddCommand(bakeCommand)
+}
+
+func newBakeBuildOptions() buildahcli.BuildOptions {
+	return buildahcli.BuildOptions{
+		LayerResults:      &buildahcli.LayerResults{},
+		BudResults:        &buildahcli.BudResults{},
+		UserNSResults:     &buildahcli.UserNSResults{},
+		FromAndBudResults: &buildahcli.FromAndBudResults{},
+		NameSpaceResults:  &buildahcli.NameSpaceResults{},
+	}
+}
+
+func bakeCmd(c *cobra.Command, args []string, iopts bakeResults) error {
+	if iopts.jobs < 0 {
+		return errors.New("--jobs must not be negative")
+	}
+	files := iopts.files
+	if len(files) == 0 {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		if files, err = bake.FindFiles(wd); err != nil {
+			return err
+		}
+	}
+	definition, err := bake.ReadFiles(files)
+	if err != nil {
+		return err
+	}
+	targets, err := definition.Resolve(args)
+	if err != nil {
+		return err
+	}
+
+	if iopts.print {
+		printed := bake.Definition{
+			Groups:  map[string]*bake.Group{bake.DefaultGroup: {Targets: []string{}}},
+			Targets: map[string]*bake.Target{},
+		}
+		for _, target := range targets {
+			printed.Groups[bake.DefaultGroup].Targets = append(printed.Groups[bake.DefaultGroup].Targets, target.Name)
+			printed.Targets[target.Name] = target
+		}
+		encoded, err := json.MarshalIndent(printed, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(encoded))
+		return nil
+	}
+
+	// the options which were passed to us, which will be passed to every
+	// target's build
+	var common []string
+	if !c.Flag("layers").Changed {
+		common = append(common, "--layers=true")
+	}
+	c.Flags().Visit(func(flag *pflag.Flag) {
+		if slices.Contains(bakeOnlyFlags, flag.Name) || slices.Contains(bakeSigningFlags, flag.Name) || c.InheritedFlags().Lookup(flag.Name) != nil {
+			return
+		}
+		common = append(common, flagArgs(flag)...)
+	})
+
+	sigstoreParams, err := c.Flags().GetString("sign-by-sigstore")
+	if err != nil {
+		return err
+	}
+	sigstoreKey, err := c.Flags().GetString("sign-by-sigstore-private-key")
+	if err != nil {
+		return err
+	}
+	passphraseFile, err := c.Flags().GetString("sign-passphrase-file")
+	if err != nil {
+		return err
+	}
+
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+
+	// resolve every target's options before starting any builds, so that
+	// a problem with one target doesn't leave others running, and so that
+	// nothing is cleaned up until every build has finished
+	type bakeBuild struct {
+		name           string
+		options        define.BuildOptions
+		containerfiles []string
+	}
+	var prepared []bakeBuild
+	var removeAll []string
+	defer func() {
+		for _, f := range removeAll {
+			os.RemoveAll(f)
+		}
+	}()
+	stepLocker := imagebuildah.NewStepLocker()
+	for _, target := range targets {
+		targetCmd, targetArgs, br, err := bakeTargetCommand(c, target, common)
+		if err != nil {
+			return fmt.Errorf("target %q: %w", target.Name, err)
+		}
+		options, containerfiles, targetRemoveAll, err := buildahcli.GenBuildOptions(targetCmd, targetArgs, br)
+		if err != nil {
+			return fmt.Errorf("target %q: %w", target.Name, err)
+		}
+		removeAll = append(removeAll, targetRemoveAll...)
+		options.SignBySigstorePrivateKeyFile = sigstoreKey
+		options.DefaultMountsFilePath = globalFlagResults.DefaultMountsFile
+		options.LogPrefix = "[" + target.Name + "] "
+		options.StepLocker = stepLocker
+		prepared = append(prepared, bakeBuild{name: target.Name, options: options, containerfiles: containerfiles})
+	}
+
+	// set up signing once, after every target's options have been
+	// checked, and share it among all of the builds
+	passphrase, signers, err := buildahcli.SigstoreSigning(sigstoreKey, passphraseFile, sigstoreParams)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, signer := range signers {
+			signer.Close()
+		}
+	}()
+	for i := range prepared {
+		prepared[i].options.SignSigstorePrivateKeyPassphrase = passphrase
+		prepared[i].options.Signers = signers
+	}
+
+	var builds multierror.Group
+	var jobs *semaphore.Weighted
+	if iopts.jobs > 0 {
+		jobs = semaphore.NewWeighted(int64(iopts.jobs))
+	}
+	ctx := getContext()
+	for _, build := range prepared {
+		builds.Go(func() error {
+			if jobs != nil {
+				if err := jobs.Acquire(ctx, 1); err != nil {
+					return err
+				}
+				defer jobs.Release(1)
+			}
+			if _, _, err := imagebuildah.BuildDockerfiles(ctx, store, build.options, build.containerfiles...); err != nil {
+				return fmt.Errorf("target %q: %w", build.name, err)
+			}
+			return nil
+		})
+	}
+	if merr := builds.Wait(); merr != nil {
+		if merr.Len() == 1 {
+			return merr.Errors[0]
+		}
+		return merr.ErrorOrNil()
+	}
+	return nil
+}
+
+// flagArgs returns command line arguments which would give flag its current
+// value.
+func flagArgs(flag *pflag.Flag) []string {
+	if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+		var args []string
+		for _, value := range sliceValue.GetSlice() {
+			args = append(args, "--"+flag.Name+"="+value)
+		}
+		return args
+	}
+	return []string{"--" + flag.Name + "=" + flag.Value.String()}
+}
+
+// bakeTargetCommand returns a command with the flags which the build command
+// accepts, set to the values in common and the ones which the target
+// specifies, along with the arguments to pass to buildahcli.GenBuildOptions()
+// for it.
+func bakeTargetCommand(c *cobra.Command, target *bake.Target, common []string) (*cobra.Command, []string, buildahcli.BuildOptions, error) {
+	targetCmd := &cobra.Command{Use: "build"}
+	br := newBakeBuildOptions()
+	flags := targetCmd.Flags()
+	if err := addBuildFlags(flags, br); err != nil {
+		return nil, nil, br, err
+	}
+
+	contextDir := "."
+	if target.Context != nil && *target.Context != "" {
+		contextDir = *target.Context
+	}
+	args := slices.Clone(common)
+	if target.Dockerfile != nil && *target.Dockerfile != "" {
+		// containerfile locations are relative to the build context
+		containerfile := *target.Dockerfile
+		if !filepath.IsAbs(containerfile) && !strings.Contains(contextDir, "://") {
+			containerfile = filepath.Join(contextDir, containerfile)
+		}
+		args = append(args, "--file="+containerfile)
+	}
+	for _, key := range slices.Sorted(maps.Keys(target.Args)) {
+		args = append(args, "--build-arg="+key+"="+target.Args[key])
+	}
+	for _, key := range slices.Sorted(maps.Keys(target.Labels)) {
+		args = append(args, "--label="+key+"="+target.Labels[key])
+	}
+	for _, tag := range target.Tags {
+		args = append(args, "--tag="+tag)
+	}
+	if len(target.Platforms) > 0 {
+		args = append(args, "--platform="+strings.Join(target.Platforms, ","))
+	}
+	for _, output := range target.Outputs {
+		args = append(args, "--output="+output)
+	}
+	for _, cacheFrom := range target.CacheFrom {
+		args = append(args, "--cache-from="+cacheFrom)
+	}
+	for _, cacheTo := range target.CacheTo {
+		args = append(args, "--cache-to="+cacheTo)
+	}
+	if target.Target != nil {
+		args = append(args, "--target="+*target.Target)
+	}
+	if target.NoCache != nil {
+		args = append(args, fmt.Sprintf("--no-cache=%t", *target.NoCache))
+	}
+	if target.Pull != nil {
+		pull := define.PullIfMissing.String()
+		if *target.Pull {
+			pull = define.PullAlways.String()
+		}
+		args = append(args, "--pull="+pull)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, br, err
+	}
+	// make the global options visible to GenBuildOptions()
+	flags.AddFlagSet(c.InheritedFlags())
+	return targetCmd, []string{contextDir}, br, nil
+}
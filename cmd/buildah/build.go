@@ -6,11 +6,18 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"go.podman.io/buildah/imagebuildah"
+	"go.podman.io/buildah/internal/rpc/debug"
 	buildahcli "go.podman.io/buildah/pkg/cli"
 	"go.podman.io/buildah/util"
 )
 
+// debugSocketEnvVar is the environment variable which, if set, provides the
+// default value for the --debug-socket flag, so that a build can be run under
+// "buildah rpc --env".
+const debugSocketEnvVar = "BUILDAH_DEBUG_SOCKET"
+
 func buildInit() {
 	buildDescription := `
   Builds an OCI image using instructions in one or more Containerfiles.
@@ -50,24 +57,39 @@ func buildInit() {
 
 	flags := buildCommand.Flags()
 	flags.SetInterspersed(false)
+	if err := addBuildFlags(flags, buildahcli.BuildOptions{
+		LayerResults:      &layerFlagsResults,
+		BudResults:        &buildFlagResults,
+		UserNSResults:     &userNSResults,
+		FromAndBudResults: &fromAndBudResults,
+		NameSpaceResults:  &namespaceResults,
+	}); err != nil {
+		logrus.Errorf("failed to setup From and Build flags: %v", err)
+		os.Exit(1)
+	}
 
+	rootCmd.AddCommand(buildCommand)
+}
+
+// addBuildFlags adds the flags which the build command accepts to flags,
+// storing their values in the structures which br points to.
+func addBuildFlags(flags *pflag.FlagSet, br buildahcli.BuildOptions) error {
 	// build is a all common flags
-	buildFlags := buildahcli.GetBudFlags(&buildFlagResults)
-	buildFlags.StringVar(&buildFlagResults.Runtime, "runtime", util.Runtime(), "`path` to an alternate runtime. Use BUILDAH_RUNTIME environment variable to override.")
+	buildFlags := buildahcli.GetBudFlags(br.BudResults)
+	buildFlags.StringVar(&br.Runtime, "runtime", util.Runtime(), "`path` to an alternate runtime. Use BUILDAH_RUNTIME environment variable to override.")
+	buildFlags.String("debug-socket", os.Getenv(debugSocketEnvVar), "when pausing for --break-at or --step, use the debug service at `address` instead of prompting. Use "+debugSocketEnvVar+" environment variable to override.")
 
-	layerFlags := buildahcli.GetLayerFlags(&layerFlagsResults)
-	fromAndBudFlags, err := buildahcli.GetFromAndBudFlags(&fromAndBudResults, &userNSResults, &namespaceResults)
+	layerFlags := buildahcli.GetLayerFlags(br.LayerResults)
+	fromAndBudFlags, err := buildahcli.GetFromAndBudFlags(br.FromAndBudResults, br.UserNSResults, br.NameSpaceResults)
 	if err != nil {
-		logrus.Errorf("failed to setup From and Build flags: %v", err)
-		os.Exit(1)
+		return err
 	}
 
 	flags.AddFlagSet(&buildFlags)
 	flags.AddFlagSet(&layerFlags)
 	flags.AddFlagSet(&fromAndBudFlags)
 	flags.SetNormalizeFunc(buildahcli.AliasFlags)
-
-	rootCmd.AddCommand(buildCommand)
+	return nil
 }
 
 func buildCmd(c *cobra.Command, inputArgs []string, iopts buildahcli.BuildOptions) error {
@@ -88,10 +110,22 @@ func buildCmd(c *cobra.Command, inputArgs []string, iopts buildahcli.BuildOption
 		for _, f := range removeAll {
 			os.RemoveAll(f)
 		}
+		for _, signer := range options.Signers {
+			signer.Close()
+		}
 	}()
 
 	options.DefaultMountsFilePath = globalFlagResults.DefaultMountsFile
 
+	if address := c.Flag("debug-socket").Value.String(); address != "" {
+		debugger, err := debug.NewDebugger(address)
+		if err != nil {
+			return err
+		}
+		defer debugger.Close()
+		options.Debugger = debugger
+	}
+
 	store, err := getStore(c)
 	if err != nil {
 		return err
@@ -14,6 +14,8 @@ import (
 	"go.podman.io/buildah"
 	"go.podman.io/buildah/define"
 	"go.podman.io/buildah/internal"
+	"go.podman.io/buildah/internal/output"
+	internalUtil "go.podman.io/buildah/internal/util"
 	"go.podman.io/buildah/pkg/cli"
 	"go.podman.io/buildah/pkg/parse"
 	"go.podman.io/buildah/util"
@@ -37,6 +39,7 @@ type commitInputOptions struct {
 	compressionFormat      string
 	compressionLevel       int
 	configFile             string
+	configPatch            string
 	creds                  string
 	cwOptions              string
 	disableCompression     bool
@@ -64,6 +67,9 @@ type commitInputOptions struct {
 	sbomScannerImage       string
 	signaturePolicy        string
 	signBy                 string
+	signBySigstore         string
+	signBySigstoreKey      string
+	signPassphraseFile     string
 	squash                 bool
 	tlsVerify              bool
 	identityLabel          bool
@@ -74,6 +80,7 @@ type commitInputOptions struct {
 	unsetAnnotation        []string
 	annotation             []string
 	createdAnnotation      bool
+	compatDockerConfig     bool
 	metadataFile           string
 }
 
@@ -120,6 +127,8 @@ func commitListFlagSet(cmd *cobra.Command, opts *commitInputOptions) {
 	_ = cmd.RegisterFlagCompletionFunc("cert-dir", completion.AutocompleteDefault)
 	flags.StringVar(&opts.configFile, "config", "", "apply configuration JSON `file` to the committed image")
 	_ = cmd.RegisterFlagCompletionFunc("config", completion.AutocompleteDefault)
+	flags.StringVar(&opts.configPatch, "config-patch", "", "apply a JSON or YAML configuration patch from `file` to the committed image")
+	_ = cmd.RegisterFlagCompletionFunc("config-patch", completion.AutocompleteDefault)
 	flags.StringVar(&opts.creds, "creds", "", "use `[username[:password]]` for accessing the registry")
 	_ = cmd.RegisterFlagCompletionFunc("creds", completion.AutocompleteNone)
 	flags.StringVar(&opts.compressionFormat, "compression-format", "", "compression format to use")
@@ -129,7 +138,7 @@ func commitListFlagSet(cmd *cobra.Command, opts *commitInputOptions) {
 	flags.StringVar(&opts.cwOptions, "cw", "", "confidential workload `options`")
 	flags.BoolVarP(&opts.disableCompression, "disable-compression", "D", true, "don't compress layers")
 	flags.BoolVar(&opts.forceCompressionFormat, "force-compression", false, "use the specified compression algorithm if the destination contains a differently-compressed variant already")
-	flags.StringVarP(&opts.format, "format", "f", defaultFormat(), "`format` of the image manifest and metadata")
+	flags.StringVarP(&opts.format, "format", "f", defaultFormat(), "`format` of the image manifest and metadata, or type of filesystem image to write")
 	_ = cmd.RegisterFlagCompletionFunc("format", completion.AutocompleteNone)
 	flags.StringVar(&opts.manifest, "manifest", "", "adds created image to the specified manifest list. Creates manifest list if it does not exist")
 	_ = cmd.RegisterFlagCompletionFunc("manifest", completion.AutocompleteNone)
@@ -183,6 +192,12 @@ func commitListFlagSet(cmd *cobra.Command, opts *commitInputOptions) {
 
 	flags.StringVar(&opts.signBy, "sign-by", "", "sign the image using a GPG key with the specified `FINGERPRINT`")
 	_ = cmd.RegisterFlagCompletionFunc("sign-by", completion.AutocompleteNone)
+	flags.StringVar(&opts.signBySigstore, "sign-by-sigstore", "", "sign the image using a sigstore parameter file at `PATH`")
+	_ = cmd.RegisterFlagCompletionFunc("sign-by-sigstore", completion.AutocompleteDefault)
+	flags.StringVar(&opts.signBySigstoreKey, "sign-by-sigstore-private-key", "", "sign the image using a sigstore private key at `PATH`")
+	_ = cmd.RegisterFlagCompletionFunc("sign-by-sigstore-private-key", completion.AutocompleteDefault)
+	flags.StringVar(&opts.signPassphraseFile, "sign-passphrase-file", "", "read a passphrase for signing an image from `PATH`")
+	_ = cmd.RegisterFlagCompletionFunc("sign-passphrase-file", completion.AutocompleteDefault)
 	if err := flags.MarkHidden("omit-timestamp"); err != nil {
 		panic(fmt.Sprintf("error marking omit-timestamp as hidden: %v", err))
 	}
@@ -210,6 +225,7 @@ func commitListFlagSet(cmd *cobra.Command, opts *commitInputOptions) {
 	flags.StringArrayVar(&opts.annotation, "annotation", []string{}, "set metadata for an image (default [])")
 	_ = cmd.RegisterFlagCompletionFunc("annotation", completion.AutocompleteNone)
 	flags.BoolVar(&opts.createdAnnotation, "created-annotation", true, `set an "org.opencontainers.image.created" annotation in the image`)
+	flags.BoolVar(&opts.compatDockerConfig, "compat-docker-config", false, "record settings which only the docker format has a place for in OCI images")
 }
 
 func commitCmd(c *cobra.Command, args []string, iopts commitInputOptions) error {
@@ -247,9 +263,22 @@ func commitCmd(c *cobra.Command, args []string, iopts commitInputOptions) error
 		}
 	}
 
-	format, err := cli.GetFormat(iopts.format)
-	if err != nil {
-		return err
+	var format, filesystem string
+	switch iopts.format {
+	case "squashfs", "erofs", "ext4":
+		// write a filesystem image to a file instead of writing an image
+		filesystem = iopts.format
+		if image == "" {
+			return fmt.Errorf("--format %s requires the location of the file to write", filesystem)
+		}
+		if iopts.cwOptions != "" || iopts.manifest != "" {
+			return fmt.Errorf("--format %s can not be used with --cw or --manifest", filesystem)
+		}
+	default:
+		var err error
+		if format, err = cli.GetFormat(iopts.format); err != nil {
+			return err
+		}
 	}
 	store, err := getStore(c)
 	if err != nil {
@@ -270,7 +299,7 @@ func commitCmd(c *cobra.Command, args []string, iopts commitInputOptions) error
 
 	// If the user specified an image, we may need to massage it a bit if
 	// no transport is specified.
-	if image != "" {
+	if image != "" && filesystem == "" {
 		if dest, err = alltransports.ParseImageName(image); err != nil {
 			candidates, err2 := shortnames.ResolveLocally(systemContext, image)
 			if err2 != nil {
@@ -304,6 +333,18 @@ func commitCmd(c *cobra.Command, args []string, iopts commitInputOptions) error
 		}
 	}
 
+	// the patch is applied to our in-memory copy of the container's
+	// configuration, which we don't save, so it only affects this image
+	if c.Flag("config-patch").Changed {
+		patch, err := os.ReadFile(iopts.configPatch)
+		if err != nil {
+			return fmt.Errorf("reading configuration patch: %w", err)
+		}
+		if err := builder.ApplyConfigPatch(patch); err != nil {
+			return fmt.Errorf("applying configuration patch from %q: %w", iopts.configPatch, err)
+		}
+	}
+
 	var addFiles map[string]string
 	if len(iopts.addFile) > 0 {
 		addFiles = make(map[string]string)
@@ -326,27 +367,41 @@ func commitCmd(c *cobra.Command, args []string, iopts commitInputOptions) error
 		}
 	}
 
+	passphrase, signers, err := cli.SigstoreSigning(iopts.signBySigstoreKey, iopts.signPassphraseFile, iopts.signBySigstore)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, signer := range signers {
+			signer.Close()
+		}
+	}()
+
 	options := buildah.CommitOptions{
-		PreferredManifestType:  format,
-		Manifest:               iopts.manifest,
-		Compression:            compress,
-		ForceCompressionFormat: iopts.forceCompressionFormat,
-		SignaturePolicyPath:    iopts.signaturePolicy,
-		SystemContext:          systemContext,
-		IIDFile:                iopts.iidfile,
-		Squash:                 iopts.squash,
-		BlobDirectory:          iopts.blobCache,
-		OmitHistory:            iopts.omitHistory,
-		SignBy:                 iopts.signBy,
-		OciEncryptConfig:       encConfig,
-		OciEncryptLayers:       encLayers,
-		UnsetEnvs:              iopts.unsetenvs,
-		OverrideChanges:        iopts.changes,
-		OverrideConfig:         overrideConfig,
-		ExtraImageContent:      addFiles,
-		UnsetAnnotations:       iopts.unsetAnnotation,
-		Annotations:            iopts.annotation,
-		CreatedAnnotation:      types.NewOptionalBool(iopts.createdAnnotation),
+		PreferredManifestType:            format,
+		Manifest:                         iopts.manifest,
+		Compression:                      compress,
+		ForceCompressionFormat:           iopts.forceCompressionFormat,
+		SignaturePolicyPath:              iopts.signaturePolicy,
+		SystemContext:                    systemContext,
+		IIDFile:                          iopts.iidfile,
+		Squash:                           iopts.squash,
+		BlobDirectory:                    iopts.blobCache,
+		OmitHistory:                      iopts.omitHistory,
+		SignBy:                           iopts.signBy,
+		SignBySigstorePrivateKeyFile:     iopts.signBySigstoreKey,
+		SignSigstorePrivateKeyPassphrase: passphrase,
+		Signers:                          signers,
+		OciEncryptConfig:                 encConfig,
+		OciEncryptLayers:                 encLayers,
+		UnsetEnvs:                        iopts.unsetenvs,
+		OverrideChanges:                  iopts.changes,
+		OverrideConfig:                   overrideConfig,
+		ExtraImageContent:                addFiles,
+		UnsetAnnotations:                 iopts.unsetAnnotation,
+		Annotations:                      iopts.annotation,
+		CreatedAnnotation:                types.NewOptionalBool(iopts.createdAnnotation),
+		CompatDockerConfig:               types.NewOptionalBool(iopts.compatDockerConfig),
 	}
 	defaultContainerConfig, err := config.Default()
 	if err != nil {
@@ -427,6 +482,16 @@ func commitCmd(c *cobra.Command, args []string, iopts commitInputOptions) error
 		}
 	}
 
+	if filesystem != "" {
+		if err := commitFilesystemImage(builder, options, image, filesystem); err != nil {
+			return fmt.Errorf("committing container %q to %s image %q: %w", builder.Container, filesystem, image, err)
+		}
+		if iopts.rm {
+			return builder.Delete()
+		}
+		return nil
+	}
+
 	if iopts.cwOptions != "" {
 		confidentialWorkloadOptions, err := parse.GetConfidentialWorkloadOptions(iopts.cwOptions)
 		if err != nil {
@@ -487,3 +552,30 @@ func commitCmd(c *cobra.Command, args []string, iopts commitInputOptions) error
 	}
 	return nil
 }
+
+// commitFilesystemImage writes the contents of the container's rootfs to a
+// filesystem image of the specified type at path.
+func commitFilesystemImage(builder *buildah.Builder, options buildah.CommitOptions, path, filesystem string) error {
+	forceTimestamp := options.HistoryTimestamp
+	if options.SourceDateEpoch != nil {
+		forceTimestamp = options.SourceDateEpoch
+	}
+	rc, errChan, err := builder.ExtractRootfs(options, buildah.ExtractRootfsOptions{ForceTimestamp: forceTimestamp})
+	if err != nil {
+		return err
+	}
+	err = internalUtil.ExportFromReader(rc, output.BuildOutputOption{
+		Type:       output.BuildOutputFSImage,
+		Path:       path,
+		Filesystem: filesystem,
+	})
+	if closeErr := rc.Close(); err == nil {
+		err = closeErr
+	}
+	if errChan != nil {
+		if getErr := <-errChan; err == nil {
+			err = getErr
+		}
+	}
+	return err
+}
@@ -15,6 +15,7 @@ import (
 	"go.podman.io/buildah/define"
 	"go.podman.io/buildah/docker"
 	buildahcli "go.podman.io/buildah/pkg/cli"
+	"go.podman.io/common/pkg/completion"
 )
 
 type configResults struct {
@@ -28,6 +29,7 @@ type configResults struct {
 	domainName               string
 	entrypoint               string
 	env                      []string
+	fromFile                 string
 	healthcheck              string
 	healthcheckInterval      string
 	healthcheckRetries       int
@@ -83,6 +85,8 @@ func configInit() {
 	flags.StringVar(&opts.domainName, "domainname", "", "set a domain `name` for containers based on image")
 	flags.StringVar(&opts.entrypoint, "entrypoint", "", "set `entry point` for containers based on image")
 	flags.StringArrayVarP(&opts.env, "env", "e", []string{}, "add `environment variable` to be set when running containers based on image (default [])")
+	flags.StringVar(&opts.fromFile, "from-file", "", "apply a JSON or YAML configuration patch from `file` before applying other options")
+	_ = configCommand.RegisterFlagCompletionFunc("from-file", completion.AutocompleteDefault)
 	flags.StringVar(&opts.healthcheck, "healthcheck", "", "set a `healthcheck` command for the target image")
 	flags.StringVar(&opts.healthcheckInterval, "healthcheck-interval", "", "set the `interval` between runs of the `healthcheck` command for the target image")
 	flags.IntVar(&opts.healthcheckRetries, "healthcheck-retries", 0, "set the `number` of times the `healthcheck` command has to fail")
@@ -451,6 +455,15 @@ func configCmd(c *cobra.Command, args []string, iopts configResults) error {
 		return fmt.Errorf("reading build container %q: %w", name, err)
 	}
 
+	if c.Flag("from-file").Changed {
+		patch, err := os.ReadFile(iopts.fromFile)
+		if err != nil {
+			return fmt.Errorf("reading configuration patch: %w", err)
+		}
+		if err := builder.ApplyConfigPatch(patch); err != nil {
+			return fmt.Errorf("applying configuration patch from %q: %w", iopts.fromFile, err)
+		}
+	}
 	if err := updateConfig(builder, c, iopts); err != nil {
 		return err
 	}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.podman.io/buildah"
+	buildahcli "go.podman.io/buildah/pkg/cli"
+)
+
+type execInputOptions struct {
+	env        []string
+	run        string
+	terminal   bool
+	workingDir string
+}
+
+func execInit() {
+	var (
+		execDescription = "\n  Runs a command in the namespaces of a command which was started in a working\n  container using \"buildah run --detach\"."
+		opts            execInputOptions
+	)
+
+	execCommand := &cobra.Command{
+		Use:   "exec",
+		Short: "Run a command alongside a detached command in a working container",
+		Long:  execDescription,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return execCmd(cmd, args, opts)
+		},
+		Example: `buildah exec containerID -- psql -c 'select 1'
+  buildah exec --run runID --terminal containerID /bin/sh`,
+		GroupID: groupContainers,
+	}
+	execCommand.SetUsageTemplate(UsageTemplate())
+
+	flags := execCommand.Flags()
+	flags.SetInterspersed(false)
+	flags.StringArrayVarP(&opts.env, "env", "e", []string{}, "add environment variable to be set when running command (default [])")
+	flags.StringVar(&opts.run, "run", "", "`ID` of the detached run to join (default is the most recently started run)")
+	flags.BoolVarP(&opts.terminal, "terminal", "t", false, "allocate a pseudo-TTY for the command")
+	flags.StringVar(&opts.workingDir, "workingdir", "", "set working directory for command (default to the detached command's working directory)")
+
+	userFlags := getUserFlags()
+	flags.AddFlagSet(&userFlags)
+
+	rootCmd.AddCommand(execCommand)
+}
+
+func execCmd(c *cobra.Command, args []string, iopts execInputOptions) error {
+	if len(args) == 0 {
+		return errors.New("container ID must be specified")
+	}
+	name := args[0]
+	args = Tail(args)
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return errors.New("command must be specified")
+	}
+
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+
+	builder, err := openBuilder(getContext(), store, name)
+	if err != nil {
+		return fmt.Errorf("reading build container %q: %w", name, err)
+	}
+
+	options := buildah.ExecOptions{
+		Env:        buildahcli.LookupEnvVarReferences(iopts.env, os.Environ()),
+		User:       c.Flag("user").Value.String(),
+		WorkingDir: iopts.workingDir,
+	}
+	if iopts.terminal {
+		options.Terminal = buildah.WithTerminal
+	}
+
+	return builder.ExecDetached(iopts.run, args, options)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type logsInputOptions struct {
+	follow bool
+	run    string
+}
+
+func logsInit() {
+	var (
+		logsDescription = "\n  Prints the output of a command which was started in a working container using\n  \"buildah run --detach\"."
+		opts            logsInputOptions
+	)
+
+	logsCommand := &cobra.Command{
+		Use:   "logs",
+		Short: "Print the output of a detached command in a working container",
+		Long:  logsDescription,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return logsCmd(cmd, args, opts)
+		},
+		Example: `buildah logs containerID
+  buildah logs --follow --run runID containerID`,
+		Args:    cobra.ExactArgs(1),
+		GroupID: groupContainers,
+	}
+	logsCommand.SetUsageTemplate(UsageTemplate())
+
+	flags := logsCommand.Flags()
+	flags.SetInterspersed(false)
+	flags.BoolVarP(&opts.follow, "follow", "f", false, "keep printing output until the command exits")
+	flags.StringVar(&opts.run, "run", "", "`ID` of the detached run (default is the most recently started run)")
+
+	rootCmd.AddCommand(logsCommand)
+}
+
+func logsCmd(c *cobra.Command, args []string, iopts logsInputOptions) error {
+	name := args[0]
+
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+
+	builder, err := openBuilder(getContext(), store, name)
+	if err != nil {
+		return fmt.Errorf("reading build container %q: %w", name, err)
+	}
+
+	run, err := builder.DetachedRun(iopts.run)
+	if err != nil {
+		return err
+	}
+	if run.LogPath == "" {
+		return fmt.Errorf("output of run %q was not captured", run.ID)
+	}
+	logFile, err := os.Open(run.LogPath)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	for {
+		if _, err := io.Copy(os.Stdout, logFile); err != nil {
+			return fmt.Errorf("reading output of run %q: %w", run.ID, err)
+		}
+		if !iopts.follow || !run.Running() {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+		if run, err = builder.DetachedRun(run.ID); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+	}
+}
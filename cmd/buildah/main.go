@@ -250,20 +250,27 @@ func main() {
 	mainInit()
 
 	addcopyInit()
+	analyzeInit()
+	bakeInit()
 	buildInit()
 	commitInit()
 	configInit()
 	containersInit()
 	dumpboltInit()
+	execInit()
 	fromInit()
 	imagesInit()
 	infoInit()
 	inspectInit()
 	loginInit()
+	logsInit()
 	logoutInit()
 	manifestInit()
 	mkcwInit()
 	mountInit()
+	optimizeInit()
+	outdatedInit()
+	pinInit()
 	pruneInit()
 	pullInit()
 	pushInit()
@@ -272,10 +279,14 @@ func main() {
 	rmInit()
 	rpcInit()
 	runInit()
+	serveInit()
 	sourceInit()
+	stopInit()
+	systemInit()
 	tagInit()
 	umountInit()
 	unshareInit()
+	verifyInit()
 	versionInit()
 
 	// Hard code TMPDIR functions to use $TMPDIR or /var/tmp
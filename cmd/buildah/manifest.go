@@ -260,6 +260,9 @@ func manifestInit() {
 	flags.StringArrayVar(&manifestPushOpts.addCompression, "add-compression", defaultContainerConfig.Engine.AddCompression.Get(), "add instances with selected compression while pushing")
 	flags.BoolVarP(&manifestPushOpts.removeSignatures, "remove-signatures", "", false, "don't copy signatures when pushing images")
 	flags.StringVar(&manifestPushOpts.signBy, "sign-by", "", "sign the image using a GPG key with the specified `FINGERPRINT`")
+	flags.StringVar(&manifestPushOpts.signBySigstore, "sign-by-sigstore", "", "sign the images using a sigstore parameter file at `PATH`")
+	flags.StringVar(&manifestPushOpts.signBySigstoreKey, "sign-by-sigstore-private-key", "", "sign the images using a sigstore private key at `PATH`")
+	flags.StringVar(&manifestPushOpts.signPassphraseFile, "sign-passphrase-file", "", "read a passphrase for signing images from `PATH`")
 	flags.StringVar(&manifestPushOpts.signaturePolicy, "signature-policy", "", "`pathname` of signature policy file (not usually used)")
 	if err := flags.MarkHidden("signature-policy"); err != nil {
 		panic(fmt.Sprintf("error marking signature-policy as hidden: %v", err))
@@ -1194,17 +1197,30 @@ func manifestPush(systemContext *types.SystemContext, store storage.Store, listI
 
 	retry := uint(opts.retry)
 
+	passphrase, signers, err := cli.SigstoreSigning(opts.signBySigstoreKey, opts.signPassphraseFile, opts.signBySigstore)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, signer := range signers {
+			signer.Close()
+		}
+	}()
+
 	options := manifests.PushOptions{
-		Store:                  store,
-		SystemContext:          systemContext,
-		ImageListSelection:     cp.CopySpecificImages,
-		Instances:              nil,
-		RemoveSignatures:       opts.removeSignatures,
-		SignBy:                 opts.signBy,
-		ManifestType:           manifestType,
-		AddCompression:         opts.addCompression,
-		ForceCompressionFormat: opts.forceCompressionFormat,
-		MaxRetries:             &retry,
+		Store:                            store,
+		SystemContext:                    systemContext,
+		ImageListSelection:               cp.CopySpecificImages,
+		Instances:                        nil,
+		RemoveSignatures:                 opts.removeSignatures,
+		SignBy:                           opts.signBy,
+		SignBySigstorePrivateKeyFile:     opts.signBySigstoreKey,
+		SignSigstorePrivateKeyPassphrase: passphrase,
+		Signers:                          signers,
+		ManifestType:                     manifestType,
+		AddCompression:                   opts.addCompression,
+		ForceCompressionFormat:           opts.forceCompressionFormat,
+		MaxRetries:                       &retry,
 	}
 	if opts.retryDelay != "" {
 		retryDelay, err := time.ParseDuration(opts.retryDelay)
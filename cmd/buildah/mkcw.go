@@ -1,15 +1,34 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"go.podman.io/buildah"
+	"go.podman.io/buildah/internal/mkcw"
+	"go.podman.io/buildah/internal/tmpdir"
 	"go.podman.io/buildah/pkg/parse"
+	"go.podman.io/buildah/util"
+	"go.podman.io/common/pkg/formats"
+	"golang.org/x/term"
 )
 
+type mkcwInspectOptions struct {
+	format string
+}
+
+type mkcwExtractOptions struct {
+	passphrase string
+	keyFile    string
+}
+
 func mkcwCmd(c *cobra.Command, args []string, options buildah.CWConvertImageOptions) error {
 	ctx := getContext()
 
@@ -18,8 +37,8 @@ func mkcwCmd(c *cobra.Command, args []string, options buildah.CWConvertImageOpti
 		return err
 	}
 
-	if options.AttestationURL == "" && options.DiskEncryptionPassphrase == "" {
-		return fmt.Errorf("neither --attestation-url nor --passphrase flags provided, disk would not be decryptable")
+	if options.AttestationURL == "" && options.DiskEncryptionPassphrase == "" && options.DiskEncryptionKeyFile == "" && options.Encryption != mkcw.EncryptionNone {
+		return fmt.Errorf("neither --attestation-url nor --passphrase nor --key-file flags provided, disk would not be decryptable")
 	}
 
 	store, err := getStore(c)
@@ -37,6 +56,148 @@ func mkcwCmd(c *cobra.Command, args []string, options buildah.CWConvertImageOpti
 	return err
 }
 
+// mkcwDiskImage copies the disk image out of the named confidential workload
+// image into a new temporary directory, and returns the disk image's location
+// along with a function which removes the temporary directory.
+func mkcwDiskImage(c *cobra.Command, imageName string) (string, func(), error) {
+	systemContext, err := parse.SystemContextFromOptions(c)
+	if err != nil {
+		return "", nil, err
+	}
+	store, err := getStore(c)
+	if err != nil {
+		return "", nil, err
+	}
+	_, img, err := util.FindImage(store, "", systemContext, imageName)
+	if err != nil {
+		return "", nil, fmt.Errorf("locating image %q: %w", imageName, err)
+	}
+	tempDir, err := os.MkdirTemp(tmpdir.GetTempDir(), "buildah-mkcw")
+	if err != nil {
+		return "", nil, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			logrus.Warnf("removing temporary directory %q: %v", tempDir, err)
+		}
+	}
+	diskImage := filepath.Join(tempDir, "disk.img")
+	if err := mkcw.CopyDiskImage(store, img, diskImage); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return diskImage, cleanup, nil
+}
+
+func mkcwInspectCmd(c *cobra.Command, args []string, iopts mkcwInspectOptions) error {
+	diskImage, cleanup, err := mkcwDiskImage(c, args[0])
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	workloadConfig, err := mkcw.ReadWorkloadConfigFromImage(diskImage)
+	if err != nil {
+		return fmt.Errorf("reading workload configuration from %q: %w", args[0], err)
+	}
+	if iopts.format != "" {
+		format := iopts.format
+		if matched, err := regexp.MatchString("{{.*}}", format); err != nil {
+			return fmt.Errorf("validating format provided: %s: %w", format, err)
+		} else if !matched {
+			return fmt.Errorf("invalid format provided: %s", format)
+		}
+		t, err := formats.NewParse("inspect", format)
+		if err != nil {
+			return fmt.Errorf("template parsing error: %w", err)
+		}
+		if err = t.Execute(os.Stdout, workloadConfig); err != nil {
+			return err
+		}
+		if term.IsTerminal(int(os.Stdout.Fd())) {
+			fmt.Println()
+		}
+		return nil
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "    ")
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		enc.SetEscapeHTML(false)
+	}
+	return enc.Encode(workloadConfig)
+}
+
+func mkcwExtractCmd(c *cobra.Command, args []string, iopts mkcwExtractOptions) error {
+	if iopts.passphrase != "" && iopts.keyFile != "" {
+		return errors.New("--passphrase and --key-file can not be used together")
+	}
+	passphrase := iopts.passphrase
+	if iopts.keyFile != "" {
+		key, err := os.ReadFile(iopts.keyFile)
+		if err != nil {
+			return fmt.Errorf("reading key file: %w", err)
+		}
+		passphrase = string(key)
+	}
+	diskImage, cleanup, err := mkcwDiskImage(c, args[0])
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	workloadConfig, err := mkcw.ReadWorkloadConfigFromImage(diskImage)
+	if err != nil {
+		return fmt.Errorf("reading workload configuration from %q: %w", args[0], err)
+	}
+	plaintextImage := diskImage
+	if workloadConfig.Encryption != mkcw.EncryptionNone {
+		if passphrase == "" {
+			return errors.New("--passphrase or --key-file is required to decrypt the disk image")
+		}
+		if err := mkcw.CheckLUKSPassphrase(diskImage, passphrase); err != nil {
+			return fmt.Errorf("unlocking disk image in %q: %w", args[0], err)
+		}
+		plaintextImage = diskImage + ".decrypted"
+		f, err := os.OpenFile(plaintextImage, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		err = mkcw.DecryptLUKS(diskImage, passphrase, f)
+		if err = errors.Join(err, f.Close()); err != nil {
+			return fmt.Errorf("decrypting disk image in %q: %w", args[0], err)
+		}
+	}
+	filesystem := workloadConfig.Filesystem
+	if filesystem == "" {
+		filesystem = "ext4"
+	}
+	if err := os.MkdirAll(args[1], 0o755); err != nil {
+		return fmt.Errorf("creating %q: %w", args[1], err)
+	}
+	destination, err := filepath.Abs(args[1])
+	if err != nil {
+		return err
+	}
+	if _, stderr, err := mkcw.ExtractFS(plaintextImage, destination, filesystem); err != nil {
+		if stderr != "" {
+			logrus.Debugf("%s", stderr)
+		}
+		return fmt.Errorf("extracting disk image contents from %q: %w", args[0], err)
+	}
+	return nil
+}
+
+// mkcwSubcommandArgs checks that a subcommand of "mkcw" was given n arguments,
+// and if it wasn't, suggests how to convert an image which has the same name
+// as the subcommand instead.
+func mkcwSubcommandArgs(n int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(n)(cmd, args); err != nil {
+			return fmt.Errorf("%w (to convert an image named %q, use \"buildah mkcw -- %s destination\")", err, cmd.Name(), cmd.Name())
+		}
+		return nil
+	}
+}
+
 func mkcwInit() {
 	var teeType string
 	var addFile []string
@@ -73,19 +234,59 @@ func mkcwInit() {
 	}
 	mkcwCommand.SetUsageTemplate(UsageTemplate())
 	rootCmd.AddCommand(mkcwCommand)
+
+	var inspectOptions mkcwInspectOptions
+	mkcwInspectCommand := &cobra.Command{
+		Use:   "inspect",
+		Short: "Inspect the workload configuration of a confidential workload image",
+		Long:  `Print the workload configuration which is stored in a confidential workload image's disk image.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return mkcwInspectCmd(cmd, args, inspectOptions)
+		},
+		Example: `buildah mkcw inspect localhost/repository:cw`,
+		Args:    mkcwSubcommandArgs(1),
+	}
+	mkcwInspectCommand.SetUsageTemplate(UsageTemplate())
+	mkcwCommand.AddCommand(mkcwInspectCommand)
+	mkcwInspectFlags := mkcwInspectCommand.Flags()
+	mkcwInspectFlags.StringVarP(&inspectOptions.format, "format", "f", "", "use `format` as a Go template to format the output")
+
+	var extractOptions mkcwExtractOptions
+	mkcwExtractCommand := &cobra.Command{
+		Use:   "extract",
+		Short: "Decrypt a confidential workload image's disk image and extract its contents",
+		Long:  `Decrypt the disk image in a confidential workload image and copy its contents to a directory.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return mkcwExtractCmd(cmd, args, extractOptions)
+		},
+		Example: `buildah mkcw extract --passphrase "secret" localhost/repository:cw /tmp/rootfs`,
+		Args:    mkcwSubcommandArgs(2),
+	}
+	mkcwExtractCommand.SetUsageTemplate(UsageTemplate())
+	mkcwCommand.AddCommand(mkcwExtractCommand)
+	mkcwExtractFlags := mkcwExtractCommand.Flags()
+	mkcwExtractFlags.StringVarP(&extractOptions.passphrase, "passphrase", "p", "", "disk encryption passphrase")
+	mkcwExtractFlags.StringVar(&extractOptions.keyFile, "key-file", "", "read the disk encryption passphrase from `file`")
+
 	flags := mkcwCommand.Flags()
 	flags.SetInterspersed(false)
 
-	flags.StringVarP(&teeType, "type", "t", "", "TEE (trusted execution environment) type: SEV,SNP (default: SNP)")
+	flags.StringVarP(&teeType, "type", "t", "", "TEE (trusted execution environment) type: SEV,SNP,TDX (default: SNP)")
 	flags.StringArrayVar(&addFile, "add-file", nil, "add contents of a file to the image at a specified path (`source:destination`)")
 	flags.StringVarP(&options.AttestationURL, "attestation-url", "u", "", "attestation server URL")
 	flags.StringVarP(&options.BaseImage, "base-image", "b", "", "alternate base image (default: scratch)")
 	flags.StringVarP(&options.DiskEncryptionPassphrase, "passphrase", "p", "", "disk encryption passphrase")
+	flags.StringVar(&options.DiskEncryptionKeyFile, "key-file", "", "read the disk encryption passphrase from `file`")
+	flags.StringVar(&options.Filesystem, "filesystem", "", "filesystem type for the disk image: ext4,xfs,erofs (default: ext4)")
+	flags.StringVar(&options.Encryption, "encryption", "", "disk image encryption: luks1,luks2,none (default: luks1)")
+	flags.StringVar(&options.Cipher, "cipher", "", "LUKS `cipher` for encrypting the disk image (default: aes-xts-plain64)")
+	flags.IntVar(&options.SectorSize, "sector-size", 0, "LUKS2 encryption sector size (default: 4096)")
+	flags.BoolVar(&options.Verity, "verity", false, "add a dm-verity hash tree to the disk image")
 	flags.IntVarP(&options.CPUs, "cpus", "c", 0, "number of CPUs to expect")
 	flags.IntVarP(&options.Memory, "memory", "m", 0, "amount of memory to expect (MB)")
 	flags.StringVarP(&options.WorkloadID, "workload-id", "w", "", "workload ID")
 	flags.StringVarP(&options.Slop, "slop", "s", "25%", "extra space needed for converting a container rootfs to a disk image")
-	flags.StringVarP(&options.FirmwareLibrary, "firmware-library", "f", "", "location of libkrunfw-sev.so")
+	flags.StringVarP(&options.FirmwareLibrary, "firmware-library", "f", "", "location of libkrunfw-sev.so, or for TDX, of the TDVF firmware image")
 	flags.BoolVarP(&options.IgnoreAttestationErrors, "ignore-attestation-errors", "", false, "ignore attestation errors")
 	if err := flags.MarkHidden("ignore-attestation-errors"); err != nil {
 		panic(fmt.Sprintf("error marking ignore-attestation-errors as hidden: %v", err))
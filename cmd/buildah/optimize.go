@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	units "github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"go.podman.io/buildah"
+	"go.podman.io/buildah/define"
+	"go.podman.io/buildah/pkg/cli"
+	"go.podman.io/buildah/pkg/parse"
+	"go.podman.io/buildah/util"
+	"go.podman.io/common/pkg/config"
+	"go.podman.io/image/v5/pkg/compression"
+	"go.podman.io/image/v5/pkg/shortnames"
+	storageTransport "go.podman.io/image/v5/storage"
+	"go.podman.io/image/v5/transports/alltransports"
+)
+
+type optimizeOptions struct {
+	output             string
+	mergeSize          string
+	splitPaths         []string
+	format             string
+	disableCompression bool
+	compressionFormat  string
+	compressionLevel   int
+	encryptionKeys     []string
+	encryptLayers      []int
+	iidfile            string
+	quiet              bool
+	signaturePolicy    string
+}
+
+func optimizeInit() {
+	var opts optimizeOptions
+	optimizeDescription := `
+  Writes a new image with the same configuration and a functionally identical
+  root filesystem as an existing image, but without the contents of files
+  which were overwritten or deleted by later layers, with small adjacent
+  layers merged, and optionally with the contents of specified paths moved to
+  a new top layer.`
+	optimizeCommand := &cobra.Command{
+		Use:   "optimize",
+		Short: "Rewrite an image's layers to remove wasted space",
+		Long:  optimizeDescription,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return optimizeCmd(cmd, args, opts)
+		},
+		Example: `buildah optimize --output myimage:optimized myimage
+  buildah optimize --merge-size 10MB --output myimage:optimized myimage
+  buildah optimize --split-path /app --output myimage:optimized myimage`,
+		Args:    cobra.ExactArgs(1),
+		GroupID: groupImages,
+	}
+	optimizeCommand.SetUsageTemplate(UsageTemplate())
+
+	flags := optimizeCommand.Flags()
+	flags.SetInterspersed(false)
+	flags.StringVar(&opts.compressionFormat, "compression-format", "", "compression format to use")
+	flags.IntVar(&opts.compressionLevel, "compression-level", 0, "compression level to use")
+	flags.BoolVarP(&opts.disableCompression, "disable-compression", "D", true, "don't compress layers")
+	flags.StringSliceVar(&opts.encryptionKeys, "encryption-key", nil, "key with the encryption protocol to use needed to encrypt the image (e.g. jwe:/path/to/key.pem)")
+	flags.IntSliceVar(&opts.encryptLayers, "encrypt-layer", nil, "layers to encrypt, 0-indexed layer indices with support for negative indexing (e.g. 0 is the first layer, -1 is the last layer). If not defined, will encrypt all layers if encryption-key flag is specified")
+	flags.StringVarP(&opts.format, "format", "f", defaultFormat(), "`format` of the image manifest and metadata")
+	flags.StringVar(&opts.iidfile, "iidfile", "", "write the image ID to the file")
+	flags.StringVar(&opts.mergeSize, "merge-size", "1MB", "merge adjacent layers which are smaller than `size`, until the merged layer reaches that size (0 to disable)")
+	flags.StringVarP(&opts.output, "output", "o", "", "`name` of the new image")
+	flags.BoolVarP(&opts.quiet, "quiet", "q", false, "don't output progress information when writing images")
+	flags.StringVar(&opts.signaturePolicy, "signature-policy", "", "`pathname` of signature policy file (not usually used)")
+	if err := flags.MarkHidden("signature-policy"); err != nil {
+		panic(fmt.Sprintf("error marking signature-policy as hidden: %v", err))
+	}
+	flags.StringArrayVar(&opts.splitPaths, "split-path", nil, "move the contents of `path` to a new top layer (can be used multiple times)")
+
+	rootCmd.AddCommand(optimizeCommand)
+}
+
+func optimizeCmd(c *cobra.Command, args []string, iopts optimizeOptions) error {
+	if iopts.output == "" {
+		return errors.New("the name of the new image must be specified using --output")
+	}
+	var mergeSize int64
+	if iopts.mergeSize != "0" {
+		size, err := units.FromHumanSize(iopts.mergeSize)
+		if err != nil {
+			return fmt.Errorf("parsing --merge-size value %q: %w", iopts.mergeSize, err)
+		}
+		mergeSize = size
+	}
+	if c.Flag("disable-compression").Changed && iopts.disableCompression && c.Flag("compression-format").Changed {
+		return errors.New("--disable-compression and --compression-format cannot be used together")
+	}
+	format, err := cli.GetFormat(iopts.format)
+	if err != nil {
+		return err
+	}
+
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+	systemContext, err := parse.SystemContextFromOptions(c)
+	if err != nil {
+		return fmt.Errorf("building system context: %w", err)
+	}
+
+	// If the new image's name doesn't include a transport, it goes in
+	// local storage.
+	dest, err := alltransports.ParseImageName(iopts.output)
+	if err != nil {
+		candidates, err2 := shortnames.ResolveLocally(systemContext, iopts.output)
+		if err2 != nil {
+			return err2
+		}
+		if len(candidates) == 0 {
+			return fmt.Errorf("parsing target image name %q", iopts.output)
+		}
+		dest, err2 = storageTransport.Transport.ParseStoreReference(store, candidates[0].String())
+		if err2 != nil {
+			return fmt.Errorf("parsing target image name %q: %w", iopts.output, err)
+		}
+	}
+
+	encConfig, encLayers, err := cli.EncryptConfig(iopts.encryptionKeys, iopts.encryptLayers)
+	if err != nil {
+		return fmt.Errorf("unable to obtain encryption config: %w", err)
+	}
+
+	commitOptions := buildah.CommitOptions{
+		PreferredManifestType: format,
+		Compression:           define.Gzip,
+		SignaturePolicyPath:   iopts.signaturePolicy,
+		SystemContext:         systemContext,
+		IIDFile:               iopts.iidfile,
+		OciEncryptConfig:      encConfig,
+		OciEncryptLayers:      encLayers,
+	}
+	if iopts.disableCompression {
+		commitOptions.Compression = define.Uncompressed
+	}
+	defaultContainerConfig, err := config.Default()
+	if err != nil {
+		return fmt.Errorf("failed to get container config: %w", err)
+	}
+	if iopts.compressionFormat != "" {
+		algo, err := compression.AlgorithmByName(iopts.compressionFormat)
+		if err != nil {
+			return err
+		}
+		commitOptions.CompressionFormat = &algo
+		commitOptions.ForceCompressionFormat = true
+	} else if defaultContainerConfig.Engine.CompressionFormat != "" && defaultContainerConfig.Engine.CompressionFormat != "gzip" {
+		algo, err := compression.AlgorithmByName(defaultContainerConfig.Engine.CompressionFormat)
+		if err != nil {
+			return fmt.Errorf("parsing compression_format from containers.conf: %w", err)
+		}
+		commitOptions.CompressionFormat = &algo
+		commitOptions.ForceCompressionFormat = true
+	}
+	if c.Flag("compression-level").Changed {
+		commitOptions.CompressionLevel = &iopts.compressionLevel
+	} else {
+		commitOptions.CompressionLevel = defaultContainerConfig.Engine.CompressionLevel
+	}
+	if !iopts.quiet {
+		commitOptions.ReportWriter = os.Stderr
+	}
+
+	options := buildah.OptimizeImageOptions{
+		InputImage:          args[0],
+		OutputImage:         dest,
+		MergeSize:           mergeSize,
+		SplitPaths:          iopts.splitPaths,
+		CommitOptions:       commitOptions,
+		SignaturePolicyPath: iopts.signaturePolicy,
+	}
+	id, ref, _, err := buildah.OptimizeImage(getContext(), systemContext, store, options)
+	if err != nil {
+		return util.GetFailureCause(err, fmt.Errorf("optimizing image %q: %w", args[0], err))
+	}
+	if ref != nil {
+		logrus.Debugf("wrote image %s with ID %s", ref, id)
+	}
+	if iopts.iidfile == "" {
+		fmt.Printf("%s\n", id)
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.podman.io/buildah/internal/pin"
+	"go.podman.io/buildah/pkg/parse"
+	"go.podman.io/buildah/pkg/sourcepolicy"
+	"go.podman.io/common/pkg/auth"
+	"go.podman.io/image/v5/docker/reference"
+	"go.podman.io/image/v5/types"
+	"go.podman.io/storage/pkg/ioutils"
+)
+
+type pinRegistryOptions struct {
+	authfile      string
+	certDir       string
+	creds         string
+	tlsVerify     bool
+	platforms     []string
+	buildContexts []string
+}
+
+type pinOptions struct {
+	pinRegistryOptions
+	update           bool
+	sourcePolicyFile string
+}
+
+type outdatedOptions struct {
+	pinRegistryOptions
+	json bool
+}
+
+// addPinRegistryFlags adds the flags which control which references "pin" and
+// "outdated" treat as images, and how they look up the images which those
+// references refer to.
+func addPinRegistryFlags(flags *pflag.FlagSet, opts *pinRegistryOptions) {
+	flags.StringVar(&opts.authfile, "authfile", auth.GetDefaultAuthFile(), "path of the authentication file. Use REGISTRY_AUTH_FILE environment variable to override")
+	flags.StringArrayVar(&opts.buildContexts, "build-context", nil, "`name[=value]` of an additional build context, which references to it aren't treated as images")
+	flags.StringVar(&opts.certDir, "cert-dir", "", "use certificates at the specified path to access the registry")
+	flags.StringVar(&opts.creds, "creds", "", "use `[username[:password]]` for accessing the registry")
+	flags.StringSliceVar(&opts.platforms, "platform", nil, "require that images be available for `OS/ARCH[/VARIANT]`")
+	flags.BoolVar(&opts.tlsVerify, "tls-verify", true, "require HTTPS and verify certificates when accessing the registry. TLS verification cannot be used when talking to an insecure registry.")
+}
+
+func pinInit() {
+	var opts pinOptions
+	pinDescription := `
+  Resolves the images which a Containerfile uses as base images, copies
+  content from, and mounts, to the digests of the manifests which they
+  currently refer to, and rewrites the Containerfile to refer to them using
+  those digests.  Alternatively, writes a source policy which pins base images
+  to those digests at build time.`
+	pinCommand := &cobra.Command{
+		Use:   "pin",
+		Short: "Pin the images which a Containerfile uses to digests",
+		Long:  pinDescription,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pinCmd(cmd, args, opts)
+		},
+		Example: `buildah pin Containerfile
+  buildah pin --update --platform linux/amd64,linux/arm64 Containerfile
+  buildah pin --source-policy-file policy.json Containerfile`,
+		Args:    cobra.ExactArgs(1),
+		GroupID: groupImages,
+	}
+	pinCommand.SetUsageTemplate(UsageTemplate())
+
+	flags := pinCommand.Flags()
+	flags.SetInterspersed(false)
+	addPinRegistryFlags(flags, &opts.pinRegistryOptions)
+	flags.StringVar(&opts.sourcePolicyFile, "source-policy-file", "", "write a source policy to `pathname` instead of rewriting the Containerfile (\"-\" for stdout)")
+	flags.BoolVar(&opts.update, "update", false, "also update references which are already pinned, if they include tags")
+
+	rootCmd.AddCommand(pinCommand)
+}
+
+func outdatedInit() {
+	var opts outdatedOptions
+	outdatedDescription := `
+  Checks whether the images which a Containerfile refers to using both tags
+  and digests have been updated, so that the tags no longer refer to the
+  digests.`
+	outdatedCommand := &cobra.Command{
+		Use:   "outdated",
+		Short: "Report pinned images in a Containerfile which have been updated",
+		Long:  outdatedDescription,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outdatedCmd(cmd, args, opts)
+		},
+		Example: `buildah outdated Containerfile
+  buildah outdated --json Containerfile`,
+		Args:    cobra.ExactArgs(1),
+		GroupID: groupImages,
+	}
+	outdatedCommand.SetUsageTemplate(UsageTemplate())
+
+	flags := outdatedCommand.Flags()
+	flags.SetInterspersed(false)
+	addPinRegistryFlags(flags, &opts.pinRegistryOptions)
+	flags.BoolVar(&opts.json, "json", false, "output in JSON format")
+
+	rootCmd.AddCommand(outdatedCommand)
+}
+
+// pinResolver resolves names to digests, remembering the results so that
+// names which are used more than once are only looked up once.
+type pinResolver struct {
+	systemContext *types.SystemContext
+	platforms     []v1.Platform
+	resolved      map[string]pinResolution
+}
+
+type pinResolution struct {
+	named  reference.Named
+	digest digest.Digest
+}
+
+func newPinResolver(c *cobra.Command, opts pinRegistryOptions) (*pinResolver, error) {
+	if err := auth.CheckAuthFile(opts.authfile); err != nil {
+		return nil, err
+	}
+	systemContext, err := parse.SystemContextFromOptions(c)
+	if err != nil {
+		return nil, fmt.Errorf("building system context: %w", err)
+	}
+	var platforms []v1.Platform
+	for _, platform := range opts.platforms {
+		os, arch, variant, err := parse.Platform(platform)
+		if err != nil {
+			return nil, fmt.Errorf("parsing platform %q: %w", platform, err)
+		}
+		platforms = append(platforms, v1.Platform{OS: os, Architecture: arch, Variant: variant})
+	}
+	return &pinResolver{
+		systemContext: systemContext,
+		platforms:     platforms,
+		resolved:      make(map[string]pinResolution),
+	}, nil
+}
+
+func (r *pinResolver) resolve(ctx context.Context, ref pin.Reference) (pinResolution, error) {
+	if resolution, ok := r.resolved[ref.Name]; ok {
+		return resolution, nil
+	}
+	named, d, err := pin.Resolve(ctx, r.systemContext, ref.Name, r.platforms)
+	if err != nil {
+		return pinResolution{}, fmt.Errorf("line %d: %w", ref.StartLine, err)
+	}
+	resolution := pinResolution{named: named, digest: d}
+	r.resolved[ref.Name] = resolution
+	return resolution, nil
+}
+
+// readPinReferences reads a Containerfile and finds the image references in it,
+// skipping references to the additional build contexts.
+func readPinReferences(containerfile string, opts pinRegistryOptions) ([]byte, []pin.Reference, error) {
+	contents, err := os.ReadFile(containerfile)
+	if err != nil {
+		return nil, nil, err
+	}
+	// accept the same values that "build --build-context" does, but
+	// only their names matter here
+	var buildContexts []string
+	for _, buildContext := range opts.buildContexts {
+		name, _, _ := strings.Cut(buildContext, "=")
+		if name == "" {
+			return nil, nil, fmt.Errorf("build context %q has no name", buildContext)
+		}
+		buildContexts = append(buildContexts, name)
+	}
+	refs, warnings, err := pin.Find(contents, buildContexts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", containerfile, err)
+	}
+	for _, warning := range warnings {
+		logrus.Warnf("%s: %s", containerfile, warning)
+	}
+	return contents, refs, nil
+}
+
+func pinCmd(c *cobra.Command, args []string, iopts pinOptions) error {
+	containerfile := args[0]
+	contents, refs, err := readPinReferences(containerfile, iopts.pinRegistryOptions)
+	if err != nil {
+		return err
+	}
+	resolver, err := newPinResolver(c, iopts.pinRegistryOptions)
+	if err != nil {
+		return err
+	}
+
+	ctx := getContext()
+	replacements := make([]string, len(refs))
+	var policy sourcepolicy.Policy
+	var changed bool
+	for i, ref := range refs {
+		if ref.Digest != "" && (!iopts.update || ref.Tag == "") {
+			logrus.Debugf("line %d: %q is already pinned", ref.StartLine, ref.Original)
+			continue
+		}
+		resolution, err := resolver.resolve(ctx, ref)
+		if err != nil {
+			return err
+		}
+		if iopts.sourcePolicyFile != "" {
+			rule, err := pin.PolicyRule(ref, resolution.named, resolution.digest)
+			if err != nil {
+				return err
+			}
+			if !slices.ContainsFunc(policy.Rules, func(r sourcepolicy.Rule) bool { return r.Selector == rule.Selector }) {
+				policy.Rules = append(policy.Rules, rule)
+			}
+			continue
+		}
+		if ref.Digest == resolution.digest {
+			continue
+		}
+		replacements[i] = ref.Pinned(resolution.digest)
+		changed = true
+		fmt.Printf("line %d: %s -> %s\n", ref.StartLine, ref.Original, replacements[i])
+	}
+
+	if iopts.sourcePolicyFile != "" {
+		if policy.Rules == nil {
+			policy.Rules = []sourcepolicy.Rule{}
+		}
+		encoded, err := json.MarshalIndent(policy, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding source policy: %w", err)
+		}
+		encoded = append(encoded, '\n')
+		if iopts.sourcePolicyFile == "-" {
+			_, err = os.Stdout.Write(encoded)
+			return err
+		}
+		return os.WriteFile(iopts.sourcePolicyFile, encoded, 0o644)
+	}
+
+	if !changed {
+		return nil
+	}
+	rewritten, err := pin.Rewrite(contents, refs, replacements)
+	if err != nil {
+		return fmt.Errorf("%s: %w", containerfile, err)
+	}
+	st, err := os.Stat(containerfile)
+	if err != nil {
+		return err
+	}
+	return ioutils.AtomicWriteFile(containerfile, rewritten, st.Mode().Perm())
+}
+
+type outdatedReference struct {
+	Line    int    `json:"line"`
+	Image   string `json:"image"`
+	Pinned  string `json:"pinned"`
+	Current string `json:"current"`
+	Updated bool   `json:"updated"`
+}
+
+func outdatedCmd(c *cobra.Command, args []string, iopts outdatedOptions) error {
+	_, refs, err := readPinReferences(args[0], iopts.pinRegistryOptions)
+	if err != nil {
+		return err
+	}
+	resolver, err := newPinResolver(c, iopts.pinRegistryOptions)
+	if err != nil {
+		return err
+	}
+
+	ctx := getContext()
+	results := []outdatedReference{}
+	for _, ref := range refs {
+		if ref.Digest == "" || ref.Tag == "" {
+			logrus.Debugf("line %d: %q is not pinned using both a tag and a digest", ref.StartLine, ref.Original)
+			continue
+		}
+		resolution, err := resolver.resolve(ctx, ref)
+		if err != nil {
+			return err
+		}
+		results = append(results, outdatedReference{
+			Line:    ref.StartLine,
+			Image:   ref.Name,
+			Pinned:  ref.Digest.String(),
+			Current: resolution.digest.String(),
+			Updated: ref.Digest != resolution.digest,
+		})
+	}
+
+	// let scripts find out whether anything needs updating without
+	// parsing the output
+	if slices.ContainsFunc(results, func(result outdatedReference) bool { return result.Updated }) {
+		exitCode = 1
+	}
+	if iopts.json {
+		encoded, err := json.MarshalIndent(results, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", encoded)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(w, "LINE\tIMAGE\tSTATUS\tPINNED\tCURRENT\n")
+	for _, result := range results {
+		status := "current"
+		if result.Updated {
+			status = "outdated"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", result.Line, result.Image, status, result.Pinned, result.Current)
+	}
+	return w.Flush()
+}
@@ -43,6 +43,9 @@ type pushOptions struct {
 	removeSignatures       bool
 	signaturePolicy        string
 	signBy                 string
+	signBySigstore         string
+	signBySigstoreKey      string
+	signPassphraseFile     string
 	tlsVerify              bool
 	encryptionKeys         []string
 	encryptLayers          []int
@@ -98,6 +101,9 @@ func pushInit() {
 	flags.BoolVar(&opts.rm, "rm", false, "remove the manifest list if push succeeds")
 	flags.BoolVarP(&opts.removeSignatures, "remove-signatures", "", false, "don't copy signatures when pushing image")
 	flags.StringVar(&opts.signBy, "sign-by", "", "sign the image using a GPG key with the specified `FINGERPRINT`")
+	flags.StringVar(&opts.signBySigstore, "sign-by-sigstore", "", "sign the image using a sigstore parameter file at `PATH`")
+	flags.StringVar(&opts.signBySigstoreKey, "sign-by-sigstore-private-key", "", "sign the image using a sigstore private key at `PATH`")
+	flags.StringVar(&opts.signPassphraseFile, "sign-passphrase-file", "", "read a passphrase for signing an image from `PATH`")
 	flags.StringVar(&opts.signaturePolicy, "signature-policy", "", "`pathname` of signature policy file (not usually used)")
 	flags.StringSliceVar(&opts.encryptionKeys, "encryption-key", nil, "key with the encryption protocol to use needed to encrypt the image (e.g. jwe:/path/to/key.pem)")
 	flags.IntSliceVar(&opts.encryptLayers, "encrypt-layer", nil, "layers to encrypt, 0-indexed layer indices with support for negative indexing (e.g. 0 is the first layer, -1 is the last layer). If not defined, will encrypt all layers if encryption-key flag is specified")
@@ -195,19 +201,32 @@ func pushCmd(c *cobra.Command, args []string, iopts pushOptions) error {
 		return fmt.Errorf("unable to obtain encryption config: %w", err)
 	}
 
+	passphrase, signers, err := cli.SigstoreSigning(iopts.signBySigstoreKey, iopts.signPassphraseFile, iopts.signBySigstore)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, signer := range signers {
+			signer.Close()
+		}
+	}()
+
 	options := buildah.PushOptions{
-		Compression:            compress,
-		ManifestType:           manifestType,
-		SignaturePolicyPath:    iopts.signaturePolicy,
-		Store:                  store,
-		SystemContext:          systemContext,
-		BlobDirectory:          iopts.blobCache,
-		RemoveSignatures:       iopts.removeSignatures,
-		SignBy:                 iopts.signBy,
-		MaxRetries:             iopts.retry,
-		OciEncryptConfig:       encConfig,
-		OciEncryptLayers:       encLayers,
-		ForceCompressionFormat: iopts.forceCompressionFormat,
+		Compression:                      compress,
+		ManifestType:                     manifestType,
+		SignaturePolicyPath:              iopts.signaturePolicy,
+		Store:                            store,
+		SystemContext:                    systemContext,
+		BlobDirectory:                    iopts.blobCache,
+		RemoveSignatures:                 iopts.removeSignatures,
+		SignBy:                           iopts.signBy,
+		SignBySigstorePrivateKeyFile:     iopts.signBySigstoreKey,
+		SignSigstorePrivateKeyPassphrase: passphrase,
+		Signers:                          signers,
+		MaxRetries:                       iopts.retry,
+		OciEncryptConfig:                 encConfig,
+		OciEncryptLayers:                 encLayers,
+		ForceCompressionFormat:           iopts.forceCompressionFormat,
 	}
 	if iopts.retryDelay != "" {
 		options.RetryDelay, err = time.ParseDuration(iopts.retryDelay)
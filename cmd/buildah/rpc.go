@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -8,8 +9,11 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"go.podman.io/buildah/internal/rpc/build"
+	"go.podman.io/buildah/internal/rpc/debug"
 	"go.podman.io/buildah/internal/rpc/listen"
 	"go.podman.io/buildah/internal/rpc/noop"
+	"go.podman.io/buildah/pkg/parse"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
@@ -58,8 +62,23 @@ func rpcCmd(c *cobra.Command, args []string) error {
 		}
 	}()
 
+	systemContext, err := parse.SystemContextFromOptions(c)
+	if err != nil {
+		return fmt.Errorf("building system context: %w", err)
+	}
+
 	s := grpc.NewServer()
+	service, err := build.Register(s, store, build.Options{SystemContext: systemContext})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := service.Close(); err != nil {
+			logrus.Errorf("cleaning up build service: %v", err)
+		}
+	}()
 	noop.Register(s)
+	debug.Register(s)
 	reflection.Register(s)
 
 	var errgroup errgroup.Group
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
@@ -19,12 +21,17 @@ import (
 	"go.podman.io/storage/pkg/mount"
 )
 
+// detachedRunEnvVar is set in the environment of the copy of ourselves which
+// "buildah run --detach" starts to run the command.
+const detachedRunEnvVar = "_BUILDAH_DETACHED_RUN_ID"
+
 type runInputOptions struct {
 	addHistory     bool
 	capAdd         []string
 	capDrop        []string
 	cdiConfigDir   string
 	contextDir     string
+	detach         bool
 	devices        []string
 	env            []string
 	hostname       string
@@ -59,6 +66,7 @@ func runInit() {
 			return runCmd(cmd, args, opts)
 		},
 		Example: `buildah run containerID -- ps -auxw
+  buildah run --detach containerID -- /usr/bin/postgres
   buildah run --terminal containerID /bin/bash
   buildah run --volume /path/on/host:/path/in/container:ro,z containerID /bin/sh`,
 		GroupID: groupContainers,
@@ -73,6 +81,7 @@ func runInit() {
 	flags.StringVar(&opts.cdiConfigDir, "cdi-config-dir", "", "`directory` of CDI configuration files")
 	_ = flags.MarkHidden("cdi-config-dir")
 	flags.StringVar(&opts.contextDir, "contextdir", "", "context directory path")
+	flags.BoolVarP(&opts.detach, "detach", "d", false, "run the command in the background and print the ID of the run")
 	flags.StringArrayVar(&opts.devices, "device", []string{}, "additional devices to provide")
 	flags.StringArrayVarP(&opts.env, "env", "e", []string{}, "add environment variable to be set temporarily when running command (default [])")
 	flags.StringVar(&opts.hostname, "hostname", "", "set the hostname inside of the container")
@@ -189,6 +198,24 @@ func runCmd(c *cobra.Command, args []string, iopts runInputOptions) error {
 
 	options.Env = buildahcli.LookupEnvVarReferences(iopts.env, os.Environ())
 
+	// If we were started by another "buildah run --detach", we're the
+	// process that waits for the command to exit.
+	options.DetachedRunID = os.Getenv(detachedRunEnvVar)
+	if options.DetachedRunID != "" {
+		if err := os.Unsetenv(detachedRunEnvVar); err != nil {
+			return err
+		}
+		options.Terminal = buildah.WithoutTerminal
+	} else if iopts.detach {
+		if iopts.terminal {
+			return errors.New("the --detach and --terminal options cannot be used together")
+		}
+		if iopts.addHistory {
+			return errors.New("the --detach and --add-history options cannot be used together")
+		}
+		return runDetached(builder, args)
+	}
+
 	systemContext, err := parse.SystemContextFromOptions(c)
 	if err != nil {
 		return fmt.Errorf("building system context: %w", err)
@@ -224,7 +251,7 @@ func runCmd(c *cobra.Command, args []string, iopts runInputOptions) error {
 	if runerr != nil {
 		logrus.Debugf("error running %v in container %q: %v", args, builder.Container, runerr)
 	}
-	if runerr == nil {
+	if runerr == nil && options.DetachedRunID == "" {
 		shell := "/bin/sh -c"
 		if len(builder.Shell()) > 0 {
 			shell = strings.Join(builder.Shell(), " ")
@@ -234,3 +261,63 @@ func runCmd(c *cobra.Command, args []string, iopts runInputOptions) error {
 	}
 	return runerr
 }
+
+// runDetached starts a copy of ourselves in a new session to run the command
+// and wait for it to exit, and prints the ID of the run once the command has
+// been started.
+func runDetached(builder *buildah.Builder, command []string) error {
+	run, err := builder.CreateDetachedRun(command)
+	if err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(run.LogPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return err
+	}
+	defer devNull.Close()
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating buildah binary: %w", err)
+	}
+	monitor := exec.Command(self, os.Args[1:]...)
+	monitor.Env = append(os.Environ(), detachedRunEnvVar+"="+run.ID)
+	monitor.Stdin, monitor.Stdout, monitor.Stderr = devNull, logFile, logFile
+	monitor.SysProcAttr = detachedProcAttr()
+	if err := monitor.Start(); err != nil {
+		return fmt.Errorf("starting detached run: %w", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = monitor.Wait()
+		close(exited)
+	}()
+	for {
+		current, err := builder.DetachedRun(run.ID)
+		if err != nil {
+			return err
+		}
+		if current.Status != buildah.DetachedRunCreated {
+			fmt.Println(run.ID)
+			return nil
+		}
+		select {
+		case <-exited:
+			// Check one more time in case it finished quickly.
+			if current, err = builder.DetachedRun(run.ID); err == nil && current.Status != buildah.DetachedRunCreated {
+				fmt.Println(run.ID)
+				return nil
+			}
+			output, _ := os.ReadFile(run.LogPath)
+			if err := builder.RemoveDetachedRun(run.ID); err != nil {
+				logrus.Debugf("removing record of failed run %q: %v", run.ID, err)
+			}
+			return fmt.Errorf("starting detached run: %s", strings.TrimSpace(string(output)))
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package main
+
+import "syscall"
+
+// detachedProcAttr returns attributes for a process which should not be
+// affected by signals sent to our terminal's process group.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"go.podman.io/buildah/internal/registry"
+)
+
+type serveOptions struct {
+	listen    string
+	readWrite bool
+}
+
+func serveInit() {
+	var opts serveOptions
+	serveDescription := `
+  Serves images and manifest lists in local storage to clients which use the
+  OCI distribution API to pull them, until it is interrupted or terminated.
+  If --read-write is specified, images can also be pushed, and are added to
+  local storage.`
+	serveCommand := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve local images over the OCI distribution API",
+		Long:  serveDescription,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return serveCmd(cmd, opts)
+		},
+		Example: `buildah serve
+  buildah serve --listen 127.0.0.1:5000 --read-write`,
+		Args:    cobra.NoArgs,
+		GroupID: groupImages,
+	}
+	serveCommand.SetUsageTemplate(UsageTemplate())
+
+	flags := serveCommand.Flags()
+	flags.StringVarP(&opts.listen, "listen", "l", "127.0.0.1:5000", "listen for requests at `address:port`")
+	flags.BoolVar(&opts.readWrite, "read-write", false, "allow clients to push images")
+
+	rootCmd.AddCommand(serveCommand)
+}
+
+func serveCmd(c *cobra.Command, iopts serveOptions) error {
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+	server, err := registry.New(store, registry.Options{ReadWrite: iopts.readWrite})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := server.Close(); err != nil {
+			logrus.Errorf("cleaning up: %v", err)
+		}
+	}()
+
+	listener, err := net.Listen("tcp", iopts.listen)
+	if err != nil {
+		return err
+	}
+	// don't let clients hold connections open without sending anything,
+	// but don't set limits on whole requests or responses, which can take
+	// as long as it takes to transfer a large layer blob
+	httpServer := &http.Server{
+		Handler:           server,
+		ReadHeaderTimeout: 30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- httpServer.Serve(listener)
+	}()
+	fmt.Fprintf(os.Stderr, "Listening for requests at %q\n", listener.Addr().String())
+	var serveErr error
+	select {
+	case sig := <-signals:
+		logrus.Debugf("received %v, shutting down", sig)
+		if err := httpServer.Shutdown(context.Background()); err != nil {
+			logrus.Errorf("shutting down: %v", err)
+		}
+		serveErr = <-served
+	case serveErr = <-served:
+	}
+	if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+		return fmt.Errorf("serving requests: %w", serveErr)
+	}
+	return nil
+}
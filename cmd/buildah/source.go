@@ -2,11 +2,42 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"go.podman.io/buildah/internal/source"
+	"go.podman.io/common/pkg/formats"
+	"golang.org/x/term"
 )
 
+type sourceInspectOptions struct {
+	format string
+}
+
+type sourceListOptions struct {
+	format    string
+	noHeading bool
+}
+
+type sourceArtifactOutputParams struct {
+	Name      string
+	Digest    string
+	MediaType string
+	Size      string
+}
+
+var sourceArtifactsHeader = map[string]string{
+	"Name":      "NAME",
+	"Digest":    "DIGEST",
+	"MediaType": "MEDIA TYPE",
+	"Size":      "SIZE",
+}
+
 var (
 	// buildah source
 	sourceDescription = `  Create, push, pull and manage source images and associated source artifacts.  A source image contains all source artifacts an ordinary OCI image has been built with.  Those artifacts can be any kind of source artifact, such as source RPMs, an entire source tree or text files.
@@ -73,6 +104,78 @@ var (
 		},
 	}
 
+	// buildah source inspect
+	sourceInspectOpts        = sourceInspectOptions{}
+	sourceInspectDescription = `  Inspect a source image at a specified path, displaying its configuration and annotations, and the annotations and sizes of its source artifacts.
+
+  Note that the buildah-source command and all its subcommands are experimental and may be subject to future changes.
+`
+	sourceInspectCommand = &cobra.Command{
+		Args:    cobra.ExactArgs(1),
+		Use:     "inspect",
+		Short:   "Inspect a source image",
+		Long:    sourceInspectDescription,
+		Example: "buildah source inspect /tmp/fedora:latest-source",
+		RunE: func(_ *cobra.Command, args []string) error {
+			return sourceInspectCmd(args[0], sourceInspectOpts)
+		},
+	}
+
+	// buildah source ls
+	sourceListOpts        = sourceListOptions{}
+	sourceListDescription = `  List the source artifacts in a source image at a specified path.
+
+  Note that the buildah-source command and all its subcommands are experimental and may be subject to future changes.
+`
+	sourceListCommand = &cobra.Command{
+		Args:    cobra.ExactArgs(1),
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List the source artifacts in a source image",
+		Long:    sourceListDescription,
+		Example: "buildah source ls /tmp/fedora:latest-source",
+		RunE: func(_ *cobra.Command, args []string) error {
+			return sourceListCmd(args[0], sourceListOpts)
+		},
+	}
+
+	// buildah source extract
+	sourceExtractOptions     = source.ExtractOptions{}
+	sourceExtractDestination string
+	sourceExtractDescription = `  Extract source artifacts from a source image at a specified path into a directory.  If no artifacts are named, all of them are extracted.
+
+  Note that the buildah-source command and all its subcommands are experimental and may be subject to future changes.
+`
+	sourceExtractCommand = &cobra.Command{
+		Args:    cobra.ExactArgs(1),
+		Use:     "extract",
+		Short:   "Extract source artifacts from a source image",
+		Long:    sourceExtractDescription,
+		Example: "buildah source extract --artifact sources.tar.gz -o /tmp/sources /tmp/fedora:latest-source",
+		RunE: func(_ *cobra.Command, args []string) error {
+			if sourceExtractDestination == "" {
+				return errors.New("an output directory must be specified with --output")
+			}
+			return source.Extract(context.Background(), args[0], sourceExtractDestination, sourceExtractOptions)
+		},
+	}
+
+	// buildah source verify
+	sourceVerifyDescription = `  Verify that the manifest, configuration, and source artifacts of a source image at a specified path match the digests and sizes which refer to them.
+
+  Note that the buildah-source command and all its subcommands are experimental and may be subject to future changes.
+`
+	sourceVerifyCommand = &cobra.Command{
+		Args:    cobra.ExactArgs(1),
+		Use:     "verify",
+		Short:   "Verify the contents of a source image",
+		Long:    sourceVerifyDescription,
+		Example: "buildah source verify /tmp/fedora:latest-source",
+		RunE: func(_ *cobra.Command, args []string) error {
+			return source.Verify(args[0])
+		},
+	}
+
 	// buildah source push
 	sourcePushOptions     = source.PushOptions{}
 	sourcePushDescription = `  Push a source image from a specified path to a registry.
@@ -117,6 +220,30 @@ func sourceInit() {
 	sourcePullFlags.BoolVar(&sourcePullOptions.TLSVerify, "tls-verify", true, "require HTTPS and verify certificates when accessing the registry")
 	sourcePullFlags.BoolVarP(&sourcePullOptions.Quiet, "quiet", "q", false, "don't output pull progress information")
 
+	// buildah source inspect
+	sourceInspectCommand.SetUsageTemplate(UsageTemplate())
+	sourceCommand.AddCommand(sourceInspectCommand)
+	sourceInspectFlags := sourceInspectCommand.Flags()
+	sourceInspectFlags.StringVarP(&sourceInspectOpts.format, "format", "f", "", "use `format` as a Go template to format the output")
+
+	// buildah source ls
+	sourceListCommand.SetUsageTemplate(UsageTemplate())
+	sourceCommand.AddCommand(sourceListCommand)
+	sourceListFlags := sourceListCommand.Flags()
+	sourceListFlags.StringVar(&sourceListOpts.format, "format", "", "pretty-print source artifacts using a Go template")
+	sourceListFlags.BoolVarP(&sourceListOpts.noHeading, "noheading", "n", false, "do not print column headings")
+
+	// buildah source extract
+	sourceExtractCommand.SetUsageTemplate(UsageTemplate())
+	sourceCommand.AddCommand(sourceExtractCommand)
+	sourceExtractFlags := sourceExtractCommand.Flags()
+	sourceExtractFlags.StringArrayVar(&sourceExtractOptions.Artifacts, "artifact", []string{}, "extract the source artifact with this `name` or digest (default is all artifacts)")
+	sourceExtractFlags.StringVarP(&sourceExtractDestination, "output", "o", "", "extract into `directory`")
+
+	// buildah source verify
+	sourceVerifyCommand.SetUsageTemplate(UsageTemplate())
+	sourceCommand.AddCommand(sourceVerifyCommand)
+
 	// buildah source push
 	sourcePushCommand.SetUsageTemplate(UsageTemplate())
 	sourceCommand.AddCommand(sourcePushCommand)
@@ -126,3 +253,61 @@ func sourceInit() {
 	sourcePushFlags.BoolVar(&sourcePushOptions.TLSVerify, "tls-verify", true, "require HTTPS and verify certificates when accessing the registry")
 	sourcePushFlags.BoolVarP(&sourcePushOptions.Quiet, "quiet", "q", false, "don't output push progress information")
 }
+
+func sourceInspectCmd(sourcePath string, iopts sourceInspectOptions) error {
+	info, err := source.Inspect(context.Background(), sourcePath)
+	if err != nil {
+		return err
+	}
+	if iopts.format != "" {
+		format := iopts.format
+		if matched, err := regexp.MatchString("{{.*}}", format); err != nil {
+			return fmt.Errorf("validating format provided: %s: %w", format, err)
+		} else if !matched {
+			return fmt.Errorf("invalid format provided: %s", format)
+		}
+		t, err := formats.NewParse("inspect", format)
+		if err != nil {
+			return fmt.Errorf("template parsing error: %w", err)
+		}
+		if err = t.Execute(os.Stdout, info); err != nil {
+			return err
+		}
+		if term.IsTerminal(int(os.Stdout.Fd())) {
+			fmt.Println()
+		}
+		return nil
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "    ")
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		enc.SetEscapeHTML(false)
+	}
+	return enc.Encode(info)
+}
+
+func sourceListCmd(sourcePath string, iopts sourceListOptions) error {
+	info, err := source.Inspect(context.Background(), sourcePath)
+	if err != nil {
+		return err
+	}
+	var outputData []any
+	for _, artifact := range info.Artifacts {
+		outputData = append(outputData, sourceArtifactOutputParams{
+			Name:      artifact.Name,
+			Digest:    artifact.Digest.String(),
+			MediaType: artifact.MediaType,
+			Size:      formattedSize(artifact.Size),
+		})
+	}
+	format := "table {{.Name}}\t{{.Digest}}\t{{.Size}}"
+	if iopts.noHeading {
+		format = strings.TrimPrefix(format, "table ")
+	}
+	if iopts.format != "" {
+		format = strings.ReplaceAll(iopts.format, `\t`, "\t")
+	}
+	out := formats.StdoutTemplateArray{Output: outputData, Template: format, Fields: sourceArtifactsHeader}
+	return formats.Writer(out).Out()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.podman.io/buildah"
+	"go.podman.io/buildah/util"
+)
+
+type stopInputOptions struct {
+	run     string
+	timeout uint
+}
+
+func stopInit() {
+	var (
+		stopDescription = "\n  Stops commands which were started in working containers using\n  \"buildah run --detach\"."
+		opts            stopInputOptions
+	)
+
+	stopCommand := &cobra.Command{
+		Use:   "stop",
+		Short: "Stop detached commands in one or more working containers",
+		Long:  stopDescription,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return stopCmd(cmd, args, opts)
+		},
+		Example: `buildah stop containerID
+  buildah stop --run runID --time 30 containerID`,
+		GroupID: groupContainers,
+	}
+	stopCommand.SetUsageTemplate(UsageTemplate())
+
+	flags := stopCommand.Flags()
+	flags.SetInterspersed(false)
+	flags.StringVar(&opts.run, "run", "", "`ID` of the detached run to stop (default is all running runs)")
+	flags.UintVarP(&opts.timeout, "time", "t", 10, "`seconds` to wait before forcibly stopping the command")
+
+	rootCmd.AddCommand(stopCommand)
+}
+
+func stopCmd(c *cobra.Command, args []string, iopts stopInputOptions) error {
+	if len(args) == 0 {
+		return errors.New("container ID must be specified")
+	}
+	if iopts.run != "" && len(args) > 1 {
+		return errors.New("when using the --run option, only one container may be specified")
+	}
+
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+
+	timeout := time.Duration(iopts.timeout) * time.Second
+	var lastError error
+	for _, name := range args {
+		builder, err := openBuilder(getContext(), store, name)
+		if err != nil {
+			lastError = util.WriteError(os.Stderr, fmt.Errorf("reading build container %q: %w", name, err), lastError)
+			continue
+		}
+		var runs []*buildah.DetachedRun
+		if iopts.run != "" {
+			run, err := builder.DetachedRun(iopts.run)
+			if err != nil {
+				lastError = util.WriteError(os.Stderr, err, lastError)
+				continue
+			}
+			runs = append(runs, run)
+		} else {
+			if runs, err = builder.DetachedRuns(); err != nil {
+				lastError = util.WriteError(os.Stderr, err, lastError)
+				continue
+			}
+		}
+		for _, run := range runs {
+			if !run.Running() {
+				continue
+			}
+			if err := builder.StopDetached(run.ID, timeout); err != nil {
+				lastError = util.WriteError(os.Stderr, fmt.Errorf("stopping run %q in container %q: %w", run.ID, builder.Container, err), lastError)
+				continue
+			}
+			fmt.Printf("%s\n", run.ID)
+		}
+	}
+	return lastError
+}
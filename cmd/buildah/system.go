@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"go.podman.io/buildah/internal/rpc/build"
+	"go.podman.io/buildah/internal/rpc/build/pb"
+	"go.podman.io/buildah/internal/rpc/debug"
+	"go.podman.io/buildah/internal/rpc/listen"
+	"go.podman.io/buildah/internal/rpc/noop"
+	"go.podman.io/buildah/pkg/parse"
+	"go.podman.io/common/pkg/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+type systemServiceOptions struct {
+	listenPath      string
+	authfile        string
+	contextDir      string
+	contextExpiry   time.Duration
+	pushDir         string
+	signaturePolicy string
+}
+
+type systemBuildOptions struct {
+	connect     string
+	file        []string
+	tag         []string
+	buildArg    []string
+	target      string
+	label       []string
+	layers      bool
+	noCache     bool
+	pull        string
+	isolation   string
+	format      string
+	ignorefile  string
+	iidfile     string
+	quiet       bool
+	sessionFile string
+}
+
+var (
+	// buildah system
+	systemDescription = "\n  Manage buildah itself."
+	systemCommand     = &cobra.Command{
+		Use:   "system",
+		Short: "Manage buildah",
+		Long:  systemDescription,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return nil
+		},
+	}
+
+	// buildah system service
+	systemServiceDescription = `
+  Runs a gRPC service which builds, lists, inspects, and pushes images, and lists
+  and inspects working containers, until it is interrupted or terminated.`
+	systemServiceOpts    = systemServiceOptions{}
+	systemServiceCommand = &cobra.Command{
+		Use:   "service",
+		Short: "Run a build service",
+		Long:  systemServiceDescription,
+		RunE: func(c *cobra.Command, _ []string) error {
+			return systemServiceCmd(c, systemServiceOpts)
+		},
+		Example: `buildah system service
+  buildah system service --listen /run/buildah/buildah.sock`,
+		Args: cobra.NoArgs,
+	}
+
+	// buildah system build
+	systemBuildDescription = `
+  Builds an image using a build service started by "buildah system service",
+  sending it a copy of the build context.`
+	systemBuildOpts    = systemBuildOptions{}
+	systemBuildCommand = &cobra.Command{
+		Use:   "build",
+		Short: "Build an image using a build service",
+		Long:  systemBuildDescription,
+		RunE: func(c *cobra.Command, args []string) error {
+			return systemBuildCmd(c, args, systemBuildOpts)
+		},
+		Example: `buildah system build -t myimage .
+  buildah system build --connect /run/buildah/buildah.sock -f Containerfile.test -t myimage:test .`,
+		Args: cobra.ExactArgs(1),
+	}
+)
+
+func systemServiceCmd(c *cobra.Command, iopts systemServiceOptions) error {
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+	systemContext, err := parse.SystemContextFromOptions(c)
+	if err != nil {
+		return fmt.Errorf("building system context: %w", err)
+	}
+
+	socketPath := iopts.listenPath
+	if socketPath == "" {
+		socketPath = filepath.Join(store.RunRoot(), "buildah.sock")
+	}
+	listener, cleanup, err := listen.Listen(socketPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			logrus.Errorf("cleaning up: %v", err)
+		}
+	}()
+
+	s := grpc.NewServer()
+	service, err := build.Register(s, store, build.Options{SystemContext: systemContext, ContextDirectory: iopts.contextDir, PushDirectory: iopts.pushDir, UploadedContextExpiry: iopts.contextExpiry})
+	if err != nil {
+		return err
+	}
+	noop.Register(s)
+	debug.Register(s)
+	reflection.Register(s)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(listener)
+	}()
+	fmt.Fprintf(os.Stderr, "Listening for requests at %q\n", socketPath)
+	var serveErr error
+	select {
+	case sig := <-signals:
+		logrus.Debugf("received %v, shutting down", sig)
+		// cancel any builds or pushes which are in progress, so that
+		// they don't hold up GracefulStop()
+		if err := service.Close(); err != nil {
+			logrus.Errorf("cleaning up build service: %v", err)
+		}
+		s.GracefulStop() // closes the listening socket
+		serveErr = <-served
+	case serveErr = <-served:
+		if err := service.Close(); err != nil {
+			logrus.Errorf("cleaning up build service: %v", err)
+		}
+	}
+	if serveErr != nil && !errors.Is(serveErr, grpc.ErrServerStopped) {
+		return fmt.Errorf("serving requests: %w", serveErr)
+	}
+	return nil
+}
+
+func systemBuildCmd(c *cobra.Command, args []string, iopts systemBuildOptions) error {
+	socketPath := iopts.connect
+	if socketPath == "" {
+		store, err := getStore(c)
+		if err != nil {
+			return err
+		}
+		socketPath = filepath.Join(store.RunRoot(), "buildah.sock")
+	}
+	contextDir, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("determining path of build context %q: %w", args[0], err)
+	}
+	// Containerfiles are found in the build context, the way the service
+	// will look for them in its copy of it
+	for _, containerfile := range iopts.file {
+		if !filepath.IsLocal(containerfile) {
+			return fmt.Errorf("Containerfile %q is not a relative path in the build context", containerfile)
+		}
+	}
+	req := &pb.BuildRequest{
+		Containerfiles: iopts.file,
+		Tags:           iopts.tag,
+		Target:         iopts.target,
+		Labels:         iopts.label,
+		Layers:         iopts.layers,
+		NoCache:        iopts.noCache,
+		Pull:           iopts.pull,
+		Isolation:      iopts.isolation,
+		Format:         iopts.format,
+	}
+	if len(iopts.buildArg) > 0 {
+		req.BuildArgs = make(map[string]string)
+		for _, arg := range iopts.buildArg {
+			name, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				value, ok = os.LookupEnv(name)
+			}
+			if ok {
+				req.BuildArgs[name] = value
+			}
+		}
+	}
+
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("connecting to build service at %q: %w", socketPath, err)
+	}
+	defer conn.Close()
+	client := pb.NewBuildClient(conn)
+	ctx := getContext()
+
+	if iopts.sessionFile != "" {
+		if req.Session, err = systemBuildSync(ctx, client, iopts, contextDir); err != nil {
+			return err
+		}
+	} else if req.ContextId, err = build.Upload(ctx, client, contextDir, iopts.file, iopts.ignorefile); err != nil {
+		return err
+	}
+	stream, err := client.Build(ctx, req)
+	if err != nil {
+		return fmt.Errorf("starting build: %w", err)
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("build service closed the connection before the build finished")
+			}
+			return fmt.Errorf("building: %w", err)
+		}
+		switch e := event.Event.(type) {
+		case *pb.Event_Log:
+			switch {
+			case e.Log.Stream == "stderr":
+				fmt.Fprint(os.Stderr, e.Log.Text)
+			case iopts.quiet:
+			case e.Log.Stream == "stdout":
+				fmt.Fprint(os.Stdout, e.Log.Text)
+			default:
+				fmt.Fprint(os.Stderr, e.Log.Text)
+			}
+		case *pb.Event_Finished:
+			if e.Finished.Error != "" {
+				return errors.New(e.Finished.Error)
+			}
+			if iopts.iidfile != "" {
+				return os.WriteFile(iopts.iidfile, []byte("sha256:"+e.Finished.ImageId), 0o644)
+			}
+			if iopts.quiet {
+				// otherwise, it was the last thing in the
+				// build's output
+				fmt.Printf("%s\n", e.Finished.ImageId)
+			}
+			return nil
+		}
+	}
+}
+
+// systemBuildSync brings the service's copy of the build context for the
+// session whose ID is in the session file up to date, starting a new session
+// if the file doesn't exist yet or the service no longer has the session, and
+// returns the session's ID.
+func systemBuildSync(ctx context.Context, client pb.BuildClient, iopts systemBuildOptions, contextDir string) (string, error) {
+	session := ""
+	contents, err := os.ReadFile(iopts.sessionFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err == nil {
+		session = strings.TrimSpace(string(contents))
+	}
+	newSession, sent, err := build.Sync(ctx, client, session, contextDir, iopts.file, iopts.ignorefile)
+	if session != "" && status.Code(err) == codes.NotFound {
+		logrus.Debugf("service no longer has session %s, starting a new one", session)
+		newSession, sent, err = build.Sync(ctx, client, "", contextDir, iopts.file, iopts.ignorefile)
+	}
+	if err != nil {
+		return "", err
+	}
+	if newSession != session {
+		if err := os.WriteFile(iopts.sessionFile, []byte(newSession+"\n"), 0o600); err != nil {
+			return "", err
+		}
+	}
+	if !iopts.quiet {
+		fmt.Fprintf(os.Stderr, "Sent %d changed items from %q to session %s\n", len(sent), contextDir, newSession)
+	}
+	return newSession, nil
+}
+
+func systemInit() {
+	// buildah system
+	systemCommand.SetUsageTemplate(UsageTemplate())
+	rootCmd.AddCommand(systemCommand)
+
+	// buildah system service
+	systemServiceCommand.SetUsageTemplate(UsageTemplate())
+	systemCommand.AddCommand(systemServiceCommand)
+	flags := systemServiceCommand.Flags()
+	flags.StringVar(&systemServiceOpts.authfile, "authfile", auth.GetDefaultAuthFile(), "path of the authentication file used when pulling and pushing images. Use REGISTRY_AUTH_FILE environment variable to override")
+	flags.StringVar(&systemServiceOpts.contextDir, "context-dir", "", "`directory` for uploaded build contexts, whose subdirectories can also be used as build contexts")
+	flags.DurationVar(&systemServiceOpts.contextExpiry, "context-expiry", build.DefaultUploadedContextExpiry, "how long to keep an uploaded build context which no build has used")
+	flags.StringVar(&systemServiceOpts.pushDir, "push-dir", "", "`directory` under which images can be pushed using the dir, oci, oci-archive, and docker-archive transports")
+	flags.StringVarP(&systemServiceOpts.listenPath, "listen", "l", "", "listening socket `path` (default is \"buildah.sock\" under the storage run root)")
+	flags.StringVar(&systemServiceOpts.signaturePolicy, "signature-policy", "", "`pathname` of signature policy file (not usually used)")
+	if err := flags.MarkHidden("signature-policy"); err != nil {
+		panic(fmt.Sprintf("error marking signature-policy as hidden: %v", err))
+	}
+
+	// buildah system build
+	systemBuildCommand.SetUsageTemplate(UsageTemplate())
+	systemCommand.AddCommand(systemBuildCommand)
+	flags = systemBuildCommand.Flags()
+	flags.SetInterspersed(false)
+	flags.StringArrayVar(&systemBuildOpts.buildArg, "build-arg", nil, "`argument=value` to supply to the builder")
+	flags.StringVarP(&systemBuildOpts.connect, "connect", "c", "", "build service socket `path` (default is \"buildah.sock\" under the storage run root)")
+	flags.StringArrayVarP(&systemBuildOpts.file, "file", "f", nil, "`pathname` of a Containerfile, relative to the build context")
+	flags.StringVar(&systemBuildOpts.format, "format", "", "`format` of the built image's manifest and metadata (default \"oci\")")
+	flags.StringVar(&systemBuildOpts.ignorefile, "ignorefile", "", "path to an alternate .containerignore file")
+	flags.StringVar(&systemBuildOpts.iidfile, "iidfile", "", "`file` to write the image ID to")
+	flags.StringVar(&systemBuildOpts.isolation, "isolation", "", "`type` of process isolation to use (default is the service's default)")
+	flags.StringArrayVar(&systemBuildOpts.label, "label", nil, "set metadata for an image (default [])")
+	flags.BoolVar(&systemBuildOpts.layers, "layers", false, "use intermediate images which the service has cached, and cache new ones")
+	flags.BoolVar(&systemBuildOpts.noCache, "no-cache", false, "do not use existing cached images for the container build")
+	flags.StringVar(&systemBuildOpts.pull, "pull", "", "pull base and SBOM scanner images: \"missing\", \"always\", \"ifnewer\", or \"never\" (default \"missing\")")
+	flags.BoolVarP(&systemBuildOpts.quiet, "quiet", "q", false, "refrain from announcing build instructions and image read/write progress")
+	flags.StringVar(&systemBuildOpts.sessionFile, "session-file", "", "keep a copy of the build context on the service for a session whose ID is stored in `file`, and only send changes to it")
+	flags.StringArrayVarP(&systemBuildOpts.tag, "tag", "t", nil, "tagged `name` to apply to the built image")
+	flags.StringVar(&systemBuildOpts.target, "target", "", "set the target build stage to build")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"go.podman.io/buildah/pkg/parse"
+	"go.podman.io/buildah/util"
+	"go.podman.io/common/libimage"
+	"go.podman.io/image/v5/docker/reference"
+	"go.podman.io/image/v5/image"
+	is "go.podman.io/image/v5/storage"
+)
+
+type verifyResults struct {
+	policy string
+	quiet  bool
+}
+
+var (
+	verifyDescription = `
+  Checks the signatures which were stored along with a locally-stored image
+  against the requirements which a signature policy sets for images in local
+  storage.`
+	verifyOpts    verifyResults
+	verifyCommand = &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the signatures of a local image against a signature policy",
+		Long:  verifyDescription,
+		RunE:  verifyCmd,
+		Example: `buildah verify registry.example.com/myapp:latest
+  buildah verify --policy ./policy.json registry.example.com/myapp:latest`,
+		Args:    cobra.ExactArgs(1),
+		GroupID: groupImages,
+	}
+)
+
+func verifyCmd(c *cobra.Command, args []string) error {
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+	systemContext, err := parse.SystemContextFromOptions(c)
+	if err != nil {
+		return fmt.Errorf("building system context: %w", err)
+	}
+	if verifyOpts.policy != "" {
+		systemContext.SignaturePolicyPath = verifyOpts.policy
+	}
+	runtime, err := libimage.RuntimeFromStore(store, &libimage.RuntimeOptions{SystemContext: systemContext})
+	if err != nil {
+		return err
+	}
+	img, resolvedName, err := runtime.LookupImage(args[0], nil)
+	if err != nil {
+		return err
+	}
+
+	// if the image was looked up using one of its names, use that name,
+	// so that signatures which identify the image by name can be matched
+	var named reference.Named
+	if candidate, err := reference.ParseNormalizedNamed(resolvedName); err == nil {
+		candidate = reference.TagNameOnly(candidate)
+		if slices.Contains(img.Names(), candidate.String()) {
+			named = candidate
+		}
+	}
+	ref, err := is.Transport.NewStoreReference(store, named, img.ID())
+	if err != nil {
+		return fmt.Errorf("building reference to image %q: %w", img.ID(), err)
+	}
+	policyContext, err := util.GetPolicyContext(systemContext)
+	if err != nil {
+		return fmt.Errorf("obtaining signature policy: %w", err)
+	}
+	defer func() {
+		if err := policyContext.Destroy(); err != nil {
+			logrus.Debugf("destroying signature policy context: %v", err)
+		}
+	}()
+
+	ctx := getContext()
+	src, err := ref.NewImageSource(ctx, systemContext)
+	if err != nil {
+		return fmt.Errorf("reading image %q: %w", img.ID(), err)
+	}
+	defer src.Close()
+	allowed, err := policyContext.IsRunningImageAllowed(ctx, image.UnparsedInstance(src, nil))
+	if err != nil {
+		return fmt.Errorf("verifying image %q: %w", args[0], err)
+	}
+	if !allowed {
+		return fmt.Errorf("image %q is not allowed by the signature policy", args[0])
+	}
+	if !verifyOpts.quiet {
+		fmt.Printf("%s\n", img.ID())
+	}
+	return nil
+}
+
+func verifyInit() {
+	flags := verifyCommand.Flags()
+	flags.SetInterspersed(false)
+	flags.StringVar(&verifyOpts.policy, "policy", "", "`pathname` of signature policy file to use instead of the system default")
+	flags.BoolVarP(&verifyOpts.quiet, "quiet", "q", false, "don't print the ID of the image when it is verified")
+	verifyCommand.SetUsageTemplate(UsageTemplate())
+	rootCmd.AddCommand(verifyCommand)
+}
@@ -24,6 +24,7 @@ import (
 	"go.podman.io/image/v5/manifest"
 	"go.podman.io/image/v5/pkg/compression"
 	"go.podman.io/image/v5/signature"
+	"go.podman.io/image/v5/signature/signer"
 	is "go.podman.io/image/v5/storage"
 	"go.podman.io/image/v5/transports"
 	"go.podman.io/image/v5/types"
@@ -120,6 +121,16 @@ type CommitOptions struct {
 	OmitTimestamp bool
 	// SignBy is the fingerprint of a GPG key to use for signing the image.
 	SignBy string
+	// SignBySigstorePrivateKeyFile is the location of a sigstore private
+	// key to use for signing the image.
+	SignBySigstorePrivateKeyFile string
+	// SignSigstorePrivateKeyPassphrase is the passphrase for the key
+	// specified in SignBySigstorePrivateKeyFile.
+	SignSigstorePrivateKeyPassphrase []byte
+	// Signers are additional signers to use for signing the image, such as
+	// those created using sigstore parameter files.  The caller is
+	// responsible for closing them.
+	Signers []*signer.Signer
 	// Manifest list to add the image to.
 	Manifest string
 	// MaxRetries is the maximum number of attempts we'll make to commit
@@ -167,6 +178,12 @@ type CommitOptions struct {
 	// the image in Docker format.  Newer BuildKit-based builds don't set
 	// this field.
 	CompatSetParent types.OptionalBool
+	// CompatDockerConfig causes settings which Docker's image
+	// configuration format has a place for, but which the OCI format
+	// doesn't, such as Healthcheck, OnBuild, Shell, StopTimeout, and
+	// Domainname, to be recorded using their Docker names when committing
+	// the image in OCI format, as Docker does for some of them.
+	CompatDockerConfig types.OptionalBool
 	// CompatLayerOmissions causes the "/dev", "/proc", and "/sys"
 	// directories to be omitted from the layer diff and related output, as
 	// the classic builder did.  Newer BuildKit-based builds include them
@@ -504,6 +521,9 @@ func (b *Builder) CommitResults(ctx context.Context, dest types.ImageReference,
 	if options.ForceCompressionFormat {
 		copyOptions.ForceCompressionFormat = true
 	}
+	copyOptions.SignBySigstorePrivateKeyFile = options.SignBySigstorePrivateKeyFile
+	copyOptions.SignSigstorePrivateKeyPassphrase = options.SignSigstorePrivateKeyPassphrase
+	copyOptions.Signers = options.Signers
 	var manifestBytes []byte
 	if manifestBytes, err = retryCopyImage(ctx, policyContext, maybeCachedDest, maybeCachedSrc, dest, copyOptions, options.MaxRetries, options.RetryDelay); err != nil {
 		return nil, fmt.Errorf("copying layers and metadata for container %q: %w", b.ContainerID, err)
@@ -17,6 +17,7 @@ import (
 	rspec "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.podman.io/buildah/docker"
 	"go.podman.io/image/v5/manifest"
 	ociLayout "go.podman.io/image/v5/oci/layout"
 	imageStorage "go.podman.io/image/v5/storage"
@@ -575,3 +576,78 @@ func TestCommitEmpty(t *testing.T) {
 		require.Equalf(t, layerDigest.Digest(), image.RootFS.DiffIDs[len(image.RootFS.DiffIDs)-1], "expected new diff ID to match the randomly-generated layer")
 	})
 }
+
+func TestCommitCompatDockerConfig(t *testing.T) {
+	// This test cannot be parallelized as this uses NewBuilder()
+	// which eventually and indirectly accesses a global variable
+	// defined in `go-selinux`, this must be fixed at `go-selinux`
+	// or builder must enable sometime of locking mechanism i.e if
+	// routine is creating Builder other's must wait for it.
+	// Tracked here: https://github.com/containers/buildah/issues/5967
+	ctx := context.TODO()
+
+	graphDriverName := os.Getenv("STORAGE_DRIVER")
+	if graphDriverName == "" {
+		graphDriverName = "vfs"
+	}
+	t.Logf("using storage driver %q", graphDriverName)
+	store, err := storage.GetStore(storageTypes.StoreOptions{
+		RunRoot:         t.TempDir(),
+		GraphRoot:       t.TempDir(),
+		GraphDriverName: graphDriverName,
+	})
+	require.NoError(t, err, "initializing storage")
+	t.Cleanup(func() { _, err := store.Shutdown(true); assert.NoError(t, err) })
+
+	builderOptions := BuilderOptions{
+		FromImage: "scratch",
+		NamespaceOptions: []NamespaceOption{{
+			Name: string(rspec.NetworkNamespace),
+			Host: true,
+		}},
+		SystemContext: &testSystemContext,
+	}
+	b, err := NewBuilder(ctx, store, builderOptions)
+	require.NoError(t, err, "creating builder")
+	healthcheck := &docker.HealthConfig{Test: []string{"CMD", "/bin/check"}, Interval: 30 * time.Second, Retries: 3}
+	b.SetHealthcheck(healthcheck)
+	b.SetShell([]string{"/bin/bash", "-c"})
+	b.SetOnBuild("LABEL triggered=yes")
+	b.SetDomainname("example.com")
+
+	commit := func(name string, compat bool) map[string]any {
+		ref, err := imageStorage.Transport.ParseStoreReference(store, name)
+		require.NoErrorf(t, err, "parsing reference to %q", name)
+		_, _, _, err = b.Commit(ctx, ref, CommitOptions{CompatDockerConfig: types.NewOptionalBool(compat)})
+		require.NoErrorf(t, err, "committing %q", name)
+		img, err := ref.NewImage(ctx, &testSystemContext)
+		require.NoErrorf(t, err, "reading %q", name)
+		defer img.Close()
+		configBlob, err := img.ConfigBlob(ctx)
+		require.NoErrorf(t, err, "reading configuration of %q", name)
+		var config struct {
+			Config map[string]any `json:"config"`
+		}
+		require.NoErrorf(t, json.Unmarshal(configBlob, &config), "parsing configuration of %q", name)
+		return config.Config
+	}
+
+	config := commit("without", false)
+	for _, field := range []string{"Healthcheck", "Shell", "OnBuild", "Domainname"} {
+		assert.NotContainsf(t, config, field, "%q should have been discarded", field)
+	}
+
+	config = commit("with", true)
+	assert.Equal(t, []any{"/bin/bash", "-c"}, config["Shell"])
+	assert.Equal(t, []any{"LABEL triggered=yes"}, config["OnBuild"])
+	assert.Equal(t, "example.com", config["Domainname"])
+	assert.Equal(t, map[string]any{"Test": []any{"CMD", "/bin/check"}, "Interval": float64(30 * time.Second), "Retries": float64(3)}, config["Healthcheck"])
+
+	builderOptions.FromImage = "with"
+	b2, err := NewBuilder(ctx, store, builderOptions)
+	require.NoError(t, err, "creating builder from image with extended configuration")
+	assert.Equal(t, healthcheck, b2.Healthcheck())
+	assert.Equal(t, []string{"/bin/bash", "-c"}, b2.Shell())
+	assert.Equal(t, []string{"LABEL triggered=yes"}, b2.OnBuild())
+	assert.Equal(t, "example.com", b2.Domainname())
+}
@@ -98,6 +98,18 @@ func (b *Builder) initConfig(ctx context.Context, sys *types.SystemContext, img
 				}
 				maps.Copy(b.ImageAnnotations, v1Manifest.Annotations)
 			}
+			// Recover any settings which only Docker's format has a
+			// place for, if they were recorded in the configuration.
+			extended := ociImageWithDockerConfig{}
+			if err := json.Unmarshal(rawConfig, &extended); err != nil {
+				return fmt.Errorf("parsing OCI configuration %q: %w", string(rawConfig), err)
+			}
+			if extension := extended.Config.dockerConfigExtension; !extension.empty() {
+				if b.Docker.Config == nil {
+					b.Docker.Config = &docker.Config{}
+				}
+				extension.restore(b.Docker.Config)
+			}
 		}
 	} else {
 		if options == nil || options.CompatScratchConfig != types.OptionalBoolTrue {
@@ -351,10 +363,11 @@ func (b *Builder) ClearOnBuild() {
 // SetOnBuild sets a trigger instruction to be executed when the image is used
 // as the base of another image.
 // Note: this setting is not present in the OCIv1 image format, so it is
-// discarded when writing images using OCIv1 formats.
+// discarded when writing images using OCIv1 formats, unless
+// CommitOptions.CompatDockerConfig is set.
 func (b *Builder) SetOnBuild(onBuild string) {
 	if onBuild != "" && b.Format != define.Dockerv2ImageManifest {
-		b.Logger.Warnf("ONBUILD is not supported for OCI image format, %s will be ignored. Must use `docker` format", onBuild)
+		b.Logger.Warnf("ONBUILD is not supported for OCI image format, %s will be ignored. Must use `docker` format or --compat-docker-config", onBuild)
 	}
 	b.Docker.Config.OnBuild = append(b.Docker.Config.OnBuild, onBuild)
 }
@@ -383,11 +396,12 @@ func (b *Builder) Shell() []string {
 // commands in the container, or in a container built using an image built from
 // this container.
 // Note: this setting is not present in the OCIv1 image format, so it is
-// discarded when writing images using OCIv1 formats, even though it is used
-// for subsequent RUN instructions while building this image.
+// discarded when writing images using OCIv1 formats unless
+// CommitOptions.CompatDockerConfig is set, even though it is used for
+// subsequent RUN instructions while building this image.
 func (b *Builder) SetShell(shell []string) {
 	if len(shell) > 0 && b.Format != define.Dockerv2ImageManifest {
-		b.Logger.Warnf("SHELL is not persisted in the OCI image format, %s will be used for RUN subsequent instructions to build this image but will not be present in saved image which may affect any images that use this as a base. Must use `docker` format to persist in saved image", shell)
+		b.Logger.Warnf("SHELL is not persisted in the OCI image format, %s will be used for RUN subsequent instructions to build this image but will not be present in saved image which may affect any images that use this as a base. Must use `docker` format or --compat-docker-config to persist in saved image", shell)
 	}
 
 	b.Docker.Config.Shell = slices.Clone(shell)
@@ -621,10 +635,11 @@ func (b *Builder) Domainname() string {
 // SetDomainname sets the domainname which will be set in the container and in
 // containers built using images built from the container.
 // Note: this setting is not present in the OCIv1 image format, so it is
-// discarded when writing images using OCIv1 formats.
+// discarded when writing images using OCIv1 formats, unless
+// CommitOptions.CompatDockerConfig is set.
 func (b *Builder) SetDomainname(name string) {
 	if name != "" && b.Format != define.Dockerv2ImageManifest {
-		b.Logger.Warnf("DOMAINNAME is not supported for OCI image format, domainname %s will be ignored. Must use `docker` format", name)
+		b.Logger.Warnf("DOMAINNAME is not supported for OCI image format, domainname %s will be ignored. Must use `docker` format or --compat-docker-config", name)
 	}
 	b.Docker.Config.Domainname = name
 }
@@ -697,12 +712,13 @@ func (b *Builder) Healthcheck() *docker.HealthConfig {
 // specifying how often that test should be run, and how many times the test
 // should fail before the container should be considered unhealthy.
 // Note: this setting is not present in the OCIv1 image format, so it is
-// discarded when writing images using OCIv1 formats.
+// discarded when writing images using OCIv1 formats, unless
+// CommitOptions.CompatDockerConfig is set.
 func (b *Builder) SetHealthcheck(config *docker.HealthConfig) {
 	b.Docker.Config.Healthcheck = nil
 	if config != nil {
 		if b.Format != define.Dockerv2ImageManifest {
-			b.Logger.Warnf("HEALTHCHECK is not supported for OCI image format and will be ignored. Must use `docker` format")
+			b.Logger.Warnf("HEALTHCHECK is not supported for OCI image format and will be ignored. Must use `docker` format or --compat-docker-config")
 		}
 		b.Docker.Config.Healthcheck = &docker.HealthConfig{
 			Test:          slices.Clone(config.Test),
@@ -0,0 +1,577 @@
+package buildah
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.podman.io/buildah/docker"
+	"sigs.k8s.io/yaml"
+)
+
+// ConfigPatchDocument is the form of a container's configuration which
+// ApplyConfigPatch applies patches to.  Every field is always present in its
+// JSON encoding, so that RFC 6902 patches can refer to any of them.
+type ConfigPatchDocument struct {
+	Env            map[string]string       `json:"env"`
+	Labels         map[string]string       `json:"labels"`
+	Annotations    map[string]string       `json:"annotations"`
+	Ports          []string                `json:"ports"`
+	Volumes        []string                `json:"volumes"`
+	Entrypoint     []string                `json:"entrypoint"`
+	Cmd            []string                `json:"cmd"`
+	User           string                  `json:"user"`
+	Healthcheck    *ConfigPatchHealthcheck `json:"healthcheck"`
+	OSFeatures     []string                `json:"osFeatures"`
+	HistoryComment string                  `json:"historyComment"`
+}
+
+// ConfigPatchHealthcheck is the form of a healthcheck in a
+// ConfigPatchDocument.  Durations are expressed as strings which can be
+// parsed by time.ParseDuration.
+type ConfigPatchHealthcheck struct {
+	Test          []string `json:"test"`
+	Interval      string   `json:"interval"`
+	Timeout       string   `json:"timeout"`
+	StartPeriod   string   `json:"startPeriod"`
+	StartInterval string   `json:"startInterval"`
+	Retries       int      `json:"retries"`
+}
+
+// ConfigPatchDocument returns the parts of the container's configuration which
+// can be modified using ApplyConfigPatch.
+func (b *Builder) ConfigPatchDocument() *ConfigPatchDocument {
+	doc := &ConfigPatchDocument{
+		Env:            map[string]string{},
+		Labels:         b.Labels(),
+		Annotations:    b.Annotations(),
+		Ports:          b.Ports(),
+		Volumes:        b.Volumes(),
+		Entrypoint:     b.Entrypoint(),
+		Cmd:            b.Cmd(),
+		User:           b.User(),
+		OSFeatures:     b.OSFeatures(),
+		HistoryComment: b.HistoryComment(),
+	}
+	for _, kv := range b.Env() {
+		k, v, _ := strings.Cut(kv, "=")
+		doc.Env[k] = v
+	}
+	if doc.Labels == nil {
+		doc.Labels = map[string]string{}
+	}
+	if doc.Annotations == nil {
+		doc.Annotations = map[string]string{}
+	}
+	for _, list := range []*[]string{&doc.Ports, &doc.Volumes, &doc.OSFeatures} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+	slices.Sort(doc.Ports)
+	slices.Sort(doc.Volumes)
+	if hc := b.Healthcheck(); hc != nil {
+		doc.Healthcheck = &ConfigPatchHealthcheck{
+			Test:          hc.Test,
+			Interval:      durationString(hc.Interval),
+			Timeout:       durationString(hc.Timeout),
+			StartPeriod:   durationString(hc.StartPeriod),
+			StartInterval: durationString(hc.StartInterval),
+			Retries:       hc.Retries,
+		}
+	}
+	return doc
+}
+
+func durationString(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// ApplyConfigPatch modifies the container's configuration using a JSON or
+// YAML document.  If the document is an object, it is treated as an RFC 7386
+// merge patch: fields which it mentions replace the corresponding fields in
+// the container's ConfigPatchDocument, maps are merged, and null values remove
+// items.  If the document is a list, it is treated as an RFC 6902 JSON patch
+// and applied to the container's ConfigPatchDocument.  The result is
+// validated before any changes are made, and then applied using the same
+// setters that "buildah config" uses.
+func (b *Builder) ApplyConfigPatch(patch []byte) error {
+	patchJSON, err := yaml.YAMLToJSON(patch)
+	if err != nil {
+		return fmt.Errorf("parsing configuration patch: %w", err)
+	}
+	current := b.ConfigPatchDocument()
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return fmt.Errorf("encoding current configuration: %w", err)
+	}
+	var doc, ops any
+	if err := json.Unmarshal(currentJSON, &doc); err != nil {
+		return fmt.Errorf("decoding current configuration: %w", err)
+	}
+	if err := json.Unmarshal(patchJSON, &ops); err != nil {
+		return fmt.Errorf("parsing configuration patch: %w", err)
+	}
+	switch p := ops.(type) {
+	case map[string]any:
+		doc = mergePatch(doc, p)
+	case []any:
+		if doc, err = jsonPatch(doc, p); err != nil {
+			return fmt.Errorf("applying configuration patch: %w", err)
+		}
+	default:
+		return errors.New("parsing configuration patch: expected an object or a list of operations")
+	}
+	updatedJSON, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("encoding patched configuration: %w", err)
+	}
+	var updated ConfigPatchDocument
+	decoder := json.NewDecoder(bytes.NewReader(updatedJSON))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&updated); err != nil {
+		return fmt.Errorf("decoding patched configuration: %w", err)
+	}
+	healthcheck, err := updated.validate()
+	if err != nil {
+		return fmt.Errorf("validating patched configuration: %w", err)
+	}
+	b.applyConfigPatchDocument(current, &updated, healthcheck)
+	return nil
+}
+
+// validate checks that the document's contents would be accepted by the
+// Builder's setters, and returns the healthcheck which it describes.
+func (d *ConfigPatchDocument) validate() (*docker.HealthConfig, error) {
+	for k := range d.Env {
+		if k == "" || strings.Contains(k, "=") {
+			return nil, fmt.Errorf("invalid environment variable name %q", k)
+		}
+	}
+	for k := range d.Labels {
+		if k == "" {
+			return nil, errors.New("empty label name")
+		}
+	}
+	for k := range d.Annotations {
+		if k == "" {
+			return nil, errors.New("empty annotation name")
+		}
+	}
+	for _, p := range d.Ports {
+		port, proto, _ := strings.Cut(p, "/")
+		if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid port %q", p)
+		}
+		if proto != "" && proto != "tcp" && proto != "udp" && proto != "sctp" {
+			return nil, fmt.Errorf("invalid protocol in port %q", p)
+		}
+	}
+	for _, v := range d.Volumes {
+		if !strings.HasPrefix(v, "/") {
+			return nil, fmt.Errorf("volume %q is not an absolute path", v)
+		}
+	}
+	for _, f := range d.OSFeatures {
+		if f == "" {
+			return nil, errors.New("empty OS feature")
+		}
+	}
+	if d.Healthcheck == nil {
+		return nil, nil
+	}
+	hc := &docker.HealthConfig{
+		Test:    d.Healthcheck.Test,
+		Retries: d.Healthcheck.Retries,
+	}
+	if len(hc.Test) == 0 {
+		return nil, errors.New("healthcheck has no test")
+	}
+	switch hc.Test[0] {
+	case "NONE":
+		if len(hc.Test) != 1 {
+			return nil, errors.New(`healthcheck test "NONE" takes no arguments`)
+		}
+	case "CMD", "CMD-SHELL":
+		if len(hc.Test) == 1 {
+			return nil, fmt.Errorf("healthcheck test %q requires a command", hc.Test[0])
+		}
+	default:
+		return nil, fmt.Errorf(`healthcheck test must start with "NONE", "CMD", or "CMD-SHELL", not %q`, hc.Test[0])
+	}
+	if hc.Retries < 0 {
+		return nil, fmt.Errorf("invalid healthcheck retries %d", hc.Retries)
+	}
+	for _, duration := range []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"interval", d.Healthcheck.Interval, &hc.Interval},
+		{"timeout", d.Healthcheck.Timeout, &hc.Timeout},
+		{"startPeriod", d.Healthcheck.StartPeriod, &hc.StartPeriod},
+		{"startInterval", d.Healthcheck.StartInterval, &hc.StartInterval},
+	} {
+		if duration.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(duration.value)
+		if err != nil {
+			return nil, fmt.Errorf("parsing healthcheck %s: %w", duration.name, err)
+		}
+		if parsed < 0 {
+			return nil, fmt.Errorf("invalid healthcheck %s %q", duration.name, duration.value)
+		}
+		*duration.dest = parsed
+	}
+	return hc, nil
+}
+
+// applyConfigPatchDocument calls setters for the parts of updated which differ
+// from current.
+func (b *Builder) applyConfigPatchDocument(current, updated *ConfigPatchDocument, healthcheck *docker.HealthConfig) {
+	for _, k := range slices.Sorted(maps.Keys(current.Env)) {
+		if _, ok := updated.Env[k]; !ok {
+			b.UnsetEnv(k)
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(updated.Env)) {
+		if v, ok := current.Env[k]; !ok || v != updated.Env[k] {
+			b.SetEnv(k, updated.Env[k])
+		}
+	}
+	for k := range current.Labels {
+		if _, ok := updated.Labels[k]; !ok {
+			b.UnsetLabel(k)
+		}
+	}
+	for k, v := range updated.Labels {
+		if old, ok := current.Labels[k]; !ok || old != v {
+			b.SetLabel(k, v)
+		}
+	}
+	for k := range current.Annotations {
+		if _, ok := updated.Annotations[k]; !ok {
+			b.UnsetAnnotation(k)
+		}
+	}
+	for k, v := range updated.Annotations {
+		if old, ok := current.Annotations[k]; !ok || old != v {
+			b.SetAnnotation(k, v)
+		}
+	}
+	for _, p := range current.Ports {
+		if !slices.Contains(updated.Ports, p) {
+			b.UnsetPort(p)
+		}
+	}
+	for _, p := range updated.Ports {
+		b.SetPort(p)
+	}
+	for _, v := range current.Volumes {
+		if !slices.Contains(updated.Volumes, v) {
+			b.RemoveVolume(v)
+		}
+	}
+	for _, v := range updated.Volumes {
+		b.AddVolume(v)
+	}
+	for _, f := range current.OSFeatures {
+		if !slices.Contains(updated.OSFeatures, f) {
+			b.UnsetOSFeature(f)
+		}
+	}
+	for _, f := range updated.OSFeatures {
+		b.SetOSFeature(f)
+	}
+	if !slices.Equal(current.Entrypoint, updated.Entrypoint) {
+		b.SetEntrypoint(updated.Entrypoint)
+	}
+	if !slices.Equal(current.Cmd, updated.Cmd) {
+		b.SetCmd(updated.Cmd)
+	}
+	if current.User != updated.User {
+		b.SetUser(updated.User)
+	}
+	if current.HistoryComment != updated.HistoryComment {
+		b.SetHistoryComment(updated.HistoryComment)
+	}
+	if !equalConfigPatchHealthchecks(current.Healthcheck, updated.Healthcheck) {
+		b.SetHealthcheck(healthcheck)
+	}
+}
+
+func equalConfigPatchHealthchecks(a, b *ConfigPatchHealthcheck) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return slices.Equal(a.Test, b.Test) && a.Interval == b.Interval && a.Timeout == b.Timeout &&
+		a.StartPeriod == b.StartPeriod && a.StartInterval == b.StartInterval && a.Retries == b.Retries
+}
+
+// mergePatch applies an RFC 7386 merge patch to target.
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	merged, ok := target.(map[string]any)
+	if !ok {
+		merged = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = mergePatch(merged[k], v)
+	}
+	return merged
+}
+
+// jsonPatch applies a list of RFC 6902 operations to doc.
+func jsonPatch(doc any, ops []any) (any, error) {
+	var err error
+	for i, o := range ops {
+		op, ok := o.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("operation %d is not an object", i)
+		}
+		name, _ := op["op"].(string)
+		path, ok := op["path"].(string)
+		if !ok {
+			return nil, fmt.Errorf("operation %d (%q) has no path", i, name)
+		}
+		value, hasValue := op["value"]
+		from, hasFrom := op["from"].(string)
+		switch name {
+		case "add", "replace", "test":
+			if !hasValue {
+				return nil, fmt.Errorf("operation %d (%q) has no value", i, name)
+			}
+		case "move", "copy":
+			if !hasFrom {
+				return nil, fmt.Errorf("operation %d (%q) has no from", i, name)
+			}
+		}
+		switch name {
+		case "add":
+			doc, err = jsonPointerAdd(doc, path, value)
+		case "remove":
+			doc, _, err = jsonPointerRemove(doc, path)
+		case "replace":
+			if doc, _, err = jsonPointerRemove(doc, path); err == nil {
+				doc, err = jsonPointerAdd(doc, path, value)
+			}
+		case "move":
+			if strings.HasPrefix(path, from+"/") {
+				return nil, fmt.Errorf("operation %d (%q) moves %q into itself", i, name, from)
+			}
+			var moved any
+			if doc, moved, err = jsonPointerRemove(doc, from); err == nil {
+				doc, err = jsonPointerAdd(doc, path, moved)
+			}
+		case "copy":
+			var copied any
+			if copied, err = jsonPointerGet(doc, from); err == nil {
+				doc, err = jsonPointerAdd(doc, path, deepCopyJSON(copied))
+			}
+		case "test":
+			var actual any
+			if actual, err = jsonPointerGet(doc, path); err == nil {
+				a, _ := json.Marshal(actual)
+				v, _ := json.Marshal(value)
+				if !bytes.Equal(a, v) {
+					err = fmt.Errorf("value at %q is %s, not %s", path, a, v)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("operation %d has unrecognized op %q", i, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%q): %w", i, name, err)
+		}
+	}
+	return doc, nil
+}
+
+// splitJSONPointer breaks an RFC 6901 JSON pointer into its unescaped
+// reference tokens.
+func splitJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(tokens[i], "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// jsonArrayIndex parses token as an index into a list of length n.  If
+// appending is true, "-" and n are accepted as referring to the end of the
+// list.
+func jsonArrayIndex(token string, n int, appending bool) (int, error) {
+	if token == "-" && appending {
+		return n, nil
+	}
+	if token == "" || strings.Trim(token, "0123456789") != "" || (token != "0" && strings.HasPrefix(token, "0")) {
+		return -1, fmt.Errorf("invalid list index %q", token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil {
+		return -1, fmt.Errorf("invalid list index %q", token)
+	}
+	if index > n || (index == n && !appending) {
+		return -1, fmt.Errorf("list index %d out of range", index)
+	}
+	return index, nil
+}
+
+func jsonPointerGet(doc any, pointer string) (any, error) {
+	tokens, err := splitJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		switch d := doc.(type) {
+		case map[string]any:
+			v, ok := d[token]
+			if !ok {
+				return nil, fmt.Errorf("%q not found", pointer)
+			}
+			doc = v
+		case []any:
+			index, err := jsonArrayIndex(token, len(d), false)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", pointer, err)
+			}
+			doc = d[index]
+		default:
+			return nil, fmt.Errorf("%q not found", pointer)
+		}
+	}
+	return doc, nil
+}
+
+// jsonPointerUpdate locates the container of the value that pointer refers to
+// and replaces it with the result of calling update on it, returning the
+// updated document.  The pointer must not refer to the whole document.
+func jsonPointerUpdate(doc any, pointer string, update func(parent any, token string) (any, error)) (any, error) {
+	tokens, err := splitJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	var walk func(node any, tokens []string) (any, error)
+	walk = func(node any, tokens []string) (any, error) {
+		if len(tokens) == 1 {
+			return update(node, tokens[0])
+		}
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok := n[tokens[0]]
+			if !ok {
+				return nil, fmt.Errorf("%q not found", pointer)
+			}
+			updated, err := walk(child, tokens[1:])
+			if err != nil {
+				return nil, err
+			}
+			n[tokens[0]] = updated
+			return n, nil
+		case []any:
+			index, err := jsonArrayIndex(tokens[0], len(n), false)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", pointer, err)
+			}
+			updated, err := walk(n[index], tokens[1:])
+			if err != nil {
+				return nil, err
+			}
+			n[index] = updated
+			return n, nil
+		}
+		return nil, fmt.Errorf("%q not found", pointer)
+	}
+	return walk(doc, tokens)
+}
+
+func jsonPointerAdd(doc any, pointer string, value any) (any, error) {
+	if pointer == "" {
+		// replace the whole document
+		return value, nil
+	}
+	return jsonPointerUpdate(doc, pointer, func(parent any, token string) (any, error) {
+		switch p := parent.(type) {
+		case map[string]any:
+			p[token] = value
+			return p, nil
+		case []any:
+			index, err := jsonArrayIndex(token, len(p), true)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", pointer, err)
+			}
+			return slices.Insert(p, index, value), nil
+		}
+		return nil, fmt.Errorf("parent of %q not found", pointer)
+	})
+}
+
+func jsonPointerRemove(doc any, pointer string) (any, any, error) {
+	if pointer == "" {
+		// remove the whole document
+		return nil, doc, nil
+	}
+	var removed any
+	doc, err := jsonPointerUpdate(doc, pointer, func(parent any, token string) (any, error) {
+		switch p := parent.(type) {
+		case map[string]any:
+			v, ok := p[token]
+			if !ok {
+				return nil, fmt.Errorf("%q not found", pointer)
+			}
+			removed = v
+			delete(p, token)
+			return p, nil
+		case []any:
+			index, err := jsonArrayIndex(token, len(p), false)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", pointer, err)
+			}
+			removed = p[index]
+			return slices.Delete(p, index, index+1), nil
+		}
+		return nil, fmt.Errorf("%q not found", pointer)
+	})
+	return doc, removed, err
+}
+
+func deepCopyJSON(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(t))
+		for k, v := range t {
+			c[k] = deepCopyJSON(v)
+		}
+		return c
+	case []any:
+		c := make([]any, len(t))
+		for i, v := range t {
+			c[i] = deepCopyJSON(v)
+		}
+		return c
+	}
+	return v
+}
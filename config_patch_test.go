@@ -0,0 +1,195 @@
+package buildah
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/buildah/define"
+	"go.podman.io/buildah/docker"
+)
+
+func newConfigPatchTestBuilder() *Builder {
+	b := &Builder{Format: define.Dockerv2ImageManifest}
+	b.Docker.Config = &docker.Config{}
+	b.SetEnv("PATH", "/usr/bin")
+	b.SetEnv("OLD", "1")
+	b.SetLabel("keep", "yes")
+	b.SetLabel("drop", "yes")
+	b.SetPort("80/tcp")
+	b.SetCmd([]string{"/bin/sh"})
+	return b
+}
+
+func TestApplyConfigPatchMerge(t *testing.T) {
+	b := newConfigPatchTestBuilder()
+	patch := `
+env:
+  OLD: null
+  NEW: value
+labels:
+  drop: null
+  added: "true"
+annotations:
+  org.opencontainers.image.source: https://example.com/repo
+ports: ["8080/tcp", "53/udp"]
+volumes: [/data]
+entrypoint: [/entrypoint.sh]
+cmd: null
+user: "1000:1000"
+healthcheck:
+  test: [CMD-SHELL, "curl -f http://localhost/"]
+  interval: 30s
+  retries: 3
+osFeatures: [win32k]
+historyComment: patched
+`
+	require.NoError(t, b.ApplyConfigPatch([]byte(patch)))
+	assert.Equal(t, []string{"PATH=/usr/bin", "NEW=value"}, b.Env())
+	assert.Equal(t, map[string]string{"keep": "yes", "added": "true"}, b.Labels())
+	assert.Equal(t, map[string]string{"org.opencontainers.image.source": "https://example.com/repo"}, b.Annotations())
+	assert.ElementsMatch(t, []string{"8080/tcp", "53/udp"}, b.Ports())
+	assert.Equal(t, []string{"/data"}, b.Volumes())
+	assert.Equal(t, []string{"/entrypoint.sh"}, b.Entrypoint())
+	assert.Empty(t, b.Cmd())
+	assert.Equal(t, "1000:1000", b.User())
+	assert.Equal(t, &docker.HealthConfig{
+		Test:     []string{"CMD-SHELL", "curl -f http://localhost/"},
+		Interval: 30 * time.Second,
+		Retries:  3,
+	}, b.Healthcheck())
+	assert.Equal(t, []string{"win32k"}, b.OSFeatures())
+	assert.Equal(t, "patched", b.HistoryComment())
+	assert.Equal(t, b.OCIv1.Config.Env, b.Docker.Config.Env)
+
+	require.NoError(t, b.ApplyConfigPatch([]byte(`{"healthcheck": null}`)))
+	assert.Nil(t, b.Healthcheck())
+}
+
+func TestApplyConfigPatchJSONPatch(t *testing.T) {
+	b := newConfigPatchTestBuilder()
+	patch := `[
+	{"op": "test", "path": "/env/OLD", "value": "1"},
+	{"op": "move", "from": "/env/OLD", "path": "/env/NEW"},
+	{"op": "add", "path": "/labels/a~1b", "value": "slash"},
+	{"op": "remove", "path": "/labels/drop"},
+	{"op": "add", "path": "/ports/-", "value": "443/tcp"},
+	{"op": "add", "path": "/cmd/0", "value": "exec"},
+	{"op": "copy", "from": "/cmd", "path": "/entrypoint"},
+	{"op": "replace", "path": "/user", "value": "nobody"}
+]`
+	require.NoError(t, b.ApplyConfigPatch([]byte(patch)))
+	assert.Equal(t, []string{"PATH=/usr/bin", "NEW=1"}, b.Env())
+	assert.Equal(t, map[string]string{"keep": "yes", "a/b": "slash"}, b.Labels())
+	assert.ElementsMatch(t, []string{"80/tcp", "443/tcp"}, b.Ports())
+	assert.Equal(t, []string{"exec", "/bin/sh"}, b.Cmd())
+	assert.Equal(t, []string{"exec", "/bin/sh"}, b.Entrypoint())
+	assert.Equal(t, "nobody", b.User())
+}
+
+func TestApplyConfigPatchErrors(t *testing.T) {
+	for _, patch := range []string{
+		`{"unknown": true}`,
+		`{"ports": ["http"]}`,
+		`{"ports": ["80/ftp"]}`,
+		`{"volumes": ["relative"]}`,
+		`{"env": {"A=B": "c"}}`,
+		`{"healthcheck": {"test": ["RUN", "true"]}}`,
+		`{"healthcheck": {"test": ["CMD", "true"], "interval": "soon"}}`,
+		`{"user": ["root"]}`,
+		`[{"op": "test", "path": "/user", "value": "root"}]`,
+		`[{"op": "remove", "path": "/env/MISSING"}]`,
+		`[{"op": "replace", "path": "", "value": []}]`,
+		`[{"op": "add", "path": "/cmd/5", "value": "x"}]`,
+		`[{"op": "frobnicate", "path": "/user"}]`,
+		`"just a string"`,
+	} {
+		t.Run(patch, func(t *testing.T) {
+			b := newConfigPatchTestBuilder()
+			before := b.ConfigPatchDocument()
+			assert.Error(t, b.ApplyConfigPatch([]byte(patch)))
+			assert.Equal(t, before, b.ConfigPatchDocument(), "configuration was modified by a rejected patch")
+		})
+	}
+}
+
+// The examples from RFC 7386, Appendix A.
+func TestMergePatchRFC7386(t *testing.T) {
+	for _, tc := range []struct {
+		target, patch, result string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	} {
+		t.Run(tc.target+" "+tc.patch, func(t *testing.T) {
+			var target, patch any
+			require.NoError(t, json.Unmarshal([]byte(tc.target), &target))
+			require.NoError(t, json.Unmarshal([]byte(tc.patch), &patch))
+			result, err := json.Marshal(mergePatch(target, patch))
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.result, string(result))
+		})
+	}
+}
+
+// The examples from RFC 6902, Appendix A, and some invalid RFC 6901 pointers.
+func TestJSONPatchRFC6902(t *testing.T) {
+	for _, tc := range []struct {
+		name, doc, patch, result string
+	}{
+		{"A.1", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"A.2", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"A.3", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"A.4", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"A.5", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"A.6", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"A.7", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"A.8", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{"A.9", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``},
+		{"A.10", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{"A.11", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`, `{"foo":"bar","baz":"qux"}`},
+		{"A.12", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``},
+		{"A.13", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","op":"remove"}]`, ``},
+		{"A.14", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+		{"A.15", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":"10"}]`, ``},
+		{"A.16", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{"whole document", `{"foo":"bar"}`, `[{"op":"replace","path":"","value":{"baz":"qux"}}]`, `{"baz":"qux"}`},
+		{"move into itself", `{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, ``},
+		{"leading zero", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/01","value":"qux"}]`, ``},
+		{"plus sign", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/+1","value":"qux"}]`, ``},
+		{"negative zero", `{"foo":["bar","baz"]}`, `[{"op":"remove","path":"/foo/-0"}]`, ``},
+		{"past the end", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/3","value":"qux"}]`, ``},
+		{"no leading slash", `{"foo":"bar"}`, `[{"op":"remove","path":"foo"}]`, ``},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var doc any
+			var ops []any
+			require.NoError(t, json.Unmarshal([]byte(tc.doc), &doc))
+			require.NoError(t, json.Unmarshal([]byte(tc.patch), &ops))
+			patched, err := jsonPatch(doc, ops)
+			if tc.result == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			result, err := json.Marshal(patched)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.result, string(result))
+		})
+	}
+}
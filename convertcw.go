@@ -46,6 +46,12 @@ type CWConvertImageOptions struct {
 	BaseImage                string
 	Logger                   *logrus.Logger
 	ExtraImageContent        map[string]string
+	Filesystem               string // "ext4" (default), "xfs", or "erofs"
+	Encryption               string // "luks1" (default), "luks2", or "none"
+	Cipher                   string // LUKS cipher, e.g., "aes-xts-plain64"
+	SectorSize               int    // LUKS2 payload sector size
+	Verity                   bool   // add a dm-verity hash tree to the disk image
+	DiskEncryptionKeyFile    string // use the contents of this file as the passphrase
 
 	// Passed through to BuilderOptions. Most settings won't make
 	// sense to be made available here because we don't launch a process.
@@ -174,6 +180,12 @@ func CWConvertImage(ctx context.Context, systemContext *types.SystemContext, sto
 		Logger:                   logger,
 		GraphOptions:             store.GraphOptions(),
 		ExtraImageContent:        options.ExtraImageContent,
+		Filesystem:               options.Filesystem,
+		Encryption:               options.Encryption,
+		Cipher:                   options.Cipher,
+		SectorSize:               options.SectorSize,
+		Verity:                   options.Verity,
+		DiskEncryptionKeyFile:    options.DiskEncryptionKeyFile,
 	}
 	rc, workloadConfig, err := mkcw.Archive(sourceDir, &source.OCIv1, archiveOptions)
 	if err != nil {
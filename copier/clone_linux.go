@@ -0,0 +1,109 @@
+package copier
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneSourcesReadable returns true if openCloneSource() can work, so that
+// Get() can note sources for Put() to read.
+var cloneSourcesReadable = sync.OnceValue(func() bool {
+	fd, err := unix.Openat2(unix.AT_FDCWD, "/", &unix.OpenHow{Flags: unix.O_PATH | unix.O_CLOEXEC})
+	if err != nil {
+		return false
+	}
+	unix.Close(fd)
+	return true
+})
+
+// openCloneSource opens a regular file at a location relative to dir, which
+// was noted by Get(), without following symbolic links out of dir.
+func openCloneSource(dir *os.File, rel string) (*os.File, error) {
+	fd, err := unix.Openat2(int(dir.Fd()), rel, &unix.OpenHow{
+		Flags:   unix.O_RDONLY | unix.O_CLOEXEC | unix.O_NOCTTY | unix.O_NONBLOCK,
+		Resolve: unix.RESOLVE_IN_ROOT | unix.RESOLVE_NO_MAGICLINKS,
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "openat2", Path: rel, Err: err}
+	}
+	f := os.NewFile(uintptr(fd), rel)
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "fstat", Path: rel, Err: err}
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFREG {
+		f.Close()
+		return nil, fmt.Errorf("%q is not a regular file", rel)
+	}
+	return f, nil
+}
+
+// cloneFile makes dest, which should be empty, share source's contents.
+func cloneFile(dest, source *os.File) error {
+	return unix.IoctlFileClone(int(dest.Fd()), int(source.Fd()))
+}
+
+// cloneUnsupported returns true if err indicates that cloning or linking will
+// never work between the source and destination directories, so that we
+// shouldn't bother trying again.  EXDEV isn't included, since a file which
+// is on a different filesystem may be on a mount under the source directory.
+func cloneUnsupported(err error) bool {
+	return errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOTTY) || errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EINVAL)
+}
+
+// cloneSourceMatches returns true if source, a regular file, already has
+// exactly the size, ownership, and permissions that hdr calls for, and has no
+// extended attributes, so that hard linking to it would produce the same
+// result as writing a new file.  Archive headers which don't use the PAX
+// format only have room for whole seconds, so a modification time which
+// rounds to the one in hdr is accepted, and the link keeps the more precise
+// value.
+func cloneSourceMatches(source *os.File, hdr *tar.Header) bool {
+	var st unix.Stat_t
+	if err := unix.Fstat(int(source.Fd()), &st); err != nil {
+		return false
+	}
+	if st.Size != hdr.Size || int(st.Uid) != hdr.Uid || int(st.Gid) != hdr.Gid {
+		return false
+	}
+	if int64(st.Mode&0o7777) != hdr.Mode&0o7777 {
+		return false
+	}
+	mtime := time.Unix(st.Mtim.Sec, st.Mtim.Nsec)
+	if !mtime.Equal(hdr.ModTime) && (hdr.ModTime.Nanosecond() != 0 || !mtime.Round(time.Second).Equal(hdr.ModTime)) {
+		return false
+	}
+	if size, err := unix.Flistxattr(int(source.Fd()), nil); err != nil || size != 0 {
+		return false
+	}
+	return true
+}
+
+// linkCloneSource creates a hard link at path to source, which was opened from
+// rel under dir, and double-checks that the new link refers to source.
+func linkCloneSource(dir *os.File, rel string, source *os.File, path string) error {
+	if err := unix.Linkat(int(dir.Fd()), rel, unix.AT_FDCWD, path, 0); err != nil {
+		return &os.LinkError{Op: "linkat", Old: rel, New: path, Err: err}
+	}
+	var linked, opened unix.Stat_t
+	if err := unix.Lstat(path, &linked); err != nil {
+		return &os.PathError{Op: "lstat", Path: path, Err: err}
+	}
+	if err := unix.Fstat(int(source.Fd()), &opened); err != nil {
+		return &os.PathError{Op: "fstat", Path: rel, Err: err}
+	}
+	if linked.Dev != opened.Dev || linked.Ino != opened.Ino {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		return fmt.Errorf("%q changed while it was being linked to %q", rel, path)
+	}
+	return nil
+}
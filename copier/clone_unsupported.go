@@ -0,0 +1,33 @@
+//go:build !linux
+
+package copier
+
+import (
+	"archive/tar"
+	"errors"
+	"os"
+)
+
+func cloneSourcesReadable() bool {
+	return false
+}
+
+func openCloneSource(_ *os.File, _ string) (*os.File, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func cloneFile(_, _ *os.File) error {
+	return errors.ErrUnsupported
+}
+
+func cloneUnsupported(_ error) bool {
+	return true
+}
+
+func cloneSourceMatches(_ *os.File, _ *tar.Header) bool {
+	return false
+}
+
+func linkCloneSource(_ *os.File, _ string, _ *os.File, _ string) error {
+	return errors.ErrUnsupported
+}
@@ -166,6 +166,7 @@ type request struct {
 	StatOptions              StatOptions
 	GetOptions               GetOptions
 	PutOptions               PutOptions
+	cloneFrom                *os.File // opened PutOptions.CloneFrom, used by put
 	MkdirOptions             MkdirOptions
 	RemoveOptions            RemoveOptions
 	EnsureOptions            EnsureOptions
@@ -403,8 +404,26 @@ type GetOptions struct {
 	Timestamp          *time.Time        // timestamp to force on all contents
 	DisallowWildcard   bool              // reject glob patterns in source paths
 	AllowEmptyWildcard bool              // don't error when glob patterns match nothing
+	NoteSources        bool              // note the location of each regular file, relative to root, in the archive, for PutOptions.CloneFrom, if Put() will be able to read them from there
+	Parallel           int               // number of workers to use for reading the contents of directory trees; values less than 2 mean none
+	ClampTimestamp     bool              // only apply Timestamp to contents which are newer than it
+	DropPatterns       []string          // leave out items whose names match these patterns, including the contents of archives being extracted
+	UnixLineEndings    []string          // convert CRLF line endings to LF in regular files whose names match these patterns, including the contents of archives being extracted
+	sourceRoot         string
 }
 
+// CloneSourcePAXRecord is the name of the PAX record in which Get() notes the
+// location of a regular file, relative to its root directory, when
+// GetOptions.NoteSources is set.
+const CloneSourcePAXRecord = "BUILDAH.clone-source"
+
+// CloneSizePAXRecord is the name of the PAX record which holds the size of a
+// regular file whose location was noted in a CloneSourcePAXRecord, if its
+// contents were then left out of the archive, leaving a size of 0 in its
+// header.  Put() reads the contents of such a file from PutOptions.CloneFrom,
+// cloning them if it can, and fails if it can't read them from there.
+const CloneSizePAXRecord = "BUILDAH.clone-size"
+
 // Get produces an archive containing items that match the specified glob
 // patterns and writes it to bulkWriter.
 // If root and directory are both not specified, the current root directory is
@@ -458,6 +477,9 @@ type PutOptions struct {
 	NoOverwriteDirNonDir bool              // instead of quietly overwriting directories with non-directories, return an error
 	NoOverwriteNonDirDir bool              // instead of quietly overwriting non-directories with directories, return an error
 	Rename               map[string]string // rename items with the specified names, or under the specified names
+	CloneFrom            string            // root directory of a Get() with NoteSources set: try to reflink files from there instead of writing them out, and read the contents of files which were left out of the archive from there
+	HardlinkClones       bool              // hard link to files under CloneFrom whose ownership, permissions, and timestamps (to the second, unless the archive carries more precise ones) already match, if they will never be modified
+	Parallel             int               // number of workers to use for writing the contents of small files; values less than 2 mean none
 }
 
 // Put extracts an archive from the bulkReader at the specified directory.
@@ -693,7 +715,25 @@ func copier(bulkReader io.Reader, bulkWriter io.Writer, req request) (*response,
 	return copierWithoutSubprocess(bulkReader, bulkWriter, req)
 }
 
+// openCloneFrom opens the directory named by PutOptions.CloneFrom, if one was
+// specified, and clears the setting if it can't be opened.
+func openCloneFrom(req *request) *os.File {
+	if req.Request != requestPut || req.PutOptions.CloneFrom == "" {
+		return nil
+	}
+	dir, err := os.Open(req.PutOptions.CloneFrom)
+	if err != nil {
+		logrus.Debugf("not cloning files: %v", err)
+		req.PutOptions.CloneFrom = ""
+		return nil
+	}
+	return dir
+}
+
 func copierWithoutSubprocess(bulkReader io.Reader, bulkWriter io.Writer, req request) (*response, error) {
+	if req.cloneFrom = openCloneFrom(&req); req.cloneFrom != nil {
+		defer req.cloneFrom.Close()
+	}
 	req.preservedRoot = req.Root
 	req.rootPrefix = string(os.PathSeparator)
 	req.preservedDirectory = req.Directory
@@ -779,6 +819,10 @@ func copierWithSubprocess(bulkReader io.Reader, bulkWriter io.Writer, req reques
 	cmd.Stdout = stdoutWrite
 	cmd.Stderr = &errorBuffer
 	cmd.ExtraFiles = []*os.File{bulkReaderRead, bulkWriterWrite}
+	if cloneFrom := openCloneFrom(&req); cloneFrom != nil {
+		defer cloneFrom.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, cloneFrom)
+	}
 	if err = cmd.Start(); err != nil {
 		return nil, fmt.Errorf("starting subprocess: %w", err)
 	}
@@ -916,6 +960,11 @@ func copierMain() {
 			// same process for Get() or Put().  Maybe later.
 			break
 		}
+		if req.Request == requestPut && req.PutOptions.CloneFrom != "" {
+			// The parent opened it for us, since we're about to
+			// chroot away from it.
+			req.cloneFrom = os.NewFile(5, "clone-from")
+		}
 
 		// Multiple requests should list the same root, because we
 		// can't un-chroot to chroot to some other location.
@@ -1367,6 +1416,17 @@ func copierHandlerGet(bulkWriter io.Writer, req request, pm *fileutils.PatternMa
 	if len(req.Globs) == 0 {
 		return errorResponse("copier: get: expected at least one glob pattern, got 0")
 	}
+	if err := checkPatterns(req.GetOptions.DropPatterns); err != nil {
+		return errorResponse("copier: get: invalid pattern for items to drop: %v", err)
+	}
+	if err := checkPatterns(req.GetOptions.UnixLineEndings); err != nil {
+		return errorResponse("copier: get: invalid pattern for files to convert: %v", err)
+	}
+	if req.GetOptions.NoteSources && cloneSourcesReadable() {
+		req.GetOptions.sourceRoot = req.Root
+	} else {
+		req.GetOptions.NoteSources = false
+	}
 	// build a queue of items by globbing
 	type queueItem struct {
 		glob    string
@@ -1483,6 +1543,16 @@ func copierHandlerGet(bulkWriter io.Writer, req request, pm *fileutils.PatternMa
 				// we don't expand any of the contents that are archives
 				options := req.GetOptions
 				options.ExpandArchives = false
+				// add items to the outgoing tar stream directly, or
+				// let workers read them while we walk the tree
+				addItem := func(info os.FileInfo, symlinkTarget, rel, path string, options GetOptions) error {
+					return copierHandlerGetOne(info, symlinkTarget, rel, path, options, tw, hardlinkChecker, idMappings)
+				}
+				var workQueue *getQueue
+				if req.GetOptions.Parallel > 1 {
+					workQueue = newGetQueue(req.GetOptions.Parallel, hardlinkChecker, idMappings)
+					addItem = workQueue.add
+				}
 				walkfn := func(path string, d fs.DirEntry, err error) error {
 					if err != nil {
 						if options.IgnoreUnreadable && errorIsPermission(err) {
@@ -1583,8 +1653,12 @@ func copierHandlerGet(bulkWriter io.Writer, req request, pm *fileutils.PatternMa
 							return fmt.Errorf("copier: get: error computing path of %q relative to %q: %w", path, req.Root, err)
 						}
 					}
+					// don't bother descending into directories that we're dropping
+					if d.IsDir() && matchesAnyPattern(options.DropPatterns, handleRename(options.Rename, filepath.ToSlash(rel))) {
+						return filepath.SkipDir
+					}
 					// add the item to the outgoing tar stream
-					if err := copierHandlerGetOne(info, symlinkTarget, rel, path, options, tw, hardlinkChecker, idMappings); err != nil {
+					if err := addItem(info, symlinkTarget, rel, path, options); err != nil {
 						if req.GetOptions.IgnoreUnreadable && errorIsPermission(err) {
 							return ok
 						} else if errors.Is(err, os.ErrNotExist) {
@@ -1596,8 +1670,30 @@ func copierHandlerGet(bulkWriter io.Writer, req request, pm *fileutils.PatternMa
 					return ok
 				}
 				// walk the directory tree, checking/adding items individually
-				if err := filepath.WalkDir(item, walkfn); err != nil {
-					return fmt.Errorf("copier: get: %q(%q): %w", queue[i].glob, item, err)
+				var walkErr error
+				if workQueue != nil {
+					walkErrs := make(chan error, 1)
+					go func() {
+						defer workQueue.close()
+						walkErrs <- filepath.WalkDir(item, walkfn)
+					}()
+					drainErr := workQueue.drain(tw, bulkWriter, func(path string, err error) error {
+						if req.GetOptions.IgnoreUnreadable && errorIsPermission(err) {
+							return nil
+						} else if errors.Is(err, os.ErrNotExist) {
+							logrus.Warningf("copier: file disappeared while reading: %q", path)
+							return nil
+						}
+						return err
+					})
+					if walkErr = <-walkErrs; drainErr != nil {
+						walkErr = drainErr
+					}
+				} else {
+					walkErr = filepath.WalkDir(item, walkfn)
+				}
+				if walkErr != nil {
+					return fmt.Errorf("copier: get: %q(%q): %w", queue[i].glob, item, walkErr)
 				}
 				itemsCopied++
 			} else {
@@ -1710,6 +1806,9 @@ func copierHandlerGetOne(srcfi os.FileInfo, symlinkTarget, name, contentPath str
 	if options.Rename != nil {
 		hdr.Name = handleRename(options.Rename, hdr.Name)
 	}
+	if matchesAnyPattern(options.DropPatterns, hdr.Name) {
+		return nil
+	}
 	if options.StripSetuidBit {
 		hdr.Mode &^= cISUID
 	}
@@ -1749,27 +1848,39 @@ func copierHandlerGetOne(srcfi os.FileInfo, symlinkTarget, name, contentPath str
 				return fmt.Errorf("decompressing %s: %w", contentPath, err)
 			}
 			defer rc.Close()
+			// the headers of members whose line endings we convert
+			// need their new sizes, which we find by reading the
+			// archive one extra time, rather than by holding their
+			// contents while we wait to write their headers
+			convertedSizes, err := archiveConvertedSizes(contentPath, &options)
+			if err != nil {
+				return err
+			}
 			tr := tar.NewReader(rc)
 			hdr, err := tr.Next()
-			for err == nil {
+			for member := 0; err == nil; member++ {
 				if options.Rename != nil {
 					hdr.Name = handleRename(options.Rename, hdr.Name)
 				}
-				if options.Timestamp != nil {
-					timestamp := options.Timestamp.UTC()
-					hdr.ModTime = timestamp
-					if !hdr.AccessTime.IsZero() {
-						hdr.AccessTime = timestamp
-					}
-					if !hdr.ChangeTime.IsZero() {
-						hdr.ChangeTime = timestamp
-					}
+				if matchesAnyPattern(options.DropPatterns, hdr.Name) || (hdr.Typeflag == tar.TypeLink && matchesAnyPattern(options.DropPatterns, hdr.Linkname)) {
+					hdr, err = tr.Next()
+					continue
+				}
+				// only we get to say where the contents of a file
+				// can be read from instead of the archive
+				delete(hdr.PAXRecords, CloneSourcePAXRecord)
+				delete(hdr.PAXRecords, CloneSizePAXRecord)
+				setTimestamps(hdr, &options)
+				var contents io.Reader = tr
+				if size, ok := convertedSizes[member]; ok {
+					hdr.Size = size
+					contents = newUnixLineEndingsReader(tr)
 				}
 				if err = tw.WriteHeader(hdr); err != nil {
 					return fmt.Errorf("writing tar header from %q to pipe: %w", contentPath, err)
 				}
 				if hdr.Size != 0 {
-					n, err := io.Copy(tw, tr)
+					n, err := io.Copy(tw, contents)
 					if err != nil {
 						return fmt.Errorf("extracting content from archive %s: %s: %w", contentPath, hdr.Name, err)
 					}
@@ -1830,6 +1941,8 @@ func copierHandlerGetOne(srcfi os.FileInfo, symlinkTarget, name, contentPath str
 		return fmt.Errorf("getting fflags: %w", err)
 	}
 	var f *os.File
+	var contents io.Reader
+	converted := false
 	switch hdr.Typeflag {
 	case tar.TypeReg:
 		// open the file first so that we don't write a header for it if we can't actually read it
@@ -1838,6 +1951,21 @@ func copierHandlerGetOne(srcfi os.FileInfo, symlinkTarget, name, contentPath str
 			return fmt.Errorf("opening file for adding its contents to archive: %w", err)
 		}
 		defer f.Close()
+		contents = f
+		if matchesAnyPattern(options.UnixLineEndings, hdr.Name) {
+			// read it once to find out how long it will be, and
+			// then again to convert it as we copy it
+			size, err := convertedLineEndingsSize(f)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", contentPath, err)
+			}
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("rewinding %s: %w", contentPath, err)
+			}
+			hdr.Size = size
+			contents = newUnixLineEndingsReader(f)
+			converted = true
+		}
 	case tar.TypeDir:
 		// open the directory file first to make sure we can access it.
 		f, err = os.Open(contentPath)
@@ -1846,14 +1974,15 @@ func copierHandlerGetOne(srcfi os.FileInfo, symlinkTarget, name, contentPath str
 		}
 		defer f.Close()
 	}
-	if options.Timestamp != nil {
-		timestamp := options.Timestamp.UTC()
-		hdr.ModTime = timestamp
-		if !hdr.AccessTime.IsZero() {
-			hdr.AccessTime = timestamp
-		}
-		if !hdr.ChangeTime.IsZero() {
-			hdr.ChangeTime = timestamp
+	setTimestamps(hdr, &options)
+	// note where the content came from, so that it can be cloned, unless
+	// we've modified it
+	if options.NoteSources && hdr.Typeflag == tar.TypeReg && hdr.Size > 0 && !converted {
+		if rel, err := filepath.Rel(options.sourceRoot, contentPath); err == nil && filepath.IsLocal(rel) {
+			if hdr.PAXRecords == nil {
+				hdr.PAXRecords = make(map[string]string, 1)
+			}
+			hdr.PAXRecords[CloneSourcePAXRecord] = filepath.ToSlash(rel)
 		}
 	}
 	// output the header
@@ -1862,7 +1991,7 @@ func copierHandlerGetOne(srcfi os.FileInfo, symlinkTarget, name, contentPath str
 	}
 	if hdr.Typeflag == tar.TypeReg {
 		// output the content
-		n, err := io.Copy(tw, f)
+		n, err := io.Copy(tw, contents)
 		if err != nil {
 			return fmt.Errorf("copying %s: %w", contentPath, err)
 		}
@@ -1874,6 +2003,47 @@ func copierHandlerGetOne(srcfi os.FileInfo, symlinkTarget, name, contentPath str
 	return nil
 }
 
+// archiveConvertedSizes reads an archive, and returns the sizes that the
+// contents of the regular files in it which options.UnixLineEndings matches
+// will have after their line endings are converted, indexed by their
+// positions in the archive.
+func archiveConvertedSizes(contentPath string, options *GetOptions) (map[int]int64, error) {
+	if len(options.UnixLineEndings) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(contentPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening file for reading archive contents: %w", err)
+	}
+	defer f.Close()
+	rc, _, err := compression.AutoDecompress(f)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing %s: %w", contentPath, err)
+	}
+	defer rc.Close()
+	sizes := make(map[int]int64)
+	tr := tar.NewReader(rc)
+	hdr, err := tr.Next()
+	for member := 0; err == nil; member++ {
+		name := hdr.Name
+		if options.Rename != nil {
+			name = handleRename(options.Rename, name)
+		}
+		if hdr.Typeflag == tar.TypeReg && matchesAnyPattern(options.UnixLineEndings, name) {
+			size, err := convertedLineEndingsSize(tr)
+			if err != nil {
+				return nil, fmt.Errorf("extracting content from archive %s: %s: %w", contentPath, name, err)
+			}
+			sizes[member] = size
+		}
+		hdr, err = tr.Next()
+	}
+	if err != io.EOF {
+		return nil, fmt.Errorf("extracting contents of archive %s: %w", contentPath, err)
+	}
+	return sizes, nil
+}
+
 func copierHandlerPut(bulkReader io.Reader, req request, idMappings *idtools.IDMappings) (*response, func() error, error) {
 	errorResponse := func(fmtspec string, args ...any) (*response, func() error, error) {
 		return &response{Error: fmt.Sprintf(fmtspec, args...), Put: putResponse{}}, nil, nil
@@ -1957,7 +2127,11 @@ func copierHandlerPut(bulkReader io.Reader, req request, idMappings *idtools.IDM
 		}
 		return nil
 	}
-	createFile := func(path string, tr *tar.Reader) (int64, error) {
+	// cloning is cleared if we find that we can't clone files at all, and
+	// hardlinkClones if we find that we can't link to them
+	cloning := true
+	hardlinkClones := req.PutOptions.HardlinkClones
+	createFile := func(path string, r io.Reader, cloneSource *os.File) (int64, error) {
 		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_EXCL, 0o600)
 		if err != nil && errors.Is(err, os.ErrExist) {
 			if req.PutOptions.NoOverwriteDirNonDir {
@@ -1988,12 +2162,144 @@ func copierHandlerPut(bulkReader io.Reader, req request, idMappings *idtools.IDM
 			return 0, fmt.Errorf("copier: put: error opening file %q for writing: %w", path, err)
 		}
 		defer f.Close()
-		n, err := io.Copy(f, tr)
+		if cloneSource != nil && cloning {
+			// if the contents are still in the archive, we
+			// don't need to write them out ourselves
+			cloneErr := cloneFile(f, cloneSource)
+			if cloneErr == nil {
+				st, err := f.Stat()
+				if err != nil {
+					return 0, fmt.Errorf("copier: put: error checking size of cloned file %q: %w", path, err)
+				}
+				return st.Size(), nil
+			}
+			logrus.Debugf("copier: put: cloning %q: %v", path, cloneErr)
+			if cloneUnsupported(cloneErr) {
+				cloning = false
+			}
+		}
+		n, err := io.Copy(f, r)
 		if err != nil {
 			return n, fmt.Errorf("copier: put: error writing file %q: %w", path, err)
 		}
 		return n, nil
 	}
+	// openCloneSourceFor returns the file noted in hdr as the source of
+	// its contents, if we have a way to get to it and it's the right size.
+	// If the contents were left out of the archive, we have to be able to.
+	openCloneSourceFor := func(hdr *tar.Header, omitted bool) (string, *os.File, error) {
+		rel, ok := hdr.PAXRecords[CloneSourcePAXRecord]
+		if !omitted && (!ok || req.cloneFrom == nil || (!cloning && !hardlinkClones)) {
+			return "", nil, nil
+		}
+		if req.cloneFrom == nil {
+			return "", nil, errors.New("no directory to read them from")
+		}
+		rel = filepath.FromSlash(rel)
+		source, err := openCloneSource(req.cloneFrom, rel)
+		if err != nil {
+			if omitted {
+				return "", nil, err
+			}
+			logrus.Debugf("copier: put: not cloning %q: %v", rel, err)
+			return "", nil, nil
+		}
+		if st, err := source.Stat(); err != nil || st.Size() != hdr.Size {
+			source.Close()
+			if omitted {
+				return "", nil, fmt.Errorf("%q is no longer %d bytes long", rel, hdr.Size)
+			}
+			return "", nil, nil
+		}
+		return rel, source, nil
+	}
+	// linkFile tries to hard link path to source instead of creating a new
+	// file, if doing so would produce the same result
+	linkFile := func(path, rel string, source *os.File, hdr *tar.Header) bool {
+		if !hardlinkClones || !cloneSourceMatches(source, hdr) {
+			return false
+		}
+		if !req.PutOptions.StripXattrs && len(mapWithPrefixedKeysWithoutKeyPrefix(hdr.PAXRecords, xattrPAXRecordNamespace)) > 0 {
+			return false
+		}
+		if st, err := os.Lstat(path); err == nil {
+			if st.IsDir() {
+				// let createFile() sort this out
+				return false
+			}
+			if err := os.Remove(path); err != nil {
+				return false
+			}
+		}
+		if err := linkCloneSource(req.cloneFrom, rel, source, path); err != nil {
+			logrus.Debugf("copier: put: linking %q: %v", path, err)
+			if cloneUnsupported(err) {
+				hardlinkClones = false
+			}
+			return false
+		}
+		return true
+	}
+	// finishItem sets the ownership, permissions, extended attributes,
+	// timestamps, and flags of a newly-created item
+	finishItem := func(path string, hdr *tar.Header) error {
+		mode := os.FileMode(hdr.Mode) & os.ModePerm
+		// set ownership
+		if err := lchown(path, hdr.Uid, hdr.Gid); err != nil {
+			return fmt.Errorf("copier: put: error setting ownership of %q to %d:%d: %w", path, hdr.Uid, hdr.Gid, err)
+		}
+		// set permissions, except for symlinks, since we don't
+		// have an lchmod, and directories, which we'll fix up
+		// on our way out so that we don't get tripped up by
+		// directories which we're not supposed to be able to
+		// write to, but which we'll need to create content in
+		if hdr.Typeflag != tar.TypeSymlink && hdr.Typeflag != tar.TypeDir {
+			if err := os.Chmod(path, mode); err != nil {
+				return fmt.Errorf("copier: put: error setting permissions on %q to 0%o: %w", path, mode, err)
+			}
+		}
+		// set other bits that might have been reset by chown()
+		if hdr.Typeflag != tar.TypeSymlink {
+			if hdr.Mode&cISUID == cISUID {
+				mode |= os.ModeSetuid
+			}
+			if hdr.Mode&cISGID == cISGID {
+				mode |= os.ModeSetgid
+			}
+			if hdr.Mode&cISVTX == cISVTX {
+				mode |= os.ModeSticky
+			}
+			if hdr.Typeflag == tar.TypeDir {
+				// if/when we do the final setting of permissions on this
+				// directory, make sure to incorporate these bits, too
+				directoryModes[path] = mode
+			}
+			if err := os.Chmod(path, mode); err != nil {
+				return fmt.Errorf("copier: put: setting additional permissions on %q to 0%o: %w", path, mode, err)
+			}
+		}
+		// set xattrs, including some that might have been reset by chown()
+		if !req.PutOptions.StripXattrs {
+			xattrs := mapWithPrefixedKeysWithoutKeyPrefix(hdr.PAXRecords, xattrPAXRecordNamespace)
+			if err := Lsetxattrs(path, xattrs); err != nil {
+				if !req.PutOptions.IgnoreXattrErrors {
+					return fmt.Errorf("copier: put: error setting extended attributes on %q: %w", path, err)
+				}
+			}
+		}
+		// set time
+		if hdr.AccessTime.IsZero() || hdr.AccessTime.Before(hdr.ModTime) {
+			hdr.AccessTime = hdr.ModTime
+		}
+		if err := lutimes(hdr.Typeflag == tar.TypeSymlink, path, hdr.AccessTime, hdr.ModTime); err != nil {
+			return fmt.Errorf("setting access and modify timestamps on %q to %s and %s: %w", path, hdr.AccessTime, hdr.ModTime, err)
+		}
+		// set fflags if supported
+		if err := archive.WriteFileFlagsFromTarHeader(path, hdr); err != nil {
+			return fmt.Errorf("copier: put: error setting fflags on %q: %w", path, err)
+		}
+		return nil
+	}
 	targetDirectory, err := resolvePath(req.Root, req.Directory, true, nil)
 	if err != nil {
 		return errorResponse("copier: put: error resolving %q: %v", req.Directory, err)
@@ -2030,6 +2336,37 @@ func copierHandlerPut(bulkReader io.Reader, req request, idMappings *idtools.IDM
 				}
 			}
 		}()
+		var workers *putWorkers
+		if req.PutOptions.Parallel > 1 {
+			workers = newPutWorkers(req.PutOptions.Parallel, finishItem)
+			defer workers.wait()
+		}
+		// finishWorkers waits for files that are being written by
+		// workers, and finishes any that they left for us to handle
+		finishWorkers := func() error {
+			if workers == nil {
+				return nil
+			}
+			for _, job := range workers.wait() {
+				if job.err != nil {
+					return job.err
+				}
+				if !job.inline {
+					continue
+				}
+				written, err := createFile(job.path, bytes.NewReader(job.contents), nil)
+				if err == nil && written != job.hdr.Size {
+					return fmt.Errorf("copier: put: error creating regular file %q: incorrect length (%d != %d)", job.path, written, job.hdr.Size)
+				}
+				if err != nil {
+					return fmt.Errorf("copier: put: error creating %q: %w", job.path, err)
+				}
+				if err := finishItem(job.path, job.hdr); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
 		ignoredItems := make(map[string]struct{})
 		tr := tar.NewReader(bulkReader)
 		hdr, err := tr.Next()
@@ -2041,6 +2378,18 @@ func copierHandlerPut(bulkReader io.Reader, req request, idMappings *idtools.IDM
 				hdr, err = tr.Next()
 				continue
 			}
+			// if the sender left the contents out, expecting us to
+			// read them from CloneFrom, the header has a placeholder
+			// size
+			contentOmitted := false
+			if size, ok := hdr.PAXRecords[CloneSizePAXRecord]; ok && hdr.Typeflag == tar.TypeReg {
+				n, err := strconv.ParseInt(size, 10, 64)
+				if err != nil || n < 0 || hdr.Size != 0 {
+					return fmt.Errorf("copier: put: invalid size %q noted for %q", size, hdr.Name)
+				}
+				hdr.Size = n
+				contentOmitted = true
+			}
 			if req.PutOptions.Rename != nil {
 				hdr.Name = handleRename(req.PutOptions.Rename, hdr.Name)
 			}
@@ -2091,13 +2440,51 @@ func copierHandlerPut(bulkReader io.Reader, req request, idMappings *idtools.IDM
 			devMajor := uint32(hdr.Devmajor)
 			devMinor := uint32(hdr.Devminor)
 			mode := os.FileMode(hdr.Mode) & os.ModePerm
+			if workers != nil {
+				// hand small regular files that we can't clone off to
+				// workers, and let them finish before we do anything else
+				_, cloneable := hdr.PAXRecords[CloneSourcePAXRecord]
+				if hdr.Typeflag == tar.TypeReg && hdr.Size <= parallelMaxFileSize && !contentOmitted && (!cloneable || req.cloneFrom == nil || (!cloning && !hardlinkClones)) {
+					if !workers.accepts(path, hdr) {
+						if err := finishWorkers(); err != nil {
+							return err
+						}
+					}
+					if err := workers.start(path, hdr, tr); err != nil {
+						return fmt.Errorf("copier: put: error reading contents for %q: %w", path, err)
+					}
+					goto nextHeader
+				}
+				if err := finishWorkers(); err != nil {
+					return err
+				}
+			}
 			switch hdr.Typeflag {
 			// no type flag for sockets
 			default:
 				return fmt.Errorf("unrecognized Typeflag %c", hdr.Typeflag)
 			case tar.TypeReg:
 				var written int64
-				written, err = createFile(path, tr)
+				rel, cloneSource, openErr := openCloneSourceFor(hdr, contentOmitted)
+				if openErr != nil {
+					return fmt.Errorf("copier: put: reading contents for %q, which were left out of the archive: %w", path, openErr)
+				}
+				if cloneSource != nil {
+					linked := linkFile(path, rel, cloneSource, hdr)
+					if linked {
+						// it's already exactly what it should be
+						cloneSource.Close()
+						goto nextHeader
+					}
+				}
+				contents := io.Reader(tr)
+				if contentOmitted {
+					contents = cloneSource
+				}
+				written, err = createFile(path, contents, cloneSource)
+				if cloneSource != nil {
+					cloneSource.Close()
+				}
 				// only check the length if there wasn't an error, which we'll
 				// check along with errors for other types of entries
 				if err == nil && written != hdr.Size {
@@ -2223,59 +2610,8 @@ func copierHandlerPut(bulkReader io.Reader, req request, idMappings *idtools.IDM
 			if err != nil {
 				return fmt.Errorf("copier: put: error creating %q: %w", path, err)
 			}
-			// set ownership
-			if err = lchown(path, hdr.Uid, hdr.Gid); err != nil {
-				return fmt.Errorf("copier: put: error setting ownership of %q to %d:%d: %w", path, hdr.Uid, hdr.Gid, err)
-			}
-			// set permissions, except for symlinks, since we don't
-			// have an lchmod, and directories, which we'll fix up
-			// on our way out so that we don't get tripped up by
-			// directories which we're not supposed to be able to
-			// write to, but which we'll need to create content in
-			if hdr.Typeflag != tar.TypeSymlink && hdr.Typeflag != tar.TypeDir {
-				if err = os.Chmod(path, mode); err != nil {
-					return fmt.Errorf("copier: put: error setting permissions on %q to 0%o: %w", path, mode, err)
-				}
-			}
-			// set other bits that might have been reset by chown()
-			if hdr.Typeflag != tar.TypeSymlink {
-				if hdr.Mode&cISUID == cISUID {
-					mode |= os.ModeSetuid
-				}
-				if hdr.Mode&cISGID == cISGID {
-					mode |= os.ModeSetgid
-				}
-				if hdr.Mode&cISVTX == cISVTX {
-					mode |= os.ModeSticky
-				}
-				if hdr.Typeflag == tar.TypeDir {
-					// if/when we do the final setting of permissions on this
-					// directory, make sure to incorporate these bits, too
-					directoryModes[path] = mode
-				}
-				if err = os.Chmod(path, mode); err != nil {
-					return fmt.Errorf("copier: put: setting additional permissions on %q to 0%o: %w", path, mode, err)
-				}
-			}
-			// set xattrs, including some that might have been reset by chown()
-			if !req.PutOptions.StripXattrs {
-				xattrs := mapWithPrefixedKeysWithoutKeyPrefix(hdr.PAXRecords, xattrPAXRecordNamespace)
-				if err = Lsetxattrs(path, xattrs); err != nil {
-					if !req.PutOptions.IgnoreXattrErrors {
-						return fmt.Errorf("copier: put: error setting extended attributes on %q: %w", path, err)
-					}
-				}
-			}
-			// set time
-			if hdr.AccessTime.IsZero() || hdr.AccessTime.Before(hdr.ModTime) {
-				hdr.AccessTime = hdr.ModTime
-			}
-			if err = lutimes(hdr.Typeflag == tar.TypeSymlink, path, hdr.AccessTime, hdr.ModTime); err != nil {
-				return fmt.Errorf("setting access and modify timestamps on %q to %s and %s: %w", path, hdr.AccessTime, hdr.ModTime, err)
-			}
-			// set fflags if supported
-			if err := archive.WriteFileFlagsFromTarHeader(path, hdr); err != nil {
-				return fmt.Errorf("copier: put: error setting fflags on %q: %w", path, err)
+			if err = finishItem(path, hdr); err != nil {
+				return err
 			}
 		nextHeader:
 			hdr, err = tr.Next()
@@ -2283,6 +2619,9 @@ func copierHandlerPut(bulkReader io.Reader, req request, idMappings *idtools.IDM
 		if err != io.EOF {
 			return fmt.Errorf("reading tar stream: expected EOF: %w", err)
 		}
+		if err := finishWorkers(); err != nil {
+			return err
+		}
 		// Drain any remaining data from bulkReader to prevent broken pipe errors.
 		// tar.Reader returns EOF after reading the standard tar EOF marker
 		// (two 512-byte blocks of nulls), but the tar file may have additional
@@ -9,11 +9,14 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/moby/sys/capability"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.podman.io/storage/pkg/idtools"
 	"go.podman.io/storage/pkg/mount"
 	"go.podman.io/storage/pkg/reexec"
 	"golang.org/x/sys/unix"
@@ -193,3 +196,369 @@ func TestGetNoCrossDevice(t *testing.T) {
 		t.Logf("got unexpected entry for %q", th.Name)
 	}
 }
+
+func TestPutClone(t *testing.T) {
+	t.Parallel()
+	contextDir := t.TempDir()
+	wholeSecond := time.Unix(1700000000, 0)
+	big := bytes.Repeat([]byte("0123456789abcdef"), 65536)
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "big"), big, 0o644))
+	require.NoError(t, os.Chtimes(filepath.Join(contextDir, "big"), wholeSecond, wholeSecond))
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "small"), []byte("small"), 0o640))
+	require.NoError(t, os.Chtimes(filepath.Join(contextDir, "small"), wholeSecond, wholeSecond.Add(time.Millisecond)))
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "empty"), nil, 0o600))
+
+	var archive bytes.Buffer
+	require.NoError(t, Get(contextDir, contextDir, GetOptions{NoteSources: true}, []string{"*"}, &archive))
+	noted := make(map[string]string)
+	tr := tar.NewReader(bytes.NewReader(archive.Bytes()))
+	hdr, err := tr.Next()
+	for err == nil {
+		if source, ok := hdr.PAXRecords[CloneSourcePAXRecord]; ok {
+			noted[hdr.Name] = source
+		}
+		hdr, err = tr.Next()
+	}
+	require.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, map[string]string{"big": "big", "small": "small"}, noted, "empty files shouldn't be noted")
+
+	inode := func(t *testing.T, path string) uint64 {
+		var st unix.Stat_t
+		require.NoError(t, unix.Stat(path, &st))
+		return st.Ino
+	}
+	check := func(t *testing.T, dest string) {
+		for name, contents := range map[string][]byte{"big": big, "small": []byte("small"), "empty": {}} {
+			actual, err := os.ReadFile(filepath.Join(dest, name))
+			require.NoError(t, err)
+			assert.Equalf(t, contents, actual, "contents of %q", name)
+			expected, err := os.Stat(filepath.Join(contextDir, name))
+			require.NoError(t, err)
+			st, err := os.Stat(filepath.Join(dest, name))
+			require.NoError(t, err)
+			assert.Equalf(t, expected.Mode(), st.Mode(), "mode of %q", name)
+			// the archive only has room for whole seconds, but a
+			// hard link keeps the source's more precise value
+			assert.Equalf(t, expected.ModTime().Round(time.Second), st.ModTime().Round(time.Second), "modification time of %q", name)
+		}
+	}
+
+	t.Run("clone", func(t *testing.T) {
+		t.Parallel()
+		dest := t.TempDir()
+		require.NoError(t, Put(dest, dest, PutOptions{CloneFrom: contextDir}, bytes.NewReader(archive.Bytes())))
+		check(t, dest)
+		assert.NotEqual(t, inode(t, filepath.Join(contextDir, "big")), inode(t, filepath.Join(dest, "big")))
+	})
+
+	t.Run("hardlink", func(t *testing.T) {
+		t.Parallel()
+		dest := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dest, "big"), []byte("to be replaced"), 0o600))
+		require.NoError(t, Put(dest, dest, PutOptions{CloneFrom: contextDir, HardlinkClones: true}, bytes.NewReader(archive.Bytes())))
+		check(t, dest)
+		// a modification time which was rounded to fit in the
+		// archive still counts as a match
+		assert.Equal(t, inode(t, filepath.Join(contextDir, "big")), inode(t, filepath.Join(dest, "big")))
+		assert.Equal(t, inode(t, filepath.Join(contextDir, "small")), inode(t, filepath.Join(dest, "small")))
+		// forcing different ownership means we can't link
+		dest = t.TempDir()
+		owner := idtools.IDPair{UID: os.Getuid() + 1, GID: os.Getgid() + 1}
+		err := Put(dest, dest, PutOptions{CloneFrom: contextDir, HardlinkClones: true, ChownFiles: &owner}, bytes.NewReader(archive.Bytes()))
+		if err != nil && os.Getuid() != 0 {
+			t.Skipf("unable to chown: %v", err)
+		}
+		require.NoError(t, err)
+		assert.NotEqual(t, inode(t, filepath.Join(contextDir, "big")), inode(t, filepath.Join(dest, "big")))
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		t.Parallel()
+		// the notes point to files which don't match, so the contents
+		// have to come from the archive
+		staleDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(staleDir, "big"), []byte("not the same size"), 0o644))
+		require.NoError(t, os.Symlink(filepath.Join(contextDir, "small"), filepath.Join(staleDir, "small")))
+		dest := t.TempDir()
+		require.NoError(t, Put(dest, dest, PutOptions{CloneFrom: staleDir, HardlinkClones: true}, bytes.NewReader(archive.Bytes())))
+		check(t, dest)
+		assert.NotEqual(t, inode(t, filepath.Join(contextDir, "small")), inode(t, filepath.Join(dest, "small")))
+	})
+
+	t.Run("omitted", func(t *testing.T) {
+		t.Parallel()
+		// the contents of noted files are left out, so they have to
+		// come from CloneFrom
+		var omitted bytes.Buffer
+		require.NoError(t, omitNotedContents(&omitted, bytes.NewReader(archive.Bytes())))
+		assert.Less(t, omitted.Len(), len(big))
+
+		dest := t.TempDir()
+		require.NoError(t, Put(dest, dest, PutOptions{CloneFrom: contextDir}, bytes.NewReader(omitted.Bytes())))
+		check(t, dest)
+		dest = t.TempDir()
+		require.NoError(t, Put(dest, dest, PutOptions{CloneFrom: contextDir, HardlinkClones: true, Parallel: 4}, bytes.NewReader(omitted.Bytes())))
+		check(t, dest)
+
+		// without the source files, there's nowhere to get the contents from
+		err = Put(t.TempDir(), t.TempDir(), PutOptions{}, bytes.NewReader(omitted.Bytes()))
+		assert.ErrorContains(t, err, "left out of the archive")
+		staleDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(staleDir, "big"), []byte("not the same size"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(staleDir, "small"), []byte("small"), 0o644))
+		dest = t.TempDir()
+		err = Put(dest, dest, PutOptions{CloneFrom: staleDir}, bytes.NewReader(omitted.Bytes()))
+		assert.ErrorContains(t, err, "no longer")
+	})
+}
+
+func TestGetExpandedCloneRecords(t *testing.T) {
+	t.Parallel()
+	// an archive in the context which claims that one of its members
+	// can be read from another file in the context
+	contextDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "secret"), []byte("secret contents"), 0o600))
+	var crafted bytes.Buffer
+	tw := tar.NewWriter(&crafted)
+	contents := []byte("innocent contents")
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name:     "innocent",
+		Typeflag: tar.TypeReg,
+		Mode:     0o644,
+		Size:     int64(len(contents)),
+		Format:   tar.FormatPAX,
+		PAXRecords: map[string]string{
+			CloneSourcePAXRecord: "secret",
+			CloneSizePAXRecord:   strconv.Itoa(len("secret contents")),
+		},
+	}))
+	_, err := tw.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "archive.tar"), crafted.Bytes(), 0o644))
+
+	var archive bytes.Buffer
+	require.NoError(t, Get(contextDir, contextDir, GetOptions{NoteSources: true, ExpandArchives: true}, []string{"archive.tar"}, &archive))
+	tr := tar.NewReader(bytes.NewReader(archive.Bytes()))
+	hdr, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "innocent", hdr.Name)
+	assert.NotContains(t, hdr.PAXRecords, CloneSourcePAXRecord)
+	assert.NotContains(t, hdr.PAXRecords, CloneSizePAXRecord)
+
+	var omitted bytes.Buffer
+	require.NoError(t, omitNotedContents(&omitted, bytes.NewReader(archive.Bytes())))
+	dest := t.TempDir()
+	require.NoError(t, Put(dest, dest, PutOptions{CloneFrom: contextDir, HardlinkClones: true}, bytes.NewReader(omitted.Bytes())))
+	actual, err := os.ReadFile(filepath.Join(dest, "innocent"))
+	require.NoError(t, err)
+	assert.Equal(t, contents, actual)
+}
+
+// omitNotedContents copies an archive, leaving out the contents of files whose
+// sources were noted, the way buildah's Add() does.
+func omitNotedContents(w io.Writer, r io.Reader) error {
+	tw := tar.NewWriter(w)
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
+	for err == nil {
+		if _, ok := hdr.PAXRecords[CloneSourcePAXRecord]; ok {
+			hdr.PAXRecords[CloneSizePAXRecord] = strconv.FormatInt(hdr.Size, 10)
+			hdr.Size = 0
+		}
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err = io.CopyN(tw, tr, hdr.Size); err != nil {
+			return err
+		}
+		hdr, err = tr.Next()
+	}
+	if !errors.Is(err, io.EOF) {
+		return err
+	}
+	return tw.Close()
+}
+
+func BenchmarkPut(b *testing.B) {
+	contextDir := b.TempDir()
+	contents := bytes.Repeat([]byte{0x5a}, 64*1024*1024)
+	require.NoError(b, os.WriteFile(filepath.Join(contextDir, "artifact"), contents, 0o644))
+	for _, clone := range []bool{false, true} {
+		name := "archive"
+		if clone {
+			name = "clone"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(contents)))
+			for b.Loop() {
+				dest := b.TempDir()
+				pipeReader, pipeWriter := io.Pipe()
+				getErr := make(chan error, 1)
+				go func() {
+					err := Get(contextDir, contextDir, GetOptions{NoteSources: clone}, []string{"artifact"}, pipeWriter)
+					pipeWriter.CloseWithError(err)
+					getErr <- err
+				}()
+				putOptions := PutOptions{}
+				archive := io.Reader(pipeReader)
+				if clone {
+					putOptions.CloneFrom = contextDir
+					omittedReader, omittedWriter := io.Pipe()
+					go func() {
+						omittedWriter.CloseWithError(omitNotedContents(omittedWriter, pipeReader))
+					}()
+					archive = omittedReader
+				}
+				require.NoError(b, Put(dest, dest, putOptions, archive))
+				require.NoError(b, <-getErr)
+			}
+		})
+	}
+}
+
+func TestParallel(t *testing.T) {
+	t.Parallel()
+	contextDir := t.TempDir()
+	wholeSecond := time.Unix(1700000000, 0)
+	for d := range 4 {
+		dir := filepath.Join(contextDir, fmt.Sprintf("dir%d", d))
+		require.NoError(t, os.Mkdir(dir, 0o755))
+		for f := range 100 {
+			file := filepath.Join(dir, fmt.Sprintf("file%d", f))
+			size := f * 37
+			if f%25 == 0 {
+				size = parallelMaxFileSize + f
+			}
+			require.NoError(t, os.WriteFile(file, bytes.Repeat([]byte{byte('a' + f%26)}, size), os.FileMode(0o600+f%64)))
+			require.NoError(t, os.Chtimes(file, wholeSecond, wholeSecond.Add(time.Duration(f)*time.Second)))
+		}
+	}
+	require.NoError(t, os.Link(filepath.Join(contextDir, "dir0", "file1"), filepath.Join(contextDir, "dir3", "link")))
+	require.NoError(t, os.Symlink("../dir0/file2", filepath.Join(contextDir, "dir2", "symlink")))
+	wholeSecondVal := unix.NsecToTimeval(wholeSecond.UnixNano())
+	require.NoError(t, unix.Lutimes(filepath.Join(contextDir, "dir2", "symlink"), []unix.Timeval{wholeSecondVal, wholeSecondVal}))
+	require.NoError(t, os.Mkdir(filepath.Join(contextDir, "empty"), 0o700))
+	for _, dir := range []string{"dir0", "dir1", "dir2", "dir3", "empty"} {
+		require.NoError(t, os.Chtimes(filepath.Join(contextDir, dir), wholeSecond, wholeSecond))
+	}
+
+	get := func(t *testing.T, parallel int) []byte {
+		var archive bytes.Buffer
+		require.NoError(t, Get(contextDir, contextDir, GetOptions{Parallel: parallel}, []string{"."}, &archive))
+		return archive.Bytes()
+	}
+	serialArchive := get(t, 0)
+
+	t.Run("get", func(t *testing.T) {
+		t.Parallel()
+		for _, parallel := range []int{2, 8} {
+			assert.Equalf(t, serialArchive, get(t, parallel), "archive produced using %d workers", parallel)
+		}
+	})
+
+	// describe returns the contents of a directory tree, and the names of
+	// hard links to things we've seen already
+	describe := func(t *testing.T, root string) map[string]string {
+		tree := make(map[string]string)
+		inodes := make(map[uint64]string)
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			require.NoError(t, err)
+			rel, err := filepath.Rel(root, path)
+			require.NoError(t, err)
+			var st unix.Stat_t
+			require.NoError(t, unix.Lstat(path, &st))
+			description := fmt.Sprintf("mode=%o mtime=%d", st.Mode, st.Mtim.Sec)
+			switch {
+			case d.Type().IsRegular():
+				if linked, ok := inodes[st.Ino]; ok {
+					description += " link=" + linked
+				}
+				inodes[st.Ino] = rel
+				contents, err := os.ReadFile(path)
+				require.NoError(t, err)
+				description += fmt.Sprintf(" size=%d contents=%x", len(contents), contents[:min(len(contents), 8)])
+			case d.Type() == os.ModeSymlink:
+				target, err := os.Readlink(path)
+				require.NoError(t, err)
+				description += " target=" + target
+			}
+			tree[rel] = description
+			return nil
+		})
+		require.NoError(t, err)
+		delete(tree, ".")
+		return tree
+	}
+
+	t.Run("put", func(t *testing.T) {
+		t.Parallel()
+		serial := t.TempDir()
+		require.NoError(t, Put(serial, serial, PutOptions{}, bytes.NewReader(serialArchive)))
+		expected := describe(t, serial)
+		assert.Equal(t, describe(t, contextDir), expected)
+
+		dest := t.TempDir()
+		require.NoError(t, Put(dest, dest, PutOptions{Parallel: 8}, bytes.NewReader(serialArchive)))
+		assert.Equal(t, expected, describe(t, dest))
+
+		// files which are already present have to be replaced
+		dest = t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dest, "dir1", "file3"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dest, "dir1", "file4"), []byte("to be replaced"), 0o644))
+		require.NoError(t, Put(dest, dest, PutOptions{Parallel: 8}, bytes.NewReader(serialArchive)))
+		assert.Equal(t, expected, describe(t, dest))
+	})
+
+	t.Run("put-duplicates", func(t *testing.T) {
+		t.Parallel()
+		// later entries for the same path have to win
+		var archive bytes.Buffer
+		tw := tar.NewWriter(&archive)
+		for i, contents := range []string{"first", "second", "third"} {
+			require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "dup", Size: int64(len(contents)), Mode: int64(0o600 + i), ModTime: wholeSecond}))
+			_, err := tw.Write([]byte(contents))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		dest := t.TempDir()
+		require.NoError(t, Put(dest, dest, PutOptions{Parallel: 8}, bytes.NewReader(archive.Bytes())))
+		contents, err := os.ReadFile(filepath.Join(dest, "dup"))
+		require.NoError(t, err)
+		assert.Equal(t, "third", string(contents))
+		st, err := os.Stat(filepath.Join(dest, "dup"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o602), st.Mode().Perm())
+	})
+}
+
+func BenchmarkParallel(b *testing.B) {
+	contextDir := b.TempDir()
+	total := int64(0)
+	for d := range 20 {
+		dir := filepath.Join(contextDir, fmt.Sprintf("dir%d", d))
+		require.NoError(b, os.Mkdir(dir, 0o755))
+		for f := range 500 {
+			contents := bytes.Repeat([]byte{byte(f)}, 4096)
+			require.NoError(b, os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d", f)), contents, 0o644))
+			total += int64(len(contents))
+		}
+	}
+	for _, parallel := range []int{1, 8} {
+		b.Run(fmt.Sprintf("workers=%d", parallel), func(b *testing.B) {
+			b.SetBytes(total)
+			for b.Loop() {
+				dest := b.TempDir()
+				pipeReader, pipeWriter := io.Pipe()
+				getErr := make(chan error, 1)
+				go func() {
+					err := Get(contextDir, contextDir, GetOptions{Parallel: parallel}, []string{"."}, pipeWriter)
+					pipeWriter.CloseWithError(err)
+					getErr <- err
+				}()
+				require.NoError(b, Put(dest, dest, PutOptions{Parallel: parallel}, pipeReader))
+				require.NoError(b, <-getErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package copier
+
+import (
+	"archive/tar"
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// checkPatterns returns an error if any of the patterns is malformed.
+func checkPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchesAnyPattern returns true if name, the name of an item in an archive,
+// matches any of the patterns.  Patterns which don't include a "/" are
+// compared to each component of the name, so that they match items with that
+// name and everything under them, anywhere in the archive.  Patterns which
+// include a "/" are compared to the name and the names of each of the
+// directories that it's in.
+func matchesAnyPattern(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		return false
+	}
+	components := strings.Split(name, "/")
+	for _, pattern := range patterns {
+		pattern = strings.Trim(pattern, "/")
+		if !strings.Contains(pattern, "/") {
+			for _, component := range components {
+				if matched, _ := path.Match(pattern, component); matched {
+					return true
+				}
+			}
+			continue
+		}
+		for i := range components {
+			if matched, _ := path.Match(pattern, strings.Join(components[:i+1], "/")); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unixLineEndingsReader reads from another reader, converting CRLF line
+// endings to LF as it goes.
+type unixLineEndingsReader struct {
+	r *bufio.Reader
+}
+
+func newUnixLineEndingsReader(r io.Reader) io.Reader {
+	return &unixLineEndingsReader{r: bufio.NewReader(r)}
+}
+
+func (u *unixLineEndingsReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c, err := u.r.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		if c == '\r' {
+			if next, err := u.r.Peek(1); err == nil && next[0] == '\n' {
+				continue
+			}
+		}
+		p[n] = c
+		n++
+	}
+	return n, nil
+}
+
+// convertedLineEndingsSize reads all of r, and returns how long its contents
+// will be after CRLF line endings are converted to LF, without keeping them.
+func convertedLineEndingsSize(r io.Reader) (int64, error) {
+	return io.Copy(io.Discard, newUnixLineEndingsReader(r))
+}
+
+// setTimestamps applies options.Timestamp to hdr, either unconditionally, or
+// if options.ClampTimestamp is set, only to times which are later than it.
+func setTimestamps(hdr *tar.Header, options *GetOptions) {
+	if options.Timestamp == nil {
+		return
+	}
+	timestamp := options.Timestamp.UTC()
+	apply := func(t *time.Time) {
+		if !options.ClampTimestamp || t.After(timestamp) {
+			*t = timestamp
+		}
+	}
+	apply(&hdr.ModTime)
+	if !hdr.AccessTime.IsZero() {
+		apply(&hdr.AccessTime)
+	}
+	if !hdr.ChangeTime.IsZero() {
+		apply(&hdr.ChangeTime)
+	}
+}
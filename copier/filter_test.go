@@ -0,0 +1,137 @@
+package copier
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchesAnyPattern(t *testing.T) {
+	cases := []struct {
+		patterns []string
+		name     string
+		matches  bool
+	}{
+		{nil, "anything", false},
+		{[]string{"__pycache__"}, "__pycache__", true},
+		{[]string{"__pycache__"}, "a/b/__pycache__", true},
+		{[]string{"__pycache__"}, "a/__pycache__/b.pyc", true},
+		{[]string{"__pycache__"}, "a/__pycache__.py", false},
+		{[]string{"*.pyc"}, "./a/b.pyc", true},
+		{[]string{"*.pyc"}, "a/b.py", false},
+		{[]string{"a/*.md"}, "a/README.md", true},
+		{[]string{"a/*.md"}, "/a/README.md", true},
+		{[]string{"a/*.md"}, "b/a/README.md", false},
+		{[]string{"a/b"}, "a/b/c/d", true},
+		{[]string{"a/b/"}, "a/b/c", true},
+		{[]string{"x", "*.txt"}, "docs/notes.txt", true},
+		{[]string{"*"}, ".", false},
+	}
+	for _, c := range cases {
+		assert.Equalf(t, c.matches, matchesAnyPattern(c.patterns, c.name), "%q vs %q", c.patterns, c.name)
+	}
+	assert.Error(t, checkPatterns([]string{"ok", "[unterminated"}))
+	assert.NoError(t, checkPatterns([]string{"ok", "*.txt", "a/[bc]"}))
+}
+
+func TestUnixLineEndingsReader(t *testing.T) {
+	cases := []struct {
+		input, output string
+	}{
+		{"", ""},
+		{"no line endings", "no line endings"},
+		{"one\r\ntwo\r\n", "one\ntwo\n"},
+		{"one\ntwo\n", "one\ntwo\n"},
+		{"lone\rcarriage\r", "lone\rcarriage\r"},
+		{"\r\r\n\n\r", "\r\n\n\r"},
+		{strings.Repeat("long line\r\n", 10000), strings.Repeat("long line\n", 10000)},
+	}
+	for _, c := range cases {
+		// read a byte at a time, so that line endings are split
+		// between reads
+		converted, err := io.ReadAll(newUnixLineEndingsReader(iotest.OneByteReader(strings.NewReader(c.input))))
+		require.NoError(t, err)
+		assert.Equalf(t, c.output, string(converted), "converting %q", c.input)
+		size, err := convertedLineEndingsSize(strings.NewReader(c.input))
+		require.NoError(t, err)
+		assert.Equalf(t, int64(len(c.output)), size, "size of converted %q", c.input)
+	}
+}
+
+func TestGetFilters(t *testing.T) {
+	t.Parallel()
+	tmp := t.TempDir()
+	older, newer, clamp := time.Unix(1000, 0), time.Unix(5000, 0), time.Unix(3000, 0)
+	write := func(name, contents string, mtime time.Time) {
+		path := filepath.Join(tmp, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	write("tree/old.txt", "one\r\ntwo\r\n", older)
+	write("tree/new.bin", "one\r\ntwo\r\n", newer)
+	write("tree/__pycache__/mod.cpython.pyc", "compiled", newer)
+	write("tree/sub/stale.pyc", "compiled", newer)
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for _, entry := range []struct {
+		name, contents string
+		mtime          time.Time
+	}{
+		{"__pycache__/other.pyc", "compiled", older},
+		{"notes.txt", "three\r\n", newer},
+		{"keep.pyx", "source", older},
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: entry.name, Size: int64(len(entry.contents)), Mode: 0o644, ModTime: entry.mtime}))
+		_, err := tw.Write([]byte(entry.contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeLink, Name: "linked.pyc", Linkname: "__pycache__/other.pyc"}))
+	require.NoError(t, tw.Close())
+	write("archive.tar", archive.String(), older)
+
+	options := GetOptions{
+		ExpandArchives:  true,
+		Timestamp:       &clamp,
+		ClampTimestamp:  true,
+		DropPatterns:    []string{"__pycache__", "*.pyc"},
+		UnixLineEndings: []string{"*.txt"},
+	}
+	var output bytes.Buffer
+	require.NoError(t, Get(tmp, tmp, options, []string{"tree", "archive.tar"}, &output))
+
+	type item struct {
+		contents string
+		mtime    int64
+	}
+	items := make(map[string]item)
+	tr := tar.NewReader(&output)
+	hdr, err := tr.Next()
+	for err == nil {
+		contents, readErr := io.ReadAll(tr)
+		require.NoError(t, readErr)
+		items[hdr.Name] = item{contents: string(contents), mtime: hdr.ModTime.Unix()}
+		hdr, err = tr.Next()
+	}
+	require.ErrorIs(t, err, io.EOF)
+	delete(items, "sub/")
+	assert.Equal(t, map[string]item{
+		"old.txt":   {"one\ntwo\n", older.Unix()},
+		"new.bin":   {"one\r\ntwo\r\n", clamp.Unix()},
+		"notes.txt": {"three\n", clamp.Unix()},
+		"keep.pyx":  {"source", older.Unix()},
+	}, items)
+
+	options.DropPatterns = []string{"[unterminated"}
+	assert.Error(t, Get(tmp, tmp, options, []string{"tree"}, io.Discard))
+}
@@ -30,3 +30,10 @@ func (h *hardlinkChecker) Add(fi os.FileInfo, name string) {
 		h.hardlinks.Store(makeHardlinkDeviceAndInode(st), name)
 	}
 }
+
+// isHardlinked returns true if fi describes a regular file which has more
+// than one link to it.
+func isHardlinked(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && fi.Mode().IsRegular() && st.Nlink > 1
+}
@@ -14,3 +14,7 @@ func (h *hardlinkChecker) Check(fi os.FileInfo) string {
 
 func (h *hardlinkChecker) Add(fi os.FileInfo, name string) {
 }
+
+func isHardlinked(fi os.FileInfo) bool {
+	return false
+}
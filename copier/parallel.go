@@ -0,0 +1,224 @@
+package copier
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"go.podman.io/storage/pkg/idtools"
+)
+
+const (
+	// parallelMaxFileSize is the largest regular file whose contents we'll
+	// read or write using a worker, instead of inline.  Larger files are
+	// handled inline so that the amount of memory we're using for queued
+	// contents stays bounded.
+	parallelMaxFileSize = 256 * 1024
+	// parallelQueueDepth is the number of items, per worker, that we'll
+	// allow to be queued up ahead of the one that's being written out.
+	parallelQueueDepth = 16
+)
+
+var errParallelAborted = errors.New("copier: parallel copy aborted")
+
+// getJob is an item that we'll be adding to the archive, which might be read
+// and formatted by a worker.
+type getJob struct {
+	info                         os.FileInfo
+	symlinkTarget, name, content string
+	options                      GetOptions
+	inline                       bool
+	done                         chan struct{}
+	buffer                       bytes.Buffer
+	err                          error
+}
+
+// getQueue reads the contents of small files and formats their archive
+// entries using multiple workers, while the results are written to the
+// archive in the order in which they were added.
+type getQueue struct {
+	hardlinkChecker *hardlinkChecker
+	idMappings      *idtools.IDMappings
+	workers         chan struct{}
+	jobs            chan *getJob
+	abort           chan struct{}
+	abortOnce       sync.Once
+}
+
+func newGetQueue(parallel int, hardlinkChecker *hardlinkChecker, idMappings *idtools.IDMappings) *getQueue {
+	return &getQueue{
+		hardlinkChecker: hardlinkChecker,
+		idMappings:      idMappings,
+		workers:         make(chan struct{}, parallel),
+		jobs:            make(chan *getJob, parallel*parallelQueueDepth),
+		abort:           make(chan struct{}),
+	}
+}
+
+// add queues an item to be added to the archive.  Regular files which aren't
+// too large and which aren't hard linked to anything are read by workers.
+func (q *getQueue) add(info os.FileInfo, symlinkTarget, name, contentPath string, options GetOptions) error {
+	job := &getJob{
+		info:          info,
+		symlinkTarget: symlinkTarget,
+		name:          name,
+		content:       contentPath,
+		options:       options,
+		inline:        !info.Mode().IsRegular() || info.Size() > parallelMaxFileSize || isHardlinked(info),
+		done:          make(chan struct{}),
+	}
+	if job.inline {
+		close(job.done)
+	} else {
+		select {
+		case q.workers <- struct{}{}:
+		case <-q.abort:
+			return errParallelAborted
+		}
+		go func() {
+			defer func() {
+				<-q.workers
+				close(job.done)
+			}()
+			tw := tar.NewWriter(&job.buffer)
+			if job.err = copierHandlerGetOne(job.info, job.symlinkTarget, job.name, job.content, job.options, tw, q.hardlinkChecker, q.idMappings); job.err == nil {
+				// don't Close() the writer, since that would
+				// add an end-of-archive marker
+				job.err = tw.Flush()
+			}
+		}()
+	}
+	select {
+	case q.jobs <- job:
+	case <-q.abort:
+		<-job.done
+		return errParallelAborted
+	}
+	return nil
+}
+
+// close indicates that no more items will be added.
+func (q *getQueue) close() {
+	close(q.jobs)
+}
+
+// drain writes the queued items to the archive in the order in which they
+// were added, until close() is called.  Errors encountered while reading
+// items are passed to handle, and if it returns an error, anything still
+// being added is discarded, and that error is returned.
+func (q *getQueue) drain(tw *tar.Writer, bulkWriter io.Writer, handle func(path string, err error) error) error {
+	var drainErr error
+	for job := range q.jobs {
+		<-job.done
+		if drainErr != nil {
+			continue
+		}
+		err := job.err
+		if err == nil {
+			if job.inline {
+				err = copierHandlerGetOne(job.info, job.symlinkTarget, job.name, job.content, job.options, tw, q.hardlinkChecker, q.idMappings)
+			} else if err = tw.Flush(); err == nil {
+				_, err = bulkWriter.Write(job.buffer.Bytes())
+			}
+		}
+		job.buffer = bytes.Buffer{}
+		if err != nil {
+			if drainErr = handle(job.content, err); drainErr != nil {
+				q.abortOnce.Do(func() { close(q.abort) })
+			}
+		}
+	}
+	return drainErr
+}
+
+// putJob is a regular file that a worker is writing.
+type putJob struct {
+	path     string
+	hdr      *tar.Header
+	contents []byte
+	inline   bool
+	err      error
+}
+
+// putWorkers creates regular files and sets their contents and attributes
+// using multiple workers.  A worker only creates files which don't already
+// exist, and leaves anything it can't handle simply for the caller to finish
+// inline after calling wait().
+type putWorkers struct {
+	finish  func(path string, hdr *tar.Header) error
+	workers chan struct{}
+	wg      sync.WaitGroup
+	jobs    []*putJob
+	paths   map[string]struct{}
+	queued  int64
+}
+
+func newPutWorkers(parallel int, finish func(path string, hdr *tar.Header) error) *putWorkers {
+	return &putWorkers{
+		finish:  finish,
+		workers: make(chan struct{}, parallel),
+		paths:   make(map[string]struct{}),
+	}
+}
+
+// accepts returns true if the regular file described by hdr can be handed to
+// a worker without first waiting for the ones that are already running.
+func (p *putWorkers) accepts(path string, hdr *tar.Header) bool {
+	if p.queued+hdr.Size > int64(cap(p.workers))*parallelQueueDepth*parallelMaxFileSize {
+		return false
+	}
+	_, pending := p.paths[path]
+	return !pending
+}
+
+// start reads the contents of a regular file from tr and hands it off to a
+// worker.
+func (p *putWorkers) start(path string, hdr *tar.Header, tr io.Reader) error {
+	contents := make([]byte, hdr.Size)
+	if _, err := io.ReadFull(tr, contents); err != nil {
+		return err
+	}
+	job := &putJob{path: path, hdr: hdr, contents: contents}
+	p.jobs = append(p.jobs, job)
+	p.paths[path] = struct{}{}
+	p.queued += hdr.Size
+	p.workers <- struct{}{}
+	p.wg.Go(func() {
+		defer func() { <-p.workers }()
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_EXCL, 0o600)
+		if err != nil {
+			// let the caller sort this out
+			job.inline = true
+			return
+		}
+		_, err = f.Write(job.contents)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			err = fmt.Errorf("copier: put: error writing file %q: %w", path, err)
+		} else {
+			err = p.finish(path, hdr)
+		}
+		job.contents = nil
+		job.err = err
+	})
+	return nil
+}
+
+// wait waits for all running workers to finish, and returns the list of jobs
+// which they were given, in the order in which they were started.  Jobs which
+// the workers left for the caller to finish are marked as such, and still have
+// their contents.
+func (p *putWorkers) wait() []*putJob {
+	p.wg.Wait()
+	jobs := p.jobs
+	p.jobs = nil
+	clear(p.paths)
+	p.queued = 0
+	return jobs
+}
@@ -9,6 +9,7 @@ import (
 	nettypes "go.podman.io/common/libnetwork/types"
 	"go.podman.io/image/v5/docker/reference"
 	"go.podman.io/image/v5/pkg/compression"
+	"go.podman.io/image/v5/signature/signer"
 	"go.podman.io/image/v5/types"
 	"go.podman.io/storage/pkg/archive"
 	"golang.org/x/sync/semaphore"
@@ -222,6 +223,30 @@ type BuildOptions struct {
 	// can DENY specific sources or CONVERT them to different references
 	// (e.g., pinning tags to digests).
 	SourcePolicyFile string
+	// PrintGraph, if set to "dot" or "json", causes the graph of the
+	// build's stages and the dependencies between them to be written to
+	// Out in that format, instead of building anything.
+	PrintGraph string
+	// Frontend is the name of the frontend which reads the build
+	// definitions, as registered with the pkg/frontend package.  If it is
+	// not set, a "syntax" parser directive in each definition which names
+	// a registered frontend selects it, and the Dockerfile frontend is
+	// used otherwise.
+	Frontend string
+	// SourceImage, if set, is where to push a source image which is
+	// generated after the build.  The source image contains the build
+	// context, contents fetched from URLs and Git repositories by ADD
+	// instructions, and any source packages found using
+	// SourceImagePackages, and its manifest's annotations record the built
+	// image's name and digest.  A transport is optional, and defaults to
+	// "docker".
+	SourceImage string
+	// SourceImagePackages lists local directories, or http or https URLs
+	// of directories, from which the source packages of RPMs and Debian
+	// packages which are listed in the package URLs that SBOMScanOptions
+	// save are fetched and added to the source image.  It requires
+	// SourceImage, and SBOMScanOptions which save package URLs.
+	SourceImagePackages []string
 	// SkipUnusedStages allows users to skip stages in a multi-stage builds
 	// which do not contribute anything to the target stage. Expected default
 	// value is true.
@@ -322,6 +347,16 @@ type BuildOptions struct {
 	Devices []string
 	// SignBy is the fingerprint of a GPG key to use for signing images.
 	SignBy string
+	// SignBySigstorePrivateKeyFile is the location of a sigstore private
+	// key to use for signing images.
+	SignBySigstorePrivateKeyFile string
+	// SignSigstorePrivateKeyPassphrase is the passphrase for the key
+	// specified in SignBySigstorePrivateKeyFile.
+	SignSigstorePrivateKeyPassphrase []byte
+	// Signers are additional signers to use for signing images, such as
+	// those created using sigstore parameter files.  The caller is
+	// responsible for closing them.
+	Signers []*signer.Signer
 	// Architecture specifies the target architecture of the image to be built.
 	Architecture string
 	// Timestamp specifies a timestamp to use for the image's created-on
@@ -360,6 +395,21 @@ type BuildOptions struct {
 	LogRusage bool
 	// File to which the Rusage logs will be saved to instead of stdout.
 	RusageLogFile string
+	// BreakAt is a list of breakpoints.  The build pauses before any
+	// instruction which starts with the text of a breakpoint (ignoring
+	// case), any instruction on a line which matches a "line:N"
+	// breakpoint, and the first instruction in any stage which matches a
+	// "stage:NAME" or "stage:INDEX" breakpoint.
+	BreakAt []string
+	// Step causes the build to pause before every instruction.
+	Step bool
+	// Debugger is consulted when the build pauses before an instruction.
+	// If not set, and BreakAt or Step are set, a debugger which prompts
+	// using os.Stdin and os.Stderr is used.
+	Debugger BuildDebugger
+	// OnRunFailure controls what happens when a RUN instruction fails.  If
+	// not set, the build is aborted.
+	OnRunFailure RunFailurePolicy
 	// Excludes is a list of excludes to be used instead of the .dockerignore file.
 	Excludes []string
 	// IgnoreFile is a name of the .containerignore file
@@ -424,6 +474,12 @@ type BuildOptions struct {
 	// configuration when committing in Docker format.  Newer
 	// BuildKit-based docker build doesn't set this field.
 	CompatSetParent types.OptionalBool
+	// CompatDockerConfig causes settings which Docker's image
+	// configuration format has a place for, but which the OCI format
+	// doesn't, such as Healthcheck, OnBuild, Shell, StopTimeout, and
+	// Domainname, to be recorded using their Docker names when writing
+	// images in OCI format, as Docker does for some of them.
+	CompatDockerConfig types.OptionalBool
 	// CompatVolumes causes the contents of locations marked as volumes in
 	// base images or by a VOLUME instruction to be preserved during RUN
 	// instructions.  Newer BuildKit-based docker build doesn't bother.
@@ -440,10 +496,39 @@ type BuildOptions struct {
 	CompatLayerOmissions types.OptionalBool
 	// NoPivotRoot inhibits the usage of pivot_root when setting up the rootfs
 	NoPivotRoot bool
+	// NoClone prevents COPY and ADD from reflinking files from the build
+	// context into the container, so that their contents are always
+	// copied.
+	NoClone bool
+	// HardlinkContext allows COPY and ADD to hard link files from the
+	// build context into the container when their ownership, permissions,
+	// and timestamps would not need to be changed, and when no RUN
+	// instruction follows them in the same stage.  It is ignored if
+	// RemoveIntermediateCtrs is not set, or if the build is being
+	// debugged.  It should only be set if the build context will not be
+	// modified during the build.
+	HardlinkContext bool
 	// CreatedAnnotation controls whether or not an "org.opencontainers.image.created"
 	// annotation is present in the output image.
 	CreatedAnnotation types.OptionalBool
 	// MetadataFile is the name of a file to which the builder should write a JSON map
 	// containing metadata about the built image.
 	MetadataFile string
+	// LogPrefix is prepended to the descriptions of steps which are
+	// logged as the build progresses.
+	LogPrefix string
+	// StepLocker, if set, is used to keep builds which are being run at
+	// the same time using the same storage from running a step which
+	// would produce the same cache key more than once.  One build runs
+	// the step, and the others wait for it to finish and then find its
+	// result in the cache.  It has no effect if Layers is not set.
+	StepLocker StepLocker
+}
+
+// StepLocker provides locks for build steps, which are identified by their
+// cache keys.
+type StepLocker interface {
+	// LockStep waits until no other build holds the lock for key, and
+	// returns a function which releases it.
+	LockStep(key string) func()
 }
@@ -0,0 +1,50 @@
+package define
+
+import "context"
+
+// DebugAction tells a build which has paused before an instruction what to do
+// next.
+type DebugAction string
+
+const (
+	// DebugContinue processes the instruction, and keeps going until the
+	// build reaches another breakpoint.
+	DebugContinue DebugAction = "continue"
+	// DebugStep processes the instruction, and pauses again before the
+	// next one.
+	DebugStep DebugAction = "step"
+	// DebugSkip does not process the instruction.  Any image which is
+	// committed as a result is recorded as having skipped it, so that it
+	// will not be mistaken for a cache hit by later builds.
+	DebugSkip DebugAction = "skip"
+	// DebugShell starts an interactive shell in the working container, and
+	// then pauses again before the same instruction.
+	DebugShell DebugAction = "shell"
+	// DebugAbort stops the build.
+	DebugAbort DebugAction = "abort"
+)
+
+// DebugPause describes the instruction which a build has paused before.
+type DebugPause struct {
+	Stage       int      // index of the stage, starting at 0
+	StageName   string   // name of the stage
+	Step        int      // index of the instruction in the stage, starting at 1
+	Steps       int      // number of instructions in the stage
+	Line        int      // line number of the instruction in its Containerfile
+	Instruction string   // the instruction as it appears in the Containerfile
+	Resolved    string   // the instruction, with its arguments resolved
+	Env         []string // environment which the instruction will be processed with
+	WorkingDir  string   // working directory which the instruction will be processed in
+	User        string   // user which the instruction will be processed as
+	Cache       string   // a description of what the build cache has to offer for the instruction
+	ContainerID string   // ID of the working container
+	Breakpoint  string   // breakpoint which was matched, empty if stepping
+}
+
+// BuildDebugger is consulted when a build pauses before an instruction,
+// either because the instruction matched one of the build's breakpoints, or
+// because the build is being stepped through.
+type BuildDebugger interface {
+	// Pause returns what the build should do next.
+	Pause(ctx context.Context, pause DebugPause) (DebugAction, error)
+}
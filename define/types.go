@@ -53,6 +53,8 @@ const (
 	SEV TeeType = "sev"
 	// SNP is a known trusted execution environment type: AMD-SNP (SEV secure nested pages) (requires epyc 3000 "milan")
 	SNP TeeType = "snp"
+	// TDX is a known trusted execution environment type: Intel TDX (trust domain extensions) (requires xeon 5th generation "emerald rapids")
+	TDX TeeType = "tdx"
 )
 
 // DefaultRlimitValue is the value set by default for nofile and nproc
@@ -148,6 +150,12 @@ type ConfidentialWorkloadOptions struct {
 	DiskEncryptionPassphrase string
 	Slop                     string
 	FirmwareLibrary          string
+	Filesystem               string // "ext4" (default), "xfs", or "erofs"
+	Encryption               string // "luks1" (default), "luks2", or "none"
+	Cipher                   string // LUKS cipher, e.g., "aes-xts-plain64"
+	SectorSize               int    // LUKS2 payload sector size
+	Verity                   bool   // add a dm-verity hash tree to the disk image
+	DiskEncryptionKeyFile    string // use the contents of this file as the passphrase
 }
 
 // SBOMMergeStrategy tells us how to merge multiple SBOM documents into one.
@@ -186,6 +194,18 @@ type SBOMScanOptions struct {
 	MergeStrategy   SBOMMergeStrategy // how to merge the outputs of multiple scans
 }
 
+// RunFailurePolicy tells a build what to do when a RUN instruction fails.
+type RunFailurePolicy string
+
+const (
+	// RunFailureAbort stops the build, which is the default behavior.
+	RunFailureAbort RunFailurePolicy = "abort"
+	// RunFailureShell starts an interactive shell in the working container
+	// with the failed instruction's environment and mounts, and afterward
+	// offers to retry the instruction or to abort the build.
+	RunFailureShell RunFailurePolicy = "shell"
+)
+
 // TempDirForURL checks if the passed-in string looks like a URL or "-".  If it
 // is, TempDirForURL creates a temporary directory, arranges for its contents
 // to be the contents of that URL, and returns the temporary directory's path
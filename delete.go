@@ -5,6 +5,15 @@ import "fmt"
 // Delete removes the working container.  The buildah.Builder object should not
 // be used after this method is called.
 func (b *Builder) Delete() error {
+	runs, err := b.DetachedRuns()
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		if run.Running() {
+			return fmt.Errorf("deleting build container %q: detached run %q is still running", b.ContainerID, run.ID)
+		}
+	}
 	if err := b.store.DeleteContainer(b.ContainerID); err != nil {
 		return fmt.Errorf("deleting build container %q: %w", b.ContainerID, err)
 	}
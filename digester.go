@@ -2,6 +2,7 @@ package buildah
 
 import (
 	"archive/tar"
+	"bytes"
 	"errors"
 	"fmt"
 	"hash"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	digest "github.com/opencontainers/go-digest"
+	"go.podman.io/buildah/copier"
 )
 
 type digester interface {
@@ -180,6 +182,15 @@ func modifyTarHeaderForDigesting(hdr *tar.Header) (skip, replaceContents bool, r
 	hdr.ModTime = zeroTime
 	hdr.AccessTime = zeroTime
 	hdr.ChangeTime = zeroTime
+	// where the content came from doesn't matter, and if noting that was
+	// the only reason for the header to use PAX, let the writer choose a
+	// format the same way it would have if it hadn't been noted
+	if _, ok := hdr.PAXRecords[copier.CloneSourcePAXRecord]; ok {
+		delete(hdr.PAXRecords, copier.CloneSourcePAXRecord)
+		if len(hdr.PAXRecords) == 0 {
+			hdr.Format = tar.FormatUnknown
+		}
+	}
 	return false, false, nil
 }
 
@@ -213,6 +224,42 @@ func (t *tarDigester) Close() error {
 	return nil
 }
 
+// asyncWriter passes data to another writer in a separate goroutine, so that
+// whatever is writing to it doesn't have to wait for each chunk to be
+// digested before it can move on.  Errors are reported by Close().
+type asyncWriter struct {
+	chunks chan []byte
+	done   chan struct{}
+	err    error
+}
+
+func newAsyncWriter(writer io.Writer, depth int) *asyncWriter {
+	a := &asyncWriter{
+		chunks: make(chan []byte, depth),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(a.done)
+		for chunk := range a.chunks {
+			if a.err == nil {
+				_, a.err = writer.Write(chunk)
+			}
+		}
+	}()
+	return a
+}
+
+func (a *asyncWriter) Write(p []byte) (int, error) {
+	a.chunks <- bytes.Clone(p)
+	return len(p), nil
+}
+
+func (a *asyncWriter) Close() error {
+	close(a.chunks)
+	<-a.done
+	return a.err
+}
+
 // CompositeDigester can compute a digest over multiple items.
 type CompositeDigester struct {
 	digesters []digester
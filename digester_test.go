@@ -4,6 +4,8 @@ import (
 	"archive/tar"
 	"bytes"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -11,6 +13,7 @@ import (
 
 	digest "github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/require"
+	"go.podman.io/buildah/copier"
 )
 
 func (c *CompositeDigester) isOpen() bool {
@@ -306,3 +309,26 @@ func TestTarFilterer(t *testing.T) {
 		})
 	}
 }
+
+func TestTarDigesterIgnoresCloneSources(t *testing.T) {
+	t.Parallel()
+	contextDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(contextDir, "subdirectory"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "subdirectory", "file"), []byte("contents"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, strings.Repeat("long-name", 20)), []byte("more contents"), 0o644))
+	require.NoError(t, copier.Lsetxattrs(filepath.Join(contextDir, "subdirectory", "file"), map[string]string{"user.note": "value"}))
+
+	digestArchive := func(noteSources bool) digest.Digest {
+		var archive bytes.Buffer
+		require.NoError(t, copier.Get(contextDir, contextDir, copier.GetOptions{NoteSources: noteSources}, []string{"."}, &archive))
+		if noteSources {
+			require.Contains(t, archive.String(), copier.CloneSourcePAXRecord)
+		}
+		digester := newTarDigester("dir")
+		_, err := io.Copy(digester, &archive)
+		require.NoError(t, err)
+		require.NoError(t, digester.Close())
+		return digester.Digest()
+	}
+	require.Equal(t, digestArchive(false), digestArchive(true))
+}
@@ -138,6 +138,7 @@ type containerImageRef struct {
 	overrideConfig        *manifest.Schema2Config
 	extraImageContent     map[string]string
 	compatSetParent       types.OptionalBool
+	compatDockerConfig    types.OptionalBool
 	layerExclusions       []copier.ConditionalRemovePath
 	layerMountTargets     []copier.ConditionalRemovePath
 	layerPullUps          []copier.EnsureParentPath
@@ -244,6 +245,12 @@ func (i *containerImageRef) extractConfidentialWorkloadFS(options ConfidentialWo
 		FirmwareLibrary:          options.FirmwareLibrary,
 		GraphOptions:             i.store.GraphOptions(),
 		ExtraImageContent:        i.extraImageContent,
+		Filesystem:               options.Filesystem,
+		Encryption:               options.Encryption,
+		Cipher:                   options.Cipher,
+		SectorSize:               options.SectorSize,
+		Verity:                   options.Verity,
+		DiskEncryptionKeyFile:    options.DiskEncryptionKeyFile,
 	}
 	rc, _, err := mkcw.Archive(mountPoint, &image, archiveOptions)
 	if err != nil {
@@ -587,6 +594,76 @@ type ociManifestBuilder struct {
 	layerMediaType string
 	oimage         v1.Image
 	omanifest      v1.Manifest
+	dockerConfig   *dockerConfigExtension
+}
+
+// dockerConfigExtension holds settings from a Docker image configuration which
+// have no equivalents in an OCI image configuration.  When we're asked to, we
+// record them in OCI image configurations using the names that they have in
+// Docker's format, as Docker itself does for Healthcheck, OnBuild, and Shell.
+type dockerConfigExtension struct {
+	Domainname  string               `json:",omitempty"`
+	Healthcheck *docker.HealthConfig `json:",omitempty"`
+	OnBuild     []string             `json:",omitempty"`
+	StopTimeout *int                 `json:",omitempty"`
+	Shell       []string             `json:",omitempty"`
+}
+
+// ociImageWithDockerConfig is an OCI image configuration with a
+// dockerConfigExtension mixed into its "config" object.
+type ociImageWithDockerConfig struct {
+	v1.Image
+	Config struct {
+		v1.ImageConfig
+		dockerConfigExtension
+	} `json:"config,omitempty"`
+}
+
+// newDockerConfigExtension returns the Docker-specific settings from config, or
+// nil if there aren't any.
+func newDockerConfigExtension(config *docker.Config) *dockerConfigExtension {
+	if config == nil {
+		return nil
+	}
+	e := &dockerConfigExtension{
+		Domainname:  config.Domainname,
+		Healthcheck: config.Healthcheck,
+		OnBuild:     config.OnBuild,
+		StopTimeout: config.StopTimeout,
+		Shell:       config.Shell,
+	}
+	if e.empty() {
+		return nil
+	}
+	return e
+}
+
+// empty returns true if e doesn't hold any settings.
+func (e *dockerConfigExtension) empty() bool {
+	return e.Domainname == "" && e.Healthcheck == nil && len(e.OnBuild) == 0 && e.StopTimeout == nil && len(e.Shell) == 0
+}
+
+// restore copies the settings in e to config, unless config already has
+// values for them.
+func (e *dockerConfigExtension) restore(config *docker.Config) {
+	if config.Domainname == "" {
+		config.Domainname = e.Domainname
+	}
+	if config.Healthcheck == nil && e.Healthcheck != nil {
+		healthcheck := *e.Healthcheck
+		healthcheck.Test = slices.Clone(e.Healthcheck.Test)
+		config.Healthcheck = &healthcheck
+	}
+	if len(config.OnBuild) == 0 && len(e.OnBuild) > 0 {
+		config.OnBuild = slices.Clone(e.OnBuild)
+	}
+	if config.StopTimeout == nil && e.StopTimeout != nil {
+		stopTimeout := *e.StopTimeout
+		config.StopTimeout = &stopTimeout
+	}
+	if len(config.Shell) == 0 && len(e.Shell) > 0 {
+		config.Shell = slices.Clone(e.Shell)
+	}
 }
 
 // Build fresh copies of the container configuration structures so that we can edit them
@@ -630,6 +707,31 @@ func (i *containerImageRef) newOCIManifestBuilder() (manifestBuilder, error) {
 		oimage.Config.ExposedPorts = nil
 	}
 
+	// If we were asked to, carry the settings which only Docker's format
+	// has a place for, after making the same changes to them that we
+	// would make when writing an image in Docker format.
+	var dockerConfig *dockerConfigExtension
+	if i.compatDockerConfig == types.OptionalBoolTrue {
+		dimage := docker.V2Image{}
+		if err := json.Unmarshal(i.dconfig, &dimage); err != nil {
+			return nil, err
+		}
+		if dimage.Config == nil {
+			dimage.Config = &docker.Config{}
+		}
+		if i.created != nil {
+			dimage.Config.Domainname = ""
+		}
+		if err := config.OverrideDocker(dimage.Config, i.overrideChanges, i.overrideConfig); err != nil {
+			return nil, fmt.Errorf("applying changes: %w", err)
+		}
+		if i.confidentialWorkload.Convert {
+			dimage.Config.Healthcheck = nil
+			dimage.Config.Shell = nil
+		}
+		dockerConfig = newDockerConfigExtension(dimage.Config)
+	}
+
 	// Return partial manifest.  The Layers lists will be populated later.
 	annotations := make(map[string]string)
 	maps.Copy(annotations, i.annotations)
@@ -664,6 +766,7 @@ func (i *containerImageRef) newOCIManifestBuilder() (manifestBuilder, error) {
 			Layers:      []v1.Descriptor{},
 			Annotations: annotations,
 		},
+		dockerConfig: dockerConfig,
 	}, nil
 }
 
@@ -797,8 +900,18 @@ func (mb *ociManifestBuilder) buildHistory(extraImageContentDiff string, extraIm
 }
 
 func (mb *ociManifestBuilder) manifestAndConfig() ([]byte, []byte, error) {
-	// Encode the image configuration blob.
-	oconfig, err := json.Marshal(&mb.oimage)
+	// Encode the image configuration blob, with any Docker-specific
+	// settings mixed in.
+	var oconfig []byte
+	var err error
+	if mb.dockerConfig != nil {
+		extended := ociImageWithDockerConfig{Image: mb.oimage}
+		extended.Config.ImageConfig = mb.oimage.Config
+		extended.Config.dockerConfigExtension = *mb.dockerConfig
+		oconfig, err = json.Marshal(&extended)
+	} else {
+		oconfig, err = json.Marshal(&mb.oimage)
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("encoding %#v as json: %w", mb.oimage, err)
 	}
@@ -1776,6 +1889,7 @@ func (b *Builder) makeContainerImageRef(options CommitOptions) (*containerImageR
 		overrideConfig:        options.OverrideConfig,
 		extraImageContent:     maps.Clone(options.ExtraImageContent),
 		compatSetParent:       options.CompatSetParent,
+		compatDockerConfig:    options.CompatDockerConfig,
 		layerExclusions:       layerExclusions,
 		layerMountTargets:     layerMountTargets,
 		layerPullUps:          layerPullUps,
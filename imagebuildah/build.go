@@ -30,6 +30,7 @@ import (
 	"go.podman.io/buildah/define"
 	"go.podman.io/buildah/internal"
 	internalUtil "go.podman.io/buildah/internal/util"
+	"go.podman.io/buildah/pkg/frontend"
 	"go.podman.io/buildah/pkg/parse"
 	"go.podman.io/buildah/util"
 	"go.podman.io/common/libimage"
@@ -196,6 +197,18 @@ func BuildDockerfiles(ctx context.Context, store storage.Store, options define.B
 		}
 	}
 
+	switch options.PrintGraph {
+	case "", StageGraphFormatDot, StageGraphFormatJSON:
+	default:
+		return "", nil, fmt.Errorf("unknown stage graph format %q (expected %q or %q)", options.PrintGraph, StageGraphFormatDot, StageGraphFormatJSON)
+	}
+	if options.PrintGraph != "" && options.Manifest != "" {
+		return "", nil, errors.New("printing the stage graph and adding the image to a manifest list are mutually exclusive")
+	}
+	if options.PrintGraph != "" && options.SourceImage != "" {
+		return "", nil, errors.New("printing the stage graph and pushing a source image are mutually exclusive")
+	}
+
 	manifestList := options.Manifest
 	options.Manifest = ""
 	type instance struct {
@@ -222,12 +235,26 @@ func BuildDockerfiles(ctx context.Context, store storage.Store, options define.B
 	}
 
 	if options.AllPlatforms {
-		options.Platforms, err = platformsForBaseImages(ctx, logger, paths, files, options.From, options.Args, options.AdditionalBuildContexts, options.SystemContext)
+		options.Platforms, err = platformsForBaseImages(ctx, logger, options.Frontend, paths, files, options.From, options.Args, options.AdditionalBuildContexts, options.SystemContext)
 		if err != nil {
 			return "", nil, err
 		}
 	}
 
+	if options.SourceImage != "" && len(options.Platforms) > 1 {
+		return "", nil, fmt.Errorf("building multiple images, but source image %q can only describe one image", options.SourceImage)
+	}
+	if len(options.SourceImagePackages) > 0 {
+		if options.SourceImage == "" {
+			return "", nil, errors.New("source packages can only be added to a source image")
+		}
+		if !slices.ContainsFunc(options.SBOMScanOptions, func(scan define.SBOMScanOptions) bool {
+			return scan.PURLOutput != "" || scan.ImagePURLOutput != ""
+		}) {
+			return "", nil, errors.New("finding source packages requires SBOM scanning which saves a list of package URLs")
+		}
+	}
+
 	if sourceDateEpoch, ok := options.Args[internal.SourceDateEpochName]; ok && options.SourceDateEpoch == nil {
 		sde, err := strconv.ParseInt(sourceDateEpoch, 10, 64)
 		if err != nil {
@@ -272,9 +299,9 @@ func BuildDockerfiles(ctx context.Context, store storage.Store, options define.B
 		platformOptions.SystemContext = &platformContext
 		platformOptions.OS = platformContext.OSChoice
 		platformOptions.Architecture = platformContext.ArchitectureChoice
-		logPrefix := ""
+		logPrefix := options.LogPrefix
 		if len(options.Platforms) > 1 {
-			logPrefix = "[" + platforms.Format(platformSpec) + "] "
+			logPrefix += "[" + platforms.Format(platformSpec) + "] "
 		}
 		// Deep copy args to prevent concurrent read/writes over Args.
 		platformOptions.Args = maps.Clone(options.Args)
@@ -425,9 +452,9 @@ func BuildDockerfiles(ctx context.Context, store storage.Store, options define.B
 }
 
 func buildDockerfilesOnce(ctx context.Context, store storage.Store, logger *logrus.Logger, logPrefix string, options define.BuildOptions, containerFiles []string, dockerfilecontents [][]byte, processLabel, mountLabel string, usingContextOverlay bool) (string, reference.Canonical, error) {
-	mainNode, err := imagebuilder.ParseDockerfile(bytes.NewReader(dockerfilecontents[0]))
+	mainNode, err := frontend.Parse(options.Frontend, containerFiles, dockerfilecontents)
 	if err != nil {
-		return "", nil, fmt.Errorf("parsing main Dockerfile: %s: %w", containerFiles[0], err)
+		return "", nil, err
 	}
 
 	// --platform was explicitly selected for this build
@@ -456,19 +483,11 @@ func buildDockerfilesOnce(ctx context.Context, store storage.Store, logger *logr
 	}
 	delete(options.Args, "TARGETPLATFORM")
 
-	for i, d := range dockerfilecontents[1:] {
-		additionalNode, err := imagebuilder.ParseDockerfile(bytes.NewReader(d))
-		if err != nil {
-			containerFiles := containerFiles[1:]
-			return "", nil, fmt.Errorf("parsing additional Dockerfile %s: %w", containerFiles[i], err)
-		}
-		mainNode.Children = append(mainNode.Children, additionalNode.Children...)
-	}
-
 	exec, err := newExecutor(logger, logPrefix, store, options, mainNode, containerFiles, processLabel, mountLabel, usingContextOverlay)
 	if err != nil {
 		return "", nil, fmt.Errorf("creating build executor: %w", err)
 	}
+	defer exec.removeRemoteSources()
 	b := imagebuilder.NewBuilder(options.Args)
 	maps.Copy(b.BuiltinArgDefaults, builtinArgDefaults)
 
@@ -488,7 +507,14 @@ func buildDockerfilesOnce(ctx context.Context, store storage.Store, logger *logr
 		}
 		stages = stagesTargeted
 	}
-	return exec.Build(ctx, stages)
+	imageID, ref, err := exec.Build(ctx, stages)
+	if err != nil || options.SourceImage == "" {
+		return imageID, ref, err
+	}
+	if err := exec.pushSourceImage(ctx, imageID, ref); err != nil {
+		return "", nil, err
+	}
+	return imageID, ref, nil
 }
 
 // preprocessContainerfileContents runs CPP(1) in preprocess-only mode on the input
@@ -557,8 +583,8 @@ func platformIsAcceptable(platform *v1.Platform, logger *logrus.Logger) bool {
 // platformsForBaseImages resolves the names of base images from the
 // dockerfiles, and if they are all valid references to manifest lists, returns
 // the list of platforms that are supported by all of the base images.
-func platformsForBaseImages(ctx context.Context, logger *logrus.Logger, dockerfilepaths []string, dockerfiles [][]byte, from string, args map[string]string, additionalBuildContext map[string]*define.AdditionalBuildContext, systemContext *types.SystemContext) ([]struct{ OS, Arch, Variant string }, error) {
-	baseImages, err := baseImages(dockerfilepaths, dockerfiles, from, args, additionalBuildContext)
+func platformsForBaseImages(ctx context.Context, logger *logrus.Logger, frontendName string, dockerfilepaths []string, dockerfiles [][]byte, from string, args map[string]string, additionalBuildContext map[string]*define.AdditionalBuildContext, systemContext *types.SystemContext) ([]struct{ OS, Arch, Variant string }, error) {
+	baseImages, err := baseImages(frontendName, dockerfilepaths, dockerfiles, from, args, additionalBuildContext)
 	if err != nil {
 		return nil, fmt.Errorf("determining list of base images: %w", err)
 	}
@@ -714,19 +740,10 @@ func platformsForBaseImages(ctx context.Context, logger *logrus.Logger, dockerfi
 // stage's base image with FROM, and returns the list of base images as
 // provided.  Each entry in the dockerfilenames slice corresponds to a slice in
 // dockerfilecontents.
-func baseImages(dockerfilenames []string, dockerfilecontents [][]byte, from string, args map[string]string, additionalBuildContext map[string]*define.AdditionalBuildContext) ([]string, error) {
-	mainNode, err := imagebuilder.ParseDockerfile(bytes.NewReader(dockerfilecontents[0]))
+func baseImages(frontendName string, dockerfilenames []string, dockerfilecontents [][]byte, from string, args map[string]string, additionalBuildContext map[string]*define.AdditionalBuildContext) ([]string, error) {
+	mainNode, err := frontend.Parse(frontendName, dockerfilenames, dockerfilecontents)
 	if err != nil {
-		return nil, fmt.Errorf("parsing main Dockerfile: %s: %w", dockerfilenames[0], err)
-	}
-
-	for i, d := range dockerfilecontents[1:] {
-		additionalNode, err := imagebuilder.ParseDockerfile(bytes.NewReader(d))
-		if err != nil {
-			dockerfilenames := dockerfilenames[1:]
-			return nil, fmt.Errorf("parsing additional Dockerfile %s: %w", dockerfilenames[i], err)
-		}
-		mainNode.Children = append(mainNode.Children, additionalNode.Children...)
+		return nil, err
 	}
 
 	b := imagebuilder.NewBuilder(args)
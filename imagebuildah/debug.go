@@ -0,0 +1,262 @@
+package imagebuildah
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/openshift/imagebuilder"
+	"github.com/openshift/imagebuilder/dockerfile/command"
+	"github.com/openshift/imagebuilder/dockerfile/parser"
+	"github.com/sirupsen/logrus"
+	"go.podman.io/buildah"
+	"go.podman.io/buildah/define"
+)
+
+// errDebugAbort is returned when a debugger tells us to stop the build.
+var errDebugAbort = errors.New("build aborted by debugger")
+
+// breakpoint is a parsed BuildOptions.BreakAt entry.  Exactly one of stage,
+// line, or prefix is set.
+type breakpoint struct {
+	spec   string
+	stage  string
+	line   int
+	prefix string
+}
+
+// parseBreakpoints parses the BuildOptions.BreakAt list.
+func parseBreakpoints(specs []string) ([]breakpoint, error) {
+	var breakpoints []breakpoint
+	for _, spec := range specs {
+		bp := breakpoint{spec: spec}
+		switch {
+		case strings.HasPrefix(spec, "stage:"):
+			bp.stage = strings.TrimPrefix(spec, "stage:")
+			if bp.stage == "" {
+				return nil, fmt.Errorf("breakpoint %q: missing stage name or index", spec)
+			}
+		case strings.HasPrefix(spec, "line:"):
+			line, err := strconv.Atoi(strings.TrimPrefix(spec, "line:"))
+			if err != nil || line < 1 {
+				return nil, fmt.Errorf("breakpoint %q: invalid line number", spec)
+			}
+			bp.line = line
+		default:
+			bp.prefix = strings.TrimSpace(spec)
+			if bp.prefix == "" {
+				return nil, fmt.Errorf("breakpoint %q: empty instruction", spec)
+			}
+		}
+		breakpoints = append(breakpoints, bp)
+	}
+	return breakpoints, nil
+}
+
+// matches returns true if the breakpoint applies to the instruction at index
+// i in the stage.
+func (bp breakpoint) matches(stage *imagebuilder.Stage, i int, node *parser.Node) bool {
+	switch {
+	case bp.stage != "":
+		return i == 0 && (bp.stage == stage.Name || bp.stage == strconv.Itoa(stage.Position))
+	case bp.line != 0:
+		return node.StartLine <= bp.line && bp.line <= node.EndLine
+	default:
+		return len(node.Original) >= len(bp.prefix) && strings.EqualFold(node.Original[:len(bp.prefix)], bp.prefix)
+	}
+}
+
+// debugPause consults the debugger, if we have one, before the instruction
+// at index i in the stage is processed.  It returns true if the instruction
+// should be skipped.
+func (s *stageExecutor) debugPause(ctx context.Context, i int, node *parser.Node, step *imagebuilder.Step, cache func() string) (bool, error) {
+	if s.executor.debugger == nil {
+		return false, nil
+	}
+	s.executor.interactiveLock.Lock()
+	defer s.executor.interactiveLock.Unlock()
+
+	matched := ""
+	for _, bp := range s.executor.breakpoints {
+		if bp.matches(s.stage, i, node) {
+			matched = bp.spec
+			break
+		}
+	}
+	if matched == "" && !s.executor.debugStepping {
+		return false, nil
+	}
+
+	config := s.stage.Builder.Config()
+	resolved := strings.ToUpper(step.Command)
+	if len(step.Flags) > 0 {
+		resolved += " " + strings.Join(step.Flags, " ")
+	}
+	if len(step.Args) > 0 {
+		resolved += " " + strings.Join(step.Args, " ")
+	}
+	pause := define.DebugPause{
+		Stage:       s.stage.Position,
+		StageName:   s.stage.Name,
+		Step:        i + 1,
+		Steps:       len(s.stage.Node.Children),
+		Line:        node.StartLine,
+		Instruction: node.Original,
+		Resolved:    resolved,
+		Env:         step.Env,
+		WorkingDir:  config.WorkingDir,
+		User:        config.User,
+		Cache:       cache(),
+		ContainerID: s.builder.ContainerID,
+		Breakpoint:  matched,
+	}
+	for {
+		action, err := s.executor.debugger.Pause(ctx, pause)
+		if err != nil {
+			return false, fmt.Errorf("consulting debugger: %w", err)
+		}
+		switch action {
+		case define.DebugContinue:
+			s.executor.debugStepping = false
+			return false, nil
+		case define.DebugStep:
+			s.executor.debugStepping = true
+			return false, nil
+		case define.DebugSkip:
+			return true, nil
+		case define.DebugShell:
+			if err := s.debugShell(*config); err != nil {
+				// the exit status of the last command run in the shell isn't interesting
+				logrus.Debugf("debugging shell exited: %v", err)
+			}
+		case define.DebugAbort:
+			return false, errDebugAbort
+		default:
+			return false, fmt.Errorf("unrecognized debugger action %q", action)
+		}
+	}
+}
+
+// debugCacheDecision describes what the build cache has to offer for an
+// instruction, without changing anything.
+func (s *stageExecutor) debugCacheDecision(ctx context.Context, node *parser.Node, step *imagebuilder.Step, checkForLayers, lastInstruction, lastStage bool) string {
+	switch {
+	case !s.executor.layers:
+		return "not used, layers are disabled"
+	case !s.executor.useCache:
+		return "not used, caching is disabled"
+	case !checkForLayers:
+		return "not used, an earlier instruction in this stage was not cached"
+	case s.executor.squash && lastInstruction && lastStage:
+		return "not used for the final instruction of a squashed build"
+	case step.Command == command.Arg:
+		return "checked after the argument is set"
+	case step.Command == command.Add || step.Command == command.Copy:
+		return "checked after content is copied"
+	}
+	cacheID, err := s.intermediateImageExists(ctx, node, "", s.stepRequiresLayer(step), lastInstruction && lastStage)
+	if err != nil {
+		return fmt.Sprintf("error checking: %v", err)
+	}
+	if cacheID == "" {
+		return "miss"
+	}
+	return "hit " + cacheID
+}
+
+// debugShell starts an interactive shell in the working container, using the
+// stage's current configuration.
+func (s *stageExecutor) debugShell(config docker.Config) error {
+	shell := "/bin/sh"
+	if len(config.Shell) > 0 {
+		shell = config.Shell[0]
+	}
+	fmt.Fprintf(os.Stderr, "Starting %s in the working container, exit the shell to continue\n", shell)
+	options := buildah.RunOptions{
+		Args:             s.executor.runtimeArgs,
+		ContextDir:       s.executor.contextDir,
+		ConfigureNetwork: s.executor.configureNetwork,
+		Env:              config.Env,
+		Hostname:         config.Hostname,
+		Logger:           s.executor.logger,
+		Mounts:           slices.Clone(s.executor.transientMounts),
+		NamespaceOptions: slices.Clone(s.executor.namespaceOptions),
+		NoHostname:       s.executor.noHostname,
+		NoHosts:          s.executor.noHosts,
+		NoPivot:          os.Getenv("BUILDAH_NOPIVOT") != "" || s.executor.noPivotRoot,
+		Runtime:          s.executor.runtime,
+		Secrets:          s.executor.secrets,
+		SSHSources:       s.executor.sshsources,
+		Stderr:           os.Stderr,
+		Stdin:            os.Stdin,
+		Stdout:           os.Stdout,
+		SystemContext:    s.systemContext,
+		Terminal:         buildah.DefaultTerminal,
+		User:             config.User,
+		WorkingDir:       config.WorkingDir,
+	}
+	if config.NetworkDisabled {
+		options.ConfigureNetwork = buildah.NetworkDisabled
+	}
+	return s.builder.Run([]string{shell}, options)
+}
+
+// terminalDebugger is the BuildDebugger which we use if the caller asked for
+// breakpoints or stepping, but didn't supply a debugger.
+type terminalDebugger struct {
+	in  io.Reader
+	out io.Writer
+}
+
+func newTerminalDebugger(in io.Reader, out io.Writer) define.BuildDebugger {
+	return &terminalDebugger{in: in, out: out}
+}
+
+func (t *terminalDebugger) Pause(_ context.Context, pause define.DebugPause) (define.DebugAction, error) {
+	stage := strconv.Itoa(pause.Stage)
+	if pause.StageName != stage {
+		stage += " (" + pause.StageName + ")"
+	}
+	if pause.Breakpoint != "" {
+		fmt.Fprintf(t.out, "Breakpoint %q reached\n", pause.Breakpoint)
+	}
+	fmt.Fprintf(t.out, "Paused before step %d/%d of stage %s, line %d: %s\n", pause.Step, pause.Steps, stage, pause.Line, pause.Instruction)
+	fmt.Fprintf(t.out, "  Resolved:    %s\n", pause.Resolved)
+	fmt.Fprintf(t.out, "  Working dir: %s\n", pause.WorkingDir)
+	fmt.Fprintf(t.out, "  User:        %s\n", pause.User)
+	fmt.Fprintf(t.out, "  Cache:       %s\n", pause.Cache)
+	fmt.Fprintf(t.out, "  Container:   %s\n", pause.ContainerID)
+	fmt.Fprintf(t.out, "  Environment:\n")
+	for _, env := range pause.Env {
+		fmt.Fprintf(t.out, "    %s\n", env)
+	}
+	for {
+		fmt.Fprint(t.out, "[c]ontinue, [s]tep, s[k]ip, s[h]ell, or [a]bort? ")
+		answer, err := readAnswer(t.in)
+		if err != nil {
+			fmt.Fprintln(t.out)
+			if errors.Is(err, io.EOF) {
+				return define.DebugAbort, nil
+			}
+			return "", err
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "c", "continue":
+			return define.DebugContinue, nil
+		case "s", "step", "":
+			return define.DebugStep, nil
+		case "k", "skip":
+			return define.DebugSkip, nil
+		case "h", "shell":
+			return define.DebugShell, nil
+		case "a", "abort":
+			return define.DebugAbort, nil
+		}
+	}
+}
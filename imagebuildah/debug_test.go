@@ -0,0 +1,80 @@
+package imagebuildah
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openshift/imagebuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/buildah/define"
+)
+
+func TestBreakpoints(t *testing.T) {
+	t.Parallel()
+	for _, bad := range []string{"stage:", "line:", "line:0", "line:x", " "} {
+		_, err := parseBreakpoints([]string{bad})
+		assert.Errorf(t, err, "parsing breakpoint %q", bad)
+	}
+
+	node, err := imagebuilder.ParseDockerfile(strings.NewReader("FROM scratch AS builder\nRUN make \\\n  all\nCOPY . /src\n"))
+	require.NoError(t, err)
+	stages, err := imagebuilder.NewStages(node, imagebuilder.NewBuilder(nil))
+	require.NoError(t, err)
+	require.Len(t, stages, 1)
+	stage := &stages[0]
+	_, err = stage.Builder.From(stage.Node)
+	require.NoError(t, err)
+	children := stage.Node.Children
+	require.Len(t, children, 2)
+
+	testCases := []struct {
+		breakpoint string
+		matches    []bool
+	}{
+		{"stage:builder", []bool{true, false}},
+		{"stage:0", []bool{true, false}},
+		{"stage:1", []bool{false, false}},
+		{"line:3", []bool{true, false}},
+		{"line:4", []bool{false, true}},
+		{"run make", []bool{true, false}},
+		{"RUN make all", []bool{false, false}},
+		{"COPY", []bool{false, true}},
+	}
+	for _, testCase := range testCases {
+		breakpoints, err := parseBreakpoints([]string{testCase.breakpoint})
+		require.NoError(t, err)
+		require.Len(t, breakpoints, 1)
+		for i, child := range children {
+			assert.Equalf(t, testCase.matches[i], breakpoints[0].matches(stage, i, child), "breakpoint %q and instruction %q", testCase.breakpoint, child.Original)
+		}
+	}
+}
+
+func TestTerminalDebugger(t *testing.T) {
+	t.Parallel()
+	var out bytes.Buffer
+	debugger := newTerminalDebugger(strings.NewReader("what\nk\nshell\n\nc\n"), &out)
+	pause := define.DebugPause{
+		Stage:       0,
+		StageName:   "builder",
+		Step:        1,
+		Steps:       2,
+		Line:        2,
+		Instruction: "RUN make $TARGET",
+		Resolved:    "RUN make all",
+		Env:         []string{"TARGET=all"},
+		Breakpoint:  "RUN make",
+	}
+	for _, expected := range []define.DebugAction{define.DebugSkip, define.DebugShell, define.DebugStep, define.DebugContinue, define.DebugAbort} {
+		action, err := debugger.Pause(context.Background(), pause)
+		require.NoError(t, err)
+		assert.Equal(t, expected, action)
+	}
+	assert.Contains(t, out.String(), `Breakpoint "RUN make" reached`)
+	assert.Contains(t, out.String(), "stage 0 (builder), line 2: RUN make $TARGET")
+	assert.Contains(t, out.String(), "RUN make all")
+	assert.Contains(t, out.String(), "TARGET=all")
+}
@@ -34,6 +34,7 @@ import (
 	"go.podman.io/image/v5/docker/reference"
 	"go.podman.io/image/v5/manifest"
 	"go.podman.io/image/v5/pkg/compression"
+	"go.podman.io/image/v5/signature/signer"
 	storageTransport "go.podman.io/image/v5/storage"
 	"go.podman.io/image/v5/transports"
 	"go.podman.io/image/v5/transports/alltransports"
@@ -142,6 +143,9 @@ type executor struct {
 	devices                                 define.ContainerDevices
 	deviceSpecs                             []string
 	signBy                                  string
+	signBySigstorePrivateKeyFile            string
+	signSigstorePrivateKeyPassphrase        []byte
+	signers                                 []*signer.Signer
 	architecture                            string
 	timestamp                               *time.Time
 	os                                      string
@@ -157,6 +161,11 @@ type executor struct {
 	stagesLock                              sync.Mutex
 	stagesSemaphore                         *semaphore.Weighted
 	logRusage                               bool
+	onRunFailure                            define.RunFailurePolicy
+	interactiveLock                         sync.Mutex
+	breakpoints                             []breakpoint
+	debugger                                define.BuildDebugger
+	debugStepping                           bool
 	rusageLogFile                           io.Writer
 	imageInfoLock                           sync.Mutex
 	imageInfoCache                          map[string]imageTypeAndHistoryAndDiffIDs
@@ -166,6 +175,7 @@ type executor struct {
 	secrets                                 map[string]define.Secret
 	sshsources                              map[string]*sshagent.Source
 	logPrefix                               string
+	printGraph                              string
 	unsetEnvs                               []string
 	unsetLabels                             []string
 	unsetAnnotations                        []string
@@ -180,13 +190,22 @@ type executor struct {
 	cdiConfigDir                            string
 	compatSetParent                         types.OptionalBool
 	compatVolumes                           types.OptionalBool
+	compatDockerConfig                      types.OptionalBool
 	compatScratchConfig                     types.OptionalBool
 	compatLayerOmissions                    types.OptionalBool
 	noPivotRoot                             bool
+	noClone                                 bool
+	hardlinkContext                         bool
 	sourceDateEpoch                         *time.Time
 	rewriteTimestamp                        bool
 	createdAnnotation                       types.OptionalBool
 	metadataFile                            string
+	sourceImage                             string
+	sourceImagePackages                     []string
+	remoteSources                           []remoteSource // content which ADD instructions fetched, for the source image
+	remoteSourcesDir                        string
+	remoteSourcesLock                       sync.Mutex
+	stepLocker                              define.StepLocker
 }
 
 type imageTypeAndHistoryAndDiffIDs struct {
@@ -245,6 +264,21 @@ func newExecutor(logger *logrus.Logger, logPrefix string, store storage.Store, o
 		}
 	}
 
+	switch options.OnRunFailure {
+	case "", define.RunFailureAbort, define.RunFailureShell:
+	default:
+		return nil, fmt.Errorf("unrecognized policy %q for RUN failures", options.OnRunFailure)
+	}
+
+	breakpoints, err := parseBreakpoints(options.BreakAt)
+	if err != nil {
+		return nil, err
+	}
+	debugger := options.Debugger
+	if debugger == nil && (len(breakpoints) > 0 || options.Step) {
+		debugger = newTerminalDebugger(os.Stdin, os.Stderr)
+	}
+
 	writer := options.ReportWriter
 	if options.Quiet {
 		writer = io.Discard
@@ -342,6 +376,9 @@ func newExecutor(logger *logrus.Logger, logPrefix string, store storage.Store, o
 		capabilities:                            capabilities,
 		deviceSpecs:                             options.Devices,
 		signBy:                                  options.SignBy,
+		signBySigstorePrivateKeyFile:            options.SignBySigstorePrivateKeyFile,
+		signSigstorePrivateKeyPassphrase:        options.SignSigstorePrivateKeyPassphrase,
+		signers:                                 options.Signers,
 		architecture:                            options.Architecture,
 		timestamp:                               options.Timestamp,
 		os:                                      options.OS,
@@ -355,6 +392,12 @@ func newExecutor(logger *logrus.Logger, logPrefix string, store storage.Store, o
 		terminatedStage:                         make(map[string]error),
 		stagesSemaphore:                         options.JobSemaphore,
 		logRusage:                               options.LogRusage,
+		onRunFailure:                            options.OnRunFailure,
+		breakpoints:                             breakpoints,
+		debugger:                                debugger,
+		debugStepping:                           options.Step,
+		sourceImage:                             options.SourceImage,
+		sourceImagePackages:                     slices.Clone(options.SourceImagePackages),
 		rusageLogFile:                           rusageLogFile,
 		imageInfoCache:                          make(map[string]imageTypeAndHistoryAndDiffIDs),
 		fromOverride:                            options.From,
@@ -363,6 +406,7 @@ func newExecutor(logger *logrus.Logger, logPrefix string, store storage.Store, o
 		secrets:                                 secrets,
 		sshsources:                              sshsources,
 		logPrefix:                               logPrefix,
+		printGraph:                              options.PrintGraph,
 		unsetEnvs:                               slices.Clone(options.UnsetEnvs),
 		unsetLabels:                             slices.Clone(options.UnsetLabels),
 		unsetAnnotations:                        slices.Clone(options.UnsetAnnotations),
@@ -375,13 +419,17 @@ func newExecutor(logger *logrus.Logger, logPrefix string, store storage.Store, o
 		cdiConfigDir:                            options.CDIConfigDir,
 		compatSetParent:                         options.CompatSetParent,
 		compatVolumes:                           options.CompatVolumes,
+		compatDockerConfig:                      options.CompatDockerConfig,
 		compatScratchConfig:                     options.CompatScratchConfig,
 		compatLayerOmissions:                    options.CompatLayerOmissions,
 		noPivotRoot:                             options.NoPivotRoot,
+		noClone:                                 options.NoClone,
+		hardlinkContext:                         options.HardlinkContext,
 		sourceDateEpoch:                         options.SourceDateEpoch,
 		rewriteTimestamp:                        options.RewriteTimestamp,
 		createdAnnotation:                       options.CreatedAnnotation,
 		metadataFile:                            options.MetadataFile,
+		stepLocker:                              options.StepLocker,
 	}
 	// sort unsetAnnotations because we will later write these
 	// values to the history of the image therefore we want to
@@ -710,17 +758,38 @@ func (b *executor) buildStage(ctx context.Context, cleanupStages map[int]*stageE
 type stageDependencyInfo struct {
 	Name           string
 	Position       int
-	Needs          []string
+	Base           string
+	Needs          []stageNeed
 	NeededByTarget bool
 }
 
+// stageNeed records that a stage needs another stage, and why.
+type stageNeed struct {
+	Stage string
+	Kind  string // one of the stageNeed* constants
+}
+
+const (
+	stageNeedFrom  = "from"  // FROM uses the stage as its base
+	stageNeedCopy  = "copy"  // ADD or COPY uses --from to read from the stage
+	stageNeedMount = "mount" // RUN uses --mount with from= to mount the stage
+	stageNeedAfter = "after" // FROM uses --after to wait for the stage
+)
+
+// addNeed records that the stage needs another stage, unless it is itself.
+func (s *stageDependencyInfo) addNeed(stage, kind string) {
+	if stage != s.Name {
+		s.Needs = append(s.Needs, stageNeed{Stage: stage, Kind: kind})
+	}
+}
+
 // Marks `NeededByTarget` as true for the given stage and all its dependency stages as true recursively.
 func markDependencyStagesForTarget(dependencyMap map[string]*stageDependencyInfo, stage string) {
 	if stageDependencyInfo, ok := dependencyMap[stage]; ok {
 		if !stageDependencyInfo.NeededByTarget {
 			stageDependencyInfo.NeededByTarget = true
 			for _, need := range stageDependencyInfo.Needs {
-				markDependencyStagesForTarget(dependencyMap, need)
+				markDependencyStagesForTarget(dependencyMap, need.Stage)
 			}
 		}
 	}
@@ -902,6 +971,7 @@ func (b *executor) Build(ctx context.Context, stages imagebuilder.Stages) (image
 							b.fromOverride = ""
 						}
 						base := child.Next.Value
+						dependencyMap[stage.Name].Base = base
 						if base != "" && base != buildah.BaseImageFakeName {
 							if replaceBuildContext, ok := b.additionalBuildContexts[child.Next.Value]; ok {
 								if replaceBuildContext.IsImage {
@@ -921,6 +991,7 @@ func (b *executor) Build(ctx context.Context, stages imagebuilder.Stages) (image
 								return "", nil, fmt.Errorf("while replacing arg variables with values for format %q: %w", base, err)
 							}
 							b.baseMap[baseWithArg] = struct{}{}
+							dependencyMap[stage.Name].Base = baseWithArg
 							logrus.Debugf("base for stage %d: %q resolves to %q", stageIndex, base, baseWithArg)
 							// Check if selected base is not an additional
 							// build context and if base is a valid stage
@@ -929,7 +1000,7 @@ func (b *executor) Build(ctx context.Context, stages imagebuilder.Stages) (image
 								if _, ok := dependencyMap[baseWithArg]; ok {
 									// update current stage's dependency info
 									currentStageInfo := dependencyMap[stage.Name]
-									currentStageInfo.Needs = append(currentStageInfo.Needs, baseWithArg)
+									currentStageInfo.addNeed(baseWithArg, stageNeedFrom)
 								}
 							}
 						}
@@ -965,7 +1036,7 @@ func (b *executor) Build(ctx context.Context, stages imagebuilder.Stages) (image
 							}
 							// Mark the stage as a dep so we actually build it
 							currentStageInfo := dependencyMap[stage.Name]
-							currentStageInfo.Needs = append(currentStageInfo.Needs, afterResolved)
+							currentStageInfo.addNeed(afterResolved, stageNeedAfter)
 							// And mark it on the stage executor itself so it knows to wait before even pulling
 							b.afterDependency[stage.Name] = afterResolved
 							logrus.Debugf("stage %d: explicit dependency on %q via --after", stageIndex, afterResolved)
@@ -1005,7 +1076,7 @@ func (b *executor) Build(ctx context.Context, stages imagebuilder.Stages) (image
 								if _, ok := dependencyMap[stageName]; ok {
 									// update current stage's dependency info
 									currentStageInfo := dependencyMap[stage.Name]
-									currentStageInfo.Needs = append(currentStageInfo.Needs, stageName)
+									currentStageInfo.addNeed(stageName, stageNeedCopy)
 								}
 							}
 						}
@@ -1041,7 +1112,7 @@ func (b *executor) Build(ctx context.Context, stages imagebuilder.Stages) (image
 										if _, ok := dependencyMap[mountFrom]; ok {
 											// update current stage's dependency info
 											currentStageInfo := dependencyMap[stage.Name]
-											currentStageInfo.Needs = append(currentStageInfo.Needs, mountFrom)
+											currentStageInfo.addNeed(mountFrom, stageNeedMount)
 										}
 									}
 								}
@@ -1062,6 +1133,17 @@ func (b *executor) Build(ctx context.Context, stages imagebuilder.Stages) (image
 	}
 	b.warnOnUnsetBuildArgs(stages, dependencyMap, b.args)
 
+	graph := newStageGraph(stages, dependencyMap)
+	if b.printGraph != "" {
+		if err := graph.write(stdout, b.printGraph); err != nil {
+			return "", nil, err
+		}
+		return "", nil, nil
+	}
+	needs := graph.needs()
+	buildStart := time.Now()
+	timings := make([]stageTiming, len(stages))
+
 	type Result struct {
 		Index         int
 		ImageID       string
@@ -1079,14 +1161,46 @@ func (b *executor) Build(ctx context.Context, stages imagebuilder.Stages) (image
 	var wg sync.WaitGroup
 	wg.Add(len(stages))
 
+	// finished receives the index of each stage after it has been built,
+	// skipped, or canceled.
+	finished := make(chan int, len(stages))
+
 	var commitResults buildah.CommitResults
 	go func() {
 		cancel := false
-		for stageIndex := range stages {
-			index := stageIndex
-			// Acquire the semaphore before creating the goroutine so we are sure they
-			// run in the specified order.
-			if err := b.stagesSemaphore.Acquire(ctx, 1); err != nil {
+		started := make([]bool, len(stages))
+		done := make([]bool, len(stages))
+		// nextStage returns the index of the first stage which hasn't
+		// been started and whose dependencies have all finished, or -1.
+		nextStage := func() int {
+			for index := range stages {
+				if !started[index] && !slices.ContainsFunc(needs[index], func(need int) bool { return !done[need] }) {
+					return index
+				}
+			}
+			return -1
+		}
+		for range stages {
+			// Wait until a stage is ready to start, so that stages
+			// which are waiting for their dependencies don't hold
+			// job slots which ready stages could use.
+			for nextStage() == -1 {
+				done[<-finished] = true
+			}
+			err := b.stagesSemaphore.Acquire(ctx, 1)
+			// Stages may have finished while we waited for a slot,
+			// possibly readying stages which come earlier.
+			for drained := false; !drained; {
+				select {
+				case finishedIndex := <-finished:
+					done[finishedIndex] = true
+				default:
+					drained = true
+				}
+			}
+			index := nextStage()
+			started[index] = true
+			if err != nil {
 				cancel = true
 				b.lastError = err
 				ch <- Result{
@@ -1094,12 +1208,14 @@ func (b *executor) Build(ctx context.Context, stages imagebuilder.Stages) (image
 					Error: err,
 				}
 				wg.Done()
+				finished <- index
 				continue
 			}
 			b.stagesLock.Lock()
 			cleanupStages := cleanupStages
 			b.stagesLock.Unlock()
 			go func() {
+				defer func() { finished <- index }()
 				defer b.stagesSemaphore.Release(1)
 				defer wg.Done()
 				if cancel || cleanupStages == nil {
@@ -1121,6 +1237,7 @@ func (b *executor) Build(ctx context.Context, stages imagebuilder.Stages) (image
 				if stageDependencyInfo, ok := dependencyMap[stages[index].Name]; ok {
 					if !stageDependencyInfo.NeededByTarget && b.skipUnusedStages != types.OptionalBoolFalse {
 						logrus.Debugf("Skipping stage with Name %q and index %d since its not needed by the target stage", stages[index].Name, index)
+						timings[index].skipped = true
 						ch <- Result{
 							Index: index,
 							Error: nil,
@@ -1128,7 +1245,9 @@ func (b *executor) Build(ctx context.Context, stages imagebuilder.Stages) (image
 						return
 					}
 				}
+				timings[index].started = time.Now()
 				stageID, stageResults, stageOnlyBaseImage, stageErr := b.buildStage(ctx, cleanupStages, stages, index)
+				timings[index].finished = time.Now()
 				if stageErr != nil {
 					cancel = true
 					ch <- Result{
@@ -1190,6 +1309,18 @@ func (b *executor) Build(ctx context.Context, stages imagebuilder.Stages) (image
 		b.stagesLock.Unlock()
 	}
 
+	built := 0
+	for _, timing := range timings {
+		if !timing.started.IsZero() {
+			built++
+		}
+	}
+	if built > 1 {
+		if err := writeStageTimeline(b.out, b.logPrefix, stages, timings, buildStart); err != nil {
+			return "", nil, fmt.Errorf("writing stage timeline: %w", err)
+		}
+	}
+
 	if len(b.unusedArgs) > 0 {
 		unusedList := make([]string, 0, len(b.unusedArgs))
 		for k := range b.unusedArgs {
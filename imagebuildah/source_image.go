@@ -0,0 +1,202 @@
+package imagebuildah
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	digest "github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"go.podman.io/buildah/internal/sbom"
+	"go.podman.io/buildah/internal/source"
+	"go.podman.io/buildah/internal/tmpdir"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// remoteSource is a copy of content which an ADD instruction fetched.
+type remoteSource struct {
+	src string
+	dir string
+}
+
+// saveRemoteSource is used as the SaveRemoteContent callback for ADD
+// instructions.  It returns the location where a copy of the content which is
+// fetched from src should be kept, so that it can be included in the source
+// image, or "" if we aren't building a source image or already have a copy.
+func (b *executor) saveRemoteSource(src string) (string, error) {
+	if b.sourceImage == "" {
+		return "", nil
+	}
+	b.remoteSourcesLock.Lock()
+	defer b.remoteSourcesLock.Unlock()
+	if slices.ContainsFunc(b.remoteSources, func(r remoteSource) bool { return r.src == src }) {
+		return "", nil
+	}
+	if b.remoteSourcesDir == "" {
+		dir, err := os.MkdirTemp(tmpdir.GetTempDir(), "buildah-remote-sources")
+		if err != nil {
+			return "", fmt.Errorf("creating directory for copies of remote content: %w", err)
+		}
+		b.remoteSourcesDir = dir
+	}
+	dir := filepath.Join(b.remoteSourcesDir, strconv.Itoa(len(b.remoteSources)))
+	b.remoteSources = append(b.remoteSources, remoteSource{src: src, dir: dir})
+	return dir, nil
+}
+
+// removeRemoteSources removes the copies of remote content which
+// saveRemoteSource arranged to keep.
+func (b *executor) removeRemoteSources() {
+	b.remoteSourcesLock.Lock()
+	defer b.remoteSourcesLock.Unlock()
+	if b.remoteSourcesDir != "" {
+		if err := os.RemoveAll(b.remoteSourcesDir); err != nil {
+			logrus.Warnf("removing copies of remote content: %v", err)
+		}
+		b.remoteSourcesDir = ""
+	}
+	b.remoteSources = nil
+}
+
+// scannedPURLs reads the package URLs which SBOM scanning saved to local files
+// or to the image that we just built.
+func (b *executor) scannedPURLs(imageID string) ([]string, error) {
+	var purls []string
+	mountPoint := ""
+	defer func() {
+		if mountPoint != "" {
+			if _, err := b.store.UnmountImage(imageID, false); err != nil {
+				logrus.Warnf("unmounting image %q: %v", imageID, err)
+			}
+		}
+	}()
+	for _, scan := range b.sbomScanOptions {
+		path := scan.PURLOutput
+		if path == "" && scan.ImagePURLOutput != "" {
+			if mountPoint == "" {
+				mounted, err := b.store.MountImage(imageID, nil, "")
+				if err != nil {
+					return nil, fmt.Errorf("mounting image %q: %w", imageID, err)
+				}
+				mountPoint = mounted
+			}
+			resolved, err := securejoin.SecureJoin(mountPoint, scan.ImagePURLOutput)
+			if err != nil {
+				return nil, err
+			}
+			path = resolved
+		}
+		if path == "" {
+			continue
+		}
+		scanned, err := sbom.ReadPURLs(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading package URLs: %w", err)
+		}
+		purls = append(purls, scanned...)
+	}
+	return purls, nil
+}
+
+// pushSourceImage assembles a source image for the image we just built, which
+// contains the build context, copies of any content which ADD instructions
+// fetched from remote locations, and, if the SourceImagePackages option was
+// set, the source packages of the packages which SBOM scanning found, and
+// pushes it to the location named by the SourceImage option.
+func (b *executor) pushSourceImage(ctx context.Context, imageID string, ref reference.Canonical) error {
+	var imageDigest digest.Digest
+	annotations := make(map[string]string)
+	if ref != nil {
+		imageDigest = ref.Digest()
+		annotations[source.AnnotationImageName] = ref.Name()
+	} else {
+		img, err := b.store.Image(imageID)
+		if err != nil {
+			return fmt.Errorf("locating image %q: %w", imageID, err)
+		}
+		imageDigest = img.Digest
+	}
+	if imageDigest != "" {
+		annotations[source.AnnotationImageDigest] = imageDigest.String()
+	}
+
+	tempDir, err := os.MkdirTemp(tmpdir.GetTempDir(), "buildah-source-image")
+	if err != nil {
+		return fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			logrus.Warnf("removing temporary directory %q: %v", tempDir, err)
+		}
+	}()
+
+	sourcePath := filepath.Join(tempDir, "image")
+	createOptions := source.CreateOptions{
+		TimeStamp:   b.timestamp == nil && b.sourceDateEpoch == nil,
+		Annotations: annotations,
+	}
+	if err := source.Create(ctx, sourcePath, createOptions); err != nil {
+		return fmt.Errorf("creating source image: %w", err)
+	}
+	artifact := func(kind string) source.AddOptions {
+		return source.AddOptions{Annotations: []string{
+			source.AnnotationArtifactSource + "=" + kind,
+			v1.AnnotationTitle + "=" + kind,
+		}}
+	}
+
+	// the build context, minus anything .containerignore told us to skip
+	contextOptions := artifact("context")
+	contextOptions.Excludes = b.excludes
+	if err := source.Add(ctx, sourcePath, b.contextDir, contextOptions); err != nil {
+		return fmt.Errorf("adding build context to source image: %w", err)
+	}
+
+	// the copies of content that ADD instructions fetched, which is what
+	// went into the image
+	b.remoteSourcesLock.Lock()
+	remoteSources := slices.Clone(b.remoteSources)
+	b.remoteSourcesLock.Unlock()
+	for _, remote := range remoteSources {
+		if err := source.Add(ctx, sourcePath, remote.dir, artifact(remote.src)); err != nil {
+			return fmt.Errorf("adding %q to source image: %w", remote.src, err)
+		}
+	}
+
+	// the source packages for the RPMs and Debian packages which the SBOM
+	// scanners found
+	if len(b.sourceImagePackages) > 0 {
+		purls, err := b.scannedPURLs(imageID)
+		if err != nil {
+			return err
+		}
+		fetchOptions := source.FetchPackageOptions{Locations: b.sourceImagePackages}
+		for i, pkg := range source.Packages(purls) {
+			dir := filepath.Join(tempDir, "packages", strconv.Itoa(i))
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				return err
+			}
+			if err := source.FetchPackage(ctx, pkg, dir, fetchOptions); err != nil {
+				return fmt.Errorf("fetching source package %q: %w", pkg.File, err)
+			}
+			if err := source.Add(ctx, sourcePath, dir, artifact(pkg.File)); err != nil {
+				return fmt.Errorf("adding source package %q to source image: %w", pkg.File, err)
+			}
+		}
+	}
+
+	pushOptions := source.PushOptions{
+		SystemContext: b.systemContext,
+		ReportWriter:  b.reportWriter,
+		Quiet:         b.quiet,
+	}
+	if err := source.Push(ctx, sourcePath, b.sourceImage, pushOptions); err != nil {
+		return fmt.Errorf("pushing source image to %q: %w", b.sourceImage, err)
+	}
+	return nil
+}
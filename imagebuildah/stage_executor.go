@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"slices"
@@ -84,6 +85,8 @@ type stageExecutor struct {
 	argsFromContainerfile []string
 	hasLink               bool
 	isLastStep            bool
+	runsRemaining         bool // a RUN instruction follows the current one in this stage
+	skippedNode           *parser.Node
 }
 
 // Preserve informs the stage executor that from this point on, it needs to
@@ -477,6 +480,12 @@ func (s *stageExecutor) performCopy(excludes []string, copies ...imagebuilder.Co
 			copy.Src = copySources
 		}
 
+		// only the build context is trusted to be left alone for
+		// the rest of the build, so it's the only place we'll hard
+		// link files from, and only if nothing will be able to
+		// modify them in the working container before it's committed
+		// and removed
+		hardlinkContext := s.executor.hardlinkContext && copy.From == "" && len(copy.Files) == 0 && !s.runsRemaining && s.executor.removeIntermediateCtrs && s.executor.debugger == nil
 		if copy.From != "" && len(copy.Files) == 0 {
 			// If from has an argument within it, resolve it to its
 			// value.  Otherwise just return the value found.
@@ -627,6 +636,11 @@ func (s *stageExecutor) performCopy(excludes []string, copies ...imagebuilder.Co
 			Parents:               copy.Parents,
 			Link:                  s.hasLink,
 			BuildMetadata:         labelsAndAnnotations,
+			NoClone:               s.executor.noClone,
+			HardlinkContext:       hardlinkContext,
+		}
+		if copy.Download {
+			options.SaveRemoteContent = s.executor.saveRemoteSource
 		}
 		if len(copy.Files) > 0 {
 			// If we are copying heredoc files, we need to temporary place
@@ -877,6 +891,7 @@ func (s *stageExecutor) Run(run imagebuilder.Run, config docker.Config) error {
 		Cmd:                  config.Cmd,
 		ContextDir:           s.executor.contextDir,
 		ConfigureNetwork:     s.executor.configureNetwork,
+		Description:          "RUN " + strings.Join(run.Args, " "),
 		Entrypoint:           config.Entrypoint,
 		Env:                  config.Env,
 		Hostname:             config.Hostname,
@@ -949,6 +964,16 @@ func (s *stageExecutor) Run(run imagebuilder.Run, config docker.Config) error {
 		options.Mounts = append(options.Mounts, heredocMounts...)
 	}
 	err = s.builder.Run(args, options)
+	for s.executor.onRunFailure == define.RunFailureShell && commandFailed(err) {
+		retry, shellErr := s.runFailureShell(args, options, config, err)
+		if shellErr != nil {
+			logrus.Warnf("debugging failed RUN instruction: %v", shellErr)
+		}
+		if !retry {
+			break
+		}
+		err = s.builder.Run(args, options)
+	}
 
 	if s.executor.compatVolumes == types.OptionalBoolTrue {
 		// Only bother with saving/restoring the contents of volumes if
@@ -963,6 +988,78 @@ func (s *stageExecutor) Run(run imagebuilder.Run, config docker.Config) error {
 	return err
 }
 
+// commandFailed returns true if err reports that a RUN instruction's command
+// ran and exited with a non-zero status, as opposed to the working container
+// not having been set up well enough to run it.
+func commandFailed(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr)
+}
+
+// runFailureShell starts an interactive shell in the working container after
+// a RUN instruction has failed, using the environment and mounts that the
+// instruction was run with, and then asks whether the instruction should be
+// retried.  Only one shell is started at a time, even if stages are being
+// built in parallel.
+func (s *stageExecutor) runFailureShell(args []string, options buildah.RunOptions, config docker.Config, runErr error) (bool, error) {
+	s.executor.interactiveLock.Lock()
+	defer s.executor.interactiveLock.Unlock()
+
+	shell := "/bin/sh"
+	if len(config.Shell) > 0 {
+		shell = config.Shell[0]
+	}
+	fmt.Fprintf(os.Stderr, "RUN %s: %v\n", strings.Join(args, " "), runErr)
+	fmt.Fprintf(os.Stderr, "Starting %s in the working container, exit the shell to continue\n", shell)
+
+	options.Stdin = os.Stdin
+	options.Stdout = os.Stdout
+	options.Stderr = os.Stderr
+	options.Terminal = buildah.DefaultTerminal
+	if err := s.builder.Run([]string{shell}, options); err != nil {
+		// the exit status of the last command run in the shell isn't interesting
+		logrus.Debugf("debugging shell exited: %v", err)
+	}
+
+	fmt.Fprint(os.Stderr, "Retry the failed instruction? [y/N] ")
+	answer, err := readAnswer(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// readAnswer reads a single line from r.  It reads one byte at a time so that
+// nothing which follows the line is consumed, since the same input will be
+// handed to the next debugging shell.
+func readAnswer(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(line) > 0 {
+				return string(line), nil
+			}
+			return "", err
+		}
+	}
+}
+
 // UnrecognizedInstruction is called when we encounter an instruction that the
 // imagebuilder parser didn't understand.
 func (s *stageExecutor) UnrecognizedInstruction(step *imagebuilder.Step) error {
@@ -1455,7 +1552,16 @@ func (s *stageExecutor) execute(ctx context.Context, base string) (imgID string,
 	}
 
 	executedLayerStep := false
+	// If we're sharing steps with other builds, we hold the lock for the
+	// step we're on until we've either committed it or found it in the
+	// cache, so that the other builds can find it there.
+	releaseStep := func() {}
+	defer func() {
+		releaseStep()
+	}()
 	for i, node := range children {
+		releaseStep()
+		releaseStep = func() {}
 		logRusage()
 		moreInstructions := i < len(children)-1
 		lastInstruction := !moreInstructions
@@ -1488,6 +1594,18 @@ func (s *stageExecutor) execute(ctx context.Context, base string) (imgID string,
 			s.log("%s", logMsg)
 		}
 
+		// Give the debugger, if we have one, a chance to look around
+		// before we do anything.
+		skip, pauseErr := s.debugPause(ctx, i, node, step, func() string {
+			return s.debugCacheDecision(ctx, node, step, checkForLayers, lastInstruction, lastStage)
+		})
+		if pauseErr != nil {
+			return "", nil, false, fmt.Errorf("building at STEP \"%s\": %w", step.Message, pauseErr)
+		}
+		if skip {
+			s.skippedNode = node
+		}
+
 		// Check if there's a --from if the step command is COPY.
 		// Also check the chmod and the chown flags for validity.
 		for _, flag := range step.Flags {
@@ -1559,6 +1677,13 @@ func (s *stageExecutor) execute(ctx context.Context, base string) (imgID string,
 		if moreInstructions {
 			noRunsRemaining = !ib.RequiresStart(&parser.Node{Children: children[i+1:]})
 		}
+		s.runsRemaining = moreInstructions && !noRunsRemaining
+		runStep := func() error {
+			if skip {
+				return nil
+			}
+			return ib.Run(step, s, noRunsRemaining)
+		}
 
 		// If we're doing a single-layer build, just process the
 		// instruction.
@@ -1567,7 +1692,7 @@ func (s *stageExecutor) execute(ctx context.Context, base string) (imgID string,
 			if s.stepRequiresLayer(step) {
 				executedLayerStep = true
 			}
-			err := ib.Run(step, s, noRunsRemaining)
+			err := runStep()
 			if err != nil {
 				logrus.Debugf("Error building at step %+v: %v", *step, err)
 				return "", nil, false, fmt.Errorf("building at STEP \"%s\": %w", step.Message, err)
@@ -1661,8 +1786,15 @@ func (s *stageExecutor) execute(ctx context.Context, base string) (imgID string,
 				avoidLookingCache = true
 			}
 		}
+		if skip {
+			// The cache can't have anything for an instruction that
+			// we're not processing.
+			avoidLookingCache = true
+		}
 
 		needsCacheKey := (len(s.executor.cacheFrom) != 0 && !avoidLookingCache) || len(s.executor.cacheTo) != 0
+		lockStep := s.executor.stepLocker != nil && checkForLayers && !avoidLookingCache
+		needsCacheKey = needsCacheKey || lockStep
 
 		// If we have to commit for this instruction, only assign the
 		// stage's configured output name to the last layer.
@@ -1693,7 +1825,7 @@ func (s *stageExecutor) execute(ctx context.Context, base string) (imgID string,
 			// and copy the content.
 			canMatchCacheOnlyAfterRun = (step.Command == command.Add || step.Command == command.Copy)
 			if canMatchCacheOnlyAfterRun {
-				if err = ib.Run(step, s, noRunsRemaining); err != nil {
+				if err = runStep(); err != nil {
 					logrus.Debugf("Error building at step %+v: %v", *step, err)
 					return "", nil, false, fmt.Errorf("building at STEP \"%s\": %w", step.Message, err)
 				}
@@ -1708,6 +1840,9 @@ func (s *stageExecutor) execute(ctx context.Context, base string) (imgID string,
 					}
 				}
 			}
+			if lockStep {
+				releaseStep = s.executor.stepLocker.LockStep(cacheKey)
+			}
 			cacheID, err = s.intermediateImageExists(ctx, node, addedContentSummary, s.stepRequiresLayer(step), lastInstruction && lastStage)
 			if err != nil {
 				return "", nil, false, fmt.Errorf("checking if cached image exists from a previous build: %w", err)
@@ -1743,7 +1878,7 @@ func (s *stageExecutor) execute(ctx context.Context, base string) (imgID string,
 		if cacheID == "" && !canMatchCacheOnlyAfterRun {
 			// Process the instruction directly.
 			s.didExecute = true
-			if err = ib.Run(step, s, noRunsRemaining); err != nil {
+			if err = runStep(); err != nil {
 				logrus.Debugf("Error building at step %+v: %v", *step, err)
 				return "", nil, false, fmt.Errorf("building at STEP \"%s\": %w", step.Message, err)
 			}
@@ -1801,7 +1936,7 @@ func (s *stageExecutor) execute(ctx context.Context, base string) (imgID string,
 			// configuration blob.
 			if !s.stepRequiresLayer(step) {
 				s.didExecute = true
-				err := ib.Run(step, s, noRunsRemaining)
+				err := runStep()
 				if err != nil {
 					logrus.Debugf("Error building at step %+v: %v", *step, err)
 					return "", nil, false, fmt.Errorf("building at STEP \"%s\": %w", step.Message, err)
@@ -2035,6 +2170,11 @@ func (s *stageExecutor) getCreatedBy(node *parser.Node, addedContentSummary stri
 	if node == nil {
 		return "/bin/sh", nil
 	}
+	if node == s.skippedNode {
+		// Make sure that nothing will mistake the result for that of
+		// actually processing the instruction.
+		return "/bin/sh -c #(nop) SKIPPED " + node.Original, nil
+	}
 
 	command := strings.ToUpper(node.Value)
 	addcopy := command == "ADD" || command == "COPY"
@@ -2392,17 +2532,20 @@ func (s *stageExecutor) pushCache(ctx context.Context, src, cacheKey string) err
 	for _, dest := range destList {
 		logrus.Debugf("trying to push cache to dest: %+v from src:%+v", dest, src)
 		options := buildah.PushOptions{
-			Compression:            s.executor.compression,
-			CompressionFormat:      s.executor.compressionFormat,
-			CompressionLevel:       s.executor.compressionLevel,
-			ForceCompressionFormat: s.executor.forceCompressionFormat,
-			SignaturePolicyPath:    s.executor.signaturePolicyPath,
-			Store:                  s.executor.store,
-			SystemContext:          s.systemContext,
-			BlobDirectory:          s.executor.blobDirectory,
-			SignBy:                 s.executor.signBy,
-			MaxRetries:             s.executor.maxPullPushRetries,
-			RetryDelay:             s.executor.retryPullPushDelay,
+			Compression:                      s.executor.compression,
+			CompressionFormat:                s.executor.compressionFormat,
+			CompressionLevel:                 s.executor.compressionLevel,
+			ForceCompressionFormat:           s.executor.forceCompressionFormat,
+			SignaturePolicyPath:              s.executor.signaturePolicyPath,
+			Store:                            s.executor.store,
+			SystemContext:                    s.systemContext,
+			BlobDirectory:                    s.executor.blobDirectory,
+			SignBy:                           s.executor.signBy,
+			SignBySigstorePrivateKeyFile:     s.executor.signBySigstorePrivateKeyFile,
+			SignSigstorePrivateKeyPassphrase: s.executor.signSigstorePrivateKeyPassphrase,
+			Signers:                          s.executor.signers,
+			MaxRetries:                       s.executor.maxPullPushRetries,
+			RetryDelay:                       s.executor.retryPullPushDelay,
 		}
 		if s.executor.cachePushSourceLookupReferenceFunc != nil {
 			options.SourceLookupReferenceFunc = s.executor.cachePushSourceLookupReferenceFunc(dest)
@@ -2708,28 +2851,32 @@ func (s *stageExecutor) commit(ctx context.Context, createdBy string, emptyLayer
 		writer = nil
 	}
 	options := buildah.CommitOptions{
-		Compression:           s.executor.compression,
-		SignaturePolicyPath:   s.executor.signaturePolicyPath,
-		ReportWriter:          writer,
-		PreferredManifestType: s.executor.outputFormat,
-		SystemContext:         s.systemContext,
-		Squash:                squash,
-		OmitHistory:           s.executor.commonBuildOptions.OmitHistory,
-		EmptyLayer:            emptyLayer,
-		OmitLayerHistoryEntry: s.hasLink,
-		BlobDirectory:         s.executor.blobDirectory,
-		SignBy:                s.executor.signBy,
-		MaxRetries:            s.executor.maxPullPushRetries,
-		RetryDelay:            s.executor.retryPullPushDelay,
-		HistoryTimestamp:      s.executor.timestamp,
-		Manifest:              s.executor.manifest,
-		CompatSetParent:       s.executor.compatSetParent,
-		SourceDateEpoch:       s.executor.sourceDateEpoch,
-		RewriteTimestamp:      s.executor.rewriteTimestamp,
-		CompatLayerOmissions:  s.executor.compatLayerOmissions,
-		UnsetAnnotations:      s.executor.unsetAnnotations,
-		Annotations:           s.executor.annotations,
-		CreatedAnnotation:     s.executor.createdAnnotation,
+		Compression:                      s.executor.compression,
+		SignaturePolicyPath:              s.executor.signaturePolicyPath,
+		ReportWriter:                     writer,
+		PreferredManifestType:            s.executor.outputFormat,
+		SystemContext:                    s.systemContext,
+		Squash:                           squash,
+		OmitHistory:                      s.executor.commonBuildOptions.OmitHistory,
+		EmptyLayer:                       emptyLayer,
+		OmitLayerHistoryEntry:            s.hasLink,
+		BlobDirectory:                    s.executor.blobDirectory,
+		SignBy:                           s.executor.signBy,
+		SignBySigstorePrivateKeyFile:     s.executor.signBySigstorePrivateKeyFile,
+		SignSigstorePrivateKeyPassphrase: s.executor.signSigstorePrivateKeyPassphrase,
+		Signers:                          s.executor.signers,
+		MaxRetries:                       s.executor.maxPullPushRetries,
+		RetryDelay:                       s.executor.retryPullPushDelay,
+		HistoryTimestamp:                 s.executor.timestamp,
+		Manifest:                         s.executor.manifest,
+		CompatSetParent:                  s.executor.compatSetParent,
+		CompatDockerConfig:               s.executor.compatDockerConfig,
+		SourceDateEpoch:                  s.executor.sourceDateEpoch,
+		RewriteTimestamp:                 s.executor.rewriteTimestamp,
+		CompatLayerOmissions:             s.executor.compatLayerOmissions,
+		UnsetAnnotations:                 s.executor.unsetAnnotations,
+		Annotations:                      s.executor.annotations,
+		CreatedAnnotation:                s.executor.createdAnnotation,
 	}
 	if finalInstruction {
 		options.ConfidentialWorkloadOptions = s.executor.confidentialWorkload
@@ -2759,7 +2906,7 @@ func (s *stageExecutor) generateBuildOutput(buildOutputOpts output.BuildOutputOp
 	extractRootfsOpts := buildah.ExtractRootfsOptions{
 		ForceTimestamp: forceTimestamp,
 	}
-	if unshare.IsRootless() {
+	if unshare.IsRootless() && buildOutputOpts.Type != output.BuildOutputFSImage {
 		// In order to maintain as much parity as possible
 		// with buildkit's version of --output and to avoid
 		// unsafe invocation of exported executables it was
@@ -2768,6 +2915,8 @@ func (s *stageExecutor) generateBuildOutput(buildOutputOpts output.BuildOutputOp
 		// to get invoked with different file-system permission its safer
 		// to strip them off for unprivileged invocation.
 		// See: https://github.com/containers/buildah/pull/3823#discussion_r829376633
+		// Filesystem images are meant to be booted or mounted
+		// rather than run from, so they get everything.
 		extractRootfsOpts.StripSetuidBit = true
 		extractRootfsOpts.StripSetgidBit = true
 		extractRootfsOpts.StripXattrs = true
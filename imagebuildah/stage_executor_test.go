@@ -2,7 +2,12 @@ package imagebuildah
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
 	"strconv"
+	"strings"
 	"testing"
 
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
@@ -99,3 +104,28 @@ func TestHistoryEntriesEqual(t *testing.T) {
 		})
 	}
 }
+
+func TestReadAnswer(t *testing.T) {
+	t.Parallel()
+	r := strings.NewReader("y\nno\nlast")
+	answer, err := readAnswer(r)
+	require.NoError(t, err)
+	assert.Equal(t, "y", answer)
+	answer, err = readAnswer(r)
+	require.NoError(t, err)
+	assert.Equal(t, "no", answer)
+	answer, err = readAnswer(r)
+	require.NoError(t, err)
+	assert.Equal(t, "last", answer)
+	_, err = readAnswer(r)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestCommandFailed(t *testing.T) {
+	t.Parallel()
+	assert.False(t, commandFailed(nil))
+	assert.False(t, commandFailed(errors.New("mounting volume: no such file or directory")))
+	err := exec.Command("sh", "-c", "exit 3").Run()
+	require.Error(t, err)
+	assert.True(t, commandFailed(fmt.Errorf("while running runtime: %w", err)))
+}
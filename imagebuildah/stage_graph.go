@@ -0,0 +1,181 @@
+package imagebuildah
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openshift/imagebuilder"
+)
+
+const (
+	// StageGraphFormatDot selects Graphviz "dot" output for
+	// define.BuildOptions.PrintGraph.
+	StageGraphFormatDot = "dot"
+	// StageGraphFormatJSON selects JSON output for
+	// define.BuildOptions.PrintGraph.
+	StageGraphFormatJSON = "json"
+)
+
+// stageGraph describes the stages in a build, and the earlier stages which
+// each of them needs.
+type stageGraph struct {
+	Stages []stageGraphNode `json:"stages"`
+}
+
+type stageGraphNode struct {
+	Index          int              `json:"index"`
+	Name           string           `json:"name,omitempty"`
+	Base           string           `json:"base,omitempty"`
+	Target         bool             `json:"target,omitempty"`
+	NeededByTarget bool             `json:"neededByTarget"`
+	Needs          []stageGraphEdge `json:"needs,omitempty"`
+}
+
+type stageGraphEdge struct {
+	Index int    `json:"index"`
+	Name  string `json:"name,omitempty"`
+	Kind  string `json:"kind"`
+}
+
+// stageName returns the name of a stage, if it was given one.
+func stageName(stage imagebuilder.Stage) string {
+	if stage.Name == strconv.Itoa(stage.Position) {
+		return ""
+	}
+	return stage.Name
+}
+
+// newStageGraph builds a stageGraph using the dependency information which
+// Build() gathers.
+func newStageGraph(stages imagebuilder.Stages, dependencyMap map[string]*stageDependencyInfo) *stageGraph {
+	graph := &stageGraph{}
+	for _, stage := range stages {
+		node := stageGraphNode{
+			Index:  stage.Position,
+			Name:   stageName(stage),
+			Target: stage.Position == len(stages)-1,
+		}
+		if info, ok := dependencyMap[stage.Name]; ok {
+			node.Base = info.Base
+			node.NeededByTarget = info.NeededByTarget
+			for _, need := range info.Needs {
+				needInfo, ok := dependencyMap[need.Stage]
+				if !ok {
+					continue
+				}
+				edge := stageGraphEdge{
+					Index: needInfo.Position,
+					Name:  stageName(stages[needInfo.Position]),
+					Kind:  need.Kind,
+				}
+				if !slices.Contains(node.Needs, edge) {
+					node.Needs = append(node.Needs, edge)
+				}
+			}
+		}
+		graph.Stages = append(graph.Stages, node)
+	}
+	return graph
+}
+
+// needs returns the indexes of the stages which each stage needs.
+func (g *stageGraph) needs() [][]int {
+	needs := make([][]int, len(g.Stages))
+	for i, node := range g.Stages {
+		for _, edge := range node.Needs {
+			// stages can only need earlier stages, but names can
+			// be reused, so guard against cycles
+			if edge.Index < i && !slices.Contains(needs[i], edge.Index) {
+				needs[i] = append(needs[i], edge.Index)
+			}
+		}
+	}
+	return needs
+}
+
+// dotQuote quotes a string for use as an ID or attribute value in a dot file.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// write writes the graph to w in the specified format.
+func (g *stageGraph) write(w io.Writer, format string) error {
+	switch format {
+	case StageGraphFormatJSON:
+		encoded, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding stage graph: %w", err)
+		}
+		_, err = fmt.Fprintf(w, "%s\n", encoded)
+		return err
+	case StageGraphFormatDot:
+		var b strings.Builder
+		b.WriteString("digraph stages {\n")
+		b.WriteString("  node [shape=box];\n")
+		for _, node := range g.Stages {
+			label := fmt.Sprintf("stage %d", node.Index)
+			if node.Name != "" {
+				label += " (" + node.Name + ")"
+			}
+			if node.Base != "" {
+				label += "\nFROM " + node.Base
+			}
+			attributes := []string{"label=" + dotQuote(label)}
+			if node.Target {
+				attributes = append(attributes, "peripheries=2")
+			}
+			if !node.NeededByTarget {
+				attributes = append(attributes, "style=dashed")
+			}
+			fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(strconv.Itoa(node.Index)), strings.Join(attributes, ", "))
+		}
+		for _, node := range g.Stages {
+			for _, edge := range node.Needs {
+				fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", dotQuote(strconv.Itoa(edge.Index)), dotQuote(strconv.Itoa(node.Index)), dotQuote(edge.Kind))
+			}
+		}
+		b.WriteString("}\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	return fmt.Errorf("unknown stage graph format %q (expected %q or %q)", format, StageGraphFormatDot, StageGraphFormatJSON)
+}
+
+// stageTiming records when a stage's build started and finished.
+type stageTiming struct {
+	started, finished time.Time
+	skipped           bool
+}
+
+// writeStageTimeline writes a table which shows when each stage's build
+// started and finished, relative to the start of the build.
+func writeStageTimeline(w io.Writer, prefix string, stages imagebuilder.Stages, timings []stageTiming, start time.Time) error {
+	round := func(d time.Duration) string {
+		return d.Round(10 * time.Millisecond).String()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%sStage timeline:\n", prefix)
+	fmt.Fprintf(tw, "%s  STAGE\tSTART\tEND\tDURATION\n", prefix)
+	for i, stage := range stages {
+		label := strconv.Itoa(stage.Position)
+		if name := stageName(stage); name != "" {
+			label += " (" + name + ")"
+		}
+		timing := timings[i]
+		switch {
+		case timing.skipped:
+			fmt.Fprintf(tw, "%s  %s\tskipped\t-\t-\n", prefix, label)
+		case timing.started.IsZero() || timing.finished.IsZero():
+			fmt.Fprintf(tw, "%s  %s\tnot built\t-\t-\n", prefix, label)
+		default:
+			fmt.Fprintf(tw, "%s  %s\t+%s\t+%s\t%s\n", prefix, label, round(timing.started.Sub(start)), round(timing.finished.Sub(start)), round(timing.finished.Sub(timing.started)))
+		}
+	}
+	return tw.Flush()
+}
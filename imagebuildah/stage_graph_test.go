@@ -0,0 +1,97 @@
+package imagebuildah
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/imagebuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/buildah/define"
+)
+
+func TestStageGraph(t *testing.T) {
+	t.Parallel()
+	node, err := imagebuilder.ParseDockerfile(strings.NewReader("FROM scratch AS one\nFROM scratch AS two\nFROM scratch\nFROM one\nCOPY --from=two / /\n"))
+	require.NoError(t, err)
+	stages, err := imagebuilder.NewStages(node, imagebuilder.NewBuilder(nil))
+	require.NoError(t, err)
+	require.Len(t, stages, 4)
+
+	dependencyMap := map[string]*stageDependencyInfo{
+		"one": {Name: "one", Position: 0, Base: "scratch", NeededByTarget: true},
+		"two": {Name: "two", Position: 1, Base: "scratch", NeededByTarget: true},
+		"2":   {Name: "2", Position: 2, Base: "scratch"},
+		"3":   {Name: "3", Position: 3, Base: "one", NeededByTarget: true},
+	}
+	dependencyMap["3"].addNeed("one", stageNeedFrom)
+	dependencyMap["3"].addNeed("two", stageNeedCopy)
+	dependencyMap["3"].addNeed("two", stageNeedCopy)
+	dependencyMap["3"].addNeed("3", stageNeedMount)
+	// a reused name can make a stage appear to need a later stage
+	dependencyMap["two"].addNeed("3", stageNeedMount)
+
+	graph := newStageGraph(stages, dependencyMap)
+	assert.Equal(t, [][]int{nil, nil, nil, {0, 1}}, graph.needs())
+
+	var buf bytes.Buffer
+	require.NoError(t, graph.write(&buf, StageGraphFormatJSON))
+	var decoded stageGraph
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Len(t, decoded.Stages, 4)
+	assert.Equal(t, "", decoded.Stages[2].Name)
+	assert.False(t, decoded.Stages[2].NeededByTarget)
+	assert.True(t, decoded.Stages[3].Target)
+	assert.Equal(t, []stageGraphEdge{{Index: 0, Name: "one", Kind: stageNeedFrom}, {Index: 1, Name: "two", Kind: stageNeedCopy}}, decoded.Stages[3].Needs)
+
+	buf.Reset()
+	require.NoError(t, graph.write(&buf, StageGraphFormatDot))
+	dot := buf.String()
+	assert.True(t, strings.HasPrefix(dot, "digraph stages {\n"), dot)
+	assert.Contains(t, dot, `"0" [label="stage 0 (one)\nFROM scratch"];`)
+	assert.Contains(t, dot, `"2" [label="stage 2\nFROM scratch", style=dashed];`)
+	assert.Contains(t, dot, `"3" [label="stage 3\nFROM one", peripheries=2];`)
+	assert.Contains(t, dot, `"0" -> "3" [label="from"];`)
+	assert.Equal(t, 1, strings.Count(dot, `"1" -> "3" [label="copy"];`))
+	assert.NotContains(t, dot, `"3" -> "3"`)
+
+	assert.ErrorContains(t, graph.write(&buf, "svg"), `unknown stage graph format "svg"`)
+
+	start := time.Now()
+	timings := []stageTiming{
+		{started: start, finished: start.Add(time.Second)},
+		{started: start, finished: start.Add(2 * time.Second)},
+		{skipped: true},
+		{started: start.Add(2 * time.Second), finished: start.Add(2500 * time.Millisecond)},
+	}
+	buf.Reset()
+	require.NoError(t, writeStageTimeline(&buf, "[x] ", stages, timings, start))
+	assert.Equal(t, []string{
+		"[x] Stage timeline:",
+		"[x]   STAGE    START    END    DURATION",
+		"[x]   0 (one)  +0s      +1s    1s",
+		"[x]   1 (two)  +0s      +2s    2s",
+		"[x]   2        skipped  -      -",
+		"[x]   3        +2s      +2.5s  500ms",
+	}, strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"))
+}
+
+func TestPrintGraphConflicts(t *testing.T) {
+	t.Parallel()
+	containerfile := filepath.Join(t.TempDir(), "Containerfile")
+	require.NoError(t, os.WriteFile(containerfile, []byte("FROM scratch\n"), 0o644))
+	for _, options := range []define.BuildOptions{
+		{PrintGraph: StageGraphFormatDot, Manifest: "localhost/list"},
+		{PrintGraph: StageGraphFormatDot, SourceImage: "oci:" + t.TempDir()},
+	} {
+		// nothing is built, so we don't need a store
+		_, _, err := BuildDockerfiles(context.Background(), nil, options, containerfile)
+		assert.ErrorContainsf(t, err, "mutually exclusive", "building with %+v", options)
+	}
+}
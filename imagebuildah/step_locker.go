@@ -0,0 +1,51 @@
+package imagebuildah
+
+import (
+	"sync"
+
+	"go.podman.io/buildah/define"
+)
+
+type stepLock struct {
+	sync.Mutex
+	users int
+}
+
+type stepLocker struct {
+	lock  sync.Mutex
+	steps map[string]*stepLock
+}
+
+// NewStepLocker returns a define.StepLocker which can be shared by builds
+// which are run concurrently in this process, so that steps which they have
+// in common are only run once.
+func NewStepLocker() define.StepLocker {
+	return &stepLocker{steps: make(map[string]*stepLock)}
+}
+
+// LockStep waits until no other build holds the lock for key, and returns a
+// function which releases it.
+func (l *stepLocker) LockStep(key string) func() {
+	l.lock.Lock()
+	step, ok := l.steps[key]
+	if !ok {
+		step = &stepLock{}
+		l.steps[key] = step
+	}
+	step.users++
+	l.lock.Unlock()
+
+	step.Lock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			step.Unlock()
+			l.lock.Lock()
+			step.users--
+			if step.users == 0 {
+				delete(l.steps, key)
+			}
+			l.lock.Unlock()
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package imagebuildah
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepLocker(t *testing.T) {
+	locker := NewStepLocker()
+
+	// different keys don't block each other
+	releaseA := locker.LockStep("a")
+	releaseB := locker.LockStep("b")
+	releaseB()
+
+	// the same key does, until it's released
+	acquired := make(chan struct{})
+	go func() {
+		release := locker.LockStep("a")
+		close(acquired)
+		release()
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("lock for a step was acquired while it was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	releaseA()
+	<-acquired
+	// releasing more than once is harmless
+	releaseA()
+
+	var wg sync.WaitGroup
+	counter := 0
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := locker.LockStep("c")
+			defer release()
+			counter++
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 10, counter)
+	assert.Empty(t, locker.(*stepLocker).steps)
+}
@@ -0,0 +1,412 @@
+// Package analyze examines the layers of an image, and reports how much
+// space each of them uses, how much of that space is wasted on content which
+// later layers overwrite or delete, and which content is duplicated.
+package analyze
+
+import (
+	"archive/tar"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/storage/pkg/archive"
+)
+
+// Layer is a layer which is to be analyzed.
+type Layer struct {
+	// ID is the layer's ID.
+	ID string
+	// CreatedBy is the command which created the layer, if it is known.
+	CreatedBy string
+	// Open returns the layer's uncompressed contents, as a tar stream in
+	// which deletions are recorded using whiteouts.
+	Open func() (io.ReadCloser, error)
+}
+
+// Report describes how an image's layers use space.
+type Report struct {
+	// TotalBytes is the size of all of the files added by all of the
+	// layers.
+	TotalBytes int64 `json:"totalBytes"`
+	// FinalBytes is the size of all of the files in the final filesystem.
+	FinalBytes int64 `json:"finalBytes"`
+	// WastedBytes is the size of the files which were overwritten or
+	// deleted by later layers.
+	WastedBytes int64 `json:"wastedBytes"`
+	// DuplicateBytes is the size of the extra copies of files in the
+	// final filesystem which have the same contents as files which were
+	// added by other layers.
+	DuplicateBytes int64 `json:"duplicateBytes"`
+	// Efficiency is the fraction of TotalBytes which is not wasted.
+	Efficiency         float64          `json:"efficiency"`
+	Layers             []LayerReport    `json:"layers"`
+	Wasted             []WastedFile     `json:"wasted"`
+	LargestFiles       []File           `json:"largestFiles"`
+	LargestDirectories []Directory      `json:"largestDirectories"`
+	Duplicates         []DuplicateFiles `json:"duplicates"`
+}
+
+// LayerReport describes one layer.
+type LayerReport struct {
+	Index     int    `json:"index"`
+	ID        string `json:"id"`
+	CreatedBy string `json:"createdBy,omitempty"`
+	// AddedBytes is the size of the files which the layer adds.
+	AddedBytes int64 `json:"addedBytes"`
+	// RemovedBytes is the size of the files from earlier layers which the
+	// layer overwrites or deletes.
+	RemovedBytes int64 `json:"removedBytes"`
+	// Files is the number of files, directories, and other items which
+	// the layer adds.
+	Files int `json:"files"`
+	// Deletions is the number of items which the layer deletes.
+	Deletions int `json:"deletions"`
+}
+
+// File is a file in the final filesystem.
+type File struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Layer int    `json:"layer"`
+}
+
+// Directory is a directory in the final filesystem.
+type Directory struct {
+	Path string `json:"path"`
+	// Size is the size of all of the files under the directory.
+	Size  int64 `json:"size"`
+	Files int   `json:"files"`
+}
+
+// WastedFile is a file which was overwritten or deleted by a later layer.
+type WastedFile struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Layer int    `json:"layer"`
+	// RemovedBy is the index of the layer which overwrote or deleted the
+	// file.
+	RemovedBy   int  `json:"removedBy"`
+	Overwritten bool `json:"overwritten"`
+}
+
+// DuplicateFiles is a set of files in the final filesystem which have the
+// same contents, and which were added by more than one layer.
+type DuplicateFiles struct {
+	Digest digest.Digest `json:"digest"`
+	Size   int64         `json:"size"`
+	Files  []File        `json:"files"`
+}
+
+// entry is an item in the filesystem, as it is built up layer by layer.
+type entry struct {
+	layer    int
+	position int
+	size     int64
+	isDir    bool
+	digest   digest.Digest
+	link     string
+	// for a hard link, the regular file whose contents it shares
+	linkContent *Content
+}
+
+// analyzer tracks the state of the filesystem as each layer is applied.
+type analyzer struct {
+	entries map[string]*entry
+	// the paths of the entries and implicitly created directories in each
+	// directory, so that removing a directory doesn't require looking at
+	// every entry
+	children map[string]map[string]struct{}
+	report   *Report
+}
+
+// add records e as the item at p.
+func (a *analyzer) add(p string, e *entry) {
+	a.entries[p] = e
+	// link p and any of its parents which aren't yet known about to their
+	// parent directories
+	for p != "/" {
+		parent := path.Dir(p)
+		children, ok := a.children[parent]
+		if !ok {
+			children = make(map[string]struct{})
+			a.children[parent] = children
+		}
+		if _, ok := children[p]; ok {
+			break
+		}
+		children[p] = struct{}{}
+		p = parent
+	}
+}
+
+// remove removes the item at p, and if it is a directory, everything under
+// it, recording regular files from layers before the current one as wasted.
+func (a *analyzer) remove(p string, current int, overwritten, subtreeOnly bool) {
+	p = path.Clean(p)
+	var walk func(dir string)
+	walk = func(dir string) {
+		for child := range a.children[dir] {
+			walk(child)
+			a.removeOne(child, current, overwritten)
+		}
+	}
+	walk(p)
+	if !subtreeOnly {
+		a.removeOne(p, current, overwritten)
+	}
+}
+
+// removeOne removes the item at p, if there is one, and it was added by a
+// layer before the current one.
+func (a *analyzer) removeOne(p string, current int, overwritten bool) {
+	e, ok := a.entries[p]
+	if ok && e.layer < current {
+		delete(a.entries, p)
+		if !e.isDir && e.size > 0 {
+			a.report.Wasted = append(a.report.Wasted, WastedFile{
+				Path:        p,
+				Size:        e.size,
+				Layer:       e.layer,
+				RemovedBy:   current,
+				Overwritten: overwritten,
+			})
+			a.report.WastedBytes += e.size
+			a.report.Layers[current].RemovedBytes += e.size
+		}
+		ok = false
+	}
+	// forget about p if nothing is left there or under it
+	if ok || len(a.children[p]) > 0 || p == "/" {
+		return
+	}
+	delete(a.children, p)
+	parent := path.Dir(p)
+	delete(a.children[parent], p)
+	if len(a.children[parent]) == 0 {
+		delete(a.children, parent)
+	}
+}
+
+// apply applies one layer's contents.
+func (a *analyzer) apply(index int, rc io.Reader) error {
+	layer := &a.report.Layers[index]
+	tr := tar.NewReader(rc)
+	hdr, err := tr.Next()
+	for position := 0; err == nil; position++ {
+		p := path.Join("/", hdr.Name)
+		dir, base := path.Split(p)
+		switch {
+		case base == archive.WhiteoutOpaqueDir:
+			// everything from earlier layers under the directory
+			// is hidden
+			a.remove(dir, index, false, true)
+			layer.Deletions++
+		case strings.HasPrefix(base, archive.WhiteoutPrefix):
+			a.remove(path.Join(dir, strings.TrimPrefix(base, archive.WhiteoutPrefix)), index, false, false)
+			layer.Deletions++
+		default:
+			e := &entry{layer: index, position: position, isDir: hdr.Typeflag == tar.TypeDir}
+			if hdr.Typeflag == tar.TypeLink {
+				e.link = path.Join("/", hdr.Linkname)
+				if target, ok := a.entries[e.link]; ok {
+					if target.linkContent != nil {
+						e.linkContent = target.linkContent
+					} else if target.digest != "" {
+						e.linkContent = &Content{Layer: target.layer, Position: target.position, Size: target.size}
+					}
+				}
+			}
+			if previous, ok := a.entries[p]; !e.isDir || (ok && !previous.isDir) {
+				// anything other than a directory replacing a
+				// directory, including one which was only
+				// created implicitly, replaces whatever was there
+				a.remove(p, index, true, false)
+			}
+			if hdr.Typeflag == tar.TypeReg {
+				digester := digest.Canonical.Digester()
+				n, err := io.Copy(digester.Hash(), tr)
+				if err != nil {
+					return fmt.Errorf("reading %q: %w", p, err)
+				}
+				e.size = n
+				e.digest = digester.Digest()
+				layer.AddedBytes += n
+			}
+			a.add(p, e)
+			layer.Files++
+		}
+		hdr, err = tr.Next()
+	}
+	if !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// read reads the layers, which are listed starting with the base layer, and
+// builds up the final filesystem.
+func read(layers []Layer) (*analyzer, error) {
+	a := &analyzer{
+		entries:  make(map[string]*entry),
+		children: make(map[string]map[string]struct{}),
+		report: &Report{
+			Layers:             make([]LayerReport, len(layers)),
+			Wasted:             []WastedFile{},
+			LargestFiles:       []File{},
+			LargestDirectories: []Directory{},
+			Duplicates:         []DuplicateFiles{},
+		},
+	}
+	report := a.report
+	for i, layer := range layers {
+		report.Layers[i] = LayerReport{Index: i, ID: layer.ID, CreatedBy: layer.CreatedBy}
+		rc, err := layer.Open()
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %q: %w", layer.ID, err)
+		}
+		err = a.apply(i, rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %q: %w", layer.ID, err)
+		}
+		report.TotalBytes += report.Layers[i].AddedBytes
+	}
+	return a, nil
+}
+
+// Analyze reads the layers, which are listed starting with the base layer,
+// and reports how they use space.  At most top items are included in each of
+// the lists of wasted, largest, and duplicated files and directories, though
+// the totals include all of them.
+func Analyze(layers []Layer, top int) (*Report, error) {
+	a, err := read(layers)
+	if err != nil {
+		return nil, err
+	}
+	return a.summarize(top), nil
+}
+
+// summarize fills in the parts of the report which describe the final
+// filesystem, and sorts and trims its lists.
+func (a *analyzer) summarize(top int) *Report {
+	report := a.report
+	report.Efficiency = 1
+	if report.TotalBytes > 0 {
+		report.Efficiency = float64(report.TotalBytes-report.WastedBytes) / float64(report.TotalBytes)
+	}
+
+	directories := make(map[string]*Directory)
+	byDigest := make(map[digest.Digest][]File)
+	for name, e := range a.entries {
+		if e.isDir || e.digest == "" {
+			continue
+		}
+		report.FinalBytes += e.size
+		file := File{Path: name, Size: e.size, Layer: e.layer}
+		report.LargestFiles = append(report.LargestFiles, file)
+		for dir := path.Dir(name); dir != "/"; dir = path.Dir(dir) {
+			d, ok := directories[dir]
+			if !ok {
+				d = &Directory{Path: dir}
+				directories[dir] = d
+			}
+			d.Size += e.size
+			d.Files++
+		}
+		if e.size > 0 {
+			byDigest[e.digest] = append(byDigest[e.digest], file)
+		}
+	}
+	for _, d := range directories {
+		report.LargestDirectories = append(report.LargestDirectories, *d)
+	}
+	for d, files := range byDigest {
+		layers := make(map[int]struct{})
+		for _, file := range files {
+			layers[file.Layer] = struct{}{}
+		}
+		if len(layers) < 2 {
+			continue
+		}
+		slices.SortFunc(files, func(a, b File) int {
+			return cmp.Or(cmp.Compare(a.Layer, b.Layer), strings.Compare(a.Path, b.Path))
+		})
+		report.Duplicates = append(report.Duplicates, DuplicateFiles{Digest: d, Size: files[0].Size, Files: files})
+		report.DuplicateBytes += files[0].Size * int64(len(files)-1)
+	}
+
+	// largest first, then by path, so that the output is stable
+	slices.SortFunc(report.Wasted, func(a, b WastedFile) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), strings.Compare(a.Path, b.Path), cmp.Compare(a.Layer, b.Layer))
+	})
+	slices.SortFunc(report.LargestFiles, func(a, b File) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), strings.Compare(a.Path, b.Path))
+	})
+	slices.SortFunc(report.LargestDirectories, func(a, b Directory) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), strings.Compare(a.Path, b.Path))
+	})
+	slices.SortFunc(report.Duplicates, func(a, b DuplicateFiles) int {
+		return cmp.Or(cmp.Compare(b.Size*int64(len(b.Files)-1), a.Size*int64(len(a.Files)-1)), strings.Compare(a.Files[0].Path, b.Files[0].Path))
+	})
+	if top >= 0 {
+		report.Wasted = report.Wasted[:min(top, len(report.Wasted))]
+		report.LargestFiles = report.LargestFiles[:min(top, len(report.LargestFiles))]
+		report.LargestDirectories = report.LargestDirectories[:min(top, len(report.LargestDirectories))]
+		report.Duplicates = report.Duplicates[:min(top, len(report.Duplicates))]
+	}
+	return report
+}
+
+// Item is an item in a layer which is present in the final filesystem.
+type Item struct {
+	// Position is the index of the item's header in the layer's tar
+	// stream.
+	Position int
+	Path     string
+	// Size is the size of the item's contents, if it is a regular file.
+	Size int64
+	// Link is the item which the item is a hard link to, if it is one.
+	Link string
+	// LinkContent locates the regular file whose contents a hard link
+	// shares, as it was when the link was added.  It is nil if the item
+	// isn't a hard link, or if its target wasn't found.
+	LinkContent *Content
+}
+
+// Content locates the header of a regular file in a layer.
+type Content struct {
+	// Layer is the index of the layer.
+	Layer int
+	// Position is the index of the file's header in the layer's tar
+	// stream.
+	Position int
+	// Size is the size of the file's contents.
+	Size int64
+}
+
+// Surviving reads the layers, which are listed starting with the base layer,
+// and returns, for each of them, the items in it which are present in the
+// final filesystem, in the order in which they appear in the layer.  Items
+// which are overwritten or deleted by later layers, and whiteouts, are not
+// included.  The report includes totals, but no lists of files.
+func Surviving(layers []Layer) (*Report, [][]Item, error) {
+	a, err := read(layers)
+	if err != nil {
+		return nil, nil, err
+	}
+	items := make([][]Item, len(layers))
+	for name, e := range a.entries {
+		items[e.layer] = append(items[e.layer], Item{Position: e.position, Path: name, Size: e.size, Link: e.link, LinkContent: e.linkContent})
+	}
+	for i := range items {
+		slices.SortFunc(items[i], func(a, b Item) int {
+			return cmp.Compare(a.Position, b.Position)
+		})
+	}
+	return a.summarize(0), items, nil
+}
@@ -0,0 +1,175 @@
+package analyze
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLayer builds a layer from a list of items, where names which end with
+// "/" are directories, and everything else is a file with the specified
+// contents.
+func testLayer(t testing.TB, id string, items ...string) Layer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; i < len(items); i += 2 {
+		name, contents := items[i], items[i+1]
+		hdr := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(contents))}
+		if strings.HasSuffix(name, "/") {
+			hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0o755, 0
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return Layer{
+		ID: id,
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+		},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	t.Parallel()
+	layers := []Layer{
+		testLayer(t, "base",
+			"etc/", "",
+			"etc/config", "12345678",
+			"usr/", "",
+			"usr/lib/", "",
+			"usr/lib/big", strings.Repeat("x", 100),
+			"usr/lib/cache/", "",
+			"usr/lib/cache/a", "aaaa",
+			"usr/lib/cache/b", "bbbbbb",
+			"var/", "",
+			"var/tmp", "temporary",
+		),
+		testLayer(t, "update",
+			"etc/", "",
+			"etc/config", "123",
+			"usr/lib/cache/", "",
+			"usr/lib/cache/.wh..wh..opq", "",
+			"usr/lib/cache/c", "c",
+			"var/.wh.tmp", "",
+			"opt/", "",
+			"opt/big", strings.Repeat("x", 100),
+		),
+		// a directory which was only created implicitly doesn't hide
+		// its contents
+		testLayer(t, "implicit", "srv/data", "data"),
+		testLayer(t, "directory", "srv/", ""),
+	}
+	layers[1].CreatedBy = "/bin/sh -c update"
+
+	report, err := Analyze(layers, -1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8+100+4+6+9+3+1+100+4), report.TotalBytes)
+	assert.Equal(t, int64(3+100+1+100+4), report.FinalBytes)
+	assert.Equal(t, int64(8+4+6+9), report.WastedBytes)
+	assert.Equal(t, int64(100), report.DuplicateBytes)
+	assert.InDelta(t, float64(235-27)/235, report.Efficiency, 0.0001)
+
+	assert.Equal(t, []LayerReport{
+		{Index: 0, ID: "base", AddedBytes: 127, Files: 10},
+		{Index: 1, ID: "update", CreatedBy: "/bin/sh -c update", AddedBytes: 104, RemovedBytes: 27, Files: 6, Deletions: 2},
+		{Index: 2, ID: "implicit", AddedBytes: 4, Files: 1},
+		{Index: 3, ID: "directory", Files: 1},
+	}, report.Layers)
+	assert.Equal(t, []WastedFile{
+		{Path: "/var/tmp", Size: 9, Layer: 0, RemovedBy: 1},
+		{Path: "/etc/config", Size: 8, Layer: 0, RemovedBy: 1, Overwritten: true},
+		{Path: "/usr/lib/cache/b", Size: 6, Layer: 0, RemovedBy: 1},
+		{Path: "/usr/lib/cache/a", Size: 4, Layer: 0, RemovedBy: 1},
+	}, report.Wasted)
+	assert.Equal(t, []File{
+		{Path: "/opt/big", Size: 100, Layer: 1},
+		{Path: "/usr/lib/big", Size: 100, Layer: 0},
+		{Path: "/srv/data", Size: 4, Layer: 2},
+		{Path: "/etc/config", Size: 3, Layer: 1},
+		{Path: "/usr/lib/cache/c", Size: 1, Layer: 1},
+	}, report.LargestFiles)
+	assert.Equal(t, []Directory{
+		{Path: "/usr", Size: 101, Files: 2},
+		{Path: "/usr/lib", Size: 101, Files: 2},
+		{Path: "/opt", Size: 100, Files: 1},
+		{Path: "/srv", Size: 4, Files: 1},
+		{Path: "/etc", Size: 3, Files: 1},
+		{Path: "/usr/lib/cache", Size: 1, Files: 1},
+	}, report.LargestDirectories)
+	assert.Equal(t, []DuplicateFiles{{
+		Digest: digest.FromString(strings.Repeat("x", 100)),
+		Size:   100,
+		Files:  []File{{Path: "/usr/lib/big", Size: 100, Layer: 0}, {Path: "/opt/big", Size: 100, Layer: 1}},
+	}}, report.Duplicates)
+
+	report, err = Analyze(layers, 1)
+	require.NoError(t, err)
+	assert.Len(t, report.Wasted, 1)
+	assert.Len(t, report.LargestFiles, 1)
+	assert.Len(t, report.LargestDirectories, 1)
+	assert.Equal(t, int64(27), report.WastedBytes)
+
+	report, err = Analyze(nil, 10)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), report.Efficiency)
+}
+
+func TestAnalyzeSubtrees(t *testing.T) {
+	t.Parallel()
+	layers := []Layer{
+		testLayer(t, "base",
+			"a/", "",
+			"a/b/", "",
+			"a/b/c/", "",
+			"a/b/c/deep", "deep",
+			"a/bb", "bb",
+			"ab", "ab",
+			"d/", "",
+			"d/e/f", "f",
+		),
+		// a whiteout hides everything under the directory, and a
+		// file replaces a directory, but neither affects items whose
+		// names merely start with the same characters
+		testLayer(t, "remove",
+			"a/.wh.b", "",
+			"d", "now a file",
+		),
+	}
+	report, err := Analyze(layers, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []WastedFile{
+		{Path: "/a/b/c/deep", Size: 4, Layer: 0, RemovedBy: 1},
+		{Path: "/d/e/f", Size: 1, Layer: 0, RemovedBy: 1, Overwritten: true},
+	}, report.Wasted)
+
+	_, items, err := Surviving(layers)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	var paths []string
+	for _, item := range items[0] {
+		paths = append(paths, item.Path)
+	}
+	assert.Equal(t, []string{"/a", "/a/bb", "/ab"}, paths)
+}
+
+func BenchmarkAnalyze(b *testing.B) {
+	// many files in one directory, each of which a later layer replaces
+	var items []string
+	for i := range 20000 {
+		items = append(items, fmt.Sprintf("dir/file%d", i), "contents")
+	}
+	layers := []Layer{testLayer(b, "base", items...), testLayer(b, "update", items...)}
+	for b.Loop() {
+		_, err := Analyze(layers, 10)
+		require.NoError(b, err)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/containers/luksy"
 	"github.com/docker/go-units"
@@ -30,7 +31,10 @@ import (
 	"go.podman.io/storage/pkg/system"
 )
 
-const minimumImageSize = 10 * 1024 * 1024
+const (
+	minimumImageSize    = 10 * 1024 * 1024
+	minimumXFSImageSize = 300 * 1024 * 1024 // mkfs.xfs refuses to create anything smaller
+)
 
 // ArchiveOptions includes optional settings for generating an archive.
 type ArchiveOptions struct {
@@ -55,6 +59,19 @@ type ArchiveOptions struct {
 	Logger                   *logrus.Logger
 	GraphOptions             []string // passed in from a storage Store, probably
 	ExtraImageContent        map[string]string
+
+	// How the disk image is laid out.  If left unset, an ext4 filesystem
+	// is encrypted using LUKS1 with a default cipher, and the disk image
+	// doesn't include a dm-verity hash tree.  There are no settings for the
+	// key derivation function: luksy uses PBKDF2 for LUKS1 and Argon2i for
+	// LUKS2, and always benchmarks the system to choose their parameters,
+	// without offering a way to override them.
+	Filesystem            string // "ext4", "xfs", or "erofs" (read-only)
+	Encryption            string // EncryptionLUKS1, EncryptionLUKS2, or EncryptionNone
+	Cipher                string // e.g., "aes-xts-plain64"
+	SectorSize            int    // payload sector size, for LUKS2
+	Verity                bool   // append a dm-verity hash tree, and record its root hash
+	DiskEncryptionKeyFile string // read the passphrase from this file, as-is
 }
 
 type chainRetrievalError struct {
@@ -103,7 +120,31 @@ func Archive(rootfsPath string, ociConfig *v1.Image, options ArchiveOptions) (io
 	if memory == 0 {
 		memory = teeDefaultMemory
 	}
-	filesystem := teeDefaultFilesystem
+	filesystem := options.Filesystem
+	if filesystem == "" {
+		filesystem = teeDefaultFilesystem
+	}
+	switch filesystem {
+	case "ext4", "xfs", "erofs":
+	default:
+		return nil, WorkloadConfig{}, fmt.Errorf("unsupported filesystem type %q for disk image", filesystem)
+	}
+	encryption := options.Encryption
+	if encryption == "" {
+		encryption = EncryptionLUKS1
+	}
+	switch encryption {
+	case EncryptionLUKS1, EncryptionLUKS2:
+	case EncryptionNone:
+		if !options.Verity {
+			return nil, WorkloadConfig{}, errors.New("disk image would be neither encrypted nor protected by dm-verity")
+		}
+		if options.DiskEncryptionPassphrase != "" || options.DiskEncryptionKeyFile != "" {
+			return nil, WorkloadConfig{}, errors.New("passphrase specified, but disk image is not going to be encrypted")
+		}
+	default:
+		return nil, WorkloadConfig{}, fmt.Errorf("unsupported encryption type %q for disk image", encryption)
+	}
 	workloadID := options.WorkloadID
 	if workloadID == "" {
 		digestInput := rootfsPath + filesystem + time.Now().String()
@@ -116,6 +157,12 @@ func Archive(rootfsPath string, ociConfig *v1.Image, options ArchiveOptions) (io
 		Memory:         memory,
 		AttestationURL: options.AttestationURL,
 	}
+	if filesystem != teeDefaultFilesystem {
+		workloadConfig.Filesystem = filesystem
+	}
+	if encryption != EncryptionLUKS1 {
+		workloadConfig.Encryption = encryption
+	}
 	if options.TempDir == "" {
 		options.TempDir = tmpdir.GetTempDir()
 	}
@@ -175,6 +222,12 @@ func Archive(rootfsPath string, ociConfig *v1.Image, options ArchiveOptions) (io
 			return nil, WorkloadConfig{}, fmt.Errorf("encoding tee data: %w", err)
 		}
 		workloadConfig.TeeData = string(encodedTeeData)
+	case TDX:
+		encodedTeeData, err := json.Marshal(TdxWorkloadData{})
+		if err != nil {
+			return nil, WorkloadConfig{}, fmt.Errorf("encoding tee data: %w", err)
+		}
+		workloadConfig.TeeData = string(encodedTeeData)
 	}
 
 	// We're going to want to add some content to the rootfs, so set up an
@@ -294,55 +347,52 @@ func Archive(rootfsPath string, ociConfig *v1.Image, options ArchiveOptions) (io
 		return nil, WorkloadConfig{}, fmt.Errorf("saving krun config: %w", err)
 	}
 
-	// Encode the workload config, in case it fails for any reason.
-	cleanedUpWorkloadConfig := workloadConfig
-	switch cleanedUpWorkloadConfig.Type {
-	default:
-		return nil, WorkloadConfig{}, fmt.Errorf("don't know how to canonicalize TEE type %q", cleanedUpWorkloadConfig.Type)
-	case SEV, SEV_NO_ES:
-		cleanedUpWorkloadConfig.Type = SEV
-	case SNP:
-		cleanedUpWorkloadConfig.Type = SNP
-	}
-	workloadConfigBytes, err := json.Marshal(cleanedUpWorkloadConfig)
-	if err != nil {
-		return nil, WorkloadConfig{}, err
-	}
-
 	// Make sure we have the passphrase to use for encrypting the disk image.
 	diskEncryptionPassphrase := options.DiskEncryptionPassphrase
-	if diskEncryptionPassphrase == "" {
+	if options.DiskEncryptionKeyFile != "" {
+		if diskEncryptionPassphrase != "" {
+			return nil, WorkloadConfig{}, errors.New("both a passphrase and a key file were specified")
+		}
+		key, err := os.ReadFile(options.DiskEncryptionKeyFile)
+		if err != nil {
+			return nil, WorkloadConfig{}, fmt.Errorf("reading disk encryption key file: %w", err)
+		}
+		if len(key) == 0 {
+			return nil, WorkloadConfig{}, fmt.Errorf("disk encryption key file %q is empty", options.DiskEncryptionKeyFile)
+		}
+		// the registration request is JSON, which can't carry arbitrary bytes
+		if workloadConfig.AttestationURL != "" && !utf8.Valid(key) {
+			return nil, WorkloadConfig{}, fmt.Errorf("disk encryption key file %q can't be registered with an attestation server: contents are not valid UTF-8", options.DiskEncryptionKeyFile)
+		}
+		diskEncryptionPassphrase = string(key)
+	}
+	if diskEncryptionPassphrase == "" && encryption != EncryptionNone {
 		diskEncryptionPassphrase, err = GenerateDiskEncryptionPassphrase()
 		if err != nil {
 			return nil, WorkloadConfig{}, err
 		}
 	}
 
-	// If we weren't told how big the image should be, get a rough estimate
-	// of the input data size, then add a hedge to it.
-	imageSize := slop(options.ImageSize, options.Slop)
-	if imageSize == 0 {
-		var sourceSize int64
-		if err := filepath.WalkDir(rootfsPath, func(_ string, d fs.DirEntry, err error) error {
-			if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, os.ErrPermission) {
-				return err
-			}
-			info, err := d.Info()
-			if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, os.ErrPermission) {
-				return err
-			}
-			sourceSize += info.Size()
-			return nil
-		}); err != nil {
-			return nil, WorkloadConfig{}, err
-		}
-		imageSize = slop(sourceSize, options.Slop)
+	// Prepare to encrypt the disk image, so that we find out about any
+	// problems with the encryption settings before we do the heavy lifting.
+	var encryptionHeader []byte
+	var encrypt func([]byte) ([]byte, error)
+	var encryptionBlockSize int
+	switch encryption {
+	case EncryptionLUKS1:
+		encryptionHeader, encrypt, encryptionBlockSize, err = luksy.EncryptV1([]string{diskEncryptionPassphrase}, options.Cipher)
+	case EncryptionLUKS2:
+		encryptionHeader, encrypt, encryptionBlockSize, err = luksy.EncryptV2([]string{diskEncryptionPassphrase}, options.Cipher, options.SectorSize)
 	}
-	if imageSize%4096 != 0 {
-		imageSize += (4096 - (imageSize % 4096))
+	if err != nil {
+		return nil, WorkloadConfig{}, fmt.Errorf("preparing to encrypt disk image: %w", err)
 	}
-	if imageSize < minimumImageSize {
-		imageSize = minimumImageSize
+
+	// If we weren't told how big the image should be, get a rough estimate
+	// of the input data size, then add a hedge to it.
+	imageSize, err := diskImageSize(rootfsPath, options.ImageSize, options.Slop, filesystem)
+	if err != nil {
+		return nil, WorkloadConfig{}, err
 	}
 
 	// Create a file to use as the unencrypted version of the disk image.
@@ -359,16 +409,15 @@ func Archive(rootfsPath string, ociConfig *v1.Image, options ArchiveOptions) (io
 		}
 	}()
 
-	// Lengthen the plaintext disk image file.
-	if err := plain.Truncate(imageSize); err != nil {
-		plain.Close()
-		return nil, WorkloadConfig{}, err
+	// Lengthen the plaintext disk image file, unless we're using a
+	// filesystem that will be sized to fit its contents.
+	if filesystem != "erofs" {
+		if err := plain.Truncate(imageSize); err != nil {
+			plain.Close()
+			return nil, WorkloadConfig{}, err
+		}
 	}
-	plainInfo, err := plain.Stat()
 	plain.Close()
-	if err != nil {
-		return nil, WorkloadConfig{}, err
-	}
 
 	// Format the disk image with the filesystem contents.
 	if _, stderr, err := MakeFS(rootfsPath, plain.Name(), filesystem); err != nil {
@@ -377,6 +426,48 @@ func Archive(rootfsPath string, ociConfig *v1.Image, options ArchiveOptions) (io
 		}
 		return nil, WorkloadConfig{}, err
 	}
+	plainInfo, err := os.Stat(plain.Name())
+	if err != nil {
+		return nil, WorkloadConfig{}, err
+	}
+	dataSize := plainInfo.Size()
+	if dataSize%4096 != 0 {
+		dataSize += 4096 - (dataSize % 4096)
+		if err := os.Truncate(plain.Name(), dataSize); err != nil {
+			return nil, WorkloadConfig{}, err
+		}
+	}
+
+	// Add a dm-verity hash tree after the filesystem, if we're adding one.
+	if options.Verity {
+		rootHash, err := AppendVerityHashTree(plain.Name(), dataSize)
+		if err != nil {
+			return nil, WorkloadConfig{}, err
+		}
+		workloadConfig.VerityRootHash = rootHash
+		workloadConfig.VerityHashOffset = dataSize
+		if plainInfo, err = os.Stat(plain.Name()); err != nil {
+			return nil, WorkloadConfig{}, err
+		}
+	}
+	payloadSize := plainInfo.Size()
+
+	// Encode the workload config, in case it fails for any reason.
+	cleanedUpWorkloadConfig := workloadConfig
+	switch cleanedUpWorkloadConfig.Type {
+	default:
+		return nil, WorkloadConfig{}, fmt.Errorf("don't know how to canonicalize TEE type %q", cleanedUpWorkloadConfig.Type)
+	case SEV, SEV_NO_ES:
+		cleanedUpWorkloadConfig.Type = SEV
+	case SNP:
+		cleanedUpWorkloadConfig.Type = SNP
+	case TDX:
+		cleanedUpWorkloadConfig.Type = TDX
+	}
+	workloadConfigBytes, err := json.Marshal(cleanedUpWorkloadConfig)
+	if err != nil {
+		return nil, WorkloadConfig{}, err
+	}
 
 	// If we're registering the workload, we can do that now.
 	if workloadConfig.AttestationURL != "" {
@@ -508,27 +599,36 @@ func Archive(rootfsPath string, ociConfig *v1.Image, options ArchiveOptions) (io
 		footer.Write(lengthBuffer)
 
 		// Start encrypting and write /disk.img.
-		header, encrypt, blockSize, err := luksy.EncryptV1([]string{diskEncryptionPassphrase}, "")
 		paddingBoundary := int64(4096)
-		paddingNeeded := (paddingBoundary - ((int64(len(header)) + imageSize + int64(footer.Len())) % paddingBoundary)) % paddingBoundary
+		paddingNeeded := (paddingBoundary - ((int64(len(encryptionHeader)) + payloadSize + int64(footer.Len())) % paddingBoundary)) % paddingBoundary
 		diskHeader := workloadConfigHeader
 		diskHeader.Name = "disk.img"
 		diskHeader.Mode = 0o600
-		diskHeader.Size = int64(len(header)) + imageSize + paddingNeeded + int64(footer.Len())
+		diskHeader.Size = int64(len(encryptionHeader)) + payloadSize + paddingNeeded + int64(footer.Len())
 		if err = tw.WriteHeader(diskHeader); err != nil {
 			logrus.Errorf("writing archive header for disk.img: %v", err)
 			return
 		}
-		if _, err = io.Copy(tw, bytes.NewReader(header)); err != nil {
-			logrus.Errorf("writing encryption header for disk.img: %v", err)
-			return
-		}
-		encryptWrapper := luksy.EncryptWriter(encrypt, tw, blockSize)
-		if _, err = io.Copy(encryptWrapper, plain); err != nil {
-			logrus.Errorf("encrypting disk.img: %v", err)
-			return
+		if encrypt == nil {
+			if _, err = io.Copy(tw, plain); err != nil {
+				logrus.Errorf("writing disk.img: %v", err)
+				return
+			}
+		} else {
+			if _, err = io.Copy(tw, bytes.NewReader(encryptionHeader)); err != nil {
+				logrus.Errorf("writing encryption header for disk.img: %v", err)
+				return
+			}
+			encryptWrapper := luksy.EncryptWriter(encrypt, tw, encryptionBlockSize)
+			if _, err = io.Copy(encryptWrapper, plain); err != nil {
+				logrus.Errorf("encrypting disk.img: %v", err)
+				return
+			}
+			if err = encryptWrapper.Close(); err != nil {
+				logrus.Errorf("encrypting disk.img: %v", err)
+				return
+			}
 		}
-		encryptWrapper.Close()
 		if _, err = tw.Write(make([]byte, paddingNeeded)); err != nil {
 			logrus.Errorf("writing padding for disk.img: %v", err)
 			return
@@ -543,6 +643,43 @@ func Archive(rootfsPath string, ociConfig *v1.Image, options ArchiveOptions) (io
 	return pipeReader, workloadConfig, nil
 }
 
+// diskImageSize returns the size of a disk image which should be large enough
+// to hold a filesystem of the specified type, populated with the contents of
+// sourcePath.  If imageSize is zero, the size of the contents is estimated
+// from the sizes of the files under sourcePath.  Either way, the slop spec is
+// applied to the size, which is then rounded up.
+func diskImageSize(sourcePath string, imageSize int64, slopSpec, filesystem string) (int64, error) {
+	if imageSize != 0 {
+		imageSize = slop(imageSize, slopSpec)
+	} else {
+		var sourceSize int64
+		if err := filepath.WalkDir(sourcePath, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, os.ErrPermission) {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, os.ErrPermission) {
+				return err
+			}
+			sourceSize += info.Size()
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		imageSize = slop(sourceSize, slopSpec)
+	}
+	if imageSize%4096 != 0 {
+		imageSize += (4096 - (imageSize % 4096))
+	}
+	if imageSize < minimumImageSize {
+		imageSize = minimumImageSize
+	}
+	if filesystem == "xfs" && imageSize < minimumXFSImageSize {
+		imageSize = minimumXFSImageSize
+	}
+	return imageSize, nil
+}
+
 func slop(size int64, slop string) int64 {
 	if slop == "" {
 		return size * 5 / 4
@@ -42,11 +42,13 @@ func TestSlop(t *testing.T) {
 }
 
 // dummyAttestationHandler replies with a fixed response code to requests to
-// the right path, and caches passphrases indexed by workload ID
+// the right path, and caches passphrases and registration requests indexed by
+// workload ID
 type dummyAttestationHandler struct {
 	t               *testing.T
 	status          int
 	passphrases     map[string]string
+	requests        map[string]RegistrationRequest
 	passphrasesLock sync.Mutex
 }
 
@@ -72,6 +74,10 @@ func (d *dummyAttestationHandler) ServeHTTP(rw http.ResponseWriter, req *http.Re
 			d.passphrases = make(map[string]string)
 		}
 		d.passphrases[registrationRequest.WorkloadID] = registrationRequest.Passphrase
+		if d.requests == nil {
+			d.requests = make(map[string]RegistrationRequest)
+		}
+		d.requests[registrationRequest.WorkloadID] = registrationRequest
 		d.passphrasesLock.Unlock()
 		// return the predetermined status
 		status := d.status
@@ -24,6 +24,7 @@ type (
 	TeeConfig           = types.TeeConfig
 	TeeConfigFlags      = types.TeeConfigFlags
 	TeeConfigMinFW      = types.TeeConfigMinFW
+	TdxTeeConfig        = types.TdxTeeConfig
 )
 
 type measurementError struct {
@@ -117,6 +118,16 @@ func SendRegistrationRequest(workloadConfig WorkloadConfig, diskEncryptionPassph
 		if err != nil {
 			return err
 		}
+	case TDX:
+		// Expect a TD which can't be debugged, and which doesn't let
+		// the host inject #VE exceptions by playing with its EPT.
+		teeConfig := TdxTeeConfig{
+			Attributes: types.TDX_ATTRIBUTES_SEPT_VE_DISABLE,
+		}
+		teeConfigBytes, err = json.Marshal(teeConfig)
+		if err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("don't know how to generate tee_config for %q TEEs", workloadConfig.Type)
 	}
@@ -171,13 +182,17 @@ func SendRegistrationRequest(workloadConfig WorkloadConfig, diskEncryptionPassph
 
 // GenerateMeasurement generates the runtime measurement using the CPU count,
 // memory size, and the firmware shared library, whatever it's called, wherever
-// it is.
+// it is.  For TDX, the firmware is a TDVF firmware image instead, and the CPU
+// count and memory size don't affect the measurement.
 // If firmwareLibrary is a path, it will be the only one checked.
 // If firmwareLibrary is a filename, it will be checked for in a hard-coded set
 // of directories.
 // If firmwareLibrary is empty, both the filename and the directory it is in
 // will be taken from a hard-coded set of candidates.
 func GenerateMeasurement(workloadConfig WorkloadConfig, firmwareLibrary string) (string, error) {
+	if workloadConfig.Type == TDX {
+		return generateTDXMeasurement(firmwareLibrary)
+	}
 	cpuString := fmt.Sprintf("%d", workloadConfig.CPUs)
 	memoryString := fmt.Sprintf("%d", workloadConfig.Memory)
 	var prefix string
@@ -0,0 +1,71 @@
+package mkcw
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"go.podman.io/storage"
+	"go.podman.io/storage/pkg/archive"
+)
+
+// diskImageName is the location of the encrypted disk image in a confidential
+// workload image's rootfs.
+const diskImageName = "disk.img"
+
+// copyDiskImageFromLayer looks for the disk image in the layer diff which
+// layer reads from, and if it finds it, copies it to imageFile.
+// Returns true if it found the disk image.
+func copyDiskImageFromLayer(layer io.Reader, imageFile string) (bool, error) {
+	tr := tar.NewReader(layer)
+	hdr, err := tr.Next()
+	for err == nil {
+		if path.Clean("/"+hdr.Name) == "/"+diskImageName && hdr.Typeflag == tar.TypeReg {
+			f, err := os.OpenFile(imageFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+			if err != nil {
+				return false, err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return false, fmt.Errorf("copying %s: %w", diskImageName, err)
+			}
+			return true, f.Close()
+		}
+		hdr, err = tr.Next()
+	}
+	if !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return false, nil
+}
+
+// CopyDiskImage copies the encrypted disk image out of a confidential workload
+// image which is in the store, writing it to imageFile.  Layers are searched
+// starting with the top layer, since the disk image is normally added on top
+// of a (possibly empty) base image.
+func CopyDiskImage(store storage.Store, image *storage.Image, imageFile string) error {
+	uncompressed := archive.Uncompressed
+	for layerID := image.TopLayer; layerID != ""; {
+		layer, err := store.Layer(layerID)
+		if err != nil {
+			return fmt.Errorf("locating layer %q: %w", layerID, err)
+		}
+		rc, err := store.Diff("", layerID, &storage.DiffOptions{Compression: &uncompressed})
+		if err != nil {
+			return fmt.Errorf("reading layer %q: %w", layerID, err)
+		}
+		found, err := copyDiskImageFromLayer(rc, imageFile)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("reading layer %q: %w", layerID, err)
+		}
+		if found {
+			return nil
+		}
+		layerID = layer.Parent
+	}
+	return fmt.Errorf("image %q does not contain /%s, is it a confidential workload image?", image.ID, diskImageName)
+}
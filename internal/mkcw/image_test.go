@@ -0,0 +1,204 @@
+package mkcw
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyDiskImageFromLayer(t *testing.T) {
+	t.Parallel()
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	for _, entry := range []struct {
+		name     string
+		typeflag byte
+		contents string
+	}{
+		{"entrypoint", tar.TypeReg, "not it"},
+		{"disk.img/", tar.TypeDir, ""},
+		{"./disk.img", tar.TypeReg, "the disk image"},
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: entry.name, Typeflag: entry.typeflag, Mode: 0o644, Size: int64(len(entry.contents))}))
+		_, err := tw.Write([]byte(entry.contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	imageFile := filepath.Join(t.TempDir(), "disk.img")
+	found, err := copyDiskImageFromLayer(bytes.NewReader(layer.Bytes()), imageFile)
+	require.NoError(t, err)
+	require.True(t, found)
+	contents, err := os.ReadFile(imageFile)
+	require.NoError(t, err)
+	assert.Equal(t, "the disk image", string(contents))
+
+	var empty bytes.Buffer
+	require.NoError(t, tar.NewWriter(&empty).Close())
+	found, err = copyDiskImageFromLayer(&empty, filepath.Join(t.TempDir(), "disk.img"))
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestDecryptAndExtract(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("debugfs"); err != nil {
+		t.Skip("debugfs not found")
+	}
+	inputPath := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(inputPath, "etc", "app"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(inputPath, "etc", "app", "config"), []byte("settings\n"), 0o640))
+	require.NoError(t, os.Symlink("app/config", filepath.Join(inputPath, "etc", "config")))
+
+	archiveOptions := ArchiveOptions{
+		TempDir:                  t.TempDir(),
+		DiskEncryptionPassphrase: "extract me",
+		IgnoreAttestationErrors:  true,
+	}
+	rc, workloadConfig, err := Archive(inputPath, &v1.Image{}, archiveOptions)
+	if err != nil {
+		if _, lookErr := exec.LookPath("mkfs.ext4"); lookErr != nil {
+			t.Skip("mkfs.ext4 not found")
+		}
+		require.NoError(t, err)
+	}
+	defer rc.Close()
+	diskImage := filepath.Join(t.TempDir(), "disk.img")
+	found, err := copyDiskImageFromLayer(rc, diskImage)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// the workload configuration is readable without the passphrase
+	readWorkloadConfig, err := ReadWorkloadConfigFromImage(diskImage)
+	require.NoError(t, err)
+	assert.Equal(t, workloadConfig, readWorkloadConfig)
+
+	// the wrong passphrase shouldn't get us anywhere
+	assert.Error(t, DecryptLUKS(diskImage, "not it", io.Discard))
+
+	plaintextImage := filepath.Join(t.TempDir(), "plaintext.img")
+	f, err := os.Create(plaintextImage)
+	require.NoError(t, err)
+	require.NoError(t, DecryptLUKS(diskImage, "extract me", f))
+	require.NoError(t, f.Close())
+
+	destination := t.TempDir()
+	_, _, err = ExtractFS(plaintextImage, destination, "ext4")
+	require.NoError(t, err)
+	contents, err := os.ReadFile(filepath.Join(destination, "etc", "app", "config"))
+	require.NoError(t, err)
+	assert.Equal(t, "settings\n", string(contents))
+	target, err := os.Readlink(filepath.Join(destination, "etc", "config"))
+	require.NoError(t, err)
+	assert.Equal(t, "app/config", target)
+	assert.FileExists(t, filepath.Join(destination, ".krun_config.json"))
+
+	// reading a file that isn't a filesystem should fail, even though
+	// debugfs exits with status 0
+	_, _, err = ExtractFS(diskImage, t.TempDir(), "ext4")
+	assert.Error(t, err)
+	_, _, err = ExtractFS(plaintextImage, t.TempDir(), "xfs")
+	assert.Error(t, err)
+}
+
+func TestArchiveVerityAndKeyFile(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("debugfs"); err != nil {
+		t.Skip("debugfs not found")
+	}
+	if _, err := exec.LookPath("mkfs.ext4"); err != nil {
+		t.Skip("mkfs.ext4 not found")
+	}
+	inputPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(inputPath, "file"), []byte("contents\n"), 0o644))
+
+	// settings which don't make sense should be rejected up front
+	for _, options := range []ArchiveOptions{
+		{Encryption: EncryptionNone},
+		{Encryption: EncryptionNone, Verity: true, DiskEncryptionPassphrase: "unused"},
+		{Encryption: "luks3", DiskEncryptionPassphrase: "secret"},
+		{Filesystem: "vfat", DiskEncryptionPassphrase: "secret"},
+		{Cipher: "rot13", DiskEncryptionPassphrase: "secret"},
+		{DiskEncryptionPassphrase: "secret", DiskEncryptionKeyFile: "/dev/null"},
+	} {
+		options.TempDir = t.TempDir()
+		options.IgnoreAttestationErrors = true
+		_, _, err := Archive(inputPath, &v1.Image{}, options)
+		assert.Errorf(t, err, "options %+v", options)
+	}
+
+	t.Run("verity", func(t *testing.T) {
+		t.Parallel()
+		rc, workloadConfig, err := Archive(inputPath, &v1.Image{}, ArchiveOptions{
+			TempDir:                 t.TempDir(),
+			Encryption:              EncryptionNone,
+			Verity:                  true,
+			IgnoreAttestationErrors: true,
+		})
+		require.NoError(t, err)
+		defer rc.Close()
+		assert.Equal(t, EncryptionNone, workloadConfig.Encryption)
+		assert.NotEmpty(t, workloadConfig.VerityRootHash)
+		diskImage := filepath.Join(t.TempDir(), "disk.img")
+		found, err := copyDiskImageFromLayer(rc, diskImage)
+		require.NoError(t, err)
+		require.True(t, found)
+
+		readWorkloadConfig, err := ReadWorkloadConfigFromImage(diskImage)
+		require.NoError(t, err)
+		assert.Equal(t, workloadConfig, readWorkloadConfig)
+
+		// the filesystem is stored as-is, followed by the hash tree
+		image, err := os.ReadFile(diskImage)
+		require.NoError(t, err)
+		require.NoError(t, checkVerity(image, workloadConfig.VerityHashOffset, workloadConfig.VerityRootHash))
+		plaintextImage := filepath.Join(t.TempDir(), "plaintext.img")
+		require.NoError(t, os.WriteFile(plaintextImage, image[:workloadConfig.VerityHashOffset], 0o600))
+		destination := t.TempDir()
+		_, _, err = ExtractFS(plaintextImage, destination, "ext4")
+		require.NoError(t, err)
+		contents, err := os.ReadFile(filepath.Join(destination, "file"))
+		require.NoError(t, err)
+		assert.Equal(t, "contents\n", string(contents))
+	})
+
+	t.Run("luks2-key-file", func(t *testing.T) {
+		t.Parallel()
+		keyFile := filepath.Join(t.TempDir(), "key")
+		require.NoError(t, os.WriteFile(keyFile, []byte("key file\ncontents"), 0o600))
+		rc, workloadConfig, err := Archive(inputPath, &v1.Image{}, ArchiveOptions{
+			TempDir:                 t.TempDir(),
+			Encryption:              EncryptionLUKS2,
+			DiskEncryptionKeyFile:   keyFile,
+			IgnoreAttestationErrors: true,
+		})
+		require.NoError(t, err)
+		defer rc.Close()
+		assert.Equal(t, EncryptionLUKS2, workloadConfig.Encryption)
+		assert.Empty(t, workloadConfig.VerityRootHash)
+		diskImage := filepath.Join(t.TempDir(), "disk.img")
+		found, err := copyDiskImageFromLayer(rc, diskImage)
+		require.NoError(t, err)
+		require.True(t, found)
+
+		assert.Error(t, CheckLUKSPassphrase(diskImage, "key file"))
+		require.NoError(t, CheckLUKSPassphrase(diskImage, "key file\ncontents"))
+		plaintextImage := filepath.Join(t.TempDir(), "plaintext.img")
+		f, err := os.Create(plaintextImage)
+		require.NoError(t, err)
+		require.NoError(t, DecryptLUKS(diskImage, "key file\ncontents", f))
+		require.NoError(t, f.Close())
+		destination := t.TempDir()
+		_, _, err = ExtractFS(plaintextImage, destination, "ext4")
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(destination, "file"))
+	})
+}
@@ -3,7 +3,9 @@ package mkcw
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/containers/luksy"
@@ -41,6 +43,46 @@ func CheckLUKSPassphrase(path, decryptionPassphrase string) error {
 	return nil
 }
 
+// DecryptLUKS decrypts the contents of the specified LUKS-encrypted file using
+// the specified passphrase, and writes the plaintext to output.
+func DecryptLUKS(path, decryptionPassphrase string, output io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	v1header, v2headerA, v2headerB, v2json, err := luksy.ReadHeaders(f, luksy.ReadHeaderOptions{})
+	if err != nil {
+		return err
+	}
+	var decryptStream func([]byte) ([]byte, error)
+	var decryptSectorSize int
+	var payloadOffset, payloadSize int64
+	switch {
+	case v1header != nil:
+		decryptStream, decryptSectorSize, payloadOffset, payloadSize, err = v1header.Decrypt(decryptionPassphrase, f)
+	case v2headerA != nil:
+		decryptStream, decryptSectorSize, payloadOffset, payloadSize, err = v2headerA.Decrypt(decryptionPassphrase, f, *v2json)
+	case v2headerB != nil:
+		decryptStream, decryptSectorSize, payloadOffset, payloadSize, err = v2headerB.Decrypt(decryptionPassphrase, f, *v2json)
+	default:
+		return fmt.Errorf("no LUKS headers read from %q", path)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(payloadOffset, io.SeekStart); err != nil {
+		return err
+	}
+	rc := luksy.DecryptReader(decryptStream, f, decryptSectorSize)
+	reader := io.Reader(rc)
+	if payloadSize >= 0 {
+		reader = io.LimitReader(reader, payloadSize)
+	}
+	_, err = io.Copy(output, reader)
+	return errors.Join(err, rc.Close())
+}
+
 // GenerateDiskEncryptionPassphrase generates a random disk encryption password
 func GenerateDiskEncryptionPassphrase() (string, error) {
 	randomizedBytes := make([]byte, 32)
@@ -2,6 +2,7 @@ package mkcw
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 
@@ -10,14 +11,14 @@ import (
 
 // MakeFS formats the imageFile as a filesystem of the specified type,
 // populating it with the contents of the directory at sourcePath.
-// Recognized filesystem types are "ext2", "ext3", "ext4", and "btrfs".
+// Recognized filesystem types are "ext2", "ext3", "ext4", "btrfs", "xfs",
+// "erofs", and "squashfs".  The "erofs" and "squashfs" filesystems are
+// read-only, and their tools size the imageFile to fit the contents instead of
+// using all of the space in it.
 // Note that krun's init is currently hard-wired to assume "ext4".
 // Returns the stdout, stderr, and any error returned by the mkfs command.
 func MakeFS(sourcePath, imageFile, filesystem string) (string, string, error) {
 	var stdout, stderr strings.Builder
-	// N.B. mkfs.xfs can accept a protofile via its -p option, but the
-	// protofile format doesn't allow us to supply timestamp information or
-	// specify that files are hard linked
 	switch filesystem {
 	case "ext2", "ext3", "ext4":
 		logrus.Debugf("mkfs -t %s --rootdir %q %q", filesystem, sourcePath, imageFile)
@@ -33,6 +34,124 @@ func MakeFS(sourcePath, imageFile, filesystem string) (string, string, error) {
 		cmd.Stderr = &stderr
 		err := cmd.Run()
 		return stdout.String(), stderr.String(), err
+	case "xfs":
+		// N.B. older versions of mkfs.xfs only accept a protofile via
+		// their -p option, and the protofile format doesn't allow us
+		// to supply timestamp information or specify that files are
+		// hard linked, so we require a version that accepts a directory
+		logrus.Debugf("mkfs -t %s -p %q %q", filesystem, sourcePath, imageFile)
+		cmd := exec.Command("mkfs", "-t", filesystem, "-p", sourcePath, imageFile)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		return stdout.String(), stderr.String(), err
+	case "erofs":
+		logrus.Debugf("mkfs -t %s %q %q", filesystem, imageFile, sourcePath)
+		cmd := exec.Command("mkfs", "-t", filesystem, imageFile, sourcePath)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		return stdout.String(), stderr.String(), err
+	case "squashfs":
+		logrus.Debugf("mksquashfs %q %q -noappend -no-progress", sourcePath, imageFile)
+		cmd := exec.Command("mksquashfs", sourcePath, imageFile, "-noappend", "-no-progress")
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		return stdout.String(), stderr.String(), err
 	}
 	return "", "", fmt.Errorf("don't know how to make a %q filesystem with contents", filesystem)
 }
+
+// MakeFSImage creates imageFile, replacing it if it already exists, and
+// formats it as a filesystem of the specified type which is populated with
+// the contents of the directory at sourcePath.  For filesystem types which
+// need to be told how large they should be, the image is sized using the
+// slop spec, in the same way that Archive() sizes its disk images.
+func MakeFSImage(sourcePath, imageFile, filesystem, slopSpec string) error {
+	var imageSize int64
+	switch filesystem {
+	case "erofs", "squashfs":
+	default:
+		size, err := diskImageSize(sourcePath, 0, slopSpec, filesystem)
+		if err != nil {
+			return fmt.Errorf("estimating size of %q: %w", sourcePath, err)
+		}
+		imageSize = size
+	}
+	f, err := os.OpenFile(imageFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if imageSize != 0 {
+		if err := f.Truncate(imageSize); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if _, stderr, err := MakeFS(sourcePath, imageFile, filesystem); err != nil {
+		if strings.TrimSpace(stderr) != "" {
+			return fmt.Errorf("%s: %w", strings.TrimSpace(stderr), err)
+		}
+		return err
+	}
+	return nil
+}
+
+// ExtractFS copies the contents of the filesystem in imageFile, which is of the
+// specified type, to the directory at destination, which must already exist.
+// Recognized filesystem types are "ext2", "ext3", "ext4", "xfs", and "erofs".
+// The filesystem is read without being mounted, so ownership is only preserved
+// if we're running as root, and for the ext and xfs filesystems, hard links are
+// copied as separate files.  Reading an xfs filesystem requires a version of
+// xfs_db which provides the "rdump" command, which any version of mkfs.xfs
+// that MakeFS can use will have been accompanied by.
+// Returns the stdout, stderr, and any error returned by the command which
+// reads the filesystem.
+func ExtractFS(imageFile, destination, filesystem string) (string, string, error) {
+	var stdout, stderr strings.Builder
+	switch filesystem {
+	case "ext2", "ext3", "ext4":
+		logrus.Debugf("debugfs -R \"rdump / %s\" %q", destination, imageFile)
+		cmd := exec.Command("debugfs", "-R", "rdump / "+destination, imageFile)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return stdout.String(), stderr.String(), err
+		}
+		// debugfs exits with status 0 even when its command fails, so
+		// anything it says other than its version banner is a problem
+		for line := range strings.Lines(stderr.String()) {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "debugfs ") {
+				return stdout.String(), stderr.String(), fmt.Errorf("reading %s filesystem: %s", filesystem, line)
+			}
+		}
+		return stdout.String(), stderr.String(), nil
+	case "xfs":
+		logrus.Debugf("xfs_db -r -c \"rdump / %s\" %q", destination, imageFile)
+		cmd := exec.Command("xfs_db", "-r", "-c", "rdump / "+destination, imageFile)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return stdout.String(), stderr.String(), err
+		}
+		// xfs_db doesn't necessarily exit with a non-zero status when
+		// a command fails, so anything it says is a problem
+		if line := strings.TrimSpace(stderr.String()); line != "" {
+			return stdout.String(), stderr.String(), fmt.Errorf("reading %s filesystem: %s", filesystem, line)
+		}
+		return stdout.String(), stderr.String(), nil
+	case "erofs":
+		logrus.Debugf("fsck.erofs --extract=%s %q", destination, imageFile)
+		cmd := exec.Command("fsck.erofs", "--extract="+destination, imageFile)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		return stdout.String(), stderr.String(), err
+	}
+	return "", "", fmt.Errorf("don't know how to read a %q filesystem", filesystem)
+}
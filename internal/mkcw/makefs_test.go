@@ -0,0 +1,78 @@
+package mkcw
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeFSImage(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("debugfs"); err != nil {
+		t.Skip("debugfs not found")
+	}
+	if _, err := exec.LookPath("mkfs.ext4"); err != nil {
+		t.Skip("mkfs.ext4 not found")
+	}
+	sourcePath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourcePath, "file"), []byte("contents\n"), 0o644))
+
+	// an existing file should be replaced, not added to
+	imageFile := filepath.Join(t.TempDir(), "root.img")
+	require.NoError(t, os.WriteFile(imageFile, []byte("not a filesystem"), 0o600))
+	require.NoError(t, MakeFSImage(sourcePath, imageFile, "ext4", ""))
+	st, err := os.Stat(imageFile)
+	require.NoError(t, err)
+	assert.Equal(t, int64(minimumImageSize), st.Size())
+
+	destination := t.TempDir()
+	_, _, err = ExtractFS(imageFile, destination, "ext4")
+	require.NoError(t, err)
+	contents, err := os.ReadFile(filepath.Join(destination, "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "contents\n", string(contents))
+
+	// the slop spec should be honored
+	require.NoError(t, MakeFSImage(sourcePath, imageFile, "ext4", "+16M"))
+	st, err = os.Stat(imageFile)
+	require.NoError(t, err)
+	assert.Greater(t, st.Size(), int64(16*1024*1024))
+
+	assert.Error(t, MakeFSImage(sourcePath, imageFile, "vfat", ""))
+}
+
+func TestMakeFSImageXFS(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("xfs_db"); err != nil {
+		t.Skip("xfs_db not found")
+	}
+	if _, err := exec.LookPath("mkfs.xfs"); err != nil {
+		t.Skip("mkfs.xfs not found")
+	}
+	sourcePath := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(sourcePath, "dir"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(sourcePath, "dir", "file"), []byte("contents\n"), 0o644))
+	require.NoError(t, os.Symlink("dir/file", filepath.Join(sourcePath, "link")))
+
+	imageFile := filepath.Join(t.TempDir(), "root.img")
+	if err := MakeFSImage(sourcePath, imageFile, "xfs", ""); err != nil {
+		t.Skipf("mkfs.xfs can't populate a filesystem from a directory: %v", err)
+	}
+	st, err := os.Stat(imageFile)
+	require.NoError(t, err)
+	assert.Equal(t, int64(minimumXFSImageSize), st.Size())
+
+	destination := t.TempDir()
+	_, _, err = ExtractFS(imageFile, destination, "xfs")
+	require.NoError(t, err)
+	contents, err := os.ReadFile(filepath.Join(destination, "dir", "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "contents\n", string(contents))
+	target, err := os.Readlink(filepath.Join(destination, "link"))
+	require.NoError(t, err)
+	assert.Equal(t, "dir/file", target)
+}
@@ -0,0 +1,232 @@
+package mkcw
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	"go.podman.io/storage/pkg/fileutils"
+)
+
+// Layout of a TDVF (TDX virtual firmware) image, which locates its TDX
+// metadata using the GUIDed table which OVMF places just before the reset
+// vector at the end of the image.
+// https://www.intel.com/content/dam/develop/external/us/en/documents/tdx-virtual-firmware-design-guide-rev-1.01.pdf
+const (
+	tdxPageSize          = 4096
+	tdxExtendChunkSize   = 256
+	tdxMetadataSignature = "TDVF"
+	tdxTableFooterOffset = 0x20 // the table ends this many bytes before the end of the image
+	tdxSectionEntrySize  = 32
+
+	// tdxSectionAttributeMRExtend indicates that a section's contents are
+	// measured into MRTD
+	tdxSectionAttributeMRExtend = 0x1
+	// tdxSectionAttributePageAug indicates that a section's pages are
+	// added after the TD starts, so they aren't part of MRTD
+	tdxSectionAttributePageAug = 0x2
+)
+
+var (
+	// the GUID which identifies the footer of the table, in the mixed-endian order in which it is stored
+	tdxTableFooterGUID = guidBytes("96b582de-1fb2-45f7-baea-a366c55a082d")
+	// the GUID which identifies the table entry which holds the offset of the TDX metadata
+	tdxMetadataOffsetGUID = guidBytes("e47a6535-984a-4798-865e-4685a7bf8ec2")
+)
+
+// guidBytes converts a GUID from its text form to the binary form in which
+// EFI stores it.
+func guidBytes(guid string) []byte {
+	var text []byte
+	for _, c := range guid {
+		if c != '-' {
+			text = append(text, byte(c))
+		}
+	}
+	raw, err := hex.DecodeString(string(text))
+	if err != nil || len(raw) != 16 {
+		panic(fmt.Sprintf("internal error: bad GUID %q", guid))
+	}
+	// the first three fields are stored little-endian
+	return []byte{
+		raw[3], raw[2], raw[1], raw[0],
+		raw[5], raw[4],
+		raw[7], raw[6],
+		raw[8], raw[9], raw[10], raw[11], raw[12], raw[13], raw[14], raw[15],
+	}
+}
+
+// tdxSection is a section entry in a TDVF image's metadata.
+type tdxSection struct {
+	DataOffset     uint32
+	RawDataSize    uint32
+	MemoryAddress  uint64
+	MemoryDataSize uint64
+	Type           uint32
+	Attributes     uint32
+}
+
+// tdxSections finds the TDX metadata in a TDVF image and returns the list of
+// sections which it describes.
+func tdxSections(firmware []byte) ([]tdxSection, error) {
+	if len(firmware) < tdxTableFooterOffset+18 {
+		return nil, errors.New("firmware image is too small to contain a GUIDed table")
+	}
+	// The table's footer is a GUID, preceded by the 16-bit length of the
+	// whole table.  Entries are laid out the same way: data, then a 16-bit
+	// length which includes the length and the GUID, and then the GUID.
+	footer := len(firmware) - tdxTableFooterOffset - 16
+	if !bytes.Equal(firmware[footer:footer+16], tdxTableFooterGUID) {
+		return nil, errors.New("firmware image does not contain a GUIDed table")
+	}
+	tableLength := int(binary.LittleEndian.Uint16(firmware[footer-2 : footer]))
+	tableStart := footer + 16 - tableLength
+	if tableLength < 18 || tableStart < 0 {
+		return nil, fmt.Errorf("firmware image's GUIDed table has invalid length %d", tableLength)
+	}
+	metadataOffset := -1
+	for entryEnd := footer - 2; entryEnd > tableStart; {
+		if entryEnd-18 < tableStart {
+			return nil, errors.New("firmware image's GUIDed table is truncated")
+		}
+		guid := firmware[entryEnd-16 : entryEnd]
+		entryLength := int(binary.LittleEndian.Uint16(firmware[entryEnd-18 : entryEnd-16]))
+		if entryLength < 18 || entryEnd-entryLength < tableStart {
+			return nil, fmt.Errorf("firmware image's GUIDed table has an entry with invalid length %d", entryLength)
+		}
+		if bytes.Equal(guid, tdxMetadataOffsetGUID) {
+			if entryLength < 18+4 {
+				return nil, errors.New("firmware image's TDX metadata offset entry is too short")
+			}
+			// the offset is measured back from the end of the image
+			offset := int(binary.LittleEndian.Uint32(firmware[entryEnd-entryLength : entryEnd-entryLength+4]))
+			metadataOffset = len(firmware) - offset
+			break
+		}
+		entryEnd -= entryLength
+	}
+	if metadataOffset < 0 || metadataOffset+16 > len(firmware) {
+		return nil, errors.New("firmware image does not contain TDX metadata")
+	}
+	metadata := firmware[metadataOffset:]
+	if string(metadata[0:4]) != tdxMetadataSignature {
+		return nil, fmt.Errorf("firmware image's TDX metadata has signature %q (expected %q)", string(metadata[0:4]), tdxMetadataSignature)
+	}
+	count := int(binary.LittleEndian.Uint32(metadata[12:16]))
+	if 16+count*tdxSectionEntrySize > len(metadata) {
+		return nil, fmt.Errorf("firmware image's TDX metadata lists %d sections, but is truncated", count)
+	}
+	sections := make([]tdxSection, count)
+	if err := binary.Read(bytes.NewReader(metadata[16:16+count*tdxSectionEntrySize]), binary.LittleEndian, sections); err != nil {
+		return nil, fmt.Errorf("reading firmware image's TDX metadata: %w", err)
+	}
+	for _, section := range sections {
+		if uint64(section.DataOffset)+uint64(section.RawDataSize) > uint64(len(firmware)) {
+			return nil, fmt.Errorf("firmware image's TDX metadata describes a section at offset %d, size %d, past the end of the image", section.DataOffset, section.RawDataSize)
+		}
+		if section.MemoryAddress%tdxPageSize != 0 || section.MemoryDataSize%tdxPageSize != 0 {
+			return nil, fmt.Errorf("firmware image's TDX metadata describes a section at address %#x, size %#x, which is not page-aligned", section.MemoryAddress, section.MemoryDataSize)
+		}
+	}
+	return sections, nil
+}
+
+// tdxMeasurement computes the MRTD value which the TDX module will produce
+// when the VMM adds the firmware image to a new TD's memory, replaying the
+// TDH.MEM.PAGE.ADD and TDH.MR.EXTEND operations that the VMM performs for each
+// section which the firmware's metadata describes.
+func tdxMeasurement(firmware []byte) (string, error) {
+	sections, err := tdxSections(firmware)
+	if err != nil {
+		return "", err
+	}
+	mrtd := sha512.New384()
+	// each operation is recorded as a 128-byte buffer which starts with
+	// the operation's name, padded to 16 bytes, and the guest physical
+	// address which it was applied to
+	record := func(operation string, gpa uint64) {
+		var buffer [128]byte
+		copy(buffer[:], operation)
+		binary.LittleEndian.PutUint64(buffer[16:], gpa)
+		mrtd.Write(buffer[:])
+	}
+	for _, section := range sections {
+		if section.Attributes&tdxSectionAttributePageAug != 0 {
+			continue
+		}
+		var contents []byte
+		if section.Attributes&tdxSectionAttributeMRExtend != 0 {
+			if uint64(section.RawDataSize) > section.MemoryDataSize {
+				return "", fmt.Errorf("firmware image's TDX metadata describes a section with %d bytes of data, which won't fit in %d bytes of memory", section.RawDataSize, section.MemoryDataSize)
+			}
+			contents = make([]byte, section.MemoryDataSize)
+			copy(contents, firmware[section.DataOffset:section.DataOffset+section.RawDataSize])
+		}
+		for page := uint64(0); page < section.MemoryDataSize; page += tdxPageSize {
+			record("MEM.PAGE.ADD", section.MemoryAddress+page)
+			if section.Attributes&tdxSectionAttributeMRExtend != 0 {
+				for chunk := page; chunk < page+tdxPageSize; chunk += tdxExtendChunkSize {
+					record("MR.EXTEND", section.MemoryAddress+chunk)
+					mrtd.Write(contents[chunk : chunk+tdxExtendChunkSize])
+				}
+			}
+		}
+	}
+	return hex.EncodeToString(mrtd.Sum(nil)), nil
+}
+
+// generateTDXMeasurement computes the launch measurement for a TDX workload
+// using the TDVF firmware image which the VMM will load.
+// If firmwareImage is a path, it will be the only one checked.
+// If firmwareImage is a filename, it will be checked for in a hard-coded set
+// of directories.
+// If firmwareImage is empty, both the filename and the directory it is in
+// will be taken from a hard-coded set of candidates.
+func generateTDXMeasurement(firmwareImage string) (string, error) {
+	firmwareDirs := []string{
+		"/usr/share/edk2/ovmf",
+		"/usr/share/OVMF",
+		"/usr/share/ovmf",
+		"/usr/share/qemu",
+	}
+	firmwareNames := []string{
+		"OVMF.inteltdx.fd",
+		"OVMF.tdx.fd",
+	}
+	var pathsToCheck []string
+	switch {
+	case firmwareImage == "":
+		for _, firmwareDir := range firmwareDirs {
+			for _, firmwareName := range firmwareNames {
+				pathsToCheck = append(pathsToCheck, filepath.Join(firmwareDir, firmwareName))
+			}
+		}
+	case filepath.IsAbs(firmwareImage):
+		pathsToCheck = append(pathsToCheck, firmwareImage)
+	default:
+		for _, firmwareDir := range firmwareDirs {
+			pathsToCheck = append(pathsToCheck, filepath.Join(firmwareDir, firmwareImage))
+		}
+	}
+	for _, candidate := range pathsToCheck {
+		if err := fileutils.Lexists(candidate); err == nil {
+			logrus.Debugf("computing TDX measurement using %q", candidate)
+			firmware, err := os.ReadFile(candidate)
+			if err != nil {
+				return "", err
+			}
+			measurement, err := tdxMeasurement(firmware)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", candidate, err)
+			}
+			return measurement, nil
+		}
+	}
+	return "", fmt.Errorf("generating measurement: none of %v found: %w", pathsToCheck, os.ErrNotExist)
+}
@@ -0,0 +1,277 @@
+package mkcw
+
+import (
+	"archive/tar"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	types "go.podman.io/buildah/internal/mkcw/types"
+)
+
+// makeTDVF builds a minimal firmware image with TDX metadata which describes
+// the specified sections, with each section's data filled in using a byte
+// value based on its index in the list.
+func makeTDVF(t *testing.T, sections []tdxSection) []byte {
+	t.Helper()
+	const imageSize = 0x10000
+	const metadataOffset = 0x100
+	firmware := make([]byte, imageSize)
+	for i, section := range sections {
+		for j := range section.RawDataSize {
+			firmware[section.DataOffset+j] = byte(i + 1)
+		}
+	}
+	// the metadata: signature, length, version, section count, sections
+	metadata := firmware[metadataOffset:]
+	copy(metadata, tdxMetadataSignature)
+	binary.LittleEndian.PutUint32(metadata[4:], uint32(16+len(sections)*tdxSectionEntrySize))
+	binary.LittleEndian.PutUint32(metadata[8:], 1)
+	binary.LittleEndian.PutUint32(metadata[12:], uint32(len(sections)))
+	for i, section := range sections {
+		entry := metadata[16+i*tdxSectionEntrySize:]
+		binary.LittleEndian.PutUint32(entry[0:], section.DataOffset)
+		binary.LittleEndian.PutUint32(entry[4:], section.RawDataSize)
+		binary.LittleEndian.PutUint64(entry[8:], section.MemoryAddress)
+		binary.LittleEndian.PutUint64(entry[16:], section.MemoryDataSize)
+		binary.LittleEndian.PutUint32(entry[24:], section.Type)
+		binary.LittleEndian.PutUint32(entry[28:], section.Attributes)
+	}
+	// the GUIDed table: one entry which holds the metadata's offset from
+	// the end of the image, then the table length, then the footer GUID
+	footer := imageSize - tdxTableFooterOffset - 16
+	copy(firmware[footer:], tdxTableFooterGUID)
+	binary.LittleEndian.PutUint16(firmware[footer-2:], 4+2+16+2+16)
+	copy(firmware[footer-18:], tdxMetadataOffsetGUID)
+	binary.LittleEndian.PutUint16(firmware[footer-20:], 4+2+16)
+	binary.LittleEndian.PutUint32(firmware[footer-24:], imageSize-metadataOffset)
+	return firmware
+}
+
+var testTDXSections = []tdxSection{
+	{ // firmware volume, measured
+		DataOffset:     0x1000,
+		RawDataSize:    0x1000,
+		MemoryAddress:  0xfffff000,
+		MemoryDataSize: 0x1000,
+		Type:           0,
+		Attributes:     tdxSectionAttributeMRExtend,
+	},
+	{ // configuration volume, measured, but smaller than its memory
+		DataOffset:     0x2000,
+		RawDataSize:    0x800,
+		MemoryAddress:  0xffffd000,
+		MemoryDataSize: 0x2000,
+		Type:           1,
+		Attributes:     tdxSectionAttributeMRExtend,
+	},
+	{ // temporary memory, added but not measured
+		MemoryAddress:  0x800000,
+		MemoryDataSize: 0x2000,
+		Type:           3,
+	},
+	{ // accepted later, neither added nor measured
+		MemoryAddress:  0x900000,
+		MemoryDataSize: 0x1000,
+		Type:           2,
+		Attributes:     tdxSectionAttributePageAug,
+	},
+}
+
+func TestTDXMeasurement(t *testing.T) {
+	t.Parallel()
+	firmware := makeTDVF(t, testTDXSections)
+
+	// replay what the VMM would do, one page at a time
+	expected := sha512.New384()
+	op := func(name string, gpa uint64) {
+		var buffer [128]byte
+		copy(buffer[:], name)
+		binary.LittleEndian.PutUint64(buffer[16:], gpa)
+		expected.Write(buffer[:])
+	}
+	fv := firmware[0x1000:0x2000]
+	for chunk := 0; chunk < 0x1000; chunk += 256 {
+		if chunk == 0 {
+			op("MEM.PAGE.ADD", 0xfffff000)
+		}
+		op("MR.EXTEND", 0xfffff000+uint64(chunk))
+		expected.Write(fv[chunk : chunk+256])
+	}
+	cfv := make([]byte, 0x2000)
+	copy(cfv, firmware[0x2000:0x2800])
+	for chunk := 0; chunk < 0x2000; chunk += 256 {
+		if chunk%0x1000 == 0 {
+			op("MEM.PAGE.ADD", 0xffffd000+uint64(chunk))
+		}
+		op("MR.EXTEND", 0xffffd000+uint64(chunk))
+		expected.Write(cfv[chunk : chunk+256])
+	}
+	op("MEM.PAGE.ADD", 0x800000)
+	op("MEM.PAGE.ADD", 0x801000)
+
+	measurement, err := tdxMeasurement(firmware)
+	require.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(expected.Sum(nil)), measurement)
+
+	// changing the contents of a measured section should change the measurement
+	firmware[0x1800]++
+	changed, err := tdxMeasurement(firmware)
+	require.NoError(t, err)
+	assert.NotEqual(t, measurement, changed)
+	firmware[0x1800]--
+
+	// going through the file, with an absolute path, should get us the same result
+	firmwarePath := filepath.Join(t.TempDir(), "OVMF.tdx.fd")
+	require.NoError(t, os.WriteFile(firmwarePath, firmware, 0o600))
+	fromFile, err := generateTDXMeasurement(firmwarePath)
+	require.NoError(t, err)
+	assert.Equal(t, measurement, fromFile)
+	fromConfig, err := GenerateMeasurement(WorkloadConfig{Type: TDX, CPUs: 2, Memory: 512}, firmwarePath)
+	require.NoError(t, err)
+	assert.Equal(t, measurement, fromConfig)
+
+	_, err = generateTDXMeasurement(filepath.Join(t.TempDir(), "missing.fd"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestTDXMeasurementErrors(t *testing.T) {
+	t.Parallel()
+	_, err := tdxMeasurement(make([]byte, 16))
+	assert.ErrorContains(t, err, "too small")
+
+	_, err = tdxMeasurement(make([]byte, 0x10000))
+	assert.ErrorContains(t, err, "does not contain a GUIDed table")
+
+	firmware := makeTDVF(t, testTDXSections)
+	copy(firmware[0x100:], "XXXX")
+	_, err = tdxMeasurement(firmware)
+	assert.ErrorContains(t, err, "signature")
+
+	unaligned := append([]tdxSection{}, testTDXSections...)
+	unaligned[2].MemoryAddress = 0x800800
+	_, err = tdxMeasurement(makeTDVF(t, unaligned))
+	assert.ErrorContains(t, err, "not page-aligned")
+
+	firmware = makeTDVF(t, testTDXSections)
+	binary.LittleEndian.PutUint32(firmware[0x100+16:], 0x20000)
+	_, err = tdxMeasurement(firmware)
+	assert.ErrorContains(t, err, "past the end of the image")
+}
+
+// serveDummyAttestation starts a dummyAttestationHandler which will be shut
+// down when the test ends, and returns it along with its URL.
+func serveDummyAttestation(t *testing.T) (*dummyAttestationHandler, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	handler := &dummyAttestationHandler{t: t}
+	server := http.Server{
+		Handler: handler,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.Logf("serve: %v", err)
+		}
+	}()
+	t.Cleanup(func() { assert.NoError(t, server.Close()) })
+	return handler, "http://" + listener.Addr().String()
+}
+
+func TestTDXRegistrationRequest(t *testing.T) {
+	t.Parallel()
+	firmware := makeTDVF(t, testTDXSections)
+	firmwarePath := filepath.Join(t.TempDir(), "OVMF.tdx.fd")
+	require.NoError(t, os.WriteFile(firmwarePath, firmware, 0o600))
+	measurement, err := tdxMeasurement(firmware)
+	require.NoError(t, err)
+
+	handler, url := serveDummyAttestation(t)
+	workloadConfig := WorkloadConfig{
+		Type:           TDX,
+		TeeData:        "{}",
+		WorkloadID:     "tdx-workload",
+		CPUs:           2,
+		Memory:         512,
+		AttestationURL: url,
+	}
+	err = SendRegistrationRequest(workloadConfig, "passphrase", firmwarePath, false, logrus.StandardLogger())
+	require.NoError(t, err)
+
+	handler.passphrasesLock.Lock()
+	request, ok := handler.requests["tdx-workload"]
+	handler.passphrasesLock.Unlock()
+	require.True(t, ok, "attestation server did not receive a registration request")
+	assert.Equal(t, "passphrase", request.Passphrase)
+	assert.Equal(t, measurement, request.LaunchMeasurement)
+	var teeConfig TdxTeeConfig
+	require.NoError(t, json.Unmarshal([]byte(request.TeeConfig), &teeConfig))
+	assert.NotZero(t, teeConfig.Attributes&types.TDX_ATTRIBUTES_SEPT_VE_DISABLE)
+	assert.Zero(t, teeConfig.Attributes&types.TDX_ATTRIBUTES_DEBUG, "registered a TD that can be debugged")
+
+	// without firmware, we can only register if we've been told to ignore errors
+	workloadConfig.WorkloadID = "tdx-workload-no-firmware"
+	missing := filepath.Join(t.TempDir(), "missing.fd")
+	err = SendRegistrationRequest(workloadConfig, "passphrase", missing, false, logrus.StandardLogger())
+	assert.ErrorAs(t, err, &measurementError{})
+	err = SendRegistrationRequest(workloadConfig, "passphrase", missing, true, logrus.StandardLogger())
+	require.NoError(t, err)
+	handler.passphrasesLock.Lock()
+	request = handler.requests["tdx-workload-no-firmware"]
+	handler.passphrasesLock.Unlock()
+	assert.Empty(t, request.LaunchMeasurement)
+}
+
+func TestArchiveTDX(t *testing.T) {
+	t.Parallel()
+	firmwarePath := filepath.Join(t.TempDir(), "OVMF.tdx.fd")
+	require.NoError(t, os.WriteFile(firmwarePath, makeTDVF(t, testTDXSections), 0o600))
+	handler, url := serveDummyAttestation(t)
+
+	archiveOptions := ArchiveOptions{
+		CPUs:            2,
+		Memory:          512,
+		TempDir:         t.TempDir(),
+		TeeType:         TDX,
+		AttestationURL:  url,
+		FirmwareLibrary: firmwarePath,
+	}
+	rc, workloadConfig, err := Archive(t.TempDir(), &v1.Image{}, archiveOptions)
+	require.NoError(t, err)
+	defer rc.Close()
+	assert.Equal(t, TDX, workloadConfig.Type)
+	assert.JSONEq(t, "{}", workloadConfig.TeeData)
+
+	contents := make(map[string][]byte)
+	tr := tar.NewReader(rc)
+	hdr, err := tr.Next()
+	for hdr != nil {
+		contents[hdr.Name], err = io.ReadAll(tr)
+		require.NoError(t, err)
+		hdr, err = tr.Next()
+	}
+	if err != nil {
+		require.ErrorIs(t, err, io.EOF)
+	}
+	var writtenWorkloadConfig WorkloadConfig
+	require.NoError(t, json.Unmarshal(contents["krun-sev.json"], &writtenWorkloadConfig))
+	assert.Equal(t, workloadConfig, writtenWorkloadConfig)
+
+	handler.passphrasesLock.Lock()
+	request, ok := handler.requests[workloadConfig.WorkloadID]
+	handler.passphrasesLock.Unlock()
+	require.True(t, ok, "attestation server did not receive a registration request")
+	assert.NotEmpty(t, request.LaunchMeasurement)
+}
@@ -40,6 +40,25 @@ const (
 	SNP_CONFIG_DEBUG           TeeConfigFlagBits = 0b00001000 // allow debugging
 )
 
+// TdxTeeConfig contains information about a TDX trust domain which the
+// attestation server should expect to find in the TD's attestation report.
+type TdxTeeConfig struct {
+	Attributes TdxAttributeBits `json:"attributes"` // required TD attributes
+}
+
+// TdxAttributeBits are the bits of a TD's ATTRIBUTES field.
+// https://cdrdv2.intel.com/v1/dl/getContent/733575 (TDX module ABI specification, TDX_ATTRIBUTES)
+type TdxAttributeBits uint64
+
+//nolint:revive,staticcheck // Don't warn about bad naming.
+const (
+	TDX_ATTRIBUTES_DEBUG           TdxAttributeBits = 1 << 0  // the TD can be debugged by the host
+	TDX_ATTRIBUTES_SEPT_VE_DISABLE TdxAttributeBits = 1 << 28 // EPT violations are not converted to #VE in the guest
+	TDX_ATTRIBUTES_PKS             TdxAttributeBits = 1 << 30 // supervisor protection keys are enabled
+	TDX_ATTRIBUTES_KL              TdxAttributeBits = 1 << 31 // key locker is enabled
+	TDX_ATTRIBUTES_PERFMON         TdxAttributeBits = 1 << 63 // the TD can use performance monitoring
+)
+
 // TeeConfigFlagMinFW corresponds to a minimum version of the kernel+initrd
 // combination that should be booted.
 type TeeConfigMinFW struct {
@@ -7,11 +7,18 @@ import "go.podman.io/buildah/define"
 // https://github.com/containers/libkrun/blob/57c59dc5359bdeeb8260b3493e9f63d3708f9ab9/src/vmm/src/resources.rs#L57
 type WorkloadConfig struct {
 	Type           define.TeeType `json:"tee"`
-	TeeData        string         `json:"tee_data"` // Type == SEV: JSON-encoded SevWorkloadData, SNP: JSON-encoded SnpWorkloadData, others?
+	TeeData        string         `json:"tee_data"` // Type == SEV: JSON-encoded SevWorkloadData, SNP: JSON-encoded SnpWorkloadData, TDX: JSON-encoded TdxWorkloadData
 	WorkloadID     string         `json:"workload_id"`
 	CPUs           int            `json:"cpus"`
 	Memory         int            `json:"ram_mib"`
 	AttestationURL string         `json:"attestation_url"`
+	// The remaining fields are only set when the disk image doesn't use
+	// the defaults: an ext4 filesystem in a LUKS-encrypted disk image,
+	// without a dm-verity hash tree.
+	Filesystem       string `json:"fs_type,omitempty"`            // "xfs" or "erofs"
+	Encryption       string `json:"encryption,omitempty"`         // "luks2" or "none"
+	VerityRootHash   string `json:"verity_root_hash,omitempty"`   // hex-encoded
+	VerityHashOffset int64  `json:"verity_hash_offset,omitempty"` // location of the verity superblock, in bytes
 }
 
 // SevWorkloadData contains the path to the SEV certificate chain and optionally,
@@ -28,6 +35,23 @@ type SnpWorkloadData struct {
 	Generation string `json:"gen"` // "milan" (naples=1, rome=2, milan=3, genoa/bergamo/siena=4, turin=5)
 }
 
+// TdxWorkloadData is the type of data in WorkloadConfig.TeeData when the type
+// is TDX.  krun doesn't currently need any, so it's always an empty object.
+type TdxWorkloadData struct{}
+
+// Disk image encryption types.
+const (
+	// EncryptionLUKS1 encrypts the disk image using LUKS1, with a key
+	// derived from the passphrase using PBKDF2.
+	EncryptionLUKS1 = "luks1"
+	// EncryptionLUKS2 encrypts the disk image using LUKS2, with a key
+	// derived from the passphrase using Argon2i.
+	EncryptionLUKS2 = "luks2"
+	// EncryptionNone leaves the disk image unencrypted, for workloads
+	// which only need its integrity to be protected using dm-verity.
+	EncryptionNone = "none"
+)
+
 //nolint:revive,staticcheck // Don't warn about bad naming.
 const (
 	// SEV_NO_ES is a known trusted execution environment type: AMD-SEV (secure encrypted virtualization without encrypted state, requires epyc 1000 "naples")
@@ -0,0 +1,141 @@
+package mkcw
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Layout of a dm-verity hash device, in the format which veritysetup(8)
+// produces by default: a superblock, padded to the hash block size, followed
+// by the hash tree, with the level closest to the root first.
+// https://gitlab.com/cryptsetup/cryptsetup/-/wikis/DMVerity
+const (
+	verityBlockSize     = 4096
+	verityHashSize      = sha256.Size
+	veritySaltSize      = 32
+	veritySuperblockLen = 512
+	verityAlgorithm     = "sha256"
+	verityHashType      = 1 // the salt is hashed before each block
+)
+
+// veritySuperblock is the on-disk verity superblock.
+type veritySuperblock struct {
+	Signature     [8]byte
+	Version       uint32
+	HashType      uint32
+	UUID          [16]byte
+	Algorithm     [32]byte
+	DataBlockSize uint32
+	HashBlockSize uint32
+	DataBlocks    uint64
+	SaltSize      uint16
+	_             [6]byte
+	Salt          [256]byte
+	_             [168]byte
+}
+
+// verityHashBlocks hashes each block in data, and returns the hashes packed
+// into hash blocks which are padded with zeroes.
+func verityHashBlocks(salt []byte, data io.Reader, blocks int64) ([]byte, error) {
+	var packed bytes.Buffer
+	block := make([]byte, verityBlockSize)
+	for range blocks {
+		if _, err := io.ReadFull(data, block); err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		h.Write(salt)
+		h.Write(block)
+		packed.Write(h.Sum(nil))
+	}
+	if extra := packed.Len() % verityBlockSize; extra != 0 {
+		packed.Write(make([]byte, verityBlockSize-extra))
+	}
+	return packed.Bytes(), nil
+}
+
+// AppendVerityHashTree computes a dm-verity hash tree for the first dataSize
+// bytes of the file at path, which must be a multiple of the block size, and
+// appends a verity superblock and the tree to the file, starting at offset
+// dataSize.
+// Returns the hex-encoded root hash, which is needed to open the device.
+func AppendVerityHashTree(path string, dataSize int64) (string, error) {
+	if dataSize <= 0 || dataSize%verityBlockSize != 0 {
+		return "", fmt.Errorf("data size %d is not a positive multiple of %d", dataSize, verityBlockSize)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if st.Size() < dataSize {
+		return "", fmt.Errorf("%q is only %d bytes long, expected at least %d", path, st.Size(), dataSize)
+	}
+
+	salt := make([]byte, veritySaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	// Hash the data blocks, then hash the blocks of hashes, and so on,
+	// until we're down to one block of hashes.
+	dataBlocks := dataSize / verityBlockSize
+	level, err := verityHashBlocks(salt, io.NewSectionReader(f, 0, dataSize), dataBlocks)
+	if err != nil {
+		return "", fmt.Errorf("hashing %q: %w", path, err)
+	}
+	levels := [][]byte{level}
+	for len(level) > verityBlockSize {
+		if level, err = verityHashBlocks(salt, bytes.NewReader(level), int64(len(level)/verityBlockSize)); err != nil {
+			return "", err
+		}
+		levels = append(levels, level)
+	}
+	root := sha256.New()
+	root.Write(salt)
+	root.Write(level)
+
+	sb := veritySuperblock{
+		Version:       1,
+		HashType:      verityHashType,
+		DataBlockSize: verityBlockSize,
+		HashBlockSize: verityBlockSize,
+		DataBlocks:    uint64(dataBlocks),
+		SaltSize:      veritySaltSize,
+	}
+	copy(sb.Signature[:], "verity")
+	copy(sb.Algorithm[:], verityAlgorithm)
+	copy(sb.Salt[:], salt)
+	if _, err := rand.Read(sb.UUID[:]); err != nil {
+		return "", err
+	}
+	var hashArea bytes.Buffer
+	if err := binary.Write(&hashArea, binary.LittleEndian, sb); err != nil {
+		return "", err
+	}
+	hashArea.Write(make([]byte, verityBlockSize-veritySuperblockLen))
+	for i := len(levels) - 1; i >= 0; i-- {
+		hashArea.Write(levels[i])
+	}
+
+	if err := f.Truncate(dataSize); err != nil {
+		return "", err
+	}
+	if _, err := f.WriteAt(hashArea.Bytes(), dataSize); err != nil {
+		return "", fmt.Errorf("writing verity hash tree to %q: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(root.Sum(nil)), nil
+}
@@ -0,0 +1,99 @@
+package mkcw
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkVerity verifies every data block in image against the hash tree at
+// hashOffset, locating hash blocks the way the kernel's dm-verity target does.
+func checkVerity(image []byte, hashOffset int64, rootHash string) error {
+	var sb veritySuperblock
+	if err := binary.Read(bytes.NewReader(image[hashOffset:hashOffset+veritySuperblockLen]), binary.LittleEndian, &sb); err != nil {
+		return err
+	}
+	if string(bytes.TrimRight(sb.Signature[:], "\x00")) != "verity" {
+		return fmt.Errorf("bad signature %q", sb.Signature[:])
+	}
+	if string(bytes.TrimRight(sb.Algorithm[:], "\x00")) != "sha256" || sb.HashType != 1 || sb.DataBlockSize != 4096 || sb.HashBlockSize != 4096 {
+		return fmt.Errorf("unexpected superblock contents %+v", sb)
+	}
+	salt := sb.Salt[:sb.SaltSize]
+	dataBlocks := int64(sb.DataBlocks)
+	const bitsPerLevel = 7 // 128 hashes per block
+	levels := 0
+	for (dataBlocks-1)>>(bitsPerLevel*levels) != 0 {
+		levels++
+	}
+	levelStart := make([]int64, levels)
+	position := hashOffset/4096 + 1
+	for i := levels - 1; i >= 0; i-- {
+		levelStart[i] = position
+		position += (dataBlocks + (1 << (bitsPerLevel * (i + 1))) - 1) >> (bitsPerLevel * (i + 1))
+	}
+	hash := func(block []byte) []byte {
+		h := sha256.New()
+		h.Write(salt)
+		h.Write(block)
+		return h.Sum(nil)
+	}
+	for b := range dataBlocks {
+		want := hash(image[b*4096 : (b+1)*4096])
+		for i := range levels {
+			hashBlock := levelStart[i] + (b >> (bitsPerLevel * (i + 1)))
+			entry := (b >> (bitsPerLevel * i)) & (1<<bitsPerLevel - 1)
+			block := image[hashBlock*4096 : (hashBlock+1)*4096]
+			if !bytes.Equal(block[entry*32:(entry+1)*32], want) {
+				return fmt.Errorf("data block %d: hash mismatch at level %d", b, i)
+			}
+			want = hash(block)
+		}
+		if hex.EncodeToString(want) != rootHash {
+			return fmt.Errorf("data block %d: root hash mismatch", b)
+		}
+	}
+	return nil
+}
+
+func TestAppendVerityHashTree(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, veritySuperblockLen, binary.Size(veritySuperblock{}))
+
+	for _, dataBlocks := range []int{2, 128, 129, 2560, 16385} {
+		t.Run(fmt.Sprintf("blocks=%d", dataBlocks), func(t *testing.T) {
+			t.Parallel()
+			data := make([]byte, dataBlocks*4096)
+			for i := range dataBlocks {
+				binary.LittleEndian.PutUint64(data[i*4096:], uint64(i)+1)
+			}
+			path := filepath.Join(t.TempDir(), "image")
+			require.NoError(t, os.WriteFile(path, data, 0o600))
+			rootHash, err := AppendVerityHashTree(path, int64(len(data)))
+			require.NoError(t, err)
+			image, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, data, image[:len(data)], "data was modified")
+			require.NoError(t, checkVerity(image, int64(len(data)), rootHash))
+
+			// corrupting a data block should be noticed
+			image[len(data)-1] ^= 0xff
+			assert.Error(t, checkVerity(image, int64(len(data)), rootHash))
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "image")
+	require.NoError(t, os.WriteFile(path, make([]byte, 4096), 0o600))
+	_, err := AppendVerityHashTree(path, 4095)
+	assert.Error(t, err)
+	_, err = AppendVerityHashTree(path, 8192)
+	assert.Error(t, err)
+}
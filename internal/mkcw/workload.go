@@ -20,11 +20,22 @@ type (
 	SevWorkloadData = types.SevWorkloadData
 	// SnpWorkloadData is the type of data in WorkloadConfig.TeeData when the type is SNP.
 	SnpWorkloadData = types.SnpWorkloadData
+	// TdxWorkloadData is the type of data in WorkloadConfig.TeeData when the type is TDX.
+	TdxWorkloadData = types.TdxWorkloadData
 	// TeeType is one of the known types of trusted execution environments for which we
 	// can generate suitable image contents.
 	TeeType = define.TeeType
 )
 
+const (
+	// EncryptionLUKS1 encrypts the disk image using LUKS1.
+	EncryptionLUKS1 = types.EncryptionLUKS1
+	// EncryptionLUKS2 encrypts the disk image using LUKS2.
+	EncryptionLUKS2 = types.EncryptionLUKS2
+	// EncryptionNone leaves the disk image unencrypted.
+	EncryptionNone = types.EncryptionNone
+)
+
 const (
 	maxWorkloadConfigSize    = 1024 * 1024
 	preferredPaddingBoundary = 4096
@@ -43,6 +54,8 @@ const (
 	SEV_NO_ES = types.SEV_NO_ES
 	// SNP is a known trusted execution environment type: AMD-SNP
 	SNP = define.SNP
+	// TDX is a known trusted execution environment type: Intel TDX
+	TDX = define.TDX
 )
 
 // ReadWorkloadConfigFromImage reads the workload configuration from the
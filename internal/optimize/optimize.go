@@ -0,0 +1,343 @@
+// Package optimize plans a new set of layers for an image, containing only
+// the items which are present in the image's final filesystem, and writes
+// them.
+package optimize
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.podman.io/buildah/internal/analyze"
+	"go.podman.io/buildah/internal/tmpdir"
+)
+
+// Options controls how the new layers are arranged.
+type Options struct {
+	// MergeSize is the size below which adjacent layers are merged.
+	// Layers are merged until the merged layer reaches this size.  If it
+	// is zero, only layers which would be empty are merged.
+	MergeSize int64
+	// SplitPaths are paths whose contents are moved to a new top layer.
+	SplitPaths []string
+}
+
+// Layer is a layer in the new set of layers.
+type Layer struct {
+	// Sources are the indexes of the original layers which the layer's
+	// contents come from.
+	Sources []int
+	// Split is true if the layer holds the contents of Options.SplitPaths.
+	Split bool
+	// Size is the size of the regular files in the layer.
+	Size int64
+	// Items is the number of items in the layer.
+	Items int
+	// keep maps the index of each original layer to the positions of the
+	// items in it which are included in this layer, and how hard links
+	// whose targets aren't in the final filesystem are written.
+	keep map[int]map[int]*brokenLink
+}
+
+// brokenLink describes how to write a hard link whose target is modified or
+// deleted by a later layer.
+type brokenLink struct {
+	// relink is an earlier hard link to the same target, which was
+	// written as a regular file, and which this one should be a hard link
+	// to instead.  If it is not set, this one is written as a regular
+	// file with the target's original contents.
+	relink string
+	// content locates those contents.
+	content *analyze.Content
+}
+
+func (l *Layer) add(source int, item analyze.Item, broken *brokenLink) {
+	if l.keep == nil {
+		l.keep = make(map[int]map[int]*brokenLink)
+	}
+	if l.keep[source] == nil {
+		l.keep[source] = make(map[int]*brokenLink)
+	}
+	l.keep[source][item.Position] = broken
+	if !slices.Contains(l.Sources, source) {
+		l.Sources = append(l.Sources, source)
+	}
+	l.Size += item.Size
+	if broken != nil && broken.relink == "" {
+		l.Size += broken.content.Size
+	}
+	l.Items++
+}
+
+// Plan arranges the items which are present in the final filesystem, as
+// returned by analyze.Surviving(), into a new set of layers.
+func Plan(items [][]analyze.Item, options Options) ([]Layer, error) {
+	var splitPaths []string
+	for _, p := range options.SplitPaths {
+		p = path.Join("/", p)
+		if p == "/" {
+			return nil, errors.New("splitting out everything under / would produce a single layer")
+		}
+		splitPaths = append(splitPaths, p)
+	}
+	isSplit := func(p string) bool {
+		return slices.ContainsFunc(splitPaths, func(split string) bool {
+			return p == split || strings.HasPrefix(p, split+"/")
+		})
+	}
+
+	// decide which items are split out, keeping hard links in the same
+	// layer as the items they link to, which were added by either the
+	// same layer or an earlier one
+	split := make([][]bool, len(items))
+	broken := make([][]*brokenLink, len(items))
+	splitByPath := make(map[string]bool)
+	// the first hard link to each target which a later layer modifies or
+	// deletes, which is written as a regular file
+	replacements := make(map[string]string)
+	for i, layerItems := range items {
+		split[i] = make([]bool, len(layerItems))
+		broken[i] = make([]*brokenLink, len(layerItems))
+		for j, item := range layerItems {
+			split[i][j] = isSplit(item.Path)
+			if item.Link != "" {
+				link := item.Link
+				if _, ok := splitByPath[link]; !ok {
+					if item.LinkContent == nil {
+						return nil, fmt.Errorf("%q is a hard link to %q, which was not found", item.Path, item.Link)
+					}
+					// the target's gone, so break the link
+					broken[i][j] = &brokenLink{content: item.LinkContent}
+					if replacement, ok := replacements[link]; ok {
+						broken[i][j].relink = replacement
+						link = replacement
+					} else {
+						replacements[link] = item.Path
+						link = ""
+					}
+				}
+				if link != "" {
+					split[i][j] = split[i][j] || splitByPath[link]
+				}
+			}
+			splitByPath[item.Path] = split[i][j]
+		}
+	}
+
+	var layers []Layer
+	var splitLayer Layer
+	for i, layerItems := range items {
+		var size int64
+		var count int
+		for j, item := range layerItems {
+			if !split[i][j] {
+				size += item.Size
+				if broken[i][j] != nil && broken[i][j].relink == "" {
+					size += broken[i][j].content.Size
+				}
+				count++
+			}
+		}
+		// start a new layer unless this one would be empty, or both
+		// it and the current one are small enough to merge
+		if len(layers) == 0 {
+			layers = append(layers, Layer{})
+		} else if current := &layers[len(layers)-1]; count > 0 && current.Items > 0 {
+			if options.MergeSize <= 0 || current.Size >= options.MergeSize || size >= options.MergeSize {
+				layers = append(layers, Layer{})
+			}
+		}
+		current := &layers[len(layers)-1]
+		if !slices.Contains(current.Sources, i) {
+			current.Sources = append(current.Sources, i)
+		}
+		for j, item := range layerItems {
+			if split[i][j] {
+				splitLayer.add(i, item, broken[i][j])
+			} else {
+				current.add(i, item, broken[i][j])
+			}
+		}
+	}
+	if splitLayer.Items > 0 {
+		splitLayer.Split = true
+		layers = append(layers, splitLayer)
+	}
+	return layers, nil
+}
+
+// Write writes the layer's contents as a tar stream to w, reading the
+// original layers using open.
+func (l *Layer) Write(w io.Writer, open func(source int) (io.ReadCloser, error)) error {
+	originals, err := l.saveOriginals(open)
+	if err != nil {
+		return err
+	}
+	defer originals.remove()
+	tw := tar.NewWriter(w)
+	for _, source := range l.Sources {
+		positions := l.keep[source]
+		if len(positions) == 0 {
+			continue
+		}
+		if err := func() error {
+			rc, err := open(source)
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			tr := tar.NewReader(rc)
+			hdr, err := tr.Next()
+			for position := 0; err == nil; position++ {
+				if broken, ok := positions[position]; ok {
+					if broken != nil {
+						if err := originals.writeBrokenLink(tw, hdr, broken); err != nil {
+							return err
+						}
+					} else {
+						if err := tw.WriteHeader(hdr); err != nil {
+							return fmt.Errorf("writing header for %q: %w", hdr.Name, err)
+						}
+						if _, err := io.Copy(tw, tr); err != nil {
+							return fmt.Errorf("copying %q: %w", hdr.Name, err)
+						}
+					}
+				}
+				hdr, err = tr.Next()
+			}
+			if !errors.Is(err, io.EOF) {
+				return err
+			}
+			return nil
+		}(); err != nil {
+			return fmt.Errorf("reading layer %d: %w", source, err)
+		}
+	}
+	return tw.Close()
+}
+
+// originals holds copies of the contents which the targets of broken hard
+// links had.
+type originals struct {
+	file    *os.File
+	offsets map[analyze.Content]int64
+}
+
+// saveOriginals copies the contents which broken hard links in the layer will
+// be written with to a temporary file.  They're read ahead of time, one layer
+// at a time, because open can't be called again while a layer which it
+// opened is still being read.
+func (l *Layer) saveOriginals(open func(source int) (io.ReadCloser, error)) (*originals, error) {
+	wanted := make(map[int]map[int]analyze.Content)
+	for _, positions := range l.keep {
+		for _, broken := range positions {
+			if broken == nil || broken.relink != "" {
+				continue
+			}
+			content := *broken.content
+			if wanted[content.Layer] == nil {
+				wanted[content.Layer] = make(map[int]analyze.Content)
+			}
+			wanted[content.Layer][content.Position] = content
+		}
+	}
+	o := &originals{offsets: make(map[analyze.Content]int64)}
+	if len(wanted) == 0 {
+		return o, nil
+	}
+	f, err := os.CreateTemp(tmpdir.GetTempDir(), "buildah-optimize")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary file: %w", err)
+	}
+	o.file = f
+	var offset int64
+	for _, source := range slices.Sorted(maps.Keys(wanted)) {
+		if err := func() error {
+			rc, err := open(source)
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			tr := tar.NewReader(rc)
+			found := 0
+			_, err = tr.Next()
+			for position := 0; err == nil && found < len(wanted[source]); position++ {
+				if content, ok := wanted[source][position]; ok {
+					n, err := io.Copy(f, tr)
+					if err != nil {
+						return fmt.Errorf("copying original contents: %w", err)
+					}
+					if n != content.Size {
+						return fmt.Errorf("expected %d bytes of original contents at position %d, got %d", content.Size, position, n)
+					}
+					o.offsets[content] = offset
+					offset += n
+					found++
+				}
+				_, err = tr.Next()
+			}
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			return nil
+		}(); err != nil {
+			o.remove()
+			return nil, fmt.Errorf("reading layer %d: %w", source, err)
+		}
+	}
+	for _, positions := range wanted {
+		for _, content := range positions {
+			if _, ok := o.offsets[content]; !ok {
+				o.remove()
+				return nil, fmt.Errorf("locating original contents at position %d in layer %d", content.Position, content.Layer)
+			}
+		}
+	}
+	return o, nil
+}
+
+// remove removes the temporary file, if there is one.
+func (o *originals) remove() {
+	if o.file == nil {
+		return
+	}
+	o.file.Close()
+	if err := os.Remove(o.file.Name()); err != nil {
+		logrus.Warnf("removing temporary file %q: %v", o.file.Name(), err)
+	}
+	o.file = nil
+}
+
+// writeBrokenLink writes a hard link whose target is not in the final
+// filesystem, either as a hard link to an earlier link to the same target
+// which was written as a regular file, or as a regular file with the
+// contents which the target had.
+func (o *originals) writeBrokenLink(tw *tar.Writer, hdr *tar.Header, broken *brokenLink) error {
+	copied := *hdr
+	hdr = &copied
+	if broken.relink != "" {
+		hdr.Linkname = strings.TrimPrefix(broken.relink, "/")
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("writing header for %q: %w", hdr.Name, err)
+		}
+		return nil
+	}
+	hdr.Typeflag = tar.TypeReg
+	hdr.Linkname = ""
+	hdr.Size = broken.content.Size
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("writing header for %q: %w", hdr.Name, err)
+	}
+	contents := io.NewSectionReader(o.file, o.offsets[*broken.content], broken.content.Size)
+	if _, err := io.Copy(tw, contents); err != nil {
+		return fmt.Errorf("copying the original contents of %q: %w", hdr.Name, err)
+	}
+	return nil
+}
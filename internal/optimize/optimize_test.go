@@ -0,0 +1,211 @@
+package optimize
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/buildah/internal/analyze"
+)
+
+// testLayer builds a layer from a list of items, where names which end with
+// "/" are directories, names which start with "=" are hard links to the
+// contents, and everything else is a file with the specified contents.
+func testLayer(t *testing.T, items ...string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; i < len(items); i += 2 {
+		name, contents := items[i], items[i+1]
+		hdr := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(contents))}
+		switch {
+		case strings.HasSuffix(name, "/"):
+			hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0o755, 0
+		case strings.HasPrefix(name, "="):
+			hdr.Name, hdr.Typeflag, hdr.Linkname, hdr.Size = name[1:], tar.TypeLink, contents, 0
+			contents = ""
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+// readCloser calls a function when it's closed.
+type readCloser struct {
+	io.Reader
+	close func()
+}
+
+func (r readCloser) Close() error {
+	r.close()
+	return nil
+}
+
+// names lists the names of the items in a tar stream.
+func names(t *testing.T, contents []byte) []string {
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(contents))
+	hdr, err := tr.Next()
+	for err == nil {
+		names = append(names, hdr.Name)
+		hdr, err = tr.Next()
+	}
+	require.ErrorIs(t, err, io.EOF)
+	return names
+}
+
+func TestPlan(t *testing.T) {
+	t.Parallel()
+	blobs := [][]byte{
+		testLayer(t,
+			"usr/", "",
+			"usr/bin/", "",
+			"usr/bin/tool", strings.Repeat("t", 1000),
+			"tmp/", "",
+			"tmp/download", strings.Repeat("d", 5000),
+		),
+		testLayer(t,
+			"tmp/", "",
+			"tmp/.wh.download", "",
+			"etc/", "",
+			"etc/small", "s",
+		),
+		testLayer(t,
+			"etc/", "",
+			"etc/other", "o",
+		),
+		testLayer(t,
+			"app/", "",
+			"app/main", strings.Repeat("m", 2000),
+			"=usr/bin/main", "app/main",
+		),
+	}
+	layers := make([]analyze.Layer, len(blobs))
+	for i := range blobs {
+		layers[i] = analyze.Layer{
+			Open: func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(blobs[i])), nil
+			},
+		}
+	}
+	open := func(source int) (io.ReadCloser, error) {
+		return layers[source].Open()
+	}
+	write := func(layer Layer) []string {
+		var buf bytes.Buffer
+		require.NoError(t, layer.Write(&buf, open))
+		return names(t, buf.Bytes())
+	}
+
+	report, items, err := analyze.Surviving(layers)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), report.WastedBytes)
+
+	// without merging, every layer keeps its surviving items, and the
+	// whiteout and what it deleted are both gone
+	planned, err := Plan(items, Options{})
+	require.NoError(t, err)
+	require.Len(t, planned, 4)
+	assert.Equal(t, []string{"usr/", "usr/bin/", "usr/bin/tool"}, write(planned[0]))
+	assert.Equal(t, []string{"tmp/", "etc/small"}, write(planned[1]))
+	assert.Equal(t, []string{"etc/", "etc/other"}, write(planned[2]))
+	assert.Equal(t, []string{"app/", "app/main", "usr/bin/main"}, write(planned[3]))
+	assert.Equal(t, int64(1000), planned[0].Size)
+
+	// small layers are merged until they reach the merge size
+	planned, err = Plan(items, Options{MergeSize: 1500})
+	require.NoError(t, err)
+	require.Len(t, planned, 2)
+	assert.Equal(t, []int{0, 1, 2}, planned[0].Sources)
+	assert.Equal(t, []string{"usr/", "usr/bin/", "usr/bin/tool", "tmp/", "etc/small", "etc/", "etc/other"}, write(planned[0]))
+	assert.Equal(t, []int{3}, planned[1].Sources)
+
+	// split paths move to a new top layer, and hard links follow the
+	// items they link to
+	planned, err = Plan(items, Options{SplitPaths: []string{"app"}})
+	require.NoError(t, err)
+	require.Len(t, planned, 4)
+	assert.Equal(t, []int{2, 3}, planned[2].Sources)
+	assert.True(t, planned[3].Split)
+	assert.Equal(t, []string{"app/", "app/main", "usr/bin/main"}, write(planned[3]))
+
+	_, err = Plan(items, Options{SplitPaths: []string{"/"}})
+	assert.Error(t, err)
+
+	// a hard link to something which isn't there can't be kept
+	_, err = Plan([][]analyze.Item{{{Path: "/a/link", Link: "/a/file"}}}, Options{})
+	assert.ErrorContains(t, err, `"/a/link" is a hard link to "/a/file", which was not found`)
+}
+
+func TestPlanBrokenLinks(t *testing.T) {
+	t.Parallel()
+	// hard links to a file which a later layer replaces, as happens when
+	// a package which hard links files is updated
+	blobs := [][]byte{
+		testLayer(t,
+			"usr/", "",
+			"usr/bin/", "",
+			"usr/bin/tool", "old tool",
+			"=usr/bin/tool-alias", "usr/bin/tool",
+			"=usr/bin/tool-other", "usr/bin/tool",
+		),
+		testLayer(t,
+			"usr/bin/", "",
+			"usr/bin/tool", "new tool",
+		),
+	}
+	layers := make([]analyze.Layer, len(blobs))
+	for i := range blobs {
+		layers[i] = analyze.Layer{
+			Open: func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(blobs[i])), nil
+			},
+		}
+	}
+	// like store.Diff(), open can't be called again until the stream
+	// which it last returned is closed
+	reading := false
+	open := func(source int) (io.ReadCloser, error) {
+		require.Falsef(t, reading, "opened layer %d while reading another", source)
+		reading = true
+		return readCloser{Reader: bytes.NewReader(blobs[source]), close: func() { reading = false }}, nil
+	}
+	_, items, err := analyze.Surviving(layers)
+	require.NoError(t, err)
+
+	for _, options := range []Options{{}, {MergeSize: 1024 * 1024}, {SplitPaths: []string{"/usr/bin/tool-other"}}} {
+		planned, err := Plan(items, options)
+		require.NoErrorf(t, err, "planning with %+v", options)
+		// read back the files and links in all of the new layers
+		contents := make(map[string]string)
+		links := make(map[string]string)
+		for _, layer := range planned {
+			var buf bytes.Buffer
+			require.NoError(t, layer.Write(&buf, open))
+			tr := tar.NewReader(&buf)
+			hdr, err := tr.Next()
+			for err == nil {
+				switch hdr.Typeflag {
+				case tar.TypeReg:
+					data, err := io.ReadAll(tr)
+					require.NoError(t, err)
+					contents[hdr.Name] = string(data)
+				case tar.TypeLink:
+					links[hdr.Name] = hdr.Linkname
+				}
+				hdr, err = tr.Next()
+			}
+			require.ErrorIs(t, err, io.EOF)
+		}
+		// the first link keeps the original contents, and the other
+		// one is a hard link to it
+		assert.Equalf(t, map[string]string{"usr/bin/tool": "new tool", "usr/bin/tool-alias": "old tool"}, contents, "planning with %+v", options)
+		assert.Equalf(t, map[string]string{"usr/bin/tool-other": "usr/bin/tool-alias"}, links, "planning with %+v", options)
+	}
+}
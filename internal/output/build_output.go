@@ -12,12 +12,14 @@ const (
 	BuildOutputStdout   BuildOutputType = 1 // stream tar to stdout
 	BuildOutputLocalDir BuildOutputType = 2
 	BuildOutputTar      BuildOutputType = 3
+	BuildOutputFSImage  BuildOutputType = 4 // a filesystem image, e.g. squashfs
 )
 
 // BuildOutputOptions contains the the outcome of parsing the value of a build --output flag
 type BuildOutputOption struct {
-	Type BuildOutputType
-	Path string // Only valid if Type is local dir or tar
+	Type       BuildOutputType
+	Path       string // Only valid if Type is local dir, tar, or filesystem image
+	Filesystem string // Only valid if Type is filesystem image
 }
 
 // GetBuildOutput is responsible for parsing custom build output argument i.e `build --output` flag.
@@ -43,6 +45,7 @@ func GetBuildOutput(buildOutput string) (BuildOutputOption, error) {
 	// Support complex values, in the form --output type=local,dest=./mydir
 	typeSelected := BuildOutputInvalid
 	pathSelected := ""
+	filesystemSelected := ""
 	for option := range strings.SplitSeq(buildOutput, ",") {
 		key, value, found := strings.Cut(option, "=")
 		if !found {
@@ -58,6 +61,9 @@ func GetBuildOutput(buildOutput string) (BuildOutputOption, error) {
 				typeSelected = BuildOutputLocalDir
 			case "tar":
 				typeSelected = BuildOutputTar
+			case "squashfs", "erofs", "ext4":
+				typeSelected = BuildOutputFSImage
+				filesystemSelected = value
 			default:
 				return BuildOutputOption{}, fmt.Errorf("invalid type %q selected for build output options %q", value, buildOutput)
 			}
@@ -77,7 +83,7 @@ func GetBuildOutput(buildOutput string) (BuildOutputOption, error) {
 	}
 
 	// Validate path
-	if typeSelected == BuildOutputLocalDir || typeSelected == BuildOutputTar {
+	if typeSelected == BuildOutputLocalDir || typeSelected == BuildOutputTar || typeSelected == BuildOutputFSImage {
 		if pathSelected == "" {
 			return BuildOutputOption{}, fmt.Errorf("missing required key %q in build output option: %q", "dest", buildOutput)
 		}
@@ -97,7 +103,8 @@ func GetBuildOutput(buildOutput string) (BuildOutputOption, error) {
 	}
 
 	return BuildOutputOption{
-		Type: typeSelected,
-		Path: pathSelected,
+		Type:       typeSelected,
+		Path:       pathSelected,
+		Filesystem: filesystemSelected,
 	}, nil
 }
@@ -36,6 +36,15 @@ func TestGetBuildOutput(t *testing.T) {
 				Path: "/tmp",
 			},
 		},
+		{
+			description: "filesystem-image",
+			input:       "type=erofs,dest=root.img",
+			output: BuildOutputOption{
+				Type:       BuildOutputFSImage,
+				Path:       "root.img",
+				Filesystem: "erofs",
+			},
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.description, func(t *testing.T) {
@@ -45,3 +54,14 @@ func TestGetBuildOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBuildOutputErrors(t *testing.T) {
+	for _, input := range []string{
+		"type=ext4",
+		"type=squashfs,dest=-",
+		"type=xfs,dest=root.img",
+	} {
+		_, err := GetBuildOutput(input)
+		assert.Errorf(t, err, "expected %q to be rejected", input)
+	}
+}
@@ -0,0 +1,330 @@
+// Package pin finds the image references in a Containerfile, resolves them
+// to the digests of the manifests which they currently refer to, and rewrites
+// the Containerfile so that they are pinned to those digests.
+package pin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/openshift/imagebuilder"
+	"go.podman.io/buildah/pkg/sourcepolicy"
+	"go.podman.io/image/v5/docker"
+	"go.podman.io/image/v5/docker/reference"
+	"go.podman.io/image/v5/image"
+	"go.podman.io/image/v5/manifest"
+	"go.podman.io/image/v5/pkg/shortnames"
+	"go.podman.io/image/v5/types"
+)
+
+// Reference is a reference to an image in a Containerfile.
+type Reference struct {
+	// StartLine and EndLine are the first and last lines of the
+	// instruction which contains the reference, starting at 1.
+	StartLine, EndLine int
+	// Instruction is the instruction which contains the reference:
+	// FROM, ADD, COPY, or RUN.
+	Instruction string
+	// Original is the reference, as it was written.
+	Original string
+	// Name is the reference, as it was written, without any digest.
+	Name string
+	// Tag is the reference's tag, if it has one.
+	Tag string
+	// Digest is the reference's digest, if it has one.
+	Digest digest.Digest
+}
+
+// Pinned returns the reference, pinned to the specified digest.
+func (r *Reference) Pinned(d digest.Digest) string {
+	return r.Name + "@" + d.String()
+}
+
+// Find parses a Containerfile and returns the references to images in its
+// FROM instructions, in --from flags of its ADD and COPY instructions, and in
+// the from= options of --mount flags of its RUN instructions.  References to
+// earlier stages, to "scratch", and to any of the named additional build
+// contexts are not included.  References which use ARG values can not be
+// pinned, and are described in the returned warnings.
+func Find(contents []byte, buildContexts []string) (refs []Reference, warnings []string, err error) {
+	node, err := imagebuilder.ParseDockerfile(bytes.NewReader(contents))
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing Containerfile: %w", err)
+	}
+	var stageNames []string
+	isStage := func(name string) bool {
+		if _, err := strconv.Atoi(name); err == nil {
+			return true
+		}
+		return slices.Contains(stageNames, strings.ToLower(name))
+	}
+	isBuildContext := func(name string) bool {
+		return slices.Contains(buildContexts, name)
+	}
+	add := func(child *nodeInfo, value string) error {
+		if strings.Contains(value, "$") {
+			warnings = append(warnings, fmt.Sprintf("line %d: not pinning %q, which uses build arguments", child.startLine, value))
+			return nil
+		}
+		named, err := reference.ParseNormalizedNamed(value)
+		if err != nil {
+			return fmt.Errorf("line %d: parsing image reference %q: %w", child.startLine, value, err)
+		}
+		ref := Reference{
+			StartLine:   child.startLine,
+			EndLine:     child.endLine,
+			Instruction: child.instruction,
+			Original:    value,
+			Name:        value,
+		}
+		if tagged, ok := named.(reference.Tagged); ok {
+			ref.Tag = tagged.Tag()
+		}
+		if digested, ok := named.(reference.Digested); ok {
+			ref.Digest = digested.Digest()
+			ref.Name = strings.TrimSuffix(value, "@"+ref.Digest.String())
+		}
+		refs = append(refs, ref)
+		return nil
+	}
+	for _, child := range node.Children {
+		info := &nodeInfo{
+			instruction: strings.ToUpper(child.Value),
+			startLine:   child.StartLine,
+			endLine:     child.EndLine,
+		}
+		switch info.instruction {
+		case "FROM":
+			if child.Next == nil {
+				continue
+			}
+			base := child.Next.Value
+			if !strings.EqualFold(base, "scratch") && !isStage(base) && !isBuildContext(base) {
+				if err := add(info, base); err != nil {
+					return nil, nil, err
+				}
+			}
+			if as := child.Next.Next; as != nil && strings.EqualFold(as.Value, "as") && as.Next != nil {
+				stageNames = append(stageNames, strings.ToLower(as.Next.Value))
+			}
+		case "ADD", "COPY":
+			for _, flag := range child.Flags {
+				if from, ok := strings.CutPrefix(flag, "--from="); ok && !isStage(from) && !isBuildContext(from) {
+					if err := add(info, from); err != nil {
+						return nil, nil, err
+					}
+				}
+			}
+		case "RUN":
+			for _, flag := range child.Flags {
+				mount, ok := strings.CutPrefix(flag, "--mount=")
+				if !ok {
+					continue
+				}
+				for field := range strings.SplitSeq(mount, ",") {
+					if from, ok := strings.CutPrefix(field, "from="); ok && !isStage(from) && !isBuildContext(from) {
+						if err := add(info, from); err != nil {
+							return nil, nil, err
+						}
+					}
+				}
+			}
+		}
+	}
+	return refs, warnings, nil
+}
+
+type nodeInfo struct {
+	instruction        string
+	startLine, endLine int
+}
+
+// isTokenBoundary returns true if c can appear next to a reference in an
+// instruction.
+func isTokenBoundary(c byte) bool {
+	return strings.IndexByte(" \t\r\n=,\\\"'", c) != -1
+}
+
+// Rewrite returns the Containerfile with each of the references replaced by
+// the corresponding replacement.  Empty replacements are skipped.
+func Rewrite(contents []byte, refs []Reference, replacements []string) ([]byte, error) {
+	if len(refs) != len(replacements) {
+		return nil, errors.New("internal error: mismatched references and replacements")
+	}
+	// find where each line starts
+	lineStarts := []int{0}
+	for i, c := range contents {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	lineStart := func(line int) int {
+		return lineStarts[min(max(line-1, 0), len(lineStarts)-1)]
+	}
+	lineEnd := func(line int) int {
+		if line < len(lineStarts) {
+			return lineStarts[line]
+		}
+		return len(contents)
+	}
+	type edit struct {
+		start, end  int
+		replacement string
+	}
+	var edits []edit
+	// references in the same instruction appear in order, so resume
+	// searching after the previous one
+	cursor, cursorLine := 0, 0
+	for i, ref := range refs {
+		start, end := lineStart(ref.StartLine), lineEnd(ref.EndLine)
+		if ref.StartLine == cursorLine {
+			start = cursor
+		}
+		offset := -1
+		for search := start; search < end; {
+			index := bytes.Index(contents[search:end], []byte(ref.Original))
+			if index == -1 {
+				break
+			}
+			index += search
+			after := index + len(ref.Original)
+			if (index == 0 || isTokenBoundary(contents[index-1])) && (after == len(contents) || isTokenBoundary(contents[after])) {
+				offset = index
+				break
+			}
+			search = index + 1
+		}
+		if offset == -1 {
+			return nil, fmt.Errorf("line %d: locating %q", ref.StartLine, ref.Original)
+		}
+		cursor, cursorLine = offset+len(ref.Original), ref.StartLine
+		if replacements[i] != "" && replacements[i] != ref.Original {
+			edits = append(edits, edit{start: offset, end: offset + len(ref.Original), replacement: replacements[i]})
+		}
+	}
+	var rewritten bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		rewritten.Write(contents[last:e.start])
+		rewritten.WriteString(e.replacement)
+		last = e.end
+	}
+	rewritten.Write(contents[last:])
+	return rewritten.Bytes(), nil
+}
+
+// Resolve looks up the image which the name, which should not include a
+// digest, currently refers to, using short-name resolution if it is not
+// fully-qualified.  It returns the fully-qualified name and the digest of the
+// image's manifest, which is a manifest list if the image is available for
+// multiple platforms.  If platforms are specified, it is an error if the
+// image is not available for each of them.
+func Resolve(ctx context.Context, sys *types.SystemContext, name string, platforms []v1.Platform) (reference.Named, digest.Digest, error) {
+	resolved, err := shortnames.Resolve(sys, name)
+	if err != nil {
+		return nil, "", fmt.Errorf("resolving %q: %w", name, err)
+	}
+	var errs []error
+	for _, candidate := range resolved.PullCandidates {
+		d, err := resolveCandidate(ctx, sys, candidate.Value, platforms)
+		if err == nil {
+			return candidate.Value, d, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 1 {
+		return nil, "", errs[0]
+	}
+	return nil, "", fmt.Errorf("resolving %q: %w", name, errors.Join(errs...))
+}
+
+func resolveCandidate(ctx context.Context, sys *types.SystemContext, named reference.Named, platforms []v1.Platform) (digest.Digest, error) {
+	ref, err := docker.NewReference(named)
+	if err != nil {
+		return "", fmt.Errorf("parsing reference to %q: %w", named.String(), err)
+	}
+	src, err := ref.NewImageSource(ctx, sys)
+	if err != nil {
+		return "", fmt.Errorf("reading %q: %w", named.String(), err)
+	}
+	defer src.Close()
+	manifestBytes, manifestType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("reading manifest for %q: %w", named.String(), err)
+	}
+	manifestDigest, err := manifest.Digest(manifestBytes)
+	if err != nil {
+		return "", fmt.Errorf("digesting manifest for %q: %w", named.String(), err)
+	}
+	if len(platforms) == 0 {
+		return manifestDigest, nil
+	}
+	if manifest.MIMETypeIsMultiImage(manifestType) {
+		list, err := manifest.ListFromBlob(manifestBytes, manifestType)
+		if err != nil {
+			return "", fmt.Errorf("parsing manifest list for %q: %w", named.String(), err)
+		}
+		for _, platform := range platforms {
+			platformSys := types.SystemContext{}
+			if sys != nil {
+				platformSys = *sys
+			}
+			platformSys.OSChoice = platform.OS
+			platformSys.ArchitectureChoice = platform.Architecture
+			platformSys.VariantChoice = platform.Variant
+			if _, err := list.ChooseInstance(&platformSys); err != nil {
+				return "", fmt.Errorf("%q: %w", named.String(), err)
+			}
+		}
+		return manifestDigest, nil
+	}
+	img, err := image.FromUnparsedImage(ctx, sys, image.UnparsedInstance(src, nil))
+	if err != nil {
+		return "", fmt.Errorf("reading image %q: %w", named.String(), err)
+	}
+	config, err := img.OCIConfig(ctx)
+	if err != nil {
+		return "", fmt.Errorf("reading configuration of image %q: %w", named.String(), err)
+	}
+	for _, platform := range platforms {
+		if config.OS != platform.OS || config.Architecture != platform.Architecture || (platform.Variant != "" && config.Variant != platform.Variant) {
+			return "", fmt.Errorf("%q is only available for %s", named.String(), formatPlatform(config.OS, config.Architecture, config.Variant))
+		}
+	}
+	return manifestDigest, nil
+}
+
+func formatPlatform(os, arch, variant string) string {
+	if variant != "" {
+		return os + "/" + arch + "/" + variant
+	}
+	return os + "/" + arch
+}
+
+// PolicyRule returns a source policy rule which converts the reference, as it
+// is written, to the resolved name pinned to the digest.  Because builds only
+// evaluate source policies for base images, the rule has no effect on other
+// references.
+func PolicyRule(ref Reference, resolved reference.Named, d digest.Digest) (sourcepolicy.Rule, error) {
+	pinned, err := reference.WithDigest(reference.TagNameOnly(resolved), d)
+	if err != nil {
+		return sourcepolicy.Rule{}, fmt.Errorf("adding digest %q to %q: %w", d.String(), resolved.String(), err)
+	}
+	return sourcepolicy.Rule{
+		Action: sourcepolicy.ActionConvert,
+		Selector: sourcepolicy.Selector{
+			Identifier: sourcepolicy.ImageSourceIdentifier(ref.Original),
+			MatchType:  sourcepolicy.MatchTypeExact,
+		},
+		Updates: &sourcepolicy.Updates{
+			Identifier: sourcepolicy.ImageSourceIdentifier(pinned.String()),
+		},
+	}, nil
+}
@@ -0,0 +1,98 @@
+package pin
+
+import (
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/buildah/pkg/sourcepolicy"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+const testDigest = digest.Digest("sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+
+func TestFindAndRewrite(t *testing.T) {
+	t.Parallel()
+	containerfile := "ARG BASE=busybox\n" +
+		"FROM registry.example.com/base:1.2 AS build\n" +
+		"COPY --from=build /a /b\n" +
+		"COPY --from=quay.io/tools/helper:latest /bin/helper /bin/\n" +
+		"FROM $BASE\n" +
+		"FROM scratch\n" +
+		"FROM build\n" +
+		"RUN --mount=type=bind,from=registry.example.com/base:1.2,target=/mnt \\\n" +
+		"    --mount=type=bind,from=0,target=/src true\n" +
+		"FROM registry.example.com/other:2@" + testDigest.String() + "\n"
+	refs, warnings, err := Find([]byte(containerfile), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`line 5: not pinning "$BASE", which uses build arguments`}, warnings)
+	require.Len(t, refs, 4)
+
+	assert.Equal(t, Reference{StartLine: 2, EndLine: 2, Instruction: "FROM", Original: "registry.example.com/base:1.2", Name: "registry.example.com/base:1.2", Tag: "1.2"}, refs[0])
+	assert.Equal(t, "COPY", refs[1].Instruction)
+	assert.Equal(t, "quay.io/tools/helper:latest", refs[1].Original)
+	assert.Equal(t, "RUN", refs[2].Instruction)
+	assert.Equal(t, 8, refs[2].StartLine)
+	assert.Equal(t, 9, refs[2].EndLine)
+	assert.Equal(t, testDigest, refs[3].Digest)
+	assert.Equal(t, "2", refs[3].Tag)
+	assert.Equal(t, "registry.example.com/other:2", refs[3].Name)
+
+	other := digest.Digest("sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210")
+	replacements := []string{refs[0].Pinned(other), "", refs[2].Pinned(other), refs[3].Pinned(other)}
+	rewritten, err := Rewrite([]byte(containerfile), refs, replacements)
+	require.NoError(t, err)
+	assert.Equal(t, "ARG BASE=busybox\n"+
+		"FROM registry.example.com/base:1.2@"+other.String()+" AS build\n"+
+		"COPY --from=build /a /b\n"+
+		"COPY --from=quay.io/tools/helper:latest /bin/helper /bin/\n"+
+		"FROM $BASE\n"+
+		"FROM scratch\n"+
+		"FROM build\n"+
+		"RUN --mount=type=bind,from=registry.example.com/base:1.2@"+other.String()+",target=/mnt \\\n"+
+		"    --mount=type=bind,from=0,target=/src true\n"+
+		"FROM registry.example.com/other:2@"+other.String()+"\n", string(rewritten))
+
+	_, err = Rewrite([]byte(containerfile), refs, nil)
+	assert.Error(t, err)
+
+	_, _, err = Find([]byte("FROM Not/Valid:Reference\n"), nil)
+	assert.ErrorContains(t, err, "line 1: parsing image reference")
+}
+
+func TestFindBuildContexts(t *testing.T) {
+	t.Parallel()
+	containerfile := "FROM base\n" +
+		"COPY --from=sources /src /src\n" +
+		"COPY --from=busybox /bin/sh /bin/sh\n" +
+		"RUN --mount=type=bind,from=tools,target=/tools true\n"
+	refs, _, err := Find([]byte(containerfile), nil)
+	require.NoError(t, err)
+	assert.Len(t, refs, 4)
+
+	// references to additional build contexts aren't references to images
+	refs, _, err = Find([]byte(containerfile), []string{"base", "sources", "tools"})
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	assert.Equal(t, "busybox", refs[0].Original)
+	assert.Equal(t, 3, refs[0].StartLine)
+}
+
+func TestPolicyRule(t *testing.T) {
+	t.Parallel()
+	resolved, err := reference.ParseNormalizedNamed("busybox")
+	require.NoError(t, err)
+	rule, err := PolicyRule(Reference{Original: "busybox"}, resolved, testDigest)
+	require.NoError(t, err)
+	assert.Equal(t, sourcepolicy.Rule{
+		Action: sourcepolicy.ActionConvert,
+		Selector: sourcepolicy.Selector{
+			Identifier: sourcepolicy.ImageSourceIdentifier("busybox"),
+			MatchType:  sourcepolicy.MatchTypeExact,
+		},
+		Updates: &sourcepolicy.Updates{
+			Identifier: sourcepolicy.ImageSourceIdentifier("docker.io/library/busybox:latest@" + testDigest.String()),
+		},
+	}, rule)
+}
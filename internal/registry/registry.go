@@ -0,0 +1,1126 @@
+// Package registry serves images from local storage using the OCI
+// distribution API, so that tools which can only pull from (or push to) a
+// registry can use them without their being copied to one first.
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	digest "github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"go.podman.io/buildah/internal/tmpdir"
+	"go.podman.io/image/v5/copy"
+	"go.podman.io/image/v5/docker/reference"
+	"go.podman.io/image/v5/image"
+	"go.podman.io/image/v5/manifest"
+	"go.podman.io/image/v5/oci/layout"
+	"go.podman.io/image/v5/signature"
+	storageTransport "go.podman.io/image/v5/storage"
+	"go.podman.io/image/v5/transports"
+	"go.podman.io/image/v5/types"
+	"go.podman.io/storage"
+	"go.podman.io/storage/pkg/archive"
+	"go.podman.io/storage/pkg/ioutils"
+	"go.podman.io/storage/pkg/stringid"
+)
+
+// maxManifestSize is the largest manifest which we'll accept.
+const maxManifestSize = 4 << 20
+
+// maxGeneratedSize is how much memory we'll use to remember manifests which
+// we generated, so that they can be requested by digest.
+const maxGeneratedSize = 16 << 20
+
+var (
+	errBlobUnknown         = errors.New("blob unknown to registry")
+	errManifestUnknown     = errors.New("manifest unknown")
+	errManifestInvalid     = errors.New("manifest invalid")
+	errManifestBlobUnknown = errors.New("manifest refers to a blob unknown to registry")
+)
+
+// Options controls how a Server handles requests.
+type Options struct {
+	// ReadWrite allows clients to push images, which are added to the
+	// store.  If not set, only pulling is allowed.
+	ReadWrite bool
+	// UploadDirectory is where blobs which are pushed are kept until the
+	// manifests which refer to them are pushed.  If not set, a temporary
+	// directory is created.
+	UploadDirectory string
+}
+
+// Server is an http.Handler which implements the pull and push parts of
+// the OCI distribution API using images in a store.
+//
+// Repository names which don't include a registry name refer to images with
+// names in "localhost", and then in "docker.io".  Images which are pushed
+// to such repositories are given names in "localhost", which is where
+// "buildah commit" puts images with unqualified names.
+//
+// Layers are read from the store when they are pulled, and are always
+// uncompressed.  When an image's manifest refers to layers which were
+// compressed, such as when the image was pulled from a registry, the
+// manifest which is served in response to a request by tag refers to the
+// uncompressed layers instead, and has a different digest.
+type Server struct {
+	store          storage.Store
+	readWrite      bool
+	dir            string
+	removeDir      bool
+	lock           sync.Mutex
+	generated      map[digest.Digest][]byte
+	generatedOrder []digest.Digest // least recently used first
+	generatedSize  int
+	maxGenerated   int
+}
+
+// New returns a Server which serves images from store.  The returned Server
+// should be closed when it is no longer needed.
+func New(store storage.Store, options Options) (*Server, error) {
+	s := &Server{
+		store:        store,
+		readWrite:    options.ReadWrite,
+		dir:          options.UploadDirectory,
+		generated:    make(map[digest.Digest][]byte),
+		maxGenerated: maxGeneratedSize,
+	}
+	if s.readWrite {
+		if s.dir == "" {
+			dir, err := os.MkdirTemp(tmpdir.GetTempDir(), "buildah-registry")
+			if err != nil {
+				return nil, err
+			}
+			s.dir = dir
+			s.removeDir = true
+		}
+		// uploaded blobs and manifests are kept in an OCI layout, from
+		// which they are copied into the store
+		if err := os.MkdirAll(filepath.Join(s.dir, "uploads"), 0o700); err != nil {
+			return nil, err
+		}
+		layoutFile, err := json.Marshal(imgspecv1.ImageLayout{Version: imgspecv1.ImageLayoutVersion})
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutils.AtomicWriteFile(filepath.Join(s.dir, imgspecv1.ImageLayoutFile), layoutFile, 0o600); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Close removes any blobs which were uploaded to the Server.
+func (s *Server) Close() error {
+	if s.removeDir {
+		return os.RemoveAll(s.dir)
+	}
+	return nil
+}
+
+type errorInfo struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// fail sends an error response in the format which the distribution API
+// specifies.
+func fail(w http.ResponseWriter, status int, code string, err error) {
+	logrus.Debugf("registry: %s: %v", code, err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	response := struct {
+		Errors []errorInfo `json:"errors"`
+	}{
+		Errors: []errorInfo{{Code: code, Message: err.Error()}},
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.Debugf("registry: sending error response: %v", err)
+	}
+}
+
+func unsupported(w http.ResponseWriter, r *http.Request) {
+	fail(w, http.StatusMethodNotAllowed, "UNSUPPORTED", fmt.Errorf("%s %s is not supported", r.Method, r.URL.Path))
+}
+
+func sendJSON(w http.ResponseWriter, r *http.Request, v any) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		fail(w, http.StatusInternalServerError, "UNKNOWN", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(encoded)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(encoded)
+	}
+}
+
+// ServeHTTP handles a request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logrus.Debugf("registry: %s %s", r.Method, r.URL)
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+	switch r.URL.Path {
+	case "/v2", "/v2/":
+		if !readOnly {
+			unsupported(w, r)
+			return
+		}
+		sendJSON(w, r, struct{}{})
+		return
+	case "/v2/_catalog":
+		if !readOnly {
+			unsupported(w, r)
+			return
+		}
+		s.catalog(w, r)
+		return
+	}
+	path, ok := strings.CutPrefix(r.URL.Path, "/v2/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var name, kind, rest string
+	if n, ok := strings.CutSuffix(path, "/tags/list"); ok {
+		name, kind = n, "tags"
+	} else if i := strings.LastIndex(path, "/blobs/uploads"); i > 0 && (len(path) == i+len("/blobs/uploads") || path[i+len("/blobs/uploads")] == '/') {
+		name, kind, rest = path[:i], "uploads", strings.Trim(path[i+len("/blobs/uploads"):], "/")
+	} else if i := strings.LastIndex(path, "/manifests/"); i > 0 {
+		name, kind, rest = path[:i], "manifests", path[i+len("/manifests/"):]
+	} else if i := strings.LastIndex(path, "/blobs/"); i > 0 {
+		name, kind, rest = path[:i], "blobs", path[i+len("/blobs/"):]
+	} else {
+		http.NotFound(w, r)
+		return
+	}
+	repositories, err := repositories(name)
+	if err != nil {
+		fail(w, http.StatusBadRequest, "NAME_INVALID", err)
+		return
+	}
+	if !readOnly && !s.readWrite {
+		fail(w, http.StatusMethodNotAllowed, "UNSUPPORTED", errors.New("pushing is not enabled"))
+		return
+	}
+
+	switch kind {
+	case "tags":
+		if !readOnly {
+			unsupported(w, r)
+			return
+		}
+		s.tags(w, r, name, repositories)
+	case "manifests":
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			s.getManifest(w, r, repositories, rest)
+		case http.MethodPut:
+			s.putManifest(w, r, name, repositories, rest)
+		default:
+			unsupported(w, r)
+		}
+	case "blobs":
+		if !readOnly {
+			unsupported(w, r)
+			return
+		}
+		s.getBlob(w, r, rest)
+	case "uploads":
+		if !s.readWrite {
+			fail(w, http.StatusMethodNotAllowed, "UNSUPPORTED", errors.New("pushing is not enabled"))
+			return
+		}
+		if rest == "" {
+			if r.Method != http.MethodPost {
+				unsupported(w, r)
+				return
+			}
+			s.startUpload(w, r, name)
+			return
+		}
+		if err := stringid.ValidateID(rest); err != nil {
+			fail(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", err)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			s.uploadStatus(w, name, rest)
+		case http.MethodPatch:
+			s.appendUpload(w, r, name, rest, false)
+		case http.MethodPut:
+			s.appendUpload(w, r, name, rest, true)
+		case http.MethodDelete:
+			if err := os.Remove(s.uploadPath(rest)); err != nil {
+				fail(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			unsupported(w, r)
+		}
+	}
+}
+
+// repositories returns the fully-qualified names of the repositories in
+// which images in a repository called name can be found.
+func repositories(name string) ([]string, error) {
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return nil, fmt.Errorf("parsing repository name %q: %w", name, err)
+	}
+	if !reference.IsNameOnly(named) {
+		return nil, fmt.Errorf("repository name %q includes a tag or digest", name)
+	}
+	if strings.HasPrefix(name, reference.Domain(named)+"/") {
+		return []string{named.Name()}, nil
+	}
+	return []string{"localhost/" + name, named.Name()}, nil
+}
+
+// paginate applies the "n" and "last" parameters in a request to a sorted
+// list.
+func paginate(r *http.Request, items []string) ([]string, error) {
+	if last := r.URL.Query().Get("last"); last != "" {
+		i, _ := slices.BinarySearch(items, last)
+		for i < len(items) && items[i] == last {
+			i++
+		}
+		items = items[i:]
+	}
+	if n := r.URL.Query().Get("n"); n != "" {
+		count, err := strconv.Atoi(n)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("invalid value for n: %q", n)
+		}
+		items = items[:min(count, len(items))]
+	}
+	return items, nil
+}
+
+// names returns the parsed names of every image in the store.
+func (s *Server) names() ([]reference.Named, error) {
+	images, err := s.store.Images()
+	if err != nil {
+		return nil, err
+	}
+	var names []reference.Named
+	for _, img := range images {
+		for _, name := range img.Names {
+			named, err := reference.ParseNormalizedNamed(name)
+			if err != nil {
+				continue
+			}
+			names = append(names, named)
+		}
+	}
+	return names, nil
+}
+
+func (s *Server) catalog(w http.ResponseWriter, r *http.Request) {
+	names, err := s.names()
+	if err != nil {
+		fail(w, http.StatusInternalServerError, "UNKNOWN", err)
+		return
+	}
+	repositories := []string{}
+	for _, named := range names {
+		repositories = append(repositories, named.Name())
+	}
+	slices.Sort(repositories)
+	repositories, err = paginate(r, slices.Compact(repositories))
+	if err != nil {
+		fail(w, http.StatusBadRequest, "PAGINATION_NUMBER_INVALID", err)
+		return
+	}
+	sendJSON(w, r, struct {
+		Repositories []string `json:"repositories"`
+	}{Repositories: repositories})
+}
+
+func (s *Server) tags(w http.ResponseWriter, r *http.Request, name string, repositories []string) {
+	names, err := s.names()
+	if err != nil {
+		fail(w, http.StatusInternalServerError, "UNKNOWN", err)
+		return
+	}
+	tags := []string{}
+	for _, named := range names {
+		if tagged, ok := named.(reference.NamedTagged); ok && slices.Contains(repositories, named.Name()) {
+			tags = append(tags, tagged.Tag())
+		}
+	}
+	if len(tags) == 0 {
+		fail(w, http.StatusNotFound, "NAME_UNKNOWN", fmt.Errorf("repository %q not known to registry", name))
+		return
+	}
+	slices.Sort(tags)
+	tags, err = paginate(r, slices.Compact(tags))
+	if err != nil {
+		fail(w, http.StatusBadRequest, "PAGINATION_NUMBER_INVALID", err)
+		return
+	}
+	sendJSON(w, r, struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}{Name: name, Tags: tags})
+}
+
+// manifestBigDataKey returns the key under which the storage transport
+// records the manifest with digest d.
+func manifestBigDataKey(d digest.Digest) string {
+	return storage.ImageDigestManifestBigDataNamePrefix + "-" + d.String()
+}
+
+// readManifest reads the manifest of img which has the digest d, or its
+// default manifest if d is not set.
+func (s *Server) readManifest(img *storage.Image, d digest.Digest) ([]byte, error) {
+	if d != "" {
+		blob, err := s.store.ImageBigData(img.ID, manifestBigDataKey(d))
+		if err == nil {
+			return blob, nil
+		}
+	}
+	blob, err := s.store.ImageBigData(img.ID, storage.ImageDigestBigDataKey)
+	if err != nil {
+		return nil, err
+	}
+	if d != "" {
+		if matches, err := manifest.MatchesDigest(blob, d); err != nil || !matches {
+			return nil, fmt.Errorf("image %q has no manifest with digest %q: %w", img.ID, d, errManifestUnknown)
+		}
+	}
+	return blob, nil
+}
+
+// lookupManifest finds the manifest for a tag in one of the repositories, or
+// for a digest.
+func (s *Server) lookupManifest(ctx context.Context, repositories []string, ref string) ([]byte, error) {
+	if d, err := digest.Parse(ref); err == nil {
+		if blob, ok := s.lookupGenerated(d); ok {
+			return blob, nil
+		}
+		images, err := s.store.ImagesByDigest(d)
+		if err != nil && !errors.Is(err, storage.ErrImageUnknown) {
+			return nil, err
+		}
+		for i := range images {
+			if blob, err := s.readManifest(images[i], d); err == nil {
+				return blob, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", errManifestUnknown, ref)
+	}
+	for _, repository := range repositories {
+		if _, err := reference.ParseNormalizedNamed(repository + ":" + ref); err != nil {
+			return nil, fmt.Errorf("invalid tag %q: %w", ref, err)
+		}
+		img, err := s.store.Image(repository + ":" + ref)
+		if err != nil {
+			if errors.Is(err, storage.ErrImageUnknown) {
+				continue
+			}
+			return nil, err
+		}
+		blob, err := s.readManifest(img, "")
+		if err != nil {
+			return nil, err
+		}
+		return s.servable(ctx, img, nil, blob)
+	}
+	return nil, fmt.Errorf("%w: %s", errManifestUnknown, ref)
+}
+
+// servable returns a version of the manifest blob, which belongs to img,
+// which only refers to blobs which we can serve.  If the manifest needs to
+// be changed, the new version is remembered so that it can later be
+// requested by digest.
+func (s *Server) servable(ctx context.Context, img *storage.Image, instance *digest.Digest, blob []byte) ([]byte, error) {
+	var updated []byte
+	var err error
+	if mimeType := manifest.GuessMIMEType(blob); manifest.MIMETypeIsMultiImage(mimeType) {
+		updated, err = s.servableList(ctx, blob, mimeType)
+	} else {
+		updated, err = s.servableImage(ctx, img, instance, blob, mimeType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return blob, nil
+	}
+	s.rememberGenerated(updated)
+	return updated, nil
+}
+
+// rememberGenerated records a manifest which we generated, so that it can be
+// requested by digest, forgetting the ones which were least recently used if
+// they're taking up too much memory.  A client which asks for one of those
+// later will have to ask for it by tag again.
+func (s *Server) rememberGenerated(blob []byte) {
+	d := digest.FromBytes(blob)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.generated[d]; ok {
+		s.touchGenerated(d)
+		return
+	}
+	s.generated[d] = blob
+	s.generatedOrder = append(s.generatedOrder, d)
+	s.generatedSize += len(blob)
+	for s.generatedSize > s.maxGenerated && len(s.generatedOrder) > 1 {
+		oldest := s.generatedOrder[0]
+		s.generatedOrder = slices.Delete(s.generatedOrder, 0, 1)
+		s.generatedSize -= len(s.generated[oldest])
+		delete(s.generated, oldest)
+	}
+}
+
+// lookupGenerated returns a manifest which we generated, if we still have it.
+func (s *Server) lookupGenerated(d digest.Digest) ([]byte, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	blob, ok := s.generated[d]
+	if ok {
+		s.touchGenerated(d)
+	}
+	return blob, ok
+}
+
+// touchGenerated marks a generated manifest as the most recently used one.
+// The caller must hold the lock.
+func (s *Server) touchGenerated(d digest.Digest) {
+	if i := slices.Index(s.generatedOrder, d); i != -1 {
+		s.generatedOrder = append(slices.Delete(s.generatedOrder, i, i+1), d)
+	}
+}
+
+// servableList returns an updated version of a manifest list if any of its
+// instances need to be updated, or nil if none do.
+func (s *Server) servableList(ctx context.Context, blob []byte, mimeType string) ([]byte, error) {
+	list, err := manifest.ListFromBlob(blob, mimeType)
+	if err != nil {
+		return nil, err
+	}
+	var updates []manifest.ListUpdate
+	changed := false
+	for _, instanceDigest := range list.Instances() {
+		instance, err := list.Instance(instanceDigest)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, instance)
+		images, err := s.store.ImagesByDigest(instanceDigest)
+		if err != nil || len(images) == 0 {
+			continue
+		}
+		instanceBlob, err := s.readManifest(images[0], instanceDigest)
+		if err != nil {
+			continue
+		}
+		servable, err := s.servable(ctx, images[0], &instanceDigest, instanceBlob)
+		if err != nil {
+			return nil, err
+		}
+		if servableDigest := digest.FromBytes(servable); servableDigest != instanceDigest {
+			updates[len(updates)-1].Digest = servableDigest
+			updates[len(updates)-1].Size = int64(len(servable))
+			updates[len(updates)-1].MediaType = manifest.GuessMIMEType(servable)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, nil
+	}
+	if err := list.UpdateInstances(updates); err != nil {
+		return nil, err
+	}
+	return list.Serialize()
+}
+
+// servableImage returns an updated version of an image's manifest if it
+// refers to layers which we can't serve, or nil if it doesn't.
+func (s *Server) servableImage(ctx context.Context, img *storage.Image, instance *digest.Digest, blob []byte, mimeType string) ([]byte, error) {
+	m, err := manifest.FromBlob(blob, mimeType)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(m.LayerInfos(), func(layer manifest.LayerInfo) bool { return !s.haveLayer(layer.Digest, false) }) {
+		return nil, nil
+	}
+	// let the storage transport tell us which blobs it would produce
+	// for the image's layers
+	if instance != nil {
+		if _, err := s.store.ImageBigData(img.ID, manifestBigDataKey(*instance)); err != nil {
+			instance = nil
+		}
+	}
+	ref, err := storageTransport.Transport.NewStoreReference(s.store, nil, img.ID)
+	if err != nil {
+		return nil, err
+	}
+	src, err := ref.NewImageSource(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	layerInfos, err := src.LayerInfosForCopy(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	original := m.LayerInfos()
+	for i := range layerInfos {
+		if i < len(original) && layerInfos[i].Digest != original[i].Digest && layerInfos[i].Digest != image.GzippedEmptyLayerDigest {
+			layerInfos[i].CompressionOperation = types.Decompress
+		}
+	}
+	if err := m.UpdateLayerInfos(layerInfos); err != nil {
+		return nil, err
+	}
+	return m.Serialize()
+}
+
+// haveLayer returns true if the store has a layer whose contents, either
+// uncompressed or, if compressed is set, as they were first compressed,
+// match d.
+func (s *Server) haveLayer(d digest.Digest, compressed bool) bool {
+	if d == image.GzippedEmptyLayerDigest {
+		return true
+	}
+	if layers, err := s.store.LayersByUncompressedDigest(d); err == nil && len(layers) > 0 {
+		return true
+	}
+	if compressed {
+		if layers, err := s.store.LayersByCompressedDigest(d); err == nil && len(layers) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) getManifest(w http.ResponseWriter, r *http.Request, repositories []string, ref string) {
+	blob, err := s.lookupManifest(r.Context(), repositories, ref)
+	if err != nil {
+		if errors.Is(err, errManifestUnknown) {
+			fail(w, http.StatusNotFound, "MANIFEST_UNKNOWN", err)
+			return
+		}
+		fail(w, http.StatusInternalServerError, "UNKNOWN", err)
+		return
+	}
+	w.Header().Set("Content-Type", manifest.GuessMIMEType(blob))
+	w.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+	w.Header().Set("Docker-Content-Digest", digest.FromBytes(blob).String())
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(blob)
+	}
+}
+
+// blob is a blob which can be sent to a client.
+type blob struct {
+	size int64 // -1 if not known
+	open func() (io.ReadCloser, error)
+}
+
+// lookupBlob finds the blob with digest d, which can be a layer, the
+// configuration blob for an image, or a blob which was pushed to us.
+func (s *Server) lookupBlob(d digest.Digest) (*blob, error) {
+	if d == image.GzippedEmptyLayerDigest {
+		return &blob{
+			size: int64(len(image.GzippedEmptyLayer)),
+			open: func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(image.GzippedEmptyLayer)), nil },
+		}, nil
+	}
+	if layers, err := s.store.LayersByUncompressedDigest(d); err == nil && len(layers) > 0 {
+		layer := layers[0]
+		return &blob{
+			size: max(layer.UncompressedSize, -1),
+			open: func() (io.ReadCloser, error) {
+				uncompressed := archive.Uncompressed
+				return s.store.Diff("", layer.ID, &storage.DiffOptions{Compression: &uncompressed})
+			},
+		}, nil
+	}
+	images, err := s.store.Images()
+	if err != nil {
+		return nil, err
+	}
+	for _, img := range images {
+		if !slices.Contains(img.BigDataNames, d.String()) {
+			continue
+		}
+		size, err := s.store.ImageBigDataSize(img.ID, d.String())
+		if err != nil {
+			continue
+		}
+		return &blob{
+			size: size,
+			open: func() (io.ReadCloser, error) {
+				data, err := s.store.ImageBigData(img.ID, d.String())
+				if err != nil {
+					return nil, err
+				}
+				return io.NopCloser(bytes.NewReader(data)), nil
+			},
+		}, nil
+	}
+	if s.readWrite {
+		if st, err := os.Stat(s.blobPath(d)); err == nil {
+			return &blob{
+				size: st.Size(),
+				open: func() (io.ReadCloser, error) { return os.Open(s.blobPath(d)) },
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", errBlobUnknown, d)
+}
+
+func (s *Server) getBlob(w http.ResponseWriter, r *http.Request, ref string) {
+	d, err := digest.Parse(ref)
+	if err != nil {
+		fail(w, http.StatusBadRequest, "DIGEST_INVALID", err)
+		return
+	}
+	b, err := s.lookupBlob(d)
+	if err != nil {
+		if errors.Is(err, errBlobUnknown) {
+			fail(w, http.StatusNotFound, "BLOB_UNKNOWN", err)
+			return
+		}
+		fail(w, http.StatusInternalServerError, "UNKNOWN", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", d.String())
+	if b.size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(b.size, 10))
+	}
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	rc, err := b.open()
+	if err != nil {
+		fail(w, http.StatusInternalServerError, "UNKNOWN", err)
+		return
+	}
+	defer rc.Close()
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, rc); err != nil {
+		logrus.Debugf("registry: sending blob %q: %v", d, err)
+	}
+}
+
+func (s *Server) blobPath(d digest.Digest) string {
+	return filepath.Join(s.dir, imgspecv1.ImageBlobsDir, d.Algorithm().String(), d.Encoded())
+}
+
+func (s *Server) uploadPath(id string) string {
+	return filepath.Join(s.dir, "uploads", id)
+}
+
+func blobLocation(name string, d digest.Digest) string {
+	return "/v2/" + name + "/blobs/" + d.String()
+}
+
+func uploadLocation(name, id string) string {
+	return "/v2/" + name + "/blobs/uploads/" + id
+}
+
+func (s *Server) startUpload(w http.ResponseWriter, r *http.Request, name string) {
+	// if we already have the blob, there's no need to upload it
+	if mount := r.URL.Query().Get("mount"); mount != "" {
+		if d, err := digest.Parse(mount); err == nil {
+			if _, err := s.lookupBlob(d); err == nil {
+				w.Header().Set("Location", blobLocation(name, d))
+				w.Header().Set("Docker-Content-Digest", d.String())
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+		}
+	}
+	id := stringid.GenerateRandomID()
+	f, err := os.OpenFile(s.uploadPath(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		fail(w, http.StatusInternalServerError, "UNKNOWN", err)
+		return
+	}
+	if err := f.Close(); err != nil {
+		fail(w, http.StatusInternalServerError, "UNKNOWN", err)
+		return
+	}
+	// a single-request upload
+	if r.URL.Query().Get("digest") != "" {
+		s.appendUpload(w, r, name, id, true)
+		return
+	}
+	w.Header().Set("Location", uploadLocation(name, id))
+	w.Header().Set("Docker-Upload-UUID", id)
+	w.Header().Set("Range", "0-0")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func uploadRange(size int64) string {
+	return "0-" + strconv.FormatInt(max(size-1, 0), 10)
+}
+
+func (s *Server) uploadStatus(w http.ResponseWriter, name, id string) {
+	st, err := os.Stat(s.uploadPath(id))
+	if err != nil {
+		fail(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", err)
+		return
+	}
+	w.Header().Set("Location", uploadLocation(name, id))
+	w.Header().Set("Docker-Upload-UUID", id)
+	w.Header().Set("Range", uploadRange(st.Size()))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// appendUpload adds the request body to an upload, and if finish is set,
+// checks that its contents match the digest which the client specified and
+// adds it to the blobs which manifests can refer to.
+func (s *Server) appendUpload(w http.ResponseWriter, r *http.Request, name, id string, finish bool) {
+	var expected digest.Digest
+	if finish {
+		var err error
+		if expected, err = digest.Parse(r.URL.Query().Get("digest")); err != nil {
+			fail(w, http.StatusBadRequest, "DIGEST_INVALID", err)
+			return
+		}
+	}
+	f, err := os.OpenFile(s.uploadPath(id), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		fail(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", err)
+		return
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		fail(w, http.StatusInternalServerError, "UNKNOWN", err)
+		return
+	}
+	if contentRange := r.Header.Get("Content-Range"); contentRange != "" {
+		start, _, _ := strings.Cut(strings.TrimPrefix(contentRange, "bytes "), "-")
+		if offset, err := strconv.ParseInt(start, 10, 64); err != nil || offset != st.Size() {
+			w.Header().Set("Location", uploadLocation(name, id))
+			w.Header().Set("Range", uploadRange(st.Size()))
+			fail(w, http.StatusRequestedRangeNotSatisfiable, "BLOB_UPLOAD_INVALID", fmt.Errorf("upload of %d bytes can't continue at %q", st.Size(), contentRange))
+			return
+		}
+	}
+	n, err := io.Copy(f, r.Body)
+	if err != nil {
+		fail(w, http.StatusInternalServerError, "BLOB_UPLOAD_INVALID", err)
+		return
+	}
+	size := st.Size() + n
+	if !finish {
+		w.Header().Set("Location", uploadLocation(name, id))
+		w.Header().Set("Docker-Upload-UUID", id)
+		w.Header().Set("Range", uploadRange(size))
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err := f.Close(); err != nil {
+		fail(w, http.StatusInternalServerError, "UNKNOWN", err)
+		return
+	}
+	if err := s.commitUpload(id, expected); err != nil {
+		os.Remove(s.uploadPath(id))
+		fail(w, http.StatusBadRequest, "DIGEST_INVALID", err)
+		return
+	}
+	w.Header().Set("Location", blobLocation(name, expected))
+	w.Header().Set("Docker-Content-Digest", expected.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+// commitUpload checks that the contents of an upload match the expected
+// digest, and moves it to where blobs are kept.
+func (s *Server) commitUpload(id string, expected digest.Digest) error {
+	if !expected.Algorithm().Available() {
+		return fmt.Errorf("unsupported digest algorithm %q", expected.Algorithm())
+	}
+	f, err := os.Open(s.uploadPath(id))
+	if err != nil {
+		return err
+	}
+	actual, err := expected.Algorithm().FromReader(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("uploaded content has digest %q, not %q", actual, expected)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.blobPath(expected)), 0o700); err != nil {
+		return err
+	}
+	return os.Rename(s.uploadPath(id), s.blobPath(expected))
+}
+
+// stageBlob makes sure that the blob with digest d is in our layout, copying
+// it from the store if needed.
+func (s *Server) stageBlob(d digest.Digest) error {
+	if _, err := os.Stat(s.blobPath(d)); err == nil {
+		return nil
+	}
+	b, err := s.lookupBlob(d)
+	if err != nil {
+		return err
+	}
+	rc, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	return s.writeBlob(d, data)
+}
+
+func (s *Server) writeBlob(d digest.Digest, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(s.blobPath(d)), 0o700); err != nil {
+		return err
+	}
+	return ioutils.AtomicWriteFile(s.blobPath(d), data, 0o600)
+}
+
+// stageManifest makes sure that everything which the manifest refers to is
+// either in our layout or can be reused from the store, and adds the
+// manifest to the layout.
+func (s *Server) stageManifest(blob []byte, mimeType string) error {
+	if manifest.MIMETypeIsMultiImage(mimeType) {
+		list, err := manifest.ListFromBlob(blob, mimeType)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errManifestInvalid, err)
+		}
+		for _, instance := range list.Instances() {
+			if _, err := os.Stat(s.blobPath(instance)); err == nil {
+				continue
+			}
+			instanceBlob, err := s.lookupManifest(context.Background(), nil, instance.String())
+			if err != nil {
+				return fmt.Errorf("%w: %w", errManifestBlobUnknown, err)
+			}
+			if err := s.stageManifest(instanceBlob, manifest.GuessMIMEType(instanceBlob)); err != nil {
+				return err
+			}
+		}
+	} else {
+		m, err := manifest.FromBlob(blob, mimeType)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errManifestInvalid, err)
+		}
+		if err := s.stageBlob(m.ConfigInfo().Digest); err != nil {
+			return fmt.Errorf("%w: %w", errManifestBlobUnknown, err)
+		}
+		for _, layer := range m.LayerInfos() {
+			if _, err := os.Stat(s.blobPath(layer.Digest)); err == nil || s.haveLayer(layer.Digest, true) {
+				continue
+			}
+			return fmt.Errorf("%w: layer %q", errManifestBlobUnknown, layer.Digest)
+		}
+	}
+	d := digest.FromBytes(blob)
+	if err := s.writeBlob(d, blob); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	indexPath := filepath.Join(s.dir, imgspecv1.ImageIndexFile)
+	index := imgspecv1.Index{Versioned: imgspecs.Versioned{SchemaVersion: 2}, MediaType: imgspecv1.MediaTypeImageIndex}
+	if data, err := os.ReadFile(indexPath); err == nil {
+		if err := json.Unmarshal(data, &index); err != nil {
+			return err
+		}
+	}
+	if slices.ContainsFunc(index.Manifests, func(desc imgspecv1.Descriptor) bool { return desc.Digest == d }) {
+		return nil
+	}
+	index.Manifests = append(index.Manifests, imgspecv1.Descriptor{
+		MediaType:   mimeType,
+		Digest:      d,
+		Size:        int64(len(blob)),
+		Annotations: map[string]string{imgspecv1.AnnotationRefName: d.Encoded()},
+	})
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return ioutils.AtomicWriteFile(indexPath, data, 0o600)
+}
+
+func (s *Server) putManifest(w http.ResponseWriter, r *http.Request, name string, repositories []string, ref string) {
+	blob, err := io.ReadAll(io.LimitReader(r.Body, maxManifestSize+1))
+	if err != nil {
+		fail(w, http.StatusBadRequest, "MANIFEST_INVALID", err)
+		return
+	}
+	if len(blob) > maxManifestSize {
+		fail(w, http.StatusRequestEntityTooLarge, "SIZE_INVALID", fmt.Errorf("manifest is larger than %d bytes", maxManifestSize))
+		return
+	}
+	d := digest.FromBytes(blob)
+	tag := ref
+	if refDigest, err := digest.Parse(ref); err == nil {
+		if refDigest != d {
+			fail(w, http.StatusBadRequest, "DIGEST_INVALID", fmt.Errorf("manifest has digest %q, not %q", d, refDigest))
+			return
+		}
+		tag = ""
+	} else if _, err := reference.ParseNormalizedNamed(repositories[0] + ":" + tag); err != nil {
+		fail(w, http.StatusBadRequest, "TAG_INVALID", err)
+		return
+	}
+	mimeType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !slices.Contains(append(slices.Clone(manifest.SupportedListMIMETypes), imgspecv1.MediaTypeImageManifest, manifest.DockerV2Schema2MediaType), mimeType) {
+		mimeType = manifest.GuessMIMEType(blob)
+	}
+
+	if err := s.stageManifest(blob, mimeType); err != nil {
+		switch {
+		case errors.Is(err, errManifestInvalid):
+			fail(w, http.StatusBadRequest, "MANIFEST_INVALID", err)
+		case errors.Is(err, errManifestBlobUnknown):
+			fail(w, http.StatusBadRequest, "MANIFEST_BLOB_UNKNOWN", err)
+		default:
+			fail(w, http.StatusInternalServerError, "UNKNOWN", err)
+		}
+		return
+	}
+	if manifest.MIMETypeIsMultiImage(mimeType) {
+		err = s.commitList(r.Context(), blob, mimeType, repositories[0], tag)
+	} else {
+		err = s.commitImage(r.Context(), d, repositories[0], tag)
+	}
+	if err != nil {
+		fail(w, http.StatusInternalServerError, "UNKNOWN", err)
+		return
+	}
+	w.Header().Set("Location", "/v2/"+name+"/manifests/"+d.String())
+	w.Header().Set("Docker-Content-Digest", d.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+// commitImage copies the image with the manifest with digest d from our
+// layout to the store, naming it repository:tag, or repository@d if tag is
+// not set.
+func (s *Server) commitImage(ctx context.Context, d digest.Digest, repository, tag string) error {
+	destination := repository + "@" + d.String()
+	if tag != "" {
+		destination = repository + ":" + tag
+	}
+	srcRef, err := layout.NewReference(s.dir, d.Encoded())
+	if err != nil {
+		return err
+	}
+	destRef, err := storageTransport.Transport.ParseStoreReference(s.store, destination)
+	if err != nil {
+		return fmt.Errorf("parsing %q: %w", destination, err)
+	}
+	// everything which was pushed to us is accepted
+	policyContext, err := signature.NewPolicyContext(&signature.Policy{
+		Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()},
+	})
+	if err != nil {
+		return fmt.Errorf("creating new signature policy context: %w", err)
+	}
+	defer func() {
+		if err := policyContext.Destroy(); err != nil {
+			logrus.Debugf("registry: destroying signature policy context: %v", err)
+		}
+	}()
+	if _, err := copy.Image(ctx, policyContext, destRef, srcRef, &copy.Options{PreserveDigests: true}); err != nil {
+		return fmt.Errorf("adding %q to storage: %w", destination, err)
+	}
+	logrus.Debugf("registry: stored %s as %q", d, destination)
+	return nil
+}
+
+// instancesBigDataKey is the key under which "buildah manifest" commands
+// record the locations of the images in a manifest list.
+const instancesBigDataKey = "instances.json"
+
+// commitList records a manifest list in the store in the way that "buildah
+// manifest create" does, so that the "buildah manifest" commands can be used
+// with it, naming it repository:tag if tag is set.  The images in the list
+// are added to the store first if they aren't already there.
+func (s *Server) commitList(ctx context.Context, blob []byte, mimeType, repository, tag string) error {
+	list, err := manifest.ListFromBlob(blob, mimeType)
+	if err != nil {
+		return err
+	}
+	instances := make(map[digest.Digest]string)
+	for _, instance := range list.Instances() {
+		images, err := s.store.ImagesByDigest(instance)
+		if err != nil || len(images) == 0 {
+			if err := s.commitImage(ctx, instance, repository, ""); err != nil {
+				return err
+			}
+			if images, err = s.store.ImagesByDigest(instance); err != nil || len(images) == 0 {
+				return fmt.Errorf("locating image with digest %q after adding it: %w", instance, err)
+			}
+		}
+		ref, err := storageTransport.Transport.NewStoreReference(s.store, nil, images[0].ID)
+		if err != nil {
+			return err
+		}
+		instances[instance] = transports.ImageName(ref)
+	}
+	instancesBytes, err := json.Marshal(instances)
+	if err != nil {
+		return err
+	}
+	var names []string
+	if tag != "" {
+		names = []string{repository + ":" + tag}
+	}
+
+	d := digest.FromBytes(blob)
+	images, err := s.store.ImagesByDigest(d)
+	if err != nil && !errors.Is(err, storage.ErrImageUnknown) {
+		return err
+	}
+	for _, img := range images {
+		if existing, err := s.store.ImageBigData(img.ID, storage.ImageDigestBigDataKey); err == nil && bytes.Equal(existing, blob) {
+			return s.store.AddNames(img.ID, names)
+		}
+	}
+	// names are added afterward, so that they can be taken from any
+	// images which already have them
+	img, err := s.store.CreateImage("", nil, "", "", &storage.ImageOptions{
+		BigData: []storage.ImageBigDataOption{
+			{Key: storage.ImageDigestBigDataKey, Data: blob, Digest: d},
+			{Key: instancesBigDataKey, Data: instancesBytes},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("adding manifest list %q to storage: %w", d, err)
+	}
+	if err := s.store.AddNames(img.ID, names); err != nil {
+		return err
+	}
+	logrus.Debugf("registry: stored list %s with names %v", d, names)
+	return nil
+}
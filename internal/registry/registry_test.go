@@ -0,0 +1,311 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/copy"
+	"go.podman.io/image/v5/docker"
+	"go.podman.io/image/v5/oci/layout"
+	"go.podman.io/image/v5/pkg/compression"
+	"go.podman.io/image/v5/signature"
+	storageTransport "go.podman.io/image/v5/storage"
+	"go.podman.io/image/v5/transports/alltransports"
+	"go.podman.io/image/v5/types"
+	"go.podman.io/storage"
+	"go.podman.io/storage/pkg/reexec"
+	storagetypes "go.podman.io/storage/types"
+)
+
+func TestMain(m *testing.M) {
+	if reexec.Init() {
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// writeBlob adds a blob to an OCI layout.
+func writeBlob(t *testing.T, dir string, data []byte) digest.Digest {
+	t.Helper()
+	d := digest.FromBytes(data)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "blobs", d.Algorithm().String()), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "blobs", d.Algorithm().String(), d.Encoded()), data, 0o644))
+	return d
+}
+
+// newLayout creates an OCI layout containing one image with one layer,
+// which is gzip-compressed if compress is set, and returns a reference to
+// it.
+func newLayout(t *testing.T, contents string, compress bool) types.ImageReference {
+	t.Helper()
+	dir := t.TempDir()
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "file", Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(contents))}))
+	_, err := tw.Write([]byte(contents))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	diffID := digest.FromBytes(layer.Bytes())
+	layerMediaType := imgspecv1.MediaTypeImageLayer
+	if compress {
+		var compressed bytes.Buffer
+		wc, err := compression.CompressStream(&compressed, compression.Gzip, nil)
+		require.NoError(t, err)
+		_, err = wc.Write(layer.Bytes())
+		require.NoError(t, err)
+		require.NoError(t, wc.Close())
+		layer = compressed
+		layerMediaType = imgspecv1.MediaTypeImageLayerGzip
+	}
+	layerDigest := writeBlob(t, dir, layer.Bytes())
+
+	config, err := json.Marshal(imgspecv1.Image{
+		Platform: imgspecv1.Platform{OS: "linux", Architecture: "amd64"},
+		RootFS:   imgspecv1.RootFS{Type: "layers", DiffIDs: []digest.Digest{diffID}},
+	})
+	require.NoError(t, err)
+	configDigest := writeBlob(t, dir, config)
+	m, err := json.Marshal(imgspecv1.Manifest{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Config:    imgspecv1.Descriptor{MediaType: imgspecv1.MediaTypeImageConfig, Digest: configDigest, Size: int64(len(config))},
+		Layers:    []imgspecv1.Descriptor{{MediaType: layerMediaType, Digest: layerDigest, Size: int64(layer.Len())}},
+	})
+	require.NoError(t, err)
+	manifestDigest := writeBlob(t, dir, m)
+	index, err := json.Marshal(imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageIndex,
+		Manifests: []imgspecv1.Descriptor{{
+			MediaType:   imgspecv1.MediaTypeImageManifest,
+			Digest:      manifestDigest,
+			Size:        int64(len(m)),
+			Annotations: map[string]string{imgspecv1.AnnotationRefName: "latest"},
+		}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, imgspecv1.ImageIndexFile), index, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, imgspecv1.ImageLayoutFile), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0o644))
+	ref, err := layout.NewReference(dir, "latest")
+	require.NoError(t, err)
+	return ref
+}
+
+func newTestServer(t *testing.T, store storage.Store, readWrite bool) string {
+	t.Helper()
+	server, err := New(store, Options{ReadWrite: readWrite, UploadDirectory: t.TempDir()})
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, server.Close()) })
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return strings.TrimPrefix(httpServer.URL, "http://")
+}
+
+func copyImage(t *testing.T, dest, src types.ImageReference) {
+	t.Helper()
+	policyContext, err := signature.NewPolicyContext(&signature.Policy{
+		Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()},
+	})
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, policyContext.Destroy()) }()
+	sys := &types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		BlobInfoCacheDir:            t.TempDir(),
+	}
+	_, err = copy.Image(context.Background(), policyContext, dest, src, &copy.Options{SourceCtx: sys, DestinationCtx: sys})
+	require.NoError(t, err)
+}
+
+func get(t *testing.T, method, url string, body io.Reader) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, body)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(data)
+}
+
+func TestPushAndPull(t *testing.T) {
+	store, err := storage.GetStore(storagetypes.StoreOptions{
+		RunRoot:         t.TempDir(),
+		GraphRoot:       t.TempDir(),
+		GraphDriverName: "vfs",
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _, err := store.Shutdown(true); assert.NoError(t, err) })
+	host := newTestServer(t, store, true)
+
+	// pull images which were pushed to us, and images which were added to
+	// the store some other way
+	for _, pushed := range []bool{false, true} {
+		name := "stored"
+		if pushed {
+			name = "pushed"
+		}
+		t.Run(name, func(t *testing.T) {
+			pushRef, err := docker.ParseReference("//" + host + "/test/" + name + ":latest")
+			require.NoError(t, err)
+			if pushed {
+				copyImage(t, pushRef, newLayout(t, name, true))
+			} else {
+				storageRef, err := storageTransport.Transport.ParseStoreReference(store, "localhost/test/"+name+":latest")
+				require.NoError(t, err)
+				copyImage(t, storageRef, newLayout(t, name, false))
+			}
+			img, err := store.Image("localhost/test/" + name + ":latest")
+			require.NoError(t, err)
+
+			pullDir := t.TempDir()
+			pullRef, err := alltransports.ParseImageName("dir:" + pullDir)
+			require.NoError(t, err)
+			copyImage(t, pullRef, pushRef)
+			m, err := os.ReadFile(filepath.Join(pullDir, "manifest.json"))
+			require.NoError(t, err)
+			var parsed imgspecv1.Manifest
+			require.NoError(t, json.Unmarshal(m, &parsed))
+			require.Len(t, parsed.Layers, 1)
+			// layers are always served uncompressed
+			assert.Equal(t, imgspecv1.MediaTypeImageLayer, parsed.Layers[0].MediaType)
+			layers, err := store.LayersByUncompressedDigest(parsed.Layers[0].Digest)
+			require.NoError(t, err)
+			require.Len(t, layers, 1)
+			assert.Equal(t, img.TopLayer, layers[0].ID)
+
+			// the manifest which we served can be found by digest
+			resp, body := get(t, http.MethodGet, "http://"+host+"/v2/test/"+name+"/manifests/"+digest.FromBytes(m).String(), nil)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, string(m), body)
+			// only the manifest which refers to compressed layers
+			// needed to be changed
+			storedManifest, err := store.ImageBigData(img.ID, storage.ImageDigestBigDataKey)
+			require.NoError(t, err)
+			assert.Equal(t, pushed, !bytes.Equal(storedManifest, m))
+		})
+	}
+
+	resp, body := get(t, http.MethodGet, "http://"+host+"/v2/test/pushed/tags/list", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"name": "test/pushed", "tags": ["latest"]}`, body)
+	resp, body = get(t, http.MethodGet, "http://"+host+"/v2/_catalog?n=1", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"repositories": ["localhost/test/pushed"]}`, body)
+	resp, body = get(t, http.MethodGet, "http://"+host+"/v2/_catalog?last=localhost/test/pushed", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"repositories": ["localhost/test/stored"]}`, body)
+
+	resp, body = get(t, http.MethodGet, "http://"+host+"/v2/localhost/test/stored/manifests/latest", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, digest.FromString(body).String(), resp.Header.Get("Docker-Content-Digest"))
+	resp, body = get(t, http.MethodHead, "http://"+host+"/v2/test/stored/manifests/nope", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Empty(t, body)
+	resp, body = get(t, http.MethodGet, "http://"+host+"/v2/test/stored/manifests/nope", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, body, "MANIFEST_UNKNOWN")
+	resp, body = get(t, http.MethodGet, "http://"+host+"/v2/test/stored/blobs/"+digest.FromString("nope").String(), nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, body, "BLOB_UNKNOWN")
+	resp, body = get(t, http.MethodGet, "http://"+host+"/v2/Invalid/manifests/latest", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "NAME_INVALID")
+
+	// uploads whose contents don't match their digests are rejected
+	resp, _ = get(t, http.MethodPost, "http://"+host+"/v2/test/blobs/uploads/", nil)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	location := resp.Header.Get("Location")
+	resp, _ = get(t, http.MethodPatch, "http://"+host+location, strings.NewReader("some"))
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "0-3", resp.Header.Get("Range"))
+	resp, body = get(t, http.MethodPut, "http://"+host+location+"?digest="+digest.FromString("something else").String(), strings.NewReader("thing"))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "DIGEST_INVALID")
+
+	// a manifest which refers to blobs which were never pushed is rejected
+	resp, body = get(t, http.MethodPut, "http://"+host+"/v2/test/manifests/broken", strings.NewReader(`{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.manifest.v1+json", "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "digest": "`+digest.FromString("nope").String()+`", "size": 4}, "layers": []}`))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "MANIFEST_BLOB_UNKNOWN")
+
+	// a server which isn't read-write refuses pushes, but serves the
+	// same images
+	readOnlyHost := newTestServer(t, store, false)
+	resp, body = get(t, http.MethodPost, "http://"+readOnlyHost+"/v2/test/blobs/uploads/", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Contains(t, body, "UNSUPPORTED")
+	resp, _ = get(t, http.MethodHead, "http://"+readOnlyHost+"/v2/test/stored/manifests/latest", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestRepositories(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		expected []string
+	}{
+		{"app", []string{"localhost/app", "docker.io/library/app"}},
+		{"team/app", []string{"localhost/team/app", "docker.io/team/app"}},
+		{"localhost/app", []string{"localhost/app"}},
+		{"quay.io/team/app", []string{"quay.io/team/app"}},
+		{"registry:5000/app", []string{"registry:5000/app"}},
+	} {
+		repositories, err := repositories(tc.name)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expected, repositories, tc.name)
+	}
+	for _, name := range []string{"App", "app:latest", "app@" + digest.FromString("").String(), ""} {
+		_, err := repositories(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestGeneratedManifests(t *testing.T) {
+	s := &Server{generated: make(map[digest.Digest][]byte), maxGenerated: 20}
+	first, second, third := []byte("first manifest"), []byte("second manifest"), []byte("third manifest")
+	s.rememberGenerated(first)
+	s.rememberGenerated(second)
+	// the oldest one was forgotten to make room
+	_, ok := s.lookupGenerated(digest.FromBytes(first))
+	assert.False(t, ok)
+	blob, ok := s.lookupGenerated(digest.FromBytes(second))
+	assert.True(t, ok)
+	assert.Equal(t, second, blob)
+	// one which is larger than the limit is still kept, by itself
+	large := bytes.Repeat([]byte("large"), 10)
+	s.rememberGenerated(large)
+	_, ok = s.lookupGenerated(digest.FromBytes(second))
+	assert.False(t, ok)
+	_, ok = s.lookupGenerated(digest.FromBytes(large))
+	assert.True(t, ok)
+	assert.Equal(t, len(large), s.generatedSize)
+
+	// looking one up makes it the most recently used
+	s.maxGenerated = 30
+	s.rememberGenerated(first)
+	s.rememberGenerated(second)
+	_, ok = s.lookupGenerated(digest.FromBytes(first))
+	assert.True(t, ok)
+	s.rememberGenerated(third)
+	_, ok = s.lookupGenerated(digest.FromBytes(second))
+	assert.False(t, ok)
+	_, ok = s.lookupGenerated(digest.FromBytes(first))
+	assert.True(t, ok)
+	_, ok = s.lookupGenerated(digest.FromBytes(third))
+	assert.True(t, ok)
+	assert.Equal(t, len(first)+len(third), s.generatedSize)
+	assert.Len(t, s.generatedOrder, len(s.generated))
+}
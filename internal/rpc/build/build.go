@@ -0,0 +1,636 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"go.podman.io/buildah"
+	"go.podman.io/buildah/define"
+	"go.podman.io/buildah/imagebuildah"
+	"go.podman.io/buildah/internal/rpc/build/pb"
+	"go.podman.io/buildah/internal/tmpdir"
+	"go.podman.io/buildah/pkg/parse"
+	"go.podman.io/buildah/pkg/util"
+	"go.podman.io/image/v5/manifest"
+	"go.podman.io/image/v5/transports"
+	"go.podman.io/image/v5/transports/alltransports"
+	"go.podman.io/image/v5/types"
+	"go.podman.io/storage"
+	"go.podman.io/storage/pkg/archive"
+	"go.podman.io/storage/pkg/chrootarchive"
+	"go.podman.io/storage/pkg/stringid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Options controls how a Service handles requests.
+type Options struct {
+	// SystemContext is used when pulling, pushing, and inspecting images.
+	SystemContext *types.SystemContext
+	// ContextDirectory is where uploaded build contexts are kept.  Build
+	// requests can also name directories under it to use as build
+	// contexts, but not directories anywhere else.  If not set, a temporary
+	// directory is created.
+	ContextDirectory string
+	// PushDirectory is where images can be written when they are pushed
+	// using the "dir", "oci", "oci-archive", or "docker-archive"
+	// transports, using paths relative to it.  If not set, images can
+	// only be pushed to registries.
+	PushDirectory string
+	// UploadedContextExpiry is how long an uploaded build context is kept
+	// if no build uses it.  If not set, DefaultUploadedContextExpiry is
+	// used.
+	UploadedContextExpiry time.Duration
+}
+
+// DefaultUploadedContextExpiry is how long an uploaded build context is kept
+// if no build uses it, unless Options.UploadedContextExpiry is set.
+const DefaultUploadedContextExpiry = 10 * time.Minute
+
+// uploadedContext is a build context which was uploaded and hasn't been used
+// by a build yet.
+type uploadedContext struct {
+	dir    string
+	expiry *time.Timer // removes the context if no build uses it in time
+}
+
+// Service implements the Build service.
+type Service struct {
+	pb.UnimplementedBuildServer
+	store         storage.Store
+	systemContext *types.SystemContext
+	contextDir    string
+	removeDir     bool
+	pushDir       string
+	uploadExpiry  time.Duration
+	lock          sync.Mutex
+	cancels       map[string]context.CancelFunc
+	contexts      map[string]*uploadedContext
+	sessions      map[string]*session
+}
+
+// Register adds a Build service which uses store to s.  The returned Service
+// should be closed when it is no longer needed.
+func Register(s grpc.ServiceRegistrar, store storage.Store, options Options) (*Service, error) {
+	service := &Service{
+		store:         store,
+		systemContext: options.SystemContext,
+		contextDir:    options.ContextDirectory,
+		pushDir:       options.PushDirectory,
+		uploadExpiry:  options.UploadedContextExpiry,
+		cancels:       make(map[string]context.CancelFunc),
+		contexts:      make(map[string]*uploadedContext),
+		sessions:      make(map[string]*session),
+	}
+	if service.systemContext == nil {
+		service.systemContext = &types.SystemContext{}
+	}
+	if service.uploadExpiry <= 0 {
+		service.uploadExpiry = DefaultUploadedContextExpiry
+	}
+	if service.contextDir == "" {
+		dir, err := os.MkdirTemp(tmpdir.GetTempDir(), "buildah-contexts")
+		if err != nil {
+			return nil, err
+		}
+		service.contextDir = dir
+		service.removeDir = true
+	} else if err := os.MkdirAll(service.contextDir, 0o700); err != nil {
+		return nil, err
+	}
+	if service.pushDir != "" {
+		if err := os.MkdirAll(service.pushDir, 0o700); err != nil {
+			return nil, err
+		}
+	}
+	pb.RegisterBuildServer(s, service)
+	return service, nil
+}
+
+// Close cancels any builds or pushes which are in progress, and removes any
+// uploaded build contexts, including those kept for sessions.
+func (b *Service) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, cancel := range b.cancels {
+		cancel()
+	}
+	var errs []error
+	for id, uploaded := range b.contexts {
+		uploaded.expiry.Stop()
+		if err := os.RemoveAll(uploaded.dir); err != nil {
+			errs = append(errs, err)
+		}
+		delete(b.contexts, id)
+	}
+	for id, s := range b.sessions {
+		if err := os.RemoveAll(s.dir); err != nil {
+			errs = append(errs, err)
+		}
+		delete(b.sessions, id)
+	}
+	if b.removeDir {
+		if err := os.RemoveAll(b.contextDir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// eventSender serializes events sent on a stream, which can't be written to
+// by more than one goroutine at a time.
+type eventSender struct {
+	lock sync.Mutex
+	id   string
+	send func(*pb.Event) error
+}
+
+func (e *eventSender) sendEvent(event *pb.Event) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	event.Id = e.id
+	return e.send(event)
+}
+
+func (e *eventSender) started() error {
+	return e.sendEvent(&pb.Event{Event: &pb.Event_Started{Started: &pb.Started{}}})
+}
+
+func (e *eventSender) finished(finished *pb.Finished) error {
+	return e.sendEvent(&pb.Event{Event: &pb.Event_Finished{Finished: finished}})
+}
+
+// writer returns an io.Writer which sends what's written to it as Log events
+// for the named stream.
+func (e *eventSender) writer(stream string) io.Writer {
+	return &logWriter{sender: e, stream: stream}
+}
+
+type logWriter struct {
+	sender *eventSender
+	stream string
+}
+
+func (l *logWriter) Write(p []byte) (int, error) {
+	if err := l.sender.sendEvent(&pb.Event{Event: &pb.Event_Log{Log: &pb.Log{Stream: l.stream, Text: string(p)}}}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// start records a new build or push, and returns its ID and a context which
+// is canceled when it's canceled.
+func (b *Service) start(ctx context.Context) (string, context.Context, func()) {
+	id := stringid.GenerateRandomID()
+	ctx, cancel := context.WithCancel(ctx)
+	b.lock.Lock()
+	b.cancels[id] = cancel
+	b.lock.Unlock()
+	return id, ctx, func() {
+		b.lock.Lock()
+		delete(b.cancels, id)
+		b.lock.Unlock()
+		cancel()
+	}
+}
+
+// UploadContext extracts an uploaded archive for use as a build context, or
+// into the copy of a build context which is kept for a session.
+func (b *Service) UploadContext(stream grpc.ClientStreamingServer[pb.UploadContextRequest, pb.UploadContextResponse]) error {
+	first, err := stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return status.Error(codes.InvalidArgument, "no build context")
+		}
+		return err
+	}
+	var id, dir string
+	var sess *session
+	if first.Session != "" {
+		if sess, err = b.getSession(first.Session); err != nil {
+			return err
+		}
+		defer sess.lock.Unlock()
+		dir = sess.dir
+	} else {
+		id = stringid.GenerateRandomID()
+		dir = filepath.Join(b.contextDir, id)
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			return err
+		}
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		req := first
+		for {
+			if _, err := writer.Write(req.Data); err != nil {
+				return
+			}
+			var err error
+			if req, err = stream.Recv(); err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				writer.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	var contents io.Reader = reader
+	if sess != nil {
+		// only accept the items which the last sync asked for
+		filtered := filterArchive(reader, sess.checkUpload)
+		defer filtered.Close()
+		contents = filtered
+	}
+	// extract in a chroot, so that items in the archive can't be
+	// written through symbolic links which it also contains
+	err = chrootarchive.Untar(contents, dir, &archive.TarOptions{NoLchown: true})
+	reader.CloseWithError(err)
+	if sess != nil {
+		if err == nil {
+			err = sess.finish()
+		}
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "updating build context for session %q: %v", first.Session, err)
+		}
+		return stream.SendAndClose(&pb.UploadContextResponse{})
+	}
+	if err != nil {
+		if err2 := os.RemoveAll(dir); err2 != nil {
+			logrus.Errorf("removing %s: %v", dir, err2)
+		}
+		return status.Errorf(codes.InvalidArgument, "extracting build context: %v", err)
+	}
+	b.lock.Lock()
+	b.contexts[id] = &uploadedContext{dir: dir, expiry: time.AfterFunc(b.uploadExpiry, func() { b.expireContext(id) })}
+	b.lock.Unlock()
+	return stream.SendAndClose(&pb.UploadContextResponse{ContextId: id})
+}
+
+// expireContext removes an uploaded build context if no build has started
+// using it.
+func (b *Service) expireContext(id string) {
+	b.lock.Lock()
+	uploaded, ok := b.contexts[id]
+	delete(b.contexts, id)
+	b.lock.Unlock()
+	if !ok {
+		return
+	}
+	logrus.Debugf("removing unused uploaded build context %s", id)
+	if err := os.RemoveAll(uploaded.dir); err != nil {
+		logrus.Errorf("removing %s: %v", uploaded.dir, err)
+	}
+}
+
+// buildOptions converts a request into build options and a list of
+// Containerfiles.
+func (b *Service) buildOptions(req *pb.BuildRequest) (define.BuildOptions, []string, error) {
+	options := define.BuildOptions{
+		ContextDirectory:    req.ContextDir,
+		Args:                req.BuildArgs,
+		Target:              req.Target,
+		Labels:              req.Labels,
+		Layers:              req.Layers,
+		NoCache:             req.NoCache,
+		CommonBuildOpts:     &define.CommonBuildOptions{},
+		SystemContext:       b.systemContext,
+		SignaturePolicyPath: b.systemContext.SignaturePolicyPath,
+		OutputFormat:        define.OCIv1ImageManifest,
+	}
+	if options.ContextDirectory == "" {
+		return options, nil, status.Error(codes.InvalidArgument, "neither a context directory nor an uploaded context was specified")
+	}
+	if len(req.Tags) > 0 {
+		options.Output = req.Tags[0]
+		options.AdditionalTags = req.Tags[1:]
+	}
+	if req.Pull != "" {
+		pullPolicy, ok := define.PolicyMap[strings.ToLower(req.Pull)]
+		if !ok {
+			return options, nil, status.Errorf(codes.InvalidArgument, "unrecognized pull policy %q", req.Pull)
+		}
+		options.PullPolicy = pullPolicy
+	}
+	isolation, err := parse.IsolationOption(req.Isolation)
+	if err != nil {
+		return options, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	options.Isolation = isolation
+	switch req.Format {
+	case "", define.OCI:
+	case define.DOCKER:
+		options.OutputFormat = define.Dockerv2ImageManifest
+	default:
+		return options, nil, status.Errorf(codes.InvalidArgument, "unrecognized image format %q", req.Format)
+	}
+	// Containerfiles have to be in the build context, and symbolic links
+	// are resolved as if the build context was the root directory, so that
+	// a request can't get us to read some other file
+	var containerfiles []string
+	for _, containerfile := range req.Containerfiles {
+		if !filepath.IsLocal(containerfile) {
+			return options, nil, status.Errorf(codes.InvalidArgument, "Containerfile %q is not a relative path in the build context", containerfile)
+		}
+		containerfiles = append(containerfiles, containerfile)
+	}
+	if len(containerfiles) == 0 {
+		containerfile, err := util.DiscoverContainerfile(options.ContextDirectory)
+		if err != nil {
+			return options, nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if containerfile, err = filepath.Rel(options.ContextDirectory, containerfile); err != nil {
+			return options, nil, err
+		}
+		containerfiles = append(containerfiles, containerfile)
+	}
+	for i, containerfile := range containerfiles {
+		resolved, err := securejoin.SecureJoin(options.ContextDirectory, containerfile)
+		if err != nil {
+			return options, nil, status.Errorf(codes.InvalidArgument, "resolving Containerfile %q: %v", containerfile, err)
+		}
+		containerfiles[i] = resolved
+	}
+	return options, containerfiles, nil
+}
+
+// contextDirectory resolves a directory which a request named as its build
+// context, which has to be under the service's context directory.
+func (b *Service) contextDirectory(dir string) (string, error) {
+	if !filepath.IsLocal(dir) || filepath.Clean(dir) == "." {
+		return "", status.Errorf(codes.InvalidArgument, "context directory %q is not a relative path under the service's context directory", dir)
+	}
+	resolved, err := securejoin.SecureJoin(b.contextDir, dir)
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "resolving context directory %q: %v", dir, err)
+	}
+	if st, err := os.Stat(resolved); err != nil || !st.IsDir() {
+		return "", status.Errorf(codes.NotFound, "no context directory %q", dir)
+	}
+	return resolved, nil
+}
+
+// Build builds an image.
+func (b *Service) Build(req *pb.BuildRequest, stream grpc.ServerStreamingServer[pb.Event]) error {
+	if req == nil {
+		return status.Error(codes.InvalidArgument, "no build request")
+	}
+	switch {
+	case req.Session != "":
+		dir, unlock, err := b.sessionDir(req.Session)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		req.ContextDir = dir
+	case req.ContextId != "":
+		b.lock.Lock()
+		uploaded, ok := b.contexts[req.ContextId]
+		delete(b.contexts, req.ContextId)
+		b.lock.Unlock()
+		if !ok {
+			return status.Errorf(codes.NotFound, "no uploaded context with ID %q", req.ContextId)
+		}
+		uploaded.expiry.Stop()
+		dir := uploaded.dir
+		defer func() {
+			if err := os.RemoveAll(dir); err != nil {
+				logrus.Errorf("removing %s: %v", dir, err)
+			}
+		}()
+		req.ContextDir = dir
+	case req.ContextDir != "":
+		dir, err := b.contextDirectory(req.ContextDir)
+		if err != nil {
+			return err
+		}
+		req.ContextDir = dir
+	}
+	options, containerfiles, err := b.buildOptions(req)
+	if err != nil {
+		return err
+	}
+
+	id, ctx, done := b.start(stream.Context())
+	defer done()
+	sender := &eventSender{id: id, send: stream.Send}
+	options.Out = sender.writer("stdout")
+	options.Err = sender.writer("stderr")
+	options.ReportWriter = sender.writer("progress")
+	if err := sender.started(); err != nil {
+		return err
+	}
+	imageID, ref, err := imagebuildah.BuildDockerfiles(ctx, b.store, options, containerfiles...)
+	finished := &pb.Finished{ImageId: imageID}
+	if ref != nil {
+		finished.Reference = ref.String()
+		finished.Digest = ref.Digest().String()
+	}
+	if err != nil {
+		finished.Error = err.Error()
+	}
+	return sender.finished(finished)
+}
+
+// Cancel cancels a build or push.
+func (b *Service) Cancel(_ context.Context, req *pb.CancelRequest) (*pb.CancelResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "no cancel request")
+	}
+	b.lock.Lock()
+	cancel, ok := b.cancels[req.Id]
+	b.lock.Unlock()
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no build or push with ID %q", req.Id)
+	}
+	cancel()
+	return &pb.CancelResponse{}, nil
+}
+
+// ListImages lists images.
+func (b *Service) ListImages(_ context.Context, _ *pb.ListImagesRequest) (*pb.ListImagesResponse, error) {
+	images, err := b.store.Images()
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListImagesResponse{}
+	for _, image := range images {
+		resp.Images = append(resp.Images, &pb.Image{
+			Id:      image.ID,
+			Names:   image.Names,
+			Digest:  image.Digest.String(),
+			Created: image.Created.Unix(),
+		})
+	}
+	return resp, nil
+}
+
+func inspectResponse(builder *buildah.Builder) (*pb.InspectResponse, error) {
+	encoded, err := json.MarshalIndent(buildah.GetBuildInfo(builder), "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return &pb.InspectResponse{Json: encoded}, nil
+}
+
+// InspectImage returns information about an image.
+func (b *Service) InspectImage(ctx context.Context, req *pb.InspectRequest) (*pb.InspectResponse, error) {
+	if req == nil || req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no image name")
+	}
+	builder, err := buildah.ImportBuilderFromImage(ctx, b.store, buildah.ImportFromImageOptions{
+		Image:         req.Name,
+		SystemContext: b.systemContext,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrImageUnknown) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, err
+	}
+	return inspectResponse(builder)
+}
+
+// ListContainers lists working containers.
+func (b *Service) ListContainers(_ context.Context, _ *pb.ListContainersRequest) (*pb.ListContainersResponse, error) {
+	builders, err := buildah.OpenAllBuilders(b.store)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListContainersResponse{}
+	for _, builder := range builders {
+		resp.Containers = append(resp.Containers, &pb.Container{
+			Id:        builder.ContainerID,
+			Name:      builder.Container,
+			ImageId:   builder.FromImageID,
+			ImageName: builder.FromImage,
+		})
+	}
+	return resp, nil
+}
+
+// InspectContainer returns information about a working container.
+func (b *Service) InspectContainer(ctx context.Context, req *pb.InspectRequest) (*pb.InspectResponse, error) {
+	if req == nil || req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no container name")
+	}
+	builder, err := buildah.OpenBuilder(b.store, req.Name)
+	if errors.Is(err, os.ErrNotExist) {
+		builder, err = buildah.ImportBuilder(ctx, b.store, buildah.ImportOptions{Container: req.Name})
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrContainerUnknown) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, err
+	}
+	return inspectResponse(builder)
+}
+
+// parseDestination parses a push destination, assuming the "docker"
+// transport if none is specified.  Destinations which would be written
+// locally have to be under the service's push directory.
+func (b *Service) parseDestination(destSpec string) (types.ImageReference, error) {
+	transport, within, ok := strings.Cut(destSpec, ":")
+	if !ok || transports.Get(transport) == nil {
+		return alltransports.ParseImageName("docker://" + destSpec)
+	}
+	switch transport {
+	case "docker":
+		return alltransports.ParseImageName(destSpec)
+	case "dir", "oci", "oci-archive", "docker-archive":
+		if b.pushDir == "" {
+			return nil, fmt.Errorf("the service was not started with a directory for %q destinations", transport)
+		}
+		// everything after the path is a reference or index
+		path, rest, hasRest := strings.Cut(within, ":")
+		if transport == "dir" {
+			path, hasRest = within, false
+		}
+		if !filepath.IsLocal(path) {
+			return nil, fmt.Errorf("%q is not a relative path under the service's push directory", path)
+		}
+		resolved, err := securejoin.SecureJoin(b.pushDir, path)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %q: %w", path, err)
+		}
+		if hasRest {
+			resolved += ":" + rest
+		}
+		return alltransports.ParseImageName(transport + ":" + resolved)
+	}
+	return nil, fmt.Errorf("images can not be pushed using the %q transport", transport)
+}
+
+// Push pushes an image.
+func (b *Service) Push(req *pb.PushRequest, stream grpc.ServerStreamingServer[pb.Event]) error {
+	if req == nil || req.Image == "" {
+		return status.Error(codes.InvalidArgument, "no image name")
+	}
+	destSpec := req.Destination
+	if destSpec == "" {
+		destSpec = req.Image
+	}
+	dest, err := b.parseDestination(destSpec)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	var manifestType string
+	switch req.Format {
+	case "":
+	case define.OCI:
+		manifestType = imgspecv1.MediaTypeImageManifest
+	case define.DOCKER, "v2s2":
+		manifestType = manifest.DockerV2Schema2MediaType
+	default:
+		return status.Errorf(codes.InvalidArgument, "unrecognized image format %q", req.Format)
+	}
+	if req.Authfile != "" {
+		// the file would be read by us, not by the client
+		return status.Error(codes.InvalidArgument, "the service's credentials are always used, an authentication file can not be specified")
+	}
+	systemContext := *b.systemContext
+	if req.SkipTlsVerify {
+		systemContext.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
+		systemContext.OCIInsecureSkipTLSVerify = true
+		systemContext.DockerDaemonInsecureSkipTLSVerify = true
+	}
+
+	id, ctx, done := b.start(stream.Context())
+	defer done()
+	sender := &eventSender{id: id, send: stream.Send}
+	if err := sender.started(); err != nil {
+		return err
+	}
+	ref, digest, err := buildah.Push(ctx, req.Image, dest, buildah.PushOptions{
+		Compression:         define.Gzip,
+		ManifestType:        manifestType,
+		SignaturePolicyPath: systemContext.SignaturePolicyPath,
+		Store:               b.store,
+		SystemContext:       &systemContext,
+		ReportWriter:        sender.writer("progress"),
+	})
+	finished := &pb.Finished{}
+	if ref != nil {
+		finished.Reference = ref.String()
+	}
+	if err != nil {
+		finished.Error = fmt.Sprintf("pushing image %q to %q: %v", req.Image, destSpec, err)
+	} else {
+		finished.Digest = digest.String()
+	}
+	return sender.finished(finished)
+}
@@ -0,0 +1,386 @@
+package build
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/buildah"
+	"go.podman.io/buildah/internal/rpc/build/pb"
+	"go.podman.io/buildah/internal/rpc/listen"
+	"go.podman.io/image/v5/types"
+	"go.podman.io/storage"
+	storagetypes "go.podman.io/storage/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	if buildah.InitReexec() {
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// finish reads events until a Finished event arrives.
+func finish(t *testing.T, stream grpc.ServerStreamingClient[pb.Event]) (string, *pb.Finished) {
+	t.Helper()
+	var id, logs string
+	for {
+		event, err := stream.Recv()
+		require.NoError(t, err, "reading events")
+		if id == "" {
+			require.NotNil(t, event.GetStarted(), "first event should be Started")
+			id = event.Id
+		}
+		assert.Equal(t, id, event.Id)
+		if log := event.GetLog(); log != nil {
+			logs += log.Text
+		}
+		if finished := event.GetFinished(); finished != nil {
+			_, err := stream.Recv()
+			assert.ErrorIs(t, err, io.EOF)
+			return logs, finished
+		}
+	}
+}
+
+// newTestService starts a Build service using a new store, and returns the
+// store, the service, and a client for it.
+func newTestService(t *testing.T) (storage.Store, *Service, pb.BuildClient) {
+	t.Helper()
+	store, err := storage.GetStore(storagetypes.StoreOptions{
+		RunRoot:         t.TempDir(),
+		GraphRoot:       t.TempDir(),
+		GraphDriverName: "vfs",
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _, err := store.Shutdown(true); assert.NoError(t, err) })
+
+	socketPath := filepath.Join(t.TempDir(), "socket")
+	listener, cleanup, err := listen.Listen(socketPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, cleanup()) })
+	s := grpc.NewServer()
+	service, err := Register(s, store, Options{
+		SystemContext:    &types.SystemContext{SignaturePolicyPath: "../../../tests/policy.json"},
+		ContextDirectory: t.TempDir(),
+		PushDirectory:    t.TempDir(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, service.Close()) })
+	go func() { assert.NoError(t, s.Serve(listener)) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, conn.Close()) })
+	return store, service, pb.NewBuildClient(conn)
+}
+
+func TestBuildService(t *testing.T) {
+	// This test cannot be parallelized, since it creates working
+	// containers, and NewBuilder() isn't safe to call concurrently.
+	ctx := context.Background()
+	store, service, client := newTestService(t)
+
+	// upload a build context in more than one chunk
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for name, contents := range map[string]string{
+		"Containerfile": "FROM scratch\nARG GREETING\nLABEL greeting=$GREETING\nCOPY hello /hello\n",
+		"hello":         "hello, world\n",
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(contents)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	upload, err := client.UploadContext(ctx)
+	require.NoError(t, err)
+	data := archive.Bytes()
+	for len(data) > 0 {
+		chunk := data[:min(len(data), 1024)]
+		require.NoError(t, upload.Send(&pb.UploadContextRequest{Data: chunk}))
+		data = data[len(chunk):]
+	}
+	uploaded, err := upload.CloseAndRecv()
+	require.NoError(t, err)
+	require.NotEmpty(t, uploaded.ContextId)
+
+	// garbage isn't a valid context
+	upload, err = client.UploadContext(ctx)
+	require.NoError(t, err)
+	require.NoError(t, upload.Send(&pb.UploadContextRequest{Data: []byte("this is not an archive")}))
+	_, err = upload.CloseAndRecv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// a directory under the service's context directory can be used as
+	// a build context, but nothing outside of it can be
+	writeContext(t, filepath.Join(service.contextDir, "project"), map[string]string{
+		"Containerfile": "FROM scratch\nCOPY hello /hello\n",
+		"hello":         "hello, world\n",
+	})
+	outside := filepath.Join(t.TempDir(), "Containerfile")
+	require.NoError(t, os.WriteFile(outside, []byte("FROM scratch\n"), 0o644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(service.contextDir, "project", "Outside")))
+
+	// bad requests are rejected before the build starts
+	for _, req := range []*pb.BuildRequest{
+		{},
+		{ContextId: "nonexistent"},
+		{ContextDir: t.TempDir()},
+		{ContextDir: filepath.Join(service.contextDir, "project")},
+		{ContextDir: "../project"},
+		{ContextDir: "."},
+		{ContextDir: "nonexistent"},
+		{ContextDir: "project", Containerfiles: []string{outside}},
+		{ContextDir: "project", Containerfiles: []string{"../../Containerfile"}},
+		{ContextDir: "project", Pull: "sometimes"},
+		{ContextDir: "project", Format: "zip"},
+	} {
+		stream, err := client.Build(ctx, req)
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.NotEqualf(t, codes.OK, status.Code(err), "build request %v", req)
+	}
+
+	// build from the uploaded context
+	stream, err := client.Build(ctx, &pb.BuildRequest{
+		ContextId: uploaded.ContextId,
+		Tags:      []string{"localhost/hello", "localhost/hello:again"},
+		BuildArgs: map[string]string{"GREETING": "hi"},
+		Format:    "docker",
+	})
+	require.NoError(t, err)
+	logs, finished := finish(t, stream)
+	require.Empty(t, finished.Error)
+	require.NotEmpty(t, finished.ImageId)
+	assert.Contains(t, finished.Reference, "localhost/hello")
+	assert.Contains(t, logs, "COPY hello /hello")
+
+	// the uploaded context can only be used once
+	stream, err = client.Build(ctx, &pb.BuildRequest{ContextId: uploaded.ContextId})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// symbolic links are resolved in the build context, so this one points
+	// to a file which doesn't exist
+	stream, err = client.Build(ctx, &pb.BuildRequest{ContextDir: "project", Containerfiles: []string{"Outside"}})
+	require.NoError(t, err)
+	_, built := finish(t, stream)
+	assert.NotEmpty(t, built.Error)
+	stream, err = client.Build(ctx, &pb.BuildRequest{ContextDir: "project", Tags: []string{"localhost/project"}})
+	require.NoError(t, err)
+	_, built = finish(t, stream)
+	require.Empty(t, built.Error)
+	_, err = store.DeleteImage(built.ImageId, true)
+	require.NoError(t, err)
+
+	// a context can also be uploaded from a directory
+	contextDir := t.TempDir()
+	writeContext(t, contextDir, map[string]string{
+		"Containerfile":    "FROM scratch\nCOPY . /\n",
+		".containerignore": "*.log\n",
+		"sub/file":         "contents\n",
+		"debug.log":        "noise\n",
+	})
+	contextID, err := Upload(ctx, client, contextDir, nil, "")
+	require.NoError(t, err)
+	service.lock.Lock()
+	uploadedDir := service.contexts[contextID].dir
+	service.lock.Unlock()
+	expected, err := Manifest(contextDir, nil, "")
+	require.NoError(t, err)
+	actual, err := Manifest(uploadedDir, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+	_, err = os.Stat(filepath.Join(uploadedDir, "debug.log"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	images, err := client.ListImages(ctx, &pb.ListImagesRequest{})
+	require.NoError(t, err)
+	require.Len(t, images.Images, 1)
+	assert.Equal(t, finished.ImageId, images.Images[0].Id)
+	assert.ElementsMatch(t, []string{"localhost/hello:latest", "localhost/hello:again"}, images.Images[0].Names)
+
+	inspected, err := client.InspectImage(ctx, &pb.InspectRequest{Name: "localhost/hello:again"})
+	require.NoError(t, err)
+	var info buildah.BuilderInfo
+	require.NoError(t, json.Unmarshal(inspected.Json, &info))
+	assert.Equal(t, finished.ImageId, info.FromImageID)
+	assert.Equal(t, "hi", info.Docker.Config.Labels["greeting"])
+	_, err = client.InspectImage(ctx, &pb.InspectRequest{Name: "localhost/nonexistent"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// working containers
+	containers, err := client.ListContainers(ctx, &pb.ListContainersRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, containers.Containers)
+	builder, err := buildah.NewBuilder(ctx, store, buildah.BuilderOptions{FromImage: finished.ImageId, Container: "working"})
+	require.NoError(t, err)
+	containers, err = client.ListContainers(ctx, &pb.ListContainersRequest{})
+	require.NoError(t, err)
+	require.Len(t, containers.Containers, 1)
+	assert.Equal(t, builder.ContainerID, containers.Containers[0].Id)
+	assert.Equal(t, "working", containers.Containers[0].Name)
+	assert.Equal(t, finished.ImageId, containers.Containers[0].ImageId)
+	inspected, err = client.InspectContainer(ctx, &pb.InspectRequest{Name: "working"})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(inspected.Json, &info))
+	assert.Equal(t, builder.ContainerID, info.ContainerID)
+	_, err = client.InspectContainer(ctx, &pb.InspectRequest{Name: "nonexistent"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// push to a directory under the push directory
+	push, err := client.Push(ctx, &pb.PushRequest{Image: "localhost/hello", Destination: "dir:hello"})
+	require.NoError(t, err)
+	_, finished = finish(t, push)
+	require.Empty(t, finished.Error)
+	assert.NotEmpty(t, finished.Digest)
+	assert.FileExists(t, filepath.Join(service.pushDir, "hello", "manifest.json"))
+	push, err = client.Push(ctx, &pb.PushRequest{Image: "localhost/hello", Destination: "dir:hello", Authfile: "/etc/shadow"})
+	require.NoError(t, err)
+	_, err = push.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	push, err = client.Push(ctx, &pb.PushRequest{Image: "localhost/nonexistent", Destination: "dir:nonexistent"})
+	require.NoError(t, err)
+	_, finished = finish(t, push)
+	assert.NotEmpty(t, finished.Error)
+
+	_, err = client.Cancel(ctx, &pb.CancelRequest{Id: "nonexistent"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestUploadContextSymlinkEscape(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, _, client := newTestService(t)
+
+	// an archive which tries to write through a symbolic link which it
+	// also contains
+	outside := t.TempDir()
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: outside}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "link/file", Mode: 0o644, Size: 5, Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte("oops\n"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	upload, err := client.UploadContext(ctx)
+	require.NoError(t, err)
+	require.NoError(t, upload.Send(&pb.UploadContextRequest{Data: archive.Bytes()}))
+	_, err = upload.CloseAndRecv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.NoFileExists(t, filepath.Join(outside, "file"))
+}
+
+func TestUploadContextExpiry(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, service, client := newTestService(t)
+	service.lock.Lock()
+	service.uploadExpiry = 100 * time.Millisecond
+	service.lock.Unlock()
+
+	// an uploaded context which no build uses is removed after a while
+	contextDir := t.TempDir()
+	writeContext(t, contextDir, map[string]string{
+		"Containerfile": "FROM scratch\n",
+	})
+	contextID, err := Upload(ctx, client, contextDir, nil, "")
+	require.NoError(t, err)
+	service.lock.Lock()
+	uploadedDir := service.contexts[contextID].dir
+	service.lock.Unlock()
+	assert.DirExists(t, uploadedDir)
+	assert.Eventually(t, func() bool {
+		service.lock.Lock()
+		defer service.lock.Unlock()
+		_, ok := service.contexts[contextID]
+		return !ok
+	}, 10*time.Second, 50*time.Millisecond)
+	assert.NoDirExists(t, uploadedDir)
+
+	// and can't be used by a build any more
+	stream, err := client.Build(ctx, &pb.BuildRequest{ContextId: contextID})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestParseDestination(t *testing.T) {
+	t.Parallel()
+	service := &Service{pushDir: t.TempDir()}
+	outside := t.TempDir()
+	// symbolic links are resolved as if the push directory was the root
+	require.NoError(t, os.Symlink("/", filepath.Join(service.pushDir, "link")))
+	for _, destSpec := range []string{
+		"registry.example/hello",
+		"localhost:5000/hello:latest",
+		"docker://registry.example/hello",
+		"dir:hello",
+		"oci:layout:hello",
+		"oci-archive:hello.tar",
+		"docker-archive:hello.tar:hello:latest",
+		"dir:link/hello",
+	} {
+		dest, err := service.parseDestination(destSpec)
+		if assert.NoErrorf(t, err, "parsing %q", destSpec) && dest.Transport().Name() != "docker" {
+			assert.Truef(t, strings.HasPrefix(dest.StringWithinTransport(), service.pushDir+string(os.PathSeparator)), "%q resolved to %q", destSpec, dest.StringWithinTransport())
+		}
+	}
+	for _, destSpec := range []string{
+		"dir:" + outside,
+		"dir:../hello",
+		"oci:/tmp/layout:hello",
+		"oci-archive:../hello.tar",
+		"docker-archive:/tmp/hello.tar",
+		"containers-storage:hello",
+		"docker-daemon:hello:latest",
+		"http://registry.example/hello",
+	} {
+		_, err := service.parseDestination(destSpec)
+		assert.Errorf(t, err, "parsing %q", destSpec)
+	}
+	_, err := (&Service{}).parseDestination("dir:hello")
+	assert.Error(t, err, "no push directory")
+}
+
+func TestEventSender(t *testing.T) {
+	t.Parallel()
+	var events []*pb.Event
+	sender := &eventSender{id: "abc", send: func(event *pb.Event) error {
+		events = append(events, event)
+		return nil
+	}}
+	require.NoError(t, sender.started())
+	n, err := sender.writer("stderr").Write([]byte("oops\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	require.NoError(t, sender.finished(&pb.Finished{Error: "oops"}))
+	require.Len(t, events, 3)
+	for _, event := range events {
+		assert.Equal(t, "abc", event.Id)
+	}
+	assert.Equal(t, "stderr", events[1].GetLog().Stream)
+	assert.Equal(t, "oops\n", events[1].GetLog().Text)
+	assert.Equal(t, "oops", events[2].GetFinished().Error)
+
+	failing := &eventSender{send: func(*pb.Event) error { return errors.New("closed") }}
+	_, err = failing.writer("stdout").Write([]byte("lost"))
+	assert.Error(t, err)
+}
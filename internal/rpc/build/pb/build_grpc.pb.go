@@ -0,0 +1,463 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.1
+// - protoc             v3.19.6
+// source: build.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Build_UploadContext_FullMethodName    = "/io.buildah.v1.Build/UploadContext"
+	Build_SyncContext_FullMethodName      = "/io.buildah.v1.Build/SyncContext"
+	Build_RemoveSession_FullMethodName    = "/io.buildah.v1.Build/RemoveSession"
+	Build_Build_FullMethodName            = "/io.buildah.v1.Build/Build"
+	Build_Cancel_FullMethodName           = "/io.buildah.v1.Build/Cancel"
+	Build_ListImages_FullMethodName       = "/io.buildah.v1.Build/ListImages"
+	Build_InspectImage_FullMethodName     = "/io.buildah.v1.Build/InspectImage"
+	Build_ListContainers_FullMethodName   = "/io.buildah.v1.Build/ListContainers"
+	Build_InspectContainer_FullMethodName = "/io.buildah.v1.Build/InspectContainer"
+	Build_Push_FullMethodName             = "/io.buildah.v1.Build/Push"
+)
+
+// BuildClient is the client API for Build service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BuildClient interface {
+	UploadContext(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadContextRequest, UploadContextResponse], error)
+	SyncContext(ctx context.Context, in *SyncContextRequest, opts ...grpc.CallOption) (*SyncContextResponse, error)
+	RemoveSession(ctx context.Context, in *RemoveSessionRequest, opts ...grpc.CallOption) (*RemoveSessionResponse, error)
+	Build(ctx context.Context, in *BuildRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	Cancel(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*CancelResponse, error)
+	ListImages(ctx context.Context, in *ListImagesRequest, opts ...grpc.CallOption) (*ListImagesResponse, error)
+	InspectImage(ctx context.Context, in *InspectRequest, opts ...grpc.CallOption) (*InspectResponse, error)
+	ListContainers(ctx context.Context, in *ListContainersRequest, opts ...grpc.CallOption) (*ListContainersResponse, error)
+	InspectContainer(ctx context.Context, in *InspectRequest, opts ...grpc.CallOption) (*InspectResponse, error)
+	Push(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type buildClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBuildClient(cc grpc.ClientConnInterface) BuildClient {
+	return &buildClient{cc}
+}
+
+func (c *buildClient) UploadContext(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadContextRequest, UploadContextResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Build_ServiceDesc.Streams[0], Build_UploadContext_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UploadContextRequest, UploadContextResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Build_UploadContextClient = grpc.ClientStreamingClient[UploadContextRequest, UploadContextResponse]
+
+func (c *buildClient) SyncContext(ctx context.Context, in *SyncContextRequest, opts ...grpc.CallOption) (*SyncContextResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SyncContextResponse)
+	err := c.cc.Invoke(ctx, Build_SyncContext_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *buildClient) RemoveSession(ctx context.Context, in *RemoveSessionRequest, opts ...grpc.CallOption) (*RemoveSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RemoveSessionResponse)
+	err := c.cc.Invoke(ctx, Build_RemoveSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *buildClient) Build(ctx context.Context, in *BuildRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Build_ServiceDesc.Streams[1], Build_Build_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BuildRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Build_BuildClient = grpc.ServerStreamingClient[Event]
+
+func (c *buildClient) Cancel(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*CancelResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelResponse)
+	err := c.cc.Invoke(ctx, Build_Cancel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *buildClient) ListImages(ctx context.Context, in *ListImagesRequest, opts ...grpc.CallOption) (*ListImagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListImagesResponse)
+	err := c.cc.Invoke(ctx, Build_ListImages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *buildClient) InspectImage(ctx context.Context, in *InspectRequest, opts ...grpc.CallOption) (*InspectResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InspectResponse)
+	err := c.cc.Invoke(ctx, Build_InspectImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *buildClient) ListContainers(ctx context.Context, in *ListContainersRequest, opts ...grpc.CallOption) (*ListContainersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListContainersResponse)
+	err := c.cc.Invoke(ctx, Build_ListContainers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *buildClient) InspectContainer(ctx context.Context, in *InspectRequest, opts ...grpc.CallOption) (*InspectResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InspectResponse)
+	err := c.cc.Invoke(ctx, Build_InspectContainer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *buildClient) Push(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Build_ServiceDesc.Streams[2], Build_Push_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PushRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Build_PushClient = grpc.ServerStreamingClient[Event]
+
+// BuildServer is the server API for Build service.
+// All implementations must embed UnimplementedBuildServer
+// for forward compatibility.
+type BuildServer interface {
+	UploadContext(grpc.ClientStreamingServer[UploadContextRequest, UploadContextResponse]) error
+	SyncContext(context.Context, *SyncContextRequest) (*SyncContextResponse, error)
+	RemoveSession(context.Context, *RemoveSessionRequest) (*RemoveSessionResponse, error)
+	Build(*BuildRequest, grpc.ServerStreamingServer[Event]) error
+	Cancel(context.Context, *CancelRequest) (*CancelResponse, error)
+	ListImages(context.Context, *ListImagesRequest) (*ListImagesResponse, error)
+	InspectImage(context.Context, *InspectRequest) (*InspectResponse, error)
+	ListContainers(context.Context, *ListContainersRequest) (*ListContainersResponse, error)
+	InspectContainer(context.Context, *InspectRequest) (*InspectResponse, error)
+	Push(*PushRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedBuildServer()
+}
+
+// UnimplementedBuildServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBuildServer struct{}
+
+func (UnimplementedBuildServer) UploadContext(grpc.ClientStreamingServer[UploadContextRequest, UploadContextResponse]) error {
+	return status.Error(codes.Unimplemented, "method UploadContext not implemented")
+}
+func (UnimplementedBuildServer) SyncContext(context.Context, *SyncContextRequest) (*SyncContextResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SyncContext not implemented")
+}
+func (UnimplementedBuildServer) RemoveSession(context.Context, *RemoveSessionRequest) (*RemoveSessionResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method RemoveSession not implemented")
+}
+func (UnimplementedBuildServer) Build(*BuildRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Error(codes.Unimplemented, "method Build not implemented")
+}
+func (UnimplementedBuildServer) Cancel(context.Context, *CancelRequest) (*CancelResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Cancel not implemented")
+}
+func (UnimplementedBuildServer) ListImages(context.Context, *ListImagesRequest) (*ListImagesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListImages not implemented")
+}
+func (UnimplementedBuildServer) InspectImage(context.Context, *InspectRequest) (*InspectResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method InspectImage not implemented")
+}
+func (UnimplementedBuildServer) ListContainers(context.Context, *ListContainersRequest) (*ListContainersResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListContainers not implemented")
+}
+func (UnimplementedBuildServer) InspectContainer(context.Context, *InspectRequest) (*InspectResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method InspectContainer not implemented")
+}
+func (UnimplementedBuildServer) Push(*PushRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Error(codes.Unimplemented, "method Push not implemented")
+}
+func (UnimplementedBuildServer) mustEmbedUnimplementedBuildServer() {}
+func (UnimplementedBuildServer) testEmbeddedByValue()               {}
+
+// UnsafeBuildServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BuildServer will
+// result in compilation errors.
+type UnsafeBuildServer interface {
+	mustEmbedUnimplementedBuildServer()
+}
+
+func RegisterBuildServer(s grpc.ServiceRegistrar, srv BuildServer) {
+	// If the following call panics, it indicates UnimplementedBuildServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Build_ServiceDesc, srv)
+}
+
+func _Build_UploadContext_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(BuildServer).UploadContext(&grpc.GenericServerStream[UploadContextRequest, UploadContextResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Build_UploadContextServer = grpc.ClientStreamingServer[UploadContextRequest, UploadContextResponse]
+
+func _Build_SyncContext_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncContextRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuildServer).SyncContext(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Build_SyncContext_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuildServer).SyncContext(ctx, req.(*SyncContextRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Build_RemoveSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuildServer).RemoveSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Build_RemoveSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuildServer).RemoveSession(ctx, req.(*RemoveSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Build_Build_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BuildRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BuildServer).Build(m, &grpc.GenericServerStream[BuildRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Build_BuildServer = grpc.ServerStreamingServer[Event]
+
+func _Build_Cancel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuildServer).Cancel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Build_Cancel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuildServer).Cancel(ctx, req.(*CancelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Build_ListImages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListImagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuildServer).ListImages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Build_ListImages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuildServer).ListImages(ctx, req.(*ListImagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Build_InspectImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuildServer).InspectImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Build_InspectImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuildServer).InspectImage(ctx, req.(*InspectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Build_ListContainers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListContainersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuildServer).ListContainers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Build_ListContainers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuildServer).ListContainers(ctx, req.(*ListContainersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Build_InspectContainer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuildServer).InspectContainer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Build_InspectContainer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuildServer).InspectContainer(ctx, req.(*InspectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Build_Push_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PushRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BuildServer).Push(m, &grpc.GenericServerStream[PushRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Build_PushServer = grpc.ServerStreamingServer[Event]
+
+// Build_ServiceDesc is the grpc.ServiceDesc for Build service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Build_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "io.buildah.v1.Build",
+	HandlerType: (*BuildServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SyncContext",
+			Handler:    _Build_SyncContext_Handler,
+		},
+		{
+			MethodName: "RemoveSession",
+			Handler:    _Build_RemoveSession_Handler,
+		},
+		{
+			MethodName: "Cancel",
+			Handler:    _Build_Cancel_Handler,
+		},
+		{
+			MethodName: "ListImages",
+			Handler:    _Build_ListImages_Handler,
+		},
+		{
+			MethodName: "InspectImage",
+			Handler:    _Build_InspectImage_Handler,
+		},
+		{
+			MethodName: "ListContainers",
+			Handler:    _Build_ListContainers_Handler,
+		},
+		{
+			MethodName: "InspectContainer",
+			Handler:    _Build_InspectContainer_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadContext",
+			Handler:       _Build_UploadContext_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Build",
+			Handler:       _Build_Build_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Push",
+			Handler:       _Build_Push_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "build.proto",
+}
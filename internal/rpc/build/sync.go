@@ -0,0 +1,605 @@
+package build
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+	"go.podman.io/buildah/internal/rpc/build/pb"
+	"go.podman.io/buildah/pkg/parse"
+	"go.podman.io/storage/pkg/fileutils"
+	"go.podman.io/storage/pkg/stringid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	fileTypeFile    = "file"
+	fileTypeDir     = "dir"
+	fileTypeSymlink = "symlink"
+
+	// uploadChunkSize is how much of an archive Sync sends per message.
+	uploadChunkSize = 1024 * 1024
+)
+
+// session is a copy of a build context which is kept between builds.
+type session struct {
+	lock  sync.Mutex
+	dir   string
+	files []*pb.FileInfo
+	// files and symbolic links which the last sync asked to have uploaded
+	needed map[string]*pb.FileInfo
+	// digests of files in dir, which are recomputed if a file's size or
+	// modification time change
+	digests map[string]cachedDigest
+}
+
+type cachedDigest struct {
+	size   int64
+	mtime  int64
+	digest string
+}
+
+// fileInfo builds a FileInfo for an item in a build context, returning nil
+// for items which aren't files, directories, or symbolic links.
+func fileInfo(path, rel string, st fs.FileInfo) (*pb.FileInfo, error) {
+	info := &pb.FileInfo{
+		Path:  filepath.ToSlash(rel),
+		Mode:  uint32(st.Mode().Perm()),
+		Mtime: st.ModTime().UnixNano(),
+	}
+	if st.Mode()&fs.ModeSetuid != 0 {
+		info.Mode |= 0o4000
+	}
+	if st.Mode()&fs.ModeSetgid != 0 {
+		info.Mode |= 0o2000
+	}
+	if st.Mode()&fs.ModeSticky != 0 {
+		info.Mode |= 0o1000
+	}
+	switch {
+	case st.Mode().IsRegular():
+		info.Type = fileTypeFile
+		info.Size = st.Size()
+	case st.IsDir():
+		info.Type = fileTypeDir
+	case st.Mode()&fs.ModeSymlink != 0:
+		info.Type = fileTypeSymlink
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		info.LinkTarget = target
+		info.Mtime = 0
+	default:
+		return nil, nil
+	}
+	return info, nil
+}
+
+// fileMode converts a FileInfo's mode to an fs.FileMode.
+func fileMode(info *pb.FileInfo) fs.FileMode {
+	mode := fs.FileMode(info.Mode) & fs.ModePerm
+	if info.Mode&0o4000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if info.Mode&0o2000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if info.Mode&0o1000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+func digestFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	digester := digest.Canonical.Digester()
+	if _, err := io.Copy(digester.Hash(), f); err != nil {
+		return "", err
+	}
+	return digester.Digest().String(), nil
+}
+
+// digest returns the digest of a file in the session's directory.
+func (s *session) digest(rel string, st fs.FileInfo) (string, error) {
+	if cached, ok := s.digests[rel]; ok && cached.size == st.Size() && cached.mtime == st.ModTime().UnixNano() {
+		return cached.digest, nil
+	}
+	d, err := digestFile(filepath.Join(s.dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", err
+	}
+	s.digests[rel] = cachedDigest{size: st.Size(), mtime: st.ModTime().UnixNano(), digest: d}
+	return d, nil
+}
+
+// sync removes items from the session's directory which aren't in files or
+// which have changed type, creates missing directories, and returns the paths
+// of files and symbolic links which need to be uploaded.
+func (s *session) sync(files []*pb.FileInfo) ([]string, error) {
+	wanted := make(map[string]*pb.FileInfo, len(files))
+	for _, info := range files {
+		wanted[info.Path] = info
+	}
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		info, ok := wanted[rel]
+		if ok {
+			switch {
+			case d.IsDir() && info.Type == fileTypeDir,
+				d.Type().IsRegular() && info.Type == fileTypeFile,
+				d.Type()&fs.ModeSymlink != 0 && info.Type == fileTypeSymlink:
+				return nil
+			}
+		}
+		delete(s.digests, rel)
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var needed []string
+	s.needed = make(map[string]*pb.FileInfo)
+	for _, info := range files {
+		path := filepath.Join(s.dir, filepath.FromSlash(info.Path))
+		st, err := os.Lstat(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		switch info.Type {
+		case fileTypeDir:
+			if err != nil {
+				if err := os.Mkdir(path, 0o700); err != nil {
+					return nil, err
+				}
+			}
+		case fileTypeSymlink:
+			if err == nil {
+				if target, err := os.Readlink(path); err == nil && target == info.LinkTarget {
+					continue
+				}
+			}
+			needed = append(needed, info.Path)
+			s.needed[info.Path] = info
+		case fileTypeFile:
+			if err == nil && st.Size() == info.Size && st.ModTime().UnixNano() == info.Mtime && st.Mode().Perm() == fileMode(info).Perm() {
+				d, err := s.digest(info.Path, st)
+				if err != nil {
+					return nil, err
+				}
+				if d == info.Digest {
+					continue
+				}
+			}
+			needed = append(needed, info.Path)
+			s.needed[info.Path] = info
+		}
+	}
+	s.files = files
+	return needed, s.finish()
+}
+
+// checkUpload returns an error if an entry in an uploaded archive isn't one
+// of the items which the last sync asked to have uploaded.
+func (s *session) checkUpload(hdr *tar.Header) error {
+	info, ok := s.needed[path.Clean(hdr.Name)]
+	if !ok {
+		return fmt.Errorf("%q was not requested", hdr.Name)
+	}
+	switch {
+	case info.Type == fileTypeFile && hdr.Typeflag == tar.TypeReg,
+		info.Type == fileTypeSymlink && hdr.Typeflag == tar.TypeSymlink:
+		return nil
+	}
+	return fmt.Errorf("%q was requested as a %s", hdr.Name, info.Type)
+}
+
+// filterArchive returns a reader which passes along the archive read from r,
+// failing at the first entry for which check returns an error.  The returned
+// reader should be closed when it is no longer needed.
+func filterArchive(r io.Reader, check func(*tar.Header) error) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		tr := tar.NewReader(r)
+		tw := tar.NewWriter(writer)
+		err := func() error {
+			for {
+				hdr, err := tr.Next()
+				if err != nil {
+					if errors.Is(err, io.EOF) {
+						return tw.Close()
+					}
+					return err
+				}
+				if err := check(hdr); err != nil {
+					return err
+				}
+				if err := tw.WriteHeader(hdr); err != nil {
+					return err
+				}
+				if _, err := io.Copy(tw, tr); err != nil {
+					return err
+				}
+			}
+		}()
+		writer.CloseWithError(err)
+	}()
+	return reader
+}
+
+// finish sets the permissions and modification times of directories in the
+// session's directory, which are changed when items are added to or removed
+// from them.
+func (s *session) finish() error {
+	for _, info := range slices.Backward(s.files) {
+		if info.Type != fileTypeDir {
+			continue
+		}
+		path := filepath.Join(s.dir, filepath.FromSlash(info.Path))
+		if st, err := os.Lstat(path); err != nil || !st.IsDir() {
+			return fmt.Errorf("%q should be a directory", info.Path)
+		}
+		if err := os.Chmod(path, fileMode(info)); err != nil {
+			return err
+		}
+		mtime := time.Unix(0, info.Mtime)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateFileInfo(info *pb.FileInfo) error {
+	if info == nil {
+		return errors.New("missing file information")
+	}
+	if info.Path == "" || info.Path == "." || !filepath.IsLocal(filepath.FromSlash(info.Path)) || filepath.ToSlash(filepath.Clean(filepath.FromSlash(info.Path))) != info.Path {
+		return fmt.Errorf("invalid path %q", info.Path)
+	}
+	switch info.Type {
+	case fileTypeFile, fileTypeDir, fileTypeSymlink:
+	default:
+		return fmt.Errorf("unrecognized type %q for %q", info.Type, info.Path)
+	}
+	return nil
+}
+
+// getSession returns a session and locks it.
+func (b *Service) getSession(id string) (*session, error) {
+	b.lock.Lock()
+	s, ok := b.sessions[id]
+	b.lock.Unlock()
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no session with ID %q", id)
+	}
+	s.lock.Lock()
+	return s, nil
+}
+
+// SyncContext updates a session's copy of a build context.
+func (b *Service) SyncContext(_ context.Context, req *pb.SyncContextRequest) (*pb.SyncContextResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "no sync request")
+	}
+	// every item's parent directory has to be listed before it, so that
+	// we never create anything under a symbolic link
+	dirs := make(map[string]struct{})
+	for _, info := range req.Files {
+		if err := validateFileInfo(info); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if parent := path.Dir(info.Path); parent != "." {
+			if _, ok := dirs[parent]; !ok {
+				return nil, status.Errorf(codes.InvalidArgument, "%q is not listed after its parent directory", info.Path)
+			}
+		}
+		if info.Type == fileTypeDir {
+			dirs[info.Path] = struct{}{}
+		}
+	}
+	id := req.Session
+	if id == "" {
+		id = stringid.GenerateRandomID()
+		dir := filepath.Join(b.contextDir, id)
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			return nil, err
+		}
+		b.lock.Lock()
+		b.sessions[id] = &session{dir: dir, digests: make(map[string]cachedDigest)}
+		b.lock.Unlock()
+	}
+	s, err := b.getSession(id)
+	if err != nil {
+		return nil, err
+	}
+	defer s.lock.Unlock()
+	needed, err := s.sync(req.Files)
+	if err != nil {
+		return nil, fmt.Errorf("updating build context for session %q: %w", id, err)
+	}
+	return &pb.SyncContextResponse{Session: id, Needed: needed}, nil
+}
+
+// RemoveSession removes a session's copy of a build context.
+func (b *Service) RemoveSession(_ context.Context, req *pb.RemoveSessionRequest) (*pb.RemoveSessionResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "no session")
+	}
+	s, err := b.getSession(req.Session)
+	if err != nil {
+		return nil, err
+	}
+	defer s.lock.Unlock()
+	b.lock.Lock()
+	delete(b.sessions, req.Session)
+	b.lock.Unlock()
+	if err := os.RemoveAll(s.dir); err != nil {
+		return nil, err
+	}
+	return &pb.RemoveSessionResponse{}, nil
+}
+
+// Manifest lists the items in contextDir which a build using the specified
+// Containerfiles and ignore file (or, if ignoreFile is "", the ignore file
+// which would be found by default) would be able to use.  The Containerfiles
+// and the ignore file are always included.
+func Manifest(contextDir string, containerfiles []string, ignoreFile string) ([]*pb.FileInfo, error) {
+	return listContext(contextDir, containerfiles, ignoreFile, true)
+}
+
+// listContext implements Manifest, only computing digests of files if
+// withDigests is set.
+func listContext(contextDir string, containerfiles []string, ignoreFile string, withDigests bool) ([]*pb.FileInfo, error) {
+	excludes, ignoreFile, err := parse.ContainerIgnoreFile(contextDir, ignoreFile, containerfiles)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	pm, err := fileutils.NewPatternMatcher(excludes)
+	if err != nil {
+		return nil, fmt.Errorf("processing excludes list %v: %w", excludes, err)
+	}
+	always := make(map[string]struct{})
+	for _, path := range append(slices.Clone(containerfiles), ignoreFile) {
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(contextDir, path)
+		}
+		if rel, err := filepath.Rel(contextDir, path); err == nil && filepath.IsLocal(rel) {
+			// include the file and the directories which contain it
+			for ; rel != "."; rel = filepath.Dir(rel) {
+				always[rel] = struct{}{}
+			}
+		}
+	}
+	var files []*pb.FileInfo
+	// excluded directories which we descend into, because exclusions might
+	// apply to items in them, which are only kept if something in them is
+	tentative := make(map[string]struct{})
+	err = filepath.WalkDir(contextDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(contextDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if _, ok := always[rel]; !ok {
+			excluded, err := pm.IsMatch(rel)
+			if err != nil {
+				return err
+			}
+			if excluded {
+				if !d.IsDir() {
+					return nil
+				}
+				if !pm.Exclusions() {
+					return filepath.SkipDir
+				}
+				tentative[filepath.ToSlash(rel)] = struct{}{}
+			}
+		}
+		st, err := d.Info()
+		if err != nil {
+			return err
+		}
+		info, err := fileInfo(path, rel, st)
+		if err != nil || info == nil {
+			return err
+		}
+		if info.Type == fileTypeFile && withDigests {
+			if info.Digest, err = digestFile(path); err != nil {
+				return err
+			}
+		}
+		files = append(files, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(tentative) > 0 {
+		keep := make(map[string]struct{})
+		for _, info := range files {
+			if _, ok := tentative[info.Path]; !ok {
+				for dir := path.Dir(info.Path); dir != "."; dir = path.Dir(dir) {
+					keep[dir] = struct{}{}
+				}
+			}
+		}
+		files = slices.DeleteFunc(files, func(info *pb.FileInfo) bool {
+			_, isTentative := tentative[info.Path]
+			_, isKept := keep[info.Path]
+			return isTentative && !isKept
+		})
+	}
+	return files, nil
+}
+
+// uploadWriter sends what's written to it to UploadContext.
+type uploadWriter struct {
+	send func(*pb.UploadContextRequest) error
+}
+
+func (u *uploadWriter) Write(p []byte) (int, error) {
+	for written := 0; written < len(p); {
+		chunk := p[written:min(len(p), written+uploadChunkSize)]
+		if err := u.send(&pb.UploadContextRequest{Data: chunk}); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+	}
+	return len(p), nil
+}
+
+// Sync brings the copy of contextDir which the service at client keeps for a
+// session up to date, starting a new session if session is "", and returns
+// the session's ID and the paths of the items which had to be sent.  See
+// Manifest for the meanings of containerfiles and ignoreFile.
+func Sync(ctx context.Context, client pb.BuildClient, session, contextDir string, containerfiles []string, ignoreFile string) (string, []string, error) {
+	files, err := Manifest(contextDir, containerfiles, ignoreFile)
+	if err != nil {
+		return "", nil, fmt.Errorf("listing contents of %q: %w", contextDir, err)
+	}
+	resp, err := client.SyncContext(ctx, &pb.SyncContextRequest{Session: session, Files: files})
+	if err != nil {
+		return "", nil, fmt.Errorf("comparing build context %q with session: %w", contextDir, err)
+	}
+	session = resp.Session
+	if len(resp.Needed) == 0 {
+		return session, nil, nil
+	}
+	logrus.Debugf("uploading %d items from %q to session %s", len(resp.Needed), contextDir, session)
+	stream, err := client.UploadContext(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := stream.Send(&pb.UploadContextRequest{Session: session}); err != nil {
+		return "", nil, err
+	}
+	infos := make(map[string]*pb.FileInfo, len(files))
+	for _, info := range files {
+		infos[info.Path] = info
+	}
+	tw := tar.NewWriter(&uploadWriter{send: stream.Send})
+	for _, path := range resp.Needed {
+		info, ok := infos[path]
+		if !ok {
+			return "", nil, fmt.Errorf("service requested %q, which is not in the build context", path)
+		}
+		if err := writeTarEntry(tw, contextDir, info); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", nil, err
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return "", nil, fmt.Errorf("uploading changes to build context %q: %w", contextDir, err)
+	}
+	return session, resp.Needed, nil
+}
+
+// Upload sends a copy of contextDir to the service at client, and returns
+// the ID of the uploaded context, which can be used for one build.  See
+// Manifest for the meanings of containerfiles and ignoreFile.
+func Upload(ctx context.Context, client pb.BuildClient, contextDir string, containerfiles []string, ignoreFile string) (string, error) {
+	files, err := listContext(contextDir, containerfiles, ignoreFile, false)
+	if err != nil {
+		return "", fmt.Errorf("listing contents of %q: %w", contextDir, err)
+	}
+	logrus.Debugf("uploading %d items from %q", len(files), contextDir)
+	stream, err := client.UploadContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	tw := tar.NewWriter(&uploadWriter{send: stream.Send})
+	for _, info := range files {
+		if err := writeTarEntry(tw, contextDir, info); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return "", fmt.Errorf("uploading build context %q: %w", contextDir, err)
+	}
+	return resp.ContextId, nil
+}
+
+func writeTarEntry(tw *tar.Writer, contextDir string, info *pb.FileInfo) error {
+	path := filepath.Join(contextDir, filepath.FromSlash(info.Path))
+	hdr := &tar.Header{
+		Name:    info.Path,
+		Mode:    int64(info.Mode),
+		ModTime: time.Unix(0, info.Mtime),
+		Format:  tar.FormatPAX,
+	}
+	switch info.Type {
+	case fileTypeDir:
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+		return tw.WriteHeader(hdr)
+	case fileTypeSymlink:
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = info.LinkTarget
+		return tw.WriteHeader(hdr)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hdr.Typeflag = tar.TypeReg
+	hdr.Size = info.Size
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(tw, f, info.Size); err != nil {
+		return fmt.Errorf("reading %q, which may have changed: %w", path, err)
+	}
+	return nil
+}
+
+// sessionDir locks a session and returns its directory, for use as a build
+// context.
+func (b *Service) sessionDir(id string) (string, func(), error) {
+	s, err := b.getSession(id)
+	if err != nil {
+		return "", nil, err
+	}
+	return s.dir, s.lock.Unlock, nil
+}
@@ -0,0 +1,195 @@
+package build
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/buildah/internal/rpc/build/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// writeContext populates a build context directory.
+func writeContext(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	}
+}
+
+func manifestPaths(files []*pb.FileInfo) []string {
+	var paths []string
+	for _, info := range files {
+		paths = append(paths, info.Path)
+	}
+	return paths
+}
+
+func TestManifest(t *testing.T) {
+	t.Parallel()
+	contextDir := t.TempDir()
+	writeContext(t, contextDir, map[string]string{
+		"Containerfile":    "FROM scratch\nCOPY . /\n",
+		".containerignore": "*.log\nbuild\ntmp\nContainerfile\n!build/keep\n",
+		"src/main.go":      "package main\n",
+		"debug.log":        "noise\n",
+		"build/out":        "binary\n",
+		"build/keep":       "keep me\n",
+		"tmp/junk":         "junk\n",
+	})
+	require.NoError(t, os.Symlink("src/main.go", filepath.Join(contextDir, "link")))
+	require.NoError(t, os.Chmod(filepath.Join(contextDir, "src"), 0o750))
+
+	files, err := Manifest(contextDir, []string{"Containerfile"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{".containerignore", "Containerfile", "build", "build/keep", "link", "src", "src/main.go"}, manifestPaths(files))
+	for _, info := range files {
+		switch info.Path {
+		case "src":
+			assert.Equal(t, fileTypeDir, info.Type)
+			assert.EqualValues(t, 0o750, info.Mode)
+		case "link":
+			assert.Equal(t, fileTypeSymlink, info.Type)
+			assert.Equal(t, "src/main.go", info.LinkTarget)
+		case "src/main.go":
+			assert.Equal(t, fileTypeFile, info.Type)
+			assert.EqualValues(t, len("package main\n"), info.Size)
+			assert.Equal(t, digest.FromString("package main\n").String(), info.Digest)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	// This test cannot be parallelized, since it builds an image, and
+	// NewBuilder() isn't safe to call concurrently.
+	ctx := context.Background()
+	_, service, client := newTestService(t)
+
+	contextDir := t.TempDir()
+	writeContext(t, contextDir, map[string]string{
+		"Containerfile":    "FROM scratch\nCOPY . /\n",
+		".containerignore": "*.log\n",
+		"a/one":            "one\n",
+		"a/b/two":          "two\n",
+		"three":            "three\n",
+		"debug.log":        "noise\n",
+	})
+	require.NoError(t, os.Symlink("three", filepath.Join(contextDir, "link")))
+
+	// needed reports what a sync of contextDir into session would upload
+	needed := func(session string) []string {
+		files, err := Manifest(contextDir, nil, "")
+		require.NoError(t, err)
+		resp, err := client.SyncContext(ctx, &pb.SyncContextRequest{Session: session, Files: files})
+		require.NoError(t, err)
+		return resp.Needed
+	}
+	// check compares the session's copy with the original
+	check := func(session string) {
+		t.Helper()
+		service.lock.Lock()
+		dir := service.sessions[session].dir
+		service.lock.Unlock()
+		expected, err := Manifest(contextDir, nil, "")
+		require.NoError(t, err)
+		actual, err := Manifest(dir, nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	session, sent, err := Sync(ctx, client, "", contextDir, nil, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, session)
+	assert.ElementsMatch(t, []string{"Containerfile", ".containerignore", "a/one", "a/b/two", "three", "link"}, sent)
+	check(session)
+	assert.Empty(t, needed(session), "nothing changed")
+
+	// modify, add, and remove some things
+	writeContext(t, contextDir, map[string]string{
+		"a/b/two":   "TWO\n",
+		"a/c/four":  "four\n",
+		"other.log": "more noise\n",
+	})
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(contextDir, "three"), later, later))
+	require.NoError(t, os.Remove(filepath.Join(contextDir, "a", "one")))
+	require.NoError(t, os.Remove(filepath.Join(contextDir, "link")))
+	require.NoError(t, os.Mkdir(filepath.Join(contextDir, "link"), 0o755))
+	assert.ElementsMatch(t, []string{"a/b/two", "a/c/four", "three"}, needed(session))
+	_, sent, err = Sync(ctx, client, session, contextDir, nil, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a/b/two", "a/c/four", "three"}, sent)
+	check(session)
+	assert.Empty(t, needed(session), "nothing changed")
+
+	// build using the session's copy, which is kept afterward
+	stream, err := client.Build(ctx, &pb.BuildRequest{Session: session})
+	require.NoError(t, err)
+	_, finished := finish(t, stream)
+	require.Empty(t, finished.Error)
+	assert.NotEmpty(t, finished.ImageId)
+	assert.Empty(t, needed(session), "nothing changed")
+
+	// a second session doesn't interfere with the first
+	other, _, err := Sync(ctx, client, "", contextDir, nil, "")
+	require.NoError(t, err)
+	assert.NotEqual(t, session, other)
+	check(other)
+
+	// only what the last sync asked for can be uploaded
+	for _, hdr := range []*tar.Header{
+		{Name: "extra", Mode: 0o644, Typeflag: tar.TypeReg},
+		{Name: "three", Typeflag: tar.TypeSymlink, Linkname: "/"},
+	} {
+		var archive bytes.Buffer
+		tw := tar.NewWriter(&archive)
+		require.NoError(t, tw.WriteHeader(hdr))
+		require.NoError(t, tw.Close())
+		upload, err := client.UploadContext(ctx)
+		require.NoError(t, err)
+		require.NoError(t, upload.Send(&pb.UploadContextRequest{Session: session, Data: archive.Bytes()}))
+		_, err = upload.CloseAndRecv()
+		assert.Equalf(t, codes.InvalidArgument, status.Code(err), "uploading %q", hdr.Name)
+	}
+	check(session)
+
+	// bad requests
+	for _, files := range [][]*pb.FileInfo{
+		{{Path: "../escape", Type: fileTypeFile}},
+		{{Path: "/abs", Type: fileTypeFile}},
+		{{Path: "a/../b", Type: fileTypeFile}},
+		{{Path: "a/b", Type: fileTypeFile}},
+		{{Path: "a", Type: fileTypeSymlink, LinkTarget: "/"}, {Path: "a/b", Type: fileTypeDir}},
+		{{Path: "pipe", Type: "fifo"}},
+	} {
+		_, err := client.SyncContext(ctx, &pb.SyncContextRequest{Session: session, Files: files})
+		assert.Equalf(t, codes.InvalidArgument, status.Code(err), "syncing %v", files)
+	}
+	_, err = client.SyncContext(ctx, &pb.SyncContextRequest{Session: "nonexistent"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, _, err = Sync(ctx, client, "nonexistent", contextDir, nil, "")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	stream, err = client.Build(ctx, &pb.BuildRequest{Session: "nonexistent"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	service.lock.Lock()
+	dir := service.sessions[session].dir
+	service.lock.Unlock()
+	_, err = client.RemoveSession(ctx, &pb.RemoveSessionRequest{Session: session})
+	require.NoError(t, err)
+	assert.NoDirExists(t, dir)
+	_, err = client.RemoveSession(ctx, &pb.RemoveSessionRequest{Session: session})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,146 @@
+package debug
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"go.podman.io/buildah/define"
+	"go.podman.io/buildah/internal/rpc/debug/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+type pendingPause struct {
+	pause  *pb.Pause
+	resume chan string
+}
+
+type debugServer struct {
+	pb.UnimplementedDebugServer
+	lock    sync.Mutex
+	nextID  uint64
+	pending []*pendingPause
+	changed chan struct{}
+}
+
+func (d *debugServer) Pause(ctx context.Context, req *pb.PauseRequest) (*pb.PauseResponse, error) {
+	if req == nil || req.Pause == nil {
+		return nil, status.Error(codes.InvalidArgument, "no pause information")
+	}
+	d.lock.Lock()
+	d.nextID++
+	p := &pendingPause{pause: req.Pause, resume: make(chan string, 1)}
+	p.pause.Id = d.nextID
+	d.pending = append(d.pending, p)
+	close(d.changed)
+	d.changed = make(chan struct{})
+	d.lock.Unlock()
+	defer func() {
+		d.lock.Lock()
+		d.pending = slices.DeleteFunc(d.pending, func(q *pendingPause) bool { return q == p })
+		d.lock.Unlock()
+	}()
+	select {
+	case action := <-p.resume:
+		return &pb.PauseResponse{Action: action}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (d *debugServer) Wait(ctx context.Context, _ *pb.WaitRequest) (*pb.WaitResponse, error) {
+	for {
+		d.lock.Lock()
+		if len(d.pending) > 0 {
+			resp := &pb.WaitResponse{Pause: d.pending[0].pause}
+			d.lock.Unlock()
+			return resp, nil
+		}
+		changed := d.changed
+		d.lock.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (d *debugServer) Resume(_ context.Context, req *pb.ResumeRequest) (*pb.ResumeResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "no resume information")
+	}
+	switch define.DebugAction(req.Action) {
+	case define.DebugContinue, define.DebugStep, define.DebugSkip, define.DebugAbort:
+	case define.DebugShell:
+		return nil, status.Errorf(codes.InvalidArgument, "action %q requires a terminal, run a shell in the working container instead", req.Action)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unrecognized action %q", req.Action)
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	i := slices.IndexFunc(d.pending, func(p *pendingPause) bool { return p.pause.Id == req.Id })
+	if i == -1 {
+		return nil, status.Errorf(codes.NotFound, "no pause with ID %d", req.Id)
+	}
+	d.pending[i].resume <- req.Action
+	d.pending = slices.Delete(d.pending, i, i+1)
+	return &pb.ResumeResponse{}, nil
+}
+
+// Register adds a Debug service, which relays between a paused build and a
+// client which decides what the build should do next, to s.
+func Register(s grpc.ServiceRegistrar) {
+	pb.RegisterDebugServer(s, &debugServer{changed: make(chan struct{})})
+}
+
+// Debugger is a define.BuildDebugger which relays pauses to a Debug service.
+type Debugger struct {
+	conn   *grpc.ClientConn
+	client pb.DebugClient
+}
+
+// NewDebugger returns a Debugger which uses the Debug service at location,
+// which is typically a "unix://" URL.
+func NewDebugger(location string) (*Debugger, error) {
+	conn, err := grpc.NewClient(location, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to debug service at %q: %w", location, err)
+	}
+	return &Debugger{conn: conn, client: pb.NewDebugClient(conn)}, nil
+}
+
+// Pause waits for a client of the Debug service to decide what the build
+// should do next.
+func (d *Debugger) Pause(ctx context.Context, pause define.DebugPause) (define.DebugAction, error) {
+	resp, err := d.client.Pause(ctx, &pb.PauseRequest{
+		Pause: &pb.Pause{
+			Stage:       int32(pause.Stage),
+			StageName:   pause.StageName,
+			Step:        int32(pause.Step),
+			Steps:       int32(pause.Steps),
+			Line:        int32(pause.Line),
+			Instruction: pause.Instruction,
+			Resolved:    pause.Resolved,
+			Env:         pause.Env,
+			WorkingDir:  pause.WorkingDir,
+			User:        pause.User,
+			Cache:       pause.Cache,
+			Container:   pause.ContainerID,
+			Breakpoint:  pause.Breakpoint,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return define.DebugAction(resp.Action), nil
+}
+
+// Close closes the connection to the Debug service.
+func (d *Debugger) Close() error {
+	return d.conn.Close()
+}
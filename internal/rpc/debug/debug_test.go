@@ -0,0 +1,85 @@
+package debug
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/buildah/define"
+	"go.podman.io/buildah/internal/rpc/debug/pb"
+	"go.podman.io/buildah/internal/rpc/listen"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDebugRelay(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	socketPath := filepath.Join(t.TempDir(), "socket")
+	listener, cleanup, err := listen.Listen(socketPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, cleanup()) })
+	s := grpc.NewServer()
+	Register(s)
+	go func() { assert.NoError(t, s.Serve(listener)) }()
+	t.Cleanup(s.Stop)
+
+	debugger, err := NewDebugger("unix://" + socketPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, debugger.Close()) })
+	controller, err := NewDebugger("unix://" + socketPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, controller.Close()) })
+
+	type result struct {
+		action define.DebugAction
+		err    error
+	}
+	results := make(chan result)
+	go func() {
+		action, err := debugger.Pause(ctx, define.DebugPause{
+			Stage:       1,
+			StageName:   "builder",
+			Step:        2,
+			Steps:       3,
+			Instruction: "RUN make $TARGET",
+			Resolved:    "RUN make all",
+			Env:         []string{"TARGET=all"},
+			ContainerID: "abc123",
+		})
+		results <- result{action, err}
+	}()
+
+	waited, err := controller.client.Wait(ctx, &pb.WaitRequest{})
+	require.NoError(t, err)
+	pause := waited.Pause
+	assert.EqualValues(t, 1, pause.Stage)
+	assert.Equal(t, "builder", pause.StageName)
+	assert.Equal(t, "RUN make all", pause.Resolved)
+	assert.Equal(t, []string{"TARGET=all"}, pause.Env)
+	assert.Equal(t, "abc123", pause.Container)
+
+	// waiting again returns the same pause until it's resumed
+	again, err := controller.client.Wait(ctx, &pb.WaitRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, pause.Id, again.Pause.Id)
+
+	_, err = controller.client.Resume(ctx, &pb.ResumeRequest{Id: pause.Id, Action: "dance"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = controller.client.Resume(ctx, &pb.ResumeRequest{Id: pause.Id, Action: string(define.DebugShell)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = controller.client.Resume(ctx, &pb.ResumeRequest{Id: pause.Id + 1, Action: string(define.DebugSkip)})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = controller.client.Resume(ctx, &pb.ResumeRequest{Id: pause.Id, Action: string(define.DebugSkip)})
+	require.NoError(t, err)
+
+	r := <-results
+	require.NoError(t, r.err)
+	assert.Equal(t, define.DebugSkip, r.action)
+
+	_, err = controller.client.Resume(ctx, &pb.ResumeRequest{Id: pause.Id, Action: string(define.DebugSkip)})
+	assert.Equal(t, codes.NotFound, status.Code(err), "resumed the same pause twice")
+}
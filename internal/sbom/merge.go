@@ -195,15 +195,33 @@ func encodeJSON(outputFile string, document any) error {
 	return nil
 }
 
+// purlImageContents and purlDocument describe the file of package URLs which
+// Merge writes.
+type purlImageContents struct {
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+type purlDocument struct {
+	ImageContents purlImageContents `json:"image_contents"`
+}
+
+// ReadPURLs reads the list of package URLs from a file which Merge wrote.
+func ReadPURLs(inputPURL string) ([]string, error) {
+	src, err := os.Open(inputPURL)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	var document purlDocument
+	if err = json.NewDecoder(src).Decode(&document); err != nil {
+		return nil, fmt.Errorf("decoding JSON document from %q: %w", inputPURL, err)
+	}
+	return document.ImageContents.Dependencies, nil
+}
+
 // Merge adds the contents of inputSBOM to inputOutputSBOM using one of a
 // handful of named strategies.
 func Merge(mergeStrategy define.SBOMMergeStrategy, inputOutputSBOM, inputSBOM, outputPURL string) (err error) {
-	type purlImageContents struct {
-		Dependencies []string `json:"dependencies,omitempty"`
-	}
-	type purlDocument struct {
-		ImageContents purlImageContents `json:"image_contents"`
-	}
 	purls := []string{}
 	seenPurls := make(map[string]struct{})
 
@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.podman.io/buildah/define"
 )
 
 func TestEncodeJSON(t *testing.T) {
@@ -304,3 +305,20 @@ func TestMergeSlicesWithoutDuplicatesMissing(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expected, base)
 }
+
+func TestReadPURLs(t *testing.T) {
+	t.Parallel()
+	tmp := t.TempDir()
+	sbom := filepath.Join(tmp, "sbom.json")
+	require.NoError(t, os.WriteFile(sbom, []byte(`{"components":[{"name":"bash","version":"5.2","purl":"pkg:rpm/fedora/bash@5.2"},{"name":"zlib","version":"1.3","purl":"pkg:rpm/fedora/zlib@1.3"}]}`), 0o644))
+	merged := filepath.Join(tmp, "merged.json")
+	require.NoError(t, os.WriteFile(merged, []byte(`{}`), 0o644))
+	purl := filepath.Join(tmp, "purl.json")
+	require.NoError(t, Merge(define.SBOMMergeStrategyCycloneDXByComponentNameAndVersion, merged, sbom, purl))
+	purls, err := ReadPURLs(purl)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pkg:rpm/fedora/bash@5.2", "pkg:rpm/fedora/zlib@1.3"}, purls)
+
+	_, err = ReadPURLs(filepath.Join(tmp, "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
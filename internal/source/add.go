@@ -19,6 +19,9 @@ import (
 type AddOptions struct {
 	// Annotations for the source artifact.
 	Annotations []string
+	// Excludes lists patterns, in .containerignore format, for items in a
+	// directory which should not be added.
+	Excludes []string
 }
 
 // annotations parses the specified annotations and transforms them into a map.
@@ -60,7 +63,7 @@ func Add(ctx context.Context, sourcePath string, artifactPath string, options Ad
 	}
 	defer ociDest.Close()
 
-	tarStream, err := archive.TarWithOptions(artifactPath, &archive.TarOptions{Compression: archive.Gzip})
+	tarStream, err := archive.TarWithOptions(artifactPath, &archive.TarOptions{Compression: archive.Gzip, ExcludePatterns: options.Excludes})
 	if err != nil {
 		return fmt.Errorf("creating compressed tar stream: %w", err)
 	}
@@ -17,6 +17,8 @@ type CreateOptions struct {
 	Author string
 	// TimeStamp controls whether a "created" timestamp is set or not.
 	TimeStamp bool
+	// Annotations to set in the source image's manifest.
+	Annotations map[string]string
 }
 
 // createdTime returns `time.Now()` if the options are configured to include a
@@ -61,6 +63,7 @@ func Create(ctx context.Context, sourcePath string, options CreateOptions) error
 			Digest:    configBlob.Digest,
 			Size:      configBlob.Size,
 		},
+		Annotations: options.Annotations,
 	}
 	if _, _, err := writeManifest(ctx, &manifest, ociDest); err != nil {
 		return err
@@ -0,0 +1,91 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.podman.io/image/v5/oci/layout"
+	"go.podman.io/image/v5/pkg/blobinfocache/none"
+	"go.podman.io/image/v5/types"
+	"go.podman.io/storage/pkg/archive"
+	"go.podman.io/storage/pkg/chrootarchive"
+)
+
+// ExtractOptions include data to alter certain knobs when extracting source
+// artifacts from a source image.
+type ExtractOptions struct {
+	// Artifacts to extract, by name or digest.  If none are specified,
+	// every artifact is extracted, in the order in which they were added.
+	Artifacts []string
+}
+
+// Extract extracts source artifacts from the source image at `sourcePath`
+// into the directory `destination`, which is created if it doesn't already
+// exist.  The contents of each artifact are checked against its digest.
+func Extract(ctx context.Context, sourcePath string, destination string, options ExtractOptions) error {
+	info, err := Inspect(ctx, sourcePath)
+	if err != nil {
+		return err
+	}
+	selected := make([]int, 0, len(info.Artifacts))
+	if len(options.Artifacts) == 0 {
+		for i := range info.Artifacts {
+			selected = append(selected, i)
+		}
+	}
+	for _, name := range options.Artifacts {
+		i, err := findArtifact(info.Artifacts, name)
+		if err != nil {
+			return err
+		}
+		selected = append(selected, i)
+	}
+
+	if err := os.MkdirAll(destination, 0o755); err != nil {
+		return fmt.Errorf("creating %q: %w", destination, err)
+	}
+
+	ociRef, err := layout.ParseReference(sourcePath)
+	if err != nil {
+		return err
+	}
+	ociSource, err := ociRef.NewImageSource(ctx, &types.SystemContext{})
+	if err != nil {
+		return err
+	}
+	defer ociSource.Close()
+
+	for _, i := range selected {
+		artifact := info.Artifacts[i]
+		if err := extractArtifact(ctx, ociSource, artifact, destination); err != nil {
+			return fmt.Errorf("extracting source artifact %q: %w", artifact.Name, err)
+		}
+	}
+	return nil
+}
+
+// extractArtifact extracts one artifact into `destination`.
+func extractArtifact(ctx context.Context, ociSource types.ImageSource, artifact Artifact, destination string) error {
+	rc, _, err := ociSource.GetBlob(ctx, types.BlobInfo{Digest: artifact.Digest, Size: artifact.Size}, none.NoCache)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	verifier := artifact.Digest.Verifier()
+	reader := io.TeeReader(rc, verifier)
+	options := &archive.TarOptions{IgnoreChownErrors: true}
+	if err := chrootarchive.Untar(reader, destination, options); err != nil {
+		return err
+	}
+	// consume any padding that followed the end of the archive, so that
+	// the whole blob is digested
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("contents do not match digest %s", artifact.Digest)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	specV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.podman.io/image/v5/oci/layout"
+	"go.podman.io/image/v5/pkg/blobinfocache/none"
+	"go.podman.io/image/v5/types"
+)
+
+// Artifact describes a source artifact in a source image.
+type Artifact struct {
+	// Name is the artifact's title annotation, if it has one, or the
+	// location its contents came from, if it was added by a build, or
+	// its digest.
+	Name        string            `json:"name"`
+	Digest      digest.Digest     `json:"digest"`
+	MediaType   string            `json:"mediaType"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Info describes a source image.
+type Info struct {
+	ManifestDigest digest.Digest     `json:"manifestDigest"`
+	Config         ImageConfig       `json:"config"`
+	Annotations    map[string]string `json:"annotations,omitempty"`
+	Artifacts      []Artifact        `json:"artifacts"`
+}
+
+// artifactName returns the name by which we refer to a source artifact.
+func artifactName(layer specV1.Descriptor) string {
+	if title := layer.Annotations[specV1.AnnotationTitle]; title != "" {
+		return title
+	}
+	return layer.Digest.String()
+}
+
+// Inspect returns information about the source image at `sourcePath`.
+func Inspect(ctx context.Context, sourcePath string) (*Info, error) {
+	ociRef, err := layout.ParseReference(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	ociSource, err := ociRef.NewImageSource(ctx, &types.SystemContext{})
+	if err != nil {
+		return nil, err
+	}
+	defer ociSource.Close()
+
+	manifest, manifestDigest, _, err := readManifestFromImageSource(ctx, ociSource)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Config.MediaType != MediaTypeSourceImageConfig {
+		return nil, fmt.Errorf("%q is not a source image: config is of type %q (expected: %q)", sourcePath, manifest.Config.MediaType, MediaTypeSourceImageConfig)
+	}
+
+	info := Info{
+		ManifestDigest: *manifestDigest,
+		Annotations:    manifest.Annotations,
+		Artifacts:      []Artifact{},
+	}
+	rc, _, err := ociSource.GetBlob(ctx, types.BlobInfo{Digest: manifest.Config.Digest, Size: manifest.Config.Size}, none.NoCache)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	defer rc.Close()
+	rawConfig, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	if err := json.Unmarshal(rawConfig, &info.Config); err != nil {
+		return nil, fmt.Errorf("decoding config: %w", err)
+	}
+
+	for _, layer := range manifest.Layers {
+		info.Artifacts = append(info.Artifacts, Artifact{
+			Name:        artifactName(layer),
+			Digest:      layer.Digest,
+			MediaType:   layer.MediaType,
+			Size:        layer.Size,
+			Annotations: layer.Annotations,
+		})
+	}
+	return &info, nil
+}
+
+// findArtifact returns the index of the artifact which `name` refers to,
+// either by its name, or by its digest or a unique prefix of its digest.
+func findArtifact(artifacts []Artifact, name string) (int, error) {
+	for i := range artifacts {
+		if artifacts[i].Name == name || artifacts[i].Digest.String() == name {
+			return i, nil
+		}
+	}
+	found := -1
+	prefix := strings.TrimPrefix(name, digest.Canonical.String()+":")
+	for i := range artifacts {
+		if prefix != "" && strings.HasPrefix(artifacts[i].Digest.Encoded(), prefix) {
+			if found != -1 && artifacts[found].Digest != artifacts[i].Digest {
+				return -1, fmt.Errorf("%q matches more than one source artifact", name)
+			}
+			found = i
+		}
+	}
+	if found == -1 {
+		return -1, fmt.Errorf("no source artifact matching %q", name)
+	}
+	return found, nil
+}
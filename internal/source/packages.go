@@ -0,0 +1,281 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Package is a source package which a binary package was built from.
+type Package struct {
+	// Type is the type of the binary package's package URL, either "rpm"
+	// or "deb".
+	Type string
+	// File is the name of the source package's file: a source RPM, or a
+	// Debian source control file which lists the package's other files.
+	File string
+}
+
+// binNMUSuffix matches the suffix of a Debian binary package's version which
+// indicates that the package was rebuilt without changing its source.
+var binNMUSuffix = regexp.MustCompile(`\+b[0-9]+$`)
+
+// parsePURL splits a package URL into its type, name, version, and
+// qualifiers.
+func parsePURL(purl string) (string, string, string, url.Values, error) {
+	rest, ok := strings.CutPrefix(purl, "pkg:")
+	if !ok {
+		return "", "", "", nil, fmt.Errorf("package URL %q does not start with %q", purl, "pkg:")
+	}
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, rawQualifiers, _ := strings.Cut(rest, "?")
+	qualifiers, err := url.ParseQuery(rawQualifiers)
+	if err != nil {
+		return "", "", "", nil, fmt.Errorf("parsing qualifiers of package URL %q: %w", purl, err)
+	}
+	var version string
+	if i := strings.LastIndex(rest, "@"); i != -1 {
+		if version, err = url.PathUnescape(rest[i+1:]); err != nil {
+			return "", "", "", nil, fmt.Errorf("parsing version in package URL %q: %w", purl, err)
+		}
+		rest = rest[:i]
+	}
+	segments := strings.Split(strings.Trim(rest, "/"), "/")
+	if len(segments) < 2 {
+		return "", "", "", nil, fmt.Errorf("package URL %q has no name", purl)
+	}
+	name, err := url.PathUnescape(segments[len(segments)-1])
+	if err != nil {
+		return "", "", "", nil, fmt.Errorf("parsing name in package URL %q: %w", purl, err)
+	}
+	return strings.ToLower(segments[0]), name, version, qualifiers, nil
+}
+
+// isFileName returns true if name can be used as the name of a file in a
+// directory without referring to anything outside of it.
+func isFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// PackageForPURL returns the source package which the binary package
+// with the specified package URL was built from.  The source package of an
+// RPM is named by the URL's "upstream" qualifier, and the source package of a
+// Debian package is named by its "upstream" qualifier or its name and version.
+// It returns false if the URL doesn't describe an RPM or a Debian package, or
+// if its source package can't be determined.
+func PackageForPURL(purl string) (Package, bool) {
+	purlType, name, version, qualifiers, err := parsePURL(purl)
+	if err != nil {
+		return Package{}, false
+	}
+	switch purlType {
+	case "rpm":
+		upstream := qualifiers.Get("upstream")
+		if !strings.HasSuffix(upstream, ".src.rpm") || !isFileName(upstream) {
+			return Package{}, false
+		}
+		return Package{Type: purlType, File: upstream}, true
+	case "deb":
+		// a binNMU doesn't change the source package's version
+		sourceName, sourceVersion := name, binNMUSuffix.ReplaceAllString(version, "")
+		if upstream := qualifiers.Get("upstream"); upstream != "" {
+			upstreamName, upstreamVersion, hasVersion := strings.Cut(upstream, "@")
+			sourceName = upstreamName
+			if hasVersion {
+				sourceVersion = upstreamVersion
+			}
+		}
+		// epochs aren't part of file names
+		if _, withoutEpoch, hasEpoch := strings.Cut(sourceVersion, ":"); hasEpoch {
+			sourceVersion = withoutEpoch
+		}
+		file := sourceName + "_" + sourceVersion + ".dsc"
+		if sourceName == "" || sourceVersion == "" || !isFileName(file) {
+			return Package{}, false
+		}
+		return Package{Type: purlType, File: file}, true
+	}
+	return Package{}, false
+}
+
+// Packages returns the source packages which the binary packages with
+// the specified package URLs were built from, listing each only once, and
+// skipping packages whose source packages can't be determined.
+func Packages(purls []string) []Package {
+	var packages []Package
+	seen := make(map[Package]struct{})
+	for _, purl := range purls {
+		pkg, ok := PackageForPURL(purl)
+		if !ok {
+			continue
+		}
+		if _, ok := seen[pkg]; ok {
+			continue
+		}
+		seen[pkg] = struct{}{}
+		packages = append(packages, pkg)
+	}
+	return packages
+}
+
+// FetchPackageOptions controls where FetchPackage looks for source packages.
+type FetchPackageOptions struct {
+	// Locations are local directories, or http or https URLs of
+	// directories, in which the files which make up source packages are
+	// looked up by name, in order.
+	Locations []string
+	// Client is used to fetch files from URLs.  If it is nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+}
+
+// errPackageFileNotFound is returned by fetchPackageFile if none of the
+// locations have the file.
+var errPackageFileNotFound = errors.New("not found")
+
+// fetchPackageFile copies the first file with the specified name from one of
+// the locations into the directory, and returns its path.
+func fetchPackageFile(ctx context.Context, name, directory string, options FetchPackageOptions) (string, error) {
+	if !isFileName(name) {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	open := func(location string) (io.ReadCloser, error) {
+		if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+			f, err := os.Open(filepath.Join(location, name))
+			if err != nil && errors.Is(err, os.ErrNotExist) {
+				return nil, errPackageFileNotFound
+			}
+			return f, err
+		}
+		client := options.Client
+		if client == nil {
+			client = http.DefaultClient
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(location, "/")+"/"+url.PathEscape(name), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			return nil, errPackageFileNotFound
+		}
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+			resp.Body.Close()
+			return nil, fmt.Errorf("invalid response status %d from %q", resp.StatusCode, req.URL.Redacted())
+		}
+		return resp.Body, nil
+	}
+	for _, location := range options.Locations {
+		rc, err := open(location)
+		if err != nil {
+			if errors.Is(err, errPackageFileNotFound) {
+				continue
+			}
+			return "", fmt.Errorf("reading %q from %q: %w", name, location, err)
+		}
+		defer rc.Close()
+		path := filepath.Join(directory, name)
+		f, err := os.Create(path)
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(f, rc); err != nil {
+			f.Close()
+			return "", fmt.Errorf("reading %q from %q: %w", name, location, err)
+		}
+		return path, f.Close()
+	}
+	return "", fmt.Errorf("%q: %w in any of %v", name, errPackageFileNotFound, options.Locations)
+}
+
+// parseDSC reads the names of the files which a Debian source control file
+// lists, along with their SHA-256 digests if it lists them.
+func parseDSC(dsc []byte) (map[string]string, error) {
+	files := make(map[string]string)
+	field := ""
+	scanner := bufio.NewScanner(bytes.NewReader(dsc))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || (line[0] != ' ' && line[0] != '\t') {
+			field, _, _ = strings.Cut(line, ":")
+			continue
+		}
+		// each line of these fields is "checksum size name"
+		if field != "Files" && field != "Checksums-Sha256" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 || !isFileName(fields[2]) {
+			return nil, fmt.Errorf("invalid %s line %q", field, strings.TrimSpace(line))
+		}
+		if field == "Checksums-Sha256" {
+			files[fields[2]] = fields[0]
+		} else if _, ok := files[fields[2]]; !ok {
+			files[fields[2]] = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no files listed")
+	}
+	return files, nil
+}
+
+// FetchPackage copies the files which make up a source package into a
+// directory.  For a Debian source package, that includes the files which its
+// source control file lists, whose SHA-256 digests are checked if they are
+// listed.
+func FetchPackage(ctx context.Context, pkg Package, directory string, options FetchPackageOptions) error {
+	path, err := fetchPackageFile(ctx, pkg.File, directory, options)
+	if err != nil || pkg.Type != "deb" {
+		return err
+	}
+	dsc, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	files, err := parseDSC(dsc)
+	if err != nil {
+		return fmt.Errorf("parsing %q: %w", pkg.File, err)
+	}
+	for name, expected := range files {
+		path, err := fetchPackageFile(ctx, name, directory, options)
+		if err != nil {
+			return err
+		}
+		if expected == "" {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		digester := sha256.New()
+		_, err = io.Copy(digester, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if actual := hex.EncodeToString(digester.Sum(nil)); actual != expected {
+			return fmt.Errorf("%q has SHA-256 digest %s, but %q lists %s", name, actual, pkg.File, expected)
+		}
+	}
+	return nil
+}
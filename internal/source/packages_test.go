@@ -0,0 +1,101 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageForPURL(t *testing.T) {
+	t.Parallel()
+	for _, c := range []struct {
+		purl     string
+		expected Package
+		ok       bool
+	}{
+		{"pkg:rpm/fedora/bash@5.2.26-3.fc40?arch=x86_64&upstream=bash-5.2.26-3.fc40.src.rpm&distro=fedora-40", Package{Type: "rpm", File: "bash-5.2.26-3.fc40.src.rpm"}, true},
+		{"pkg:rpm/fedora/gpg-pubkey@a15b79cc-63d04c2c", Package{}, false},
+		{"pkg:rpm/fedora/evil@1?upstream=..%2F..%2Fetc%2Fpasswd.src.rpm", Package{}, false},
+		{"pkg:deb/debian/bash@5.2.15-2%2Bb2?arch=amd64&distro=debian-12", Package{Type: "deb", File: "bash_5.2.15-2.dsc"}, true},
+		{"pkg:deb/debian/libc6@2.36-9%2Bdeb12u4?arch=amd64&upstream=glibc", Package{Type: "deb", File: "glibc_2.36-9+deb12u4.dsc"}, true},
+		{"pkg:deb/debian/libgcc-s1@12.2.0-14?arch=amd64&upstream=gcc-12%4012.2.0-14", Package{Type: "deb", File: "gcc-12_12.2.0-14.dsc"}, true},
+		{"pkg:deb/debian/tzdata@1:2024a-0%2Bdeb12u1", Package{Type: "deb", File: "tzdata_2024a-0+deb12u1.dsc"}, true},
+		{"pkg:deb/debian/unversioned", Package{}, false},
+		{"pkg:golang/github.com/containers/buildah@v1.40.0", Package{}, false},
+		{"not a purl", Package{}, false},
+	} {
+		pkg, ok := PackageForPURL(c.purl)
+		assert.Equalf(t, c.ok, ok, "source package for %q", c.purl)
+		assert.Equalf(t, c.expected, pkg, "source package for %q", c.purl)
+	}
+
+	packages := Packages([]string{
+		"pkg:deb/debian/libc6@2.36-9?upstream=glibc",
+		"pkg:deb/debian/libc-bin@2.36-9?upstream=glibc",
+		"pkg:golang/example.com/module@v1.0.0",
+	})
+	assert.Equal(t, []Package{{Type: "deb", File: "glibc_2.36-9.dsc"}}, packages)
+}
+
+func TestFetchPackage(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	local := t.TempDir()
+	remote := t.TempDir()
+	server := httptest.NewServer(http.FileServer(http.Dir(remote)))
+	t.Cleanup(server.Close)
+	options := FetchPackageOptions{Locations: []string{local, server.URL + "/"}}
+
+	require.NoError(t, os.WriteFile(filepath.Join(remote, "tool-1.0-1.src.rpm"), []byte("srpm"), 0o644))
+	directory := t.TempDir()
+	require.NoError(t, FetchPackage(ctx, Package{Type: "rpm", File: "tool-1.0-1.src.rpm"}, directory, options))
+	contents, err := os.ReadFile(filepath.Join(directory, "tool-1.0-1.src.rpm"))
+	require.NoError(t, err)
+	assert.Equal(t, "srpm", string(contents))
+
+	err = FetchPackage(ctx, Package{Type: "rpm", File: "missing-1.0-1.src.rpm"}, t.TempDir(), options)
+	assert.ErrorIs(t, err, errPackageFileNotFound)
+
+	// a Debian source package's other files are found through its
+	// source control file, which can be in a different location
+	orig := []byte("original source")
+	origDigest := sha256.Sum256(orig)
+	require.NoError(t, os.WriteFile(filepath.Join(remote, "tool_1.0.orig.tar.gz"), orig, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(remote, "tool_1.0-1.debian.tar.xz"), []byte("packaging"), 0o644))
+	dsc := `-----BEGIN PGP SIGNED MESSAGE-----
+Hash: SHA512
+
+Format: 3.0 (quilt)
+Source: tool
+Version: 1.0-1
+Checksums-Sha256:
+ ` + hex.EncodeToString(origDigest[:]) + ` 15 tool_1.0.orig.tar.gz
+Files:
+ 0123456789abcdef0123456789abcdef 15 tool_1.0.orig.tar.gz
+ 0123456789abcdef0123456789abcdef 9 tool_1.0-1.debian.tar.xz
+`
+	require.NoError(t, os.WriteFile(filepath.Join(local, "tool_1.0-1.dsc"), []byte(dsc), 0o644))
+	directory = t.TempDir()
+	require.NoError(t, FetchPackage(ctx, Package{Type: "deb", File: "tool_1.0-1.dsc"}, directory, options))
+	for _, name := range []string{"tool_1.0-1.dsc", "tool_1.0.orig.tar.gz", "tool_1.0-1.debian.tar.xz"} {
+		assert.FileExists(t, filepath.Join(directory, name))
+	}
+
+	// listed digests are checked
+	require.NoError(t, os.WriteFile(filepath.Join(remote, "tool_1.0.orig.tar.gz"), []byte("tampered"), 0o644))
+	err = FetchPackage(ctx, Package{Type: "deb", File: "tool_1.0-1.dsc"}, t.TempDir(), options)
+	assert.ErrorContains(t, err, "SHA-256 digest")
+
+	// names of files in other directories aren't followed
+	require.NoError(t, os.WriteFile(filepath.Join(local, "evil_1.0-1.dsc"), []byte("Files:\n 0123 4 ../../etc/passwd\n"), 0o644))
+	err = FetchPackage(ctx, Package{Type: "deb", File: "evil_1.0-1.dsc"}, t.TempDir(), options)
+	assert.ErrorContains(t, err, "invalid Files line")
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 
 	"go.podman.io/buildah/pkg/parse"
 	"go.podman.io/image/v5/copy"
 	"go.podman.io/image/v5/oci/layout"
 	"go.podman.io/image/v5/pkg/shortnames"
 	"go.podman.io/image/v5/signature"
+	"go.podman.io/image/v5/transports"
 	"go.podman.io/image/v5/transports/alltransports"
 	"go.podman.io/image/v5/types"
 	"go.podman.io/storage/pkg/fileutils"
@@ -78,7 +80,12 @@ func Pull(ctx context.Context, imageInput string, sourcePath string, options Pul
 	return nil
 }
 
+// stringToImageReference parses a fully-qualified image name, which is assumed
+// to refer to a registry unless it specifies a transport.
 func stringToImageReference(imageInput string) (types.ImageReference, error) {
+	if transport, _, ok := strings.Cut(imageInput, ":"); ok && transports.Get(transport) != nil {
+		return alltransports.ParseImageName(imageInput)
+	}
 	if shortnames.IsShortName(imageInput) {
 		return nil, fmt.Errorf("pulling source images by short name (%q) is not supported, please use a fully-qualified name", imageInput)
 	}
@@ -3,6 +3,7 @@ package source
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 
 	"go.podman.io/buildah/pkg/parse"
@@ -24,6 +25,12 @@ type PushOptions struct {
 	Quiet bool
 	// If set after copying the artifact, write the digest of the resulting image to the file
 	DigestFile string
+	// SystemContext, if set, is used instead of one built from TLSVerify
+	// and Credentials.
+	SystemContext *types.SystemContext
+	// ReportWriter, if set, receives progress information instead of
+	// os.Stderr.
+	ReportWriter io.Writer
 }
 
 // Push the source image at `sourcePath` to `imageInput` at a container
@@ -41,7 +48,9 @@ func Push(ctx context.Context, sourcePath string, imageInput string, options Pus
 	sysCtx := &types.SystemContext{
 		DockerInsecureSkipTLSVerify: types.NewOptionalBool(!options.TLSVerify),
 	}
-	if options.Credentials != "" {
+	if options.SystemContext != nil {
+		sysCtx = options.SystemContext
+	} else if options.Credentials != "" {
 		authConf, err := parse.AuthConfig(options.Credentials)
 		if err != nil {
 			return err
@@ -63,6 +72,9 @@ func Push(ctx context.Context, sourcePath string, imageInput string, options Pus
 	}
 	if !options.Quiet {
 		copyOpts.ReportWriter = os.Stderr
+		if options.ReportWriter != nil {
+			copyOpts.ReportWriter = options.ReportWriter
+		}
 	}
 	manifestBytes, err := copy.Image(ctx, policyContext, destRef, srcRef, copyOpts)
 	if err != nil {
@@ -20,6 +20,20 @@ import (
 // MediaTypeSourceImageConfig specifies the media type of a source-image config.
 const MediaTypeSourceImageConfig = "application/vnd.oci.source.image.config.v1+json"
 
+const (
+	// AnnotationImageDigest is set in the manifest of a source image which
+	// was generated by a build to the manifest digest of the built image.
+	AnnotationImageDigest = "io.buildah.source.image.digest"
+	// AnnotationImageName is set in the manifest of a source image which
+	// was generated by a build to the name of the built image.
+	AnnotationImageName = "io.buildah.source.image.name"
+	// AnnotationArtifactSource is set on each layer of a source image which
+	// was generated by a build to describe where the layer's contents came
+	// from: "context" for the build context, the URL which an ADD
+	// instruction used, or the file name of a source package.
+	AnnotationArtifactSource = "io.buildah.source.artifact"
+)
+
 // ImageConfig specifies the config of a source image.
 type ImageConfig struct {
 	// Created is the combined date and time at which the layer was created, formatted as defined by RFC 3339, section 5.6.
@@ -0,0 +1,90 @@
+package source
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	specV1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// blobPath returns the location of the blob which `descriptor` describes in
+// the OCI layout at `sourcePath`.
+func blobPath(sourcePath string, descriptor specV1.Descriptor) (string, error) {
+	if err := descriptor.Digest.Validate(); err != nil {
+		return "", fmt.Errorf("invalid digest %q: %w", descriptor.Digest, err)
+	}
+	return filepath.Join(sourcePath, "blobs", descriptor.Digest.Algorithm().String(), descriptor.Digest.Encoded()), nil
+}
+
+// verifyBlob checks that the blob which `descriptor` describes is present in
+// the OCI layout at `sourcePath`, and that its size and digest match.
+func verifyBlob(sourcePath string, descriptor specV1.Descriptor) error {
+	path, err := blobPath(sourcePath, descriptor)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading blob %s: %w", descriptor.Digest, err)
+	}
+	defer f.Close()
+	digester := descriptor.Digest.Algorithm().Digester()
+	size, err := io.Copy(digester.Hash(), f)
+	if err != nil {
+		return fmt.Errorf("reading blob %s: %w", descriptor.Digest, err)
+	}
+	if size != descriptor.Size {
+		return fmt.Errorf("blob %s is %d bytes long (expected %d)", descriptor.Digest, size, descriptor.Size)
+	}
+	if actual := digester.Digest(); actual != descriptor.Digest {
+		return fmt.Errorf("blob %s has digest %s", descriptor.Digest, actual)
+	}
+	return nil
+}
+
+// Verify checks that the manifest, config, and every source artifact in the
+// source image at `sourcePath` match the sizes and digests which refer to
+// them.  Every problem which is found is described in the returned error.
+func Verify(sourcePath string) error {
+	rawIndex, err := os.ReadFile(filepath.Join(sourcePath, "index.json"))
+	if err != nil {
+		return err
+	}
+	index := specV1.Index{}
+	if err := json.Unmarshal(rawIndex, &index); err != nil {
+		return fmt.Errorf("decoding index: %w", err)
+	}
+	if len(index.Manifests) != 1 {
+		return fmt.Errorf("index of %q lists %d manifests (expected 1)", sourcePath, len(index.Manifests))
+	}
+	if err := verifyBlob(sourcePath, index.Manifests[0]); err != nil {
+		return fmt.Errorf("verifying manifest: %w", err)
+	}
+	manifestPath, err := blobPath(sourcePath, index.Manifests[0])
+	if err != nil {
+		return err
+	}
+	rawManifest, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	manifest := specV1.Manifest{}
+	if err := json.Unmarshal(rawManifest, &manifest); err != nil {
+		return fmt.Errorf("decoding manifest: %w", err)
+	}
+
+	var errs []error
+	if err := verifyBlob(sourcePath, manifest.Config); err != nil {
+		errs = append(errs, fmt.Errorf("verifying config: %w", err))
+	}
+	for _, layer := range manifest.Layers {
+		if err := verifyBlob(sourcePath, layer); err != nil {
+			errs = append(errs, fmt.Errorf("verifying source artifact %q: %w", artifactName(layer), err))
+		}
+	}
+	return errors.Join(errs...)
+}
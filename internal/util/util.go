@@ -7,7 +7,10 @@ import (
 	"path/filepath"
 
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"go.podman.io/buildah/internal/mkcw"
 	"go.podman.io/buildah/internal/output"
+	"go.podman.io/buildah/internal/tmpdir"
 	"go.podman.io/common/libimage"
 	lplatform "go.podman.io/common/libimage/platform"
 	"go.podman.io/image/v5/types"
@@ -55,7 +58,7 @@ func ExportFromReader(input io.Reader, opts output.BuildOutputOption) error {
 	var err error
 
 	// Only process path for types that require it.
-	if opts.Type == output.BuildOutputLocalDir || opts.Type == output.BuildOutputTar {
+	if opts.Type == output.BuildOutputLocalDir || opts.Type == output.BuildOutputTar || opts.Type == output.BuildOutputFSImage {
 		if !filepath.IsAbs(opts.Path) {
 			if opts.Path, err = filepath.Abs(opts.Path); err != nil {
 				return err
@@ -99,6 +102,32 @@ func ExportFromReader(input io.Reader, opts output.BuildOutputOption) error {
 		if _, err = io.Copy(outFile, input); err != nil {
 			return fmt.Errorf("failed while performing copy to %q: %w", opts.Path, err)
 		}
+	case output.BuildOutputFSImage:
+		// Unpack everything into a scratch directory, keeping
+		// ownership and extended attributes intact, since unlike with
+		// a local directory, the user won't be accessing these files
+		// directly, and then build the filesystem image from that.
+		// When we're rootless, we're in a user namespace, so we can
+		// still chown to any of the IDs the contents would use.
+		scratch, err := os.MkdirTemp(tmpdir.GetTempDir(), "buildah-output")
+		if err != nil {
+			return fmt.Errorf("creating temporary directory: %w", err)
+		}
+		defer func() {
+			if err := os.RemoveAll(scratch); err != nil {
+				logrus.Warnf("removing temporary directory %q: %v", scratch, err)
+			}
+		}()
+		rootfs := filepath.Join(scratch, "rootfs")
+		if err = os.Mkdir(rootfs, 0o755); err != nil {
+			return fmt.Errorf("creating temporary directory: %w", err)
+		}
+		if err = chrootarchive.Untar(input, rootfs, &archive.TarOptions{}); err != nil {
+			return fmt.Errorf("failed while performing untar at %q: %w", rootfs, err)
+		}
+		if err = mkcw.MakeFSImage(rootfs, opts.Path, opts.Filesystem, ""); err != nil {
+			return fmt.Errorf("failed while creating %s image at %q: %w", opts.Filesystem, opts.Path, err)
+		}
 	default:
 		return fmt.Errorf("unsupported output type %q", opts.Type)
 	}
@@ -0,0 +1,264 @@
+package buildah
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"go.podman.io/buildah/internal/analyze"
+	"go.podman.io/buildah/internal/optimize"
+	"go.podman.io/image/v5/docker/reference"
+	"go.podman.io/image/v5/types"
+	"go.podman.io/storage"
+	"go.podman.io/storage/pkg/archive"
+)
+
+// OptimizeImageOptions provides both required and optional bits of
+// configuration for OptimizeImage().
+type OptimizeImageOptions struct {
+	// Required parameters.
+	InputImage  string
+	OutputImage types.ImageReference
+
+	// MergeSize is the size below which adjacent layers are merged.
+	// Layers are merged until the merged layer reaches this size.  If it
+	// is zero, layers are only merged if they would otherwise be empty.
+	MergeSize int64
+	// SplitPaths are paths whose contents are moved to a new top layer,
+	// so that layers below it can be reused when they change.
+	SplitPaths []string
+
+	// CommitOptions are used to commit the new image, so that settings
+	// like compression and encryption apply to it.  The layers and history
+	// which they would otherwise add are overridden.
+	CommitOptions CommitOptions
+
+	ContainerSuffix     string
+	SignaturePolicyPath string
+	Logger              *logrus.Logger
+}
+
+// OptimizeImage reads the layers of an image and writes a new image with a
+// functionally identical root filesystem and the same configuration, but
+// without the contents of files which were overwritten or deleted by later
+// layers, with small adjacent layers merged, and optionally with the contents
+// of specified paths moved to a new top layer.  Its history describes which of
+// the original layers each new layer was made from.
+// Returns the new image's ID and digest on success, along with a canonical
+// reference for it if a repository name was specified.
+func OptimizeImage(ctx context.Context, systemContext *types.SystemContext, store storage.Store, options OptimizeImageOptions) (string, reference.Canonical, digest.Digest, error) {
+	logger := options.Logger
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	if options.CommitOptions.Squash || options.CommitOptions.ConfidentialWorkloadOptions.Convert {
+		return "", nil, "", errors.New("optimizing an image can not be combined with squashing or converting it")
+	}
+
+	// Read the source image's configuration and history.
+	source, err := ImportBuilderFromImage(ctx, store, ImportFromImageOptions{
+		Image:               options.InputImage,
+		SignaturePolicyPath: options.SignaturePolicyPath,
+		SystemContext:       systemContext,
+	})
+	if err != nil {
+		return "", nil, "", err
+	}
+	sourceImage, err := store.Image(source.FromImageID)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("locating image %q: %w", options.InputImage, err)
+	}
+
+	// List the source image's layers, starting with the base layer.
+	var layers []analyze.Layer
+	uncompressed := archive.Uncompressed
+	for layerID := sourceImage.TopLayer; layerID != ""; {
+		layer, err := store.Layer(layerID)
+		if err != nil {
+			return "", nil, "", fmt.Errorf("locating layer %q: %w", layerID, err)
+		}
+		id := layer.ID
+		layers = append([]analyze.Layer{{
+			ID: id,
+			Open: func() (io.ReadCloser, error) {
+				return store.Diff("", id, &storage.DiffOptions{Compression: &uncompressed})
+			},
+		}}, layers...)
+		layerID = layer.Parent
+	}
+	open := func(source int) (io.ReadCloser, error) {
+		return layers[source].Open()
+	}
+
+	// Figure out which history entries go with which layers.  Entries for
+	// empty layers go with the next layer, or with the last layer if
+	// there is no next layer.
+	layerHistory := make([][]v1.History, len(layers))
+	if nonEmpty := len(source.OCIv1.History) - countEmptyLayers(source.OCIv1.History); nonEmpty == len(layers) && len(layers) > 0 {
+		layer := 0
+		for _, history := range source.OCIv1.History {
+			layerHistory[min(layer, len(layers)-1)] = append(layerHistory[min(layer, len(layers)-1)], history)
+			if !history.EmptyLayer {
+				layer++
+			}
+		}
+	} else {
+		logger.Debugf("image %q has %d layers, but its history describes %d, not preserving history", options.InputImage, len(layers), nonEmpty)
+	}
+
+	// Plan the new layers.
+	report, items, err := analyze.Surviving(layers)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("reading image %q: %w", options.InputImage, err)
+	}
+	planned, err := optimize.Plan(items, optimize.Options{MergeSize: options.MergeSize, SplitPaths: options.SplitPaths})
+	if err != nil {
+		return "", nil, "", fmt.Errorf("rearranging the layers of image %q: %w", options.InputImage, err)
+	}
+
+	// Create the working container which we'll commit, starting from
+	// scratch, but with the source image's configuration.
+	target, err := NewBuilder(ctx, store, BuilderOptions{
+		FromImage:           "scratch",
+		SystemContext:       systemContext,
+		Logger:              logger,
+		ContainerSuffix:     options.ContainerSuffix,
+		SignaturePolicyPath: options.SignaturePolicyPath,
+	})
+	if err != nil {
+		return "", nil, "", fmt.Errorf("creating container: %w", err)
+	}
+	defer func() {
+		if err := target.Delete(); err != nil {
+			logrus.Warnf("deleting target container: %v", err)
+		}
+	}()
+	target.OCIv1 = source.OCIv1
+	target.OCIv1.RootFS.DiffIDs = nil
+	target.OCIv1.History = nil
+	target.Docker = source.Docker
+	target.Docker.RootFS = nil
+	target.Docker.History = nil
+	target.Docker.Parent = ""
+	target.ImageAnnotations = source.ImageAnnotations
+
+	// Write the new layers where the working container's other data is
+	// kept, so that they're cleaned up along with it.
+	cdir, err := store.ContainerDirectory(target.ContainerID)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("determining directory for working container: %w", err)
+	}
+	shortID := source.FromImageID[:min(12, len(source.FromImageID))]
+	var linkedLayers []LinkedLayer
+	for i, layer := range planned {
+		if layer.Items == 0 {
+			continue
+		}
+		blobPath := filepath.Join(cdir, fmt.Sprintf("optimized-layer-%d.tar", i))
+		if err := writeOptimizedLayer(blobPath, &layer, open); err != nil {
+			return "", nil, "", err
+		}
+		history := v1.History{
+			Comment: fmt.Sprintf("buildah optimize: from layer%s %s of image %s", plural(len(layer.Sources)), formatLayerList(layer.Sources), shortID),
+		}
+		if layer.Split {
+			history.Comment = fmt.Sprintf("buildah optimize: %s, split out of layer%s %s of image %s", strings.Join(options.SplitPaths, ", "), plural(len(layer.Sources)), formatLayerList(layer.Sources), shortID)
+		}
+		var createdBy []string
+		for _, source := range layer.Sources {
+			for _, h := range layerHistory[source] {
+				if h.Created != nil && (history.Created == nil || h.Created.After(*history.Created)) {
+					history.Created = h.Created
+				}
+				// skip repeats, like the "/bin/sh" recorded for
+				// each commit of a container which didn't run anything
+				if h.CreatedBy != "" && (len(createdBy) == 0 || createdBy[len(createdBy)-1] != h.CreatedBy) {
+					createdBy = append(createdBy, h.CreatedBy)
+				}
+				if history.Author == "" {
+					history.Author = h.Author
+				}
+			}
+		}
+		history.CreatedBy = strings.Join(createdBy, " && ")
+		linkedLayers = append(linkedLayers, LinkedLayer{History: history, BlobPath: blobPath})
+	}
+
+	summary := fmt.Sprintf("rewrote %d layer%s as %d, dropping %d bytes of files which were overwritten or deleted", len(layers), plural(len(layers)), len(linkedLayers), report.WastedBytes)
+	if options.CommitOptions.ReportWriter != nil {
+		fmt.Fprintf(options.CommitOptions.ReportWriter, "Optimizing %s: %s\n", options.InputImage, summary)
+	}
+	target.SetCreatedBy(fmt.Sprintf("buildah optimize %s", options.InputImage))
+	target.SetHistoryComment(summary)
+
+	commitOptions := options.CommitOptions
+	commitOptions.PrependedLinkedLayers = append(linkedLayers, commitOptions.PrependedLinkedLayers...)
+	commitOptions.EmptyLayer = true
+	commitOptions.OmitLayerHistoryEntry = false
+	commitOptions.OmitHistory = false
+	if commitOptions.SystemContext == nil {
+		commitOptions.SystemContext = systemContext
+	}
+	if commitOptions.SignaturePolicyPath == "" {
+		commitOptions.SignaturePolicyPath = options.SignaturePolicyPath
+	}
+	return target.Commit(ctx, options.OutputImage, commitOptions)
+}
+
+// writeOptimizedLayer writes one of the layers which OptimizeImage() planned
+// to a file.
+func writeOptimizedLayer(blobPath string, layer *optimize.Layer, open func(int) (io.ReadCloser, error)) error {
+	f, err := os.Create(blobPath)
+	if err != nil {
+		return fmt.Errorf("creating layer file: %w", err)
+	}
+	if err := layer.Write(f, open); err != nil {
+		f.Close()
+		return fmt.Errorf("writing layer: %w", err)
+	}
+	return f.Close()
+}
+
+func countEmptyLayers(history []v1.History) int {
+	count := 0
+	for _, h := range history {
+		if h.EmptyLayer {
+			count++
+		}
+	}
+	return count
+}
+
+// formatLayerList formats a list of layer indexes, collapsing runs of
+// consecutive indexes into ranges.
+func formatLayerList(indexes []int) string {
+	var parts []string
+	for i := 0; i < len(indexes); {
+		j := i
+		for j+1 < len(indexes) && indexes[j+1] == indexes[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", indexes[i], indexes[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(indexes[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
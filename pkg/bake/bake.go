@@ -0,0 +1,337 @@
+// Package bake reads definitions of sets of images which are built together,
+// using the HCL and JSON file formats which "docker buildx bake" reads.
+//
+// A definition file contains "target" blocks, each of which describes how to
+// build one image, "group" blocks, which name sets of targets, and "variable"
+// blocks, whose values can be overridden using environment variables and
+// referred to in the other blocks using "${NAME}".  A set of functions from
+// the HCL standard library, such as join(), lower(), and replace(), can also
+// be called.
+package bake
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// DefaultFiles are the names of the definition files which are read, in
+// order, if none are specified.
+var DefaultFiles = []string{"docker-bake.json", "docker-bake.override.json", "docker-bake.hcl", "docker-bake.override.hcl"}
+
+// DefaultGroup is the group which is built if no targets are specified.
+const DefaultGroup = "default"
+
+// Target describes how to build one image.  Fields which are not set in the
+// target's definition are nil, and can be inherited from other targets.
+type Target struct {
+	Name       string            `json:"-" hcl:"name,label"`
+	Inherits   []string          `json:"inherits,omitempty" hcl:"inherits,optional"`
+	Context    *string           `json:"context,omitempty" hcl:"context,optional"`
+	Dockerfile *string           `json:"dockerfile,omitempty" hcl:"dockerfile,optional"`
+	Args       map[string]string `json:"args,omitempty" hcl:"args,optional"`
+	Labels     map[string]string `json:"labels,omitempty" hcl:"labels,optional"`
+	Tags       []string          `json:"tags,omitempty" hcl:"tags,optional"`
+	Platforms  []string          `json:"platforms,omitempty" hcl:"platforms,optional"`
+	Outputs    []string          `json:"output,omitempty" hcl:"output,optional"`
+	CacheFrom  []string          `json:"cache-from,omitempty" hcl:"cache-from,optional"`
+	CacheTo    []string          `json:"cache-to,omitempty" hcl:"cache-to,optional"`
+	Target     *string           `json:"target,omitempty" hcl:"target,optional"`
+	NoCache    *bool             `json:"no-cache,omitempty" hcl:"no-cache,optional"`
+	Pull       *bool             `json:"pull,omitempty" hcl:"pull,optional"`
+}
+
+// Group names a set of targets and other groups.
+type Group struct {
+	Name    string   `json:"-" hcl:"name,label"`
+	Targets []string `json:"targets" hcl:"targets"`
+}
+
+// Definition is the combined contents of one or more definition files.
+type Definition struct {
+	Groups  map[string]*Group  `json:"group,omitempty"`
+	Targets map[string]*Target `json:"target"`
+}
+
+// functions are the functions which can be called in definition files.
+var functions = map[string]function.Function{
+	"and":           stdlib.AndFunc,
+	"coalesce":      stdlib.CoalesceFunc,
+	"concat":        stdlib.ConcatFunc,
+	"contains":      stdlib.ContainsFunc,
+	"distinct":      stdlib.DistinctFunc,
+	"equal":         stdlib.EqualFunc,
+	"flatten":       stdlib.FlattenFunc,
+	"format":        stdlib.FormatFunc,
+	"join":          stdlib.JoinFunc,
+	"jsondecode":    stdlib.JSONDecodeFunc,
+	"jsonencode":    stdlib.JSONEncodeFunc,
+	"keys":          stdlib.KeysFunc,
+	"length":        stdlib.LengthFunc,
+	"lower":         stdlib.LowerFunc,
+	"merge":         stdlib.MergeFunc,
+	"not":           stdlib.NotFunc,
+	"notequal":      stdlib.NotEqualFunc,
+	"or":            stdlib.OrFunc,
+	"regex":         stdlib.RegexFunc,
+	"regex_replace": stdlib.RegexReplaceFunc,
+	"replace":       stdlib.ReplaceFunc,
+	"split":         stdlib.SplitFunc,
+	"substr":        stdlib.SubstrFunc,
+	"trimprefix":    stdlib.TrimPrefixFunc,
+	"trimspace":     stdlib.TrimSpaceFunc,
+	"trimsuffix":    stdlib.TrimSuffixFunc,
+	"upper":         stdlib.UpperFunc,
+	"values":        stdlib.ValuesFunc,
+}
+
+type variable struct {
+	Name    string    `hcl:"name,label"`
+	Default cty.Value `hcl:"default,optional"`
+}
+
+type fileContents struct {
+	Variables []*struct {
+		Name   string   `hcl:"name,label"`
+		Remain hcl.Body `hcl:",remain"`
+	} `hcl:"variable,block"`
+	Groups  []*Group  `hcl:"group,block"`
+	Targets []*Target `hcl:"target,block"`
+}
+
+// FindFiles returns the paths of the files in DefaultFiles which are present
+// in dir.
+func FindFiles(dir string) ([]string, error) {
+	var files []string
+	for _, name := range DefaultFiles {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no definition file (%s) found in %q", strings.Join(DefaultFiles, ", "), dir)
+	}
+	return files, nil
+}
+
+// ReadFiles reads and combines the definitions in the named files.  Files
+// whose names end in ".json" are parsed as JSON, and others are parsed as
+// HCL.  Values of variables are read from the environment, if they are set
+// there.  When a target or group is defined more than once, the settings in
+// later definitions override those in earlier ones.
+func ReadFiles(paths []string) (*Definition, error) {
+	contents := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading definition file: %w", err)
+		}
+		contents = append(contents, data)
+	}
+	return parse(paths, contents, os.LookupEnv)
+}
+
+func parse(names []string, contents [][]byte, lookupEnv func(string) (string, bool)) (*Definition, error) {
+	parser := hclparse.NewParser()
+	var files []*hcl.File
+	for i, name := range names {
+		var file *hcl.File
+		var diags hcl.Diagnostics
+		if strings.HasSuffix(name, ".json") {
+			file, diags = parser.ParseJSON(contents[i], name)
+		} else {
+			file, diags = parser.ParseHCL(contents[i], name)
+		}
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("parsing definition file: %w", diags)
+		}
+		files = append(files, file)
+	}
+	body := hcl.MergeFiles(files)
+
+	var decoded fileContents
+	variablesOnly, _, diags := body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "variable", LabelNames: []string{"name"}}},
+	})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("reading variables: %w", diags)
+	}
+	evalContext := &hcl.EvalContext{
+		Variables: map[string]cty.Value{},
+		Functions: functions,
+	}
+	for _, block := range variablesOnly.Blocks {
+		var v variable
+		if diags := gohcl.DecodeBody(block.Body, nil, &v); diags.HasErrors() {
+			return nil, fmt.Errorf("reading variable %q: %w", block.Labels[0], diags)
+		}
+		value := v.Default
+		if value.IsNull() {
+			value = cty.StringVal("")
+		}
+		if override, ok := lookupEnv(block.Labels[0]); ok {
+			value = cty.StringVal(override)
+		}
+		evalContext.Variables[block.Labels[0]] = value
+	}
+	if diags := gohcl.DecodeBody(body, evalContext, &decoded); diags.HasErrors() {
+		return nil, fmt.Errorf("reading definitions: %w", diags)
+	}
+
+	definition := &Definition{
+		Groups:  make(map[string]*Group),
+		Targets: make(map[string]*Target),
+	}
+	for _, group := range decoded.Groups {
+		definition.Groups[group.Name] = group
+	}
+	for _, target := range decoded.Targets {
+		if _, isGroup := definition.Groups[target.Name]; isGroup {
+			return nil, fmt.Errorf("%q is defined as both a group and a target", target.Name)
+		}
+		if existing, ok := definition.Targets[target.Name]; ok {
+			existing.merge(target)
+			continue
+		}
+		definition.Targets[target.Name] = target
+	}
+	return definition, nil
+}
+
+// merge overrides the settings in t with any which are set in o.  Args and
+// labels are merged.
+func (t *Target) merge(o *Target) {
+	if o.Inherits != nil {
+		t.Inherits = slices.Clone(o.Inherits)
+	}
+	if o.Context != nil {
+		t.Context = o.Context
+	}
+	if o.Dockerfile != nil {
+		t.Dockerfile = o.Dockerfile
+	}
+	if o.Args != nil {
+		if t.Args == nil {
+			t.Args = make(map[string]string)
+		}
+		maps.Copy(t.Args, o.Args)
+	}
+	if o.Labels != nil {
+		if t.Labels == nil {
+			t.Labels = make(map[string]string)
+		}
+		maps.Copy(t.Labels, o.Labels)
+	}
+	if o.Tags != nil {
+		t.Tags = slices.Clone(o.Tags)
+	}
+	if o.Platforms != nil {
+		t.Platforms = slices.Clone(o.Platforms)
+	}
+	if o.Outputs != nil {
+		t.Outputs = slices.Clone(o.Outputs)
+	}
+	if o.CacheFrom != nil {
+		t.CacheFrom = slices.Clone(o.CacheFrom)
+	}
+	if o.CacheTo != nil {
+		t.CacheTo = slices.Clone(o.CacheTo)
+	}
+	if o.Target != nil {
+		t.Target = o.Target
+	}
+	if o.NoCache != nil {
+		t.NoCache = o.NoCache
+	}
+	if o.Pull != nil {
+		t.Pull = o.Pull
+	}
+}
+
+// Resolve returns the targets which the named targets and groups refer to,
+// in the order in which they are first mentioned, with any settings that
+// they inherit from other targets filled in.  If no names are specified, the
+// DefaultGroup is used.
+func (d *Definition) Resolve(names []string) ([]*Target, error) {
+	if len(names) == 0 {
+		if _, ok := d.Groups[DefaultGroup]; !ok {
+			return nil, fmt.Errorf("no targets specified, and no %q group is defined", DefaultGroup)
+		}
+		names = []string{DefaultGroup}
+	}
+	var targetNames []string
+	var expand func(name string, seen []string) error
+	expand = func(name string, seen []string) error {
+		if slices.Contains(seen, name) {
+			return fmt.Errorf("group %q contains itself", name)
+		}
+		if group, ok := d.Groups[name]; ok {
+			for _, member := range group.Targets {
+				if err := expand(member, append(seen, name)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if _, ok := d.Targets[name]; !ok {
+			return fmt.Errorf("no target or group named %q is defined", name)
+		}
+		if !slices.Contains(targetNames, name) {
+			targetNames = append(targetNames, name)
+		}
+		return nil
+	}
+	for _, name := range names {
+		if err := expand(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	targets := make([]*Target, 0, len(targetNames))
+	for _, name := range targetNames {
+		target, err := d.inherit(name, nil)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// inherit returns a copy of the named target, with the settings it inherits
+// from the targets it names in its Inherits list filled in.
+func (d *Definition) inherit(name string, seen []string) (*Target, error) {
+	if slices.Contains(seen, name) {
+		return nil, fmt.Errorf("target %q inherits from itself", name)
+	}
+	target, ok := d.Targets[name]
+	if !ok {
+		return nil, fmt.Errorf("target %q inherits from %q, which is not defined", seen[len(seen)-1], name)
+	}
+	resolved := &Target{Name: name}
+	for _, parent := range target.Inherits {
+		inherited, err := d.inherit(parent, append(seen, name))
+		if err != nil {
+			return nil, err
+		}
+		resolved.merge(inherited)
+	}
+	resolved.merge(target)
+	resolved.Inherits = nil
+	return resolved, nil
+}
@@ -0,0 +1,160 @@
+package bake
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testHCL = `
+variable "TAG" {
+  default = "latest"
+}
+
+variable "REGISTRY" {
+  default = "registry.example.com"
+}
+
+group "default" {
+  targets = ["app", "tools"]
+}
+
+group "tools" {
+  targets = ["cli", "app"]
+}
+
+target "base" {
+  context = "src"
+  args = {
+    GO_VERSION = "1.25"
+    CGO = "0"
+  }
+  labels = { team = "builders" }
+  platforms = ["linux/amd64"]
+}
+
+target "app" {
+  inherits = ["base"]
+  dockerfile = "Containerfile.app"
+  args = { CGO = "1" }
+  tags = ["${REGISTRY}/app:${TAG}", lower("${REGISTRY}/APP:stable")]
+  no-cache = true
+}
+
+target "cli" {
+  inherits = ["app"]
+  target = "cli"
+  tags = ["${REGISTRY}/cli:${TAG}"]
+}
+`
+
+func lookupEnv(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
+
+func TestResolve(t *testing.T) {
+	definition, err := parse([]string{"docker-bake.hcl"}, [][]byte{[]byte(testHCL)}, lookupEnv(map[string]string{"TAG": "v1"}))
+	require.NoError(t, err)
+
+	targets, err := definition.Resolve(nil)
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+	app, cli := targets[0], targets[1]
+
+	assert.Equal(t, "app", app.Name)
+	require.NotNil(t, app.Context)
+	assert.Equal(t, "src", *app.Context)
+	require.NotNil(t, app.Dockerfile)
+	assert.Equal(t, "Containerfile.app", *app.Dockerfile)
+	assert.Equal(t, map[string]string{"GO_VERSION": "1.25", "CGO": "1"}, app.Args)
+	assert.Equal(t, map[string]string{"team": "builders"}, app.Labels)
+	assert.Equal(t, []string{"registry.example.com/app:v1", "registry.example.com/app:stable"}, app.Tags)
+	assert.Equal(t, []string{"linux/amd64"}, app.Platforms)
+	require.NotNil(t, app.NoCache)
+	assert.True(t, *app.NoCache)
+	assert.Nil(t, app.Target)
+	assert.Nil(t, app.Inherits)
+
+	assert.Equal(t, "cli", cli.Name)
+	require.NotNil(t, cli.Target)
+	assert.Equal(t, "cli", *cli.Target)
+	assert.Equal(t, []string{"registry.example.com/cli:v1"}, cli.Tags)
+	assert.Equal(t, app.Args, cli.Args)
+
+	targets, err = definition.Resolve([]string{"cli"})
+	require.NoError(t, err)
+	require.Len(t, targets, 1)
+	assert.Equal(t, "cli", targets[0].Name)
+
+	_, err = definition.Resolve([]string{"missing"})
+	assert.ErrorContains(t, err, `no target or group named "missing"`)
+}
+
+func TestParseJSONAndOverrides(t *testing.T) {
+	base := `{
+  "variable": {"TAG": {"default": "dev"}},
+  "target": {
+    "app": {"context": ".", "tags": ["app:${TAG}"], "args": {"A": "1"}, "pull": true}
+  }
+}`
+	override := `
+target "app" {
+  args = { B = "2" }
+  output = ["type=local,dest=out"]
+}
+`
+	definition, err := parse([]string{"docker-bake.json", "docker-bake.override.hcl"}, [][]byte{[]byte(base), []byte(override)}, lookupEnv(nil))
+	require.NoError(t, err)
+	targets, err := definition.Resolve([]string{"app"})
+	require.NoError(t, err)
+	require.Len(t, targets, 1)
+	app := targets[0]
+	assert.Equal(t, []string{"app:dev"}, app.Tags)
+	assert.Equal(t, map[string]string{"A": "1", "B": "2"}, app.Args)
+	assert.Equal(t, []string{"type=local,dest=out"}, app.Outputs)
+	require.NotNil(t, app.Pull)
+	assert.True(t, *app.Pull)
+
+	_, err = definition.Resolve(nil)
+	assert.ErrorContains(t, err, `no "default" group`)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, contents, resolve, message string
+	}{
+		{"unknown attribute", `target "a" { frobnicate = true }`, "a", "Unsupported argument"},
+		{"syntax", `target "a" {`, "a", "parsing definition file"},
+		{"undefined variable", `target "a" { tags = ["${NOPE}"] }`, "a", "Unknown variable"},
+		{"inheritance loop", "target \"a\" {\n inherits = [\"b\"]\n}\ntarget \"b\" {\n inherits = [\"a\"]\n}", "a", "inherits from itself"},
+		{"missing parent", `target "a" { inherits = ["b"] }`, "a", `inherits from "b", which is not defined`},
+		{"group loop", `group "g" { targets = ["g"] }`, "g", "contains itself"},
+		{"group and target", "group \"a\" {\n targets = []\n}\ntarget \"a\" {}", "a", "both a group and a target"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			definition, err := parse([]string{"docker-bake.hcl"}, [][]byte{[]byte(tc.contents)}, lookupEnv(nil))
+			if err == nil {
+				_, err = definition.Resolve([]string{tc.resolve})
+			}
+			assert.ErrorContains(t, err, tc.message)
+		})
+	}
+}
+
+func TestFindFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := FindFiles(dir)
+	assert.Error(t, err)
+	for _, name := range []string{"docker-bake.hcl", "docker-bake.json"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o644))
+	}
+	files, err := FindFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "docker-bake.json"), filepath.Join(dir, "docker-bake.hcl")}, files)
+}
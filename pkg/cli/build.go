@@ -45,7 +45,11 @@ const (
 	PullPushRetryDelay = 2 * time.Second
 )
 
-// GenBuildOptions translates command line flags into a BuildOptions structure
+// GenBuildOptions translates command line flags into a BuildOptions structure.
+// If --sign-by-sigstore was used, the returned structure's Signers field holds
+// a signer which the caller must close when it is done with it.  Signers are
+// only created after every other option has been validated, so none need to
+// be closed if an error is returned.
 func GenBuildOptions(c *cobra.Command, inputArgs []string, iopts BuildOptions) (define.BuildOptions, []string, []string, error) {
 	options := define.BuildOptions{}
 
@@ -130,6 +134,13 @@ func GenBuildOptions(c *cobra.Command, inputArgs []string, iopts BuildOptions) (
 		}
 	}
 
+	onRunFailure := define.RunFailurePolicy(iopts.OnFailure)
+	switch onRunFailure {
+	case "", define.RunFailureAbort, define.RunFailureShell:
+	default:
+		return options, nil, nil, fmt.Errorf("unrecognized value %q for --on-failure, must be %q or %q", iopts.OnFailure, define.RunFailureAbort, define.RunFailureShell)
+	}
+
 	containerfiles := getContainerfiles(iopts.File)
 	format, err := GetFormat(iopts.Format)
 	if err != nil {
@@ -399,7 +410,10 @@ func GenBuildOptions(c *cobra.Command, inputArgs []string, iopts BuildOptions) (
 		sbomScanOptions = append(sbomScanOptions, *sbomScanOption)
 	}
 
-	var compatVolumes, createdAnnotation, inheritAnnotations, inheritLabels, skipUnusedStages types.OptionalBool
+	var compatDockerConfig, compatVolumes, createdAnnotation, inheritAnnotations, inheritLabels, skipUnusedStages types.OptionalBool
+	if c.Flag("compat-docker-config").Changed {
+		compatDockerConfig = types.NewOptionalBool(iopts.CompatDockerConfig)
+	}
 	if c.Flag("compat-volumes").Changed {
 		compatVolumes = types.NewOptionalBool(iopts.CompatVolumes)
 	}
@@ -417,90 +431,101 @@ func GenBuildOptions(c *cobra.Command, inputArgs []string, iopts BuildOptions) (
 	}
 
 	options = define.BuildOptions{
-		AddCapabilities:         iopts.CapAdd,
-		AdditionalBuildContexts: additionalBuildContext,
-		AdditionalTags:          tags,
-		AllPlatforms:            iopts.AllPlatforms,
-		Annotations:             iopts.Annotation,
-		Architecture:            systemContext.ArchitectureChoice,
-		Args:                    args,
-		BlobDirectory:           iopts.BlobCache,
-		BuildOutputs:            iopts.BuildOutputs,
-		CacheFrom:               cacheFrom,
-		CacheTo:                 cacheTo,
-		CacheTTL:                cacheTTL,
-		CDIConfigDir:            iopts.CDIConfigDir,
-		CompatVolumes:           compatVolumes,
-		ConfidentialWorkload:    confidentialWorkloadOptions,
-		CPPFlags:                iopts.CPPFlags,
-		CommonBuildOpts:         commonOpts,
-		Compression:             compression,
-		CompressionFormat:       compressionFormat,
-		CompressionLevel:        compressionLevel,
-		ForceCompressionFormat:  forceCompressionFormat,
-		ConfigureNetwork:        networkPolicy,
-		ContextDirectory:        contextDir,
-		CreatedAnnotation:       createdAnnotation,
-		Devices:                 iopts.Devices,
-		DropCapabilities:        iopts.CapDrop,
-		Err:                     stderr,
-		Excludes:                excludes,
-		ForceRmIntermediateCtrs: iopts.ForceRm,
-		From:                    iopts.From,
-		GroupAdd:                iopts.GroupAdd,
-		IDMappingOptions:        idmappingOptions,
-		IIDFile:                 iopts.Iidfile,
-		IIDFileRaw:              iopts.IidfileRaw,
-		IgnoreFile:              iopts.IgnoreFile,
-		In:                      stdin,
-		InheritLabels:           inheritLabels,
-		InheritAnnotations:      inheritAnnotations,
-		Isolation:               isolation,
-		Jobs:                    &iopts.Jobs,
-		Labels:                  iopts.Label,
-		LayerLabels:             iopts.LayerLabel,
-		Layers:                  layers,
-		LogFile:                 iopts.Logfile,
-		LogRusage:               iopts.LogRusage,
-		LogSplitByPlatform:      iopts.LogSplitByPlatform,
-		Manifest:                iopts.Manifest,
-		MetadataFile:            iopts.MetadataFile,
-		MaxPullPushRetries:      iopts.Retry,
-		NamespaceOptions:        namespaceOptions,
-		NoCache:                 iopts.NoCache,
-		OS:                      systemContext.OSChoice,
-		OSFeatures:              iopts.OSFeatures,
-		OSVersion:               iopts.OSVersion,
-		OciDecryptConfig:        decryptConfig,
-		Out:                     stdout,
-		Output:                  outputSpec,
-		OutputFormat:            format,
-		Platforms:               platforms,
-		PullPolicy:              pullPolicy,
-		Quiet:                   iopts.Quiet,
-		RemoveIntermediateCtrs:  iopts.Rm,
-		ReportWriter:            reporter,
-		RewriteTimestamp:        iopts.RewriteTimestamp,
-		Runtime:                 iopts.Runtime,
-		RuntimeArgs:             runtimeFlags,
-		RusageLogFile:           iopts.RusageLogFile,
-		SaveStages:              iopts.SaveStages,
-		SBOMScanOptions:         sbomScanOptions,
-		SignBy:                  iopts.SignBy,
-		SignaturePolicyPath:     iopts.SignaturePolicy,
-		SourcePolicyFile:        iopts.SourcePolicyFile,
-		SkipUnusedStages:        skipUnusedStages,
-		SourceDateEpoch:         sourceDateEpoch,
-		Squash:                  iopts.Squash,
-		StageLabels:             iopts.StageLabels,
-		SystemContext:           systemContext,
-		Target:                  iopts.Target,
-		Timestamp:               timestamp,
-		TransientMounts:         iopts.Volumes,
-		TransientRunMounts:      iopts.TransientRunMounts,
-		UnsetEnvs:               iopts.UnsetEnvs,
-		UnsetLabels:             iopts.UnsetLabels,
-		UnsetAnnotations:        iopts.UnsetAnnotations,
+		AddCapabilities:              iopts.CapAdd,
+		AdditionalBuildContexts:      additionalBuildContext,
+		AdditionalTags:               tags,
+		AllPlatforms:                 iopts.AllPlatforms,
+		Annotations:                  iopts.Annotation,
+		Architecture:                 systemContext.ArchitectureChoice,
+		Args:                         args,
+		BlobDirectory:                iopts.BlobCache,
+		BreakAt:                      iopts.BreakAt,
+		BuildOutputs:                 iopts.BuildOutputs,
+		CacheFrom:                    cacheFrom,
+		CacheTo:                      cacheTo,
+		CacheTTL:                     cacheTTL,
+		CDIConfigDir:                 iopts.CDIConfigDir,
+		CompatDockerConfig:           compatDockerConfig,
+		CompatVolumes:                compatVolumes,
+		ConfidentialWorkload:         confidentialWorkloadOptions,
+		CPPFlags:                     iopts.CPPFlags,
+		CommonBuildOpts:              commonOpts,
+		Compression:                  compression,
+		CompressionFormat:            compressionFormat,
+		CompressionLevel:             compressionLevel,
+		ForceCompressionFormat:       forceCompressionFormat,
+		ConfigureNetwork:             networkPolicy,
+		ContextDirectory:             contextDir,
+		CreatedAnnotation:            createdAnnotation,
+		Devices:                      iopts.Devices,
+		DropCapabilities:             iopts.CapDrop,
+		Err:                          stderr,
+		Excludes:                     excludes,
+		ForceRmIntermediateCtrs:      iopts.ForceRm,
+		From:                         iopts.From,
+		Frontend:                     iopts.Frontend,
+		GroupAdd:                     iopts.GroupAdd,
+		HardlinkContext:              iopts.HardlinkContext,
+		IDMappingOptions:             idmappingOptions,
+		IIDFile:                      iopts.Iidfile,
+		IIDFileRaw:                   iopts.IidfileRaw,
+		IgnoreFile:                   iopts.IgnoreFile,
+		In:                           stdin,
+		InheritLabels:                inheritLabels,
+		InheritAnnotations:           inheritAnnotations,
+		Isolation:                    isolation,
+		Jobs:                         &iopts.Jobs,
+		Labels:                       iopts.Label,
+		LayerLabels:                  iopts.LayerLabel,
+		Layers:                       layers,
+		LogFile:                      iopts.Logfile,
+		LogRusage:                    iopts.LogRusage,
+		LogSplitByPlatform:           iopts.LogSplitByPlatform,
+		Manifest:                     iopts.Manifest,
+		MetadataFile:                 iopts.MetadataFile,
+		MaxPullPushRetries:           iopts.Retry,
+		NamespaceOptions:             namespaceOptions,
+		NoCache:                      iopts.NoCache,
+		NoClone:                      iopts.NoClone,
+		OS:                           systemContext.OSChoice,
+		OSFeatures:                   iopts.OSFeatures,
+		OSVersion:                    iopts.OSVersion,
+		OciDecryptConfig:             decryptConfig,
+		OnRunFailure:                 onRunFailure,
+		Out:                          stdout,
+		Output:                       outputSpec,
+		OutputFormat:                 format,
+		Platforms:                    platforms,
+		PrintGraph:                   iopts.PrintGraph,
+		PullPolicy:                   pullPolicy,
+		Quiet:                        iopts.Quiet,
+		RemoveIntermediateCtrs:       iopts.Rm,
+		ReportWriter:                 reporter,
+		RewriteTimestamp:             iopts.RewriteTimestamp,
+		Runtime:                      iopts.Runtime,
+		RuntimeArgs:                  runtimeFlags,
+		RusageLogFile:                iopts.RusageLogFile,
+		SaveStages:                   iopts.SaveStages,
+		SBOMScanOptions:              sbomScanOptions,
+		SignBy:                       iopts.SignBy,
+		SignBySigstorePrivateKeyFile: iopts.SignBySigstoreKey,
+		SignaturePolicyPath:          iopts.SignaturePolicy,
+		SourceImage:                  iopts.SourceImage,
+		SourceImagePackages:          iopts.SourceImagePackages,
+		SourcePolicyFile:             iopts.SourcePolicyFile,
+		SkipUnusedStages:             skipUnusedStages,
+		SourceDateEpoch:              sourceDateEpoch,
+		Squash:                       iopts.Squash,
+		StageLabels:                  iopts.StageLabels,
+		Step:                         iopts.Step,
+		SystemContext:                systemContext,
+		Target:                       iopts.Target,
+		Timestamp:                    timestamp,
+		TransientMounts:              iopts.Volumes,
+		TransientRunMounts:           iopts.TransientRunMounts,
+		UnsetEnvs:                    iopts.UnsetEnvs,
+		UnsetLabels:                  iopts.UnsetLabels,
+		UnsetAnnotations:             iopts.UnsetAnnotations,
 	}
 	if iopts.RetryDelay != "" {
 		options.PullPushRetryDelay, err = time.ParseDuration(iopts.RetryDelay)
@@ -517,6 +542,13 @@ func GenBuildOptions(c *cobra.Command, inputArgs []string, iopts BuildOptions) (
 
 	options.Envs = LookupEnvVarReferences(iopts.Envs, os.Environ())
 
+	// this may prompt for a passphrase, so it's done last, and the
+	// caller is responsible for closing any signers we create here
+	options.SignSigstorePrivateKeyPassphrase, options.Signers, err = SigstoreSigning(iopts.SignBySigstoreKey, iopts.SignPassphraseFile, iopts.SignBySigstore)
+	if err != nil {
+		return options, nil, nil, err
+	}
+
 	return options, containerfiles, removeAll, nil
 }
 
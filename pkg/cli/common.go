@@ -60,6 +60,7 @@ type BudResults struct {
 	AllPlatforms           bool
 	Annotation             []string
 	Authfile               string
+	BreakAt                []string
 	BuildArg               []string
 	BuildArgFile           []string
 	BuildContext           []string
@@ -79,6 +80,7 @@ type BudResults struct {
 	File                   []string
 	Format                 string
 	From                   string
+	Frontend               string
 	Iidfile                string
 	IidfileRaw             string
 	InheritLabels          bool
@@ -92,9 +94,13 @@ type BudResults struct {
 	NoHostname             bool
 	NoHosts                bool
 	NoCache                bool
+	NoClone                bool
+	HardlinkContext        bool
 	Timestamp              int64
 	OmitHistory            bool
+	OnFailure              string
 	OCIHooksDir            []string
+	PrintGraph             string
 	Pull                   string
 	PullAlways             bool
 	PullNever              bool
@@ -113,8 +119,12 @@ type BudResults struct {
 	SbomImgPurlOutput      string
 	Secrets                []string
 	SSH                    []string
+	Step                   bool
 	SignaturePolicy        string
 	SignBy                 string
+	SignBySigstore         string
+	SignBySigstoreKey      string
+	SignPassphraseFile     string
 	Squash                 bool
 	SkipUnusedStages       bool
 	Stdin                  bool
@@ -134,10 +144,13 @@ type BudResults struct {
 	CWOptions              string
 	SBOMOptions            []string
 	CompatVolumes          bool
+	CompatDockerConfig     bool
 	SourceDateEpoch        string
 	RewriteTimestamp       bool
 	CreatedAnnotation      bool
 	SourcePolicyFile       string
+	SourceImage            string
+	SourceImagePackages    []string
 	TransientRunMounts     []string
 }
 
@@ -238,6 +251,7 @@ func GetBudFlags(flags *BudResults) pflag.FlagSet {
 	fs.StringArrayVar(&flags.Annotation, "annotation", []string{}, "set metadata for an image (default [])")
 	fs.StringVar(&flags.Authfile, "authfile", "", "path of the authentication file.")
 	fs.StringArrayVar(&flags.OCIHooksDir, "hooks-dir", []string{}, "set the OCI hooks directory path (may be set multiple times)")
+	fs.StringArrayVar(&flags.BreakAt, "break-at", []string{}, "pause before instructions which match `breakpoint` (an instruction prefix, line:N, or stage:NAME)")
 	fs.StringArrayVar(&flags.BuildArg, "build-arg", []string{}, "`argument=value` to supply to the builder")
 	fs.StringArrayVar(&flags.BuildArgFile, "build-arg-file", []string{}, "`argfile.conf` containing lines of argument=value to supply to the builder")
 	fs.StringArrayVar(&flags.BuildContext, "build-context", []string{}, "`argument=value` to supply additional build context to the builder")
@@ -247,6 +261,7 @@ func GetBudFlags(flags *BudResults) pflag.FlagSet {
 	fs.StringVar(&flags.CertDir, "cert-dir", "", "use certificates at the specified path to access the registry")
 	fs.BoolVar(&flags.Compress, "compress", false, "this is a legacy option, which has no effect on the image")
 	fs.BoolVar(&flags.CompatVolumes, "compat-volumes", false, "preserve the contents of VOLUMEs during RUN instructions")
+	fs.BoolVar(&flags.CompatDockerConfig, "compat-docker-config", false, "record settings which only the docker format has a place for in OCI images")
 	fs.BoolVar(&flags.InheritLabels, "inherit-labels", true, "inherit the labels from the base image or base stages.")
 	fs.BoolVar(&flags.InheritAnnotations, "inherit-annotations", true, "inherit the annotations from the base image or base stages.")
 	fs.StringArrayVar(&flags.CPPFlags, "cpp-flag", []string{}, "set additional flag to pass to C preprocessor (cpp)")
@@ -260,6 +275,8 @@ func GetBudFlags(flags *BudResults) pflag.FlagSet {
 	fs.BoolVar(&flags.DisableContentTrust, "disable-content-trust", false, "this is a Docker specific option and is a NOOP")
 	fs.StringArrayVar(&flags.Envs, "env", []string{}, "set environment variable for the image")
 	fs.StringVar(&flags.From, "from", "", "image name used to replace the value in the first FROM instruction in the Containerfile")
+	fs.StringVar(&flags.Frontend, "frontend", "", "`name` of the frontend which reads the build definitions (\"dockerfile\" or \"yaml\")")
+	fs.BoolVar(&flags.HardlinkContext, "hardlink-context", false, "hard link files from the build context instead of copying them when possible (the context must not be modified later)")
 	fs.StringVar(&flags.IgnoreFile, "ignorefile", "", "path to an alternate .dockerignore file")
 	fs.StringSliceVarP(&flags.File, "file", "f", []string{}, "`pathname or URL` of a Dockerfile")
 	fs.StringVar(&flags.Format, "format", DefaultFormat(), "`format` of the built image's manifest and metadata. Use BUILDAH_FORMAT environment variable to override.")
@@ -285,11 +302,14 @@ func GetBudFlags(flags *BudResults) pflag.FlagSet {
 	fs.StringVar(&flags.Manifest, "manifest", "", "add the image to the specified manifest list. Creates manifest list if it does not exist")
 	fs.StringVar(&flags.MetadataFile, "metadata-file", "", "`file` to write metadata about the image to")
 	fs.BoolVar(&flags.NoCache, "no-cache", false, "do not use existing cached images for the container build. Build from the start with a new set of cached layers.")
+	fs.BoolVar(&flags.NoClone, "no-clone", false, "do not reflink files from the build context, always copy their contents")
 	fs.BoolVar(&flags.NoHostname, "no-hostname", false, "do not create new /etc/hostname file for RUN instructions, use the one from the base image.")
 	fs.BoolVar(&flags.NoHosts, "no-hosts", false, "do not create new /etc/hosts file for RUN instructions, use the one from the base image.")
 	fs.String("os", runtime.GOOS, "set the OS to the provided value instead of the current operating system of the host")
 	fs.StringArrayVar(&flags.OSFeatures, "os-feature", []string{}, "set required OS `feature` for the target image in addition to values from the base image")
 	fs.StringVar(&flags.OSVersion, "os-version", "", "set required OS `version` for the target image instead of the value from the base image")
+	fs.StringVar(&flags.PrintGraph, "print-graph", "", "print the graph of the build's stages in `format` (\"dot\" or \"json\") instead of building")
+	fs.Lookup("print-graph").NoOptDefVal = "dot" // treat a --print-graph with no argument like --print-graph=dot
 	fs.StringVar(&flags.Pull, "pull", "missing", `pull base and SBOM scanner images from the registry. Values:
 always:  pull base and SBOM scanner images even if the named images are present in store.
 missing: pull base and SBOM scanner images if the named images are not present in store.
@@ -306,6 +326,7 @@ newer:   only pull base and SBOM scanner images when newer images exist on the r
 	}
 	fs.BoolVarP(&flags.Quiet, "quiet", "q", false, "refrain from announcing build instructions and image read/write progress")
 	fs.BoolVar(&flags.OmitHistory, "omit-history", false, "omit build history information from built image")
+	fs.StringVar(&flags.OnFailure, "on-failure", string(define.RunFailureAbort), "`policy` for RUN instructions which fail: 'abort' the build, or start a debugging 'shell' in the container")
 	fs.BoolVar(&flags.IdentityLabel, "identity-label", true, "add default identity label")
 	fs.BoolVar(&flags.Rm, "rm", true, "remove intermediate containers after a successful build")
 	// "runtime" definition moved to avoid name collision in podman build.  Defined in cmd/buildah/build.go.
@@ -321,11 +342,16 @@ newer:   only pull base and SBOM scanner images when newer images exist on the r
 	fs.StringVar(&flags.SbomImgPurlOutput, "sbom-image-purl-output", "", "add scan results to image as `path`")
 	fs.StringArrayVar(&flags.Secrets, "secret", []string{}, "secret file to expose to the build")
 	fs.StringVar(&flags.SignBy, "sign-by", "", "sign the image using a GPG key with the specified `FINGERPRINT`")
+	fs.StringVar(&flags.SignBySigstore, "sign-by-sigstore", "", "sign the image using a sigstore parameter file at `PATH`")
+	fs.StringVar(&flags.SignBySigstoreKey, "sign-by-sigstore-private-key", "", "sign the image using a sigstore private key at `PATH`")
+	fs.StringVar(&flags.SignPassphraseFile, "sign-passphrase-file", "", "read a passphrase for signing an image from `PATH`")
 	fs.StringVar(&flags.SignaturePolicy, "signature-policy", "", "`pathname` of signature policy file (not usually used)")
 	if err := fs.MarkHidden("signature-policy"); err != nil {
 		panic(fmt.Sprintf("error marking the signature-policy flag as hidden: %v", err))
 	}
 	fs.StringVar(&flags.SourcePolicyFile, "source-policy-file", "", "`pathname` of source policy file for controlling source references during build")
+	fs.StringVar(&flags.SourceImage, "source-image", "", "after building, push a source image containing the build context and ADD sources to `image`")
+	fs.StringArrayVar(&flags.SourceImagePackages, "source-image-packages", []string{}, "add source packages of packages listed in SBOM data to the source image, fetching them from `location`")
 	fs.BoolVar(&flags.SkipUnusedStages, "skip-unused-stages", true, "skips stages in multi-stage builds which do not affect the final target")
 	sourceDateEpochUsageDefault := ", defaults to current time"
 	if v := os.Getenv(internal.SourceDateEpochName); v != "" {
@@ -334,8 +360,9 @@ newer:   only pull base and SBOM scanner images when newer images exist on the r
 	fs.StringVar(&flags.SourceDateEpoch, "source-date-epoch", os.Getenv(internal.SourceDateEpochName), "set new timestamps in image info to `seconds` after the epoch"+sourceDateEpochUsageDefault)
 	fs.BoolVar(&flags.RewriteTimestamp, "rewrite-timestamp", false, "set timestamps in layers to no later than the value for --source-date-epoch")
 	fs.BoolVar(&flags.Squash, "squash", false, "squash all image layers into a single layer")
-	fs.StringArrayVar(&flags.SSH, "ssh", []string{}, "SSH agent socket or keys to expose to the build. (format: default|<id>[=<socket>|<key>[,<key>]])")
+	fs.StringArrayVar(&flags.SSH, "ssh", []string{}, "SSH agent socket or keys to expose to the build. (format: default|<id>[=<socket>|<key>[,<key>]][,known_hosts=<path>])")
 	fs.BoolVar(&flags.Stdin, "stdin", false, "pass stdin into containers")
+	fs.BoolVar(&flags.Step, "step", false, "pause before every instruction")
 	fs.StringArrayVarP(&flags.Tag, "tag", "t", []string{}, "tagged `name` to apply to the built image")
 	fs.StringArrayVarP(&flags.BuildOutputs, "output", "o", nil, "output destination (format: type=local,dest=path)")
 	fs.StringVar(&flags.Target, "target", "", "set the target build stage to build")
@@ -354,6 +381,7 @@ func GetBudFlagsCompletions() commonComp.FlagCompletions {
 	flagCompletion["annotation"] = commonComp.AutocompleteNone
 	flagCompletion["arch"] = commonComp.AutocompleteNone
 	flagCompletion["authfile"] = commonComp.AutocompleteDefault
+	flagCompletion["break-at"] = commonComp.AutocompleteNone
 	flagCompletion["build-arg"] = commonComp.AutocompleteNone
 	flagCompletion["build-arg-file"] = commonComp.AutocompleteDefault
 	flagCompletion["build-context"] = commonComp.AutocompleteNone
@@ -370,6 +398,7 @@ func GetBudFlagsCompletions() commonComp.FlagCompletions {
 	flagCompletion["file"] = commonComp.AutocompleteDefault
 	flagCompletion["format"] = commonComp.AutocompleteNone
 	flagCompletion["from"] = commonComp.AutocompleteDefault
+	flagCompletion["frontend"] = commonComp.AutocompleteNone
 	flagCompletion["hooks-dir"] = commonComp.AutocompleteNone
 	flagCompletion["ignorefile"] = commonComp.AutocompleteDefault
 	flagCompletion["iidfile"] = commonComp.AutocompleteDefault
@@ -380,11 +409,13 @@ func GetBudFlagsCompletions() commonComp.FlagCompletions {
 	flagCompletion["logfile"] = commonComp.AutocompleteDefault
 	flagCompletion["manifest"] = commonComp.AutocompleteDefault
 	flagCompletion["metadata-file"] = commonComp.AutocompleteDefault
+	flagCompletion["on-failure"] = commonComp.AutocompleteNone
 	flagCompletion["mount"] = commonComp.AutocompleteNone
 	flagCompletion["os"] = commonComp.AutocompleteNone
 	flagCompletion["os-feature"] = commonComp.AutocompleteNone
 	flagCompletion["os-version"] = commonComp.AutocompleteNone
 	flagCompletion["output"] = commonComp.AutocompleteNone
+	flagCompletion["print-graph"] = commonComp.AutocompleteNone
 	flagCompletion["pull"] = commonComp.AutocompleteDefault
 	flagCompletion["runtime-flag"] = commonComp.AutocompleteNone
 	flagCompletion["sbom"] = commonComp.AutocompleteNone
@@ -397,8 +428,13 @@ func GetBudFlagsCompletions() commonComp.FlagCompletions {
 	flagCompletion["sbom-image-purl-output"] = commonComp.AutocompleteNone
 	flagCompletion["secret"] = commonComp.AutocompleteNone
 	flagCompletion["sign-by"] = commonComp.AutocompleteNone
+	flagCompletion["sign-by-sigstore"] = commonComp.AutocompleteDefault
+	flagCompletion["sign-by-sigstore-private-key"] = commonComp.AutocompleteDefault
+	flagCompletion["sign-passphrase-file"] = commonComp.AutocompleteDefault
 	flagCompletion["signature-policy"] = commonComp.AutocompleteNone
 	flagCompletion["source-policy-file"] = commonComp.AutocompleteDefault
+	flagCompletion["source-image"] = commonComp.AutocompleteNone
+	flagCompletion["source-image-packages"] = commonComp.AutocompleteDefault
 	flagCompletion["ssh"] = commonComp.AutocompleteNone
 	flagCompletion["source-date-epoch"] = commonComp.AutocompleteNone
 	flagCompletion["tag"] = commonComp.AutocompleteNone
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	imagecli "go.podman.io/image/v5/pkg/cli"
+	"go.podman.io/image/v5/pkg/cli/sigstore"
+	"go.podman.io/image/v5/signature/signer"
+	"golang.org/x/term"
+)
+
+// SigstoreSigning reads the passphrase for a sigstore private key, if
+// privateKeyFile is set, and creates a signer using a sigstore parameter file,
+// if paramFile is set.  The passphrase is read from passphraseFile if it is
+// set, or prompted for if standard input is a terminal.  The caller should
+// close the returned signers once it's done with them.
+// Parameter files are parsed by the same package that podman and skopeo use,
+// so that settings which request a certificate from Fulcio (after an OIDC
+// login) or an upload to Rekor work the same way for all of them; that's
+// where the dependencies on those clients come from.
+func SigstoreSigning(privateKeyFile, passphraseFile, paramFile string) ([]byte, []*signer.Signer, error) {
+	if passphraseFile != "" && privateKeyFile == "" && paramFile == "" {
+		return nil, nil, errors.New("--sign-passphrase-file is only meaningful with --sign-by-sigstore-private-key or --sign-by-sigstore")
+	}
+	var passphrase []byte
+	if privateKeyFile != "" {
+		p, err := readSigstorePassphrase(privateKeyFile, passphraseFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		passphrase = []byte(p)
+	}
+	var signers []*signer.Signer
+	if paramFile != "" {
+		s, err := sigstore.NewSignerFromParameterFile(paramFile, &sigstore.Options{
+			PrivateKeyPassphrasePrompt: func(keyFile string) (string, error) {
+				return readSigstorePassphrase(keyFile, passphraseFile)
+			},
+			Stdin:  os.Stdin,
+			Stdout: os.Stdout,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating signer from %q: %w", paramFile, err)
+		}
+		signers = append(signers, s)
+	}
+	return passphrase, signers, nil
+}
+
+// readSigstorePassphrase reads the passphrase for keyFile from passphraseFile,
+// or from the terminal if passphraseFile isn't set.
+func readSigstorePassphrase(keyFile, passphraseFile string) (string, error) {
+	if passphraseFile != "" {
+		return imagecli.ReadPassphraseFile(passphraseFile)
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("reading passphrase for %q: standard input is not a terminal, use --sign-passphrase-file", keyFile)
+	}
+	fmt.Fprintf(os.Stderr, "Key Passphrase for %s: ", keyFile)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase for %q: %w", keyFile, err)
+	}
+	return string(passphrase), nil
+}
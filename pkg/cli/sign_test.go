@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigstoreSigning(t *testing.T) {
+	passphraseFile := filepath.Join(t.TempDir(), "passphrase")
+	require.NoError(t, os.WriteFile(passphraseFile, []byte("secret\nignored\n"), 0o600))
+
+	passphrase, signers, err := SigstoreSigning("", "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, passphrase)
+	assert.Empty(t, signers)
+
+	_, _, err = SigstoreSigning("", passphraseFile, "")
+	assert.ErrorContains(t, err, "only meaningful with")
+
+	passphrase, signers, err = SigstoreSigning("key.private", passphraseFile, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("secret"), passphrase)
+	assert.Empty(t, signers)
+
+	_, _, err = SigstoreSigning("", passphraseFile, filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestGenBuildOptionsSigningLast(t *testing.T) {
+	br := BuildOptions{
+		LayerResults:      &LayerResults{},
+		BudResults:        &BudResults{},
+		UserNSResults:     &UserNSResults{},
+		FromAndBudResults: &FromAndBudResults{},
+		NameSpaceResults:  &NameSpaceResults{},
+	}
+	c := &cobra.Command{Use: "build"}
+	budFlags := GetBudFlags(br.BudResults)
+	layerFlags := GetLayerFlags(br.LayerResults)
+	fromAndBudFlags, err := GetFromAndBudFlags(br.FromAndBudResults, br.UserNSResults, br.NameSpaceResults)
+	require.NoError(t, err)
+	c.Flags().AddFlagSet(&budFlags)
+	c.Flags().AddFlagSet(&layerFlags)
+	c.Flags().AddFlagSet(&fromAndBudFlags)
+	contextDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "Containerfile"), []byte("FROM scratch\n"), 0o644))
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+
+	// signers aren't set up if another option is bad
+	require.NoError(t, c.Flags().Parse([]string{"--retry-delay=soon", "--sign-by-sigstore=" + missing}))
+	_, _, _, err = GenBuildOptions(c, []string{contextDir}, br)
+	assert.ErrorContains(t, err, "--retry-delay")
+
+	require.NoError(t, c.Flags().Parse([]string{"--retry-delay=1s"}))
+	_, _, _, err = GenBuildOptions(c, []string{contextDir}, br)
+	assert.ErrorContains(t, err, missing)
+}
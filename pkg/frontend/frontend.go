@@ -0,0 +1,156 @@
+// Package frontend converts build definitions into the syntax trees of
+// equivalent Containerfiles, which imagebuildah then builds.
+//
+// Frontends are registered by name.  The "dockerfile" frontend, which
+// parses Containerfiles and Dockerfiles, and the "yaml" frontend, which
+// reads a declarative description of an image, are always registered.  A
+// build definition can select the frontend which reads it using a "syntax"
+// parser directive on its first line, for example "# syntax=yaml".
+package frontend
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/openshift/imagebuilder"
+	"github.com/openshift/imagebuilder/dockerfile/parser"
+)
+
+// Frontend reads build definitions.
+type Frontend interface {
+	// Parse parses the contents of the build definition in the named
+	// file, and returns the syntax tree of an equivalent Containerfile.
+	Parse(name string, contents []byte) (*parser.Node, error)
+}
+
+// Func adapts a function to the Frontend interface.
+type Func func(name string, contents []byte) (*parser.Node, error)
+
+// Parse calls f.
+func (f Func) Parse(name string, contents []byte) (*parser.Node, error) {
+	return f(name, contents)
+}
+
+const (
+	// Dockerfile is the name of the frontend which parses Containerfiles
+	// and Dockerfiles, and which is used when none is specified.
+	Dockerfile = "dockerfile"
+	// YAML is the name of the frontend which reads declarative YAML
+	// build definitions.
+	YAML = "yaml"
+)
+
+var (
+	frontendsLock sync.Mutex
+	frontends     = map[string]Frontend{
+		Dockerfile: Func(func(_ string, contents []byte) (*parser.Node, error) {
+			return imagebuilder.ParseDockerfile(bytes.NewReader(contents))
+		}),
+		YAML: Func(parseYAML),
+	}
+
+	directiveRegexp = regexp.MustCompile(`^#\s*([a-zA-Z][a-zA-Z0-9]*)\s*=\s*(.+?)\s*$`)
+)
+
+// Register makes a frontend available using the specified name.
+func Register(name string, frontend Frontend) error {
+	if name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return fmt.Errorf("invalid frontend name %q", name)
+	}
+	frontendsLock.Lock()
+	defer frontendsLock.Unlock()
+	if _, ok := frontends[name]; ok {
+		return fmt.Errorf("a frontend named %q is already registered", name)
+	}
+	frontends[name] = frontend
+	return nil
+}
+
+// Lookup returns the frontend registered with the specified name.
+func Lookup(name string) (Frontend, bool) {
+	frontendsLock.Lock()
+	defer frontendsLock.Unlock()
+	frontend, ok := frontends[name]
+	return frontend, ok
+}
+
+// Names returns the sorted names of the registered frontends.
+func Names() []string {
+	frontendsLock.Lock()
+	defer frontendsLock.Unlock()
+	return slices.Sorted(maps.Keys(frontends))
+}
+
+// Syntax returns the value of the "syntax" parser directive at the start of
+// a build definition, if there is one.
+func Syntax(contents []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		matches := directiveRegexp.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if matches == nil {
+			// directives are only recognized at the very start
+			break
+		}
+		if strings.EqualFold(matches[1], "syntax") {
+			return matches[2]
+		}
+	}
+	return ""
+}
+
+// Select returns the frontend which should read a build definition.  If name
+// is set, the frontend with that name is used.  Otherwise, if the definition
+// starts with a "syntax" parser directive which names a registered frontend,
+// that frontend is used.  Any other "syntax" value, such as the name of a
+// BuildKit frontend image, is ignored, and the Dockerfile frontend is used.
+func Select(name string, contents []byte) (Frontend, error) {
+	if name != "" {
+		frontend, ok := Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown frontend %q (known frontends: %s)", name, strings.Join(Names(), ", "))
+		}
+		return frontend, nil
+	}
+	if syntax := Syntax(contents); syntax != "" {
+		if frontend, ok := Lookup(syntax); ok {
+			return frontend, nil
+		}
+	}
+	frontend, _ := Lookup(Dockerfile)
+	return frontend, nil
+}
+
+// Parse parses the build definitions, each using the frontend which Select()
+// chooses for it, and combines them into one syntax tree.
+func Parse(frontendName string, names []string, contents [][]byte) (*parser.Node, error) {
+	if len(contents) == 0 || len(names) != len(contents) {
+		return nil, errors.New("internal error: mismatched build definition names and contents")
+	}
+	var root *parser.Node
+	for i := range contents {
+		frontend, err := Select(frontendName, contents[i])
+		if err != nil {
+			return nil, err
+		}
+		node, err := frontend.Parse(names[i], contents[i])
+		if err != nil {
+			if i == 0 {
+				return nil, fmt.Errorf("parsing main Dockerfile: %s: %w", names[i], err)
+			}
+			return nil, fmt.Errorf("parsing additional Dockerfile %s: %w", names[i], err)
+		}
+		if root == nil {
+			root = node
+			continue
+		}
+		root.Children = append(root.Children, node.Children...)
+	}
+	return root, nil
+}
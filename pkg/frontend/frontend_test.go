@@ -0,0 +1,155 @@
+package frontend
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openshift/imagebuilder/dockerfile/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// instructions returns the original text of each instruction in the tree.
+func instructions(node *parser.Node) []string {
+	var lines []string
+	for _, child := range node.Children {
+		lines = append(lines, child.Original)
+	}
+	return lines
+}
+
+func TestSyntax(t *testing.T) {
+	for _, testCase := range []struct {
+		contents, syntax string
+	}{
+		{"# syntax=yaml\nbase: scratch\n", "yaml"},
+		{"#syntax = yaml \n", "yaml"},
+		{"# escape=`\n# Syntax=docker/dockerfile:1\nFROM scratch\n", "docker/dockerfile:1"},
+		{"FROM scratch\n# syntax=yaml\n", ""},
+		{"# a comment\n# syntax=yaml\n", ""},
+		{"", ""},
+	} {
+		assert.Equalf(t, testCase.syntax, Syntax([]byte(testCase.contents)), "parsing %q", testCase.contents)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	contents := []byte("# syntax=yaml\nbase: scratch\n")
+	node, err := Parse("", []string{"def.yaml"}, [][]byte{contents})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FROM scratch"}, instructions(node))
+
+	// an explicitly-selected frontend overrides the directive
+	node, err = Parse(Dockerfile, []string{"def.yaml"}, [][]byte{contents})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base: scratch"}, instructions(node))
+
+	// a directive naming an image is ignored
+	node, err = Parse("", []string{"Containerfile"}, [][]byte{[]byte("# syntax=docker/dockerfile:1\nFROM scratch\n")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FROM scratch"}, instructions(node))
+
+	_, err = Select("no-such-frontend", contents)
+	assert.ErrorContains(t, err, `unknown frontend "no-such-frontend"`)
+
+	// definitions which use different frontends are combined
+	node, err = Parse("", []string{"Containerfile", "extra.yaml"}, [][]byte{
+		[]byte("FROM scratch AS first\n"),
+		[]byte("# syntax=yaml\nbase: first\nuser: nobody\n"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FROM scratch AS first", "FROM first", "USER nobody"}, instructions(node))
+
+	_, err = Parse("", []string{"Containerfile", "extra.yaml"}, [][]byte{
+		[]byte("FROM scratch\n"),
+		[]byte("# syntax=yaml\nuser: nobody\n"),
+	})
+	assert.ErrorContains(t, err, "parsing additional Dockerfile extra.yaml")
+}
+
+func TestRegister(t *testing.T) {
+	failing := Func(func(name string, _ []byte) (*parser.Node, error) {
+		return nil, errors.New("not implemented")
+	})
+	require.NoError(t, Register("test-failing", failing))
+	assert.Error(t, Register("test-failing", failing))
+	assert.Error(t, Register("test failing", failing))
+	assert.Contains(t, Names(), "test-failing")
+
+	_, err := Parse("", []string{"def"}, [][]byte{[]byte("# syntax=test-failing\n")})
+	assert.ErrorContains(t, err, "not implemented")
+}
+
+func TestYAML(t *testing.T) {
+	definition := `# syntax=yaml
+base: registry.example.com/base:latest
+packageManager: dnf
+packages: [httpd, "it's"]
+env:
+  B: 'say "$HOME"'
+  A: one
+workdir: /srv
+files:
+  - source: site/
+    destination: /var/www/html/
+    chown: "1000:1000"
+    chmod: "0644"
+run:
+  - echo hello
+  - |
+    set -e
+    echo multi
+labels:
+  org.example.name: web
+expose: ["80", 443/udp]
+user: apache
+entrypoint: [/usr/sbin/httpd, -DFOREGROUND]
+cmd: ["-X"]
+`
+	node, err := Parse("", []string{"def.yaml"}, [][]byte{[]byte(definition)})
+	require.NoError(t, err)
+	lines := instructions(node)
+	require.Len(t, lines, 14)
+	assert.Equal(t, []string{
+		"FROM registry.example.com/base:latest",
+		`ENV A="one"`,
+		`ENV B="say \"\$HOME\""`,
+		`RUN dnf -y install 'httpd' 'it'\''s' && dnf clean all`,
+		"WORKDIR /srv",
+		`COPY --chown=1000:1000 --chmod=0644 ["site/","/var/www/html/"]`,
+		"RUN echo hello",
+	}, lines[:7])
+	assert.True(t, strings.HasPrefix(lines[7], "RUN <<"+heredocDelimiter), lines[7])
+	assert.Equal(t, "multi", strings.Fields(node.Children[7].Heredocs[0].Content)[3])
+	assert.Equal(t, []string{
+		`LABEL org.example.name="web"`,
+		"EXPOSE 80",
+		"EXPOSE 443/udp",
+		"USER apache",
+		`ENTRYPOINT ["/usr/sbin/httpd","-DFOREGROUND"]`,
+		`CMD ["-X"]`,
+	}, lines[8:])
+
+	node, err = Parse(YAML, []string{"def.yaml"}, [][]byte{[]byte("base: scratch\npackages: [curl]\n")})
+	require.NoError(t, err)
+	require.Len(t, node.Children, 2)
+	assert.Contains(t, node.Children[1].Original, "if command -v dnf >/dev/null 2>&1; then dnf -y install 'curl'")
+	assert.Contains(t, node.Children[1].Original, "elif command -v apk >/dev/null 2>&1; then apk add --no-cache 'curl'")
+
+	for _, testCase := range []struct {
+		definition, err string
+	}{
+		{"packages: [curl]\n", "no base image specified"},
+		{"base: scratch\nunknown: field\n", `unknown field "unknown"`},
+		{"base: scratch\npackages: [curl]\npackageManager: pacman\n", `unknown package manager "pacman"`},
+		{"base: scratch\nenv:\n  A: \"one\\ntwo\"\n", "contains a line break"},
+		{"base: scratch\nenv:\n  A B: one\n", `invalid environment variable name "A B"`},
+		{"base: scratch\nfiles:\n  - source: a\n", "both a source and a destination"},
+		{"base: scratch\nuser: two words\n", `invalid user "two words"`},
+		{"base: scratch\nrun: [\"\"]\n", "empty run command"},
+	} {
+		_, err := Parse(YAML, []string{"def.yaml"}, [][]byte{[]byte(testCase.definition)})
+		assert.ErrorContainsf(t, err, testCase.err, "parsing %q", testCase.definition)
+	}
+}
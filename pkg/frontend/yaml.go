@@ -0,0 +1,269 @@
+package frontend
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/openshift/imagebuilder"
+	"github.com/openshift/imagebuilder/dockerfile/parser"
+	"sigs.k8s.io/yaml"
+)
+
+// Definition is a declarative build definition which the "yaml" frontend
+// reads.  It describes a single-stage build, in which instructions are
+// generated in a fixed order: FROM, ENV, RUN (to install packages), WORKDIR,
+// COPY, RUN (for each command), LABEL, EXPOSE, USER, ENTRYPOINT, and CMD.
+type Definition struct {
+	// Base is the base image.  It is required, and can be "scratch".
+	Base string `json:"base"`
+	// Packages are installed using PackageManager.
+	Packages []string `json:"packages,omitempty"`
+	// PackageManager is one of "dnf", "microdnf", "yum", "apt", "apk",
+	// or "zypper".  If it is not set, the first of those which is present
+	// in the base image is used.
+	PackageManager string `json:"packageManager,omitempty"`
+	// Env sets environment variables.
+	Env map[string]string `json:"env,omitempty"`
+	// Workdir sets the working directory.
+	Workdir string `json:"workdir,omitempty"`
+	// Files are copied from the build context.
+	Files []File `json:"files,omitempty"`
+	// Run lists commands which are run using the shell, in order.
+	Run []string `json:"run,omitempty"`
+	// Labels sets labels.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Expose lists ports, with optional "/tcp" or "/udp" suffixes.
+	Expose []string `json:"expose,omitempty"`
+	// User sets the user, and optionally the group, to run as.
+	User string `json:"user,omitempty"`
+	// Entrypoint sets the entrypoint, in exec form.
+	Entrypoint []string `json:"entrypoint,omitempty"`
+	// Cmd sets the command, in exec form.
+	Cmd []string `json:"cmd,omitempty"`
+}
+
+// File describes content which is copied from the build context.
+type File struct {
+	// Source is a location in the build context.
+	Source string `json:"source"`
+	// Destination is a location in the image.
+	Destination string `json:"destination"`
+	// Chown optionally sets the ownership of the copied content.
+	Chown string `json:"chown,omitempty"`
+	// Chmod optionally sets the permissions of the copied content.
+	Chmod string `json:"chmod,omitempty"`
+}
+
+const heredocDelimiter = "BUILDAH_YAML_EOF"
+
+var packageInstallCommands = map[string]string{
+	"apk":      "apk add --no-cache %s",
+	"apt":      "apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends %s && rm -rf /var/lib/apt/lists/*",
+	"dnf":      "dnf -y install %s && dnf clean all",
+	"microdnf": "microdnf -y install %s && microdnf clean all",
+	"yum":      "yum -y install %s && yum clean all",
+	"zypper":   "zypper --non-interactive install %s && zypper clean --all",
+}
+
+// packageManagerSearchOrder is the order in which package managers are
+// looked for when one isn't specified.
+var packageManagerSearchOrder = []string{"dnf", "microdnf", "yum", "apt", "apk", "zypper"}
+
+func parseYAML(name string, contents []byte) (*parser.Node, error) {
+	var definition Definition
+	if err := yaml.UnmarshalStrict(contents, &definition); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", name, err)
+	}
+	containerfile, err := definition.Containerfile()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return imagebuilder.ParseDockerfile(bytes.NewReader(containerfile))
+}
+
+// Containerfile returns the contents of a Containerfile which is equivalent
+// to the definition.
+func (d *Definition) Containerfile() ([]byte, error) {
+	var buf bytes.Buffer
+	if d.Base == "" {
+		return nil, errors.New("no base image specified")
+	}
+	if err := checkLine("base image", d.Base); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&buf, "FROM %s\n", d.Base)
+
+	if err := writeKeyValues(&buf, "ENV", "environment variable", d.Env); err != nil {
+		return nil, err
+	}
+
+	if len(d.Packages) > 0 {
+		quoted := make([]string, 0, len(d.Packages))
+		for _, pkg := range d.Packages {
+			if pkg == "" {
+				return nil, errors.New("empty package name")
+			}
+			if err := checkLine("package name", pkg); err != nil {
+				return nil, err
+			}
+			quoted = append(quoted, shellQuote(pkg))
+		}
+		packages := strings.Join(quoted, " ")
+		if d.PackageManager != "" {
+			command, ok := packageInstallCommands[d.PackageManager]
+			if !ok {
+				return nil, fmt.Errorf("unknown package manager %q (known package managers: %s)", d.PackageManager, strings.Join(slices.Sorted(maps.Keys(packageInstallCommands)), ", "))
+			}
+			fmt.Fprintf(&buf, "RUN "+command+"\n", packages)
+		} else {
+			buf.WriteString("RUN ")
+			for i, manager := range packageManagerSearchOrder {
+				if i > 0 {
+					buf.WriteString("; el")
+				}
+				fmt.Fprintf(&buf, "if command -v %s >/dev/null 2>&1; then "+packageInstallCommands[manager], manager, packages)
+			}
+			buf.WriteString("; else echo no supported package manager found >&2; exit 1; fi\n")
+		}
+	}
+
+	if d.Workdir != "" {
+		if err := checkLine("working directory", d.Workdir); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "WORKDIR %s\n", d.Workdir)
+	}
+
+	for _, file := range d.Files {
+		if file.Source == "" || file.Destination == "" {
+			return nil, errors.New("files must specify both a source and a destination")
+		}
+		buf.WriteString("COPY")
+		if file.Chown != "" {
+			if err := checkWord("chown value", file.Chown); err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&buf, " --chown=%s", file.Chown)
+		}
+		if file.Chmod != "" {
+			if err := checkWord("chmod value", file.Chmod); err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&buf, " --chmod=%s", file.Chmod)
+		}
+		args, err := execForm("file", []string{file.Source, file.Destination})
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, " %s\n", args)
+	}
+
+	for _, command := range d.Run {
+		command = strings.TrimRight(command, "\n")
+		if strings.TrimSpace(command) == "" {
+			return nil, errors.New("empty run command")
+		}
+		if !strings.Contains(command, "\n") {
+			fmt.Fprintf(&buf, "RUN %s\n", command)
+			continue
+		}
+		if slices.Contains(strings.Split(command, "\n"), heredocDelimiter) {
+			return nil, fmt.Errorf("run command contains the line %q", heredocDelimiter)
+		}
+		fmt.Fprintf(&buf, "RUN <<%s\n%s\n%s\n", heredocDelimiter, command, heredocDelimiter)
+	}
+
+	if err := writeKeyValues(&buf, "LABEL", "label", d.Labels); err != nil {
+		return nil, err
+	}
+
+	for _, port := range d.Expose {
+		if err := checkWord("port", port); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "EXPOSE %s\n", port)
+	}
+
+	if d.User != "" {
+		if err := checkWord("user", d.User); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "USER %s\n", d.User)
+	}
+
+	for _, instruction := range []struct {
+		name string
+		args []string
+	}{
+		{"ENTRYPOINT", d.Entrypoint},
+		{"CMD", d.Cmd},
+	} {
+		if len(instruction.args) == 0 {
+			continue
+		}
+		args, err := execForm(strings.ToLower(instruction.name), instruction.args)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%s %s\n", instruction.name, args)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeKeyValues writes an ENV or LABEL instruction which sets each of the
+// values, in sorted order.
+func writeKeyValues(buf *bytes.Buffer, instruction, what string, values map[string]string) error {
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if key == "" || strings.ContainsAny(key, "= \t\r\n\"'\\$") {
+			return fmt.Errorf("invalid %s name %q", what, key)
+		}
+		if err := checkLine(what+" value", values[key]); err != nil {
+			return err
+		}
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(values[key])
+		fmt.Fprintf(buf, "%s %s=\"%s\"\n", instruction, key, value)
+	}
+	return nil
+}
+
+// execForm returns args encoded as a JSON array.
+func execForm(what string, args []string) (string, error) {
+	for _, arg := range args {
+		if err := checkLine(what+" argument", arg); err != nil {
+			return "", err
+		}
+	}
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("encoding %s arguments: %w", what, err)
+	}
+	return string(encoded), nil
+}
+
+// checkLine rejects values which would span multiple lines of a
+// Containerfile.
+func checkLine(what, value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("%s %q contains a line break", what, value)
+	}
+	return nil
+}
+
+// checkWord rejects values which would be treated as multiple arguments.
+func checkWord(what, value string) error {
+	if value == "" || strings.ContainsAny(value, " \t\r\n") {
+		return fmt.Errorf("invalid %s %q", what, value)
+	}
+	return nil
+}
+
+// shellQuote quotes a value for use as a single word in a shell command.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
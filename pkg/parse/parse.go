@@ -5,8 +5,10 @@ package parse //nolint:revive,nolintlint
 // would be useful to projects vendoring buildah
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"net"
 	"os"
@@ -41,6 +43,7 @@ import (
 	"go.podman.io/storage/pkg/idtools"
 	"go.podman.io/storage/pkg/unshare"
 	storageTypes "go.podman.io/storage/types"
+	"golang.org/x/crypto/ssh"
 	"golang.org/x/term"
 )
 
@@ -821,7 +824,7 @@ func GetConfidentialWorkloadOptions(arg string) (define.ConfidentialWorkloadOpti
 		case strings.HasPrefix(option, "type="):
 			options.TeeType = TeeType(strings.TrimPrefix(option, "type="))
 			switch options.TeeType {
-			case define.SEV, define.SNP, mkcwtypes.SEV_NO_ES:
+			case define.SEV, define.SNP, define.TDX, mkcwtypes.SEV_NO_ES:
 			default:
 				return options, fmt.Errorf("parsing type= value %q: unrecognized value", options.TeeType)
 			}
@@ -865,13 +868,56 @@ func GetConfidentialWorkloadOptions(arg string) (define.ConfidentialWorkloadOpti
 			options.FirmwareLibrary = val
 		case strings.HasPrefix(option, "slop="):
 			options.Slop = strings.TrimPrefix(option, "slop=")
+		case strings.HasPrefix(option, "key_file="), strings.HasPrefix(option, "key-file="):
+			options.Convert = true
+			options.DiskEncryptionKeyFile = strings.TrimPrefix(option, "key_file=")
+			if options.DiskEncryptionKeyFile == option {
+				options.DiskEncryptionKeyFile = strings.TrimPrefix(option, "key-file=")
+			}
+		case strings.HasPrefix(option, "fs="), strings.HasPrefix(option, "filesystem="):
+			options.Filesystem = strings.TrimPrefix(option, "fs=")
+			if options.Filesystem == option {
+				options.Filesystem = strings.TrimPrefix(option, "filesystem=")
+			}
+			switch options.Filesystem {
+			case "ext4", "xfs", "erofs":
+			default:
+				return options, fmt.Errorf("parsing fs= value %q: unrecognized value", options.Filesystem)
+			}
+		case strings.HasPrefix(option, "encryption="):
+			options.Encryption = strings.TrimPrefix(option, "encryption=")
+			switch options.Encryption {
+			case mkcwtypes.EncryptionLUKS1, mkcwtypes.EncryptionLUKS2, mkcwtypes.EncryptionNone:
+			default:
+				return options, fmt.Errorf("parsing encryption= value %q: unrecognized value", options.Encryption)
+			}
+		case strings.HasPrefix(option, "cipher="):
+			options.Cipher = strings.TrimPrefix(option, "cipher=")
+		case strings.HasPrefix(option, "sector_size="), strings.HasPrefix(option, "sector-size="):
+			val := strings.TrimPrefix(option, "sector_size=")
+			if val == option {
+				val = strings.TrimPrefix(option, "sector-size=")
+			}
+			options.SectorSize, err = strconv.Atoi(val)
+			if err != nil {
+				return options, fmt.Errorf("parsing sector_size= value %q: %w", val, err)
+			}
+		case option == "verity":
+			options.Verity = true
+		case strings.HasPrefix(option, "verity="):
+			val := strings.TrimPrefix(option, "verity=")
+			options.Verity = val == "true" || val == "yes" || val == "on" || val == "1"
 		default:
-			knownOptions := []string{"type", "attestation_url", "passphrase", "workload_id", "cpus", "memory", "firmware_library", "slop"}
+			knownOptions := []string{"type", "attestation_url", "passphrase", "key_file", "workload_id", "cpus", "memory", "firmware_library", "slop", "fs", "encryption", "cipher", "sector_size", "verity"}
 			return options, fmt.Errorf("expected one or more of %q as arguments for --cw, not %q", knownOptions, option)
 		}
 	}
+	if options.Encryption == mkcwtypes.EncryptionNone && options.Verity {
+		// nothing to decrypt, so no passphrase is needed
+		options.Convert = true
+	}
 	if options != defaults && !options.Convert {
-		return options, fmt.Errorf("--cw arguments missing one or more of (%q, %q)", "passphrase", "attestation_url")
+		return options, fmt.Errorf("--cw arguments missing one or more of (%q, %q, %q)", "passphrase", "key_file", "attestation_url")
 	}
 	return options, nil
 }
@@ -1375,25 +1421,64 @@ func Secrets(secrets []string) (map[string]define.Secret, error) {
 	return parsed, nil
 }
 
-// SSH parses the --ssh flag
+// SSH parses the --ssh flag, which takes the form
+// id[=socket|key[,key...]][,known_hosts=path]
 func SSH(sshSources []string) (map[string]*sshagent.Source, error) {
 	parsed := make(map[string]*sshagent.Source)
-	var paths []string
 	for _, v := range sshSources {
-		parts := strings.SplitN(v, "=", 2)
-		if len(parts) > 1 {
-			paths = strings.Split(parts[1], ",")
+		fields := strings.Split(v, ",")
+		id, first, hasPaths := strings.Cut(fields[0], "=")
+		var paths []string
+		var knownHosts string
+		if hasPaths {
+			paths = append(paths, first)
+		}
+		for _, field := range fields[1:] {
+			key, value, hasValue := strings.Cut(field, "=")
+			if !hasValue {
+				paths = append(paths, field)
+				continue
+			}
+			switch key {
+			case "known_hosts":
+				if err := checkKnownHosts(value); err != nil {
+					return nil, fmt.Errorf("ssh %q: %w", id, err)
+				}
+				knownHosts = value
+			default:
+				paths = append(paths, field)
+			}
 		}
 
 		source, err := sshagent.NewSource(paths)
 		if err != nil {
 			return nil, err
 		}
-		parsed[parts[0]] = source
+		source.KnownHosts = knownHosts
+		parsed[id] = source
 	}
 	return parsed, nil
 }
 
+// checkKnownHosts checks that a file can be parsed as a known_hosts file.
+func checkKnownHosts(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading known_hosts file: %w", err)
+	}
+	for len(bytes.TrimSpace(contents)) > 0 {
+		_, _, _, _, rest, err := ssh.ParseKnownHosts(contents)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("parsing known_hosts file %q: %w", path, err)
+		}
+		contents = rest
+	}
+	return nil
+}
+
 // ContainerIgnoreFile consumes path to `dockerignore` or `containerignore`
 // and returns list of files to exclude along with the path to processed ignore
 // file. Deprecated since this might become internal only, please avoid relying
@@ -1,8 +1,12 @@
 package parse //nolint:revive,nolintlint
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 
@@ -12,6 +16,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.podman.io/buildah/define"
 	"go.podman.io/image/v5/types"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestCommonBuildOptionsFromFlagSet(t *testing.T) {
@@ -358,3 +363,47 @@ func TestSecrets(t *testing.T) {
 		})
 	}
 }
+
+func TestSSH(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "/run/user/1000/agent.sock")
+	dir := t.TempDir()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(private, "")
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600))
+	knownHosts := filepath.Join(dir, "known_hosts")
+	require.NoError(t, os.WriteFile(knownHosts, []byte("# comment\ngithub.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl\n"), 0o644))
+	badKnownHosts := filepath.Join(dir, "bad_known_hosts")
+	require.NoError(t, os.WriteFile(badKnownHosts, []byte("github.com not-a-key\n"), 0o644))
+
+	errorTests := []struct {
+		name  string
+		input string
+	}{
+		{"missing-known-hosts", "default,known_hosts=" + filepath.Join(dir, "missing")},
+		{"bad-known-hosts", "default,known_hosts=" + badKnownHosts},
+		{"missing-key", "default=" + filepath.Join(dir, "missing")},
+	}
+	for _, tc := range errorTests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := SSH([]string{tc.input})
+			assert.Error(t, err, "expected error for input %q", tc.input)
+		})
+	}
+
+	parsed, err := SSH([]string{
+		"default",
+		"hosts,known_hosts=" + knownHosts,
+		"keys=" + keyFile + "," + keyFile + ",known_hosts=" + knownHosts,
+	})
+	require.NoError(t, err)
+	require.Len(t, parsed, 3)
+	assert.Equal(t, "/run/user/1000/agent.sock", parsed["default"].Socket)
+	assert.Empty(t, parsed["default"].KnownHosts)
+	assert.Equal(t, "/run/user/1000/agent.sock", parsed["hosts"].Socket)
+	assert.Equal(t, knownHosts, parsed["hosts"].KnownHosts)
+	assert.Len(t, parsed["keys"].Keys, 2)
+	assert.Equal(t, knownHosts, parsed["keys"].KnownHosts)
+}
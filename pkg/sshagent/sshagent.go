@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sync"
 	"time"
 
@@ -20,7 +21,7 @@ import (
 
 // AgentServer is an ssh agent that can be served and shutdown at a later time
 type AgentServer struct {
-	agent     agent.Agent
+	agent     agent.ExtendedAgent
 	wg        sync.WaitGroup
 	conn      *net.Conn
 	listener  net.Listener
@@ -29,17 +30,63 @@ type AgentServer struct {
 	serveDir  string
 }
 
+// AgentServerOptions controls which requests an AgentServer honors, and how
+// it logs them
+type AgentServerOptions struct {
+	// AllowedKeys lists the SHA256 fingerprints, in the format which
+	// "ssh-keygen -l" prints, of the only keys which the agent will list
+	// or use.  If it is empty, every key can be used.
+	AllowedKeys []string
+	// MaxSignatures is the number of signatures which the agent will
+	// produce before refusing further requests.  If it is zero, there is
+	// no limit.
+	MaxSignatures int
+	// Logger is used to log signature requests.  If it is not set, the
+	// standard logrus logger is used.
+	Logger *logrus.Logger
+	// Description identifies the agent's client, usually the instruction
+	// which the agent is being served to, in log messages.
+	Description string
+}
+
 // NewAgentServer creates a new agent on the host
 func NewAgentServer(source *Source) (*AgentServer, error) {
+	return NewAgentServerWithOptions(source, AgentServerOptions{})
+}
+
+// NewAgentServerWithOptions creates a new agent on the host, which enforces
+// the restrictions in the specified options and logs signature requests
+func NewAgentServerWithOptions(source *Source, options AgentServerOptions) (*AgentServer, error) {
+	var a *AgentServer
+	var err error
 	if source.Keys != nil {
-		return newAgentServerKeyring(source.Keys)
+		a, err = newAgentServerKeyring(source.Keys)
+	} else {
+		a, err = newAgentServerSocket(source.Socket)
+	}
+	if err != nil {
+		return nil, err
+	}
+	logger := options.Logger
+	if logger == nil {
+		logger = logrus.StandardLogger()
 	}
-	return newAgentServerSocket(source.Socket)
+	a.agent = &restrictedAgent{
+		ExtendedAgent: a.agent,
+		allowed:       options.AllowedKeys,
+		maxSignatures: options.MaxSignatures,
+		logger:        logger,
+		description:   options.Description,
+	}
+	return a, nil
 }
 
 // newAgentServerKeyring creates a new agent from scratch and adds keys
 func newAgentServerKeyring(keys []any) (*AgentServer, error) {
-	a := agent.NewKeyring()
+	a, ok := agent.NewKeyring().(agent.ExtendedAgent)
+	if !ok {
+		return nil, errors.New("failed to create ssh agent: keyring does not support extended requests")
+	}
 	for _, k := range keys {
 		if err := a.Add(agent.AddedKey{PrivateKey: k}); err != nil {
 			return nil, fmt.Errorf("failed to create ssh agent: %w", err)
@@ -187,11 +234,83 @@ func (a *readOnlyAgent) Extension(_ string, _ []byte) ([]byte, error) {
 	return nil, errors.New("extensions not allowed by buildah")
 }
 
+// restrictedAgent implements the agent.ExtendedAgent interface
+// restrictedAgent hides keys which aren't allowed, limits the number of signatures it will produce, and logs signature requests
+type restrictedAgent struct {
+	agent.ExtendedAgent
+	allowed       []string
+	maxSignatures int
+	logger        *logrus.Logger
+	description   string
+	lock          sync.Mutex
+	signatures    int
+}
+
+func (a *restrictedAgent) isAllowed(key ssh.PublicKey) bool {
+	return len(a.allowed) == 0 || slices.Contains(a.allowed, ssh.FingerprintSHA256(key))
+}
+
+func (a *restrictedAgent) List() ([]*agent.Key, error) {
+	keys, err := a.ExtendedAgent.List()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(keys, func(key *agent.Key) bool { return !a.isAllowed(key) }), nil
+}
+
+func (a *restrictedAgent) Signers() ([]ssh.Signer, error) {
+	signers, err := a.ExtendedAgent.Signers()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(signers, func(signer ssh.Signer) bool { return !a.isAllowed(signer.PublicKey()) }), nil
+}
+
+// checkSign decides whether or not to honor a request to sign something
+// using the key, and logs the decision
+func (a *restrictedAgent) checkSign(key ssh.PublicKey) error {
+	fingerprint := ssh.FingerprintSHA256(key)
+	prefix := "ssh agent"
+	if a.description != "" {
+		prefix = fmt.Sprintf("ssh agent for %q", a.description)
+	}
+	if !a.isAllowed(key) {
+		a.logger.Warnf("%s: refused signature request for disallowed %s key %s", prefix, key.Type(), fingerprint)
+		return fmt.Errorf("key %s not allowed by buildah", fingerprint)
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.maxSignatures > 0 && a.signatures >= a.maxSignatures {
+		a.logger.Warnf("%s: refused signature request for %s key %s: limit of %d signatures reached", prefix, key.Type(), fingerprint, a.maxSignatures)
+		return fmt.Errorf("signature limit of %d reached", a.maxSignatures)
+	}
+	a.signatures++
+	a.logger.Infof("%s: signature request %d for %s key %s", prefix, a.signatures, key.Type(), fingerprint)
+	return nil
+}
+
+func (a *restrictedAgent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	if err := a.checkSign(key); err != nil {
+		return nil, err
+	}
+	return a.ExtendedAgent.Sign(key, data)
+}
+
+func (a *restrictedAgent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	if err := a.checkSign(key); err != nil {
+		return nil, err
+	}
+	return a.ExtendedAgent.SignWithFlags(key, data, flags)
+}
+
 // Source is what the forwarded agent's source is
 // The source of the forwarded agent can be from a socket on the host, or from individual key files
 type Source struct {
 	Socket string
 	Keys   []any
+	// KnownHosts is the location of a known_hosts file to provide
+	// alongside the agent.
+	KnownHosts string
 }
 
 // NewSource takes paths and checks of they are keys or sockets, and creates a source
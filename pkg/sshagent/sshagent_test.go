@@ -1,12 +1,17 @@
 package sshagent
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"net"
 	"testing"
 
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
 )
 
@@ -53,3 +58,57 @@ func TestAgentServer(t *testing.T) {
 	_, err = testClient(sock)
 	require.Error(t, err)
 }
+
+func TestAgentServerRestrictions(t *testing.T) {
+	t.Parallel()
+	var keys []any
+	var publicKeys []ssh.PublicKey
+	for range 2 {
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		publicKey, err := ssh.NewPublicKey(public)
+		require.NoError(t, err)
+		keys = append(keys, private)
+		publicKeys = append(publicKeys, publicKey)
+	}
+	allowed, disallowed := publicKeys[0], publicKeys[1]
+	src := &Source{Keys: keys}
+	var log bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&log)
+	logger.SetLevel(logrus.InfoLevel)
+	ag, err := NewAgentServerWithOptions(src, AgentServerOptions{
+		AllowedKeys:   []string{ssh.FingerprintSHA256(allowed)},
+		MaxSignatures: 1,
+		Logger:        logger,
+		Description:   "RUN git clone",
+	})
+	require.NoError(t, err)
+	sock, err := ag.Serve("")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, ag.Shutdown()) }()
+
+	// only the allowed key is listed
+	listed, err := testClient(sock)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, allowed.Marshal(), listed[0].Marshal())
+
+	conn, err := net.Dial("unix", sock)
+	require.NoError(t, err)
+	defer conn.Close()
+	client := agent.NewClient(conn)
+	// the disallowed key can't be used
+	_, err = client.Sign(disallowed, []byte("data"))
+	assert.Error(t, err)
+	// the allowed key can be used, but only once
+	signature, err := client.Sign(allowed, []byte("data"))
+	require.NoError(t, err)
+	assert.NoError(t, allowed.Verify([]byte("data"), signature))
+	_, err = client.Sign(allowed, []byte("data"))
+	assert.Error(t, err)
+
+	assert.Contains(t, log.String(), `ssh agent for \"RUN git clone\": signature request 1 for ssh-ed25519 key `+ssh.FingerprintSHA256(allowed))
+	assert.Contains(t, log.String(), "refused signature request for disallowed ssh-ed25519 key "+ssh.FingerprintSHA256(disallowed))
+	assert.Contains(t, log.String(), "limit of 1 signatures reached")
+}
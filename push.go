@@ -14,6 +14,7 @@ import (
 	"go.podman.io/image/v5/docker/reference"
 	"go.podman.io/image/v5/manifest"
 	"go.podman.io/image/v5/pkg/compression"
+	"go.podman.io/image/v5/signature/signer"
 	"go.podman.io/image/v5/transports"
 	"go.podman.io/image/v5/types"
 	"go.podman.io/storage"
@@ -75,6 +76,16 @@ type PushOptions struct {
 	Quiet bool
 	// SignBy is the fingerprint of a GPG key to use for signing the image.
 	SignBy string
+	// SignBySigstorePrivateKeyFile is the location of a sigstore private
+	// key to use for signing the image.
+	SignBySigstorePrivateKeyFile string
+	// SignSigstorePrivateKeyPassphrase is the passphrase for the key
+	// specified in SignBySigstorePrivateKeyFile.
+	SignSigstorePrivateKeyPassphrase []byte
+	// Signers are additional signers to use for signing the image, such as
+	// those created using sigstore parameter files.  The caller is
+	// responsible for closing them.
+	Signers []*signer.Signer
 	// RemoveSignatures causes any existing signatures for the image to be
 	// discarded for the pushed copy.
 	RemoveSignatures bool
@@ -116,6 +127,9 @@ func Push(ctx context.Context, image string, dest types.ImageReference, options
 	libimageOptions.Writer = options.ReportWriter
 	libimageOptions.ManifestMIMEType = options.ManifestType
 	libimageOptions.SignBy = options.SignBy
+	libimageOptions.SignBySigstorePrivateKeyFile = options.SignBySigstorePrivateKeyFile
+	libimageOptions.SignSigstorePrivateKeyPassphrase = options.SignSigstorePrivateKeyPassphrase
+	libimageOptions.Signers = options.Signers
 	libimageOptions.RemoveSignatures = options.RemoveSignatures
 	libimageOptions.RetryDelay = &options.RetryDelay
 	libimageOptions.OciEncryptConfig = options.OciEncryptConfig
@@ -192,6 +192,16 @@ type RunOptions struct {
 	// ValidExitCodes is a list of exit codes which should be considered
 	// successful. If empty, only exit code 0 is considered success.
 	ValidExitCodes []int32
+	// Description describes the command, such as the build instruction
+	// which caused it to be run, for use in log messages.  If it is not
+	// set, a description based on the container's name is used.
+	Description string
+	// DetachedRunID is the ID of a run which was allocated using
+	// CreateDetachedRun().  If set, Run() records details about the
+	// command in the run's state when it starts, so that
+	// Builder.ExecDetached() and Builder.StopDetached() can find it, and
+	// records its exit status when it finishes.
+	DetachedRunID string
 }
 
 // RunMountArtifacts are the artifacts created when using a run mount.
@@ -220,6 +230,8 @@ type runMountInfo struct {
 	Secrets map[string]define.Secret
 	// SSHSources is the available ssh agents to use in a RUN
 	SSHSources map[string]*sshagent.Source `json:"-"`
+	// Description describes the command, for use in log messages
+	Description string
 	// Map of stages and container mountpoint if any from stage executor
 	StageMountPoints map[string]internal.StageMountDetails
 	// System context of current build
@@ -1512,6 +1512,7 @@ func (b *Builder) runSetupRunMounts(bundlePath string, mounts []string, sources
 	intermediateMounts := make([]string, 0, len(mounts))
 	finalMounts := make([]specs.Mount, 0, len(mounts))
 	agents := make([]*sshagent.AgentServer, 0, len(mounts))
+	var knownHosts []string
 	var envVars []string
 	targetLocks := []*lockfile.LockFile{}
 	var overlayDirs []string
@@ -1583,7 +1584,7 @@ func (b *Builder) runSetupRunMounts(bundlePath string, mounts []string, sources
 				envVars = append(envVars, mountOrEnvSpec.EnvVariable)
 			}
 		case "ssh":
-			mountSpec, agent, err := b.getSSHMount(tokens, len(agents), sources.SSHSources, idMaps)
+			mountSpec, agent, knownHostsFile, err := b.getSSHMount(tokens, len(agents), sources.SSHSources, sources.Description, idMaps)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -1593,6 +1594,9 @@ func (b *Builder) runSetupRunMounts(bundlePath string, mounts []string, sources
 					envVars = append(envVars, "SSH_AUTH_SOCK="+mountSpec.Destination)
 				}
 				agents = append(agents, agent)
+				if knownHostsFile != "" && !slices.Contains(knownHosts, knownHostsFile) {
+					knownHosts = append(knownHosts, knownHostsFile)
+				}
 			}
 		case define.TypeBind:
 			if bundleMountsDir == "" {
@@ -1649,6 +1653,13 @@ func (b *Builder) runSetupRunMounts(bundlePath string, mounts []string, sources
 			return nil, nil, fmt.Errorf("invalid mount type %q", mountType)
 		}
 	}
+	if len(knownHosts) > 0 {
+		mountSpec, err := b.getSSHKnownHostsMount(bundlePath, knownHosts)
+		if err != nil {
+			return nil, nil, err
+		}
+		finalMounts = append(finalMounts, *mountSpec)
+	}
 	succeeded = true
 	artifacts := &runMountArtifacts{
 		RunOverlayDirs:     overlayDirs,
@@ -1889,18 +1900,22 @@ func (b *Builder) getSecretMount(tokens []string, secrets map[string]define.Secr
 }
 
 // getSSHMount parses the --mount type=ssh flag in the Containerfile, checks if there's an ssh source provided, and creates and starts an ssh-agent to be forwarded into the container
-func (b *Builder) getSSHMount(tokens []string, count int, sshsources map[string]*sshagent.Source, idMaps IDMaps) (*specs.Mount, *sshagent.AgentServer, error) {
-	errInvalidSyntax := errors.New("ssh should have syntax id=id[,target=path,required=bool,mode=uint,uid=uint,gid=uint")
+// The agent enforces any key allowlist and signature limit which the mount specifies
+// If the ssh source includes a known_hosts file, its location is also returned
+func (b *Builder) getSSHMount(tokens []string, count int, sshsources map[string]*sshagent.Source, description string, idMaps IDMaps) (*specs.Mount, *sshagent.AgentServer, string, error) {
+	errInvalidSyntax := errors.New("ssh should have syntax id=id[,target=path,required=bool,mode=uint,uid=uint,gid=uint,allow=fingerprint,max-signatures=uint")
 
 	var err error
 	var id, target string
 	var required bool
 	var uid, gid uint32
 	var mode uint32 = 0o600
+	var allowed []string
+	var maxSignatures int
 	for _, val := range tokens {
 		kv := strings.SplitN(val, "=", 2)
 		if len(kv) < 2 {
-			return nil, nil, errInvalidSyntax
+			return nil, nil, "", errInvalidSyntax
 		}
 		switch kv[0] {
 		case "type":
@@ -1913,28 +1928,39 @@ func (b *Builder) getSSHMount(tokens []string, count int, sshsources map[string]
 		case "required":
 			required, err = strconv.ParseBool(kv[1])
 			if err != nil {
-				return nil, nil, errInvalidSyntax
+				return nil, nil, "", errInvalidSyntax
 			}
 		case "mode":
 			mode64, err := strconv.ParseUint(kv[1], 8, 32)
 			if err != nil {
-				return nil, nil, errInvalidSyntax
+				return nil, nil, "", errInvalidSyntax
 			}
 			mode = uint32(mode64)
 		case "uid":
 			uid64, err := strconv.ParseUint(kv[1], 10, 32)
 			if err != nil {
-				return nil, nil, errInvalidSyntax
+				return nil, nil, "", errInvalidSyntax
 			}
 			uid = uint32(uid64)
 		case "gid":
 			gid64, err := strconv.ParseUint(kv[1], 10, 32)
 			if err != nil {
-				return nil, nil, errInvalidSyntax
+				return nil, nil, "", errInvalidSyntax
 			}
 			gid = uint32(gid64)
+		case "allow":
+			if !strings.HasPrefix(kv[1], "SHA256:") {
+				return nil, nil, "", fmt.Errorf("ssh key fingerprint %q is not in the SHA256:... format which \"ssh-keygen -l\" prints", kv[1])
+			}
+			allowed = append(allowed, kv[1])
+		case "max-signatures":
+			n, err := strconv.ParseUint(kv[1], 10, 31)
+			if err != nil || n == 0 {
+				return nil, nil, "", fmt.Errorf("ssh max-signatures must be a positive integer, not %q", kv[1])
+			}
+			maxSignatures = int(n)
 		default:
-			return nil, nil, errInvalidSyntax
+			return nil, nil, "", errInvalidSyntax
 		}
 	}
 
@@ -1949,51 +1975,59 @@ func (b *Builder) getSSHMount(tokens []string, count int, sshsources map[string]
 	sshsource, ok := sshsources[id]
 	if !ok {
 		if required {
-			return nil, nil, fmt.Errorf("ssh required but no ssh with id %s found", id)
+			return nil, nil, "", fmt.Errorf("ssh required but no ssh with id %s found", id)
 		}
-		return nil, nil, nil
+		return nil, nil, "", nil
+	}
+	if description == "" {
+		description = "container " + b.Container
 	}
 	// Create new agent from keys or socket
-	fwdAgent, err := sshagent.NewAgentServer(sshsource)
+	fwdAgent, err := sshagent.NewAgentServerWithOptions(sshsource, sshagent.AgentServerOptions{
+		AllowedKeys:   allowed,
+		MaxSignatures: maxSignatures,
+		Logger:        b.Logger,
+		Description:   description,
+	})
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 	// Start ssh server, and get the host sock we're mounting in the container
 	hostSock, err := fwdAgent.Serve(b.ProcessLabel)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 
 	if err := relabel(filepath.Dir(hostSock), b.MountLabel, false); err != nil {
 		if shutdownErr := fwdAgent.Shutdown(); shutdownErr != nil {
 			b.Logger.Errorf("error shutting down agent: %v", shutdownErr)
 		}
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 	if err := relabel(hostSock, b.MountLabel, false); err != nil {
 		if shutdownErr := fwdAgent.Shutdown(); shutdownErr != nil {
 			b.Logger.Errorf("error shutting down agent: %v", shutdownErr)
 		}
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 	hostUID, hostGID, err := util.GetHostIDs(idMaps.uidmap, idMaps.gidmap, uid, gid)
 	if err != nil {
 		if shutdownErr := fwdAgent.Shutdown(); shutdownErr != nil {
 			b.Logger.Errorf("error shutting down agent: %v", shutdownErr)
 		}
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 	if err := os.Lchown(hostSock, int(hostUID), int(hostGID)); err != nil {
 		if shutdownErr := fwdAgent.Shutdown(); shutdownErr != nil {
 			b.Logger.Errorf("error shutting down agent: %v", shutdownErr)
 		}
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 	if err := os.Chmod(hostSock, os.FileMode(mode)); err != nil {
 		if shutdownErr := fwdAgent.Shutdown(); shutdownErr != nil {
 			b.Logger.Errorf("error shutting down agent: %v", shutdownErr)
 		}
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 	newMount := specs.Mount{
 		Destination: target,
@@ -2001,7 +2035,37 @@ func (b *Builder) getSSHMount(tokens []string, count int, sshsources map[string]
 		Source:      hostSock,
 		Options:     append(define.BindOptions, "rprivate", "ro"),
 	}
-	return &newMount, fwdAgent, nil
+	return &newMount, fwdAgent, sshsource.KnownHosts, nil
+}
+
+// getSSHKnownHostsMount combines the contents of the known_hosts files which
+// were provided with ssh sources, and returns a mount which provides them in
+// the container as the system-wide known hosts file
+func (b *Builder) getSSHKnownHostsMount(bundlePath string, knownHostsFiles []string) (*specs.Mount, error) {
+	var contents []byte
+	for _, knownHostsFile := range knownHostsFiles {
+		data, err := os.ReadFile(knownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading known_hosts file: %w", err)
+		}
+		contents = append(contents, data...)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			contents = append(contents, '\n')
+		}
+	}
+	hostFile := filepath.Join(bundlePath, "ssh_known_hosts")
+	if err := os.WriteFile(hostFile, contents, 0o644); err != nil {
+		return nil, fmt.Errorf("writing known_hosts file: %w", err)
+	}
+	if err := relabel(hostFile, b.MountLabel, false); err != nil {
+		return nil, err
+	}
+	return &specs.Mount{
+		Destination: "/etc/ssh/ssh_known_hosts",
+		Type:        define.TypeBind,
+		Source:      hostFile,
+		Options:     append(define.BindOptions, "rprivate", "ro"),
+	}, nil
 }
 
 // cleanupRunMounts cleans up run mounts so they only appear in this run.
@@ -0,0 +1,471 @@
+package buildah
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.podman.io/buildah/define"
+	"go.podman.io/buildah/pkg/chrootuser"
+	"go.podman.io/buildah/util"
+	"go.podman.io/storage/pkg/ioutils"
+	"go.podman.io/storage/pkg/stringid"
+)
+
+const (
+	// detachedRunsDir is the name of the directory, alongside the
+	// container's state file, where we keep track of detached runs.
+	detachedRunsDir = "runs"
+)
+
+// DetachedRunStatus describes the state of a command which was started using
+// RunOptions.DetachedRunID.
+type DetachedRunStatus string
+
+const (
+	// DetachedRunCreated indicates that the run has been allocated, but
+	// that the command has not been started yet.
+	DetachedRunCreated DetachedRunStatus = "created"
+	// DetachedRunRunning indicates that the command is running.
+	DetachedRunRunning DetachedRunStatus = "running"
+	// DetachedRunExited indicates that the command has exited.
+	DetachedRunExited DetachedRunStatus = "exited"
+)
+
+// DetachedRun holds information about a command which was started in a
+// working container, and which is allowed to keep running after the process
+// which started it has gone away.  Its state is stored in a "runs" directory
+// next to the state of the working container itself.
+type DetachedRun struct {
+	// ID is a unique identifier for the run.
+	ID string `json:"id"`
+	// Command is the command which was run.
+	Command []string `json:"command,omitempty"`
+	// Status is the last known status of the run.
+	Status DetachedRunStatus `json:"status"`
+	// Created is the time when the run was allocated.
+	Created time.Time `json:"created"`
+	// Started is the time when the command was started.
+	Started time.Time `json:"started,omitzero"`
+	// Finished is the time when the command exited.
+	Finished time.Time `json:"finished,omitzero"`
+	// ExitCode is the exit status of the command, if it has exited.
+	ExitCode int32 `json:"exitCode"`
+	// Error describes why the command failed, if it failed for a reason
+	// other than exiting with a non-zero status.
+	Error string `json:"error,omitempty"`
+	// MonitorPID is the ID of the process which called Run() and which is
+	// waiting for the command to exit.
+	MonitorPID int `json:"monitorPid,omitempty"`
+	// Isolation is the type of isolation which the command was run with.
+	Isolation define.Isolation `json:"isolation,omitempty"`
+	// Runtime is the OCI runtime which is running the command, if
+	// Isolation is IsolationOCI or IsolationOCIRootless.
+	Runtime string `json:"runtime,omitempty"`
+	// RuntimeArgs are the global arguments which were passed to Runtime.
+	RuntimeArgs []string `json:"runtimeArgs,omitempty"`
+	// RuntimeContainer is the name which Runtime knows the container by.
+	RuntimeContainer string `json:"runtimeContainer,omitempty"`
+	// LogPath is the location of the file which holds the command's
+	// output, if the caller directed the command's output to it.
+	LogPath string `json:"logPath,omitempty"`
+}
+
+// Running returns true if the run's command has been started and has not yet
+// been observed to have exited.
+func (r *DetachedRun) Running() bool {
+	return r.Status == DetachedRunRunning
+}
+
+// ExecOptions control how Builder.ExecDetached() runs an additional command
+// alongside a detached run.
+type ExecOptions struct {
+	// Env is a list of additional environment variables to set.
+	Env []string
+	// User is the user as whom to run the command.  If not set, the user
+	// of the detached run's command is used.
+	User string
+	// WorkingDir is the working directory for the command.  If not set,
+	// the working directory of the detached run's command is used.
+	WorkingDir string
+	// Terminal controls whether or not the command is run with a
+	// pseudoterminal.  DefaultTerminal is treated as WithoutTerminal.
+	Terminal TerminalPolicy
+	// The stdin/stdout/stderr descriptors to use.  If set to nil, the
+	// corresponding files in the "os" package are used as defaults.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+func (b *Builder) detachedRunsDir() (string, error) {
+	cdir, err := b.store.ContainerDirectory(b.ContainerID)
+	if err != nil {
+		return "", fmt.Errorf("locating state directory for container %q: %w", b.ContainerID, err)
+	}
+	return filepath.Join(cdir, detachedRunsDir), nil
+}
+
+func (b *Builder) saveDetachedRun(run *DetachedRun) error {
+	dir, err := b.detachedRunsDir()
+	if err != nil {
+		return err
+	}
+	state, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("encoding state of run %q: %w", run.ID, err)
+	}
+	if err = ioutils.AtomicWriteFile(filepath.Join(dir, run.ID+".json"), state, 0o600); err != nil {
+		return fmt.Errorf("saving state of run %q: %w", run.ID, err)
+	}
+	return nil
+}
+
+func (b *Builder) readDetachedRun(dir, id string) (*DetachedRun, error) {
+	state, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("reading state of run %q: %w", id, err)
+	}
+	var run DetachedRun
+	if err := json.Unmarshal(state, &run); err != nil {
+		return nil, fmt.Errorf("parsing state of run %q: %w", id, err)
+	}
+	if run.Status != DetachedRunExited && run.MonitorPID != 0 && !processExists(run.MonitorPID) {
+		// Whoever was waiting for the command went away without
+		// recording its exit status, so it's not running anymore.
+		run.Status = DetachedRunExited
+		run.ExitCode = -1
+		run.Error = "monitoring process exited unexpectedly"
+	}
+	return &run, nil
+}
+
+// CreateDetachedRun allocates a new detached run for the specified command,
+// with a log file for it in the container's state directory.  The returned
+// value's ID can then be set in RunOptions.DetachedRunID when calling Run(),
+// typically from a process which has been detached from the caller.
+func (b *Builder) CreateDetachedRun(command []string) (*DetachedRun, error) {
+	dir, err := b.detachedRunsDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating directory for detached runs: %w", err)
+	}
+	run := &DetachedRun{
+		ID:      stringid.GenerateRandomID(),
+		Command: slices.Clone(command),
+		Status:  DetachedRunCreated,
+		Created: time.Now().UTC(),
+	}
+	run.LogPath = filepath.Join(dir, run.ID+".log")
+	logFile, err := os.OpenFile(run.LogPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("creating log file for run %q: %w", run.ID, err)
+	}
+	logFile.Close()
+	if err := b.saveDetachedRun(run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// DetachedRuns returns the list of detached runs for the container, sorted by
+// the time they were created.
+func (b *Builder) DetachedRuns() ([]*DetachedRun, error) {
+	dir, err := b.detachedRunsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading list of detached runs: %w", err)
+	}
+	var runs []*DetachedRun
+	for _, entry := range entries {
+		id, isState := strings.CutSuffix(entry.Name(), ".json")
+		if !isState || strings.HasPrefix(id, ".") {
+			continue
+		}
+		run, err := b.readDetachedRun(dir, id)
+		if err != nil {
+			logrus.Debugf("%v, ignoring", err)
+			continue
+		}
+		runs = append(runs, run)
+	}
+	slices.SortFunc(runs, func(a, b *DetachedRun) int {
+		return a.Created.Compare(b.Created)
+	})
+	return runs, nil
+}
+
+// DetachedRun returns information about the detached run with the specified
+// ID, which can be abbreviated so long as it remains unambiguous.  If the ID
+// is empty, the most recently created run which is still running is
+// returned, or the most recently created run if none are running.
+func (b *Builder) DetachedRun(id string) (*DetachedRun, error) {
+	runs, err := b.DetachedRuns()
+	if err != nil {
+		return nil, err
+	}
+	var match *DetachedRun
+	if id == "" {
+		for _, run := range slices.Backward(runs) {
+			if run.Running() {
+				return run, nil
+			}
+			if match == nil {
+				match = run
+			}
+		}
+		if match == nil {
+			return nil, fmt.Errorf("container %q has no detached runs: %w", b.Container, os.ErrNotExist)
+		}
+		return match, nil
+	}
+	for _, run := range runs {
+		if run.ID == id {
+			return run, nil
+		}
+		if strings.HasPrefix(run.ID, id) {
+			if match != nil {
+				return nil, fmt.Errorf("run ID %q is ambiguous in container %q", id, b.Container)
+			}
+			match = run
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("container %q has no detached run with ID %q: %w", b.Container, id, os.ErrNotExist)
+	}
+	return match, nil
+}
+
+// RemoveDetachedRun removes the record of a detached run, and its log, if
+// the run is not running.
+func (b *Builder) RemoveDetachedRun(id string) error {
+	run, err := b.DetachedRun(id)
+	if err != nil {
+		return err
+	}
+	if run.Running() {
+		return fmt.Errorf("run %q in container %q is still running", run.ID, b.Container)
+	}
+	dir, err := b.detachedRunsDir()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range []string{run.ID + ".json", run.ID + ".log"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// detachedRunStarted records that Run() is about to start the command for the
+// detached run identified by options.DetachedRunID, if there is one.
+func (b *Builder) detachedRunStarted(options RunOptions, isolation define.Isolation, runtimeContainer string) error {
+	if options.DetachedRunID == "" {
+		return nil
+	}
+	dir, err := b.detachedRunsDir()
+	if err != nil {
+		return err
+	}
+	run, err := b.readDetachedRun(dir, options.DetachedRunID)
+	if err != nil {
+		return err
+	}
+	if run.Status != DetachedRunCreated {
+		return fmt.Errorf("run %q in container %q has already been started", run.ID, b.Container)
+	}
+	run.Status = DetachedRunRunning
+	run.Started = time.Now().UTC()
+	run.MonitorPID = os.Getpid()
+	run.Isolation = isolation
+	if isolation == define.IsolationOCI || isolation == define.IsolationOCIRootless {
+		run.Runtime = options.Runtime
+		if run.Runtime == "" {
+			run.Runtime = util.Runtime()
+		}
+		if localRuntime := util.FindLocalRuntime(run.Runtime); localRuntime != "" {
+			run.Runtime = localRuntime
+		}
+		run.RuntimeArgs = slices.Clone(options.Args)
+		run.RuntimeContainer = runtimeContainer
+	}
+	return b.saveDetachedRun(run)
+}
+
+// detachedRunFinished records the result of running the command for the
+// detached run identified by options.DetachedRunID, if there is one.
+func (b *Builder) detachedRunFinished(options RunOptions, runErr error) {
+	if options.DetachedRunID == "" {
+		return
+	}
+	dir, err := b.detachedRunsDir()
+	if err == nil {
+		var run *DetachedRun
+		if run, err = b.readDetachedRun(dir, options.DetachedRunID); err == nil {
+			run.Status = DetachedRunExited
+			run.Finished = time.Now().UTC()
+			run.Error = ""
+			exitCode, ok := exitCodeFromError(runErr)
+			if !ok {
+				exitCode = -1
+				run.Error = runErr.Error()
+			}
+			run.ExitCode = exitCode
+			err = b.saveDetachedRun(run)
+		}
+	}
+	if err != nil {
+		logrus.Errorf("recording exit status of run %q: %v", options.DetachedRunID, err)
+	}
+}
+
+func (r *DetachedRun) runtimeCommand(args ...string) (*exec.Cmd, error) {
+	if r.Runtime == "" || r.RuntimeContainer == "" {
+		return nil, fmt.Errorf("run %q is using %s isolation, which does not support this operation", r.ID, r.Isolation)
+	}
+	return exec.Command(r.Runtime, slices.Concat(r.RuntimeArgs, args)...), nil
+}
+
+// ExecDetached runs an additional command in the namespaces of a running
+// detached run, and waits for it to exit.  Only runs which were started using
+// an OCI runtime can be joined.
+func (b *Builder) ExecDetached(id string, command []string, options ExecOptions) error {
+	if len(command) == 0 {
+		return errors.New("no command specified")
+	}
+	run, err := b.DetachedRun(id)
+	if err != nil {
+		return err
+	}
+	if !run.Running() {
+		return fmt.Errorf("run %q in container %q is not running", run.ID, b.Container)
+	}
+	args := []string{"exec"}
+	if options.Terminal == WithTerminal {
+		args = append(args, "--tty")
+	}
+	for _, env := range options.Env {
+		args = append(args, "--env", env)
+	}
+	if options.WorkingDir != "" {
+		args = append(args, "--cwd", options.WorkingDir)
+	}
+	if options.User != "" {
+		// The runtime only understands numeric IDs, so resolve names
+		// using the container's own user and group databases, which
+		// means that the container has to be mounted.
+		mountPoint, err := b.Mount(b.MountLabel)
+		if err != nil {
+			return fmt.Errorf("mounting container %q: %w", b.ContainerID, err)
+		}
+		uid, gid, _, err := chrootuser.GetUser(mountPoint, options.User)
+		if unmountErr := b.Unmount(); unmountErr != nil {
+			logrus.Errorf("error unmounting container: %v", unmountErr)
+		}
+		if err != nil {
+			return fmt.Errorf("resolving user %q in container %q: %w", options.User, b.Container, err)
+		}
+		args = append(args, "--user", strconv.FormatUint(uint64(uid), 10)+":"+strconv.FormatUint(uint64(gid), 10))
+	}
+	args = append(append(args, run.RuntimeContainer), command...)
+	cmd, err := run.runtimeCommand(args...)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = options.Stdin, options.Stdout, options.Stderr
+	if cmd.Stdin == nil {
+		cmd.Stdin = os.Stdin
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	logrus.Debugf("Running %q", cmd.Args)
+	return cmd.Run()
+}
+
+// StopDetached asks the command of a running detached run to exit by sending
+// it SIGTERM, and waits for it to exit.  If it has not exited after the
+// specified timeout, it is sent SIGKILL.
+func (b *Builder) StopDetached(id string, timeout time.Duration) error {
+	run, err := b.DetachedRun(id)
+	if err != nil {
+		return err
+	}
+	if !run.Running() {
+		return nil
+	}
+	signal := func(sig syscall.Signal) error {
+		if run.RuntimeContainer == "" {
+			// Let the monitoring process clean up after itself.
+			if err := signalProcess(run.MonitorPID, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+				return fmt.Errorf("sending %v to process %d: %w", sig, run.MonitorPID, err)
+			}
+			return nil
+		}
+		cmd, err := run.runtimeCommand("kill", run.RuntimeContainer, strconv.Itoa(int(sig)))
+		if err != nil {
+			return err
+		}
+		if output, err := cmd.CombinedOutput(); err != nil {
+			logrus.Debugf("from %s sending %v to %q: %s: %v", run.Runtime, sig, run.RuntimeContainer, string(output), err)
+		}
+		return nil
+	}
+	wait := func(deadline time.Time) (bool, error) {
+		for {
+			current, err := b.DetachedRun(run.ID)
+			if err != nil {
+				return false, err
+			}
+			if !current.Running() {
+				return true, nil
+			}
+			if time.Now().After(deadline) {
+				return false, nil
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	if err := signal(syscall.SIGTERM); err != nil {
+		return err
+	}
+	stopped, err := wait(time.Now().Add(timeout))
+	if err != nil || stopped {
+		return err
+	}
+	logrus.Debugf("run %q did not exit after %v, sending SIGKILL", run.ID, timeout)
+	if err := signal(syscall.SIGKILL); err != nil {
+		return err
+	}
+	stopped, err = wait(time.Now().Add(30 * time.Second))
+	if err != nil {
+		return err
+	}
+	if !stopped {
+		return fmt.Errorf("run %q in container %q did not exit", run.ID, b.Container)
+	}
+	return nil
+}
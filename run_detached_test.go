@@ -0,0 +1,105 @@
+package buildah
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/buildah/define"
+	"go.podman.io/storage"
+	"go.podman.io/storage/types"
+)
+
+func TestDetachedRuns(t *testing.T) {
+	// This test cannot be parallelized as this uses NewBuilder().
+	ctx := context.TODO()
+	store, err := storage.GetStore(types.StoreOptions{
+		RunRoot:         t.TempDir(),
+		GraphRoot:       t.TempDir(),
+		GraphDriverName: "vfs",
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _, err := store.Shutdown(true); assert.NoError(t, err) })
+	b, err := NewBuilder(ctx, store, BuilderOptions{})
+	require.NoError(t, err)
+
+	runs, err := b.DetachedRuns()
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+	_, err = b.DetachedRun("")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	first, err := b.CreateDetachedRun([]string{"true"})
+	require.NoError(t, err)
+	assert.Equal(t, DetachedRunCreated, first.Status)
+	assert.FileExists(t, first.LogPath)
+	second, err := b.CreateDetachedRun([]string{"false"})
+	require.NoError(t, err)
+
+	runs, err = b.DetachedRuns()
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, first.ID, runs[0].ID)
+	assert.Equal(t, second.ID, runs[1].ID)
+
+	// abbreviated IDs, and the most recent run when nothing is running
+	run, err := b.DetachedRun(first.ID[:12])
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, run.ID)
+	run, err = b.DetachedRun("")
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, run.ID)
+	_, err = b.DetachedRun("not-a-run")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// a running run is preferred, and blocks removal of the container
+	options := RunOptions{DetachedRunID: first.ID, Runtime: "/usr/bin/true", Args: []string{"--debug"}}
+	require.NoError(t, b.detachedRunStarted(options, define.IsolationOCI, "buildah-bundle"))
+	assert.Error(t, b.detachedRunStarted(options, define.IsolationOCI, "buildah-bundle"), "started the same run twice")
+	run, err = b.DetachedRun("")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, run.ID)
+	assert.True(t, run.Running())
+	assert.Equal(t, os.Getpid(), run.MonitorPID)
+	assert.Equal(t, "/usr/bin/true", run.Runtime)
+	assert.Equal(t, []string{"--debug"}, run.RuntimeArgs)
+	assert.Equal(t, "buildah-bundle", run.RuntimeContainer)
+	assert.Error(t, b.RemoveDetachedRun(first.ID))
+	assert.Error(t, b.Delete())
+	// user names are looked up in the container, which has no /etc/passwd
+	assert.Error(t, b.ExecDetached(first.ID, []string{"true"}, ExecOptions{User: "root"}))
+	assert.Empty(t, b.MountPoint, "left the container mounted")
+
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+	b.detachedRunFinished(options, exitErr)
+	run, err = b.DetachedRun(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, DetachedRunExited, run.Status)
+	assert.EqualValues(t, 3, run.ExitCode)
+	assert.Empty(t, run.Error)
+
+	options.DetachedRunID = second.ID
+	require.NoError(t, b.detachedRunStarted(options, define.IsolationChroot, "buildah-bundle"))
+	run, err = b.DetachedRun(second.ID)
+	require.NoError(t, err)
+	assert.Empty(t, run.RuntimeContainer, "chroot runs can't be joined")
+	assert.Error(t, b.ExecDetached(second.ID, []string{"true"}, ExecOptions{}))
+	b.detachedRunFinished(options, errors.New("something went wrong"))
+	run, err = b.DetachedRun(second.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, -1, run.ExitCode)
+	assert.Equal(t, "something went wrong", run.Error)
+
+	require.NoError(t, b.RemoveDetachedRun(first.ID))
+	assert.NoFileExists(t, first.LogPath)
+	runs, err = b.DetachedRuns()
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, second.ID, runs[0].ID)
+
+	require.NoError(t, b.Delete())
+}
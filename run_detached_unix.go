@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd
+
+package buildah
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processExists returns true if a process with the specified ID exists.
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func signalProcess(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
@@ -268,6 +268,7 @@ func (b *Builder) Run(command []string, options RunOptions) error {
 		ContextDir:       options.ContextDir,
 		Secrets:          options.Secrets,
 		SSHSources:       options.SSHSources,
+		Description:      options.Description,
 		StageMountPoints: options.StageMountPoints,
 		SystemContext:    options.SystemContext,
 	}
@@ -343,6 +344,10 @@ func (b *Builder) Run(command []string, options RunOptions) error {
 		}
 	}()
 
+	if err := b.detachedRunStarted(options, isolation, containerName); err != nil {
+		return err
+	}
+
 	switch isolation {
 	case IsolationOCI:
 		var moreCreateArgs []string
@@ -357,6 +362,7 @@ func (b *Builder) Run(command []string, options RunOptions) error {
 	default:
 		err = errors.New("don't know how to run this command")
 	}
+	b.detachedRunFinished(options, err)
 	return checkExitCodeError(err, options.ValidExitCodes)
 }
 
@@ -503,6 +503,7 @@ rootless=%d
 		ContextDir:       options.ContextDir,
 		Secrets:          options.Secrets,
 		SSHSources:       options.SSHSources,
+		Description:      options.Description,
 		StageMountPoints: options.StageMountPoints,
 		SystemContext:    options.SystemContext,
 	}
@@ -574,6 +575,11 @@ rootless=%d
 		}
 	}
 
+	containerName := define.Package + "-" + filepath.Base(path)
+	if err := b.detachedRunStarted(options, isolation, containerName); err != nil {
+		return err
+	}
+
 	switch isolation {
 	case define.IsolationOCI:
 		var moreCreateArgs []string
@@ -581,7 +587,7 @@ rootless=%d
 			moreCreateArgs = append(moreCreateArgs, "--no-pivot")
 		}
 		err = b.runUsingRuntimeSubproc(isolation, options, configureNetwork, networkString, moreCreateArgs, spec,
-			mountPoint, path, define.Package+"-"+filepath.Base(path), b.Container, hostsFile, resolvFile)
+			mountPoint, path, containerName, b.Container, hostsFile, resolvFile)
 	case IsolationChroot:
 		err = chroot.RunUsingChroot(spec, path, homeDir, options.Stdin, options.Stdout, options.Stderr, options.NoPivot)
 	case IsolationOCIRootless:
@@ -590,10 +596,11 @@ rootless=%d
 			moreCreateArgs = append(moreCreateArgs, "--no-pivot")
 		}
 		err = b.runUsingRuntimeSubproc(isolation, options, configureNetwork, networkString, moreCreateArgs, spec,
-			mountPoint, path, define.Package+"-"+filepath.Base(path), b.Container, hostsFile, resolvFile)
+			mountPoint, path, containerName, b.Container, hostsFile, resolvFile)
 	default:
 		err = errors.New("don't know how to run this command")
 	}
+	b.detachedRunFinished(options, err)
 	return checkExitCodeError(err, options.ValidExitCodes)
 }
 
@@ -4,6 +4,7 @@ package buildah
 
 import (
 	"errors"
+	"syscall"
 
 	nettypes "go.podman.io/common/libnetwork/types"
 	"go.podman.io/storage"
@@ -27,3 +28,11 @@ func DefaultNamespaceOptions() (NamespaceOptions, error) {
 func getNetworkInterface(store storage.Store) (nettypes.ContainerNetwork, error) {
 	return nil, errors.New("function not supported on non-linux systems")
 }
+
+func processExists(pid int) bool {
+	return false
+}
+
+func signalProcess(pid int, sig syscall.Signal) error {
+	return errors.New("function not supported on non-linux systems")
+}
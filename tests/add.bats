@@ -631,3 +631,38 @@ EOF
   test -L $root/dest/symlink
   test "$(readlink $root/dest/symlink)" = "./file"
 }
+
+@test "add-content-filters" {
+  mkdir -p $TEST_SCRATCH_DIR/context/tree/__pycache__ $TEST_SCRATCH_DIR/context/tree/sub $TEST_SCRATCH_DIR/archived/__pycache__
+  printf 'one\r\ntwo\r\n' > $TEST_SCRATCH_DIR/context/tree/old.txt
+  printf 'one\r\ntwo\r\n' > $TEST_SCRATCH_DIR/context/tree/new.bin
+  createrandom $TEST_SCRATCH_DIR/context/tree/__pycache__/mod.pyc
+  createrandom $TEST_SCRATCH_DIR/context/tree/sub/stale.pyc
+  touch -d @1000 $TEST_SCRATCH_DIR/context/tree/old.txt
+  touch -d @5000 $TEST_SCRATCH_DIR/context/tree/new.bin
+  printf 'three\r\n' > $TEST_SCRATCH_DIR/archived/notes.txt
+  createrandom $TEST_SCRATCH_DIR/archived/__pycache__/other.pyc
+  tar -cf $TEST_SCRATCH_DIR/context/archive.tar -C $TEST_SCRATCH_DIR/archived .
+
+  run_buildah from $WITH_POLICY_JSON scratch
+  cid=$output
+  run_buildah 125 copy --clamp-timestamp $cid $TEST_SCRATCH_DIR/context/tree /tree
+  expect_output --substring "requires either --timestamp or"
+  run_buildah 125 copy --drop '[' $cid $TEST_SCRATCH_DIR/context/tree /tree
+  expect_output --substring "invalid pattern"
+  SOURCE_DATE_EPOCH=3000 run_buildah copy --clamp-timestamp --chmod-dirs 0751 --chmod-files 0640 --drop __pycache__ --drop '*.pyc' --unix-line-endings '*.txt' $cid $TEST_SCRATCH_DIR/context/tree /tree
+  run_buildah add --drop __pycache__ --unix-line-endings '*.txt' $cid $TEST_SCRATCH_DIR/context/archive.tar /archive
+
+  run_buildah mount $cid
+  root=$output
+  test ! -e $root/tree/__pycache__
+  test ! -e $root/tree/sub/stale.pyc
+  test ! -e $root/archive/__pycache__
+  run stat -c '%a %Y' $root/tree/old.txt $root/tree/new.bin $root/tree/sub
+  expect_output "640 1000
+640 3000
+751 3000"
+  printf 'one\ntwo\n' | cmp - $root/tree/old.txt
+  cmp $TEST_SCRATCH_DIR/context/tree/new.bin $root/tree/new.bin
+  printf 'three\n' | cmp - $root/archive/notes.txt
+}
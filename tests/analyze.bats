@@ -0,0 +1,34 @@
+#!/usr/bin/env bats
+
+load helpers
+
+@test "analyze" {
+  createrandom $TEST_SCRATCH_DIR/first 50000
+  createrandom $TEST_SCRATCH_DIR/second 100000
+  run_buildah from scratch
+  cid=$output
+  run_buildah copy $cid $TEST_SCRATCH_DIR/first /data/file
+  run_buildah copy $cid $TEST_SCRATCH_DIR/second /data/copy
+  run_buildah commit $WITH_POLICY_JSON $cid base
+  run_buildah from $WITH_POLICY_JSON base
+  cid=$output
+  run_buildah copy $cid $TEST_SCRATCH_DIR/second /data/file
+  run_buildah commit $WITH_POLICY_JSON $cid analyzed
+
+  # the files have different sizes, so that the overwrite is noticed when
+  # the layer diff is computed
+  run_buildah analyze analyzed
+  expect_output --substring "Wasted space: +50 KB"
+  expect_output --substring "Efficiency: +80.00%"
+  expect_output --substring "50 KB +0 +1 \(overwritten\) +/data/file"
+  expect_output --substring "Duplicate content: +100 KB"
+
+  run_buildah analyze --json analyzed
+  run jq -r '.wastedBytes, .layers[1].removedBytes, .duplicates[0].files[1].path' <<< "$output"
+  expect_output $'50000\n50000\n/data/file'
+
+  run_buildah analyze --max-wasted 50KB analyzed
+  run_buildah 125 analyze --max-wasted 10KB analyzed
+  expect_output --substring "50 KB wasted in image \"analyzed\" exceeds the limit of 10 KB"
+  run_buildah 125 analyze nonexistent-image
+}
@@ -0,0 +1,108 @@
+#!/usr/bin/env bats
+
+load helpers
+
+function _bake_setup() {
+  local contextdir=${TEST_SCRATCH_DIR}/bake
+  mkdir -p $contextdir
+  echo shared > $contextdir/shared.txt
+  echo a > $contextdir/a.txt
+  echo b > $contextdir/b.txt
+  for which in a b ; do
+    cat > $contextdir/Containerfile.$which <<- EOF
+	FROM scratch
+	COPY shared.txt /shared.txt
+	ENV SHARED=1
+	COPY $which.txt /$which.txt
+	EOF
+  done
+  cat > $contextdir/docker-bake.hcl << 'EOF'
+variable "TAG" {
+  default = "dev"
+}
+
+group "default" {
+  targets = ["a", "b"]
+}
+
+target "common" {
+  context = "."
+  labels = { team = "builders" }
+}
+
+target "a" {
+  inherits = ["common"]
+  dockerfile = "Containerfile.a"
+  tags = ["bake-a:${TAG}"]
+  labels = { which = upper("a") }
+}
+
+target "b" {
+  inherits = ["common"]
+  dockerfile = "Containerfile.b"
+  tags = ["bake-b:${TAG}"]
+}
+EOF
+}
+
+@test "bake-print" {
+  _bake_setup
+  cd ${TEST_SCRATCH_DIR}/bake
+  TAG=v1 run_buildah bake --print b
+  expect_output --substring '"bake-b:v1"'
+  expect_output --substring '"team": "builders"'
+  assert "$output" !~ "bake-a" "only the named target is printed"
+
+  run_buildah 125 bake nonexistent
+  expect_output --substring 'no target or group named "nonexistent"'
+
+  cd ${TEST_SCRATCH_DIR}
+  run_buildah 125 bake
+  expect_output --substring "no definition file"
+}
+
+@test "bake-shared-steps" {
+  _bake_setup
+  cd ${TEST_SCRATCH_DIR}/bake
+  run_buildah --log-level=debug bake $WITH_POLICY_JSON
+  expect_output --substring "\[a\] STEP 2/5: COPY shared.txt /shared.txt"
+  expect_output --substring "\[b\] STEP 2/5: COPY shared.txt /shared.txt"
+  # the target which didn't run the shared steps found them in the cache
+  expect_output --substring "Found a cache hit in the first iteration"
+
+  run_buildah inspect --type=image --format '{{.OCIv1.Config.Labels.team}} {{.OCIv1.Config.Labels.which}} {{.OCIv1.Config.Env}}' bake-a:dev
+  expect_output "builders A [PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin SHARED=1]"
+  run_buildah inspect --type=image --format '{{.OCIv1.Config.Labels.team}} {{.OCIv1.Config.Labels.which}}' bake-b:dev
+  expect_output "builders <no value>"
+
+  # everything is in the cache now
+  run_buildah bake $WITH_POLICY_JSON --jobs 1 a
+  expect_output --substring "\[a\] STEP 4/5: COPY a.txt /a.txt"
+  expect_output --substring "Using cache"
+}
+
+@test "bake-json-and-build-options" {
+  _bake_setup
+  cd ${TEST_SCRATCH_DIR}/bake
+  cat > override.json << 'EOF'
+{"target": {"b": {"args": {"UNUSED": "1"}, "tags": ["bake-json:latest"]}}}
+EOF
+  run_buildah bake $WITH_POLICY_JSON --format docker -f docker-bake.hcl -f override.json b
+  run_buildah inspect --type=image --format '{{.Manifest}}' bake-json:latest
+  expect_output --substring "application/vnd.docker.distribution.manifest.v2+json"
+  run_buildah inspect --type=image --format '{{.Docker.Config.Labels.team}}' bake-json:latest
+  expect_output "builders"
+}
+
+@test "bake-invalid-target" {
+  _bake_setup
+  cd ${TEST_SCRATCH_DIR}/bake
+  cat > override.json << 'EOF2'
+{"target": {"b": {"context": "./missing"}}}
+EOF2
+  # every target is checked before any of them are built
+  run_buildah 125 bake $WITH_POLICY_JSON -f docker-bake.hcl -f override.json
+  expect_output --substring 'target "b": '
+  run_buildah 125 inspect --type=image bake-a:dev
+  expect_output --substring "image not known"
+}
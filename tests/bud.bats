@@ -2843,6 +2843,39 @@ _EOF
   expect_output --substring 'bin'
 }
 
+@test "build with custom build output and output rootfs to filesystem images" {
+  _prefetch alpine
+  mytmpdir=${TEST_SCRATCH_DIR}/my-dir
+  mkdir -p $mytmpdir
+  cat > $mytmpdir/Containerfile << _EOF
+FROM alpine
+RUN echo 'hello'> hello && chown 1234:5678 hello && chmod u+s hello
+_EOF
+  if which debugfs > /dev/null 2> /dev/null && which mkfs.ext4 > /dev/null 2> /dev/null; then
+    run_buildah build --output type=ext4,dest=$mytmpdir/rootfs.ext4 $WITH_POLICY_JSON -t test-bud -f $mytmpdir/Containerfile .
+    # ownership and the setuid bit should be preserved, even when rootless
+    run debugfs -R "stat /hello" $mytmpdir/rootfs.ext4
+    assert "$status" -eq 0 "debugfs stat"
+    expect_output --substring 'User:  1234   Group:  5678'
+    expect_output --substring 'Mode:  04644'
+  fi
+  if which unsquashfs > /dev/null 2> /dev/null && which mksquashfs > /dev/null 2> /dev/null; then
+    run_buildah build --output type=squashfs,dest=$mytmpdir/rootfs.squashfs $WITH_POLICY_JSON -t test-bud -f $mytmpdir/Containerfile .
+    run unsquashfs -lln $mytmpdir/rootfs.squashfs /hello
+    assert "$status" -eq 0 "unsquashfs -lln"
+    expect_output --substring '-rwSr--r-- 1234/5678 '
+  fi
+  if which fsck.erofs > /dev/null 2> /dev/null && which mkfs.erofs > /dev/null 2> /dev/null; then
+    run_buildah build --output type=erofs,dest=$mytmpdir/rootfs.erofs $WITH_POLICY_JSON -t test-bud -f $mytmpdir/Containerfile .
+    run fsck.erofs $mytmpdir/rootfs.erofs
+    assert "$status" -eq 0 "fsck.erofs"
+  fi
+  run_buildah 125 build --output type=erofs $WITH_POLICY_JSON -t test-bud -f $mytmpdir/Containerfile .
+  expect_output --substring 'missing required key "dest"'
+  run_buildah 125 build --output type=squashfs,dest=- $WITH_POLICY_JSON -t test-bud -f $mytmpdir/Containerfile .
+  expect_output --substring 'only "type=tar" can be used with "dest=-"'
+}
+
 @test "build with custom build output must fail for bad input" {
   _prefetch alpine
   mytmpdir=${TEST_SCRATCH_DIR}/my-dir
@@ -4812,6 +4845,40 @@ _EOF
   run_buildah build $WITH_POLICY_JSON $contextdir
 }
 
+@test "bud-copy --no-clone --hardlink-context" {
+  local contextdir=${TEST_SCRATCH_DIR}/context
+  mkdir -p $contextdir/tree/a
+  createrandom $contextdir/tree/a/small 100
+  createrandom $contextdir/tree/large 300000
+  cat > $contextdir/Containerfile << _EOF
+FROM scratch AS base
+COPY tree /tree/
+FROM scratch
+COPY --from=base /tree /from-base/
+COPY tree /tree/
+_EOF
+
+  # the layers should not depend on how the contents were copied
+  run_buildah build $WITH_POLICY_JSON -t default $contextdir
+  run_buildah inspect --format '{{.OCIv1.RootFS.DiffIDs}}' default
+  diffids="$output"
+  run_buildah build $WITH_POLICY_JSON --no-clone -t noclone $contextdir
+  run_buildah inspect --format '{{.OCIv1.RootFS.DiffIDs}}' noclone
+  expect_output "$diffids"
+  run_buildah build $WITH_POLICY_JSON --hardlink-context -t hardlinked $contextdir
+  run_buildah inspect --format '{{.OCIv1.RootFS.DiffIDs}}' hardlinked
+  expect_output "$diffids"
+  # nothing that was built should still be sharing the context's files
+  assert $(stat -c %h $contextdir/tree/large) == 1 "the build left a hard link to the context"
+
+  run_buildah from hardlinked
+  cid=$output
+  run_buildah mount $cid
+  root=$output
+  diff -r $contextdir/tree $root/tree
+  diff -r $contextdir/tree $root/from-base
+}
+
 @test "bud copy with .dockerignore #1" {
   _prefetch alpine
   mytmpdir=${TEST_SCRATCH_DIR}/my-dir
@@ -5920,6 +5987,57 @@ _EOF
   expect_output --substring "test got <input>"
 }
 
+@test "bud --on-failure=shell" {
+  _prefetch alpine
+  mytmpdir=${TEST_SCRATCH_DIR}/my-dir
+  mkdir -p ${mytmpdir}
+cat > $mytmpdir/Containerfile << _EOF
+FROM alpine
+ENV FOO=bar
+RUN --mount=type=cache,target=/cache echo here > /cache/marker && false
+_EOF
+
+  run_buildah 125 build --on-failure=bogus $WITH_POLICY_JSON ${mytmpdir}
+  expect_output --substring 'unrecognized value "bogus" for --on-failure'
+
+  # the shell gets the step's environment and mounts, and then the build is
+  # aborted when there's no answer to the retry prompt
+  run_buildah 1 build --on-failure=shell $WITH_POLICY_JSON ${mytmpdir} <<< 'echo shell got $FOO; cat /cache/marker; exit'
+  expect_output --substring "shell got bar"
+  expect_output --substring "here"
+  expect_output --substring "Retry the failed instruction"
+  expect_output --substring "building .*: exit status 1"
+}
+
+@test "bud --break-at and --step" {
+  _prefetch alpine
+  mytmpdir=${TEST_SCRATCH_DIR}/my-dir
+  mkdir -p ${mytmpdir}
+cat > $mytmpdir/Containerfile << _EOF
+FROM alpine AS first
+ARG TARGET=everything
+RUN touch /everything
+RUN test ! -e /everything
+COPY Containerfile /\$TARGET
+_EOF
+
+  run_buildah 125 build --break-at line:0 $WITH_POLICY_JSON ${mytmpdir}
+  expect_output --substring 'breakpoint "line:0": invalid line number'
+
+  # skip the instruction which would cause the next one to fail
+  run_buildah build --break-at 'run touch' --break-at COPY $WITH_POLICY_JSON ${mytmpdir} <<< $'k\nc'
+  expect_output --substring 'Breakpoint "run touch" reached'
+  expect_output --substring 'Breakpoint "COPY" reached'
+  expect_output --substring "Resolved: +COPY Containerfile /everything"
+  expect_output --substring "TARGET=everything"
+
+  # keep stepping until we run out of answers, and then stop
+  run_buildah 125 build --step $WITH_POLICY_JSON ${mytmpdir} <<< s
+  expect_output --substring "Paused before step 1/4 of stage 0 \\(first\\), line 2: ARG TARGET=everything"
+  expect_output --substring "Paused before step 2/4 of stage 0 \\(first\\), line 3: RUN touch /everything"
+  expect_output --substring "build aborted by debugger"
+}
+
 @test "bud with --arch flag" {
   _prefetch alpine
   mytmpdir=${TEST_SCRATCH_DIR}/my-dir
@@ -10508,3 +10626,88 @@ _EOF
   # Verify final image IDs match (complete cache hit)
   assert "$first_build_final_image_id" "==" "$second_build_final_image_id" "final image ID should match when cache is fully reused"
 }
+
+@test "bud-yaml-frontend" {
+  _prefetch alpine
+  local contextdir=${TEST_SCRATCH_DIR}/yaml-frontend
+  mkdir -p $contextdir/site
+  echo hello > $contextdir/site/index.html
+  cat > $contextdir/image.yaml << _EOF
+# syntax=yaml
+base: alpine
+env:
+  GREETING: hello "world"
+files:
+  - source: site/
+    destination: /srv/
+run:
+  - test -s /srv/index.html
+labels:
+  purpose: yaml-frontend
+entrypoint: ["/bin/cat", "/srv/index.html"]
+_EOF
+
+  run_buildah build $WITH_POLICY_JSON -f $contextdir/image.yaml -t yaml-image $contextdir
+  expect_output --substring 'STEP 2/6: ENV GREETING="hello \"world\""'
+  expect_output --substring 'STEP 4/6: RUN test -s /srv/index.html'
+  run_buildah inspect --format '{{index .OCIv1.Config.Labels "purpose"}} {{.OCIv1.Config.Entrypoint}}' yaml-image
+  expect_output "yaml-frontend [/bin/cat /srv/index.html]"
+  run_buildah inspect --format '{{range .OCIv1.Config.Env}}{{println .}}{{end}}' yaml-image
+  expect_output --substring 'GREETING=hello "world"'
+
+  # without the directive, the frontend has to be selected explicitly
+  sed -i -e '/^# syntax=yaml/d' $contextdir/image.yaml
+  run_buildah build $WITH_POLICY_JSON --frontend yaml -f $contextdir/image.yaml -t yaml-image $contextdir
+  expect_output --substring 'STEP 4/6: RUN test -s /srv/index.html'
+
+  run_buildah 125 build $WITH_POLICY_JSON --frontend no-such-frontend -f $contextdir/image.yaml $contextdir
+  expect_output --substring 'unknown frontend "no-such-frontend"'
+
+  echo 'unknown: field' >> $contextdir/image.yaml
+  run_buildah 125 build $WITH_POLICY_JSON --frontend yaml -f $contextdir/image.yaml $contextdir
+  expect_output --substring 'unknown field "unknown"'
+}
+
+@test "bud-print-graph-and-stage-timeline" {
+  local contextdir=${TEST_SCRATCH_DIR}/stage-graph
+  mkdir -p $contextdir
+  echo a > $contextdir/a
+  echo b > $contextdir/b
+  cat > $contextdir/Containerfile << _EOF
+FROM scratch AS one
+COPY a /a
+
+FROM scratch AS unused
+COPY b /b
+
+FROM scratch AS two
+COPY b /b
+
+FROM one
+COPY --from=two /b /b2
+_EOF
+
+  run_buildah build $WITH_POLICY_JSON --print-graph $contextdir
+  expect_output --substring 'digraph stages {'
+  expect_output --substring '"1" \[label="stage 1 \(unused\)\\nFROM scratch", style=dashed\];'
+  expect_output --substring '"3" \[label="stage 3\\nFROM one", peripheries=2\];'
+  expect_output --substring '"0" -> "3" \[label="from"\];'
+  expect_output --substring '"2" -> "3" \[label="copy"\];'
+  assert "$output" !~ "STEP" "--print-graph should not build anything"
+
+  run_buildah build $WITH_POLICY_JSON --print-graph=json $contextdir
+  run jq -c '.stages[3].needs' <<< "$output"
+  assert "$output" = '[{"index":0,"name":"one","kind":"from"},{"index":2,"name":"two","kind":"copy"}]'
+
+  run_buildah 125 build $WITH_POLICY_JSON --print-graph=svg $contextdir
+  expect_output --substring 'unknown stage graph format "svg"'
+
+  run_buildah 125 build $WITH_POLICY_JSON --print-graph --source-image oci:${TEST_SCRATCH_DIR}/stage-graph-source $contextdir
+  expect_output --substring 'printing the stage graph and pushing a source image are mutually exclusive'
+
+  run_buildah build $WITH_POLICY_JSON --jobs 0 -t stage-graph $contextdir
+  expect_output --substring 'Stage timeline:'
+  expect_output --substring '1 \(unused\) +skipped'
+  expect_output --substring '2 \(two\) +\+[0-9.]+m?s +\+[0-9.]+m?s'
+  assert "${lines[-1]}" =~ "^[0-9a-f]{64}$" "image ID should still be printed last"
+}
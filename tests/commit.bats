@@ -721,3 +721,54 @@ _EOF
   assert "$output" !~ "gzip" \
     "manifest should NOT reference gzip layers when containers.conf specifies zstd"
 }
+
+@test "commit --format to a filesystem image" {
+  if ! which debugfs > /dev/null 2> /dev/null || ! which mkfs.ext4 > /dev/null 2> /dev/null; then
+    skip "debugfs or mkfs.ext4 not found"
+  fi
+  _prefetch busybox
+  run_buildah from -q busybox
+  local cid="$output"
+  echo hello > $TEST_SCRATCH_DIR/hello
+  run_buildah copy --chown 1234:5678 --chmod 4755 "$cid" $TEST_SCRATCH_DIR/hello /hello
+  run_buildah images -a -q
+  local images="$output"
+  run_buildah commit --format ext4 --source-date-epoch 60 "$cid" $TEST_SCRATCH_DIR/rootfs.img
+  run debugfs -R "stat /hello" $TEST_SCRATCH_DIR/rootfs.img
+  assert "$status" -eq 0 "debugfs stat"
+  expect_output --substring 'User:  1234   Group:  5678'
+  expect_output --substring 'Mode:  04755'
+  expect_output --substring 'mtime: 0x0000003c:'
+  # no image should have been written
+  run_buildah images -a -q
+  expect_output "$images"
+
+  run_buildah 125 commit --format ext4 "$cid"
+  expect_output --substring "requires the location of the file to write"
+  run_buildah 125 commit --format ext4 --cw type=snp,passphrase=secret "$cid" $TEST_SCRATCH_DIR/rootfs.img
+  expect_output --substring "can not be used with --cw"
+}
+
+@test "commit-compat-docker-config" {
+  run_buildah from $WITH_POLICY_JSON scratch
+  local cid="$output"
+  run_buildah config --healthcheck "CMD /bin/check" --healthcheck-interval 30s --shell "/bin/bash -c" --domainname example.com --onbuild "LABEL triggered=yes" "$cid"
+
+  # without the option, the OCI configuration has no place for these settings
+  run_buildah commit $WITH_POLICY_JSON --format oci "$cid" plain-oci
+  run_buildah inspect --type image --format '{{.Docker.Config.Healthcheck}} {{.Docker.Config.Shell}} {{.Docker.Config.OnBuild}}' plain-oci
+  expect_output "<nil> [] []"
+
+  run_buildah commit $WITH_POLICY_JSON --format oci --compat-docker-config "$cid" compat-oci
+  run_buildah inspect --type image --format '{{.Config}}' compat-oci
+  expect_output --substring '"Healthcheck":{"Test":\["CMD","/bin/check"\],"Interval":30000000000,'
+  expect_output --substring '"OnBuild":\["LABEL triggered=yes"\]'
+  expect_output --substring '"Shell":\["/bin/bash","-c"\]'
+  expect_output --substring '"Domainname":"example.com"'
+
+  # the settings should be restored when the image is used as a base
+  run_buildah from --quiet $WITH_POLICY_JSON compat-oci
+  local cid2="$output"
+  run_buildah inspect --format '{{.Docker.Config.Healthcheck.Test}} {{.Docker.Config.Shell}} {{.Docker.Config.Domainname}}' "$cid2"
+  expect_output "[CMD /bin/check] [/bin/bash -c] example.com"
+}
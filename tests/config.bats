@@ -450,3 +450,44 @@ EOF
 
   check_matrix 'Config.Volumes'      "map[]"
 }
+
+@test "config --from-file and commit --config-patch" {
+  _prefetch busybox
+  run_buildah from --quiet --pull=false $WITH_POLICY_JSON busybox
+  cid=$output
+  run_buildah config --env DROPME=1 --label dropme=1 $cid
+
+  cat > ${TEST_SCRATCH_DIR}/patch.yaml << _EOF
+env:
+  DROPME: null
+  GREETING: hello
+labels:
+  dropme: null
+  org.example.team: builders
+ports: ["8080/tcp"]
+entrypoint: [/bin/sh, -c]
+cmd: [echo \$GREETING]
+user: "1000"
+_EOF
+  run_buildah config --from-file ${TEST_SCRATCH_DIR}/patch.yaml --label fromflag=1 $cid
+  run_buildah inspect --format '{{.OCIv1.Config.Env}} {{.OCIv1.Config.Labels}} {{.OCIv1.Config.ExposedPorts}} {{.OCIv1.Config.Entrypoint}} {{.OCIv1.Config.Cmd}} {{.OCIv1.Config.User}}' $cid
+  expect_output "[PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin GREETING=hello] map[fromflag:1 org.example.team:builders] map[8080/tcp:{}] [/bin/sh -c] [echo \$GREETING] 1000"
+
+  # an invalid patch is rejected without changing anything
+  echo '{"volumes": ["relative"]}' > ${TEST_SCRATCH_DIR}/bad.json
+  run_buildah 125 config --from-file ${TEST_SCRATCH_DIR}/bad.json $cid
+  expect_output --substring 'volume "relative" is not an absolute path'
+
+  # a JSON patch applied at commit time doesn't change the container
+  cat > ${TEST_SCRATCH_DIR}/patch.json << _EOF
+[
+  {"op": "replace", "path": "/user", "value": "root"},
+  {"op": "add", "path": "/annotations/org.opencontainers.image.source", "value": "https://example.com/repo"}
+]
+_EOF
+  run_buildah commit --config-patch ${TEST_SCRATCH_DIR}/patch.json $WITH_POLICY_JSON $cid patched-image
+  run_buildah inspect --type=image --format '{{.OCIv1.Config.User}} {{index .ImageAnnotations "org.opencontainers.image.source"}}' patched-image
+  expect_output "root https://example.com/repo"
+  run_buildah inspect --format '{{.OCIv1.Config.User}}' $cid
+  expect_output "1000"
+}
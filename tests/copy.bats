@@ -809,3 +809,53 @@ parents/y/b.txt"
   run_buildah 125 copy --allow-empty-wildcard=true $cid ${TEST_SCRATCH_DIR}/no-such-file /dest4/
   expect_output --substring "no such file or directory"
 }
+
+@test "copy --jobs" {
+  mkdir -p ${TEST_SCRATCH_DIR}/tree/a ${TEST_SCRATCH_DIR}/tree/b
+  for i in $(seq 1 50) ; do
+    createrandom ${TEST_SCRATCH_DIR}/tree/a/file$i $((i * 100))
+    createrandom ${TEST_SCRATCH_DIR}/tree/b/file$i $((i * 100))
+  done
+  createrandom ${TEST_SCRATCH_DIR}/tree/large 300000
+  ln ${TEST_SCRATCH_DIR}/tree/a/file1 ${TEST_SCRATCH_DIR}/tree/b/link
+  ln -s ../a/file2 ${TEST_SCRATCH_DIR}/tree/b/symlink
+
+  run_buildah from $WITH_POLICY_JSON scratch
+  cid=$output
+  run_buildah 125 copy --jobs -1 $cid ${TEST_SCRATCH_DIR}/tree /serial
+  expect_output --substring "invalid value for --jobs"
+  run_buildah 125 copy --jobs 0 $cid ${TEST_SCRATCH_DIR}/tree /serial
+  expect_output --substring "must be a positive integer"
+  run_buildah copy $cid ${TEST_SCRATCH_DIR}/tree /serial
+  serial="$output"
+  run_buildah copy --jobs 8 $cid ${TEST_SCRATCH_DIR}/tree /parallel
+  # the content digest should not depend on the number of workers
+  expect_output "$serial"
+
+  run_buildah mount $cid
+  root=$output
+  diff -r ${TEST_SCRATCH_DIR}/tree $root/parallel
+  diff -r $root/serial $root/parallel
+  test $(stat -c %i $root/parallel/a/file1) = $(stat -c %i $root/parallel/b/link)
+  test $(readlink $root/parallel/b/symlink) = ../a/file2
+}
+
+@test "copy --no-clone" {
+  mkdir -p ${TEST_SCRATCH_DIR}/tree/a
+  createrandom ${TEST_SCRATCH_DIR}/tree/a/small 100
+  createrandom ${TEST_SCRATCH_DIR}/tree/large 300000
+
+  run_buildah from $WITH_POLICY_JSON scratch
+  cid=$output
+  run_buildah copy $cid ${TEST_SCRATCH_DIR}/tree /default
+  digest="$output"
+  run_buildah copy --no-clone $cid ${TEST_SCRATCH_DIR}/tree /noclone
+  # the content digest should not depend on how the contents were copied
+  expect_output "$digest"
+
+  run_buildah mount $cid
+  root=$output
+  diff -r ${TEST_SCRATCH_DIR}/tree $root/default
+  diff -r ${TEST_SCRATCH_DIR}/tree $root/noclone
+  assert $(stat -c %i ${TEST_SCRATCH_DIR}/tree/large) != $(stat -c %i $root/noclone/large) "--no-clone should have made a copy"
+}
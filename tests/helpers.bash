@@ -1114,3 +1114,22 @@ function convert_v1_shares_to_v2_weight() {
   test -n "$newconverted"
   echo "$oldconverted" "$newconverted"
 }
+
+# start_serve starts "buildah serve" with the specified options, and sets
+# $serve_pid and $serve_address.
+function start_serve() {
+  local log=$TEST_SCRATCH_DIR/serve.log
+  ${BUILDAH_BINARY} ${BUILDAH_REGISTRY_OPTS} ${ROOTDIR_OPTS} serve --listen 127.0.0.1:0 "$@" 2> $log &
+  serve_pid=$!
+  for i in $(seq 1 50); do
+    grep -q "Listening for requests at" $log && break
+    sleep 0.1
+  done
+  serve_address=$(sed -n -e 's/^Listening for requests at "\(.*\)"$/\1/p' $log)
+  test -n "$serve_address"
+}
+
+function stop_serve() {
+  kill -TERM $serve_pid
+  wait $serve_pid
+}
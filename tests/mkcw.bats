@@ -99,6 +99,13 @@ function mkcw_check_image() {
 
   run_buildah commit --iidfile $iidfile --cw type=sev,ignore_attestation_errors,passphrase="$passphrase" "$ctrID"
   mkcw_check_image $(< $iidfile)
+
+  run_buildah commit --iidfile $iidfile --cw type=tdx,ignore_attestation_errors,passphrase="$passphrase" "$ctrID"
+  mkcw_check_image $(< $iidfile)
+  run_buildah from $(< $iidfile)
+  run_buildah mount "$output"
+  run jq -r .tee "$output"/krun-sev.json
+  assert "$output" = "tdx" "TEE type recorded in krun-sev.json"
 }
 
 @test "mkcw build" {
@@ -122,3 +129,72 @@ function mkcw_check_image() {
   run_buildah build --iidfile "$TEST_SCRATCH_DIR"/iid --cw type=SEV,ignore_attestation_errors,passphrase="mkcw build --layers" --layers -f bud/env/Dockerfile.check-env bud/env
   mkcw_check_image $(< "$TEST_SCRATCH_DIR"/iid)
 }
+
+@test "mkcw inspect and extract" {
+  if ! which debugfs > /dev/null 2> /dev/null ; then
+    skip "debugfs not found"
+  fi
+  _prefetch busybox
+  passphrase="mkcw extract $(random_string)"
+  run_buildah mkcw --ignore-attestation-errors --type tdx --workload-id inspect-me --cpus 3 --memory 768 --passphrase="$passphrase" busybox busybox-cw
+
+  run_buildah mkcw inspect busybox-cw
+  run jq -r '.tee, .workload_id, .cpus, .ram_mib' <<< "$output"
+  assert "${lines[0]}" = "tdx" "TEE type"
+  assert "${lines[1]}" = "inspect-me" "workload ID"
+  assert "${lines[2]}" = "3" "CPUs"
+  assert "${lines[3]}" = "768" "memory"
+  run_buildah mkcw inspect --format '{{.Type}}/{{.WorkloadID}}' busybox-cw
+  expect_output "tdx/inspect-me"
+
+  run_buildah 125 mkcw inspect busybox
+  expect_output --substring "does not contain /disk.img"
+
+  # an image with the same name as a subcommand can still be converted
+  run_buildah 125 mkcw inspect busybox busybox-cw
+  expect_output --substring "buildah mkcw -- inspect destination"
+  run_buildah tag busybox inspect
+  run_buildah mkcw --ignore-attestation-errors --type tdx --workload-id named-inspect --passphrase="$passphrase" -- inspect inspect-cw
+  run_buildah mkcw inspect --format '{{.WorkloadID}}' inspect-cw
+  expect_output "named-inspect"
+
+  run_buildah 125 mkcw extract busybox-cw ${TEST_SCRATCH_DIR}/rootfs
+  expect_output --substring "passphrase is required"
+  run_buildah 125 mkcw extract --passphrase "not $passphrase" busybox-cw ${TEST_SCRATCH_DIR}/rootfs
+  expect_output --substring "unlocking disk image"
+
+  run_buildah mkcw extract --passphrase "$passphrase" busybox-cw ${TEST_SCRATCH_DIR}/rootfs
+  test -s ${TEST_SCRATCH_DIR}/rootfs/.krun_config.json
+  run_buildah from busybox
+  run_buildah mount "$output"
+  cmp "$output"/bin/sh ${TEST_SCRATCH_DIR}/rootfs/bin/sh
+}
+
+@test "mkcw encryption and verity choices" {
+  if ! which debugfs > /dev/null 2> /dev/null ; then
+    skip "debugfs not found"
+  fi
+  _prefetch busybox
+  run_buildah 125 mkcw --ignore-attestation-errors --encryption none busybox busybox-cw
+  expect_output --substring "neither encrypted nor protected by dm-verity"
+  run_buildah 125 mkcw --ignore-attestation-errors --filesystem vfat --passphrase secret busybox busybox-cw
+  expect_output --substring "vfat"
+
+  run_buildah mkcw --ignore-attestation-errors --encryption none --verity busybox busybox-verity
+  run_buildah mkcw inspect --format '{{.Encryption}}' busybox-verity
+  expect_output "none"
+  run_buildah mkcw inspect busybox-verity
+  run jq -r '.verity_root_hash' <<< "$output"
+  assert "$output" =~ "^[0-9a-f]{64}$" "verity root hash"
+  run_buildah mkcw extract busybox-verity ${TEST_SCRATCH_DIR}/verity
+  test -s ${TEST_SCRATCH_DIR}/verity/bin/sh
+
+  printf 'mkcw key file\n%s' "$(random_string)" > ${TEST_SCRATCH_DIR}/key
+  run_buildah mkcw --ignore-attestation-errors --encryption luks2 --key-file ${TEST_SCRATCH_DIR}/key busybox busybox-luks2
+  run_buildah mkcw inspect --format '{{.Encryption}}' busybox-luks2
+  expect_output "luks2"
+  run_buildah 125 mkcw extract --passphrase "mkcw key file" busybox-luks2 ${TEST_SCRATCH_DIR}/luks2
+  expect_output --substring "unlocking disk image"
+  run_buildah mkcw extract --key-file ${TEST_SCRATCH_DIR}/key busybox-luks2 ${TEST_SCRATCH_DIR}/luks2
+  cmp ${TEST_SCRATCH_DIR}/verity/bin/sh ${TEST_SCRATCH_DIR}/luks2/bin/sh
+}
@@ -0,0 +1,93 @@
+#!/usr/bin/env bats
+
+load helpers
+
+@test "optimize" {
+  createrandom ${TEST_SCRATCH_DIR}/first 50000
+  createrandom ${TEST_SCRATCH_DIR}/second 100000
+  createrandom ${TEST_SCRATCH_DIR}/app 1000
+  run_buildah from scratch
+  cid=$output
+  run_buildah copy $cid ${TEST_SCRATCH_DIR}/first /data/file
+  run_buildah copy $cid ${TEST_SCRATCH_DIR}/first /data/deleted
+  run_buildah commit $WITH_POLICY_JSON --rm $cid layer1
+  run_buildah from --quiet layer1
+  cid=$output
+  run_buildah copy $cid ${TEST_SCRATCH_DIR}/second /data/file
+  run_buildah mount $cid
+  rm -f $output/data/deleted
+  run_buildah commit $WITH_POLICY_JSON --rm $cid layer2
+  run_buildah from --quiet layer2
+  cid=$output
+  run_buildah copy $cid ${TEST_SCRATCH_DIR}/app /app/main
+  run_buildah config --env FOO=bar --entrypoint '["/app/main"]' $cid
+  run_buildah commit $WITH_POLICY_JSON --rm $cid original
+  run_buildah inspect -t image -f '{{len .OCIv1.RootFS.DiffIDs}}' original
+  expect_output 3
+
+  run_buildah 125 optimize $WITH_POLICY_JSON original
+  expect_output --substring "must be specified using --output"
+
+  # the small layers are merged, and the overwritten and deleted files dropped
+  run_buildah optimize $WITH_POLICY_JSON --output optimized original
+  expect_output --substring "rewrote 3 layers as 1, dropping 100000 bytes"
+  run_buildah inspect -t image -f '{{len .OCIv1.RootFS.DiffIDs}}' optimized
+  expect_output 1
+  run_buildah inspect -t image -f '{{.OCIv1.Config.Env}} {{.OCIv1.Config.Entrypoint}}' optimized
+  expect_output "[FOO=bar] [/app/main]"
+  run_buildah inspect -t image -f '{{range .OCIv1.History}}{{.Comment}}{{"\n"}}{{end}}' optimized
+  expect_output --substring "buildah optimize: from layers 0-2 of image"
+  run_buildah analyze optimized
+  expect_output --substring "Wasted space: +0 B"
+
+  run_buildah from --quiet optimized
+  cid=$output
+  run_buildah mount $cid
+  mountpoint=$output
+  cmp ${mountpoint}/data/file ${TEST_SCRATCH_DIR}/second
+  cmp ${mountpoint}/app/main ${TEST_SCRATCH_DIR}/app
+  test ! -e ${mountpoint}/data/deleted
+
+  # without merging, the application gets a layer of its own, and the base
+  # layer, none of whose contents survived, is dropped
+  run_buildah optimize $WITH_POLICY_JSON -q --merge-size 0 --split-path /app --iidfile ${TEST_SCRATCH_DIR}/iid --output split original
+  expect_output ""
+  run_buildah inspect -t image -f '{{len .OCIv1.RootFS.DiffIDs}}' $(cat ${TEST_SCRATCH_DIR}/iid)
+  expect_output 2
+  run_buildah inspect -t image -f '{{range .OCIv1.History}}{{.Comment}}{{"\n"}}{{end}}' split
+  expect_output --substring "buildah optimize: /app, split out of layer 2 of image"
+
+  run_buildah 125 optimize $WITH_POLICY_JSON --merge-size bogus --output bogus original
+  expect_output --substring "parsing --merge-size value"
+}
+
+@test "optimize-hard-link-to-updated-file" {
+  createrandom ${TEST_SCRATCH_DIR}/old 1000
+  # a different size, so that the change is noticed even within the same second
+  createrandom ${TEST_SCRATCH_DIR}/new 2000
+  run_buildah from scratch
+  cid=$output
+  run_buildah copy $cid ${TEST_SCRATCH_DIR}/old /usr/bin/tool
+  run_buildah mount $cid
+  ln $output/usr/bin/tool $output/usr/bin/tool-alias
+  ln $output/usr/bin/tool $output/usr/bin/tool-other
+  run_buildah commit $WITH_POLICY_JSON --rm $cid linked
+  run_buildah from --quiet linked
+  cid=$output
+  run_buildah mount $cid
+  # replace the file without affecting the other links, like a package update
+  cp ${TEST_SCRATCH_DIR}/new $output/usr/bin/tool.new
+  mv -f $output/usr/bin/tool.new $output/usr/bin/tool
+  run_buildah commit $WITH_POLICY_JSON --rm $cid updated
+
+  # the links survive, with the contents they had
+  run_buildah optimize $WITH_POLICY_JSON --merge-size 0 --output optimized-links updated
+  run_buildah from --quiet optimized-links
+  cid=$output
+  run_buildah mount $cid
+  mountpoint=$output
+  cmp ${mountpoint}/usr/bin/tool ${TEST_SCRATCH_DIR}/new
+  cmp ${mountpoint}/usr/bin/tool-alias ${TEST_SCRATCH_DIR}/old
+  cmp ${mountpoint}/usr/bin/tool-other ${TEST_SCRATCH_DIR}/old
+  test $(stat -c %i ${mountpoint}/usr/bin/tool-alias) = $(stat -c %i ${mountpoint}/usr/bin/tool-other)
+}
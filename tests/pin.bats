@@ -0,0 +1,66 @@
+#!/usr/bin/env bats
+
+load helpers
+
+@test "pin and outdated" {
+  run_buildah from scratch
+  run_buildah commit $WITH_POLICY_JSON $output pinned
+  start_serve
+
+  cat > $TEST_SCRATCH_DIR/Containerfile <<_EOF
+ARG BASE=scratch
+FROM $serve_address/pinned:latest AS build
+FROM \$BASE
+COPY --from=build / /
+COPY --from=$serve_address/pinned / /
+_EOF
+  run_buildah pin --tls-verify=false $TEST_SCRATCH_DIR/Containerfile
+  expect_output --substring "line 3: not pinning .*which uses build arguments"
+  expect_output --substring "line 2: $serve_address/pinned:latest -> $serve_address/pinned:latest@sha256:"
+  expect_output --substring "line 5: $serve_address/pinned -> $serve_address/pinned@sha256:"
+  run grep -c "@sha256:" $TEST_SCRATCH_DIR/Containerfile
+  expect_output 2
+  run grep "COPY --from=build / /" $TEST_SCRATCH_DIR/Containerfile
+  assert "$status" -eq 0 "references to stages should be left alone"
+
+  # nothing has changed
+  run_buildah outdated --tls-verify=false $TEST_SCRATCH_DIR/Containerfile
+  expect_output --substring "$serve_address/pinned:latest +current"
+  run_buildah pin --tls-verify=false $TEST_SCRATCH_DIR/Containerfile
+  assert "$output" !~ " -> "
+
+  # the image is only available for one platform
+  run_buildah 125 pin --tls-verify=false --update --platform linux/nonesuch $TEST_SCRATCH_DIR/Containerfile
+  expect_output --substring "is only available for"
+
+  # update the tag
+  run_buildah from scratch
+  run_buildah config --label updated=true $output
+  run_buildah commit $WITH_POLICY_JSON $output pinned
+  run_buildah 1 outdated --tls-verify=false --json $TEST_SCRATCH_DIR/Containerfile
+  expect_output --substring '"updated": true'
+  run_buildah 1 outdated --tls-verify=false $TEST_SCRATCH_DIR/Containerfile
+  expect_output --substring "$serve_address/pinned:latest +outdated"
+  run_buildah pin --tls-verify=false --update --source-policy-file $TEST_SCRATCH_DIR/policy.json $TEST_SCRATCH_DIR/Containerfile
+  run cat $TEST_SCRATCH_DIR/policy.json
+  expect_output --substring '"action": "CONVERT"'
+  run_buildah pin --tls-verify=false --update $TEST_SCRATCH_DIR/Containerfile
+  expect_output --substring "line 2: $serve_address/pinned:latest@sha256:"
+  run_buildah outdated --tls-verify=false $TEST_SCRATCH_DIR/Containerfile
+  assert "$output" !~ "outdated"
+
+  # references to additional build contexts aren't looked up
+  cat > $TEST_SCRATCH_DIR/Containerfile.contexts <<_EOF
+FROM base
+COPY --from=sources / /
+COPY --from=$serve_address/pinned:latest / /
+_EOF
+  run_buildah pin --tls-verify=false --build-context base=docker-image://$serve_address/pinned --build-context sources=$TEST_SCRATCH_DIR $TEST_SCRATCH_DIR/Containerfile.contexts
+  expect_output --substring "line 3: $serve_address/pinned:latest -> $serve_address/pinned:latest@sha256:"
+  assert "$output" !~ "line [12]:" "references to build contexts should be left alone"
+  run grep -c "@sha256:" $TEST_SCRATCH_DIR/Containerfile.contexts
+  expect_output 1
+  run_buildah 125 pin --tls-verify=false --build-context =$TEST_SCRATCH_DIR $TEST_SCRATCH_DIR/Containerfile.contexts
+  expect_output --substring "has no name"
+  stop_serve
+}
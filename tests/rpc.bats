@@ -8,3 +8,99 @@ load helpers
   run_buildah rpc --env LISTENER ${GRPCNOOP_BINARY} --env LISTENER first-arg second-arg
   assert "$output" = 'ignored:"first-arg,second-arg"'
 }
+
+# start_service starts "buildah system service" with the specified options,
+# listening at $TEST_SCRATCH_DIR/service.sock, and sets $service_pid and
+# $service_socket.
+function start_service() {
+  service_socket=$TEST_SCRATCH_DIR/service.sock
+  ${BUILDAH_BINARY} ${BUILDAH_REGISTRY_OPTS} ${ROOTDIR_OPTS} system service --listen $service_socket "$@" 2> $TEST_SCRATCH_DIR/service.log &
+  service_pid=$!
+  for i in $(seq 1 50); do
+    test -S $service_socket && break
+    sleep 0.1
+  done
+  test -S $service_socket
+}
+
+function stop_service() {
+  kill -TERM $service_pid
+  wait $service_pid
+}
+
+@test "system service" {
+  start_service
+  run ${GRPCNOOP_BINARY} --connect unix://$service_socket first-arg second-arg
+  assert "$status" -eq 0 "poking the service"
+  assert "$output" = 'ignored:"first-arg,second-arg"'
+  stop_service
+  test ! -e $service_socket
+  run cat $TEST_SCRATCH_DIR/service.log
+  expect_output --substring "Listening for requests at \"$service_socket\""
+}
+
+@test "system build" {
+  local contextdir=$TEST_SCRATCH_DIR/context
+  mkdir -p $contextdir/subdir
+  createrandom $contextdir/subdir/randomfile
+  createrandom $contextdir/debug.log
+  echo '*.log' > $contextdir/.containerignore
+  printf 'FROM scratch\nARG NAME\nLABEL name=$NAME\nCOPY . /\n' > $contextdir/Containerfile
+  printf 'FROM scratch\nCOPY subdir /\n' > $contextdir/Containerfile.other
+  start_service --context-dir $TEST_SCRATCH_DIR/contexts
+
+  run_buildah system build --connect $service_socket -t localhost/built --build-arg NAME=value $contextdir
+  expect_output --substring "COPY . /"
+  run_buildah inspect -t image -f '{{index .OCIv1.Config.Labels "name"}}' localhost/built
+  expect_output value
+  run_buildah from --quiet localhost/built
+  cid=$output
+  run_buildah mount $cid
+  mountpoint=$output
+  cmp $contextdir/subdir/randomfile $mountpoint/subdir/randomfile
+  test ! -e $mountpoint/debug.log
+
+  run_buildah system build --connect $service_socket -q -f Containerfile.other -t localhost/other --iidfile $TEST_SCRATCH_DIR/iid $contextdir
+  expect_output ""
+  run_buildah inspect -t image -f '{{.FromImageID}}' localhost/other
+  expect_output "$(sed -e 's/^sha256://' $TEST_SCRATCH_DIR/iid)"
+
+  run_buildah 125 system build --connect $service_socket -f ../Containerfile $contextdir
+  expect_output --substring "not a relative path in the build context"
+  run_buildah 125 system build --connect $service_socket -f Containerfile.missing $contextdir
+  expect_output --substring "Containerfile.missing"
+
+  stop_service
+}
+
+@test "system build --session-file" {
+  local contextdir=$TEST_SCRATCH_DIR/context
+  mkdir -p $contextdir/subdir
+  createrandom $contextdir/subdir/first
+  createrandom $contextdir/subdir/second
+  printf 'FROM scratch\nCOPY subdir /subdir\n' > $contextdir/Containerfile
+  start_service
+
+  # the first build sends everything, and the next one only what changed
+  run_buildah system build --connect $service_socket --session-file $TEST_SCRATCH_DIR/session -t localhost/synced $contextdir
+  expect_output --substring "Sent 3 changed items"
+  session=$(cat $TEST_SCRATCH_DIR/session)
+  test -n "$session"
+  createrandom $contextdir/subdir/second 2000
+  run_buildah system build --connect $service_socket --session-file $TEST_SCRATCH_DIR/session -t localhost/synced $contextdir
+  expect_output --substring "Sent 1 changed items from \"$contextdir\" to session $session"
+  run_buildah from --quiet localhost/synced
+  cid=$output
+  run_buildah mount $cid
+  mountpoint=$output
+  cmp $contextdir/subdir/first $mountpoint/subdir/first
+  cmp $contextdir/subdir/second $mountpoint/subdir/second
+
+  # a new session is started if the service no longer has the old one
+  stop_service
+  start_service
+  run_buildah system build --connect $service_socket --session-file $TEST_SCRATCH_DIR/session $contextdir
+  expect_output --substring "Sent 3 changed items"
+  assert "$(cat $TEST_SCRATCH_DIR/session)" != "$session"
+  stop_service
+}
@@ -1098,3 +1098,42 @@ _EOF
   run_buildah 125 run --valid-exit-codes 1 $cid sh -c "exit 0"
   expect_output --substring "not in the valid exit codes list"
 }
+
+@test "run --detach, exec, logs and stop" {
+  skip_if_no_runtime
+
+  _prefetch alpine
+  run_buildah from --quiet --pull=false $WITH_POLICY_JSON alpine
+  cid=$output
+
+  run_buildah 125 run --detach --terminal $cid sleep 1
+  expect_output --substring "cannot be used together"
+
+  run_buildah run --detach $cid sh -c 'echo started; touch /tmp/started; exec sleep 600'
+  runid=$output
+  assert "$runid" =~ "^[0-9a-f]{64}$"
+
+  # The container can't be removed while the command is running.
+  run_buildah 125 rm $cid
+  expect_output --substring "is still running"
+
+  run_buildah exec $cid test -f /tmp/started
+  run_buildah exec --run ${runid:0:12} --env FOO=bar $cid sh -c 'echo $FOO'
+  expect_output "bar"
+
+  run_buildah logs $cid
+  expect_output "started"
+
+  run_buildah stop --time 1 $cid
+  expect_output "$runid"
+  run_buildah 125 exec $cid true
+  expect_output --substring "is not running"
+
+  # A command which exits by itself has its output kept, too.
+  run_buildah run --detach $cid sh -c 'echo done'
+  runid=$output
+  run_buildah logs --follow --run $runid $cid
+  expect_output "done"
+
+  run_buildah rm $cid
+}
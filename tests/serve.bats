@@ -0,0 +1,40 @@
+#!/usr/bin/env bats
+
+load helpers
+
+@test "serve" {
+  createrandom $TEST_SCRATCH_DIR/randomfile
+  run_buildah from scratch
+  cid=$output
+  run_buildah copy $cid $TEST_SCRATCH_DIR/randomfile /randomfile
+  run_buildah commit $WITH_POLICY_JSON $cid served
+  run_buildah inspect --type=image --format '{{.FromImageID}}' served
+  iid=$output
+
+  start_serve
+  # pull by tag, using the unqualified name
+  run_buildah pull $WITH_POLICY_JSON --tls-verify=false $serve_address/served
+  run_buildah inspect --type=image --format '{{.FromImageID}}' $serve_address/served
+  expect_output $iid
+  # the same image, using its fully-qualified name
+  run_buildah pull $WITH_POLICY_JSON --tls-verify=false $serve_address/localhost/served:latest
+  run_buildah 125 pull $WITH_POLICY_JSON --tls-verify=false $serve_address/nonexistent
+  # pushing isn't allowed unless --read-write is used
+  run_buildah 125 push $WITH_POLICY_JSON --tls-verify=false --retry=0 served docker://$serve_address/pushed:v1
+  expect_output --substring "pushing is not enabled"
+  stop_serve
+
+  start_serve --read-write
+  run_buildah push $WITH_POLICY_JSON --tls-verify=false served docker://$serve_address/pushed:v1
+  run_buildah inspect --type=image --format '{{.FromImageID}}' localhost/pushed:v1
+  expect_output $iid
+
+  # manifest lists
+  run_buildah manifest create servedlist served
+  run_buildah manifest inspect --tls-verify=false $serve_address/servedlist
+  expect_output --substring '"mediaType": "application/vnd.oci.image.index.v1+json"'
+  run_buildah manifest push $WITH_POLICY_JSON --tls-verify=false --all servedlist docker://$serve_address/pushedlist:v1
+  run_buildah manifest inspect localhost/pushedlist:v1
+  expect_output --substring '"mediaType": "application/vnd.oci.image.index.v1+json"'
+  stop_serve
+}
@@ -105,3 +105,72 @@ function _gpg_setup() {
   ls -l ${TEST_SCRATCH_DIR}/signed-image/
   test -s ${TEST_SCRATCH_DIR}/signed-image/signature-1
 }
+
+function _sigstore_setup() {
+  if ! which skopeo > /dev/null 2> /dev/null ; then
+    skip 'skopeo command not found in $PATH'
+  fi
+  echo -n sigstore-passphrase > ${TEST_SCRATCH_DIR}/sigstore-passphrase
+  skopeo generate-sigstore-key --output-prefix ${TEST_SCRATCH_DIR}/sigstore --passphrase-file ${TEST_SCRATCH_DIR}/sigstore-passphrase
+  # verify applies the policy for images in local storage, which is scoped
+  # by storage driver and location
+  run_buildah info --format '[{{.store.GraphDriverName}}@{{.store.GraphRoot}}]'
+  local storespec="$output"
+  cat > ${TEST_SCRATCH_DIR}/sigstore-policy.json <<- EOF
+	{
+	  "default": [{"type": "insecureAcceptAnything"}],
+	  "transports": {
+	    "containers-storage": {
+	      "${storespec}registry.example.com": [
+	        {"type": "sigstoreSigned", "keyPath": "${TEST_SCRATCH_DIR}/sigstore.pub", "signedIdentity": {"type": "matchRepoDigestOrExact"}}
+	      ]
+	    }
+	  }
+	}
+	EOF
+}
+
+@test "commit-build-verify-sigstore-signatures" {
+  _sigstore_setup
+
+  run_buildah from --quiet $WITH_POLICY_JSON scratch
+  cid=$output
+  run_buildah commit $WITH_POLICY_JSON --sign-by-sigstore-private-key ${TEST_SCRATCH_DIR}/sigstore.private --sign-passphrase-file ${TEST_SCRATCH_DIR}/sigstore-passphrase $cid registry.example.com/signed
+  run_buildah commit $WITH_POLICY_JSON $cid registry.example.com/unsigned
+
+  run_buildah verify --policy ${TEST_SCRATCH_DIR}/sigstore-policy.json registry.example.com/signed
+  run_buildah 125 verify --policy ${TEST_SCRATCH_DIR}/sigstore-policy.json registry.example.com/unsigned
+  expect_output --substring "A signature was required, but no signature exists"
+  # the unsigned image isn't named after a location the policy cares about
+  run_buildah tag registry.example.com/unsigned other.example.com/unsigned
+  run_buildah verify -q --policy ${TEST_SCRATCH_DIR}/sigstore-policy.json other.example.com/unsigned
+  expect_output ""
+
+  # a passphrase file without a key is an error, as is a key with a bad passphrase
+  run_buildah 125 commit $WITH_POLICY_JSON --sign-passphrase-file ${TEST_SCRATCH_DIR}/sigstore-passphrase $cid registry.example.com/unsigned
+  expect_output --substring "only meaningful with"
+  echo -n wrong > ${TEST_SCRATCH_DIR}/wrong-passphrase
+  run_buildah 125 commit $WITH_POLICY_JSON --sign-by-sigstore-private-key ${TEST_SCRATCH_DIR}/sigstore.private --sign-passphrase-file ${TEST_SCRATCH_DIR}/wrong-passphrase $cid registry.example.com/unsigned
+
+  # a parameter file works, too
+  cat > ${TEST_SCRATCH_DIR}/sigstore-params.yaml <<- EOF
+	privateKeyFile: ${TEST_SCRATCH_DIR}/sigstore.private
+	privateKeyPassphraseFile: ${TEST_SCRATCH_DIR}/sigstore-passphrase
+	EOF
+  builddir=${TEST_SCRATCH_DIR}/builddir
+  mkdir -p $builddir
+  cat > ${builddir}/Dockerfile <<- EOF
+	FROM scratch
+	ADD Dockerfile /
+	EOF
+  run_buildah build $WITH_POLICY_JSON --sign-by-sigstore ${TEST_SCRATCH_DIR}/sigstore-params.yaml -t registry.example.com/built ${builddir}
+  run_buildah verify --policy ${TEST_SCRATCH_DIR}/sigstore-policy.json registry.example.com/built
+
+  # signing requires a destination with a registry-style name
+  run_buildah 125 push $WITH_POLICY_JSON --sign-by-sigstore-private-key ${TEST_SCRATCH_DIR}/sigstore.private --sign-passphrase-file ${TEST_SCRATCH_DIR}/sigstore-passphrase registry.example.com/unsigned dir:${TEST_SCRATCH_DIR}/signed-image
+  expect_output --substring "Cannot determine canonical Docker reference"
+  run_buildah manifest create list
+  run_buildah manifest add list registry.example.com/unsigned
+  run_buildah 125 manifest push $WITH_POLICY_JSON --sign-by-sigstore ${TEST_SCRATCH_DIR}/sigstore-params.yaml --all list dir:${TEST_SCRATCH_DIR}/signed-list
+  expect_output --substring "Cannot determine canonical Docker reference"
+}
@@ -153,3 +153,201 @@ load helpers
   # FIXME: if there's a nonzero chance of this failing, include actual diffs
   assert "$status" -eq 0 "status from diff of srcdir vs pulldir"
 }
+
+@test "build --source-image" {
+  _prefetch busybox
+  contextdir=${TEST_SCRATCH_DIR}/context
+  mkdir -p $contextdir/ignored
+  echo kept > $contextdir/kept
+  echo ignored > $contextdir/ignored/file
+  echo ignored > $contextdir/.containerignore
+  cat > $contextdir/Containerfile << _EOF
+FROM busybox
+COPY kept /kept
+_EOF
+
+  srcdir=${TEST_SCRATCH_DIR}/source
+  run_buildah build $WITH_POLICY_JSON -t source-test --source-image oci:$srcdir $contextdir
+  run_buildah inspect --format '{{.FromImageDigest}}' source-test
+  imageDigest="$output"
+
+  run jq -r .manifests[0].digest $srcdir/index.json
+  manifestDigest=${output//sha256:/} # strip off the sha256 prefix
+  manifest=$srcdir/blobs/sha256/$manifestDigest
+  run jq -r '.annotations["io.buildah.source.image.digest"]' $manifest
+  expect_output "$imageDigest"
+  run jq -r '.annotations["io.buildah.source.image.name"]' $manifest
+  expect_output "localhost/source-test"
+  run jq -r '.layers | length' $manifest
+  expect_output "1"
+  run jq -r '.layers[0].annotations["io.buildah.source.artifact"]' $manifest
+  expect_output "context"
+
+  # the layer holds the context, minus anything that was ignored
+  run jq -r .layers[0].digest $manifest
+  run tar tzf $srcdir/blobs/sha256/${output//sha256:/}
+  expect_output --substring "kept"
+  assert "$output" !~ "ignored/file"
+
+  run_buildah 125 build $WITH_POLICY_JSON --platform linux/amd64,linux/arm64 --source-image oci:${TEST_SCRATCH_DIR}/other $contextdir
+  expect_output --substring "can only describe one image"
+}
+
+@test "build --source-image with remote content" {
+  contentdir=${TEST_SCRATCH_DIR}/content
+  mkdir -p $contentdir
+  createrandom $contentdir/remote
+  starthttpd $contentdir
+  checksum=sha256:$(sha256sum $contentdir/remote | cut -f1 -d' ')
+  contextdir=${TEST_SCRATCH_DIR}/context
+  mkdir -p $contextdir
+  cat > $contextdir/Containerfile << _EOF
+FROM scratch
+ADD --checksum=$checksum http://0.0.0.0:${HTTP_SERVER_PORT}/remote /remote
+_EOF
+
+  srcdir=${TEST_SCRATCH_DIR}/source
+  run_buildah build $WITH_POLICY_JSON --layers=false --source-image oci:$srcdir $contextdir
+
+  run jq -r .manifests[0].digest $srcdir/index.json
+  manifestDigest=${output//sha256:/} # strip off the sha256 prefix
+  manifest=$srcdir/blobs/sha256/$manifestDigest
+  run jq -r '.layers | length' $manifest
+  expect_output "2"
+  run jq -r '.layers[1].annotations["io.buildah.source.artifact"]' $manifest
+  expect_output "http://0.0.0.0:${HTTP_SERVER_PORT}/remote"
+
+  # the layer holds what the build downloaded
+  run jq -r .layers[1].digest $manifest
+  mkdir ${TEST_SCRATCH_DIR}/extracted
+  tar -C ${TEST_SCRATCH_DIR}/extracted -xzf $srcdir/blobs/sha256/${output//sha256:/}
+  cmp $contentdir/remote ${TEST_SCRATCH_DIR}/extracted/remote
+
+  # content which doesn't match the checksum fails the build, and never
+  # makes it into a source image
+  createrandom $contentdir/remote
+  run_buildah 125 build $WITH_POLICY_JSON --layers=false --source-image oci:${TEST_SCRATCH_DIR}/other $contextdir
+  expect_output --substring "unexpected response digest"
+  test ! -e ${TEST_SCRATCH_DIR}/other
+  stophttpd
+}
+
+@test "build --source-image with source packages" {
+  _prefetch alpine
+  contextdir=${TEST_SCRATCH_DIR}/context
+  mkdir -p $contextdir
+  cat > $contextdir/Containerfile << _EOF
+FROM scratch
+COPY sbom.json /sbom.json
+_EOF
+  # the "scan" just reports what's in this file
+  cat > $contextdir/sbom.json << _EOF
+{"bomFormat":"CycloneDX","components":[
+ {"name":"tool","version":"1.0-1","purl":"pkg:rpm/example/tool@1.0-1?arch=x86_64&upstream=tool-1.0-1.src.rpm"},
+ {"name":"libtool1","version":"2.0-1+b1","purl":"pkg:deb/example/libtool1@2.0-1%2Bb1?arch=amd64&upstream=tool2"},
+ {"name":"module","version":"v1.0.0","purl":"pkg:golang/example.com/module@v1.0.0"}
+]}
+_EOF
+  sbomflags=(--sbom-scanner-image=alpine "--sbom-scanner-command=cp {CONTEXT}/sbom.json {OUTPUT}" --sbom-merge-strategy=merge-cyclonedx-by-component-name-and-version --sbom-purl-output=${TEST_SCRATCH_DIR}/purl.json)
+
+  # the source RPM is found locally, and the Debian source package's files
+  # are found over http
+  localdir=${TEST_SCRATCH_DIR}/local
+  mkdir -p $localdir
+  createrandom $localdir/tool-1.0-1.src.rpm
+  remotedir=${TEST_SCRATCH_DIR}/remote
+  mkdir -p $remotedir
+  createrandom $remotedir/tool2_2.0.orig.tar.gz
+  cat > $remotedir/tool2_2.0-1.dsc << _EOF
+Format: 3.0 (quilt)
+Source: tool2
+Version: 2.0-1
+Checksums-Sha256:
+ $(sha256sum $remotedir/tool2_2.0.orig.tar.gz | cut -f1 -d' ') $(stat -c %s $remotedir/tool2_2.0.orig.tar.gz) tool2_2.0.orig.tar.gz
+_EOF
+  starthttpd $remotedir
+
+  srcdir=${TEST_SCRATCH_DIR}/source
+  run_buildah build $WITH_POLICY_JSON "${sbomflags[@]}" --source-image oci:$srcdir --source-image-packages $localdir --source-image-packages http://0.0.0.0:${HTTP_SERVER_PORT}/ $contextdir
+  stophttpd
+
+  run jq -r .manifests[0].digest $srcdir/index.json
+  manifestDigest=${output//sha256:/} # strip off the sha256 prefix
+  manifest=$srcdir/blobs/sha256/$manifestDigest
+  run jq -r '.layers | length' $manifest
+  expect_output "3"
+  # packages are added in the order of their package URLs
+  run jq -r '.layers[1].annotations["io.buildah.source.artifact"]' $manifest
+  expect_output "tool2_2.0-1.dsc"
+  run jq -r '.layers[2].annotations["io.buildah.source.artifact"]' $manifest
+  expect_output "tool-1.0-1.src.rpm"
+  run jq -r .layers[1].digest $manifest
+  mkdir ${TEST_SCRATCH_DIR}/extracted
+  tar -C ${TEST_SCRATCH_DIR}/extracted -xzf $srcdir/blobs/sha256/${output//sha256:/}
+  cmp $remotedir/tool2_2.0-1.dsc ${TEST_SCRATCH_DIR}/extracted/tool2_2.0-1.dsc
+  cmp $remotedir/tool2_2.0.orig.tar.gz ${TEST_SCRATCH_DIR}/extracted/tool2_2.0.orig.tar.gz
+  run jq -r .layers[2].digest $manifest
+  tar -C ${TEST_SCRATCH_DIR}/extracted -xzf $srcdir/blobs/sha256/${output//sha256:/}
+  cmp $localdir/tool-1.0-1.src.rpm ${TEST_SCRATCH_DIR}/extracted/tool-1.0-1.src.rpm
+
+  # a source package which can't be found fails the build
+  run_buildah 125 build $WITH_POLICY_JSON "${sbomflags[@]}" --source-image oci:${TEST_SCRATCH_DIR}/other --source-image-packages $localdir $contextdir
+  expect_output --substring "tool2_2.0-1.dsc\": not found"
+
+  # the package URLs have to come from somewhere
+  run_buildah 125 build $WITH_POLICY_JSON --source-image oci:${TEST_SCRATCH_DIR}/other --source-image-packages $localdir $contextdir
+  expect_output --substring "requires SBOM scanning"
+  run_buildah 125 build $WITH_POLICY_JSON "${sbomflags[@]}" --source-image-packages $localdir $contextdir
+  expect_output --substring "only be added to a source image"
+}
+
+@test "source inspect/ls/extract/verify" {
+  srcdir=${TEST_SCRATCH_DIR}/newsource
+  run_buildah source create --author="Buildah authors" $srcdir
+  echo 111 > ${TEST_SCRATCH_DIR}/file1
+  run_buildah source add --annotation org.opencontainers.image.title=file1 $srcdir ${TEST_SCRATCH_DIR}/file1
+  mkdir ${TEST_SCRATCH_DIR}/dir2
+  echo 222 > ${TEST_SCRATCH_DIR}/dir2/file2
+  run_buildah source add --annotation key=value $srcdir ${TEST_SCRATCH_DIR}/dir2
+  run_buildah source ls --noheading --format '{{.Digest}}' $srcdir
+  dir2Digest=${lines[1]}
+
+  run_buildah source inspect --format '{{.Config.Author}}' $srcdir
+  expect_output "Buildah authors"
+  run_buildah source inspect $srcdir
+  run jq -r '.artifacts | length' <<< "$output"
+  expect_output "2"
+  # an artifact without a title is named after its digest
+  run_buildah source inspect --format '{{range .Artifacts}}{{.Name}} {{index .Annotations "key"}};{{end}}' $srcdir
+  expect_output "file1 ;$dir2Digest value;"
+
+  run_buildah source ls $srcdir
+  expect_line_count 3
+  expect_output --substring "NAME +DIGEST +SIZE"
+  run_buildah source ls --noheading --format '{{.Name}}' $srcdir
+  expect_output "file1
+$dir2Digest"
+
+  run_buildah source extract -o ${TEST_SCRATCH_DIR}/all $srcdir
+  cmp ${TEST_SCRATCH_DIR}/file1 ${TEST_SCRATCH_DIR}/all/file1
+  cmp ${TEST_SCRATCH_DIR}/dir2/file2 ${TEST_SCRATCH_DIR}/all/file2
+  run_buildah source extract --artifact file1 -o ${TEST_SCRATCH_DIR}/one $srcdir
+  run ls ${TEST_SCRATCH_DIR}/one
+  expect_output "file1"
+  dir2Prefix=${dir2Digest#sha256:}
+  run_buildah source extract --artifact ${dir2Prefix:0:12} -o ${TEST_SCRATCH_DIR}/two $srcdir
+  run ls ${TEST_SCRATCH_DIR}/two
+  expect_output "file2"
+  run_buildah 125 source extract --artifact nonexistent -o ${TEST_SCRATCH_DIR}/none $srcdir
+  expect_output --substring "no source artifact matching"
+  run_buildah 125 source extract $srcdir
+  expect_output --substring "output directory must be specified"
+
+  run_buildah source verify $srcdir
+  expect_output ""
+  layer=$srcdir/blobs/sha256/${dir2Prefix}
+  chmod u+w $layer
+  echo garbage >> $layer
+  run_buildah 125 source verify $srcdir
+  expect_output --substring "verifying source artifact \"$dir2Digest\""
+}
@@ -83,3 +83,44 @@ function teardown(){
   run_buildah rm -a
 }
 
+
+@test "bud with ssh key restrictions and known_hosts" {
+  # quay.io/hummingbird/git is only available on these arches:
+  skip_unless_arch amd64 arm64
+  _prefetch quay.io/hummingbird/git
+
+  mytmpdir=${TEST_SCRATCH_DIR}/my-dir1
+  mkdir -p ${mytmpdir}
+  ssh-keygen -t ed25519 -f $mytmpdir/allowed -q -N ""
+  ssh-keygen -t ed25519 -f $mytmpdir/disallowed -q -N ""
+  allowed=$(ssh-keygen -l -f $mytmpdir/allowed | awk '{ print $2; }')
+  disallowed=$(ssh-keygen -l -f $mytmpdir/disallowed | awk '{ print $2; }')
+  echo "git.example.com $(cat $mytmpdir/allowed.pub | cut -d' ' -f1-2)" > $mytmpdir/known_hosts
+
+  contextdir=${TEST_SCRATCH_DIR}/context
+  mkdir -p $contextdir
+  cat > $contextdir/Containerfile << _EOF
+FROM quay.io/hummingbird/git
+USER 0:0
+RUN --mount=type=ssh,id=default,max-signatures=none ssh-add -l
+_EOF
+  run_buildah 125 bud --ssh default=$mytmpdir/allowed $WITH_POLICY_JSON $contextdir
+  expect_output --substring "max-signatures must be a positive integer"
+
+  # the restrictions apply to the RUN instruction which mounts the agent
+  cat > $contextdir/Containerfile << _EOF
+FROM quay.io/hummingbird/git
+USER 0:0
+RUN --mount=type=ssh,id=default,allow=$allowed,max-signatures=1 ssh-add -l -E sha256 | sed 's/^/limited: /' && cat /etc/ssh/ssh_known_hosts
+RUN --mount=type=ssh,id=default ssh-add -l -E sha256 | sed 's/^/open: /'
+_EOF
+  run_buildah bud --ssh default=$mytmpdir/allowed,$mytmpdir/disallowed,known_hosts=$mytmpdir/known_hosts $WITH_POLICY_JSON $contextdir
+  expect_output --substring "git.example.com ssh-ed25519 "
+  buildoutput="$output"
+  run grep -F "limited: 256 $allowed" <<< "$buildoutput"
+  assert "$status" -eq 0 "restricted RUN lists the allowed key"
+  run grep -F "limited: 256 $disallowed" <<< "$buildoutput"
+  assert "$status" -eq 1 "restricted RUN does not list the disallowed key"
+  run grep -F "open: 256 $disallowed" <<< "$buildoutput"
+  assert "$status" -eq 0 "unrestricted RUN lists the disallowed key"
+}